@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ScheduleWindow caps the number of replicas between StartHour (inclusive)
+// and EndHour (exclusive). Windows may wrap around midnight, e.g. 22-06.
+type ScheduleWindow struct {
+	StartHour   int
+	EndHour     int
+	MaxReplicas int
+}
+
+func (w ScheduleWindow) contains(hour int) bool {
+	if w.StartHour <= w.EndHour {
+		return hour >= w.StartHour && hour < w.EndHour
+	}
+	return hour >= w.StartHour || hour < w.EndHour
+}
+
+// parseSchedule parses a comma separated list of "start-end:replicas" entries,
+// for example "08-20:3,20-08:10".
+func parseSchedule(value string) ([]ScheduleWindow, error) {
+	var windows []ScheduleWindow
+	if strings.TrimSpace(value) == "" {
+		return windows, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		hours, replicas, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid schedule entry %q", entry)
+		}
+		start, end, ok := strings.Cut(hours, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid schedule hours %q", hours)
+		}
+
+		startHour, err := strconv.Atoi(start)
+		if err != nil || startHour < 0 || startHour > 23 {
+			return nil, fmt.Errorf("invalid schedule start hour %q", start)
+		}
+		endHour, err := strconv.Atoi(end)
+		if err != nil || endHour < 0 || endHour > 24 {
+			return nil, fmt.Errorf("invalid schedule end hour %q", end)
+		}
+		maxReplicas, err := strconv.Atoi(replicas)
+		if err != nil || maxReplicas < 0 {
+			return nil, fmt.Errorf("invalid schedule replicas %q", replicas)
+		}
+
+		windows = append(windows, ScheduleWindow{
+			StartHour:   startHour,
+			EndHour:     endHour % 24,
+			MaxReplicas: maxReplicas,
+		})
+	}
+
+	return windows, nil
+}
+
+// budget tracks the spend accrued by running replicas and derives the
+// maximum number of replicas the autoscaler is allowed to run right now.
+type budget struct {
+	config     Config
+	day        string
+	spentToday float64
+	lastTick   time.Time
+}
+
+func newBudget(config Config) *budget {
+	return &budget{config: config}
+}
+
+// accrue adds the cost of running the given number of replicas since the
+// previous tick. The daily total resets when the calendar day changes.
+func (b *budget) accrue(now time.Time, replicas int) {
+	today := now.Format("2006-01-02")
+	if b.day != today {
+		if b.day != "" {
+			log.Printf("Budget: spent %.2f on %s, resetting daily spend", b.spentToday, b.day)
+		}
+		b.day = today
+		b.spentToday = 0
+	}
+
+	if !b.lastTick.IsZero() && b.config.InstanceHourlyPrice > 0 {
+		elapsed := now.Sub(b.lastTick).Hours()
+		b.spentToday += float64(replicas) * b.config.InstanceHourlyPrice * elapsed
+	}
+	b.lastTick = now
+}
+
+// replicaCap returns the highest replica count permitted by the configured
+// schedule and spend limits. MinReplicas raises the schedule's cap but not
+// the spend limits, so the hourly and daily budgets are never exceeded to
+// keep it.
+func (b *budget) replicaCap(now time.Time) int {
+	limit := b.config.MaxReplicas
+
+	for _, window := range b.config.Schedule {
+		if window.contains(now.Hour()) {
+			limit = min(limit, window.MaxReplicas)
+			break
+		}
+	}
+	limit = max(limit, b.config.MinReplicas)
+
+	if b.config.InstanceHourlyPrice > 0 {
+		if b.config.MaxHourlySpend > 0 {
+			limit = min(limit, int(b.config.MaxHourlySpend/b.config.InstanceHourlyPrice))
+		}
+
+		if b.config.MaxDailySpend > 0 {
+			remaining := b.config.MaxDailySpend - b.spentToday
+			if remaining <= 0 {
+				return 0
+			}
+			// Spread what is left of the daily budget over the rest of the day.
+			midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+			hoursLeft := midnight.Sub(now).Hours()
+			if hoursLeft > 0 {
+				limit = min(limit, int(remaining/(b.config.InstanceHourlyPrice*hoursLeft)))
+			}
+		}
+	}
+
+	return limit
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Labels docker compose puts on the containers of a service.
+const (
+	composeProjectLabel         = "com.docker.compose.project"
+	composeServiceLabel         = "com.docker.compose.service"
+	composeContainerNumberLabel = "com.docker.compose.container-number"
+)
+
+// dockerError is an error response from the Docker Engine API.
+type dockerError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *dockerError) Error() string {
+	return fmt.Sprintf("docker API returned %d: %s", e.StatusCode, e.Message)
+}
+
+// containerSummary is an entry of the container list endpoint.
+type containerSummary struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	State  string            `json:"State"`
+	Status string            `json:"Status"`
+	Labels map[string]string `json:"Labels"`
+}
+
+func (c containerSummary) running() bool {
+	return c.State == "running"
+}
+
+// unhealthy reports whether the container's health check is failing. The
+// list endpoint only exposes health through the status text, e.g.
+// "Up 5 minutes (unhealthy)".
+func (c containerSummary) unhealthy() bool {
+	return strings.Contains(c.Status, "(unhealthy)")
+}
+
+func (c containerSummary) number() int {
+	n, _ := strconv.Atoi(c.Labels[composeContainerNumberLabel])
+	return n
+}
+
+func (c containerSummary) name() string {
+	if len(c.Names) == 0 {
+		return c.ID[:12]
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// dockerClient scales a compose service by talking to the Docker Engine API
+// directly. Containers are told apart by the labels docker compose sets, so
+// the compose CLI does not need to be installed next to the autoscaler.
+type dockerClient struct {
+	httpClient  *http.Client
+	baseURL     string
+	project     string
+	service     string
+	stopTimeout time.Duration
+}
+
+// newDockerClient connects to host, which is either a unix:// socket or a
+// tcp:// address like DOCKER_HOST.
+func newDockerClient(host, apiVersion, project, service string, stopTimeout time.Duration) (*dockerClient, error) {
+	hostURL, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host %q: %w", host, err)
+	}
+
+	client := &dockerClient{
+		httpClient:  &http.Client{},
+		project:     project,
+		service:     service,
+		stopTimeout: stopTimeout,
+	}
+
+	switch hostURL.Scheme {
+	case "unix":
+		socketPath := hostURL.Path
+		client.httpClient.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socketPath)
+			},
+		}
+		client.baseURL = "http://docker"
+	case "tcp", "http":
+		client.baseURL = "http://" + hostURL.Host
+	default:
+		return nil, fmt.Errorf("unsupported docker host scheme %q", hostURL.Scheme)
+	}
+
+	if apiVersion != "" {
+		client.baseURL += "/v" + strings.TrimPrefix(apiVersion, "v")
+	}
+	return client, nil
+}
+
+func (d *dockerClient) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	reqURL := d.baseURL + path
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("docker API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		apiErr := &dockerError{StatusCode: resp.StatusCode}
+		var payload struct {
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err == nil {
+			apiErr.Message = payload.Message
+		}
+		return apiErr
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode docker API response: %w", err)
+		}
+	}
+	return nil
+}
+
+// listContainers returns every container of the service, stopped ones
+// included, ordered by their compose container number.
+func (d *dockerClient) listContainers(ctx context.Context) ([]containerSummary, error) {
+	labels := []string{composeServiceLabel + "=" + d.service}
+	if d.project != "" {
+		labels = append(labels, composeProjectLabel+"="+d.project)
+	}
+	filters, err := json.Marshal(map[string][]string{"label": labels})
+	if err != nil {
+		return nil, err
+	}
+
+	var containers []containerSummary
+	query := url.Values{"all": {"1"}, "filters": {string(filters)}}
+	if err := d.do(ctx, http.MethodGet, "/containers/json", query, nil, &containers); err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].number() < containers[j].number()
+	})
+	return containers, nil
+}
+
+// currentReplicas counts the running containers of the service. Unhealthy
+// containers still count since they hold a slot until they are replaced.
+func (d *dockerClient) currentReplicas(ctx context.Context) (int, error) {
+	containers, err := d.listContainers(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	running := 0
+	for _, container := range containers {
+		if !container.running() {
+			continue
+		}
+		running++
+		if container.unhealthy() {
+			log.Printf("Container %s is unhealthy", container.name())
+		}
+	}
+	return running, nil
+}
+
+// scale runs exactly replicas containers of the service. Surplus containers
+// are stopped, unhealthy ones first, and kept so they can be started again
+// later; new containers are only created once no stopped one is left.
+func (d *dockerClient) scale(ctx context.Context, replicas int) error {
+	containers, err := d.listContainers(ctx)
+	if err != nil {
+		return err
+	}
+
+	var running, stopped []containerSummary
+	for _, container := range containers {
+		if container.running() {
+			running = append(running, container)
+		} else {
+			stopped = append(stopped, container)
+		}
+	}
+
+	if len(running) > replicas {
+		// Stop unhealthy containers first, then the most recently added
+		sort.SliceStable(running, func(i, j int) bool {
+			if running[i].unhealthy() != running[j].unhealthy() {
+				return running[i].unhealthy()
+			}
+			return running[i].number() > running[j].number()
+		})
+		for _, container := range running[:len(running)-replicas] {
+			if err := d.stopContainer(ctx, container); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	missing := replicas - len(running)
+	for _, container := range stopped {
+		if missing == 0 {
+			return nil
+		}
+		if err := d.startContainer(ctx, container.ID); err != nil {
+			return fmt.Errorf("failed to start %s: %w", container.name(), err)
+		}
+		log.Printf("Started container %s", container.name())
+		missing--
+	}
+
+	if missing > 0 {
+		if len(containers) == 0 {
+			return fmt.Errorf("no container of service %s to clone, create one with docker compose first", d.service)
+		}
+		template := containers[0]
+		if len(running) > 0 {
+			template = running[0]
+		}
+		nextNumber := containers[len(containers)-1].number() + 1
+		for i := 0; i < missing; i++ {
+			if err := d.cloneContainer(ctx, template.ID, nextNumber+i); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// stopContainer gives the container stopTimeout to drain before it is killed.
+func (d *dockerClient) stopContainer(ctx context.Context, container containerSummary) error {
+	query := url.Values{"t": {strconv.Itoa(int(d.stopTimeout.Seconds()))}}
+	err := d.do(ctx, http.MethodPost, "/containers/"+container.ID+"/stop", query, nil, nil)
+	var apiErr *dockerError
+	if err != nil && !(errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotModified) {
+		return fmt.Errorf("failed to stop %s: %w", container.name(), err)
+	}
+	log.Printf("Stopped container %s", container.name())
+	return nil
+}
+
+func (d *dockerClient) startContainer(ctx context.Context, id string) error {
+	err := d.do(ctx, http.MethodPost, "/containers/"+id+"/start", nil, nil, nil)
+	var apiErr *dockerError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	return err
+}
+
+// containerInspect holds the parts of a container's configuration needed to
+// create another one like it. Config and HostConfig are passed through
+// untouched so no setting of the compose file is lost.
+type containerInspect struct {
+	Name            string                 `json:"Name"`
+	Config          map[string]interface{} `json:"Config"`
+	HostConfig      map[string]interface{} `json:"HostConfig"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			Aliases []string `json:"Aliases"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// cloneContainer creates and starts a container with the configuration of
+// template, numbered the way docker compose numbers replicas.
+func (d *dockerClient) cloneContainer(ctx context.Context, templateID string, number int) error {
+	var template containerInspect
+	if err := d.do(ctx, http.MethodGet, "/containers/"+templateID+"/json", nil, nil, &template); err != nil {
+		return fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	config := template.Config
+	// The hostname defaults to the container id, so the template's must not be reused
+	delete(config, "Hostname")
+	labels, _ := config["Labels"].(map[string]interface{})
+	if labels == nil {
+		labels = make(map[string]interface{})
+	}
+	labels[composeContainerNumberLabel] = strconv.Itoa(number)
+	config["Labels"] = labels
+	config["HostConfig"] = template.HostConfig
+
+	name := d.containerName(template.Name, number)
+
+	// Only one network can be attached on create, the rest are connected after
+	var networks []string
+	for network := range template.NetworkSettings.Networks {
+		networks = append(networks, network)
+	}
+	sort.Strings(networks)
+	if len(networks) > 0 {
+		config["NetworkingConfig"] = map[string]interface{}{
+			"EndpointsConfig": map[string]interface{}{
+				networks[0]: map[string]interface{}{"Aliases": []string{d.service}},
+			},
+		}
+	}
+
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := d.do(ctx, http.MethodPost, "/containers/create", url.Values{"name": {name}}, config, &created); err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+
+	for _, network := range networks[min(1, len(networks)):] {
+		body := map[string]interface{}{
+			"Container":      created.ID,
+			"EndpointConfig": map[string]interface{}{"Aliases": []string{d.service}},
+		}
+		if err := d.do(ctx, http.MethodPost, "/networks/"+network+"/connect", nil, body, nil); err != nil {
+			return fmt.Errorf("failed to connect %s to network %s: %w", name, network, err)
+		}
+	}
+
+	if err := d.startContainer(ctx, created.ID); err != nil {
+		return fmt.Errorf("failed to start %s: %w", name, err)
+	}
+	log.Printf("Created container %s", name)
+	return nil
+}
+
+// containerName derives the name of a new replica from the template's name,
+// e.g. project-worker-1 becomes project-worker-3.
+func (d *dockerClient) containerName(templateName string, number int) string {
+	templateName = strings.TrimPrefix(templateName, "/")
+	if idx := strings.LastIndexAny(templateName, "-_"); idx != -1 {
+		if _, err := strconv.Atoi(templateName[idx+1:]); err == nil {
+			return fmt.Sprintf("%s%d", templateName[:idx+1], number)
+		}
+	}
+	if d.project != "" {
+		return fmt.Sprintf("%s-%s-%d", d.project, d.service, number)
+	}
+	return fmt.Sprintf("%s-%d", d.service, number)
+}
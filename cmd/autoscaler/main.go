@@ -2,21 +2,23 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/go-redis/redis/v8"
 )
 
 // Configuration from environment variables
 type Config struct {
-	MetricsURL        string
+	MetricsURL string
+	// Queues are the job queues whose lengths, summed, drive scaling
+	Queues            []string
 	ServiceName       string
 	MinReplicas       int
 	MaxReplicas       int
@@ -24,6 +26,31 @@ type Config struct {
 	ScaleUpCooldown   time.Duration
 	ScaleDownCooldown time.Duration
 	PollInterval      time.Duration
+	// MetricsAddr is where the autoscaler serves its own metrics. Empty
+	// disables them.
+	MetricsAddr string
+
+	// Docker Engine API. ComposeProject limits scaling to the containers of
+	// one compose project; StopTimeout is how long a worker may drain.
+	DockerHost       string
+	DockerAPIVersion string
+	ComposeProject   string
+	StopTimeout      time.Duration
+
+	// Redis is used to wake up on new jobs and to see whether workers are
+	// still busy. The last worker is only removed after IdleGracePeriod
+	// without queued or running jobs.
+	RedisAddr       string
+	RedisPassword   string
+	RedisDB         int
+	IdleGracePeriod time.Duration
+
+	// Cost controls. InstanceHourlyPrice is the price of running one replica
+	// for an hour; spend limits are ignored when it is zero.
+	InstanceHourlyPrice float64
+	MaxHourlySpend      float64
+	MaxDailySpend       float64
+	Schedule            []ScheduleWindow
 }
 
 func loadConfig() Config {
@@ -52,15 +79,43 @@ func loadConfig() Config {
 		return defaultValue
 	}
 
+	getEnvFloatOrDefault := func(key string, defaultValue float64) float64 {
+		if value := os.Getenv(key); value != "" {
+			if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+				return floatValue
+			}
+		}
+		return defaultValue
+	}
+
+	schedule, err := parseSchedule(os.Getenv("SCALING_SCHEDULE"))
+	if err != nil {
+		log.Printf("Ignoring SCALING_SCHEDULE: %v", err)
+	}
+
 	return Config{
-		MetricsURL:        getEnvOrDefault("METRICS_URL", "http://metrics-exporter:9090/metrics"),
-		ServiceName:       getEnvOrDefault("SERVICE_NAME", "worker"),
-		MinReplicas:       getEnvIntOrDefault("MIN_REPLICAS", 0),
-		MaxReplicas:       getEnvIntOrDefault("MAX_REPLICAS", 10),
-		QueueThreshold:    getEnvIntOrDefault("QUEUE_THRESHOLD", 1),
-		ScaleUpCooldown:   getEnvDurationOrDefault("SCALE_UP_COOLDOWN", 10*time.Second),
-		ScaleDownCooldown: getEnvDurationOrDefault("SCALE_DOWN_COOLDOWN", 300*time.Second),
-		PollInterval:      getEnvDurationOrDefault("POLL_INTERVAL", 5*time.Second),
+		MetricsURL:          getEnvOrDefault("METRICS_URL", "http://metrics-exporter:9090/metrics"),
+		ServiceName:         getEnvOrDefault("SERVICE_NAME", "worker"),
+		Queues:              strings.Split(getEnvOrDefault("SCALE_QUEUES", "video_jobs,video_jobs:gpu"), ","),
+		MinReplicas:         getEnvIntOrDefault("MIN_REPLICAS", 0),
+		MaxReplicas:         getEnvIntOrDefault("MAX_REPLICAS", 10),
+		QueueThreshold:      getEnvIntOrDefault("QUEUE_THRESHOLD", 1),
+		ScaleUpCooldown:     getEnvDurationOrDefault("SCALE_UP_COOLDOWN", 10*time.Second),
+		ScaleDownCooldown:   getEnvDurationOrDefault("SCALE_DOWN_COOLDOWN", 300*time.Second),
+		PollInterval:        getEnvDurationOrDefault("POLL_INTERVAL", 5*time.Second),
+		MetricsAddr:         getEnvOrDefault("AUTOSCALER_METRICS_ADDR", ":9091"),
+		InstanceHourlyPrice: getEnvFloatOrDefault("INSTANCE_HOURLY_PRICE", 0),
+		MaxHourlySpend:      getEnvFloatOrDefault("MAX_HOURLY_SPEND", 0),
+		MaxDailySpend:       getEnvFloatOrDefault("MAX_DAILY_SPEND", 0),
+		Schedule:            schedule,
+		DockerHost:          getEnvOrDefault("DOCKER_HOST", "unix:///var/run/docker.sock"),
+		DockerAPIVersion:    getEnvOrDefault("DOCKER_API_VERSION", "1.41"),
+		ComposeProject:      getEnvOrDefault("COMPOSE_PROJECT_NAME", ""),
+		StopTimeout:         getEnvDurationOrDefault("STOP_TIMEOUT", 300*time.Second),
+		RedisAddr:           getEnvOrDefault("REDIS_ADDR", "localhost:6379"),
+		RedisPassword:       os.Getenv("REDIS_PASSWORD"),
+		RedisDB:             getEnvIntOrDefault("REDIS_DB", 0),
+		IdleGracePeriod:     getEnvDurationOrDefault("IDLE_GRACE_PERIOD", 600*time.Second),
 	}
 }
 
@@ -68,48 +123,130 @@ func main() {
 	config := loadConfig()
 	log.Printf("Starting autoscaler with config: %+v", config)
 
+	docker, err := newDockerClient(config.DockerHost, config.DockerAPIVersion, config.ComposeProject, config.ServiceName, config.StopTimeout)
+	if err != nil {
+		log.Fatalf("Failed to create docker client: %v", err)
+	}
+
+	if config.MetricsAddr != "" {
+		go serveMetrics(config.MetricsAddr)
+	}
+
+	ctx := context.Background()
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     config.RedisAddr,
+		Password: config.RedisPassword,
+		DB:       config.RedisDB,
+	})
+	// A nil channel never fires, leaving only the poll interval
+	var wake <-chan struct{}
+	if wake, err = subscribeToJobs(ctx, redisClient); err != nil {
+		log.Printf("Wake-on-job disabled: %v", err)
+	}
+
 	lastScaleUp := time.Now().Add(-config.ScaleUpCooldown)
 	lastScaleDown := time.Now().Add(-config.ScaleDownCooldown)
+	spend := newBudget(config)
+	lastBusy := time.Now()
 
 	ticker := time.NewTicker(config.PollInterval)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		woken := false
+		select {
+		case <-ticker.C:
+		case <-wake:
+			woken = true
+		}
+
 		// Get current queue length
-		queueLength, err := getQueueLength(config.MetricsURL)
+		queueLength, err := getQueueLength(config.MetricsURL, config.Queues)
 		if err != nil {
 			log.Printf("Error getting queue length: %v", err)
+			errorsTotal.WithLabelValues("queue_length").Inc()
 			continue
 		}
+		// The exporter polls the queue, so a job that was just published may
+		// not be counted yet
+		if woken && queueLength == 0 {
+			queueLength = 1
+		}
 
 		// Get current number of replicas
-		currentReplicas, err := getCurrentReplicas(config.ServiceName)
+		currentReplicas, err := getCurrentReplicas(docker)
 		if err != nil {
 			log.Printf("Error getting current replicas: %v", err)
+			errorsTotal.WithLabelValues("replicas").Inc()
 			continue
 		}
 
-		log.Printf("Current state: queue_length=%d, replicas=%d", queueLength, currentReplicas)
+		// Unknown counts as busy so workers are never removed mid-job
+		runningJobs, err := countRunningJobs(ctx, redisClient)
+		if err != nil {
+			log.Printf("Error getting running jobs: %v", err)
+			errorsTotal.WithLabelValues("running_jobs").Inc()
+			runningJobs = -1
+		}
+
+		now := time.Now()
+		if queueLength > 0 || runningJobs != 0 {
+			lastBusy = now
+		}
+		idle := now.Sub(lastBusy) >= config.IdleGracePeriod
+		spend.accrue(now, currentReplicas)
+		maxReplicas := spend.replicaCap(now)
+		currentReplicasGauge.Set(float64(currentReplicas))
+		desiredReplicasGauge.Set(float64(currentReplicas))
+		maxReplicasGauge.Set(float64(maxReplicas))
+		recordCooldowns(config, now, lastScaleUp, lastScaleDown)
+
+		log.Printf("Current state: queue_length=%d, running_jobs=%d, replicas=%d, max_replicas=%d, spent_today=%.2f",
+			queueLength, runningJobs, currentReplicas, maxReplicas, spend.spentToday)
+
+		// Budget or schedule caps take effect immediately, regardless of cooldown
+		if currentReplicas > maxReplicas {
+			log.Printf("Scaling down from %d to %d replicas to stay within budget", currentReplicas, maxReplicas)
+			desiredReplicasGauge.Set(float64(maxReplicas))
+			scalingDecisionsTotal.WithLabelValues("down", "budget").Inc()
+
+			if err := scaleService(docker, maxReplicas); err != nil {
+				log.Printf("Error scaling down: %v", err)
+				errorsTotal.WithLabelValues("scale_down").Inc()
+				continue
+			}
+
+			lastScaleDown = time.Now()
+			continue
+		}
 
 		// Determine if scaling is needed
-		if queueLength > 0 && currentReplicas < config.MaxReplicas && time.Since(lastScaleUp) > config.ScaleUpCooldown {
+		// Waking up from zero skips the cooldown so the first job starts right away
+		if queueLength > 0 && currentReplicas < maxReplicas && (currentReplicas == 0 || time.Since(lastScaleUp) > config.ScaleUpCooldown) {
 			// Scale up
-			targetReplicas := min(currentReplicas+1, config.MaxReplicas)
+			targetReplicas := min(currentReplicas+1, maxReplicas)
 			log.Printf("Scaling up from %d to %d replicas", currentReplicas, targetReplicas)
+			desiredReplicasGauge.Set(float64(targetReplicas))
+			scalingDecisionsTotal.WithLabelValues("up", "queue").Inc()
 			
-			if err := scaleService(config.ServiceName, targetReplicas); err != nil {
+			if err := scaleService(docker, targetReplicas); err != nil {
 				log.Printf("Error scaling up: %v", err)
+				errorsTotal.WithLabelValues("scale_up").Inc()
 				continue
 			}
 			
 			lastScaleUp = time.Now()
-		} else if queueLength == 0 && currentReplicas > config.MinReplicas && time.Since(lastScaleDown) > config.ScaleDownCooldown {
+		} else if queueLength == 0 && currentReplicas > config.MinReplicas && time.Since(lastScaleDown) > config.ScaleDownCooldown &&
+			(currentReplicas > 1 || idle) {
 			// Scale down
 			targetReplicas := max(currentReplicas-1, config.MinReplicas)
 			log.Printf("Scaling down from %d to %d replicas", currentReplicas, targetReplicas)
+			desiredReplicasGauge.Set(float64(targetReplicas))
+			scalingDecisionsTotal.WithLabelValues("down", "idle").Inc()
 			
-			if err := scaleService(config.ServiceName, targetReplicas); err != nil {
+			if err := scaleService(docker, targetReplicas); err != nil {
 				log.Printf("Error scaling down: %v", err)
+				errorsTotal.WithLabelValues("scale_down").Inc()
 				continue
 			}
 			
@@ -118,7 +255,10 @@ func main() {
 	}
 }
 
-func getQueueLength(metricsURL string) (int, error) {
+// getQueueLength sums the lengths of queues as reported by the metrics
+// exporter, which also exports queues such as the failed jobs that say
+// nothing about the work waiting
+func getQueueLength(metricsURL string, queues []string) (int, error) {
 	resp, err := http.Get(metricsURL)
 	if err != nil {
 		return 0, err
@@ -130,38 +270,48 @@ func getQueueLength(metricsURL string) (int, error) {
 		return 0, err
 	}
 
-	// Parse the metrics output to find the queue length
+	// Parse the metrics output to find the queue lengths, in lines such as
+	// redis_queue_length{queue="video_jobs"} 3
+	total, found := 0, false
 	lines := strings.Split(string(body), "\n")
 	for _, line := range lines {
-		if strings.Contains(line, "redis_queue_length") && !strings.HasPrefix(line, "#") {
-			parts := strings.Fields(line)
-			if len(parts) >= 2 {
-				return strconv.Atoi(parts[1])
+		if !strings.HasPrefix(line, "redis_queue_length{") {
+			continue
+		}
+		parts := strings.Fields(line)
+		if len(parts) < 2 {
+			continue
+		}
+		for _, queue := range queues {
+			if strings.Contains(parts[0], fmt.Sprintf("queue=%q", strings.TrimSpace(queue))) {
+				length, err := strconv.ParseFloat(parts[1], 64)
+				if err != nil {
+					return 0, err
+				}
+				total += int(length)
+				found = true
 			}
 		}
 	}
 
-	return 0, fmt.Errorf("queue length metric not found")
-}
-
-func getCurrentReplicas(serviceName string) (int, error) {
-	cmd := exec.Command("docker", "compose", "ps", serviceName, "--format", "json")
-	output, err := cmd.Output()
-	if err != nil {
-		return 0, err
-	}
-
-	var containers []map[string]interface{}
-	if err := json.Unmarshal(output, &containers); err != nil {
-		return 0, err
+	if !found {
+		return 0, fmt.Errorf("queue length metric not found")
 	}
+	return total, nil
+}
 
-	return len(containers), nil
+func getCurrentReplicas(docker *dockerClient) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return docker.currentReplicas(ctx)
 }
 
-func scaleService(serviceName string, replicas int) error {
-	cmd := exec.Command("docker", "compose", "up", "-d", "--scale", fmt.Sprintf("%s=%d", serviceName, replicas), serviceName)
-	return cmd.Run()
+// scaleService waits for stopped containers to drain, so its deadline is
+// derived from the stop timeout.
+func scaleService(docker *dockerClient, replicas int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), docker.stopTimeout+time.Minute)
+	defer cancel()
+	return docker.scale(ctx, replicas)
 }
 
 func min(a, b int) int {
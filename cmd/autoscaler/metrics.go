@@ -0,0 +1,63 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	currentReplicasGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "autoscaler_current_replicas",
+		Help: "Worker replicas running at the last poll",
+	})
+	desiredReplicasGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "autoscaler_desired_replicas",
+		Help: "Worker replicas the autoscaler decided on at the last poll",
+	})
+	maxReplicasGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "autoscaler_max_replicas",
+		Help: "Worker replicas allowed by the budget and schedule at the last poll",
+	})
+	scalingDecisionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "autoscaler_scaling_decisions_total",
+		Help: "Scale operations the autoscaler started, by direction and reason",
+	}, []string{"direction", "reason"})
+	cooldownActiveGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "autoscaler_cooldown_active",
+		Help: "Whether scaling in a direction is held back by its cooldown",
+	}, []string{"direction"})
+	errorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "autoscaler_errors_total",
+		Help: "Failed autoscaler operations, by operation",
+	}, []string{"operation"})
+)
+
+// recordCooldowns publishes whether the scale up and scale down cooldowns
+// are still running at now
+func recordCooldowns(config Config, now, lastScaleUp, lastScaleDown time.Time) {
+	cooldownActiveGauge.WithLabelValues("up").Set(boolGauge(now.Sub(lastScaleUp) <= config.ScaleUpCooldown))
+	cooldownActiveGauge.WithLabelValues("down").Set(boolGauge(now.Sub(lastScaleDown) <= config.ScaleDownCooldown))
+}
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// serveMetrics exposes the autoscaler's metrics on addr. A failing server
+// does not stop scaling.
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	log.Printf("Starting metrics server on %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("Metrics server stopped: %v", err)
+	}
+}
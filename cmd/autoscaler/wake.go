@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// jobChannel is where the API announces every job it enqueues
+	jobChannel = "new_video_jobs_channel"
+	// runningJobsPattern matches the running job counts workers report with
+	// their heartbeats
+	runningJobsPattern = "worker:running:*"
+)
+
+// subscribeToJobs signals on the returned channel whenever a job is published,
+// so a fleet scaled to zero wakes up without waiting for the next poll.
+// Notifications that arrive while one is pending are coalesced.
+func subscribeToJobs(ctx context.Context, client *redis.Client) (<-chan struct{}, error) {
+	pubsub := client.Subscribe(ctx, jobChannel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", jobChannel, err)
+	}
+
+	wake := make(chan struct{}, 1)
+	go func() {
+		defer pubsub.Close()
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-ch:
+				if !ok {
+					return
+				}
+				select {
+				case wake <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return wake, nil
+}
+
+// countRunningJobs sums the jobs every live worker reports as in flight.
+// Counts expire with the worker's heartbeat, so dead workers drop out.
+func countRunningJobs(ctx context.Context, client *redis.Client) (int, error) {
+	var keys []string
+	iter := client.Scan(ctx, 0, runningJobsPattern, 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return 0, fmt.Errorf("failed to scan running jobs: %w", err)
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	values, err := client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get running jobs: %w", err)
+	}
+
+	running := 0
+	for i, value := range values {
+		str, ok := value.(string)
+		if !ok {
+			// Expired between SCAN and MGET
+			continue
+		}
+		n, err := strconv.Atoi(str)
+		if err != nil {
+			log.Printf("Ignoring malformed running job count in %s: %q", keys[i], str)
+			continue
+		}
+		running += n
+	}
+	return running, nil
+}
@@ -0,0 +1,301 @@
+// Command benchmark measures the speed and quality of every encoder preset
+// available on the host and writes the presets the worker should use. Point
+// the worker's RecommendationsPath at the output to replace its core-count
+// heuristics.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/worker"
+)
+
+// candidate is an encoder and the presets of it worth comparing
+type candidate struct {
+	codec   models.Codec
+	hwAccel worker.HardwareAccelType
+	encoder string
+	presets []string
+	// inputArgs go before the input, e.g. to open a hardware device
+	inputArgs []string
+	// args are the encoder arguments for a preset
+	args func(preset string) []string
+}
+
+var candidates = []candidate{
+	{
+		codec: models.CodecH264, hwAccel: worker.HWAccelNone, encoder: "libx264",
+		presets: []string{"veryfast", "fast", "medium", "slow"},
+		args: func(preset string) []string {
+			return []string{"-c:v", "libx264", "-preset", preset, "-b:v", "5000k"}
+		},
+	},
+	{
+		codec: models.CodecH264, hwAccel: worker.HWAccelNVENC, encoder: "h264_nvenc",
+		presets: []string{"p1", "p4", "p7"},
+		args: func(preset string) []string {
+			return []string{"-c:v", "h264_nvenc", "-preset", preset, "-b:v", "5000k"}
+		},
+	},
+	{
+		codec: models.CodecH264, hwAccel: worker.HWAccelVAAPI, encoder: "h264_vaapi",
+		presets:   []string{"balanced"},
+		inputArgs: []string{"-vaapi_device", "/dev/dri/renderD128"},
+		args: func(string) []string {
+			return []string{"-vf", "format=nv12,hwupload", "-c:v", "h264_vaapi", "-b:v", "5000k"}
+		},
+	},
+	{
+		codec: models.CodecH264, hwAccel: worker.HWAccelQSV, encoder: "h264_qsv",
+		presets: []string{"veryfast", "medium", "veryslow"},
+		args: func(preset string) []string {
+			return []string{"-c:v", "h264_qsv", "-preset", preset, "-b:v", "5000k"}
+		},
+	},
+	{
+		codec: models.CodecAV1, hwAccel: worker.HWAccelNone, encoder: "libsvtav1",
+		presets: []string{"11", "10", "9", "8", "7", "6"},
+		args: func(preset string) []string {
+			return []string{"-c:v", "libsvtav1", "-preset", preset, "-crf", "28"}
+		},
+	},
+	{
+		codec: models.CodecVP9, hwAccel: worker.HWAccelNone, encoder: "libvpx-vp9",
+		presets: []string{"5", "4", "3", "2"},
+		args: func(preset string) []string {
+			return []string{"-c:v", "libvpx-vp9", "-deadline", "good", "-cpu-used", preset, "-row-mt", "1", "-crf", "31", "-b:v", "5000k"}
+		},
+	},
+}
+
+var ssimPattern = regexp.MustCompile(`All:([0-9.]+)`)
+
+func main() {
+	clip := flag.String("clip", "", "Reference clip; a synthetic 1080p clip is generated when empty")
+	duration := flag.Int("duration", 10, "Length in seconds of the generated clip")
+	out := flag.String("out", "encoder-recommendations.json", "Recommendations file to write")
+	minSpeed := flag.Float64("min-speed", 1.0, "Slowest speed, as a multiple of real time, a preset may have to be recommended")
+	flag.Parse()
+
+	workDir, err := os.MkdirTemp("", "encoder-benchmark-*")
+	if err != nil {
+		log.Fatalf("Failed to create work directory: %v", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	reference := *clip
+	if reference == "" {
+		reference = filepath.Join(workDir, "reference.mkv")
+		log.Printf("Generating a %ds reference clip", *duration)
+		if err := generateClip(reference, *duration); err != nil {
+			log.Fatalf("Failed to generate reference clip: %v", err)
+		}
+	}
+	frameRate, err := probeFrameRate(reference)
+	if err != nil {
+		log.Fatalf("Failed to probe reference clip: %v", err)
+	}
+
+	available := availableEncoders()
+	host, _ := os.Hostname()
+	recommendations := &worker.EncoderRecommendations{
+		Host:        host,
+		CPUs:        runtime.NumCPU(),
+		GeneratedAt: time.Now().UTC(),
+		MinSpeed:    *minSpeed,
+	}
+
+	for _, c := range candidates {
+		if !available[c.encoder] {
+			log.Printf("Skipping %s, not available in this ffmpeg build", c.encoder)
+			continue
+		}
+
+		var results []worker.EncoderBenchmark
+		for _, preset := range c.presets {
+			result := benchmark(c, preset, reference, frameRate, workDir)
+			if result.Error != "" {
+				log.Printf("%s %s failed: %s", c.encoder, preset, result.Error)
+			} else {
+				log.Printf("%s %s: %.1f fps (%.2fx), SSIM %.4f", c.encoder, preset, result.FPS, result.Speed, result.SSIM)
+			}
+			results = append(results, result)
+		}
+		recommendations.Results = append(recommendations.Results, results...)
+		if best, ok := recommend(results, *minSpeed); ok {
+			log.Printf("Recommending %s preset %s", c.encoder, best.Preset)
+			recommendations.Presets = append(recommendations.Presets, best)
+		}
+	}
+
+	data, err := json.MarshalIndent(recommendations, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode recommendations: %v", err)
+	}
+	if err := os.WriteFile(*out, data, 0644); err != nil {
+		log.Fatalf("Failed to write recommendations: %v", err)
+	}
+	log.Printf("Wrote %d recommendations to %s", len(recommendations.Presets), *out)
+}
+
+// recommend picks the best quality preset that keeps up with minSpeed, or the
+// fastest one when none does.
+func recommend(results []worker.EncoderBenchmark, minSpeed float64) (worker.EncoderBenchmark, bool) {
+	var best, fastest *worker.EncoderBenchmark
+	for i := range results {
+		result := &results[i]
+		if result.Error != "" {
+			continue
+		}
+		if fastest == nil || result.FPS > fastest.FPS {
+			fastest = result
+		}
+		if result.Speed >= minSpeed && (best == nil || result.SSIM > best.SSIM) {
+			best = result
+		}
+	}
+	if best == nil {
+		best = fastest
+	}
+	if best == nil {
+		return worker.EncoderBenchmark{}, false
+	}
+	return *best, true
+}
+
+// benchmark encodes the reference clip with one preset, timing the encode and
+// comparing the output with the clip.
+func benchmark(c candidate, preset, reference string, frameRate float64, workDir string) worker.EncoderBenchmark {
+	result := worker.EncoderBenchmark{Codec: c.codec, HWAccel: c.hwAccel, Encoder: c.encoder, Preset: preset}
+	output := filepath.Join(workDir, fmt.Sprintf("%s-%s.mp4", c.encoder, preset))
+	defer os.Remove(output)
+
+	args := []string{"-y", "-hide_banner", "-loglevel", "error", "-nostats", "-progress", "pipe:1"}
+	args = append(args, c.inputArgs...)
+	args = append(args, "-i", reference)
+	args = append(args, c.args(preset)...)
+	args = append(args, "-an", output)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	start := time.Now()
+	if err := cmd.Run(); err != nil {
+		result.Error = fmt.Sprintf("%v: %s", err, strings.TrimSpace(stderr.String()))
+		return result
+	}
+	elapsed := time.Since(start)
+
+	frames := lastProgressValue(stdout.Bytes(), "frame")
+	if frames <= 0 || elapsed <= 0 {
+		result.Error = "no frames encoded"
+		return result
+	}
+	result.FPS = frames / elapsed.Seconds()
+	if frameRate > 0 {
+		result.Speed = result.FPS / frameRate
+	}
+
+	ssim, err := measureSSIM(output, reference)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.SSIM = ssim
+	return result
+}
+
+// lastProgressValue returns the last value of key in ffmpeg -progress output
+func lastProgressValue(progress []byte, key string) float64 {
+	var value float64
+	scanner := bufio.NewScanner(bytes.NewReader(progress))
+	for scanner.Scan() {
+		if k, v, ok := strings.Cut(scanner.Text(), "="); ok && k == key {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				value = parsed
+			}
+		}
+	}
+	return value
+}
+
+func measureSSIM(output, reference string) (float64, error) {
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-nostats", "-i", output, "-i", reference,
+		"-lavfi", "[0:v][1:v]ssim", "-f", "null", "-")
+	stderr, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure ssim: %v", err)
+	}
+	match := ssimPattern.FindSubmatch(stderr)
+	if match == nil {
+		return 0, fmt.Errorf("ssim not reported")
+	}
+	return strconv.ParseFloat(string(match[1]), 64)
+}
+
+// generateClip writes a synthetic 1080p30 clip with enough motion and detail
+// to tell presets apart. It is stored losslessly so it is a fair reference.
+func generateClip(path string, seconds int) error {
+	cmd := exec.Command("ffmpeg", "-y", "-hide_banner", "-loglevel", "error",
+		"-f", "lavfi", "-i", fmt.Sprintf("testsrc2=size=1920x1080:rate=30:duration=%d", seconds),
+		"-c:v", "ffv1", "-pix_fmt", "yuv420p", path)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, output)
+	}
+	return nil
+}
+
+func probeFrameRate(path string) (float64, error) {
+	output, err := exec.Command("ffprobe", "-v", "quiet", "-select_streams", "v:0",
+		"-show_entries", "stream=avg_frame_rate", "-of", "csv=p=0", path).Output()
+	if err != nil {
+		return 0, err
+	}
+	num, den, found := strings.Cut(strings.TrimSpace(string(output)), "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid frame rate %q", output)
+	}
+	if !found {
+		return n, nil
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0, fmt.Errorf("invalid frame rate %q", output)
+	}
+	return n / d, nil
+}
+
+// availableEncoders lists the video encoders compiled into ffmpeg. Hardware
+// encoders can still fail when the host has no matching device; those fail
+// their benchmark instead.
+func availableEncoders() map[string]bool {
+	output, err := exec.Command("ffmpeg", "-hide_banner", "-encoders").Output()
+	if err != nil {
+		log.Fatalf("Failed to list ffmpeg encoders: %v", err)
+	}
+	encoders := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && strings.HasPrefix(fields[0], "V") {
+			encoders[fields[1]] = true
+		}
+	}
+	return encoders
+}
@@ -2,10 +2,16 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -14,9 +20,12 @@ import (
 )
 
 const (
-	queueName     = "video_jobs"
-	metricsPort   = ":9090"
-	pollInterval  = 5 * time.Second
+	// defaultQueues are the job queues of the CPU and GPU workers, the
+	// deferred jobs and the failed jobs that make up the dead letter queue
+	defaultQueues   = "video_jobs,video_jobs:gpu,jobs:deferred,jobs:failed"
+	metricsPort     = ":9090"
+	pollInterval    = 5 * time.Second
+	shutdownTimeout = 10 * time.Second
 )
 
 var (
@@ -27,10 +36,24 @@ var (
 		},
 		[]string{"queue"},
 	)
+	scrapeDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "redis_queue_scrape_duration_seconds",
+			Help:    "Time taken to read the length of every queue",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 12),
+		},
+	)
+	scrapeErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "redis_queue_scrape_errors_total",
+			Help: "Failed reads of a queue's length",
+		},
+		[]string{"queue"},
+	)
 )
 
 func init() {
-	prometheus.MustRegister(queueLengthGauge)
+	prometheus.MustRegister(queueLengthGauge, scrapeDuration, scrapeErrors)
 }
 
 func main() {
@@ -50,32 +73,70 @@ func main() {
 		}
 	}
 
+	queues := parseQueues(os.Getenv("QUEUES"))
+	if len(queues) == 0 {
+		queues = parseQueues(defaultQueues)
+	}
+
 	// Initialize Redis client
 	redisClient := redis.NewClient(&redis.Options{
 		Addr:     redisAddr,
 		Password: redisPassword,
 		DB:       redisDB,
 	})
+	defer redisClient.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	// Test Redis connection
-	ctx := context.Background()
 	if _, err := redisClient.Ping(ctx).Result(); err != nil {
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
 	log.Println("Connected to Redis successfully")
 
 	// Start metrics collection in a goroutine
-	go collectMetrics(ctx, redisClient)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		collectMetrics(ctx, redisClient, queues)
+	}()
 
 	// Expose metrics endpoint
-	http.Handle("/metrics", promhttp.Handler())
-	log.Printf("Starting metrics server on %s", metricsPort)
-	if err := http.ListenAndServe(metricsPort, nil); err != nil {
-		log.Fatalf("Failed to start metrics server: %v", err)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{Addr: metricsPort, Handler: mux}
+	go func() {
+		log.Printf("Starting metrics server on %s for queues %v", metricsPort, queues)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start metrics server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down metrics exporter")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down metrics server: %v", err)
 	}
+	wg.Wait()
 }
 
-func collectMetrics(ctx context.Context, client *redis.Client) {
+// parseQueues splits a comma separated list of queue keys
+func parseQueues(value string) []string {
+	var queues []string
+	for _, queue := range strings.Split(value, ",") {
+		if queue = strings.TrimSpace(queue); queue != "" {
+			queues = append(queues, queue)
+		}
+	}
+	return queues
+}
+
+func collectMetrics(ctx context.Context, client *redis.Client, queues []string) {
 	ticker := time.NewTicker(pollInterval)
 	defer ticker.Stop()
 
@@ -84,16 +145,44 @@ func collectMetrics(ctx context.Context, client *redis.Client) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			// Get queue length
-			queueLength, err := client.LLen(ctx, queueName).Result()
-			if err != nil {
-				log.Printf("Error getting queue length: %v", err)
-				continue
-			}
+			start := time.Now()
+			for _, queue := range queues {
+				length, err := queueLength(ctx, client, queue)
+				if err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					log.Printf("Error getting length of queue %s: %v", queue, err)
+					scrapeErrors.WithLabelValues(queue).Inc()
+					continue
+				}
 
-			// Update Prometheus gauge
-			queueLengthGauge.WithLabelValues(queueName).Set(float64(queueLength))
-			log.Printf("Queue %s length: %d", queueName, queueLength)
+				// Update Prometheus gauge
+				queueLengthGauge.WithLabelValues(queue).Set(float64(length))
+			}
+			scrapeDuration.Observe(time.Since(start).Seconds())
 		}
 	}
 }
+
+// queueLength reads the length of a queue. Job queues are lists, while the
+// deferred and failed jobs are kept in sorted sets; a queue that does not
+// exist yet is empty.
+func queueLength(ctx context.Context, client *redis.Client, queue string) (int64, error) {
+	kind, err := client.Type(ctx, queue).Result()
+	if err != nil {
+		return 0, err
+	}
+	switch kind {
+	case "list":
+		return client.LLen(ctx, queue).Result()
+	case "zset":
+		return client.ZCard(ctx, queue).Result()
+	case "set":
+		return client.SCard(ctx, queue).Result()
+	case "none":
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("unsupported key type %s", kind)
+	}
+}
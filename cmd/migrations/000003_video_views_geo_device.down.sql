@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_video_views_video_id_device_type;
+DROP INDEX IF EXISTS idx_video_views_video_id_country;
+
+ALTER TABLE video_views
+    DROP COLUMN IF EXISTS os,
+    DROP COLUMN IF EXISTS browser,
+    DROP COLUMN IF EXISTS device_type,
+    DROP COLUMN IF EXISTS region,
+    DROP COLUMN IF EXISTS country;
@@ -0,0 +1,10 @@
+-- Store derived location and device details for each view
+ALTER TABLE video_views
+    ADD COLUMN country VARCHAR(2) NOT NULL DEFAULT '',
+    ADD COLUMN region VARCHAR(128) NOT NULL DEFAULT '',
+    ADD COLUMN device_type VARCHAR(16) NOT NULL DEFAULT 'unknown',
+    ADD COLUMN browser VARCHAR(64) NOT NULL DEFAULT 'unknown',
+    ADD COLUMN os VARCHAR(64) NOT NULL DEFAULT 'unknown';
+
+CREATE INDEX idx_video_views_video_id_country ON video_views(video_id, country);
+CREATE INDEX idx_video_views_video_id_device_type ON video_views(video_id, device_type);
@@ -0,0 +1,3 @@
+ALTER TABLE video_files
+    DROP COLUMN IF EXISTS encrypted_data_key,
+    DROP COLUMN IF EXISTS encrypted;
@@ -0,0 +1,4 @@
+-- Per-video envelope encryption for stored sources and outputs
+ALTER TABLE video_files
+    ADD COLUMN encrypted BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN encrypted_data_key BYTEA;               -- Per-video data key, wrapped by the KMS
@@ -0,0 +1,13 @@
+-- Playback position reported periodically by players, used for retention curves
+CREATE TABLE video_heartbeats (
+    id BIGSERIAL PRIMARY KEY,
+    video_id UUID NOT NULL REFERENCES video_files(video_id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(user_id) ON DELETE SET NULL,
+    session_id VARCHAR(64) NOT NULL,
+    position INTEGER NOT NULL, -- Playback position in seconds
+    timestamp TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_video_heartbeats_video_id_position ON video_heartbeats(video_id, position);
+CREATE INDEX idx_video_heartbeats_session_id ON video_heartbeats(session_id);
+CREATE INDEX idx_video_heartbeats_timestamp ON video_heartbeats(timestamp);
@@ -0,0 +1,5 @@
+ALTER TABLE video_files
+    DROP COLUMN IF EXISTS share_token,
+    DROP COLUMN IF EXISTS visibility;
+
+DROP TYPE IF EXISTS video_visibility;
@@ -0,0 +1,6 @@
+-- Who may play a video; unlisted videos are shared through a secret token
+CREATE TYPE video_visibility AS ENUM ('private', 'unlisted', 'public');
+
+ALTER TABLE video_files
+    ADD COLUMN visibility video_visibility NOT NULL DEFAULT 'private',
+    ADD COLUMN share_token VARCHAR(64) UNIQUE;
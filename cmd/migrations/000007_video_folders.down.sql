@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_video_files_folder_id;
+
+ALTER TABLE video_files
+    DROP COLUMN IF EXISTS folder_id;
+
+DROP TABLE IF EXISTS folders;
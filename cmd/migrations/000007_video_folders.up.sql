@@ -0,0 +1,18 @@
+-- Folders let users organize their library; they can be nested
+CREATE TABLE folders
+(
+    folder_id  UUID PRIMARY KEY                  DEFAULT uuid_generate_v4(),
+    user_id    UUID                     NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    parent_id  UUID                              REFERENCES folders (folder_id) ON DELETE CASCADE,
+    name       VARCHAR(255)             NOT NULL CHECK ( name <> '' ),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE          DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_folders_user_parent ON folders (user_id, parent_id);
+
+-- Videos of a deleted folder move back to the library root
+ALTER TABLE video_files
+    ADD COLUMN folder_id UUID REFERENCES folders (folder_id) ON DELETE SET NULL;
+
+CREATE INDEX idx_video_files_folder_id ON video_files (folder_id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_video_files_status_progress_updated_at;
+
+ALTER TABLE video_files
+    DROP COLUMN IF EXISTS progress_updated_at,
+    DROP COLUMN IF EXISTS worker_id;
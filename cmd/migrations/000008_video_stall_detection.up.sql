@@ -0,0 +1,7 @@
+-- Track which worker owns a video and when its progress last moved, so jobs
+-- whose worker died can be told apart from slow ones
+ALTER TABLE video_files
+    ADD COLUMN worker_id           VARCHAR(100),
+    ADD COLUMN progress_updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX idx_video_files_status_progress_updated_at ON video_files (status, progress_updated_at);
@@ -0,0 +1,4 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS plan;
+
+DROP TYPE IF EXISTS user_plan;
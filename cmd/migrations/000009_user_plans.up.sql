@@ -0,0 +1,5 @@
+-- Billing plan of a user; free plan videos may get the platform watermark
+CREATE TYPE user_plan AS ENUM ('free', 'paid');
+
+ALTER TABLE users
+    ADD COLUMN plan user_plan NOT NULL DEFAULT 'free';
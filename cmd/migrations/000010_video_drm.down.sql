@@ -0,0 +1,3 @@
+ALTER TABLE video_files
+    DROP COLUMN IF EXISTS drm_key_id,
+    DROP COLUMN IF EXISTS drm_scheme;
@@ -0,0 +1,5 @@
+-- Key ID and protection scheme of DRM packaged videos. The content key itself
+-- stays with the key server.
+ALTER TABLE video_files
+    ADD COLUMN drm_key_id VARCHAR(32),
+    ADD COLUMN drm_scheme VARCHAR(10);
@@ -0,0 +1,10 @@
+-- Tool versions and encoder settings each job was processed with
+CREATE TABLE job_environments
+(
+    job_id      VARCHAR(64) PRIMARY KEY,
+    video_id    UUID                     NOT NULL REFERENCES video_files (video_id) ON DELETE CASCADE,
+    environment JSONB                    NOT NULL,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_job_environments_video_id ON job_environments (video_id, created_at);
@@ -0,0 +1 @@
+ALTER TABLE playback_info DROP COLUMN IF EXISTS thumbnails;
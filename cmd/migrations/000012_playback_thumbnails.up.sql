@@ -0,0 +1 @@
+ALTER TABLE playback_info ADD COLUMN thumbnails TEXT[] NOT NULL DEFAULT ARRAY[]::TEXT[];
@@ -0,0 +1,120 @@
+-- Turn the analytics tables back into plain tables
+
+DROP VIEW video_performance;
+
+-- video_views
+ALTER TABLE video_views RENAME TO video_views_partitioned;
+ALTER TABLE video_views_partitioned DROP CONSTRAINT video_views_pkey;
+ALTER SEQUENCE video_views_id_seq RENAME TO video_views_partitioned_id_seq;
+
+CREATE TABLE video_views (
+    id SERIAL PRIMARY KEY,
+    video_id UUID NOT NULL REFERENCES video_files(video_id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(user_id) ON DELETE SET NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent TEXT,
+    timestamp TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    duration INTEGER DEFAULT 0, -- Duration watched in seconds
+    country VARCHAR(2) NOT NULL DEFAULT '',
+    region VARCHAR(128) NOT NULL DEFAULT '',
+    device_type VARCHAR(16) NOT NULL DEFAULT 'unknown',
+    browser VARCHAR(64) NOT NULL DEFAULT 'unknown',
+    os VARCHAR(64) NOT NULL DEFAULT 'unknown'
+);
+
+INSERT INTO video_views (id, video_id, user_id, ip, user_agent, timestamp, duration, country, region, device_type, browser, os)
+SELECT id, video_id, user_id, ip, user_agent, timestamp, duration, country, region, device_type, browser, os
+FROM video_views_partitioned;
+SELECT setval('video_views_id_seq', COALESCE((SELECT MAX(id) FROM video_views), 0) + 1, false);
+
+DROP TABLE video_views_partitioned;
+
+CREATE INDEX idx_video_views_video_id ON video_views(video_id);
+CREATE INDEX idx_video_views_user_id ON video_views(user_id);
+CREATE INDEX idx_video_views_timestamp ON video_views(timestamp);
+CREATE INDEX idx_video_views_video_id_country ON video_views(video_id, country);
+CREATE INDEX idx_video_views_video_id_device_type ON video_views(video_id, device_type);
+
+-- video_watch_sessions
+ALTER TABLE video_watch_sessions RENAME TO video_watch_sessions_partitioned;
+ALTER TABLE video_watch_sessions_partitioned DROP CONSTRAINT video_watch_sessions_pkey;
+ALTER SEQUENCE video_watch_sessions_id_seq RENAME TO video_watch_sessions_partitioned_id_seq;
+
+CREATE TABLE video_watch_sessions (
+    id SERIAL PRIMARY KEY,
+    video_id UUID NOT NULL REFERENCES video_files(video_id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(user_id) ON DELETE SET NULL,
+    session_id VARCHAR(64) NOT NULL,
+    start_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    end_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    watch_duration INTEGER NOT NULL, -- Duration watched in seconds
+    completed BOOLEAN DEFAULT FALSE
+);
+
+INSERT INTO video_watch_sessions (id, video_id, user_id, session_id, start_time, end_time, watch_duration, completed)
+SELECT id, video_id, user_id, session_id, start_time, end_time, watch_duration, completed
+FROM video_watch_sessions_partitioned;
+SELECT setval('video_watch_sessions_id_seq', COALESCE((SELECT MAX(id) FROM video_watch_sessions), 0) + 1, false);
+
+DROP TABLE video_watch_sessions_partitioned;
+
+CREATE INDEX idx_video_watch_sessions_video_id ON video_watch_sessions(video_id);
+CREATE INDEX idx_video_watch_sessions_user_id ON video_watch_sessions(user_id);
+CREATE INDEX idx_video_watch_sessions_session_id ON video_watch_sessions(session_id);
+CREATE INDEX idx_video_watch_sessions_start_time ON video_watch_sessions(start_time);
+
+-- video_heartbeats
+ALTER TABLE video_heartbeats RENAME TO video_heartbeats_partitioned;
+ALTER TABLE video_heartbeats_partitioned DROP CONSTRAINT video_heartbeats_pkey;
+ALTER SEQUENCE video_heartbeats_id_seq RENAME TO video_heartbeats_partitioned_id_seq;
+
+CREATE TABLE video_heartbeats (
+    id BIGSERIAL PRIMARY KEY,
+    video_id UUID NOT NULL REFERENCES video_files(video_id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(user_id) ON DELETE SET NULL,
+    session_id VARCHAR(64) NOT NULL,
+    position INTEGER NOT NULL, -- Playback position in seconds
+    timestamp TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO video_heartbeats (id, video_id, user_id, session_id, position, timestamp)
+SELECT id, video_id, user_id, session_id, position, timestamp
+FROM video_heartbeats_partitioned;
+SELECT setval('video_heartbeats_id_seq', COALESCE((SELECT MAX(id) FROM video_heartbeats), 0) + 1, false);
+
+DROP TABLE video_heartbeats_partitioned;
+
+CREATE INDEX idx_video_heartbeats_video_id_position ON video_heartbeats(video_id, position);
+CREATE INDEX idx_video_heartbeats_session_id ON video_heartbeats(session_id);
+CREATE INDEX idx_video_heartbeats_timestamp ON video_heartbeats(timestamp);
+
+CREATE VIEW video_performance AS
+SELECT 
+    v.video_id,
+    p.title,
+    p.duration,
+    e.total_views,
+    e.unique_views,
+    e.total_watch_time,
+    e.avg_watch_time,
+    e.completion_rate,
+    e.engagement_score,
+    (SELECT COUNT(*) FROM video_views 
+     WHERE video_id = v.video_id AND timestamp > NOW() - INTERVAL '7 days') AS views_last_7_days,
+    (SELECT COUNT(*) FROM video_views 
+     WHERE video_id = v.video_id AND timestamp > NOW() - INTERVAL '30 days') AS views_last_30_days,
+    (SELECT COALESCE(SUM(duration), 0) FROM video_views 
+     WHERE video_id = v.video_id AND timestamp > NOW() - INTERVAL '7 days') AS watch_time_last_7_days,
+    (SELECT COALESCE(SUM(duration), 0) FROM video_views 
+     WHERE video_id = v.video_id AND timestamp > NOW() - INTERVAL '30 days') AS watch_time_last_30_days,
+    p.thumbnail AS thumbnail_url,
+    v.uploaded_at AS created_at
+FROM 
+    video_files v
+JOIN 
+    playback_info p ON v.video_id = p.video_id
+LEFT JOIN 
+    video_engagement e ON v.video_id = e.video_id;
+
+
+DROP FUNCTION IF EXISTS create_monthly_partitions(TEXT, DATE, DATE);
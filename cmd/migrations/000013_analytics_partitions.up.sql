@@ -0,0 +1,161 @@
+-- Partition the raw analytics tables by month so expired data can be pruned
+-- by dropping whole partitions instead of deleting rows
+
+-- Creates a partition of parent for every month from from_month to to_month,
+-- named <parent>_pYYYY_MM. Also called by the retention job to create
+-- partitions ahead of time.
+CREATE OR REPLACE FUNCTION create_monthly_partitions(parent TEXT, from_month DATE, to_month DATE)
+RETURNS VOID AS $$
+DECLARE
+    month DATE := date_trunc('month', from_month);
+BEGIN
+    WHILE month <= to_month LOOP
+        EXECUTE format(
+            'CREATE TABLE IF NOT EXISTS %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+            parent || '_p' || to_char(month, 'YYYY_MM'), parent, month, (month + INTERVAL '1 month')::date
+        );
+        month := month + INTERVAL '1 month';
+    END LOOP;
+END;
+$$ LANGUAGE plpgsql;
+
+-- The view depends on video_views and is recreated once it is partitioned
+DROP VIEW video_performance;
+
+-- video_views
+ALTER TABLE video_views RENAME TO video_views_unpartitioned;
+ALTER TABLE video_views_unpartitioned DROP CONSTRAINT video_views_pkey;
+ALTER SEQUENCE video_views_id_seq OWNED BY NONE;
+
+CREATE TABLE video_views (
+    id INTEGER NOT NULL DEFAULT nextval('video_views_id_seq'),
+    video_id UUID NOT NULL REFERENCES video_files(video_id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(user_id) ON DELETE SET NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent TEXT,
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    duration INTEGER DEFAULT 0, -- Duration watched in seconds
+    country VARCHAR(2) NOT NULL DEFAULT '',
+    region VARCHAR(128) NOT NULL DEFAULT '',
+    device_type VARCHAR(16) NOT NULL DEFAULT 'unknown',
+    browser VARCHAR(64) NOT NULL DEFAULT 'unknown',
+    os VARCHAR(64) NOT NULL DEFAULT 'unknown',
+    PRIMARY KEY (id, timestamp)
+) PARTITION BY RANGE (timestamp);
+
+CREATE TABLE video_views_default PARTITION OF video_views DEFAULT;
+SELECT create_monthly_partitions(
+    'video_views',
+    COALESCE((SELECT MIN(timestamp) FROM video_views_unpartitioned)::date, CURRENT_DATE),
+    (CURRENT_DATE + INTERVAL '2 months')::date
+);
+
+INSERT INTO video_views (id, video_id, user_id, ip, user_agent, timestamp, duration, country, region, device_type, browser, os)
+SELECT id, video_id, user_id, ip, user_agent, COALESCE(timestamp, CURRENT_TIMESTAMP), duration, country, region, device_type, browser, os
+FROM video_views_unpartitioned;
+
+DROP TABLE video_views_unpartitioned;
+ALTER SEQUENCE video_views_id_seq OWNED BY video_views.id;
+
+CREATE INDEX idx_video_views_video_id ON video_views(video_id);
+CREATE INDEX idx_video_views_user_id ON video_views(user_id);
+CREATE INDEX idx_video_views_timestamp ON video_views(timestamp);
+CREATE INDEX idx_video_views_video_id_country ON video_views(video_id, country);
+CREATE INDEX idx_video_views_video_id_device_type ON video_views(video_id, device_type);
+
+-- video_watch_sessions
+ALTER TABLE video_watch_sessions RENAME TO video_watch_sessions_unpartitioned;
+ALTER TABLE video_watch_sessions_unpartitioned DROP CONSTRAINT video_watch_sessions_pkey;
+ALTER SEQUENCE video_watch_sessions_id_seq OWNED BY NONE;
+
+CREATE TABLE video_watch_sessions (
+    id INTEGER NOT NULL DEFAULT nextval('video_watch_sessions_id_seq'),
+    video_id UUID NOT NULL REFERENCES video_files(video_id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(user_id) ON DELETE SET NULL,
+    session_id VARCHAR(64) NOT NULL,
+    start_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    end_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    watch_duration INTEGER NOT NULL, -- Duration watched in seconds
+    completed BOOLEAN DEFAULT FALSE,
+    PRIMARY KEY (id, start_time)
+) PARTITION BY RANGE (start_time);
+
+CREATE TABLE video_watch_sessions_default PARTITION OF video_watch_sessions DEFAULT;
+SELECT create_monthly_partitions(
+    'video_watch_sessions',
+    COALESCE((SELECT MIN(start_time) FROM video_watch_sessions_unpartitioned)::date, CURRENT_DATE),
+    (CURRENT_DATE + INTERVAL '2 months')::date
+);
+
+INSERT INTO video_watch_sessions (id, video_id, user_id, session_id, start_time, end_time, watch_duration, completed)
+SELECT id, video_id, user_id, session_id, start_time, end_time, watch_duration, completed
+FROM video_watch_sessions_unpartitioned;
+
+DROP TABLE video_watch_sessions_unpartitioned;
+ALTER SEQUENCE video_watch_sessions_id_seq OWNED BY video_watch_sessions.id;
+
+CREATE INDEX idx_video_watch_sessions_video_id ON video_watch_sessions(video_id);
+CREATE INDEX idx_video_watch_sessions_user_id ON video_watch_sessions(user_id);
+CREATE INDEX idx_video_watch_sessions_session_id ON video_watch_sessions(session_id);
+CREATE INDEX idx_video_watch_sessions_start_time ON video_watch_sessions(start_time);
+
+-- video_heartbeats
+ALTER TABLE video_heartbeats RENAME TO video_heartbeats_unpartitioned;
+ALTER TABLE video_heartbeats_unpartitioned DROP CONSTRAINT video_heartbeats_pkey;
+ALTER SEQUENCE video_heartbeats_id_seq OWNED BY NONE;
+
+CREATE TABLE video_heartbeats (
+    id BIGINT NOT NULL DEFAULT nextval('video_heartbeats_id_seq'),
+    video_id UUID NOT NULL REFERENCES video_files(video_id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(user_id) ON DELETE SET NULL,
+    session_id VARCHAR(64) NOT NULL,
+    position INTEGER NOT NULL, -- Playback position in seconds
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id, timestamp)
+) PARTITION BY RANGE (timestamp);
+
+CREATE TABLE video_heartbeats_default PARTITION OF video_heartbeats DEFAULT;
+SELECT create_monthly_partitions(
+    'video_heartbeats',
+    COALESCE((SELECT MIN(timestamp) FROM video_heartbeats_unpartitioned)::date, CURRENT_DATE),
+    (CURRENT_DATE + INTERVAL '2 months')::date
+);
+
+INSERT INTO video_heartbeats (id, video_id, user_id, session_id, position, timestamp)
+SELECT id, video_id, user_id, session_id, position, COALESCE(timestamp, CURRENT_TIMESTAMP)
+FROM video_heartbeats_unpartitioned;
+
+DROP TABLE video_heartbeats_unpartitioned;
+ALTER SEQUENCE video_heartbeats_id_seq OWNED BY video_heartbeats.id;
+
+CREATE INDEX idx_video_heartbeats_video_id_position ON video_heartbeats(video_id, position);
+CREATE INDEX idx_video_heartbeats_session_id ON video_heartbeats(session_id);
+CREATE INDEX idx_video_heartbeats_timestamp ON video_heartbeats(timestamp);
+
+CREATE VIEW video_performance AS
+SELECT 
+    v.video_id,
+    p.title,
+    p.duration,
+    e.total_views,
+    e.unique_views,
+    e.total_watch_time,
+    e.avg_watch_time,
+    e.completion_rate,
+    e.engagement_score,
+    (SELECT COUNT(*) FROM video_views 
+     WHERE video_id = v.video_id AND timestamp > NOW() - INTERVAL '7 days') AS views_last_7_days,
+    (SELECT COUNT(*) FROM video_views 
+     WHERE video_id = v.video_id AND timestamp > NOW() - INTERVAL '30 days') AS views_last_30_days,
+    (SELECT COALESCE(SUM(duration), 0) FROM video_views 
+     WHERE video_id = v.video_id AND timestamp > NOW() - INTERVAL '7 days') AS watch_time_last_7_days,
+    (SELECT COALESCE(SUM(duration), 0) FROM video_views 
+     WHERE video_id = v.video_id AND timestamp > NOW() - INTERVAL '30 days') AS watch_time_last_30_days,
+    p.thumbnail AS thumbnail_url,
+    v.uploaded_at AS created_at
+FROM 
+    video_files v
+JOIN 
+    playback_info p ON v.video_id = p.video_id
+LEFT JOIN 
+    video_engagement e ON v.video_id = e.video_id;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_video_files_search_vector;
+DROP TRIGGER IF EXISTS video_files_search_vector_trigger ON video_files;
+DROP FUNCTION IF EXISTS video_files_search_vector_update();
+
+ALTER TABLE video_files
+    DROP COLUMN search_vector,
+    DROP COLUMN tags,
+    DROP COLUMN description,
+    DROP COLUMN title;
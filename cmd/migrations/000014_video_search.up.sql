@@ -0,0 +1,28 @@
+-- Full-text search over the video library. The search vector is kept up to
+-- date by a trigger since array_to_string is not immutable and cannot be used
+-- in a generated column.
+
+ALTER TABLE video_files
+    ADD COLUMN title VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN description TEXT NOT NULL DEFAULT '',
+    ADD COLUMN tags TEXT[] NOT NULL DEFAULT ARRAY[]::TEXT[],
+    ADD COLUMN search_vector TSVECTOR;
+
+CREATE OR REPLACE FUNCTION video_files_search_vector_update() RETURNS trigger AS $$
+BEGIN
+    NEW.search_vector :=
+        setweight(to_tsvector('english', COALESCE(NULLIF(NEW.title, ''), NEW.file_name)), 'A') ||
+        setweight(to_tsvector('english', COALESCE(NEW.description, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(array_to_string(NEW.tags, ' '), '')), 'C');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER video_files_search_vector_trigger
+    BEFORE INSERT OR UPDATE OF title, description, tags, file_name ON video_files
+    FOR EACH ROW EXECUTE FUNCTION video_files_search_vector_update();
+
+-- Fire the trigger for existing videos
+UPDATE video_files SET title = title;
+
+CREATE INDEX idx_video_files_search_vector ON video_files USING GIN (search_vector);
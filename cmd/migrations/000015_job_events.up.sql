@@ -0,0 +1,15 @@
+-- Lifecycle events of every job, aggregated into the admin throughput stats
+CREATE TABLE job_events
+(
+    id             BIGSERIAL PRIMARY KEY,
+    job_id         VARCHAR(64)              NOT NULL,
+    video_id       UUID                     NOT NULL REFERENCES video_files (video_id) ON DELETE CASCADE,
+    event          VARCHAR(16)              NOT NULL CHECK ( event IN ('queued', 'started', 'completed', 'failed') ),
+    job_type       VARCHAR(16)              NOT NULL DEFAULT 'encode',
+    codec          VARCHAR(16)              NOT NULL DEFAULT '',
+    failure_reason VARCHAR(32),
+    created_at     TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_job_events_created_at ON job_events (created_at);
+CREATE INDEX idx_job_events_job_id ON job_events (job_id, created_at);
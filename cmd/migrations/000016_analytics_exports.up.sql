@@ -0,0 +1,20 @@
+-- Analytics exports too large to stream, written to object storage in the
+-- background and downloaded through a presigned URL
+CREATE TABLE analytics_exports
+(
+    export_id    UUID PRIMARY KEY                  DEFAULT uuid_generate_v4(),
+    user_id      UUID                     NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    dataset      VARCHAR(16)              NOT NULL CHECK ( dataset IN ('views', 'sessions') ),
+    format       VARCHAR(16)              NOT NULL CHECK ( format IN ('csv', 'ndjson') ),
+    video_id     UUID,
+    start_date   TIMESTAMP WITH TIME ZONE NOT NULL,
+    end_date     TIMESTAMP WITH TIME ZONE NOT NULL,
+    status       VARCHAR(16)              NOT NULL DEFAULT 'pending' CHECK ( status IN ('pending', 'running', 'completed', 'failed') ),
+    object_key   VARCHAR(512),
+    rows         BIGINT                   NOT NULL DEFAULT 0,
+    error        TEXT,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_analytics_exports_user_id ON analytics_exports (user_id, created_at DESC);
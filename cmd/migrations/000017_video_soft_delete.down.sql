@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_video_files_deleted_at;
+
+ALTER TABLE video_files
+    DROP COLUMN IF EXISTS deleted_at;
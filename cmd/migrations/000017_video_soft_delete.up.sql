@@ -0,0 +1,5 @@
+-- Deleted videos stay in the trash, restorable, until they are purged
+ALTER TABLE video_files
+    ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_video_files_deleted_at ON video_files (deleted_at) WHERE deleted_at IS NOT NULL;
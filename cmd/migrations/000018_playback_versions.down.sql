@@ -0,0 +1,4 @@
+ALTER TABLE playback_info
+    DROP COLUMN IF EXISTS version;
+
+DROP TABLE IF EXISTS playback_versions;
@@ -0,0 +1,27 @@
+-- Every encode of a video's outputs. playback_info holds the active one; the
+-- others are kept so playback can be rolled back to them. Version 0 is the
+-- original encode, stored at the video's unversioned output prefix.
+CREATE TABLE playback_versions
+(
+    video_id      UUID                     NOT NULL REFERENCES video_files (video_id) ON DELETE CASCADE,
+    version       INTEGER                  NOT NULL,
+    job_id        VARCHAR(64),
+    profile       JSONB,
+    status        VARCHAR(20)              NOT NULL DEFAULT 'queued',
+    error_message TEXT,
+    title         VARCHAR(255)             NOT NULL DEFAULT '',
+    duration      DECIMAL(10, 3)           NOT NULL DEFAULT 0,
+    thumbnail     TEXT                     NOT NULL DEFAULT '',
+    thumbnails    TEXT[]                   NOT NULL DEFAULT ARRAY []::TEXT[],
+    qualities     JSONB                    NOT NULL DEFAULT '{}'::jsonb,
+    subtitles     TEXT[]                   NOT NULL DEFAULT ARRAY []::TEXT[],
+    format        playback_format          NOT NULL DEFAULT 'hls',
+    drm_key_id    VARCHAR(32),
+    drm_scheme    VARCHAR(10),
+    created_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    completed_at  TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (video_id, version)
+);
+
+ALTER TABLE playback_info
+    ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
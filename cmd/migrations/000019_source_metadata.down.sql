@@ -0,0 +1,2 @@
+ALTER TABLE video_files
+    DROP COLUMN IF EXISTS source_metadata;
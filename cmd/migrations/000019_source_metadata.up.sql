@@ -0,0 +1,4 @@
+-- ffprobe output of the uploaded source, recorded by the worker that
+-- downloads it
+ALTER TABLE video_files
+    ADD COLUMN source_metadata JSONB;
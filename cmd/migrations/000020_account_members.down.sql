@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_account_members_user_id;
+DROP TABLE IF EXISTS account_members;
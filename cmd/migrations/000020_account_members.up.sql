@@ -0,0 +1,14 @@
+-- Teammates an account grants access to its video library and analytics. The
+-- account's own user is not listed: it holds every permission on it.
+CREATE TABLE account_members
+(
+    account_id UUID                     NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    user_id    UUID                     NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    role       VARCHAR(10)              NOT NULL CHECK (role IN ('admin', 'editor', 'viewer')),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (account_id, user_id),
+    CHECK (account_id <> user_id)
+);
+
+CREATE INDEX idx_account_members_user_id ON account_members (user_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_audit_logs_account_created;
+DROP TABLE IF EXISTS audit_logs;
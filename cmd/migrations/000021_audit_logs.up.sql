@@ -0,0 +1,18 @@
+-- Mutating actions taken on an account, by its user or its members
+CREATE TABLE audit_logs
+(
+    id          BIGSERIAL PRIMARY KEY,
+    account_id  UUID                     NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    actor_id    UUID                     NOT NULL,
+    actor_email VARCHAR(60)              NOT NULL DEFAULT '',
+    action      VARCHAR(40)              NOT NULL,
+    resource_id VARCHAR(255)             NOT NULL DEFAULT '',
+    method      VARCHAR(10)              NOT NULL,
+    path        TEXT                     NOT NULL,
+    ip          VARCHAR(45)              NOT NULL DEFAULT '',
+    status_code INTEGER                  NOT NULL,
+    payload     JSONB                    NOT NULL DEFAULT '{}'::jsonb,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_logs_account_created ON audit_logs (account_id, created_at DESC);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_job_usage_job_id;
+DROP INDEX IF EXISTS idx_job_usage_user_finished;
+DROP TABLE IF EXISTS job_usage;
@@ -0,0 +1,22 @@
+-- Compute taken by every attempt at a job, for reconciling cost estimates and
+-- exporting usage to billing
+CREATE TABLE job_usage
+(
+    id              BIGSERIAL PRIMARY KEY,
+    job_id          VARCHAR(64)              NOT NULL,
+    video_id        UUID                     NOT NULL REFERENCES video_files (video_id) ON DELETE CASCADE,
+    user_id         UUID                     NOT NULL,
+    job_type        VARCHAR(16)              NOT NULL DEFAULT 'encode',
+    codec           VARCHAR(16)              NOT NULL DEFAULT '',
+    hardware_class  VARCHAR(8)               NOT NULL DEFAULT 'cpu',
+    outcome         VARCHAR(16)              NOT NULL CHECK ( outcome IN ('completed', 'failed', 'interrupted') ),
+    source_seconds  DECIMAL(10, 3)           NOT NULL DEFAULT 0,
+    compute_seconds DECIMAL(12, 3)           NOT NULL,
+    cost            DECIMAL(14, 6)           NOT NULL DEFAULT 0,
+    currency        VARCHAR(3)               NOT NULL DEFAULT '',
+    started_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at     TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_job_usage_user_finished ON job_usage (user_id, finished_at);
+CREATE INDEX idx_job_usage_job_id ON job_usage (job_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_billing_events_pending;
+DROP INDEX IF EXISTS idx_billing_events_user_created;
+DROP TABLE IF EXISTS billing_events;
@@ -0,0 +1,24 @@
+-- Usage of every completed job, metered for billing. Events are delivered to
+-- the billing webhook when one is configured; delivered_at stays NULL until
+-- the receiver accepts them.
+CREATE TABLE billing_events
+(
+    id                    BIGSERIAL PRIMARY KEY,
+    event_id              UUID                     NOT NULL UNIQUE,
+    type                  VARCHAR(32)              NOT NULL,
+    job_id                VARCHAR(64)              NOT NULL,
+    video_id              UUID                     NOT NULL,
+    user_id               UUID                     NOT NULL,
+    job_type              VARCHAR(16)              NOT NULL DEFAULT 'encode',
+    tier                  VARCHAR(16)              NOT NULL,
+    encode_minutes        DECIMAL(12, 3)           NOT NULL DEFAULT 0,
+    storage_bytes         BIGINT                   NOT NULL DEFAULT 0,
+    egress_bytes_per_view BIGINT                   NOT NULL DEFAULT 0,
+    created_at            TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    delivered_at          TIMESTAMP WITH TIME ZONE,
+    attempts              INTEGER                  NOT NULL DEFAULT 0,
+    next_attempt_at       TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_billing_events_user_created ON billing_events (user_id, created_at);
+CREATE INDEX idx_billing_events_pending ON billing_events (next_attempt_at) WHERE delivered_at IS NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE playback_versions
+    DROP COLUMN IF EXISTS chapters;
+
+ALTER TABLE playback_info
+    DROP COLUMN IF EXISTS chapters;
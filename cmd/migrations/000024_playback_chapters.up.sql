@@ -0,0 +1,6 @@
+-- Chapter markers of the encoded video, for players to navigate by
+ALTER TABLE playback_info
+    ADD COLUMN chapters JSONB;
+
+ALTER TABLE playback_versions
+    ADD COLUMN chapters JSONB;
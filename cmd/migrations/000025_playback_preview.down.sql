@@ -0,0 +1,5 @@
+ALTER TABLE playback_versions
+    DROP COLUMN IF EXISTS preview;
+
+ALTER TABLE playback_info
+    DROP COLUMN IF EXISTS preview;
@@ -0,0 +1,6 @@
+-- URL of the short animated preview shown when hovering a video
+ALTER TABLE playback_info
+    ADD COLUMN preview TEXT;
+
+ALTER TABLE playback_versions
+    ADD COLUMN preview TEXT;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_output_cache_video_id;
+DROP TABLE IF EXISTS output_cache;
@@ -0,0 +1,15 @@
+-- Outputs of finished encodes keyed by the hash of their source and of the
+-- profile they were encoded with, so a user submitting the same file with the
+-- same settings gets a copy instead of a new encode
+CREATE TABLE output_cache
+(
+    user_id       UUID                     NOT NULL,
+    source_hash   VARCHAR(64)              NOT NULL,
+    profile_hash  VARCHAR(64)              NOT NULL,
+    video_id      UUID                     NOT NULL REFERENCES video_files (video_id) ON DELETE CASCADE,
+    output_prefix TEXT                     NOT NULL,
+    created_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, source_hash, profile_hash)
+);
+
+CREATE INDEX idx_output_cache_video_id ON output_cache (video_id);
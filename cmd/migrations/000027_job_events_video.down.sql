@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_job_events_video_id;
@@ -0,0 +1,2 @@
+-- The status endpoint looks up the last job of a video
+CREATE INDEX idx_job_events_video_id ON job_events (video_id, created_at);
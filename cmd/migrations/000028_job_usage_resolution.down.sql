@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_job_usage_finished_at;
+ALTER TABLE job_usage DROP COLUMN IF EXISTS resolution;
@@ -0,0 +1,5 @@
+-- The highest rendition of every encode, so ETAs can be based on the
+-- throughput of jobs of the same codec, resolution and hardware class
+ALTER TABLE job_usage ADD COLUMN resolution VARCHAR(16) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_job_usage_finished_at ON job_usage (finished_at);
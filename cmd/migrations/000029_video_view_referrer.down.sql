@@ -0,0 +1 @@
+ALTER TABLE video_views DROP COLUMN IF EXISTS referrer;
@@ -0,0 +1,3 @@
+-- The host of the page a video was played on, for the top referrers of the
+-- account summary. Empty for direct playback.
+ALTER TABLE video_views ADD COLUMN referrer VARCHAR(255) NOT NULL DEFAULT '';
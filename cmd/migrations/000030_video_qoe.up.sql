@@ -0,0 +1,23 @@
+-- Playback quality reported by players, partitioned by month like the other
+-- raw analytics tables
+CREATE TABLE video_qoe_beacons (
+    id BIGSERIAL,
+    video_id UUID NOT NULL REFERENCES video_files(video_id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(user_id) ON DELETE SET NULL,
+    session_id VARCHAR(64) NOT NULL,
+    playback_version INTEGER NOT NULL DEFAULT 0,
+    rendition VARCHAR(16) NOT NULL DEFAULT '',
+    bitrate INTEGER NOT NULL DEFAULT 0, -- Bits per second of the rendition
+    startup_time INTEGER, -- Milliseconds to the first frame, sent once per session
+    rebuffer_count INTEGER NOT NULL DEFAULT 0,
+    rebuffer_duration INTEGER NOT NULL DEFAULT 0, -- Milliseconds
+    bitrate_switches INTEGER NOT NULL DEFAULT 0,
+    play_time INTEGER NOT NULL DEFAULT 0, -- Milliseconds
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id, timestamp)
+) PARTITION BY RANGE (timestamp);
+
+CREATE TABLE video_qoe_beacons_default PARTITION OF video_qoe_beacons DEFAULT;
+SELECT create_monthly_partitions('video_qoe_beacons', CURRENT_DATE, (CURRENT_DATE + INTERVAL '2 months')::date);
+
+CREATE INDEX idx_video_qoe_beacons_video_id_timestamp ON video_qoe_beacons(video_id, timestamp);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS cdn_log_files;
+DROP TABLE IF EXISTS delivery_stats;
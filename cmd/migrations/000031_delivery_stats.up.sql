@@ -0,0 +1,19 @@
+-- Bytes and requests delivered per video, rendition and day, attributed from
+-- CDN access logs
+CREATE TABLE delivery_stats (
+    video_id UUID NOT NULL REFERENCES video_files(video_id) ON DELETE CASCADE,
+    rendition VARCHAR(16) NOT NULL,
+    day DATE NOT NULL,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    requests BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (video_id, rendition, day)
+);
+
+CREATE INDEX idx_delivery_stats_day ON delivery_stats(day);
+
+-- Access log files already counted, so each is ingested once
+CREATE TABLE cdn_log_files (
+    key TEXT PRIMARY KEY,
+    records BIGINT NOT NULL DEFAULT 0,
+    processed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+-- Postgres cannot drop enum values; rejected videos and jobs become failed
+UPDATE video_files SET status = 'failed' WHERE status = 'rejected';
+UPDATE encoding_jobs SET status = 'failed' WHERE status = 'rejected';
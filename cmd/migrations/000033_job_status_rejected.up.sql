@@ -0,0 +1,2 @@
+-- Videos whose source failed the malware scan
+ALTER TYPE job_status ADD VALUE IF NOT EXISTS 'rejected';
@@ -0,0 +1,5 @@
+ALTER TABLE playback_info DROP COLUMN IF EXISTS images,
+    DROP COLUMN IF EXISTS waveform,
+    DROP COLUMN IF EXISTS kind;
+
+ALTER TABLE video_files DROP COLUMN IF EXISTS kind;
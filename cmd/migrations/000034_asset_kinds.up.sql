@@ -0,0 +1,8 @@
+-- Audio files and images are stored and played like videos, with their kind
+-- telling the pipelines and players apart
+ALTER TABLE video_files ADD COLUMN IF NOT EXISTS kind VARCHAR(16) NOT NULL DEFAULT 'video'
+    CHECK (kind IN ('video', 'audio', 'image'));
+
+ALTER TABLE playback_info ADD COLUMN IF NOT EXISTS kind VARCHAR(16) NOT NULL DEFAULT 'video',
+    ADD COLUMN IF NOT EXISTS waveform TEXT,
+    ADD COLUMN IF NOT EXISTS images JSONB;
@@ -0,0 +1 @@
+ALTER TABLE playback_versions DROP COLUMN IF EXISTS waveform;
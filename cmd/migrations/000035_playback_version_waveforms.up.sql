@@ -0,0 +1,3 @@
+-- Playback versions keep the waveform of their encode, so rolling back
+-- restores it with the rest of the playback info
+ALTER TABLE playback_versions ADD COLUMN IF NOT EXISTS waveform TEXT;
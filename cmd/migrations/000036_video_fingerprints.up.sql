@@ -0,0 +1,8 @@
+-- Perceptual fingerprints of video sources, compared to find re-uploads
+-- within an account
+CREATE TABLE IF NOT EXISTS video_fingerprints (
+    video_id UUID PRIMARY KEY REFERENCES video_files(video_id) ON DELETE CASCADE,
+    interval DOUBLE PRECISION NOT NULL,
+    hashes BIGINT[] NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"sync"
@@ -15,8 +17,11 @@ import (
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/db/aws"
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/db/postgres"
 	clientRedis "github.com/amankumarsingh77/cloud-video-encoder/pkg/db/redis"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/kms"
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/mtls"
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
@@ -74,15 +79,24 @@ func main() {
 
 	// Initialize repositories
 	awsRepo := repository.NewAwsRepository(awsClient, presignClient)
-	redisRepo := repository.NewVideoRedisRepo(redisClient)
+	jobQueue, err := repository.NewQueue(cfg, redisClient)
+	if err != nil {
+		appLogger.Fatalf("Queue init error: %s", err)
+	}
+	redisRepo := repository.NewVideoRedisRepo(redisClient, jobQueue)
 	videoRepo := repository.NewVideoRepo(psqlDB)
 
+	keyManager, err := kms.New(cfg.Encryption.MasterKey)
+	if err != nil && !errors.Is(err, kms.ErrNotConfigured) {
+		appLogger.Fatalf("KMS init error: %s", err)
+	}
+
 	// Create context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Initialize and start worker pool
-	videoWorker, err := worker.NewWorker(cfg, appLogger, redisRepo, awsRepo, videoRepo)
+	videoWorker, err := worker.NewWorker(cfg, appLogger, redisRepo, awsRepo, videoRepo, keyManager)
 	if err != nil {
 		appLogger.Fatalf("Failed to initialize worker: %s", err)
 	}
@@ -104,10 +118,44 @@ func main() {
 		runHealthCheck(ctx, appLogger, cfg)
 	}()
 
+	// Expose worker metrics such as the storage circuit breaker state. With
+	// mTLS enabled only scrapers holding a certificate from the CA get in.
+	if cfg.Worker.MetricsPort != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		metricsServer := &http.Server{Addr: ":" + cfg.Worker.MetricsPort, Handler: mux}
+		if cfg.MTLS.CertFile != "" {
+			reloader, err := mtls.NewReloader(mtls.Options{
+				CertFile:     cfg.MTLS.CertFile,
+				KeyFile:      cfg.MTLS.KeyFile,
+				CAFile:       cfg.MTLS.CAFile,
+				AllowedNames: cfg.MTLS.AllowedNames,
+			})
+			if err != nil {
+				appLogger.Fatalf("Failed to load mtls certificates: %s", err)
+			}
+			metricsServer.TLSConfig = reloader.ServerConfig(true)
+		}
+		go func() {
+			var err error
+			if metricsServer.TLSConfig != nil {
+				err = metricsServer.ListenAndServeTLS("", "")
+			} else {
+				err = metricsServer.ListenAndServe()
+			}
+			if err != nil {
+				appLogger.Errorf("Metrics server error: %v", err)
+			}
+		}()
+	}
+
 	// Wait for shutdown signal
 	sig := <-sigChan
 	appLogger.Infof("Received shutdown signal: %v", sig)
 
+	// Stop taking jobs and let running encodes finish or checkpoint
+	videoWorker.Drain(time.Duration(cfg.Worker.DrainTimeout) * time.Second)
+
 	// Cancel context to stop all goroutines
 	cancel()
 
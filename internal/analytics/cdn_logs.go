@@ -0,0 +1,14 @@
+package analytics
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// LogStore holds the CDN access logs that delivered bytes are attributed
+// from
+type LogStore interface {
+	ListObjectsWithPrefix(ctx context.Context, bucket, prefix string) ([]string, error)
+	GetObject(ctx context.Context, bucket, filename string) (*s3.GetObjectOutput, error)
+}
@@ -10,13 +10,34 @@ type Handlers interface {
 	// Video views
 	RecordVideoView(c echo.Context) error
 	GetVideoViews(c echo.Context) error
+	GetVideoGeo(c echo.Context) error
+	GetVideoDevices(c echo.Context) error
+	GetVideoTimeSeries(c echo.Context) error
 	
 	// Watch sessions
 	StartWatchSession(c echo.Context) error
 	EndWatchSession(c echo.Context) error
+	RecordHeartbeat(c echo.Context) error
+	RecordLiveHeartbeat(c echo.Context) error
+	GetVideoViewers(c echo.Context) error
+	GetConcurrentViewers(c echo.Context) error
+	GetVideoRetention(c echo.Context) error
+	GetAccountHeatmap(c echo.Context) error
+
+	// Playback quality
+	RecordQoEBeacon(c echo.Context) error
+	GetVideoQoE(c echo.Context) error
+
+	// CDN delivery
+	GetVideoDelivery(c echo.Context) error
+	GetAccountDelivery(c echo.Context) error
 	
 	// Video performance
 	GetVideoPerformance(c echo.Context) error
 	GetTopPerformingVideos(c echo.Context) error
 	GetRecentVideos(c echo.Context) error
+
+	// Exports
+	ExportAnalytics(c echo.Context) error
+	GetAnalyticsExport(c echo.Context) error
 }
@@ -0,0 +1,136 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/analytics"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/httpErrors"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// ExportAnalytics godoc
+// @Summary Export views or watch sessions
+// @Description Export the views or watch sessions of the user's videos as CSV or NDJSON. Short ranges are streamed in the response; longer ones, or any with async=true, are written to storage in the background and 202 is returned with the export to poll.
+// @Tags analytics
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param dataset query string true "views or sessions"
+// @Param format query string false "csv (default) or ndjson"
+// @Param start_date query string true "Start date (YYYY-MM-DD)"
+// @Param end_date query string false "End date (YYYY-MM-DD), defaults to now"
+// @Param video_id query string false "Only export this video"
+// @Param async query bool false "Always export in the background"
+// @Success 200 {string} string
+// @Success 202 {object} models.AnalyticsExport
+// @Router /analytics/export [get]
+func (h *AnalyticsHandlers) ExportAnalytics(c echo.Context) error {
+	user, err := utils.GetUserFromCtx(c.Request().Context())
+	if err != nil {
+		return httpErrors.NewUnauthorizedError(err)
+	}
+
+	input := &models.AnalyticsExportInput{
+		Dataset: models.ExportDataset(c.QueryParam("dataset")),
+		Format:  models.ExportFormat(c.QueryParam("format")),
+	}
+	if input.Format == "" {
+		input.Format = models.ExportCSV
+	}
+	if videoIDStr := c.QueryParam("video_id"); videoIDStr != "" {
+		videoID, err := uuid.Parse(videoIDStr)
+		if err != nil {
+			return httpErrors.NewBadRequestError(err)
+		}
+		input.VideoID = &videoID
+	}
+	filter := &models.AnalyticsFilter{}
+	if err := parseTimeRange(c, filter); err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+	input.TimeRange = filter.TimeRange
+
+	async, _ := strconv.ParseBool(c.QueryParam("async"))
+	if !async {
+		w := &exportResponse{c: c, input: input}
+		err = h.useCase.ExportAnalytics(c.Request().Context(), user.UserID, input, w)
+		if err == nil {
+			w.start()
+			return nil
+		}
+		if !errors.Is(err, analytics.ErrExportTooLarge) {
+			if w.started {
+				// The status is already sent; cut the body short instead
+				h.logger.Errorf("Error streaming analytics export: %v", err)
+				return err
+			}
+			return httpErrors.NewBadRequestError(err)
+		}
+	}
+
+	export, err := h.useCase.StartAnalyticsExport(c.Request().Context(), user.UserID, input)
+	if err != nil {
+		h.logger.Errorf("Error starting analytics export: %v", err)
+		return httpErrors.NewBadRequestError(err)
+	}
+	return c.JSON(http.StatusAccepted, export)
+}
+
+// GetAnalyticsExport godoc
+// @Summary Get a background export
+// @Description Get the status of a background export, with a presigned download URL once it has completed
+// @Tags analytics
+// @Produce json
+// @Param export_id path string true "Export ID"
+// @Success 200 {object} models.AnalyticsExport
+// @Router /analytics/exports/{export_id} [get]
+func (h *AnalyticsHandlers) GetAnalyticsExport(c echo.Context) error {
+	user, err := utils.GetUserFromCtx(c.Request().Context())
+	if err != nil {
+		return httpErrors.NewUnauthorizedError(err)
+	}
+	exportID, err := uuid.Parse(c.Param("export_id"))
+	if err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+
+	export, err := h.useCase.GetAnalyticsExport(c.Request().Context(), user.UserID, exportID)
+	if err != nil {
+		if errors.Is(err, analytics.ErrExportNotFound) {
+			return httpErrors.NewNotFoundError(err)
+		}
+		h.logger.Errorf("Error getting analytics export: %v", err)
+		return httpErrors.NewInternalServerError(err)
+	}
+	return c.JSON(http.StatusOK, export)
+}
+
+// exportResponse sends the headers of a streamed export with its first
+// bytes, so errors raised before any row is read can still be answered with
+// an error status.
+type exportResponse struct {
+	c       echo.Context
+	input   *models.AnalyticsExportInput
+	started bool
+}
+
+func (r *exportResponse) Write(p []byte) (int, error) {
+	r.start()
+	return r.c.Response().Write(p)
+}
+
+func (r *exportResponse) start() {
+	if r.started {
+		return
+	}
+	r.started = true
+	header := r.c.Response().Header()
+	header.Set(echo.HeaderContentType, r.input.ContentType())
+	header.Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", r.input.FileName()))
+	r.c.Response().WriteHeader(http.StatusOK)
+}
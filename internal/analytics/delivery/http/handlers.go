@@ -1,12 +1,17 @@
 package http
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/analytics"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/httpErrors"
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
@@ -17,13 +22,15 @@ import (
 
 // AnalyticsHandlers implements the analytics.Handlers interface
 type AnalyticsHandlers struct {
+	cfg     *config.Config
 	useCase analytics.UseCase
 	logger  logger.Logger
 }
 
 // NewAnalyticsHandlers creates a new AnalyticsHandlers
-func NewAnalyticsHandlers(useCase analytics.UseCase, logger logger.Logger) analytics.Handlers {
+func NewAnalyticsHandlers(cfg *config.Config, useCase analytics.UseCase, logger logger.Logger) analytics.Handlers {
 	return &AnalyticsHandlers{
+		cfg:     cfg,
 		useCase: useCase,
 		logger:  logger,
 	}
@@ -31,10 +38,12 @@ func NewAnalyticsHandlers(useCase analytics.UseCase, logger logger.Logger) analy
 
 // GetAnalyticsSummary godoc
 // @Summary Get analytics summary for a user
-// @Description Get analytics summary including total videos, views, watch time, and top videos
+// @Description Get analytics summary including total videos, views, watch time, top videos, trends against the previous period and breakdowns by folder, tag and referrer
 // @Tags analytics
 // @Accept json
 // @Produce json
+// @Param start_date query string false "Start date of the trends and breakdowns (YYYY-MM-DD, default 30 days ago)"
+// @Param end_date query string false "End date of the trends and breakdowns (YYYY-MM-DD)"
 // @Success 200 {object} models.AnalyticsSummary
 // @Router /analytics/summary [get]
 func (h *AnalyticsHandlers) GetAnalyticsSummary(c echo.Context) error {
@@ -43,7 +52,12 @@ func (h *AnalyticsHandlers) GetAnalyticsSummary(c echo.Context) error {
 		return httpErrors.NewUnauthorizedError(err)
 	}
 
-	summary, err := h.useCase.GetAnalyticsSummary(c.Request().Context(), user.UserID)
+	filter := &models.AnalyticsFilter{}
+	if err := parseTimeRange(c, filter); err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+
+	summary, err := h.useCase.GetAnalyticsSummary(c.Request().Context(), user.UserID, filter)
 	if err != nil {
 		h.logger.Errorf("Error getting analytics summary: %v", err)
 		return httpErrors.NewInternalServerError(err)
@@ -59,7 +73,7 @@ func (h *AnalyticsHandlers) GetAnalyticsSummary(c echo.Context) error {
 // @Accept json
 // @Produce json
 // @Param input body models.VideoView true "Video view info"
-// @Success 201 {object} models.VideoView
+// @Success 202 {object} models.VideoView
 // @Router /analytics/views [post]
 func (h *AnalyticsHandlers) RecordVideoView(c echo.Context) error {
 	view := &models.VideoView{}
@@ -67,12 +81,14 @@ func (h *AnalyticsHandlers) RecordVideoView(c echo.Context) error {
 		return httpErrors.NewBadRequestError(err)
 	}
 
+	if err := h.checkVideoAccess(c, view.VideoID); err != nil {
+		return err
+	}
+
 	// Set user ID if authenticated
 	user, err := utils.GetUserFromCtx(c.Request().Context())
 	if err == nil {
 		view.UserID = user.UserID
-	}else {
-		log.Printf("error getting user id : %v", err)
 	}
 
 	// Set IP address
@@ -81,12 +97,34 @@ func (h *AnalyticsHandlers) RecordVideoView(c echo.Context) error {
 	// Set user agent
 	view.UserAgent = c.Request().UserAgent()
 
+	// Players embedded in another page send its URL; others are referred by
+	// the page that requested the view
+	referrer := view.Referrer
+	if referrer == "" {
+		referrer = c.Request().Referer()
+	}
+	view.Referrer = referrerHost(referrer)
+
+	// Prefer the country resolved by a trusted CDN when it is available
+	view.Country = ""
+	view.Region = ""
+	if header := h.cfg.Analytics.CountryHeader; header != "" {
+		if country := c.Request().Header.Get(header); countryCodeRe.MatchString(country) && country != "XX" {
+			view.Country = country
+		}
+	}
+
 	if err := h.useCase.RecordVideoView(c.Request().Context(), view); err != nil {
+		if errors.Is(err, analytics.ErrViewBufferFull) {
+			c.Response().Header().Set("Retry-After", "1")
+			return httpErrors.NewRestError(http.StatusServiceUnavailable, err.Error(), nil)
+		}
 		h.logger.Errorf("Error recording video view: %v", err)
 		return httpErrors.NewInternalServerError(err)
 	}
 
-	return c.JSON(http.StatusCreated, view)
+	// The view is written in the background, so it has no ID yet
+	return c.JSON(http.StatusAccepted, view)
 }
 
 // GetVideoViews godoc
@@ -115,25 +153,8 @@ func (h *AnalyticsHandlers) GetVideoViews(c echo.Context) error {
 	}
 
 	// Parse time range
-	startDateStr := c.QueryParam("start_date")
-	endDateStr := c.QueryParam("end_date")
-
-	if startDateStr != "" {
-		startDate, err := time.Parse("2006-01-02", startDateStr)
-		if err != nil {
-			return httpErrors.NewBadRequestError(err)
-		}
-		filter.TimeRange.StartDate = startDate
-	}
-
-	if endDateStr != "" {
-		endDate, err := time.Parse("2006-01-02", endDateStr)
-		if err != nil {
-			return httpErrors.NewBadRequestError(err)
-		}
-		// Set to end of day
-		endDate = endDate.Add(24*time.Hour - time.Second)
-		filter.TimeRange.EndDate = endDate
+	if err := parseTimeRange(c, filter); err != nil {
+		return httpErrors.NewBadRequestError(err)
 	}
 
 	// Parse pagination
@@ -160,6 +181,9 @@ func (h *AnalyticsHandlers) GetVideoViews(c echo.Context) error {
 
 	views, err := h.useCase.GetVideoViews(c.Request().Context(), videoID, filter)
 	if err != nil {
+		if errors.Is(err, analytics.ErrVideoAccessDenied) {
+			return httpErrors.NewForbiddenError(err)
+		}
 		h.logger.Errorf("Error getting video views: %v", err)
 		return httpErrors.NewInternalServerError(err)
 	}
@@ -167,6 +191,130 @@ func (h *AnalyticsHandlers) GetVideoViews(c echo.Context) error {
 	return c.JSON(http.StatusOK, views)
 }
 
+// GetVideoGeo godoc
+// @Summary Get video views by location
+// @Description Get views for a specific video grouped by country and region
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Param video_id path string true "Video ID"
+// @Param start_date query string false "Start date (YYYY-MM-DD)"
+// @Param end_date query string false "End date (YYYY-MM-DD)"
+// @Param limit query int false "Limit"
+// @Success 200 {array} models.GeoBreakdown
+// @Router /analytics/videos/{video_id}/geo [get]
+func (h *AnalyticsHandlers) GetVideoGeo(c echo.Context) error {
+	videoID, err := uuid.Parse(c.Param("video_id"))
+	if err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+
+	filter := &models.AnalyticsFilter{
+		VideoID: videoID,
+	}
+	if err := parseTimeRange(c, filter); err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			return httpErrors.NewBadRequestError(err)
+		}
+		filter.Limit = limit
+	}
+
+	breakdown, err := h.useCase.GetGeoBreakdown(c.Request().Context(), videoID, filter)
+	if err != nil {
+		if errors.Is(err, analytics.ErrVideoAccessDenied) {
+			return httpErrors.NewForbiddenError(err)
+		}
+		h.logger.Errorf("Error getting video geo breakdown: %v", err)
+		return httpErrors.NewInternalServerError(err)
+	}
+
+	return c.JSON(http.StatusOK, breakdown)
+}
+
+// GetVideoTimeSeries godoc
+// @Summary Get a video metric over time
+// @Description Get the views, unique viewers or watch time of a video bucketed by hour, day or week
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Param video_id path string true "Video ID"
+// @Param metric query string false "views, unique_viewers or watch_time (default views)"
+// @Param interval query string false "hour, day or week (default day)"
+// @Param start_date query string false "Start date (YYYY-MM-DD)"
+// @Param end_date query string false "End date (YYYY-MM-DD)"
+// @Success 200 {object} models.TimeSeries
+// @Router /analytics/videos/{video_id}/timeseries [get]
+func (h *AnalyticsHandlers) GetVideoTimeSeries(c echo.Context) error {
+	videoID, err := uuid.Parse(c.Param("video_id"))
+	if err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+
+	filter := &models.AnalyticsFilter{
+		VideoID: videoID,
+	}
+	if err := parseTimeRange(c, filter); err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+
+	metric := models.TimeSeriesMetric(c.QueryParam("metric"))
+	interval := models.TimeSeriesInterval(c.QueryParam("interval"))
+	series, err := h.useCase.GetVideoTimeSeries(c.Request().Context(), videoID, metric, interval, filter)
+	if err != nil {
+		if errors.Is(err, analytics.ErrInvalidTimeSeries) {
+			return httpErrors.NewBadRequestError(err)
+		}
+		if errors.Is(err, analytics.ErrVideoAccessDenied) {
+			return httpErrors.NewForbiddenError(err)
+		}
+		h.logger.Errorf("Error getting video time series: %v", err)
+		return httpErrors.NewInternalServerError(err)
+	}
+
+	return c.JSON(http.StatusOK, series)
+}
+
+// GetVideoDevices godoc
+// @Summary Get video views by device
+// @Description Get views for a specific video grouped by device type, browser and OS
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Param video_id path string true "Video ID"
+// @Param start_date query string false "Start date (YYYY-MM-DD)"
+// @Param end_date query string false "End date (YYYY-MM-DD)"
+// @Success 200 {object} models.DeviceBreakdown
+// @Router /analytics/videos/{video_id}/devices [get]
+func (h *AnalyticsHandlers) GetVideoDevices(c echo.Context) error {
+	videoID, err := uuid.Parse(c.Param("video_id"))
+	if err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+
+	filter := &models.AnalyticsFilter{
+		VideoID: videoID,
+	}
+	if err := parseTimeRange(c, filter); err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+
+	breakdown, err := h.useCase.GetDeviceBreakdown(c.Request().Context(), videoID, filter)
+	if err != nil {
+		if errors.Is(err, analytics.ErrVideoAccessDenied) {
+			return httpErrors.NewForbiddenError(err)
+		}
+		h.logger.Errorf("Error getting video device breakdown: %v", err)
+		return httpErrors.NewInternalServerError(err)
+	}
+
+	return c.JSON(http.StatusOK, breakdown)
+}
+
 // StartWatchSession godoc
 // @Summary Start a watch session
 // @Description Start a new watch session for a video
@@ -182,6 +330,10 @@ func (h *AnalyticsHandlers) StartWatchSession(c echo.Context) error {
 		return httpErrors.NewBadRequestError(err)
 	}
 
+	if err := h.checkVideoAccess(c, session.VideoID); err != nil {
+		return err
+	}
+
 	// Set user ID if authenticated
 	user, err := utils.GetUserFromCtx(c.Request().Context())
 	if err == nil {
@@ -226,8 +378,13 @@ func (h *AnalyticsHandlers) EndWatchSession(c echo.Context) error {
 		return httpErrors.NewBadRequestError(echo.NewHTTPError(http.StatusBadRequest, "Session ID is required"))
 	}
 
+	if err := h.checkVideoAccess(c, session.VideoID); err != nil {
+		return err
+	}
+
 	err := h.useCase.EndWatchSession(
 		c.Request().Context(),
+		session.VideoID,
 		session.SessionID,
 		session.WatchDuration,
 		session.Completed,
@@ -240,6 +397,280 @@ func (h *AnalyticsHandlers) EndWatchSession(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]string{"message": "Session ended successfully"})
 }
 
+// RecordHeartbeat godoc
+// @Summary Record a playback heartbeat
+// @Description Record the current playback position of a watch session. Players should send one every 10 seconds while playing.
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Param input body models.VideoHeartbeat true "Heartbeat info"
+// @Success 201 {object} models.VideoHeartbeat
+// @Router /analytics/heartbeats [post]
+func (h *AnalyticsHandlers) RecordHeartbeat(c echo.Context) error {
+	heartbeat := &models.VideoHeartbeat{}
+	if err := c.Bind(heartbeat); err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+
+	if heartbeat.SessionID == "" {
+		return httpErrors.NewBadRequestError(echo.NewHTTPError(http.StatusBadRequest, "Session ID is required"))
+	}
+
+	if err := h.checkVideoAccess(c, heartbeat.VideoID); err != nil {
+		return err
+	}
+
+	// Set user ID if authenticated
+	user, err := utils.GetUserFromCtx(c.Request().Context())
+	if err == nil {
+		heartbeat.UserID = user.UserID
+	}
+	heartbeat.Timestamp = time.Now()
+
+	if err := h.useCase.RecordHeartbeat(c.Request().Context(), heartbeat); err != nil {
+		h.logger.Errorf("Error recording heartbeat: %v", err)
+		return httpErrors.NewInternalServerError(err)
+	}
+
+	return c.JSON(http.StatusCreated, heartbeat)
+}
+
+// GetVideoRetention godoc
+// @Summary Get video audience retention
+// @Description Get the number of sessions that watched each part of a video
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Param video_id path string true "Video ID"
+// @Param bucket query int false "Bucket size in seconds (default 1)"
+// @Param start_date query string false "Start date (YYYY-MM-DD)"
+// @Param end_date query string false "End date (YYYY-MM-DD)"
+// @Success 200 {object} models.RetentionCurve
+// @Router /analytics/videos/{video_id}/retention [get]
+func (h *AnalyticsHandlers) GetVideoRetention(c echo.Context) error {
+	videoID, err := uuid.Parse(c.Param("video_id"))
+	if err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+
+	filter := &models.AnalyticsFilter{
+		VideoID: videoID,
+	}
+	if err := parseTimeRange(c, filter); err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+
+	bucketSize := 1
+	if bucketStr := c.QueryParam("bucket"); bucketStr != "" {
+		bucketSize, err = strconv.Atoi(bucketStr)
+		if err != nil || bucketSize < 1 {
+			return httpErrors.NewBadRequestError(echo.NewHTTPError(http.StatusBadRequest, "Bucket must be a positive number of seconds"))
+		}
+	}
+
+	curve, err := h.useCase.GetRetentionCurve(c.Request().Context(), videoID, bucketSize, filter)
+	if err != nil {
+		if errors.Is(err, analytics.ErrVideoAccessDenied) {
+			return httpErrors.NewForbiddenError(err)
+		}
+		h.logger.Errorf("Error getting video retention: %v", err)
+		return httpErrors.NewInternalServerError(err)
+	}
+
+	return c.JSON(http.StatusOK, curve)
+}
+
+// RecordQoEBeacon godoc
+// @Summary Record a playback quality beacon
+// @Description Record the startup time, rebuffering, bitrate switches and rendition of a watch session since its previous beacon. Durations are in milliseconds.
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Param input body models.QoEBeacon true "QoE beacon"
+// @Success 201 {object} models.QoEBeacon
+// @Router /analytics/qoe [post]
+func (h *AnalyticsHandlers) RecordQoEBeacon(c echo.Context) error {
+	beacon := &models.QoEBeacon{}
+	if err := c.Bind(beacon); err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+
+	if beacon.SessionID == "" {
+		return httpErrors.NewBadRequestError(echo.NewHTTPError(http.StatusBadRequest, "Session ID is required"))
+	}
+
+	if err := h.checkVideoAccess(c, beacon.VideoID); err != nil {
+		return err
+	}
+
+	// Set user ID if authenticated
+	user, err := utils.GetUserFromCtx(c.Request().Context())
+	if err == nil {
+		beacon.UserID = user.UserID
+	}
+	beacon.Timestamp = time.Now()
+
+	if err := h.useCase.RecordQoEBeacon(c.Request().Context(), beacon); err != nil {
+		if errors.Is(err, analytics.ErrInvalidQoEBeacon) {
+			return httpErrors.NewBadRequestError(err)
+		}
+		h.logger.Errorf("Error recording qoe beacon: %v", err)
+		return httpErrors.NewInternalServerError(err)
+	}
+
+	return c.JSON(http.StatusCreated, beacon)
+}
+
+// GetVideoQoE godoc
+// @Summary Get video playback quality
+// @Description Get the startup time, rebuffering and bitrate of a video's playback overall, per playback version and per rendition
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Param video_id path string true "Video ID"
+// @Param start_date query string false "Start date (YYYY-MM-DD)"
+// @Param end_date query string false "End date (YYYY-MM-DD)"
+// @Success 200 {object} models.VideoQoE
+// @Router /analytics/videos/{video_id}/qoe [get]
+func (h *AnalyticsHandlers) GetVideoQoE(c echo.Context) error {
+	videoID, err := uuid.Parse(c.Param("video_id"))
+	if err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+
+	filter := &models.AnalyticsFilter{
+		VideoID: videoID,
+	}
+	if err := parseTimeRange(c, filter); err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+
+	qoe, err := h.useCase.GetVideoQoE(c.Request().Context(), videoID, filter)
+	if err != nil {
+		if errors.Is(err, analytics.ErrVideoAccessDenied) {
+			return httpErrors.NewForbiddenError(err)
+		}
+		h.logger.Errorf("Error getting video qoe: %v", err)
+		return httpErrors.NewInternalServerError(err)
+	}
+
+	return c.JSON(http.StatusOK, qoe)
+}
+
+// GetVideoDelivery godoc
+// @Summary Get video CDN delivery
+// @Description Get the bytes and requests the CDN delivered of a video overall, per rendition and per day, attributed from its access logs
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Param video_id path string true "Video ID"
+// @Param start_date query string false "Start date (YYYY-MM-DD)"
+// @Param end_date query string false "End date (YYYY-MM-DD)"
+// @Success 200 {object} models.VideoDelivery
+// @Router /analytics/videos/{video_id}/delivery [get]
+func (h *AnalyticsHandlers) GetVideoDelivery(c echo.Context) error {
+	videoID, err := uuid.Parse(c.Param("video_id"))
+	if err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+
+	filter := &models.AnalyticsFilter{
+		VideoID: videoID,
+	}
+	if err := parseTimeRange(c, filter); err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+
+	delivery, err := h.useCase.GetVideoDelivery(c.Request().Context(), videoID, filter)
+	if err != nil {
+		if errors.Is(err, analytics.ErrVideoAccessDenied) {
+			return httpErrors.NewForbiddenError(err)
+		}
+		h.logger.Errorf("Error getting video delivery: %v", err)
+		return httpErrors.NewInternalServerError(err)
+	}
+
+	return c.JSON(http.StatusOK, delivery)
+}
+
+// GetAccountDelivery godoc
+// @Summary Get account CDN delivery
+// @Description Get the bytes and requests the CDN delivered of the user's videos, with the videos that used the most bandwidth
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Param limit query int false "Number of videos (default 20)"
+// @Param start_date query string false "Start date (YYYY-MM-DD)"
+// @Param end_date query string false "End date (YYYY-MM-DD)"
+// @Success 200 {object} models.AccountDelivery
+// @Router /analytics/delivery [get]
+func (h *AnalyticsHandlers) GetAccountDelivery(c echo.Context) error {
+	user, err := utils.GetUserFromCtx(c.Request().Context())
+	if err != nil {
+		return httpErrors.NewUnauthorizedError(err)
+	}
+
+	filter := &models.AnalyticsFilter{
+		UserID: user.UserID,
+	}
+	if err := parseTimeRange(c, filter); err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		filter.Limit, err = strconv.Atoi(limitStr)
+		if err != nil || filter.Limit < 1 {
+			return httpErrors.NewBadRequestError(echo.NewHTTPError(http.StatusBadRequest, "Limit must be a positive number"))
+		}
+	}
+
+	delivery, err := h.useCase.GetAccountDelivery(c.Request().Context(), user.UserID, filter)
+	if err != nil {
+		h.logger.Errorf("Error getting account delivery: %v", err)
+		return httpErrors.NewInternalServerError(err)
+	}
+
+	return c.JSON(http.StatusOK, delivery)
+}
+
+// GetAccountHeatmap godoc
+// @Summary Get account viewer heatmap
+// @Description Get where viewers drop off relative to video length across all of the user's videos
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Param buckets query int false "Number of buckets each video is split into (default 20)"
+// @Param start_date query string false "Start date (YYYY-MM-DD)"
+// @Param end_date query string false "End date (YYYY-MM-DD)"
+// @Success 200 {object} models.AccountHeatmap
+// @Router /analytics/heatmap [get]
+func (h *AnalyticsHandlers) GetAccountHeatmap(c echo.Context) error {
+	user, err := utils.GetUserFromCtx(c.Request().Context())
+	if err != nil {
+		return httpErrors.NewUnauthorizedError(err)
+	}
+
+	filter := &models.AnalyticsFilter{}
+	if err := parseTimeRange(c, filter); err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+
+	buckets := 0
+	if bucketsStr := c.QueryParam("buckets"); bucketsStr != "" {
+		buckets, err = strconv.Atoi(bucketsStr)
+		if err != nil || buckets < 1 || buckets > 100 {
+			return httpErrors.NewBadRequestError(echo.NewHTTPError(http.StatusBadRequest, "Buckets must be between 1 and 100"))
+		}
+	}
+
+	heatmap, err := h.useCase.GetAccountHeatmap(c.Request().Context(), user.UserID, buckets, filter)
+	if err != nil {
+		h.logger.Errorf("Error getting account heatmap: %v", err)
+		return httpErrors.NewInternalServerError(err)
+	}
+
+	return c.JSON(http.StatusOK, heatmap)
+}
+
 // GetVideoPerformance godoc
 // @Summary Get video performance metrics
 // @Description Get performance metrics for a specific video
@@ -258,6 +689,9 @@ func (h *AnalyticsHandlers) GetVideoPerformance(c echo.Context) error {
 
 	performance, err := h.useCase.GetVideoPerformance(c.Request().Context(), videoID)
 	if err != nil {
+		if errors.Is(err, analytics.ErrVideoAccessDenied) {
+			return httpErrors.NewForbiddenError(err)
+		}
 		h.logger.Errorf("Error getting video performance: %v", err)
 		return httpErrors.NewInternalServerError(err)
 	}
@@ -333,6 +767,60 @@ func (h *AnalyticsHandlers) GetRecentVideos(c echo.Context) error {
 	return c.JSON(http.StatusOK, videos)
 }
 
+// checkVideoAccess rejects playback events for videos the viewer may not
+// watch. Unlisted videos are authorized by the share token in the token query param.
+func (h *AnalyticsHandlers) checkVideoAccess(c echo.Context, videoID uuid.UUID) error {
+	err := h.useCase.CheckVideoAccess(c.Request().Context(), videoID, c.QueryParam("token"))
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, analytics.ErrVideoAccessDenied) {
+		return httpErrors.NewForbiddenError(err)
+	}
+	h.logger.Errorf("Error checking video access: %v", err)
+	return httpErrors.NewInternalServerError(err)
+}
+
+// countryCodeRe matches ISO country codes. CDNs send XX for unknown
+// countries, and Cloudflare T1 for Tor.
+var countryCodeRe = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// referrerHost reduces a referring URL to its host without a www. prefix, so
+// views from every page of a site are counted together
+func referrerHost(referrer string) string {
+	u, err := url.Parse(referrer)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if len(host) > 255 {
+		return ""
+	}
+	return host
+}
+
+// parseTimeRange reads the start_date and end_date query params into filter
+func parseTimeRange(c echo.Context, filter *models.AnalyticsFilter) error {
+	if startDateStr := c.QueryParam("start_date"); startDateStr != "" {
+		startDate, err := time.Parse("2006-01-02", startDateStr)
+		if err != nil {
+			return err
+		}
+		filter.TimeRange.StartDate = startDate
+	}
+
+	if endDateStr := c.QueryParam("end_date"); endDateStr != "" {
+		endDate, err := time.Parse("2006-01-02", endDateStr)
+		if err != nil {
+			return err
+		}
+		// Set to end of day
+		filter.TimeRange.EndDate = endDate.Add(24*time.Hour - time.Second)
+	}
+
+	return nil
+}
+
 // Helper function to get user ID from context
 func getUserIDFromContext(c echo.Context) (uuid.UUID, error) {
 	user := c.Get("user")
@@ -0,0 +1,87 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/analytics"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/httpErrors"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// RecordLiveHeartbeat godoc
+// @Summary Record a live viewer heartbeat
+// @Description Count a session as watching a video right now. Unlike heartbeats it is not stored, so players that do not report retention can send it instead. Send one every 10 seconds while playing.
+// @Tags analytics
+// @Accept json
+// @Param input body models.LiveHeartbeat true "Live heartbeat"
+// @Success 204
+// @Router /analytics/live [post]
+func (h *AnalyticsHandlers) RecordLiveHeartbeat(c echo.Context) error {
+	heartbeat := &models.LiveHeartbeat{}
+	if err := c.Bind(heartbeat); err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+
+	if err := h.checkVideoAccess(c, heartbeat.VideoID); err != nil {
+		return err
+	}
+
+	if err := h.useCase.RecordLiveHeartbeat(c.Request().Context(), heartbeat); err != nil {
+		h.logger.Errorf("Error recording live heartbeat: %v", err)
+		return httpErrors.NewInternalServerError(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetVideoViewers godoc
+// @Summary Get concurrent viewers of a video
+// @Description Get the number of sessions watching a video right now
+// @Tags analytics
+// @Produce json
+// @Param video_id path string true "Video ID"
+// @Success 200 {object} models.VideoViewers
+// @Router /analytics/videos/{video_id}/viewers [get]
+func (h *AnalyticsHandlers) GetVideoViewers(c echo.Context) error {
+	videoID, err := uuid.Parse(c.Param("video_id"))
+	if err != nil {
+		return httpErrors.NewBadRequestError(err)
+	}
+
+	viewers, err := h.useCase.GetVideoViewers(c.Request().Context(), videoID)
+	if err != nil {
+		if errors.Is(err, analytics.ErrVideoAccessDenied) {
+			return httpErrors.NewForbiddenError(err)
+		}
+		h.logger.Errorf("Error getting video viewers: %v", err)
+		return httpErrors.NewInternalServerError(err)
+	}
+
+	return c.JSON(http.StatusOK, viewers)
+}
+
+// GetConcurrentViewers godoc
+// @Summary Get concurrent viewers of the account
+// @Description Get the number of sessions watching the user's videos right now, and the most watched videos
+// @Tags analytics
+// @Produce json
+// @Success 200 {object} models.ConcurrentViewers
+// @Router /analytics/viewers [get]
+func (h *AnalyticsHandlers) GetConcurrentViewers(c echo.Context) error {
+	user, err := utils.GetUserFromCtx(c.Request().Context())
+	if err != nil {
+		return httpErrors.NewUnauthorizedError(err)
+	}
+
+	viewers, err := h.useCase.GetConcurrentViewers(c.Request().Context(), user.UserID)
+	if err != nil {
+		h.logger.Errorf("Error getting concurrent viewers: %v", err)
+		return httpErrors.NewInternalServerError(err)
+	}
+
+	return c.JSON(http.StatusOK, viewers)
+}
@@ -3,25 +3,54 @@ package http
 import (
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/analytics"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/middleware"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
 	"github.com/labstack/echo/v4"
 )
 
 // MapAnalyticsRoutes maps the analytics routes to the Echo instance
 func MapAnalyticsRoutes(analyticsGroup *echo.Group, h analytics.Handlers, mw *middleware.MiddlewareManager) {
+	// Players of public and unlisted videos send beacons without an account;
+	// the handlers check the viewer may watch the video. Echo applies group
+	// middleware when a route is added, so these must come before Use.
+	analyticsGroup.POST("/views", h.RecordVideoView, mw.OptionalAuthSessionMiddleware, mw.AnalyticsRateLimit)
+	analyticsGroup.POST("/sessions/start", h.StartWatchSession, mw.OptionalAuthSessionMiddleware, mw.AnalyticsRateLimit)
+	analyticsGroup.POST("/sessions/end", h.EndWatchSession, mw.OptionalAuthSessionMiddleware, mw.AnalyticsRateLimit)
+	analyticsGroup.POST("/heartbeats", h.RecordHeartbeat, mw.OptionalAuthSessionMiddleware, mw.AnalyticsRateLimit)
+	analyticsGroup.POST("/live", h.RecordLiveHeartbeat, mw.OptionalAuthSessionMiddleware, mw.AnalyticsRateLimit)
+	analyticsGroup.POST("/qoe", h.RecordQoEBeacon, mw.OptionalAuthSessionMiddleware, mw.AnalyticsRateLimit)
+
 	// Analytics dashboard
 	analyticsGroup.Use(mw.AuthSessionMiddleware)
-	analyticsGroup.GET("/summary", h.GetAnalyticsSummary)
+	// Members of an account act on it with the permissions of their role
+	analyticsGroup.Use(mw.AccountMiddleware)
+	canRead := mw.Authorize(models.PermissionAnalyticsRead)
+	analyticsGroup.GET("/summary", h.GetAnalyticsSummary, canRead)
 	
 	// Video views
-	analyticsGroup.POST("/views", h.RecordVideoView)
-	analyticsGroup.GET("/videos/:video_id/views", h.GetVideoViews)
+	analyticsGroup.GET("/videos/:video_id/views", h.GetVideoViews, canRead)
+	analyticsGroup.GET("/videos/:video_id/geo", h.GetVideoGeo, canRead)
+	analyticsGroup.GET("/videos/:video_id/devices", h.GetVideoDevices, canRead)
+	analyticsGroup.GET("/videos/:video_id/timeseries", h.GetVideoTimeSeries, canRead)
 	
 	// Watch sessions
-	analyticsGroup.POST("/sessions/start", h.StartWatchSession)
-	analyticsGroup.POST("/sessions/end", h.EndWatchSession)
+	analyticsGroup.GET("/videos/:video_id/viewers", h.GetVideoViewers, canRead)
+	analyticsGroup.GET("/viewers", h.GetConcurrentViewers, canRead)
+	analyticsGroup.GET("/videos/:video_id/retention", h.GetVideoRetention, canRead)
+	analyticsGroup.GET("/heatmap", h.GetAccountHeatmap, canRead)
+
+	// Playback quality
+	analyticsGroup.GET("/videos/:video_id/qoe", h.GetVideoQoE, canRead)
+
+	// CDN delivery
+	analyticsGroup.GET("/videos/:video_id/delivery", h.GetVideoDelivery, canRead)
+	analyticsGroup.GET("/delivery", h.GetAccountDelivery, canRead)
 	
 	// Video performance
-	analyticsGroup.GET("/videos/:video_id/performance", h.GetVideoPerformance)
-	analyticsGroup.GET("/videos/top", h.GetTopPerformingVideos)
-	analyticsGroup.GET("/videos/recent", h.GetRecentVideos)
+	analyticsGroup.GET("/videos/:video_id/performance", h.GetVideoPerformance, canRead)
+	analyticsGroup.GET("/videos/top", h.GetTopPerformingVideos, canRead)
+	analyticsGroup.GET("/videos/recent", h.GetRecentVideos, canRead)
+
+	// Exports
+	analyticsGroup.GET("/export", h.ExportAnalytics, canRead)
+	analyticsGroup.GET("/exports/:export_id", h.GetAnalyticsExport, canRead)
 }
@@ -0,0 +1,24 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+var (
+	// ErrExportTooLarge is returned for exports whose time range is too long
+	// to stream in the response. They have to run in the background.
+	ErrExportTooLarge = errors.New("time range too large to export synchronously")
+	// ErrExportNotFound is returned for exports of other users as well
+	ErrExportNotFound = errors.New("export not found")
+)
+
+// ExportStore keeps background exports until they are downloaded
+type ExportStore interface {
+	PutObject(ctx context.Context, input models.UploadInput) (*s3.PutObjectOutput, error)
+	GetPresignedObjectURL(ctx context.Context, bucket, filename string, expires time.Duration) (string, error)
+}
@@ -2,6 +2,7 @@ package analytics
 
 import (
 	"context"
+	"time"
 
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
 	"github.com/google/uuid"
@@ -9,16 +10,41 @@ import (
 
 // Repository defines the interface for analytics data storage
 type Repository interface {
+	// Video access
+	GetVideoAccess(ctx context.Context, videoID uuid.UUID) (*models.VideoFile, error)
+
 	// Video views
 	CreateVideoView(ctx context.Context, view *models.VideoView) error
+	CreateVideoViews(ctx context.Context, views []*models.VideoView) error
 	GetVideoViews(ctx context.Context, videoID uuid.UUID, filter *models.AnalyticsFilter) ([]*models.VideoView, error)
 	GetTotalVideoViews(ctx context.Context, videoID uuid.UUID) (int64, error)
 	GetUniqueVideoViews(ctx context.Context, videoID uuid.UUID) (int64, error)
+	GetGeoBreakdown(ctx context.Context, videoID uuid.UUID, filter *models.AnalyticsFilter) ([]*models.GeoBreakdown, error)
+	GetDeviceBreakdown(ctx context.Context, videoID uuid.UUID, column string, filter *models.AnalyticsFilter) ([]*models.BreakdownItem, error)
+	GetViewTimeSeries(ctx context.Context, videoID uuid.UUID, metric models.TimeSeriesMetric, interval models.TimeSeriesInterval, from, to time.Time) ([]*models.TimeSeriesPoint, error)
 	
 	// Watch sessions
 	CreateWatchSession(ctx context.Context, session *models.VideoWatchSession) error
 	UpdateWatchSession(ctx context.Context, session *models.VideoWatchSession) error
 	GetWatchSessions(ctx context.Context, videoID uuid.UUID, filter *models.AnalyticsFilter) ([]*models.VideoWatchSession, error)
+
+	// Heartbeats and retention
+	CreateHeartbeat(ctx context.Context, heartbeat *models.VideoHeartbeat) error
+	GetRetention(ctx context.Context, videoID uuid.UUID, bucketSize int, filter *models.AnalyticsFilter) ([]*models.RetentionPoint, error)
+	GetHeartbeatSessionCount(ctx context.Context, videoID uuid.UUID, filter *models.AnalyticsFilter) (int64, error)
+	GetAccountHeatmap(ctx context.Context, userID uuid.UUID, buckets int, filter *models.AnalyticsFilter) ([]*models.HeatmapBucket, error)
+	GetAccountHeartbeatTotals(ctx context.Context, userID uuid.UUID, filter *models.AnalyticsFilter) (*models.AccountHeatmap, error)
+
+	// Playback quality
+	CreateQoEBeacon(ctx context.Context, beacon *models.QoEBeacon) error
+	GetQoEMetrics(ctx context.Context, videoID uuid.UUID, groupBy string, filter *models.AnalyticsFilter) ([]*models.QoEMetrics, error)
+
+	// CDN delivery
+	GetNewLogFiles(ctx context.Context, keys []string) ([]string, error)
+	GetVideoIDByOutputPrefix(ctx context.Context, userID uuid.UUID, prefix string) (uuid.UUID, error)
+	RecordDeliveryLog(ctx context.Context, key string, records int64, stats []*models.DeliveryStat) error
+	GetDeliveryTotals(ctx context.Context, videoID uuid.UUID, groupBy string, filter *models.AnalyticsFilter) ([]*models.DeliveryTotal, error)
+	GetAccountDeliveryTotals(ctx context.Context, userID uuid.UUID, groupBy string, filter *models.AnalyticsFilter) ([]*models.DeliveryTotal, error)
 	
 	// Engagement metrics
 	UpdateVideoEngagement(ctx context.Context, engagement *models.VideoEngagement) error
@@ -31,6 +57,22 @@ type Repository interface {
 	
 	// Summary metrics
 	GetAnalyticsSummary(ctx context.Context, userID uuid.UUID) (*models.AnalyticsSummary, error)
+	GetViewTotals(ctx context.Context, userID uuid.UUID, from, to time.Time) (*models.ViewTotals, error)
+	GetFolderBreakdown(ctx context.Context, userID uuid.UUID, from, to time.Time, limit int) ([]*models.SummaryBreakdown, error)
+	GetTagBreakdown(ctx context.Context, userID uuid.UUID, from, to time.Time, limit int) ([]*models.SummaryBreakdown, error)
+	GetReferrerBreakdown(ctx context.Context, userID uuid.UUID, from, to time.Time, limit int) ([]*models.SummaryBreakdown, error)
 	GetTotalVideos(ctx context.Context, userID uuid.UUID) (int64, error)
 	GetTotalWatchTime(ctx context.Context, userID uuid.UUID) (int64, error)
+
+	// Exports
+	StreamVideoViews(ctx context.Context, filter *models.AnalyticsFilter, fn func(*models.VideoView) error) error
+	StreamWatchSessions(ctx context.Context, filter *models.AnalyticsFilter, fn func(*models.VideoWatchSession) error) error
+	CreateExport(ctx context.Context, export *models.AnalyticsExport) (*models.AnalyticsExport, error)
+	UpdateExport(ctx context.Context, export *models.AnalyticsExport) error
+	GetExport(ctx context.Context, userID, exportID uuid.UUID) (*models.AnalyticsExport, error)
+
+	// Retention
+	CreateMonthlyPartitions(ctx context.Context, table string, from, to time.Time) error
+	DropPartitionsBefore(ctx context.Context, table string, cutoff time.Time) ([]string, error)
+	DeleteRowsBefore(ctx context.Context, table, column string, cutoff time.Time) (int64, error)
 }
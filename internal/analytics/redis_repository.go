@@ -0,0 +1,26 @@
+package analytics
+
+import (
+	"context"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
+)
+
+// LiveRepository tracks the sessions currently playing videos. A session
+// counts as a viewer until it has not been seen for the window the counts
+// are taken over.
+type LiveRepository interface {
+	MarkViewer(ctx context.Context, videoID, ownerID uuid.UUID, sessionID string, at time.Time) error
+	CountVideoViewers(ctx context.Context, videoID uuid.UUID, since time.Time) (int64, error)
+	CountAccountViewers(ctx context.Context, ownerID uuid.UUID, since time.Time) (int64, error)
+	CountAllViewers(ctx context.Context, since time.Time) (int64, error)
+	// ListVideoViewers returns the videos with viewers, most watched first.
+	// Only the videos of ownerID are listed unless it is uuid.Nil.
+	ListVideoViewers(ctx context.Context, ownerID uuid.UUID, since time.Time, limit int) ([]*models.VideoViewers, error)
+	// ListAccountViewers returns the accounts with viewers, most watched first
+	ListAccountViewers(ctx context.Context, since time.Time, limit int) ([]*models.AccountViewers, error)
+	GetVideoOwner(ctx context.Context, videoID uuid.UUID) (uuid.UUID, error)
+	SetVideoOwner(ctx context.Context, videoID, ownerID uuid.UUID, ttl time.Duration) error
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// GetNewLogFiles returns the access log files of keys that were not
+// ingested yet, in the order given
+func (r *PostgresRepository) GetNewLogFiles(ctx context.Context, keys []string) ([]string, error) {
+	var processed []string
+	if err := r.db.SelectContext(ctx, &processed, `SELECT key FROM cdn_log_files WHERE key = ANY($1)`, pq.Array(keys)); err != nil {
+		r.logger.Errorf("Error getting processed log files: %v", err)
+		return nil, err
+	}
+
+	done := make(map[string]bool, len(processed))
+	for _, key := range processed {
+		done[key] = true
+	}
+	var fresh []string
+	for _, key := range keys {
+		if !done[key] {
+			fresh = append(fresh, key)
+		}
+	}
+	return fresh, nil
+}
+
+// GetVideoIDByOutputPrefix finds the video of the user whose outputs are
+// stored under prefix, that is whose source key is prefix plus an extension
+func (r *PostgresRepository) GetVideoIDByOutputPrefix(ctx context.Context, userID uuid.UUID, prefix string) (uuid.UUID, error) {
+	query := `
+		SELECT video_id
+		FROM video_files
+		WHERE user_id = $1 AND regexp_replace(s3_key, '\.[^./]*$', '') = $2
+		ORDER BY uploaded_at DESC
+		LIMIT 1
+	`
+
+	var videoID uuid.UUID
+	if err := r.db.GetContext(ctx, &videoID, query, userID, prefix); err != nil {
+		return uuid.Nil, err
+	}
+	return videoID, nil
+}
+
+// RecordDeliveryLog adds the stats attributed from an access log file and
+// marks it ingested in one transaction. A file ingested before, e.g. by
+// another server, is left alone.
+func (r *PostgresRepository) RecordDeliveryLog(ctx context.Context, key string, records int64, stats []*models.DeliveryStat) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO cdn_log_files (key, records) VALUES ($1, $2)
+		ON CONFLICT (key) DO NOTHING
+	`, key, records)
+	if err != nil {
+		r.logger.Errorf("Error marking log file %s: %v", key, err)
+		return err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if inserted == 0 {
+		return nil
+	}
+
+	for _, stat := range stats {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO delivery_stats (video_id, rendition, day, bytes, requests)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (video_id, rendition, day) DO UPDATE
+			SET bytes = delivery_stats.bytes + EXCLUDED.bytes,
+				requests = delivery_stats.requests + EXCLUDED.requests
+		`, stat.VideoID, stat.Rendition, stat.Day, stat.Bytes, stat.Requests); err != nil {
+			r.logger.Errorf("Error recording delivery stats of %s: %v", key, err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// deliveryGroupKeys maps what delivery stats may be grouped by to the
+// expression of their key and the order of the groups. The empty group sums
+// all stats.
+var deliveryGroupKeys = map[string][2]string{
+	"":          {"''", ""},
+	"rendition": {"d.rendition", " ORDER BY bytes DESC"},
+	"day":       {"to_char(d.day, 'YYYY-MM-DD')", " ORDER BY key"},
+	"video_id":  {"d.video_id::text", " ORDER BY bytes DESC"},
+}
+
+// GetDeliveryTotals sums the delivery stats of a video, per rendition or day
+// or overall when groupBy is empty
+func (r *PostgresRepository) GetDeliveryTotals(ctx context.Context, videoID uuid.UUID, groupBy string, filter *models.AnalyticsFilter) ([]*models.DeliveryTotal, error) {
+	return r.getDeliveryTotals(ctx, "d.video_id = $1", videoID, groupBy, filter)
+}
+
+// GetAccountDeliveryTotals sums the delivery stats of a user's videos, per
+// video_id or overall when groupBy is empty
+func (r *PostgresRepository) GetAccountDeliveryTotals(ctx context.Context, userID uuid.UUID, groupBy string, filter *models.AnalyticsFilter) ([]*models.DeliveryTotal, error) {
+	return r.getDeliveryTotals(ctx, "d.video_id IN (SELECT video_id FROM video_files WHERE user_id = $1)", userID, groupBy, filter)
+}
+
+func (r *PostgresRepository) getDeliveryTotals(ctx context.Context, condition string, id uuid.UUID, groupBy string, filter *models.AnalyticsFilter) ([]*models.DeliveryTotal, error) {
+	group, ok := deliveryGroupKeys[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("invalid delivery group: %s", groupBy)
+	}
+
+	query := `
+		SELECT ` + group[0] + ` AS key, COALESCE(SUM(d.bytes), 0) AS bytes, COALESCE(SUM(d.requests), 0) AS requests
+		FROM delivery_stats d
+		WHERE ` + condition
+
+	args := []interface{}{id}
+	argCount := 2
+
+	if !filter.TimeRange.StartDate.IsZero() {
+		query += " AND d.day >= $" + fmt.Sprintf("%d", argCount) + "::date"
+		args = append(args, filter.TimeRange.StartDate)
+		argCount++
+	}
+
+	if !filter.TimeRange.EndDate.IsZero() {
+		query += " AND d.day <= $" + fmt.Sprintf("%d", argCount) + "::date"
+		args = append(args, filter.TimeRange.EndDate)
+		argCount++
+	}
+
+	if groupBy != "" {
+		query += " GROUP BY 1" + group[1]
+	}
+
+	if filter.Limit > 0 {
+		query += " LIMIT $" + fmt.Sprintf("%d", argCount)
+		args = append(args, filter.Limit)
+	}
+
+	var totals []*models.DeliveryTotal
+	if err := r.db.SelectContext(ctx, &totals, query, args...); err != nil {
+		r.logger.Errorf("Error getting delivery totals: %v", err)
+		return nil, err
+	}
+
+	return totals, nil
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// exportFilter builds the conditions shared by the export queries: the
+// videos of filter.UserID, optionally one of them, and the time range on
+// column.
+func exportFilter(column string, filter *models.AnalyticsFilter) (string, []interface{}) {
+	conditions := " WHERE v.user_id = $1 AND t." + column + " >= $2 AND t." + column + " <= $3"
+	args := []interface{}{filter.UserID, filter.TimeRange.StartDate, filter.TimeRange.EndDate}
+	if filter.VideoID != uuid.Nil {
+		conditions += fmt.Sprintf(" AND t.video_id = $%d", len(args)+1)
+		args = append(args, filter.VideoID)
+	}
+	return conditions, args
+}
+
+// StreamVideoViews calls fn with every view of the user's videos in the time
+// range, oldest first. Rows are read one at a time, so exports of any size
+// use constant memory. The IP and user agent are left out: exports leave the
+// platform and only the details derived from them are needed.
+func (r *PostgresRepository) StreamVideoViews(ctx context.Context, filter *models.AnalyticsFilter, fn func(*models.VideoView) error) error {
+	conditions, args := exportFilter("timestamp", filter)
+	query := `
+		SELECT t.id, t.video_id, t.user_id, t.timestamp, COALESCE(t.duration, 0) AS duration,
+		       t.country, t.region, t.device_type, t.browser, t.os
+		FROM video_views t
+		JOIN video_files v ON v.video_id = t.video_id` + conditions + `
+		ORDER BY t.timestamp, t.id
+	`
+
+	rows, err := r.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		r.logger.Errorf("Error streaming video views: %v", err)
+		return err
+	}
+	return scanEach(rows, fn)
+}
+
+// StreamWatchSessions calls fn with every watch session of the user's videos
+// started in the time range, oldest first.
+func (r *PostgresRepository) StreamWatchSessions(ctx context.Context, filter *models.AnalyticsFilter, fn func(*models.VideoWatchSession) error) error {
+	conditions, args := exportFilter("start_time", filter)
+	query := `
+		SELECT t.id, t.video_id, t.user_id, t.session_id, t.start_time, t.end_time,
+		       t.watch_duration, COALESCE(t.completed, FALSE) AS completed
+		FROM video_watch_sessions t
+		JOIN video_files v ON v.video_id = t.video_id` + conditions + `
+		ORDER BY t.start_time, t.id
+	`
+
+	rows, err := r.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		r.logger.Errorf("Error streaming watch sessions: %v", err)
+		return err
+	}
+	return scanEach(rows, fn)
+}
+
+// scanEach scans every row into a new T and hands it to fn, stopping at the
+// first error.
+func scanEach[T any](rows *sqlx.Rows, fn func(*T) error) error {
+	defer rows.Close()
+	for rows.Next() {
+		dest := new(T)
+		if err := rows.StructScan(dest); err != nil {
+			return err
+		}
+		if err := fn(dest); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// CreateExport stores a new export request
+func (r *PostgresRepository) CreateExport(ctx context.Context, export *models.AnalyticsExport) (*models.AnalyticsExport, error) {
+	query := `
+		INSERT INTO analytics_exports (user_id, dataset, format, video_id, start_date, end_date, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING *
+	`
+
+	created := &models.AnalyticsExport{}
+	err := r.db.GetContext(ctx, created, query,
+		export.UserID, export.Dataset, export.Format, export.VideoID,
+		export.StartDate, export.EndDate, export.Status,
+	)
+	if err != nil {
+		r.logger.Errorf("Error creating analytics export: %v", err)
+		return nil, err
+	}
+	return created, nil
+}
+
+// UpdateExport records the progress of an export
+func (r *PostgresRepository) UpdateExport(ctx context.Context, export *models.AnalyticsExport) error {
+	query := `
+		UPDATE analytics_exports
+		SET status = $2, object_key = $3, rows = $4, error = $5, completed_at = $6
+		WHERE export_id = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		export.ExportID, export.Status, export.ObjectKey, export.Rows, export.Error, export.CompletedAt,
+	)
+	if err != nil {
+		r.logger.Errorf("Error updating analytics export: %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetExport gets an export of the user
+func (r *PostgresRepository) GetExport(ctx context.Context, userID, exportID uuid.UUID) (*models.AnalyticsExport, error) {
+	query := `
+		SELECT *
+		FROM analytics_exports
+		WHERE export_id = $1 AND user_id = $2
+	`
+
+	export := &models.AnalyticsExport{}
+	if err := r.db.GetContext(ctx, export, query, exportID, userID); err != nil {
+		r.logger.Errorf("Error getting analytics export: %v", err)
+		return nil, err
+	}
+	return export, nil
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/analytics"
@@ -10,6 +11,7 @@ import (
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // PostgresRepository implements the analytics.Repository interface
@@ -26,11 +28,29 @@ func NewPostgresRepository(db *sqlx.DB, logger logger.Logger) analytics.Reposito
 	}
 }
 
+// GetVideoAccess gets the owner and visibility of a video
+func (r *PostgresRepository) GetVideoAccess(ctx context.Context, videoID uuid.UUID) (*models.VideoFile, error) {
+	query := `
+		SELECT video_id, user_id, visibility, share_token
+		FROM video_files
+		WHERE video_id = $1
+	`
+
+	video := &models.VideoFile{}
+	err := r.db.GetContext(ctx, video, query, videoID)
+	if err != nil {
+		r.logger.Errorf("Error getting video access: %v", err)
+		return nil, err
+	}
+
+	return video, nil
+}
+
 // CreateVideoView records a new video view
 func (r *PostgresRepository) CreateVideoView(ctx context.Context, view *models.VideoView) error {
 	query := `
-		INSERT INTO video_views (video_id, user_id, ip, user_agent, timestamp, duration)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO video_views (video_id, user_id, ip, user_agent, timestamp, duration, country, region, device_type, browser, os, referrer)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 		RETURNING id
 	`
 
@@ -43,6 +63,12 @@ func (r *PostgresRepository) CreateVideoView(ctx context.Context, view *models.V
 		view.UserAgent,
 		view.Timestamp,
 		view.Duration,
+		view.Country,
+		view.Region,
+		view.DeviceType,
+		view.Browser,
+		view.OS,
+		view.Referrer,
 	).Scan(&view.ID)
 
 	if err != nil {
@@ -53,10 +79,37 @@ func (r *PostgresRepository) CreateVideoView(ctx context.Context, view *models.V
 	return nil
 }
 
+// CreateVideoViews records a batch of video views with a single COPY
+func (r *PostgresRepository) CreateVideoViews(ctx context.Context, views []*models.VideoView) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("video_views", "video_id", "user_id", "ip", "user_agent",
+		"timestamp", "duration", "country", "region", "device_type", "browser", "os", "referrer"))
+	if err != nil {
+		return fmt.Errorf("failed to prepare copy: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, view := range views {
+		if _, err := stmt.ExecContext(ctx, view.VideoID, view.UserID, view.IP, view.UserAgent, view.Timestamp,
+			view.Duration, view.Country, view.Region, view.DeviceType, view.Browser, view.OS, view.Referrer); err != nil {
+			return fmt.Errorf("failed to copy video view: %w", err)
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return fmt.Errorf("failed to copy video views: %w", err)
+	}
+	return tx.Commit()
+}
+
 // GetVideoViews retrieves video views based on filter
 func (r *PostgresRepository) GetVideoViews(ctx context.Context, videoID uuid.UUID, filter *models.AnalyticsFilter) ([]*models.VideoView, error) {
 	query := `
-		SELECT id, video_id, user_id, ip, user_agent, timestamp, duration
+		SELECT id, video_id, user_id, ip, user_agent, timestamp, duration, country, region, device_type, browser, os, referrer
 		FROM video_views
 		WHERE video_id = $1
 	`
@@ -135,6 +188,139 @@ func (r *PostgresRepository) GetUniqueVideoViews(ctx context.Context, videoID uu
 	return count, nil
 }
 
+// GetGeoBreakdown aggregates views of a video by country and region
+func (r *PostgresRepository) GetGeoBreakdown(ctx context.Context, videoID uuid.UUID, filter *models.AnalyticsFilter) ([]*models.GeoBreakdown, error) {
+	query := `
+		SELECT country, region, COUNT(*) AS views,
+			COUNT(DISTINCT COALESCE(user_id::text, ip)) AS unique_viewers,
+			COALESCE(SUM(duration), 0) AS watch_time
+		FROM video_views
+		WHERE video_id = $1
+	`
+
+	args := []interface{}{videoID}
+	argCount := 2
+
+	if !filter.TimeRange.StartDate.IsZero() {
+		query += " AND timestamp >= $" + fmt.Sprintf("%d", argCount)
+		args = append(args, filter.TimeRange.StartDate)
+		argCount++
+	}
+
+	if !filter.TimeRange.EndDate.IsZero() {
+		query += " AND timestamp <= $" + fmt.Sprintf("%d", argCount)
+		args = append(args, filter.TimeRange.EndDate)
+		argCount++
+	}
+
+	query += " GROUP BY country, region ORDER BY views DESC"
+
+	if filter.Limit > 0 {
+		query += " LIMIT $" + fmt.Sprintf("%d", argCount)
+		args = append(args, filter.Limit)
+	}
+
+	var breakdown []*models.GeoBreakdown
+	err := r.db.SelectContext(ctx, &breakdown, query, args...)
+	if err != nil {
+		r.logger.Errorf("Error getting geo breakdown: %v", err)
+		return nil, err
+	}
+
+	return breakdown, nil
+}
+
+// deviceBreakdownColumns lists the video_views columns a device breakdown may group by
+var deviceBreakdownColumns = map[string]bool{
+	"device_type": true,
+	"browser":     true,
+	"os":          true,
+}
+
+// GetDeviceBreakdown aggregates views of a video by device_type, browser or os
+func (r *PostgresRepository) GetDeviceBreakdown(ctx context.Context, videoID uuid.UUID, column string, filter *models.AnalyticsFilter) ([]*models.BreakdownItem, error) {
+	if !deviceBreakdownColumns[column] {
+		return nil, fmt.Errorf("invalid breakdown column: %s", column)
+	}
+
+	query := `
+		SELECT ` + column + ` AS name, COUNT(*) AS views, COALESCE(SUM(duration), 0) AS watch_time
+		FROM video_views
+		WHERE video_id = $1
+	`
+
+	args := []interface{}{videoID}
+	argCount := 2
+
+	if !filter.TimeRange.StartDate.IsZero() {
+		query += " AND timestamp >= $" + fmt.Sprintf("%d", argCount)
+		args = append(args, filter.TimeRange.StartDate)
+		argCount++
+	}
+
+	if !filter.TimeRange.EndDate.IsZero() {
+		query += " AND timestamp <= $" + fmt.Sprintf("%d", argCount)
+		args = append(args, filter.TimeRange.EndDate)
+		argCount++
+	}
+
+	query += " GROUP BY " + column + " ORDER BY views DESC"
+
+	if filter.Limit > 0 {
+		query += " LIMIT $" + fmt.Sprintf("%d", argCount)
+		args = append(args, filter.Limit)
+	}
+
+	var breakdown []*models.BreakdownItem
+	err := r.db.SelectContext(ctx, &breakdown, query, args...)
+	if err != nil {
+		r.logger.Errorf("Error getting %s breakdown: %v", column, err)
+		return nil, err
+	}
+
+	return breakdown, nil
+}
+
+// timeSeriesValues is the aggregate of video_views behind each time series
+// metric
+var timeSeriesValues = map[models.TimeSeriesMetric]string{
+	models.MetricViews:         "COUNT(v.id)",
+	models.MetricUniqueViewers: "COUNT(DISTINCT COALESCE(v.user_id::text, v.ip))",
+	models.MetricWatchTime:     "COALESCE(SUM(v.duration), 0)",
+}
+
+// GetViewTimeSeries aggregates a metric of the views of a video between from
+// and to into UTC buckets of interval, with a zero point for empty buckets
+func (r *PostgresRepository) GetViewTimeSeries(ctx context.Context, videoID uuid.UUID, metric models.TimeSeriesMetric, interval models.TimeSeriesInterval, from, to time.Time) ([]*models.TimeSeriesPoint, error) {
+	value, ok := timeSeriesValues[metric]
+	if !ok {
+		return nil, fmt.Errorf("invalid time series metric: %s", metric)
+	}
+
+	query := `
+		SELECT b.bucket AT TIME ZONE 'UTC' AS bucket, ` + value + ` AS value
+		FROM generate_series(
+			date_trunc($2, $3::timestamptz AT TIME ZONE 'UTC'),
+			date_trunc($2, $4::timestamptz AT TIME ZONE 'UTC'),
+			('1 ' || $2)::interval
+		) AS b(bucket)
+		LEFT JOIN video_views v
+			ON v.video_id = $1
+			AND v.timestamp >= $3 AND v.timestamp <= $4
+			AND date_trunc($2, v.timestamp AT TIME ZONE 'UTC') = b.bucket
+		GROUP BY b.bucket
+		ORDER BY b.bucket
+	`
+
+	points := []*models.TimeSeriesPoint{}
+	if err := r.db.SelectContext(ctx, &points, query, videoID, string(interval), from, to); err != nil {
+		r.logger.Errorf("Error getting %s time series: %v", metric, err)
+		return nil, err
+	}
+
+	return points, nil
+}
+
 // CreateWatchSession creates a new watch session
 func (r *PostgresRepository) CreateWatchSession(ctx context.Context, session *models.VideoWatchSession) error {
 	query := `
@@ -163,12 +349,12 @@ func (r *PostgresRepository) CreateWatchSession(ctx context.Context, session *mo
 	return nil
 }
 
-// UpdateWatchSession updates an existing watch session
+// UpdateWatchSession updates an existing watch session of session.VideoID
 func (r *PostgresRepository) UpdateWatchSession(ctx context.Context, session *models.VideoWatchSession) error {
 	query := `
 		UPDATE video_watch_sessions
 		SET end_time = $1, watch_duration = $2, completed = $3
-		WHERE session_id = $4
+		WHERE session_id = $4 AND video_id = $5
 	`
 
 	_, err := r.db.ExecContext(
@@ -178,6 +364,7 @@ func (r *PostgresRepository) UpdateWatchSession(ctx context.Context, session *mo
 		session.WatchDuration,
 		session.Completed,
 		session.SessionID,
+		session.VideoID,
 	)
 
 	if err != nil {
@@ -234,6 +421,269 @@ func (r *PostgresRepository) GetWatchSessions(ctx context.Context, videoID uuid.
 	return sessions, nil
 }
 
+// CreateHeartbeat records a playback position update
+func (r *PostgresRepository) CreateHeartbeat(ctx context.Context, heartbeat *models.VideoHeartbeat) error {
+	query := `
+		INSERT INTO video_heartbeats (video_id, user_id, session_id, position, timestamp)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	userID := uuid.NullUUID{UUID: heartbeat.UserID, Valid: heartbeat.UserID != uuid.Nil}
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		heartbeat.VideoID,
+		userID,
+		heartbeat.SessionID,
+		heartbeat.Position,
+		heartbeat.Timestamp,
+	).Scan(&heartbeat.ID)
+
+	if err != nil {
+		r.logger.Errorf("Error creating heartbeat: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetRetention counts the sessions that reported a position in each bucket of a video
+func (r *PostgresRepository) GetRetention(ctx context.Context, videoID uuid.UUID, bucketSize int, filter *models.AnalyticsFilter) ([]*models.RetentionPoint, error) {
+	query := `
+		SELECT (position / $2) * $2 AS position, COUNT(DISTINCT session_id) AS viewers
+		FROM video_heartbeats
+		WHERE video_id = $1
+	`
+
+	args := []interface{}{videoID, bucketSize}
+	argCount := 3
+
+	if !filter.TimeRange.StartDate.IsZero() {
+		query += " AND timestamp >= $" + fmt.Sprintf("%d", argCount)
+		args = append(args, filter.TimeRange.StartDate)
+		argCount++
+	}
+
+	if !filter.TimeRange.EndDate.IsZero() {
+		query += " AND timestamp <= $" + fmt.Sprintf("%d", argCount)
+		args = append(args, filter.TimeRange.EndDate)
+	}
+
+	query += " GROUP BY 1 ORDER BY 1"
+
+	var points []*models.RetentionPoint
+	err := r.db.SelectContext(ctx, &points, query, args...)
+	if err != nil {
+		r.logger.Errorf("Error getting retention: %v", err)
+		return nil, err
+	}
+
+	return points, nil
+}
+
+// GetHeartbeatSessionCount gets the number of sessions that sent heartbeats for a video
+func (r *PostgresRepository) GetHeartbeatSessionCount(ctx context.Context, videoID uuid.UUID, filter *models.AnalyticsFilter) (int64, error) {
+	query := `
+		SELECT COUNT(DISTINCT session_id)
+		FROM video_heartbeats
+		WHERE video_id = $1
+	`
+
+	args := []interface{}{videoID}
+	argCount := 2
+
+	if !filter.TimeRange.StartDate.IsZero() {
+		query += " AND timestamp >= $" + fmt.Sprintf("%d", argCount)
+		args = append(args, filter.TimeRange.StartDate)
+		argCount++
+	}
+
+	if !filter.TimeRange.EndDate.IsZero() {
+		query += " AND timestamp <= $" + fmt.Sprintf("%d", argCount)
+		args = append(args, filter.TimeRange.EndDate)
+	}
+
+	var count int64
+	err := r.db.GetContext(ctx, &count, query, args...)
+	if err != nil {
+		r.logger.Errorf("Error getting heartbeat session count: %v", err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetAccountHeatmap counts the sessions that reached each of buckets equal
+// slices of their video's length, across all videos of a user
+func (r *PostgresRepository) GetAccountHeatmap(ctx context.Context, userID uuid.UUID, buckets int, filter *models.AnalyticsFilter) ([]*models.HeatmapBucket, error) {
+	query := `
+		SELECT LEAST(FLOOR(h.position / COALESCE(p.duration, v.duration) * $2), $2 - 1)::int AS bucket,
+			COUNT(DISTINCT (h.video_id, h.session_id)) AS viewers
+		FROM video_heartbeats h
+		JOIN video_files v ON v.video_id = h.video_id
+		LEFT JOIN playback_info p ON p.video_id = h.video_id
+		WHERE v.user_id = $1 AND COALESCE(p.duration, v.duration) > 0
+	`
+
+	args := []interface{}{userID, buckets}
+	argCount := 3
+
+	if !filter.TimeRange.StartDate.IsZero() {
+		query += " AND h.timestamp >= $" + fmt.Sprintf("%d", argCount)
+		args = append(args, filter.TimeRange.StartDate)
+		argCount++
+	}
+
+	if !filter.TimeRange.EndDate.IsZero() {
+		query += " AND h.timestamp <= $" + fmt.Sprintf("%d", argCount)
+		args = append(args, filter.TimeRange.EndDate)
+	}
+
+	query += " GROUP BY 1 ORDER BY 1"
+
+	var points []*models.HeatmapBucket
+	err := r.db.SelectContext(ctx, &points, query, args...)
+	if err != nil {
+		r.logger.Errorf("Error getting account heatmap: %v", err)
+		return nil, err
+	}
+
+	return points, nil
+}
+
+// GetAccountHeartbeatTotals counts the videos of a user that have heartbeats
+// and the sessions that sent them
+func (r *PostgresRepository) GetAccountHeartbeatTotals(ctx context.Context, userID uuid.UUID, filter *models.AnalyticsFilter) (*models.AccountHeatmap, error) {
+	query := `
+		SELECT COUNT(DISTINCT h.video_id) AS videos,
+			COUNT(DISTINCT (h.video_id, h.session_id)) AS total_sessions
+		FROM video_heartbeats h
+		JOIN video_files v ON v.video_id = h.video_id
+		LEFT JOIN playback_info p ON p.video_id = h.video_id
+		WHERE v.user_id = $1 AND COALESCE(p.duration, v.duration) > 0
+	`
+
+	args := []interface{}{userID}
+	argCount := 2
+
+	if !filter.TimeRange.StartDate.IsZero() {
+		query += " AND h.timestamp >= $" + fmt.Sprintf("%d", argCount)
+		args = append(args, filter.TimeRange.StartDate)
+		argCount++
+	}
+
+	if !filter.TimeRange.EndDate.IsZero() {
+		query += " AND h.timestamp <= $" + fmt.Sprintf("%d", argCount)
+		args = append(args, filter.TimeRange.EndDate)
+	}
+
+	totals := &models.AccountHeatmap{}
+	err := r.db.GetContext(ctx, totals, query, args...)
+	if err != nil {
+		r.logger.Errorf("Error getting account heartbeat totals: %v", err)
+		return nil, err
+	}
+
+	return totals, nil
+}
+
+// CreateQoEBeacon records a playback quality report
+func (r *PostgresRepository) CreateQoEBeacon(ctx context.Context, beacon *models.QoEBeacon) error {
+	query := `
+		INSERT INTO video_qoe_beacons (video_id, user_id, session_id, playback_version, rendition, bitrate,
+			startup_time, rebuffer_count, rebuffer_duration, bitrate_switches, play_time, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id
+	`
+
+	userID := uuid.NullUUID{UUID: beacon.UserID, Valid: beacon.UserID != uuid.Nil}
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		beacon.VideoID,
+		userID,
+		beacon.SessionID,
+		beacon.PlaybackVersion,
+		beacon.Rendition,
+		beacon.Bitrate,
+		beacon.StartupTime,
+		beacon.RebufferCount,
+		beacon.RebufferDuration,
+		beacon.BitrateSwitches,
+		beacon.PlayTime,
+		beacon.Timestamp,
+	).Scan(&beacon.ID)
+
+	if err != nil {
+		r.logger.Errorf("Error creating qoe beacon: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// qoeGroupColumns maps what QoE metrics may be grouped by to the expression
+// of their key. The empty group aggregates all beacons of a video.
+var qoeGroupColumns = map[string]string{
+	"":                 "''",
+	"playback_version": "playback_version::text",
+	"rendition":        "rendition",
+}
+
+// GetQoEMetrics aggregates the QoE beacons of a video, per playback_version or
+// rendition or overall when groupBy is empty. Groups with the most play time
+// come first.
+func (r *PostgresRepository) GetQoEMetrics(ctx context.Context, videoID uuid.UUID, groupBy string, filter *models.AnalyticsFilter) ([]*models.QoEMetrics, error) {
+	key, ok := qoeGroupColumns[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("invalid qoe group: %s", groupBy)
+	}
+
+	query := `
+		SELECT ` + key + ` AS key,
+			COUNT(DISTINCT session_id) AS sessions,
+			COUNT(DISTINCT session_id) FILTER (WHERE rebuffer_count > 0) AS rebuffered_sessions,
+			COALESCE(SUM(play_time), 0) AS play_time,
+			COALESCE(AVG(startup_time), 0) AS startup_time_avg,
+			COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY startup_time), 0) AS startup_time_p50,
+			COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY startup_time), 0) AS startup_time_p95,
+			COALESCE(SUM(rebuffer_count), 0) AS rebuffer_count,
+			COALESCE(SUM(rebuffer_duration), 0) AS rebuffer_duration,
+			COALESCE(SUM(bitrate_switches), 0) AS bitrate_switches,
+			COALESCE(SUM(bitrate::bigint * play_time)::float / NULLIF(SUM(play_time), 0), 0) AS average_bitrate
+		FROM video_qoe_beacons
+		WHERE video_id = $1
+	`
+
+	args := []interface{}{videoID}
+	argCount := 2
+
+	if !filter.TimeRange.StartDate.IsZero() {
+		query += " AND timestamp >= $" + fmt.Sprintf("%d", argCount)
+		args = append(args, filter.TimeRange.StartDate)
+		argCount++
+	}
+
+	if !filter.TimeRange.EndDate.IsZero() {
+		query += " AND timestamp <= $" + fmt.Sprintf("%d", argCount)
+		args = append(args, filter.TimeRange.EndDate)
+	}
+
+	if groupBy != "" {
+		query += " GROUP BY " + groupBy + " ORDER BY play_time DESC"
+	}
+
+	var metrics []*models.QoEMetrics
+	err := r.db.SelectContext(ctx, &metrics, query, args...)
+	if err != nil {
+		r.logger.Errorf("Error getting qoe metrics: %v", err)
+		return nil, err
+	}
+
+	return metrics, nil
+}
+
 // UpdateVideoEngagement updates or creates video engagement metrics
 func (r *PostgresRepository) UpdateVideoEngagement(ctx context.Context, engagement *models.VideoEngagement) error {
 	_, err := r.db.ExecContext(
@@ -366,6 +816,49 @@ func (r *PostgresRepository) GetAnalyticsSummary(ctx context.Context, userID uui
 	return summary, nil
 }
 
+// GetViewTotals counts the views of a user's videos from from until to
+func (r *PostgresRepository) GetViewTotals(ctx context.Context, userID uuid.UUID, from, to time.Time) (*models.ViewTotals, error) {
+	totals := &models.ViewTotals{}
+	if err := r.db.GetContext(ctx, totals, getViewTotalsQuery, userID, from, to); err != nil {
+		r.logger.Errorf("Error getting view totals: %v", err)
+		return nil, err
+	}
+	return totals, nil
+}
+
+// GetFolderBreakdown sums the views of a user's videos per folder, busiest
+// first
+func (r *PostgresRepository) GetFolderBreakdown(ctx context.Context, userID uuid.UUID, from, to time.Time, limit int) ([]*models.SummaryBreakdown, error) {
+	breakdown := []*models.SummaryBreakdown{}
+	if err := r.db.SelectContext(ctx, &breakdown, getFolderBreakdownQuery, userID, from, to, limit); err != nil {
+		r.logger.Errorf("Error getting folder breakdown: %v", err)
+		return nil, err
+	}
+	return breakdown, nil
+}
+
+// GetTagBreakdown sums the views of a user's videos per tag, busiest first.
+// A video with several tags counts toward each of them.
+func (r *PostgresRepository) GetTagBreakdown(ctx context.Context, userID uuid.UUID, from, to time.Time, limit int) ([]*models.SummaryBreakdown, error) {
+	breakdown := []*models.SummaryBreakdown{}
+	if err := r.db.SelectContext(ctx, &breakdown, getTagBreakdownQuery, userID, from, to, limit); err != nil {
+		r.logger.Errorf("Error getting tag breakdown: %v", err)
+		return nil, err
+	}
+	return breakdown, nil
+}
+
+// GetReferrerBreakdown sums the views of a user's videos per referrer host,
+// busiest first. Direct views are left out.
+func (r *PostgresRepository) GetReferrerBreakdown(ctx context.Context, userID uuid.UUID, from, to time.Time, limit int) ([]*models.SummaryBreakdown, error) {
+	breakdown := []*models.SummaryBreakdown{}
+	if err := r.db.SelectContext(ctx, &breakdown, getReferrerBreakdownQuery, userID, from, to, limit); err != nil {
+		r.logger.Errorf("Error getting referrer breakdown: %v", err)
+		return nil, err
+	}
+	return breakdown, nil
+}
+
 // GetTotalVideos gets the total number of videos for a user
 func (r *PostgresRepository) GetTotalVideos(ctx context.Context, userID uuid.UUID) (int64, error) {
 	var count int64
@@ -389,3 +882,57 @@ func (r *PostgresRepository) GetTotalWatchTime(ctx context.Context, userID uuid.
 
 	return totalWatchTime, nil
 }
+
+// CreateMonthlyPartitions creates the monthly partitions of table covering
+// from to to that do not exist yet
+func (r *PostgresRepository) CreateMonthlyPartitions(ctx context.Context, table string, from, to time.Time) error {
+	_, err := r.db.ExecContext(ctx, createMonthlyPartitionsQuery, table, from, to)
+	if err != nil {
+		r.logger.Errorf("Error creating partitions of %s: %v", table, err)
+		return err
+	}
+
+	return nil
+}
+
+// DropPartitionsBefore drops the monthly partitions of table that only hold
+// rows older than cutoff and returns their names
+func (r *PostgresRepository) DropPartitionsBefore(ctx context.Context, table string, cutoff time.Time) ([]string, error) {
+	var partitions []string
+	err := r.db.SelectContext(ctx, &partitions, listPartitionsQuery, table)
+	if err != nil {
+		r.logger.Errorf("Error listing partitions of %s: %v", table, err)
+		return nil, err
+	}
+
+	var dropped []string
+	for _, partition := range partitions {
+		// Partitions are named <table>_pYYYY_MM; the default partition is kept
+		month, err := time.Parse("2006_01", strings.TrimPrefix(partition, table+"_p"))
+		if err != nil || month.AddDate(0, 1, 0).After(cutoff) {
+			continue
+		}
+
+		if _, err := r.db.ExecContext(ctx, "DROP TABLE IF EXISTS "+pq.QuoteIdentifier(partition)); err != nil {
+			r.logger.Errorf("Error dropping partition %s: %v", partition, err)
+			return dropped, err
+		}
+		dropped = append(dropped, partition)
+	}
+
+	return dropped, nil
+}
+
+// DeleteRowsBefore deletes the rows of table whose column is older than
+// cutoff. It cleans up what dropping whole partitions leaves behind.
+func (r *PostgresRepository) DeleteRowsBefore(ctx context.Context, table, column string, cutoff time.Time) (int64, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s < $1", pq.QuoteIdentifier(table), pq.QuoteIdentifier(column))
+
+	res, err := r.db.ExecContext(ctx, query, cutoff)
+	if err != nil {
+		r.logger.Errorf("Error deleting expired rows of %s: %v", table, err)
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/analytics"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	// liveRetention is how long sessions are kept in the viewer sets. Counts
+	// are taken over shorter windows; older entries are trimmed on write and
+	// idle sets expire.
+	liveRetention = 5 * time.Minute
+
+	viewersAllKey      = "viewers:all"
+	viewersVideosKey   = "viewers:videos"
+	viewersAccountsKey = "viewers:accounts"
+	viewersVideoFmt    = "viewers:video:%s"
+	viewersAccountFmt  = "viewers:account:%s"
+	// viewersAccountVideosFmt lists the videos of an account with viewers
+	viewersAccountVideosFmt = "viewers:account_videos:%s"
+	viewersOwnerFmt         = "viewers:owner:%s"
+)
+
+// liveRepo keeps viewers in sorted sets scored by the time they were last
+// seen, so counting the viewers of a window is a single ZCOUNT.
+type liveRepo struct {
+	redisClient *redis.Client
+}
+
+func NewLiveRepository(redisClient *redis.Client) analytics.LiveRepository {
+	return &liveRepo{redisClient: redisClient}
+}
+
+func (r *liveRepo) MarkViewer(ctx context.Context, videoID, ownerID uuid.UUID, sessionID string, at time.Time) error {
+	score := float64(at.Unix())
+	stale := strconv.FormatInt(at.Add(-liveRetention).Unix(), 10)
+	// Sessions of different videos are counted separately in the account and
+	// global sets
+	member := videoID.String() + ":" + sessionID
+
+	sets := []struct {
+		key    string
+		member string
+	}{
+		{fmt.Sprintf(viewersVideoFmt, videoID), sessionID},
+		{fmt.Sprintf(viewersAccountFmt, ownerID), member},
+		{fmt.Sprintf(viewersAccountVideosFmt, ownerID), videoID.String()},
+		{viewersAllKey, member},
+		{viewersVideosKey, videoID.String()},
+		{viewersAccountsKey, ownerID.String()},
+	}
+
+	pipe := r.redisClient.Pipeline()
+	for _, set := range sets {
+		pipe.ZAdd(ctx, set.key, &redis.Z{Score: score, Member: set.member})
+		pipe.ZRemRangeByScore(ctx, set.key, "-inf", "("+stale)
+		pipe.Expire(ctx, set.key, liveRetention)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to mark viewer: %w", err)
+	}
+	return nil
+}
+
+func (r *liveRepo) count(ctx context.Context, key string, since time.Time) (int64, error) {
+	count, err := r.redisClient.ZCount(ctx, key, strconv.FormatInt(since.Unix(), 10), "+inf").Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count viewers: %w", err)
+	}
+	return count, nil
+}
+
+func (r *liveRepo) CountVideoViewers(ctx context.Context, videoID uuid.UUID, since time.Time) (int64, error) {
+	return r.count(ctx, fmt.Sprintf(viewersVideoFmt, videoID), since)
+}
+
+func (r *liveRepo) CountAccountViewers(ctx context.Context, ownerID uuid.UUID, since time.Time) (int64, error) {
+	return r.count(ctx, fmt.Sprintf(viewersAccountFmt, ownerID), since)
+}
+
+func (r *liveRepo) CountAllViewers(ctx context.Context, since time.Time) (int64, error) {
+	return r.count(ctx, viewersAllKey, since)
+}
+
+func (r *liveRepo) ListVideoViewers(ctx context.Context, ownerID uuid.UUID, since time.Time, limit int) ([]*models.VideoViewers, error) {
+	key := viewersVideosKey
+	if ownerID != uuid.Nil {
+		key = fmt.Sprintf(viewersAccountVideosFmt, ownerID)
+	}
+	counts, err := r.listCounts(ctx, key, viewersVideoFmt, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	videos := make([]*models.VideoViewers, len(counts))
+	for i, count := range counts {
+		videos[i] = &models.VideoViewers{VideoID: count.id, Viewers: count.viewers}
+	}
+	return videos, nil
+}
+
+func (r *liveRepo) ListAccountViewers(ctx context.Context, since time.Time, limit int) ([]*models.AccountViewers, error) {
+	counts, err := r.listCounts(ctx, viewersAccountsKey, viewersAccountFmt, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]*models.AccountViewers, len(counts))
+	for i, count := range counts {
+		accounts[i] = &models.AccountViewers{UserID: count.id, Viewers: count.viewers}
+	}
+	return accounts, nil
+}
+
+type viewerCount struct {
+	id      uuid.UUID
+	viewers int64
+}
+
+// listCounts counts the viewers in the set of every id seen in the index
+// since the given time and returns the limit largest counts.
+func (r *liveRepo) listCounts(ctx context.Context, indexKey, setFmt string, since time.Time, limit int) ([]viewerCount, error) {
+	from := strconv.FormatInt(since.Unix(), 10)
+	ids, err := r.redisClient.ZRangeByScore(ctx, indexKey, &redis.ZRangeBy{Min: from, Max: "+inf"}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list viewer sets: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	pipe := r.redisClient.Pipeline()
+	cmds := make([]*redis.IntCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.ZCount(ctx, fmt.Sprintf(setFmt, id), from, "+inf")
+	}
+	if _, err = pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to count viewers: %w", err)
+	}
+
+	counts := make([]viewerCount, 0, len(ids))
+	for i, id := range ids {
+		parsed, err := uuid.Parse(id)
+		if err != nil || cmds[i].Val() == 0 {
+			continue
+		}
+		counts = append(counts, viewerCount{id: parsed, viewers: cmds[i].Val()})
+	}
+	sort.SliceStable(counts, func(i, j int) bool { return counts[i].viewers > counts[j].viewers })
+	if limit > 0 && len(counts) > limit {
+		counts = counts[:limit]
+	}
+	return counts, nil
+}
+
+// GetVideoOwner returns the cached owner of a video, or uuid.Nil when it is
+// not cached.
+func (r *liveRepo) GetVideoOwner(ctx context.Context, videoID uuid.UUID) (uuid.UUID, error) {
+	owner, err := r.redisClient.Get(ctx, fmt.Sprintf(viewersOwnerFmt, videoID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return uuid.Nil, nil
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get video owner: %w", err)
+	}
+	return uuid.Parse(owner)
+}
+
+func (r *liveRepo) SetVideoOwner(ctx context.Context, videoID, ownerID uuid.UUID, ttl time.Duration) error {
+	if err := r.redisClient.Set(ctx, fmt.Sprintf(viewersOwnerFmt, videoID), ownerID.String(), ttl).Err(); err != nil {
+		return fmt.Errorf("failed to cache video owner: %w", err)
+	}
+	return nil
+}
@@ -3,6 +3,54 @@ package repository
 // SQL queries for analytics repository
 
 const (
+	// Account summary queries. Periods are half-open: $2 <= timestamp < $3.
+	getViewTotalsQuery = `
+		SELECT COUNT(vv.id) AS views,
+			COUNT(DISTINCT COALESCE(vv.user_id::text, vv.ip)) AS unique_viewers,
+			COALESCE(SUM(vv.duration), 0) AS watch_time
+		FROM video_views vv
+		JOIN video_files v ON v.video_id = vv.video_id
+		WHERE v.user_id = $1 AND vv.timestamp >= $2 AND vv.timestamp < $3
+	`
+
+	getFolderBreakdownQuery = `
+		SELECT COALESCE(f.folder_id::text, '') AS key, COALESCE(f.name, '') AS name,
+			COUNT(DISTINCT v.video_id) AS videos, COUNT(vv.id) AS views,
+			COALESCE(SUM(vv.duration), 0) AS watch_time
+		FROM video_files v
+		LEFT JOIN folders f ON f.folder_id = v.folder_id
+		LEFT JOIN video_views vv ON vv.video_id = v.video_id AND vv.timestamp >= $2 AND vv.timestamp < $3
+		WHERE v.user_id = $1 AND v.deleted_at IS NULL
+		GROUP BY f.folder_id, f.name
+		ORDER BY views DESC, videos DESC
+		LIMIT $4
+	`
+
+	getTagBreakdownQuery = `
+		SELECT t.tag AS key, t.tag AS name,
+			COUNT(DISTINCT v.video_id) AS videos, COUNT(vv.id) AS views,
+			COALESCE(SUM(vv.duration), 0) AS watch_time
+		FROM video_files v
+		CROSS JOIN LATERAL unnest(v.tags) AS t(tag)
+		LEFT JOIN video_views vv ON vv.video_id = v.video_id AND vv.timestamp >= $2 AND vv.timestamp < $3
+		WHERE v.user_id = $1 AND v.deleted_at IS NULL
+		GROUP BY t.tag
+		ORDER BY views DESC, videos DESC
+		LIMIT $4
+	`
+
+	getReferrerBreakdownQuery = `
+		SELECT vv.referrer AS key, vv.referrer AS name,
+			COUNT(DISTINCT vv.video_id) AS videos, COUNT(vv.id) AS views,
+			COALESCE(SUM(vv.duration), 0) AS watch_time
+		FROM video_views vv
+		JOIN video_files v ON v.video_id = vv.video_id
+		WHERE v.user_id = $1 AND vv.timestamp >= $2 AND vv.timestamp < $3 AND vv.referrer <> ''
+		GROUP BY vv.referrer
+		ORDER BY views DESC
+		LIMIT $4
+	`
+
 	// Video engagement queries
 	updateVideoEngagementQuery = `
 		INSERT INTO video_engagement (
@@ -116,4 +164,16 @@ const (
 		JOIN video_files vf ON vv.video_id = vf.video_id
 		WHERE vf.user_id = $1
 	`
+
+	// Retention queries
+	createMonthlyPartitionsQuery = `SELECT create_monthly_partitions($1, $2::date, $3::date)`
+
+	listPartitionsQuery = `
+		SELECT child.relname
+		FROM pg_inherits
+		JOIN pg_class parent ON parent.oid = pg_inherits.inhparent
+		JOIN pg_class child ON child.oid = pg_inherits.inhrelid
+		WHERE parent.relname = $1
+		ORDER BY child.relname
+	`
 )
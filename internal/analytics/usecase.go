@@ -2,20 +2,60 @@ package analytics
 
 import (
 	"context"
+	"errors"
+	"io"
 
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
 	"github.com/google/uuid"
 )
 
+// ErrVideoAccessDenied is returned when recording playback of a video the
+// viewer is not allowed to watch
+var ErrVideoAccessDenied = errors.New("video access denied")
+
+// ErrInvalidTimeSeries is returned for time series with an unknown metric or
+// interval, or a range with too many buckets
+var ErrInvalidTimeSeries = errors.New("invalid time series")
+
+// ErrInvalidQoEBeacon is returned for QoE beacons with negative or
+// implausible values
+var ErrInvalidQoEBeacon = errors.New("invalid qoe beacon")
+
+// ErrViewBufferFull is returned when recording a view while more views are
+// waiting to be written than the buffer holds
+var ErrViewBufferFull = errors.New("too many views waiting to be recorded")
+
 // UseCase defines the interface for analytics business logic
 type UseCase interface {
+	// Video access
+	CheckVideoAccess(ctx context.Context, videoID uuid.UUID, shareToken string) error
+
 	// Video views
 	RecordVideoView(ctx context.Context, view *models.VideoView) error
 	GetVideoViews(ctx context.Context, videoID uuid.UUID, filter *models.AnalyticsFilter) ([]*models.VideoView, error)
+	GetGeoBreakdown(ctx context.Context, videoID uuid.UUID, filter *models.AnalyticsFilter) ([]*models.GeoBreakdown, error)
+	GetDeviceBreakdown(ctx context.Context, videoID uuid.UUID, filter *models.AnalyticsFilter) (*models.DeviceBreakdown, error)
+	GetVideoTimeSeries(ctx context.Context, videoID uuid.UUID, metric models.TimeSeriesMetric, interval models.TimeSeriesInterval, filter *models.AnalyticsFilter) (*models.TimeSeries, error)
 	
 	// Watch sessions
 	StartWatchSession(ctx context.Context, videoID, userID uuid.UUID, sessionID string) (*models.VideoWatchSession, error)
-	EndWatchSession(ctx context.Context, sessionID string, watchDuration int64, completed bool) error
+	EndWatchSession(ctx context.Context, videoID uuid.UUID, sessionID string, watchDuration int64, completed bool) error
+
+	// Heartbeats and retention
+	RecordHeartbeat(ctx context.Context, heartbeat *models.VideoHeartbeat) error
+	RecordLiveHeartbeat(ctx context.Context, heartbeat *models.LiveHeartbeat) error
+	GetVideoViewers(ctx context.Context, videoID uuid.UUID) (*models.VideoViewers, error)
+	GetConcurrentViewers(ctx context.Context, userID uuid.UUID) (*models.ConcurrentViewers, error)
+	GetRetentionCurve(ctx context.Context, videoID uuid.UUID, bucketSize int, filter *models.AnalyticsFilter) (*models.RetentionCurve, error)
+	GetAccountHeatmap(ctx context.Context, userID uuid.UUID, buckets int, filter *models.AnalyticsFilter) (*models.AccountHeatmap, error)
+
+	// Playback quality
+	RecordQoEBeacon(ctx context.Context, beacon *models.QoEBeacon) error
+	GetVideoQoE(ctx context.Context, videoID uuid.UUID, filter *models.AnalyticsFilter) (*models.VideoQoE, error)
+
+	// CDN delivery
+	GetVideoDelivery(ctx context.Context, videoID uuid.UUID, filter *models.AnalyticsFilter) (*models.VideoDelivery, error)
+	GetAccountDelivery(ctx context.Context, userID uuid.UUID, filter *models.AnalyticsFilter) (*models.AccountDelivery, error)
 	
 	// Engagement metrics
 	CalculateEngagement(ctx context.Context, videoID uuid.UUID) (*models.VideoEngagement, error)
@@ -27,7 +67,12 @@ type UseCase interface {
 	GetRecentVideos(ctx context.Context, userID uuid.UUID, limit int) ([]*models.VideoPerformance, error)
 	
 	// Summary metrics
-	GetAnalyticsSummary(ctx context.Context, userID uuid.UUID) (*models.AnalyticsSummary, error)
+	GetAnalyticsSummary(ctx context.Context, userID uuid.UUID, filter *models.AnalyticsFilter) (*models.AnalyticsSummary, error)
 	GetTotalVideos(ctx context.Context, userID uuid.UUID) (int64, error)
 	GetTotalWatchTime(ctx context.Context, userID uuid.UUID) (int64, error)
+
+	// Exports
+	ExportAnalytics(ctx context.Context, userID uuid.UUID, input *models.AnalyticsExportInput, w io.Writer) error
+	StartAnalyticsExport(ctx context.Context, userID uuid.UUID, input *models.AnalyticsExportInput) (*models.AnalyticsExport, error)
+	GetAnalyticsExport(ctx context.Context, userID, exportID uuid.UUID) (*models.AnalyticsExport, error)
 }
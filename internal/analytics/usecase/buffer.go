@@ -0,0 +1,197 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/analytics"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// DefaultViewBufferSize is how many views wait to be written at most
+	// when the analytics config does not set it
+	DefaultViewBufferSize = 10000
+	// DefaultViewBatchSize is how many views are written at once at most
+	DefaultViewBatchSize = 500
+	// DefaultViewFlushInterval is the longest a view waits to be written
+	DefaultViewFlushInterval = time.Second
+	// viewEnqueueTimeout is how long a request waits for room in a full
+	// buffer before its view is rejected
+	viewEnqueueTimeout = 100 * time.Millisecond
+	// viewWriteAttempts is how often a batch is written before its views
+	// are given up
+	viewWriteAttempts = 3
+	// viewWriteTimeout bounds a single write of a batch
+	viewWriteTimeout = 10 * time.Second
+)
+
+var (
+	viewsBufferedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "analytics_views_buffered_total",
+		Help: "Number of video views accepted into the write buffer",
+	})
+	viewsWrittenTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "analytics_views_written_total",
+		Help: "Number of buffered video views written to Postgres",
+	})
+	viewsDroppedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "analytics_views_dropped_total",
+		Help: "Number of video views lost, by reason: rejected while the buffer was full or failed to be written",
+	}, []string{"reason"})
+	viewBufferDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "analytics_view_buffer_depth",
+		Help: "Number of video views waiting in the write buffer",
+	})
+)
+
+// ViewBuffer decouples recording video views from writing them. Requests add
+// views to a bounded buffer and Run writes them to Postgres in batches, so a
+// burst of playback costs a few COPYs instead of an insert per view. A full
+// buffer pushes back by rejecting views with analytics.ErrViewBufferFull.
+type ViewBuffer struct {
+	repo      analytics.Repository
+	views     chan *models.VideoView
+	batchSize int
+	interval  time.Duration
+	done      chan struct{}
+	logger    logger.Logger
+}
+
+// NewViewBuffer creates a view buffer sized by the analytics config
+func NewViewBuffer(repo analytics.Repository, cfg config.AnalyticsConfig, log logger.Logger) *ViewBuffer {
+	size := cfg.ViewBufferSize
+	if size <= 0 {
+		size = DefaultViewBufferSize
+	}
+	batchSize := cfg.ViewBatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultViewBatchSize
+	}
+	interval := time.Duration(cfg.ViewFlushInterval) * time.Millisecond
+	if interval <= 0 {
+		interval = DefaultViewFlushInterval
+	}
+	return &ViewBuffer{
+		repo:      repo,
+		views:     make(chan *models.VideoView, size),
+		batchSize: batchSize,
+		interval:  interval,
+		done:      make(chan struct{}),
+		logger:    log,
+	}
+}
+
+// Add queues a view to be written. It waits briefly for room while the
+// buffer is full and returns analytics.ErrViewBufferFull if none frees up.
+func (b *ViewBuffer) Add(ctx context.Context, view *models.VideoView) error {
+	select {
+	case b.views <- view:
+	default:
+		timer := time.NewTimer(viewEnqueueTimeout)
+		defer timer.Stop()
+		select {
+		case b.views <- view:
+		case <-timer.C:
+			viewsDroppedTotal.WithLabelValues("buffer_full").Inc()
+			return analytics.ErrViewBufferFull
+		case <-ctx.Done():
+			viewsDroppedTotal.WithLabelValues("buffer_full").Inc()
+			return ctx.Err()
+		}
+	}
+	viewsBufferedTotal.Inc()
+	viewBufferDepth.Set(float64(len(b.views)))
+	return nil
+}
+
+// Run writes buffered views whenever a batch is full or the flush interval
+// passed, until ctx is done. It then writes what is left in the buffer and
+// closes Done.
+func (b *ViewBuffer) Run(ctx context.Context) {
+	defer close(b.done)
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	batch := make([]*models.VideoView, 0, b.batchSize)
+	for {
+		select {
+		case view := <-b.views:
+			batch = append(batch, view)
+			if len(batch) >= b.batchSize {
+				batch = b.flush(batch)
+			}
+		case <-ticker.C:
+			batch = b.flush(batch)
+		case <-ctx.Done():
+			for {
+				select {
+				case view := <-b.views:
+					batch = append(batch, view)
+					if len(batch) >= b.batchSize {
+						batch = b.flush(batch)
+					}
+				default:
+					b.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// Done is closed once Run has written the buffer after being stopped
+func (b *ViewBuffer) Done() <-chan struct{} {
+	return b.done
+}
+
+// flush writes batch, retrying failed writes, and returns it emptied. A batch
+// that keeps failing is written view by view, so a view the database rejects
+// does not cost the rest of its batch.
+func (b *ViewBuffer) flush(batch []*models.VideoView) []*models.VideoView {
+	viewBufferDepth.Set(float64(len(b.views)))
+	if len(batch) == 0 {
+		return batch
+	}
+
+	var err error
+	for attempt := 1; attempt <= viewWriteAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), viewWriteTimeout)
+		err = b.repo.CreateVideoViews(ctx, batch)
+		cancel()
+		if err == nil {
+			viewsWrittenTotal.Add(float64(len(batch)))
+			return batch[:0]
+		}
+		b.logger.Warnf("ViewBuffer - failed to write %d views, attempt %d: %v", len(batch), attempt, err)
+		time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+	}
+	b.logger.Errorf("ViewBuffer - failed to write %d views as a batch, writing them one by one: %v", len(batch), err)
+	b.writeEach(batch)
+	return batch[:0]
+}
+
+// writeEach writes the views of a failed batch one at a time and counts the
+// ones that fail as lost. The writes share one timeout, so a database that
+// is down costs no more than a batch write.
+func (b *ViewBuffer) writeEach(batch []*models.VideoView) {
+	ctx, cancel := context.WithTimeout(context.Background(), viewWriteTimeout)
+	defer cancel()
+
+	var dropped int
+	for _, view := range batch {
+		if err := b.repo.CreateVideoView(ctx, view); err != nil {
+			dropped++
+			continue
+		}
+		viewsWrittenTotal.Inc()
+	}
+	if dropped > 0 {
+		b.logger.Errorf("ViewBuffer - dropping %d of %d views", dropped, len(batch))
+		viewsDroppedTotal.WithLabelValues("write_failed").Add(float64(dropped))
+	}
+}
@@ -0,0 +1,293 @@
+package usecase
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/analytics"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/cdnlog"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultCDNLogInterval is used when the analytics config does not set one
+	DefaultCDNLogInterval = 15 * time.Minute
+	// DeliveryVideoLimit is how many videos the account delivery lists
+	DeliveryVideoLimit = 20
+)
+
+var (
+	// renditionRe matches the directories renditions are written to, e.g.
+	// 720p or 1080p60
+	renditionRe = regexp.MustCompile(`^[0-9]{3,4}p([0-9]{2})?$`)
+	// versionRe matches the directories of re-encoded playback versions
+	versionRe = regexp.MustCompile(`^v[0-9]+$`)
+)
+
+// DeliveryLogJob attributes the bytes and requests in the CDN access logs to
+// the videos and renditions they delivered, so bandwidth costs can be
+// broken down per video
+type DeliveryLogJob struct {
+	repo   analytics.Repository
+	store  analytics.LogStore
+	cfg    config.AnalyticsConfig
+	logger logger.Logger
+}
+
+// NewDeliveryLogJob creates a job ingesting the logs configured in cfg from
+// store
+func NewDeliveryLogJob(repo analytics.Repository, store analytics.LogStore, cfg config.AnalyticsConfig, log logger.Logger) *DeliveryLogJob {
+	return &DeliveryLogJob{
+		repo:   repo,
+		store:  store,
+		cfg:    cfg,
+		logger: log,
+	}
+}
+
+// Run ingests new logs once immediately and then every interval until ctx
+// is done. It returns right away when no log bucket or an unknown log
+// format is configured.
+func (j *DeliveryLogJob) Run(ctx context.Context) {
+	if j.cfg.CDNLogBucket == "" {
+		return
+	}
+	switch cdnlog.Format(j.cfg.CDNLogFormat) {
+	case cdnlog.FormatS3, cdnlog.FormatCloudFront:
+	default:
+		j.logger.Errorf("Run - unknown cdn log format %q, not ingesting cdn logs", j.cfg.CDNLogFormat)
+		return
+	}
+	interval := time.Duration(j.cfg.CDNLogInterval) * time.Second
+	if interval <= 0 {
+		interval = DefaultCDNLogInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		j.Ingest(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Ingest processes every log file not ingested yet. Failures are logged and
+// the file is retried on the next run.
+func (j *DeliveryLogJob) Ingest(ctx context.Context) {
+	keys, err := j.store.ListObjectsWithPrefix(ctx, j.cfg.CDNLogBucket, j.cfg.CDNLogPrefix)
+	if err != nil {
+		j.logger.Errorf("Ingest - failed to list cdn logs: %v", err)
+		return
+	}
+	if len(keys) == 0 {
+		return
+	}
+	keys, err = j.repo.GetNewLogFiles(ctx, keys)
+	if err != nil {
+		j.logger.Errorf("Ingest - failed to filter cdn logs: %v", err)
+		return
+	}
+
+	videos := make(map[string]uuid.UUID)
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := j.ingestFile(ctx, key, videos); err != nil {
+			j.logger.Errorf("Ingest - failed to ingest cdn log %s: %v", key, err)
+		}
+	}
+}
+
+// ingestFile attributes the downloads in one log file. videos caches the
+// video of each output prefix, uuid.Nil for prefixes of no video, across
+// the files of a run.
+func (j *DeliveryLogJob) ingestFile(ctx context.Context, key string, videos map[string]uuid.UUID) error {
+	object, err := j.store.GetObject(ctx, j.cfg.CDNLogBucket, key)
+	if err != nil {
+		return fmt.Errorf("failed to get log: %w", err)
+	}
+	defer object.Body.Close()
+
+	body := io.Reader(object.Body)
+	if strings.HasSuffix(key, ".gz") {
+		gz, err := gzip.NewReader(object.Body)
+		if err != nil {
+			return fmt.Errorf("failed to decompress log: %w", err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	type statKey struct {
+		videoID   uuid.UUID
+		rendition string
+		day       time.Time
+	}
+	stats := make(map[statKey]*models.DeliveryStat)
+	var records int64
+	var lookupErr error
+	err = cdnlog.Parse(body, cdnlog.Format(j.cfg.CDNLogFormat), func(record cdnlog.Record) {
+		records++
+		prefix, rendition, ok := splitOutputKey(record.Key)
+		if !ok || lookupErr != nil {
+			return
+		}
+		videoID, cached := videos[prefix]
+		if !cached {
+			videoID, lookupErr = j.lookupVideo(ctx, prefix)
+			if lookupErr != nil {
+				return
+			}
+			videos[prefix] = videoID
+		}
+		if videoID == uuid.Nil {
+			return
+		}
+
+		k := statKey{videoID: videoID, rendition: rendition, day: record.Time.Truncate(24 * time.Hour)}
+		stat, found := stats[k]
+		if !found {
+			stat = &models.DeliveryStat{VideoID: videoID, Rendition: rendition, Day: k.day}
+			stats[k] = stat
+		}
+		stat.Bytes += record.Bytes
+		stat.Requests++
+	})
+	if err != nil {
+		return fmt.Errorf("failed to parse log: %w", err)
+	}
+	if lookupErr != nil {
+		return fmt.Errorf("failed to look up video: %w", lookupErr)
+	}
+
+	rows := make([]*models.DeliveryStat, 0, len(stats))
+	for _, stat := range stats {
+		rows = append(rows, stat)
+	}
+	if err := j.repo.RecordDeliveryLog(ctx, key, records, rows); err != nil {
+		return fmt.Errorf("failed to record delivery stats: %w", err)
+	}
+	return nil
+}
+
+// lookupVideo returns the video whose outputs are stored under prefix, or
+// uuid.Nil when there is none, e.g. because it was deleted
+func (j *DeliveryLogJob) lookupVideo(ctx context.Context, prefix string) (uuid.UUID, error) {
+	userID, err := uuid.Parse(strings.Split(prefix, "/")[1])
+	if err != nil {
+		return uuid.Nil, nil
+	}
+	videoID, err := j.repo.GetVideoIDByOutputPrefix(ctx, userID, prefix)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, nil
+	}
+	return videoID, err
+}
+
+// splitOutputKey splits the key of an output, laid out as
+// uploads/<user id>/<name>/[v<version>/]<rendition>/<file>, into the prefix
+// of its video and its rendition. Files outside a rendition directory are
+// attributed to models.RenditionOther.
+func splitOutputKey(key string) (prefix, rendition string, ok bool) {
+	parts := strings.Split(strings.TrimPrefix(path.Clean("/"+key), "/"), "/")
+	if len(parts) < 4 || parts[0] != "uploads" {
+		return "", "", false
+	}
+	prefix = strings.Join(parts[:3], "/")
+
+	rest := parts[3:]
+	if len(rest) > 1 && versionRe.MatchString(rest[0]) {
+		rest = rest[1:]
+	}
+	if len(rest) > 1 && renditionRe.MatchString(rest[0]) {
+		return prefix, rest[0], true
+	}
+	return prefix, models.RenditionOther, true
+}
+
+// GetVideoDelivery returns what the CDN delivered of a video overall, per
+// rendition and per day
+func (a *analyticsUC) GetVideoDelivery(ctx context.Context, videoID uuid.UUID, filter *models.AnalyticsFilter) (*models.VideoDelivery, error) {
+	if err := a.checkVideoOwner(ctx, videoID); err != nil {
+		return nil, err
+	}
+
+	overall, err := a.repo.GetDeliveryTotals(ctx, videoID, "", filter)
+	if err != nil {
+		a.logger.Errorf("GetVideoDelivery - overall error: %v", err)
+		return nil, fmt.Errorf("failed to get delivery totals: %w", err)
+	}
+
+	byRendition, err := a.repo.GetDeliveryTotals(ctx, videoID, "rendition", filter)
+	if err != nil {
+		a.logger.Errorf("GetVideoDelivery - rendition error: %v", err)
+		return nil, fmt.Errorf("failed to get delivery by rendition: %w", err)
+	}
+
+	byDay, err := a.repo.GetDeliveryTotals(ctx, videoID, "day", filter)
+	if err != nil {
+		a.logger.Errorf("GetVideoDelivery - day error: %v", err)
+		return nil, fmt.Errorf("failed to get delivery by day: %w", err)
+	}
+
+	delivery := &models.VideoDelivery{
+		VideoID:     videoID,
+		ByRendition: byRendition,
+		ByDay:       byDay,
+	}
+	if len(overall) > 0 {
+		delivery.Bytes = overall[0].Bytes
+		delivery.Requests = overall[0].Requests
+	}
+	return delivery, nil
+}
+
+// GetAccountDelivery returns what the CDN delivered of a user's videos, with
+// up to filter.Limit videos that used the most bandwidth
+func (a *analyticsUC) GetAccountDelivery(ctx context.Context, userID uuid.UUID, filter *models.AnalyticsFilter) (*models.AccountDelivery, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DeliveryVideoLimit
+	}
+	totalsFilter := *filter
+	totalsFilter.Limit = 0
+
+	overall, err := a.repo.GetAccountDeliveryTotals(ctx, userID, "", &totalsFilter)
+	if err != nil {
+		a.logger.Errorf("GetAccountDelivery - overall error: %v", err)
+		return nil, fmt.Errorf("failed to get delivery totals: %w", err)
+	}
+
+	videosFilter := *filter
+	videosFilter.Limit = limit
+	byVideo, err := a.repo.GetAccountDeliveryTotals(ctx, userID, "video_id", &videosFilter)
+	if err != nil {
+		a.logger.Errorf("GetAccountDelivery - video_id error: %v", err)
+		return nil, fmt.Errorf("failed to get delivery by video: %w", err)
+	}
+
+	delivery := &models.AccountDelivery{ByVideo: byVideo}
+	if len(overall) > 0 {
+		delivery.Bytes = overall[0].Bytes
+		delivery.Requests = overall[0].Requests
+	}
+	return delivery, nil
+}
@@ -0,0 +1,357 @@
+package usecase
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/analytics"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultExportMaxSyncDays is the longest range streamed in the response
+	// when the analytics config does not set one
+	DefaultExportMaxSyncDays = 31
+	// ExportURLTTL is how long the download URL of a background export is
+	// valid. A new one is issued every time the export is fetched.
+	ExportURLTTL = time.Hour
+	// exportTimeout bounds a background export
+	exportTimeout = 30 * time.Minute
+)
+
+// ExportAnalytics writes the views or watch sessions of the user's videos in
+// the time range to w. Ranges longer than the synchronous limit are rejected
+// with analytics.ErrExportTooLarge before anything is written.
+func (a *analyticsUC) ExportAnalytics(ctx context.Context, userID uuid.UUID, input *models.AnalyticsExportInput, w io.Writer) error {
+	if err := a.validateExport(ctx, input); err != nil {
+		return err
+	}
+
+	maxDays := DefaultExportMaxSyncDays
+	if a.cfg.Analytics.ExportMaxSyncDays > 0 {
+		maxDays = a.cfg.Analytics.ExportMaxSyncDays
+	}
+	if input.TimeRange.EndDate.Sub(input.TimeRange.StartDate) > time.Duration(maxDays)*24*time.Hour {
+		return analytics.ErrExportTooLarge
+	}
+
+	if _, err := a.writeExport(ctx, userID, input, w); err != nil {
+		a.logger.Errorf("ExportAnalytics - writeExport error: %v", err)
+		return fmt.Errorf("failed to export analytics: %w", err)
+	}
+	return nil
+}
+
+// StartAnalyticsExport writes an export to object storage in the background.
+// Its progress and download URL are read with GetAnalyticsExport.
+func (a *analyticsUC) StartAnalyticsExport(ctx context.Context, userID uuid.UUID, input *models.AnalyticsExportInput) (*models.AnalyticsExport, error) {
+	if err := a.validateExport(ctx, input); err != nil {
+		return nil, err
+	}
+
+	export, err := a.repo.CreateExport(ctx, &models.AnalyticsExport{
+		UserID:    userID,
+		Dataset:   input.Dataset,
+		Format:    input.Format,
+		VideoID:   input.VideoID,
+		StartDate: input.TimeRange.StartDate,
+		EndDate:   input.TimeRange.EndDate,
+		Status:    models.ExportPending,
+	})
+	if err != nil {
+		a.logger.Errorf("StartAnalyticsExport - CreateExport error: %v", err)
+		return nil, fmt.Errorf("failed to create export: %w", err)
+	}
+
+	go a.runExport(*export, *input)
+	return export, nil
+}
+
+// GetAnalyticsExport returns an export of the user, with a download URL once
+// it has completed.
+func (a *analyticsUC) GetAnalyticsExport(ctx context.Context, userID, exportID uuid.UUID) (*models.AnalyticsExport, error) {
+	export, err := a.repo.GetExport(ctx, userID, exportID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, analytics.ErrExportNotFound
+		}
+		a.logger.Errorf("GetAnalyticsExport - GetExport error: %v", err)
+		return nil, fmt.Errorf("failed to get export: %w", err)
+	}
+
+	if export.Status == models.ExportCompleted && export.ObjectKey != nil {
+		downloadURL, err := a.store.GetPresignedObjectURL(ctx, a.exportBucket(), *export.ObjectKey, ExportURLTTL)
+		if err != nil {
+			a.logger.Errorf("GetAnalyticsExport - GetPresignedObjectURL error: %v", err)
+			return nil, fmt.Errorf("failed to presign export: %w", err)
+		}
+		expires := time.Now().Add(ExportURLTTL)
+		export.DownloadURL = downloadURL
+		export.URLExpires = &expires
+	}
+	return export, nil
+}
+
+// validateExport checks the input and defaults the end of the range to now
+func (a *analyticsUC) validateExport(ctx context.Context, input *models.AnalyticsExportInput) error {
+	if err := utils.ValidateStruct(ctx, input); err != nil {
+		return fmt.Errorf("invalid input: %v", err)
+	}
+	if input.TimeRange.StartDate.IsZero() {
+		return fmt.Errorf("start_date is required")
+	}
+	if input.TimeRange.EndDate.IsZero() {
+		input.TimeRange.EndDate = time.Now()
+	}
+	if input.TimeRange.EndDate.Before(input.TimeRange.StartDate) {
+		return fmt.Errorf("end_date is before start_date")
+	}
+	return nil
+}
+
+func (a *analyticsUC) exportBucket() string {
+	if a.cfg.Analytics.ExportBucket != "" {
+		return a.cfg.Analytics.ExportBucket
+	}
+	return a.cfg.S3.InputBucket
+}
+
+// runExport writes a background export and records the outcome. The request
+// that started it is gone, so it runs on its own context.
+func (a *analyticsUC) runExport(export models.AnalyticsExport, input models.AnalyticsExportInput) {
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+
+	export.Status = models.ExportRunning
+	if err := a.repo.UpdateExport(ctx, &export); err != nil {
+		a.logger.Errorf("runExport - UpdateExport error: %v", err)
+	}
+
+	err := a.uploadExport(ctx, &export, &input)
+	completedAt := time.Now()
+	export.CompletedAt = &completedAt
+	export.Status = models.ExportCompleted
+	if err != nil {
+		a.logger.Errorf("runExport - export %s failed: %v", export.ExportID, err)
+		message := err.Error()
+		export.Status = models.ExportFailed
+		export.Error = &message
+	}
+	if err := a.repo.UpdateExport(ctx, &export); err != nil {
+		a.logger.Errorf("runExport - UpdateExport error: %v", err)
+	}
+}
+
+// uploadExport writes an export to a temporary file, whose size S3 needs to
+// know up front, and uploads it.
+func (a *analyticsUC) uploadExport(ctx context.Context, export *models.AnalyticsExport, input *models.AnalyticsExportInput) error {
+	file, err := os.CreateTemp("", "analytics-export-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	rows, err := a.writeExport(ctx, export.UserID, input, file)
+	if err != nil {
+		return err
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	name := input.FileName()
+	key := fmt.Sprintf("exports/%s/%s/%s", export.UserID, export.ExportID, name)
+	_, err = a.store.PutObject(ctx, models.UploadInput{
+		File:               file,
+		Name:               name,
+		MimeType:           input.ContentType(),
+		Size:               size,
+		Key:                key,
+		BucketName:         a.exportBucket(),
+		ContentDisposition: fmt.Sprintf("attachment; filename=%q", name),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload export: %w", err)
+	}
+
+	export.ObjectKey = &key
+	export.Rows = rows
+	return nil
+}
+
+// writeExport encodes the rows selected by input to w and returns how many
+// were written.
+func (a *analyticsUC) writeExport(ctx context.Context, userID uuid.UUID, input *models.AnalyticsExportInput, w io.Writer) (int64, error) {
+	filter := &models.AnalyticsFilter{UserID: userID, TimeRange: input.TimeRange}
+	if input.VideoID != nil {
+		filter.VideoID = *input.VideoID
+	}
+
+	var rows int64
+	var enc exportEncoder
+	var err error
+	switch input.Dataset {
+	case models.ExportViews:
+		enc = newExportEncoder(input.Format, viewColumns, w)
+		err = a.repo.StreamVideoViews(ctx, filter, func(view *models.VideoView) error {
+			rows++
+			return enc.encode(newViewRow(view))
+		})
+	case models.ExportSessions:
+		enc = newExportEncoder(input.Format, sessionColumns, w)
+		err = a.repo.StreamWatchSessions(ctx, filter, func(session *models.VideoWatchSession) error {
+			rows++
+			return enc.encode(newSessionRow(session))
+		})
+	default:
+		return 0, fmt.Errorf("unknown dataset %q", input.Dataset)
+	}
+	if err != nil {
+		return rows, err
+	}
+	return rows, enc.flush()
+}
+
+// exportRow is a row of an export. Rows are encoded as JSON objects in
+// NDJSON exports and as the fields of csvRecord in CSV exports.
+type exportRow interface {
+	csvRecord() []string
+}
+
+var viewColumns = []string{"id", "video_id", "user_id", "timestamp", "duration", "country", "region", "device_type", "browser", "os"}
+
+type viewRow struct {
+	ID         int64     `json:"id"`
+	VideoID    uuid.UUID `json:"video_id"`
+	UserID     string    `json:"user_id"`
+	Timestamp  time.Time `json:"timestamp"`
+	Duration   int64     `json:"duration"`
+	Country    string    `json:"country"`
+	Region     string    `json:"region"`
+	DeviceType string    `json:"device_type"`
+	Browser    string    `json:"browser"`
+	OS         string    `json:"os"`
+}
+
+func newViewRow(view *models.VideoView) *viewRow {
+	return &viewRow{
+		ID:         view.ID,
+		VideoID:    view.VideoID,
+		UserID:     exportUserID(view.UserID),
+		Timestamp:  view.Timestamp.UTC(),
+		Duration:   view.Duration,
+		Country:    view.Country,
+		Region:     view.Region,
+		DeviceType: view.DeviceType,
+		Browser:    view.Browser,
+		OS:         view.OS,
+	}
+}
+
+func (r *viewRow) csvRecord() []string {
+	return []string{
+		strconv.FormatInt(r.ID, 10), r.VideoID.String(), r.UserID, r.Timestamp.Format(time.RFC3339),
+		strconv.FormatInt(r.Duration, 10), r.Country, r.Region, r.DeviceType, r.Browser, r.OS,
+	}
+}
+
+var sessionColumns = []string{"id", "video_id", "user_id", "session_id", "start_time", "end_time", "watch_duration", "completed"}
+
+type sessionRow struct {
+	ID            int64     `json:"id"`
+	VideoID       uuid.UUID `json:"video_id"`
+	UserID        string    `json:"user_id"`
+	SessionID     string    `json:"session_id"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	WatchDuration int64     `json:"watch_duration"`
+	Completed     bool      `json:"completed"`
+}
+
+func newSessionRow(session *models.VideoWatchSession) *sessionRow {
+	return &sessionRow{
+		ID:            session.ID,
+		VideoID:       session.VideoID,
+		UserID:        exportUserID(session.UserID),
+		SessionID:     session.SessionID,
+		StartTime:     session.StartTime.UTC(),
+		EndTime:       session.EndTime.UTC(),
+		WatchDuration: session.WatchDuration,
+		Completed:     session.Completed,
+	}
+}
+
+func (r *sessionRow) csvRecord() []string {
+	return []string{
+		strconv.FormatInt(r.ID, 10), r.VideoID.String(), r.UserID, r.SessionID,
+		r.StartTime.Format(time.RFC3339), r.EndTime.Format(time.RFC3339),
+		strconv.FormatInt(r.WatchDuration, 10), strconv.FormatBool(r.Completed),
+	}
+}
+
+// exportUserID leaves the user of anonymous viewers empty
+func exportUserID(userID uuid.UUID) string {
+	if userID == uuid.Nil {
+		return ""
+	}
+	return userID.String()
+}
+
+type exportEncoder interface {
+	encode(row exportRow) error
+	flush() error
+}
+
+func newExportEncoder(format models.ExportFormat, columns []string, w io.Writer) exportEncoder {
+	if format == models.ExportNDJSON {
+		buf := bufio.NewWriter(w)
+		return &ndjsonEncoder{buf: buf, enc: json.NewEncoder(buf)}
+	}
+	// The header is buffered like the rows, so nothing reaches w before the
+	// first rows are read or the export is flushed
+	writer := csv.NewWriter(w)
+	_ = writer.Write(columns)
+	return &csvEncoder{w: writer}
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) encode(row exportRow) error {
+	return e.w.Write(row.csvRecord())
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonEncoder struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) encode(row exportRow) error {
+	return e.enc.Encode(row)
+}
+
+func (e *ndjsonEncoder) flush() error {
+	return e.buf.Flush()
+}
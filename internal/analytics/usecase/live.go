@@ -0,0 +1,206 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/analytics"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// ViewerWindow is how recently a session must have sent a heartbeat to
+	// count as a concurrent viewer. Players send one every 10 seconds, so a
+	// viewer survives two lost heartbeats.
+	ViewerWindow = 30 * time.Second
+	// MaxLiveVideos caps the videos and accounts listed with their viewer
+	// counts
+	MaxLiveVideos = 50
+	// videoOwnerTTL is how long the owner of a video is cached for the
+	// account viewer counts
+	videoOwnerTTL = time.Hour
+)
+
+// RecordLiveHeartbeat counts the session as watching the video now, without
+// storing the heartbeat like RecordHeartbeat does.
+func (a *analyticsUC) RecordLiveHeartbeat(ctx context.Context, heartbeat *models.LiveHeartbeat) error {
+	if err := utils.ValidateStruct(ctx, heartbeat); err != nil {
+		return fmt.Errorf("invalid input: %v", err)
+	}
+	return a.markViewer(ctx, heartbeat.VideoID, heartbeat.SessionID)
+}
+
+// markViewer adds the session to the viewers of the video and of its owner
+func (a *analyticsUC) markViewer(ctx context.Context, videoID uuid.UUID, sessionID string) error {
+	ownerID, err := a.videoOwner(ctx, videoID)
+	if err != nil {
+		a.logger.Errorf("markViewer - videoOwner error: %v", err)
+		return fmt.Errorf("failed to get video owner: %w", err)
+	}
+	if err := a.live.MarkViewer(ctx, videoID, ownerID, sessionID, time.Now()); err != nil {
+		a.logger.Errorf("markViewer - MarkViewer error: %v", err)
+		return fmt.Errorf("failed to record viewer: %w", err)
+	}
+	return nil
+}
+
+// videoOwner returns the owner of a video from the cache, falling back to
+// the database, so heartbeats cost no query once a video is being watched.
+func (a *analyticsUC) videoOwner(ctx context.Context, videoID uuid.UUID) (uuid.UUID, error) {
+	ownerID, err := a.live.GetVideoOwner(ctx, videoID)
+	if err != nil {
+		a.logger.Warnf("videoOwner - GetVideoOwner error: %v", err)
+	}
+	if ownerID != uuid.Nil {
+		return ownerID, nil
+	}
+
+	video, err := a.repo.GetVideoAccess(ctx, videoID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if err := a.live.SetVideoOwner(ctx, videoID, video.UserID, videoOwnerTTL); err != nil {
+		a.logger.Warnf("videoOwner - SetVideoOwner error: %v", err)
+	}
+	return video.UserID, nil
+}
+
+// checkVideoOwner makes sure the user in ctx owns the video, so the
+// analytics of a video are only shown to its owner. It returns
+// analytics.ErrVideoAccessDenied for videos of other users and for videos
+// that do not exist.
+func (a *analyticsUC) checkVideoOwner(ctx context.Context, videoID uuid.UUID) error {
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		return analytics.ErrVideoAccessDenied
+	}
+	ownerID, err := a.videoOwner(ctx, videoID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return analytics.ErrVideoAccessDenied
+		}
+		a.logger.Errorf("checkVideoOwner - videoOwner error: %v", err)
+		return fmt.Errorf("failed to get video owner: %w", err)
+	}
+	if ownerID != user.UserID {
+		return analytics.ErrVideoAccessDenied
+	}
+	return nil
+}
+
+// GetVideoViewers returns the number of sessions watching a video right now.
+// Only the owner of the video may see it.
+func (a *analyticsUC) GetVideoViewers(ctx context.Context, videoID uuid.UUID) (*models.VideoViewers, error) {
+	if err := a.checkVideoOwner(ctx, videoID); err != nil {
+		return nil, err
+	}
+
+	viewers, err := a.live.CountVideoViewers(ctx, videoID, time.Now().Add(-ViewerWindow))
+	if err != nil {
+		a.logger.Errorf("GetVideoViewers - CountVideoViewers error: %v", err)
+		return nil, fmt.Errorf("failed to get concurrent viewers: %w", err)
+	}
+	return &models.VideoViewers{VideoID: videoID, Viewers: viewers}, nil
+}
+
+// GetConcurrentViewers returns the number of sessions watching the user's
+// videos right now, and the most watched of them.
+func (a *analyticsUC) GetConcurrentViewers(ctx context.Context, userID uuid.UUID) (*models.ConcurrentViewers, error) {
+	now := time.Now()
+	since := now.Add(-ViewerWindow)
+	viewers, err := a.live.CountAccountViewers(ctx, userID, since)
+	if err != nil {
+		a.logger.Errorf("GetConcurrentViewers - CountAccountViewers error: %v", err)
+		return nil, fmt.Errorf("failed to get concurrent viewers: %w", err)
+	}
+	videos, err := a.live.ListVideoViewers(ctx, userID, since, MaxLiveVideos)
+	if err != nil {
+		a.logger.Errorf("GetConcurrentViewers - ListVideoViewers error: %v", err)
+		return nil, fmt.Errorf("failed to get concurrent viewers: %w", err)
+	}
+	return &models.ConcurrentViewers{
+		Viewers: viewers,
+		Videos:  videos,
+		Window:  int(ViewerWindow.Seconds()),
+		AsOf:    now,
+	}, nil
+}
+
+var (
+	concurrentViewersDesc = prometheus.NewDesc(
+		"concurrent_viewers",
+		"Sessions that sent a playback heartbeat within the viewer window",
+		nil, nil,
+	)
+	videoConcurrentViewersDesc = prometheus.NewDesc(
+		"video_concurrent_viewers",
+		"Sessions watching a video, for the most watched videos",
+		[]string{"video_id"}, nil,
+	)
+	accountConcurrentViewersDesc = prometheus.NewDesc(
+		"account_concurrent_viewers",
+		"Sessions watching the videos of an account, for the most watched accounts",
+		[]string{"user_id"}, nil,
+	)
+)
+
+// viewersCollector reads the concurrent viewer counts from Redis when
+// scraped. Only the most watched videos and accounts get a series, which
+// keeps the number of series bounded however many are being watched.
+type viewersCollector struct {
+	live   analytics.LiveRepository
+	logger logger.Logger
+}
+
+// NewViewersCollector returns a Prometheus collector of the concurrent
+// viewers tracked in live
+func NewViewersCollector(live analytics.LiveRepository, log logger.Logger) prometheus.Collector {
+	return &viewersCollector{live: live, logger: log}
+}
+
+func (c *viewersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- concurrentViewersDesc
+	ch <- videoConcurrentViewersDesc
+	ch <- accountConcurrentViewersDesc
+}
+
+func (c *viewersCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	since := time.Now().Add(-ViewerWindow)
+	total, err := c.live.CountAllViewers(ctx, since)
+	if err != nil {
+		c.logger.Errorf("viewersCollector - CountAllViewers error: %v", err)
+		ch <- prometheus.NewInvalidMetric(concurrentViewersDesc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(concurrentViewersDesc, prometheus.GaugeValue, float64(total))
+
+	videos, err := c.live.ListVideoViewers(ctx, uuid.Nil, since, MaxLiveVideos)
+	if err != nil {
+		c.logger.Errorf("viewersCollector - ListVideoViewers error: %v", err)
+		ch <- prometheus.NewInvalidMetric(videoConcurrentViewersDesc, err)
+		return
+	}
+	for _, video := range videos {
+		ch <- prometheus.MustNewConstMetric(videoConcurrentViewersDesc, prometheus.GaugeValue, float64(video.Viewers), video.VideoID.String())
+	}
+
+	accounts, err := c.live.ListAccountViewers(ctx, since, MaxLiveVideos)
+	if err != nil {
+		c.logger.Errorf("viewersCollector - ListAccountViewers error: %v", err)
+		ch <- prometheus.NewInvalidMetric(accountConcurrentViewersDesc, err)
+		return
+	}
+	for _, account := range accounts {
+		ch <- prometheus.MustNewConstMetric(accountConcurrentViewersDesc, prometheus.GaugeValue, float64(account.Viewers), account.UserID.String())
+	}
+}
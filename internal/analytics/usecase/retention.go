@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/analytics"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
+)
+
+const (
+	// DefaultPruneInterval is used when the analytics config does not set one
+	DefaultPruneInterval = 24 * time.Hour
+	// PartitionsAhead is how many months of partitions are created in advance
+	// so new rows never land in the default partition
+	PartitionsAhead = 2
+)
+
+// retentionTable is a monthly partitioned analytics table and how many months
+// of it are kept
+type retentionTable struct {
+	name   string
+	column string
+	months int
+}
+
+// RetentionJob keeps the analytics tables from growing unbounded. It creates
+// upcoming monthly partitions and drops the ones past their retention.
+type RetentionJob struct {
+	repo   analytics.Repository
+	cfg    config.AnalyticsConfig
+	logger logger.Logger
+}
+
+// NewRetentionJob creates a retention job for the configured policy
+func NewRetentionJob(repo analytics.Repository, cfg config.AnalyticsConfig, log logger.Logger) *RetentionJob {
+	return &RetentionJob{
+		repo:   repo,
+		cfg:    cfg,
+		logger: log,
+	}
+}
+
+// Run prunes once immediately and then every prune interval until ctx is done
+func (j *RetentionJob) Run(ctx context.Context) {
+	interval := time.Duration(j.cfg.PruneInterval) * time.Second
+	if interval <= 0 {
+		interval = DefaultPruneInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		j.Prune(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Prune applies the retention policy to every analytics table. Failures are
+// logged and retried on the next run.
+func (j *RetentionJob) Prune(ctx context.Context) {
+	now := time.Now().UTC()
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	for _, table := range j.tables() {
+		if err := j.repo.CreateMonthlyPartitions(ctx, table.name, thisMonth, thisMonth.AddDate(0, PartitionsAhead, 0)); err != nil {
+			j.logger.Errorf("Prune - failed to create partitions of %s: %v", table.name, err)
+		}
+
+		if table.months <= 0 {
+			continue
+		}
+		cutoff := now.AddDate(0, -table.months, 0)
+
+		dropped, err := j.repo.DropPartitionsBefore(ctx, table.name, cutoff)
+		if err != nil {
+			j.logger.Errorf("Prune - failed to drop partitions of %s: %v", table.name, err)
+			continue
+		}
+
+		deleted, err := j.repo.DeleteRowsBefore(ctx, table.name, table.column, cutoff)
+		if err != nil {
+			j.logger.Errorf("Prune - failed to delete expired rows of %s: %v", table.name, err)
+			continue
+		}
+
+		if len(dropped) > 0 || deleted > 0 {
+			j.logger.Infof("Pruned %s older than %s: dropped partitions %v and %d rows", table.name, cutoff.Format("2006-01-02"), dropped, deleted)
+		}
+	}
+}
+
+func (j *RetentionJob) tables() []retentionTable {
+	return []retentionTable{
+		{name: "video_views", column: "timestamp", months: j.cfg.ViewRetentionMonths},
+		{name: "video_watch_sessions", column: "start_time", months: j.cfg.SessionRetentionMonths},
+		{name: "video_heartbeats", column: "timestamp", months: j.cfg.HeartbeatRetentionMonths},
+		{name: "video_qoe_beacons", column: "timestamp", months: j.cfg.QoERetentionMonths},
+	}
+}
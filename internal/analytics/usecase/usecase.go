@@ -0,0 +1,650 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/analytics"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/geoip"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/useragent"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultHeatmapBuckets splits videos into 5% slices for the account heatmap
+	DefaultHeatmapBuckets = 20
+	// DefaultTimeSeriesBuckets is how many buckets a time series without a
+	// start date covers
+	DefaultTimeSeriesBuckets = 30
+	// MaxTimeSeriesBuckets bounds the range of a time series
+	MaxTimeSeriesBuckets = 1000
+	// DefaultSummaryPeriod is the range of the account summary's trends and
+	// breakdowns when no start date is given
+	DefaultSummaryPeriod = 30 * 24 * time.Hour
+	// SummaryBreakdownLimit is how many folders, tags and referrers the
+	// account summary lists
+	SummaryBreakdownLimit = 10
+	// MaxQoEStartupTime is the longest startup time a QoE beacon may report;
+	// longer ones are not plausible and would skew the averages
+	MaxQoEStartupTime = 5 * time.Minute
+)
+
+type analyticsUC struct {
+	cfg    *config.Config
+	repo   analytics.Repository
+	live   analytics.LiveRepository
+	geo    geoip.Resolver
+	store  analytics.ExportStore
+	views  *ViewBuffer
+	logger logger.Logger
+}
+
+// NewAnalyticsUseCase creates a new analytics use case. Concurrent viewers
+// are tracked in live, background exports are kept in store and views are
+// written through the views buffer.
+func NewAnalyticsUseCase(cfg *config.Config, repo analytics.Repository, live analytics.LiveRepository, geo geoip.Resolver, store analytics.ExportStore, views *ViewBuffer, log logger.Logger) analytics.UseCase {
+	return &analyticsUC{
+		cfg:    cfg,
+		repo:   repo,
+		live:   live,
+		geo:    geo,
+		store:  store,
+		views:  views,
+		logger: log,
+	}
+}
+
+// CheckVideoAccess makes sure the viewer in ctx may watch the video, so
+// playback of private videos by other users cannot be recorded
+func (a *analyticsUC) CheckVideoAccess(ctx context.Context, videoID uuid.UUID, shareToken string) error {
+	viewerID := uuid.Nil
+	if user, err := utils.GetUserFromCtx(ctx); err == nil {
+		viewerID = user.UserID
+	}
+
+	video, err := a.repo.GetVideoAccess(ctx, videoID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return analytics.ErrVideoAccessDenied
+		}
+		a.logger.Errorf("CheckVideoAccess - GetVideoAccess error: %v", err)
+		return fmt.Errorf("failed to check video access: %w", err)
+	}
+
+	if !video.CanView(viewerID, shareToken) {
+		return analytics.ErrVideoAccessDenied
+	}
+
+	return nil
+}
+
+// RecordVideoView enriches a view with location and device details and queues
+// it to be stored. It returns analytics.ErrViewBufferFull when views come in
+// faster than they are written.
+func (a *analyticsUC) RecordVideoView(ctx context.Context, view *models.VideoView) error {
+	if view.VideoID == uuid.Nil {
+		return fmt.Errorf("video id is required")
+	}
+
+	if view.Timestamp.IsZero() {
+		view.Timestamp = time.Now()
+	}
+
+	// Country may already be known from a CDN header
+	if view.Country == "" {
+		location := a.geo.Lookup(view.IP)
+		view.Country = location.Country
+		view.Region = location.Region
+	}
+
+	device := useragent.Parse(view.UserAgent)
+	view.DeviceType = device.DeviceType
+	view.Browser = device.Browser
+	view.OS = device.OS
+
+	if err := a.views.Add(ctx, view); err != nil {
+		a.logger.Warnf("RecordVideoView - failed to buffer view: %v", err)
+		return fmt.Errorf("failed to record video view: %w", err)
+	}
+
+	return nil
+}
+
+func (a *analyticsUC) GetVideoViews(ctx context.Context, videoID uuid.UUID, filter *models.AnalyticsFilter) ([]*models.VideoView, error) {
+	if err := a.checkVideoOwner(ctx, videoID); err != nil {
+		return nil, err
+	}
+
+	views, err := a.repo.GetVideoViews(ctx, videoID, filter)
+	if err != nil {
+		a.logger.Errorf("GetVideoViews - GetVideoViews error: %v", err)
+		return nil, fmt.Errorf("failed to get video views: %w", err)
+	}
+
+	return views, nil
+}
+
+func (a *analyticsUC) GetGeoBreakdown(ctx context.Context, videoID uuid.UUID, filter *models.AnalyticsFilter) ([]*models.GeoBreakdown, error) {
+	if err := a.checkVideoOwner(ctx, videoID); err != nil {
+		return nil, err
+	}
+
+	breakdown, err := a.repo.GetGeoBreakdown(ctx, videoID, filter)
+	if err != nil {
+		a.logger.Errorf("GetGeoBreakdown - GetGeoBreakdown error: %v", err)
+		return nil, fmt.Errorf("failed to get geo breakdown: %w", err)
+	}
+
+	return breakdown, nil
+}
+
+// GetVideoTimeSeries buckets a metric of the views of a video by interval.
+// The range defaults to the DefaultTimeSeriesBuckets buckets up to now and
+// may span at most MaxTimeSeriesBuckets.
+func (a *analyticsUC) GetVideoTimeSeries(ctx context.Context, videoID uuid.UUID, metric models.TimeSeriesMetric, interval models.TimeSeriesInterval, filter *models.AnalyticsFilter) (*models.TimeSeries, error) {
+	if err := a.checkVideoOwner(ctx, videoID); err != nil {
+		return nil, err
+	}
+
+	if metric == "" {
+		metric = models.MetricViews
+	}
+	if interval == "" {
+		interval = models.IntervalDay
+	}
+	width := interval.Duration()
+	if width == 0 {
+		return nil, fmt.Errorf("%w: unknown interval %q", analytics.ErrInvalidTimeSeries, interval)
+	}
+	switch metric {
+	case models.MetricViews, models.MetricUniqueViewers, models.MetricWatchTime:
+	default:
+		return nil, fmt.Errorf("%w: unknown metric %q", analytics.ErrInvalidTimeSeries, metric)
+	}
+
+	to := filter.TimeRange.EndDate
+	if to.IsZero() {
+		to = time.Now()
+	}
+	from := filter.TimeRange.StartDate
+	if from.IsZero() {
+		from = to.Add(-DefaultTimeSeriesBuckets * width)
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: start must be before end", analytics.ErrInvalidTimeSeries)
+	}
+	if to.Sub(from) > MaxTimeSeriesBuckets*width {
+		return nil, fmt.Errorf("%w: range is limited to %d buckets", analytics.ErrInvalidTimeSeries, MaxTimeSeriesBuckets)
+	}
+
+	points, err := a.repo.GetViewTimeSeries(ctx, videoID, metric, interval, from, to)
+	if err != nil {
+		a.logger.Errorf("GetVideoTimeSeries - GetViewTimeSeries error: %v", err)
+		return nil, fmt.Errorf("failed to get time series: %w", err)
+	}
+
+	return &models.TimeSeries{
+		VideoID:  videoID,
+		Metric:   metric,
+		Interval: interval,
+		From:     from,
+		To:       to,
+		Points:   points,
+	}, nil
+}
+
+func (a *analyticsUC) GetDeviceBreakdown(ctx context.Context, videoID uuid.UUID, filter *models.AnalyticsFilter) (*models.DeviceBreakdown, error) {
+	if err := a.checkVideoOwner(ctx, videoID); err != nil {
+		return nil, err
+	}
+
+	devices, err := a.repo.GetDeviceBreakdown(ctx, videoID, "device_type", filter)
+	if err != nil {
+		a.logger.Errorf("GetDeviceBreakdown - device_type error: %v", err)
+		return nil, fmt.Errorf("failed to get device breakdown: %w", err)
+	}
+
+	browsers, err := a.repo.GetDeviceBreakdown(ctx, videoID, "browser", filter)
+	if err != nil {
+		a.logger.Errorf("GetDeviceBreakdown - browser error: %v", err)
+		return nil, fmt.Errorf("failed to get browser breakdown: %w", err)
+	}
+
+	operatingSystems, err := a.repo.GetDeviceBreakdown(ctx, videoID, "os", filter)
+	if err != nil {
+		a.logger.Errorf("GetDeviceBreakdown - os error: %v", err)
+		return nil, fmt.Errorf("failed to get os breakdown: %w", err)
+	}
+
+	return &models.DeviceBreakdown{
+		Devices:          devices,
+		Browsers:         browsers,
+		OperatingSystems: operatingSystems,
+	}, nil
+}
+
+func (a *analyticsUC) StartWatchSession(ctx context.Context, videoID, userID uuid.UUID, sessionID string) (*models.VideoWatchSession, error) {
+	if videoID == uuid.Nil {
+		return nil, fmt.Errorf("video id is required")
+	}
+
+	now := time.Now()
+	session := &models.VideoWatchSession{
+		VideoID:   videoID,
+		UserID:    userID,
+		SessionID: sessionID,
+		StartTime: now,
+		EndTime:   now,
+	}
+
+	if err := a.repo.CreateWatchSession(ctx, session); err != nil {
+		a.logger.Errorf("StartWatchSession - CreateWatchSession error: %v", err)
+		return nil, fmt.Errorf("failed to start watch session: %w", err)
+	}
+
+	return session, nil
+}
+
+// EndWatchSession records the end of a watch session of videoID. Sessions of
+// other videos are left alone.
+func (a *analyticsUC) EndWatchSession(ctx context.Context, videoID uuid.UUID, sessionID string, watchDuration int64, completed bool) error {
+	session := &models.VideoWatchSession{
+		VideoID:       videoID,
+		SessionID:     sessionID,
+		EndTime:       time.Now(),
+		WatchDuration: watchDuration,
+		Completed:     completed,
+	}
+
+	if err := a.repo.UpdateWatchSession(ctx, session); err != nil {
+		a.logger.Errorf("EndWatchSession - UpdateWatchSession error: %v", err)
+		return fmt.Errorf("failed to end watch session: %w", err)
+	}
+
+	return nil
+}
+
+// RecordHeartbeat stores a playback position update for a watch session
+func (a *analyticsUC) RecordHeartbeat(ctx context.Context, heartbeat *models.VideoHeartbeat) error {
+	if heartbeat.VideoID == uuid.Nil {
+		return fmt.Errorf("video id is required")
+	}
+	if heartbeat.SessionID == "" {
+		return fmt.Errorf("session id is required")
+	}
+	if heartbeat.Position < 0 {
+		return fmt.Errorf("position cannot be negative")
+	}
+
+	if heartbeat.Timestamp.IsZero() {
+		heartbeat.Timestamp = time.Now()
+	}
+
+	if err := a.repo.CreateHeartbeat(ctx, heartbeat); err != nil {
+		a.logger.Errorf("RecordHeartbeat - CreateHeartbeat error: %v", err)
+		return fmt.Errorf("failed to record heartbeat: %w", err)
+	}
+
+	// The concurrent viewer counts are best effort and never fail a heartbeat
+	_ = a.markViewer(ctx, heartbeat.VideoID, heartbeat.SessionID)
+
+	return nil
+}
+
+// GetRetentionCurve returns how many sessions watched each bucketSize-second
+// bucket of a video, along with that count as a share of all sessions
+func (a *analyticsUC) GetRetentionCurve(ctx context.Context, videoID uuid.UUID, bucketSize int, filter *models.AnalyticsFilter) (*models.RetentionCurve, error) {
+	if err := a.checkVideoOwner(ctx, videoID); err != nil {
+		return nil, err
+	}
+
+	if bucketSize < 1 {
+		bucketSize = 1
+	}
+
+	totalSessions, err := a.repo.GetHeartbeatSessionCount(ctx, videoID, filter)
+	if err != nil {
+		a.logger.Errorf("GetRetentionCurve - GetHeartbeatSessionCount error: %v", err)
+		return nil, fmt.Errorf("failed to get session count: %w", err)
+	}
+
+	points, err := a.repo.GetRetention(ctx, videoID, bucketSize, filter)
+	if err != nil {
+		a.logger.Errorf("GetRetentionCurve - GetRetention error: %v", err)
+		return nil, fmt.Errorf("failed to get retention: %w", err)
+	}
+
+	for _, point := range points {
+		if totalSessions > 0 {
+			point.Retention = float64(point.Viewers) / float64(totalSessions) * 100
+		}
+	}
+
+	return &models.RetentionCurve{
+		VideoID:       videoID,
+		BucketSize:    bucketSize,
+		TotalSessions: totalSessions,
+		Points:        points,
+	}, nil
+}
+
+// RecordQoEBeacon stores a player's playback quality report
+func (a *analyticsUC) RecordQoEBeacon(ctx context.Context, beacon *models.QoEBeacon) error {
+	if beacon.VideoID == uuid.Nil {
+		return fmt.Errorf("%w: video id is required", analytics.ErrInvalidQoEBeacon)
+	}
+	if beacon.SessionID == "" {
+		return fmt.Errorf("%w: session id is required", analytics.ErrInvalidQoEBeacon)
+	}
+	if beacon.PlaybackVersion < 0 || beacon.Bitrate < 0 || beacon.RebufferCount < 0 ||
+		beacon.RebufferDuration < 0 || beacon.BitrateSwitches < 0 || beacon.PlayTime < 0 {
+		return fmt.Errorf("%w: values cannot be negative", analytics.ErrInvalidQoEBeacon)
+	}
+	if beacon.StartupTime != nil && (*beacon.StartupTime < 0 || *beacon.StartupTime > MaxQoEStartupTime.Milliseconds()) {
+		return fmt.Errorf("%w: startup time must be between 0 and %s", analytics.ErrInvalidQoEBeacon, MaxQoEStartupTime)
+	}
+	if len(beacon.Rendition) > 16 {
+		return fmt.Errorf("%w: unknown rendition %q", analytics.ErrInvalidQoEBeacon, beacon.Rendition)
+	}
+
+	if beacon.Timestamp.IsZero() {
+		beacon.Timestamp = time.Now()
+	}
+
+	if err := a.repo.CreateQoEBeacon(ctx, beacon); err != nil {
+		a.logger.Errorf("RecordQoEBeacon - CreateQoEBeacon error: %v", err)
+		return fmt.Errorf("failed to record qoe beacon: %w", err)
+	}
+
+	return nil
+}
+
+// GetVideoQoE aggregates the playback quality of a video overall, per
+// playback version and per rendition, so the effect of changes to its
+// encoding ladder on real playback can be compared
+func (a *analyticsUC) GetVideoQoE(ctx context.Context, videoID uuid.UUID, filter *models.AnalyticsFilter) (*models.VideoQoE, error) {
+	if err := a.checkVideoOwner(ctx, videoID); err != nil {
+		return nil, err
+	}
+
+	overall, err := a.repo.GetQoEMetrics(ctx, videoID, "", filter)
+	if err != nil {
+		a.logger.Errorf("GetVideoQoE - overall error: %v", err)
+		return nil, fmt.Errorf("failed to get qoe metrics: %w", err)
+	}
+
+	byVersion, err := a.repo.GetQoEMetrics(ctx, videoID, "playback_version", filter)
+	if err != nil {
+		a.logger.Errorf("GetVideoQoE - playback_version error: %v", err)
+		return nil, fmt.Errorf("failed to get qoe metrics by version: %w", err)
+	}
+
+	byRendition, err := a.repo.GetQoEMetrics(ctx, videoID, "rendition", filter)
+	if err != nil {
+		a.logger.Errorf("GetVideoQoE - rendition error: %v", err)
+		return nil, fmt.Errorf("failed to get qoe metrics by rendition: %w", err)
+	}
+
+	qoe := &models.VideoQoE{
+		VideoID:     videoID,
+		Overall:     &models.QoEMetrics{},
+		ByVersion:   byVersion,
+		ByRendition: byRendition,
+	}
+	if len(overall) > 0 {
+		qoe.Overall = overall[0]
+	}
+	groups := append([]*models.QoEMetrics{qoe.Overall}, byVersion...)
+	for _, metrics := range append(groups, byRendition...) {
+		if total := metrics.PlayTime + metrics.RebufferDuration; total > 0 {
+			metrics.RebufferRatio = float64(metrics.RebufferDuration) / float64(total) * 100
+		}
+	}
+
+	return qoe, nil
+}
+
+// GetAccountHeatmap shows where viewers drop off relative to video length
+// across all videos of a user. Each video is split into buckets equal
+// slices so positions in a short clip and a long film line up; every bucket
+// is returned, including those no session reached
+func (a *analyticsUC) GetAccountHeatmap(ctx context.Context, userID uuid.UUID, buckets int, filter *models.AnalyticsFilter) (*models.AccountHeatmap, error) {
+	if buckets < 1 {
+		buckets = DefaultHeatmapBuckets
+	}
+
+	heatmap, err := a.repo.GetAccountHeartbeatTotals(ctx, userID, filter)
+	if err != nil {
+		a.logger.Errorf("GetAccountHeatmap - GetAccountHeartbeatTotals error: %v", err)
+		return nil, fmt.Errorf("failed to get session count: %w", err)
+	}
+
+	counts, err := a.repo.GetAccountHeatmap(ctx, userID, buckets, filter)
+	if err != nil {
+		a.logger.Errorf("GetAccountHeatmap - GetAccountHeatmap error: %v", err)
+		return nil, fmt.Errorf("failed to get heatmap: %w", err)
+	}
+
+	viewers := make(map[int]int64, len(counts))
+	for _, count := range counts {
+		viewers[count.Bucket] = count.Viewers
+	}
+
+	heatmap.UserID = userID
+	heatmap.Buckets = buckets
+	heatmap.Points = make([]*models.HeatmapBucket, buckets)
+	width := 100 / float64(buckets)
+	for i := range heatmap.Points {
+		point := &models.HeatmapBucket{
+			Bucket:       i,
+			StartPercent: float64(i) * width,
+			EndPercent:   float64(i+1) * width,
+			Viewers:      viewers[i],
+		}
+		if heatmap.TotalSessions > 0 {
+			point.Retention = float64(point.Viewers) / float64(heatmap.TotalSessions) * 100
+		}
+		if i > 0 {
+			point.DropOff = heatmap.Points[i-1].Retention - point.Retention
+			if heatmap.SteepestDropOff == nil || point.DropOff > heatmap.SteepestDropOff.DropOff {
+				heatmap.SteepestDropOff = point
+			}
+		}
+		heatmap.Points[i] = point
+	}
+
+	return heatmap, nil
+}
+
+// CalculateEngagement recomputes and stores engagement metrics for a video
+func (a *analyticsUC) CalculateEngagement(ctx context.Context, videoID uuid.UUID) (*models.VideoEngagement, error) {
+	totalViews, err := a.repo.GetTotalVideoViews(ctx, videoID)
+	if err != nil {
+		a.logger.Errorf("CalculateEngagement - GetTotalVideoViews error: %v", err)
+		return nil, fmt.Errorf("failed to get total views: %w", err)
+	}
+
+	uniqueViews, err := a.repo.GetUniqueVideoViews(ctx, videoID)
+	if err != nil {
+		a.logger.Errorf("CalculateEngagement - GetUniqueVideoViews error: %v", err)
+		return nil, fmt.Errorf("failed to get unique views: %w", err)
+	}
+
+	sessions, err := a.repo.GetWatchSessions(ctx, videoID, &models.AnalyticsFilter{VideoID: videoID})
+	if err != nil {
+		a.logger.Errorf("CalculateEngagement - GetWatchSessions error: %v", err)
+		return nil, fmt.Errorf("failed to get watch sessions: %w", err)
+	}
+
+	engagement := &models.VideoEngagement{
+		VideoID:          videoID,
+		TotalViews:       totalViews,
+		UniqueViews:      uniqueViews,
+		LastCalculatedAt: time.Now(),
+	}
+
+	var completedSessions int64
+	for _, session := range sessions {
+		engagement.TotalWatchTime += session.WatchDuration
+		if session.Completed {
+			completedSessions++
+		}
+	}
+
+	if len(sessions) > 0 {
+		engagement.AvgWatchTime = float64(engagement.TotalWatchTime) / float64(len(sessions))
+		engagement.CompletionRate = float64(completedSessions) / float64(len(sessions)) * 100
+	}
+
+	// Weighted towards completion; repeat viewing lowers the unique ratio
+	var uniqueRatio float64
+	if totalViews > 0 {
+		uniqueRatio = float64(uniqueViews) / float64(totalViews) * 100
+	}
+	engagement.EngagementScore = engagement.CompletionRate*0.6 + uniqueRatio*0.4
+
+	if err := a.repo.UpdateVideoEngagement(ctx, engagement); err != nil {
+		a.logger.Errorf("CalculateEngagement - UpdateVideoEngagement error: %v", err)
+		return nil, fmt.Errorf("failed to update engagement: %w", err)
+	}
+
+	return engagement, nil
+}
+
+func (a *analyticsUC) GetVideoEngagement(ctx context.Context, videoID uuid.UUID) (*models.VideoEngagement, error) {
+	if err := a.checkVideoOwner(ctx, videoID); err != nil {
+		return nil, err
+	}
+
+	engagement, err := a.repo.GetVideoEngagement(ctx, videoID)
+	if err != nil {
+		a.logger.Errorf("GetVideoEngagement - GetVideoEngagement error: %v", err)
+		return nil, fmt.Errorf("failed to get video engagement: %w", err)
+	}
+
+	return engagement, nil
+}
+
+func (a *analyticsUC) GetVideoPerformance(ctx context.Context, videoID uuid.UUID) (*models.VideoPerformance, error) {
+	if err := a.checkVideoOwner(ctx, videoID); err != nil {
+		return nil, err
+	}
+
+	performance, err := a.repo.GetVideoPerformance(ctx, videoID)
+	if err != nil {
+		a.logger.Errorf("GetVideoPerformance - GetVideoPerformance error: %v", err)
+		return nil, fmt.Errorf("failed to get video performance: %w", err)
+	}
+
+	return performance, nil
+}
+
+func (a *analyticsUC) GetTopPerformingVideos(ctx context.Context, userID uuid.UUID, limit int) ([]*models.VideoPerformance, error) {
+	videos, err := a.repo.GetTopPerformingVideos(ctx, userID, limit)
+	if err != nil {
+		a.logger.Errorf("GetTopPerformingVideos - GetTopPerformingVideos error: %v", err)
+		return nil, fmt.Errorf("failed to get top performing videos: %w", err)
+	}
+
+	return videos, nil
+}
+
+func (a *analyticsUC) GetRecentVideos(ctx context.Context, userID uuid.UUID, limit int) ([]*models.VideoPerformance, error) {
+	videos, err := a.repo.GetRecentVideos(ctx, userID, limit)
+	if err != nil {
+		a.logger.Errorf("GetRecentVideos - GetRecentVideos error: %v", err)
+		return nil, fmt.Errorf("failed to get recent videos: %w", err)
+	}
+
+	return videos, nil
+}
+
+// GetAnalyticsSummary returns the totals of a user's account, with trends and
+// breakdowns by folder, tag and referrer over the filter's time range. The
+// range defaults to the DefaultSummaryPeriod up to now.
+func (a *analyticsUC) GetAnalyticsSummary(ctx context.Context, userID uuid.UUID, filter *models.AnalyticsFilter) (*models.AnalyticsSummary, error) {
+	summary, err := a.repo.GetAnalyticsSummary(ctx, userID)
+	if err != nil {
+		a.logger.Errorf("GetAnalyticsSummary - GetAnalyticsSummary error: %v", err)
+		return nil, fmt.Errorf("failed to get analytics summary: %w", err)
+	}
+
+	viewers, err := a.live.CountAccountViewers(ctx, userID, time.Now().Add(-ViewerWindow))
+	if err != nil {
+		a.logger.Errorf("GetAnalyticsSummary - CountAccountViewers error: %v", err)
+	}
+	summary.ConcurrentViewers = viewers
+
+	// The end date of a filter is the last second of its day, while the
+	// summary queries exclude the end of their period
+	to := time.Now()
+	if !filter.TimeRange.EndDate.IsZero() {
+		to = filter.TimeRange.EndDate.Add(time.Second)
+	}
+	from := filter.TimeRange.StartDate
+	if from.IsZero() {
+		from = to.Add(-DefaultSummaryPeriod)
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("start date must be before end date")
+	}
+	summary.Period = models.AnalyticsTimeRange{StartDate: from, EndDate: to}
+
+	current, err := a.repo.GetViewTotals(ctx, userID, from, to)
+	if err != nil {
+		a.logger.Errorf("GetAnalyticsSummary - GetViewTotals error: %v", err)
+		return nil, fmt.Errorf("failed to get view totals: %w", err)
+	}
+	previous, err := a.repo.GetViewTotals(ctx, userID, from.Add(-to.Sub(from)), from)
+	if err != nil {
+		a.logger.Errorf("GetAnalyticsSummary - GetViewTotals error: %v", err)
+		return nil, fmt.Errorf("failed to get view totals: %w", err)
+	}
+	summary.Trends = models.SummaryTrends{
+		Views:         models.NewTrend(current.Views, previous.Views),
+		UniqueViewers: models.NewTrend(current.UniqueViewers, previous.UniqueViewers),
+		WatchTime:     models.NewTrend(current.WatchTime, previous.WatchTime),
+	}
+
+	if summary.ByFolder, err = a.repo.GetFolderBreakdown(ctx, userID, from, to, SummaryBreakdownLimit); err != nil {
+		a.logger.Errorf("GetAnalyticsSummary - GetFolderBreakdown error: %v", err)
+		return nil, fmt.Errorf("failed to get folder breakdown: %w", err)
+	}
+	if summary.ByTag, err = a.repo.GetTagBreakdown(ctx, userID, from, to, SummaryBreakdownLimit); err != nil {
+		a.logger.Errorf("GetAnalyticsSummary - GetTagBreakdown error: %v", err)
+		return nil, fmt.Errorf("failed to get tag breakdown: %w", err)
+	}
+	if summary.TopReferrers, err = a.repo.GetReferrerBreakdown(ctx, userID, from, to, SummaryBreakdownLimit); err != nil {
+		a.logger.Errorf("GetAnalyticsSummary - GetReferrerBreakdown error: %v", err)
+		return nil, fmt.Errorf("failed to get referrer breakdown: %w", err)
+	}
+
+	return summary, nil
+}
+
+func (a *analyticsUC) GetTotalVideos(ctx context.Context, userID uuid.UUID) (int64, error) {
+	total, err := a.repo.GetTotalVideos(ctx, userID)
+	if err != nil {
+		a.logger.Errorf("GetTotalVideos - GetTotalVideos error: %v", err)
+		return 0, fmt.Errorf("failed to get total videos: %w", err)
+	}
+
+	return total, nil
+}
+
+func (a *analyticsUC) GetTotalWatchTime(ctx context.Context, userID uuid.UUID) (int64, error) {
+	total, err := a.repo.GetTotalWatchTime(ctx, userID)
+	if err != nil {
+		a.logger.Errorf("GetTotalWatchTime - GetTotalWatchTime error: %v", err)
+		return 0, fmt.Errorf("failed to get total watch time: %w", err)
+	}
+
+	return total, nil
+}
@@ -0,0 +1,7 @@
+package audit
+
+import "github.com/labstack/echo/v4"
+
+type Handler interface {
+	ListAuditLogs() echo.HandlerFunc
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/audit"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type auditHandler struct {
+	auditUC audit.UseCase
+}
+
+func NewAuditHandler(auditUC audit.UseCase) audit.Handler {
+	return &auditHandler{auditUC: auditUC}
+}
+
+// ListAuditLogs pages through the audit log of the account, filtered by the
+// action, actor_id, from and to query parameters. Times are RFC 3339.
+func (h *auditHandler) ListAuditLogs() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		pagination, err := utils.GetPaginationFromCtx(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+
+		filter := &models.AuditLogFilter{Action: models.AuditAction(c.QueryParam("action"))}
+		if actorID := c.QueryParam("actor_id"); actorID != "" {
+			id, err := uuid.Parse(actorID)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid actor id"})
+			}
+			filter.ActorID = &id
+		}
+		if filter.From, err = parseOptionalTime(c.QueryParam("from")); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid from time"})
+		}
+		if filter.To, err = parseOptionalTime(c.QueryParam("to")); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid to time"})
+		}
+
+		logs, err := h.auditUC.List(c.Request().Context(), filter, pagination)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, logs)
+	}
+}
+
+// parseOptionalTime parses an RFC 3339 time, returning nil for an empty value
+func parseOptionalTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,16 @@
+package http
+
+import (
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/audit"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/middleware"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// MapAuditRoutes maps the audit log of the account, which only its user and
+// its admins can read
+func MapAuditRoutes(auditGroup *echo.Group, h audit.Handler, mw *middleware.MiddlewareManager) {
+	auditGroup.Use(mw.AuthSessionMiddleware)
+	auditGroup.Use(mw.AccountMiddleware)
+	auditGroup.GET("", h.ListAuditLogs(), mw.Authorize(models.PermissionAuditRead))
+}
@@ -0,0 +1,13 @@
+package audit
+
+import (
+	"context"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	Create(ctx context.Context, log *models.AuditLog) error
+	List(ctx context.Context, accountID uuid.UUID, filter *models.AuditLogFilter, pq *utils.Pagination) (*models.AuditLogList, error)
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/audit"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type auditRepo struct {
+	db *sqlx.DB
+}
+
+func NewAuditRepo(db *sqlx.DB) audit.Repository {
+	return &auditRepo{db: db}
+}
+
+func (a *auditRepo) Create(ctx context.Context, log *models.AuditLog) error {
+	payload := log.Payload
+	if len(payload) == 0 {
+		payload = []byte("{}")
+	}
+	if _, err := a.db.ExecContext(
+		ctx,
+		createAuditLogQuery,
+		log.AccountID,
+		log.ActorID,
+		log.ActorEmail,
+		log.Action,
+		log.ResourceID,
+		log.Method,
+		log.Path,
+		log.IP,
+		log.StatusCode,
+		payload,
+	); err != nil {
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+	return nil
+}
+
+// List pages through the audit log of an account, most recent first
+func (a *auditRepo) List(ctx context.Context, accountID uuid.UUID, filter *models.AuditLogFilter, pq *utils.Pagination) (*models.AuditLogList, error) {
+	args := []any{accountID, filter.Action, filter.ActorID, filter.From, filter.To}
+
+	var totalCount int
+	if err := a.db.GetContext(ctx, &totalCount, getTotalAuditLogsQuery, args...); err != nil {
+		return nil, fmt.Errorf("failed to get total audit logs count: %w", err)
+	}
+	logs := make([]*models.AuditLog, 0, pq.GetSize())
+	if totalCount > 0 {
+		args = append(args, pq.GetOffset(), pq.GetLimit())
+		if err := a.db.SelectContext(ctx, &logs, getAuditLogsQuery, args...); err != nil {
+			return nil, fmt.Errorf("failed to get audit logs: %w", err)
+		}
+	}
+	return &models.AuditLogList{
+		Logs:       logs,
+		TotalCount: utils.GetTotalPages(totalCount, pq.GetSize()),
+		Page:       pq.GetPage(),
+		PageSize:   pq.GetSize(),
+		HasMore:    utils.GetHasMore(pq.GetPage(), totalCount, pq.GetSize()),
+	}, nil
+}
@@ -0,0 +1,18 @@
+package repository
+
+const (
+	createAuditLogQuery = `INSERT INTO audit_logs (account_id, actor_id, actor_email, action, resource_id, method, path, ip, status_code, payload, created_at)
+						VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, now())`
+
+	// Zero filters are passed as NULL and match every entry
+	auditLogFilter = `WHERE account_id = $1
+						AND ($2 = '' OR action = $2)
+						AND ($3::uuid IS NULL OR actor_id = $3)
+						AND ($4::timestamptz IS NULL OR created_at >= $4)
+						AND ($5::timestamptz IS NULL OR created_at < $5)`
+	getTotalAuditLogsQuery = `SELECT COUNT(*) FROM audit_logs ` + auditLogFilter
+	getAuditLogsQuery      = `SELECT id, account_id, actor_id, actor_email, action, resource_id, method, path, ip, status_code, payload, created_at
+						FROM audit_logs ` + auditLogFilter + `
+						ORDER BY created_at DESC, id DESC
+						OFFSET $6 LIMIT $7`
+)
@@ -0,0 +1,12 @@
+package audit
+
+import (
+	"context"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+)
+
+type UseCase interface {
+	Record(ctx context.Context, log *models.AuditLog) error
+	List(ctx context.Context, filter *models.AuditLogFilter, pq *utils.Pagination) (*models.AuditLogList, error)
+}
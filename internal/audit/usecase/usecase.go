@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/audit"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+)
+
+type auditUC struct {
+	auditRepo audit.Repository
+	logger    logger.Logger
+}
+
+func NewAuditUseCase(auditRepo audit.Repository, log logger.Logger) audit.UseCase {
+	return &auditUC{
+		auditRepo: auditRepo,
+		logger:    log,
+	}
+}
+
+// Record stores an action in the audit log of the account in the context,
+// attributed to the user making the request
+func (a *auditUC) Record(ctx context.Context, log *models.AuditLog) error {
+	account, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get user from context: %w", err)
+	}
+	actor, err := utils.GetActorFromCtx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get actor from context: %w", err)
+	}
+	log.AccountID = account.UserID
+	log.ActorID = actor.UserID
+	log.ActorEmail = actor.Email
+
+	if err := a.auditRepo.Create(ctx, log); err != nil {
+		a.logger.Errorf("Record - Create error: %v", err)
+		return fmt.Errorf("failed to record audit log: %w", err)
+	}
+	return nil
+}
+
+// List pages through the audit log of the account in the context
+func (a *auditUC) List(ctx context.Context, filter *models.AuditLogFilter, pq *utils.Pagination) (*models.AuditLogList, error) {
+	account, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		a.logger.Errorf("List - failed to get user from context: %v", err)
+		return nil, fmt.Errorf("failed to get user from context: %w", err)
+	}
+	logs, err := a.auditRepo.List(ctx, account.UserID, filter, pq)
+	if err != nil {
+		a.logger.Errorf("List - List error: %v", err)
+		return nil, fmt.Errorf("failed to list audit logs: %w", err)
+	}
+	return logs, nil
+}
@@ -12,4 +12,17 @@ type Handler interface {
 	GetUserByID() echo.HandlerFunc
 	GenerateApiKey() echo.HandlerFunc
 	GetUserStorageStats() echo.HandlerFunc
+	AddMember() echo.HandlerFunc
+	UpdateMember() echo.HandlerFunc
+	RemoveMember() echo.HandlerFunc
+	ListMembers() echo.HandlerFunc
+	ListMemberAccounts() echo.HandlerFunc
+	ListSessions() echo.HandlerFunc
+	RevokeSession() echo.HandlerFunc
+	RevokeSessions() echo.HandlerFunc
+	OAuthProviders() echo.HandlerFunc
+	OAuthLogin() echo.HandlerFunc
+	OAuthCallback() echo.HandlerFunc
+	RefreshToken() echo.HandlerFunc
+	RevokeToken() echo.HandlerFunc
 }
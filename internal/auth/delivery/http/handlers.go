@@ -1,6 +1,7 @@
 package http
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -40,17 +41,15 @@ func (h *authHandler) Register() echo.HandlerFunc {
 		}
 
 		createdUser, err := h.authUc.Register(c.Request().Context(), user)
+		if errors.Is(err, auth.ErrSSORequired) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+		}
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
-		sess, err := h.sessUC.CreateSession(c.Request().Context(), &models.Session{
-			UserID: createdUser.User.UserID,
-		}, h.cfg.Session.Expire)
-		if err != nil {
+		if err := h.signIn(c, createdUser); err != nil {
 			return c.JSON(httpErrors.ErrorResponse(err))
 		}
-
-		c.SetCookie(utils.CreateSessionCookie(h.cfg, sess))
 		return c.JSON(http.StatusCreated, createdUser)
 	}
 }
@@ -80,26 +79,55 @@ func (h *authHandler) Login() echo.HandlerFunc {
 		}
 
 		userWithToken, err := h.authUc.Login(c.Request().Context(), user)
+		if errors.Is(err, auth.ErrSSORequired) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+		}
 		if err != nil {
 			return c.JSON(http.StatusUnauthorized, map[string]string{
 				"error": err.Error(),
 			})
 		}
 
-		// Set cookie for web clients
-		sess, err := h.sessUC.CreateSession(c.Request().Context(), &models.Session{
-			UserID: userWithToken.User.UserID,
-		}, h.cfg.Session.Expire)
-		if err != nil {
+		if err := h.signIn(c, userWithToken); err != nil {
 			return c.JSON(httpErrors.ErrorResponse(err))
 		}
 
-		c.SetCookie(utils.CreateSessionCookie(h.cfg, sess))
-
 		return c.JSON(http.StatusOK, userWithToken)
 	}
 }
 
+// signIn signs the user in on the requesting device. Web clients get a
+// session cookie and, with access tokens enabled, token clients a refresh
+// token along with the access token. Both belong to the one session of the
+// device.
+func (h *authHandler) signIn(c echo.Context, userWithToken *models.UserWithToken) error {
+	expire := h.cfg.Session.Expire
+	if !utils.SessionsEnabled(h.cfg) {
+		expire = utils.RefreshTokenExpire(h.cfg)
+	}
+	sess, err := h.sessUC.CreateSession(c.Request().Context(), &models.Session{
+		UserID:    userWithToken.User.UserID,
+		UserAgent: c.Request().UserAgent(),
+		IP:        c.RealIP(),
+	}, expire)
+	if err != nil {
+		return err
+	}
+
+	if utils.TokensEnabled(h.cfg) {
+		refreshToken, err := h.sessUC.IssueRefreshToken(c.Request().Context(), sess)
+		if err != nil {
+			return err
+		}
+		userWithToken.RefreshToken = refreshToken
+		userWithToken.ExpiresIn = int(utils.AccessTokenExpire(h.cfg).Seconds())
+	}
+	if utils.SessionsEnabled(h.cfg) {
+		c.SetCookie(utils.CreateSessionCookie(h.cfg, sess))
+	}
+	return nil
+}
+
 func (h *authHandler) GetMe() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		user, ok := c.Get("user").(*models.User)
@@ -119,6 +147,13 @@ func (h *authHandler) ResetPassword() echo.HandlerFunc {
 
 func (h *authHandler) Logout() echo.HandlerFunc {
 	return func(c echo.Context) error {
+		if sessCookie, err := c.Cookie(h.cfg.Session.Name); err == nil && sessCookie.Value != "" {
+			if err := h.sessUC.DeleteByID(c.Request().Context(), sessCookie.Value); err != nil {
+				h.logger.Errorf("Logout - DeleteByID error: %v", err)
+			}
+			c.SetCookie(utils.DeleteSessionCookie(h.cfg))
+		}
+
 		cookie := new(http.Cookie)
 		cookie.Name = "jwt-token"
 		cookie.Value = ""
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/auth"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+func (h *authHandler) AddMember() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		input := &models.AddMemberInput{}
+		if err := c.Bind(input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		if err := utils.ValidateStruct(c.Request().Context(), input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+
+		member, err := h.authUc.AddMember(c.Request().Context(), input)
+		if err != nil {
+			if errors.Is(err, auth.ErrAlreadyMember) {
+				return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+			}
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusCreated, member)
+	}
+}
+
+func (h *authHandler) UpdateMember() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		memberID, err := uuid.Parse(c.Param("member_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid member id"})
+		}
+		input := &models.UpdateMemberInput{}
+		if err := c.Bind(input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		if err := utils.ValidateStruct(c.Request().Context(), input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+
+		member, err := h.authUc.UpdateMember(c.Request().Context(), memberID, input)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "Member not found"})
+			}
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, member)
+	}
+}
+
+func (h *authHandler) RemoveMember() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		memberID, err := uuid.Parse(c.Param("member_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid member id"})
+		}
+		if err := h.authUc.RemoveMember(c.Request().Context(), memberID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "Member not found"})
+			}
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+func (h *authHandler) ListMembers() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		members, err := h.authUc.ListMembers(c.Request().Context())
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, members)
+	}
+}
+
+func (h *authHandler) ListMemberAccounts() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		accounts, err := h.authUc.ListMemberAccounts(c.Request().Context())
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, accounts)
+	}
+}
@@ -0,0 +1,118 @@
+package http
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/auth"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/httpErrors"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/oauth"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// oauthStateCookie keeps the provider, state and PKCE verifier of a sign
+	// in between the redirect to the provider and its callback
+	oauthStateCookie = "oauth-state"
+	// oauthStateExpire is how long users have to sign in at the provider
+	oauthStateExpire = 10 * time.Minute
+	oauthCookiePath  = "/api/v1/auth/oauth/"
+)
+
+// OAuthProviders lists the providers users can sign in with
+func (h *authHandler) OAuthProviders() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string][]string{"providers": h.authUc.OAuthProviders()})
+	}
+}
+
+// OAuthLogin redirects the browser to sign in at the provider
+func (h *authHandler) OAuthLogin() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		provider := c.Param("provider")
+		state, err := oauth.NewVerifier()
+		if err != nil {
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+		verifier, err := oauth.NewVerifier()
+		if err != nil {
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		url, err := h.authUc.OAuthAuthURL(provider, state, verifier)
+		if errors.Is(err, auth.ErrUnknownProvider) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		if err != nil {
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		c.SetCookie(&http.Cookie{
+			Name:     oauthStateCookie,
+			Value:    provider + "." + state + "." + verifier,
+			Path:     oauthCookiePath,
+			MaxAge:   int(oauthStateExpire.Seconds()),
+			Secure:   h.cfg.Cookie.Secure,
+			HttpOnly: true,
+			// Lax, as the provider's redirect back is a cross-site navigation
+			SameSite: http.SameSiteLaxMode,
+		})
+		return c.Redirect(http.StatusFound, url)
+	}
+}
+
+// OAuthCallback signs the user in once the provider redirects back, and
+// sends them to the configured success URL
+func (h *authHandler) OAuthCallback() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		provider := c.Param("provider")
+		if errParam := c.QueryParam("error"); errParam != "" {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Sign in was cancelled: " + errParam})
+		}
+
+		cookie, err := c.Cookie(oauthStateCookie)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Sign in expired, please try again"})
+		}
+		c.SetCookie(&http.Cookie{
+			Name:     oauthStateCookie,
+			Value:    "",
+			Path:     oauthCookiePath,
+			MaxAge:   -1,
+			Secure:   h.cfg.Cookie.Secure,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		parts := strings.SplitN(cookie.Value, ".", 3)
+		state := c.QueryParam("state")
+		if len(parts) != 3 || parts[0] != provider || state == "" ||
+			subtle.ConstantTimeCompare([]byte(parts[1]), []byte(state)) != 1 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid sign in state"})
+		}
+
+		userWithToken, err := h.authUc.OAuthLogin(c.Request().Context(), provider, c.QueryParam("code"), parts[2])
+		if err != nil {
+			switch {
+			case errors.Is(err, auth.ErrUnknownProvider):
+				return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+			case errors.Is(err, auth.ErrEmailNotVerified), errors.Is(err, oauth.ErrNoEmail):
+				return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+			}
+			h.logger.Errorf("OAuthCallback - OAuthLogin error: %v", err)
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Failed to sign in"})
+		}
+
+		if err := h.signIn(c, userWithToken); err != nil {
+			return c.JSON(httpErrors.ErrorResponse(err))
+		}
+
+		if h.cfg.OAuth.SuccessURL != "" {
+			return c.Redirect(http.StatusFound, h.cfg.OAuth.SuccessURL)
+		}
+		return c.JSON(http.StatusOK, userWithToken)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/auth"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/middleware"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
 	"github.com/labstack/echo/v4"
 )
 
@@ -11,11 +12,36 @@ func MapAuthRoutes(authGroup *echo.Group, h auth.Handler, mw *middleware.Middlew
 	authGroup.POST("/register", h.Register())
 	authGroup.POST("/login", h.Login())
 	authGroup.POST("/logout", h.Logout())
+
+	// Token clients renew their access token and log out with their refresh
+	// token
+	authGroup.POST("/token/refresh", h.RefreshToken())
+	authGroup.POST("/token/revoke", h.RevokeToken())
 	authGroup.GET("/:user_id", h.GetUserByID(), mw.OwnerOrAdminMiddleware())
+
+	// Sign in with Google or GitHub
+	authGroup.GET("/oauth", h.OAuthProviders())
+	authGroup.GET("/oauth/:provider", h.OAuthLogin())
+	authGroup.GET("/oauth/:provider/callback", h.OAuthCallback())
+
 	authGroup.Use(mw.AuthSessionMiddleware)
 	//authGroup.Use(mw.AuthJWTMiddleware(authUC, cfg))
 	authGroup.GET("/me", h.GetMe())
-	authGroup.PUT("/:user_id", h.Update(), mw.OwnerOrAdminMiddleware())
+	authGroup.PUT("/:user_id", h.Update(), mw.OwnerOrAdminMiddleware(), mw.Audit(models.AuditProfileUpdate))
 	authGroup.GET("/user/storage/stats", h.GetUserStorageStats())
+
+	// Devices the user is signed in on
+	authGroup.GET("/sessions", h.ListSessions())
+	authGroup.DELETE("/sessions", h.RevokeSessions(), mw.Audit(models.AuditSessionRevoke))
+	authGroup.DELETE("/sessions/:session_id", h.RevokeSession(), mw.Audit(models.AuditSessionRevoke))
+
+	// Members of an account, managed by its user or the members with a role
+	// that grants it
+	manageMembers := mw.Authorize(models.PermissionMembersManage)
+	authGroup.GET("/accounts", h.ListMemberAccounts())
+	authGroup.GET("/members", h.ListMembers(), mw.AccountMiddleware, manageMembers)
+	authGroup.POST("/members", h.AddMember(), mw.AccountMiddleware, manageMembers, mw.Audit(models.AuditMemberAdd))
+	authGroup.PUT("/members/:member_id", h.UpdateMember(), mw.AccountMiddleware, manageMembers, mw.Audit(models.AuditMemberUpdate))
+	authGroup.DELETE("/members/:member_id", h.RemoveMember(), mw.AccountMiddleware, manageMembers, mw.Audit(models.AuditMemberRemove))
 	//authGroup.DELETE("/:user_id", h.GetUserByID()))
 }
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/session"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// ListSessions lists the devices the user is signed in on
+func (h *authHandler) ListSessions() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, ok := c.Get("user").(*models.User)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized access"})
+		}
+		sessionID, _ := c.Get("sid").(string)
+		devices, err := h.sessUC.ListDevices(c.Request().Context(), user.UserID, sessionID)
+		if err != nil {
+			h.logger.Errorf("ListSessions - ListDevices error: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list sessions"})
+		}
+		return c.JSON(http.StatusOK, devices)
+	}
+}
+
+// RevokeSession signs the user out on one device. Revoking the current
+// session also clears its cookie.
+func (h *authHandler) RevokeSession() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, ok := c.Get("user").(*models.User)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized access"})
+		}
+		deviceID := c.Param("session_id")
+		sessionID, _ := c.Get("sid").(string)
+		current, _ := h.sessUC.GetSessionByID(c.Request().Context(), sessionID)
+		if err := h.sessUC.RevokeDevice(c.Request().Context(), user.UserID, deviceID); err != nil {
+			if errors.Is(err, session.ErrDeviceNotFound) {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "Session not found"})
+			}
+			h.logger.Errorf("RevokeSession - RevokeDevice error: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke session"})
+		}
+		if current != nil && current.DeviceID == deviceID {
+			c.SetCookie(utils.DeleteSessionCookie(h.cfg))
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// RevokeSessions signs the user out on every other device, or on every
+// device including this one with include_current=true
+func (h *authHandler) RevokeSessions() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, ok := c.Get("user").(*models.User)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized access"})
+		}
+		keep, _ := c.Get("sid").(string)
+		includeCurrent := c.QueryParam("include_current") == "true"
+		if includeCurrent {
+			keep = ""
+		}
+		revoked, err := h.sessUC.RevokeAllDevices(c.Request().Context(), user.UserID, keep)
+		if err != nil {
+			h.logger.Errorf("RevokeSessions - RevokeAllDevices error: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke sessions"})
+		}
+		if includeCurrent {
+			c.SetCookie(utils.DeleteSessionCookie(h.cfg))
+		}
+		return c.JSON(http.StatusOK, map[string]int{"revoked": revoked})
+	}
+}
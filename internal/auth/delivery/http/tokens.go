@@ -0,0 +1,87 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/session"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type refreshTokenInput struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// RefreshToken exchanges a refresh token for a new access token and refresh
+// token. The old refresh token cannot be used again.
+func (h *authHandler) RefreshToken() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !utils.TokensEnabled(h.cfg) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Access tokens are not enabled"})
+		}
+		var input refreshTokenInput
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+		}
+		if err := utils.ValidateStruct(c.Request().Context(), &input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+
+		refreshToken, sess, err := h.sessUC.RotateRefreshToken(c.Request().Context(), input.RefreshToken)
+		if err != nil {
+			if errors.Is(err, session.ErrRefreshTokenReused) {
+				h.logger.Warnf("RefreshToken - reused refresh token from %s, device signed out", c.RealIP())
+			}
+			if errors.Is(err, session.ErrInvalidRefreshToken) || errors.Is(err, session.ErrRefreshTokenReused) {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+			}
+			h.logger.Errorf("RefreshToken - RotateRefreshToken error: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to refresh token"})
+		}
+
+		user, err := h.authUc.GetByID(c.Request().Context(), sess.UserID)
+		if err != nil {
+			h.logger.Errorf("RefreshToken - GetByID error: %v", err)
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": session.ErrInvalidRefreshToken.Error()})
+		}
+		token, err := utils.GenerateJWTToken(user, h.cfg)
+		if err != nil {
+			h.logger.Errorf("RefreshToken - GenerateJWTToken error: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to refresh token"})
+		}
+
+		return c.JSON(http.StatusOK, &models.UserWithToken{
+			User:         user,
+			Token:        token,
+			RefreshToken: refreshToken,
+			ExpiresIn:    int(utils.AccessTokenExpire(h.cfg).Seconds()),
+		})
+	}
+}
+
+// RevokeToken signs out the device of a refresh token, the way token clients
+// log out
+func (h *authHandler) RevokeToken() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !utils.TokensEnabled(h.cfg) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Access tokens are not enabled"})
+		}
+		var input refreshTokenInput
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+		}
+		if err := utils.ValidateStruct(c.Request().Context(), &input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+
+		// Unknown tokens are signed out already; revoking is idempotent
+		if err := h.sessUC.RevokeRefreshToken(c.Request().Context(), input.RefreshToken); err != nil &&
+			!errors.Is(err, session.ErrInvalidRefreshToken) {
+			h.logger.Errorf("RevokeToken - RevokeRefreshToken error: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke token"})
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
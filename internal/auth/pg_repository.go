@@ -14,4 +14,12 @@ type Repository interface {
 	FindByEmail(ctx context.Context, user *models.User) (*models.User, error)
 	CreateApiKey(ctx context.Context, apiKey string, userID string) error
 	GetStorageUsage(ctx context.Context, userID uuid.UUID) (*models.StorageUsage, error)
+	AddMember(ctx context.Context, accountID, userID uuid.UUID, role models.Role) error
+	UpdateMember(ctx context.Context, accountID, userID uuid.UUID, role models.Role) error
+	RemoveMember(ctx context.Context, accountID, userID uuid.UUID) error
+	GetMember(ctx context.Context, accountID, userID uuid.UUID) (*models.AccountMember, error)
+	ListMembers(ctx context.Context, accountID uuid.UUID) ([]models.AccountMember, error)
+	ListMemberAccounts(ctx context.Context, userID uuid.UUID) ([]models.MemberAccount, error)
+	GetIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error)
+	SaveIdentity(ctx context.Context, identity *models.UserIdentity) error
 }
@@ -93,7 +93,7 @@ func (a *authRepo) FindByEmail(ctx context.Context, user *models.User) (*models.
 		getUserByEmail,
 		&user.Email,
 	).StructScan(u); err != nil {
-		return nil, fmt.Errorf("failed to get user :%v", err)
+		return nil, fmt.Errorf("failed to get user :%w", err)
 	}
 	return u, nil
 }
@@ -123,3 +123,79 @@ func (a *authRepo) GetStorageUsage(ctx context.Context, userID uuid.UUID) (*mode
 	}
 	return storageUsage, nil
 }
+
+func (a *authRepo) AddMember(ctx context.Context, accountID, userID uuid.UUID, role models.Role) error {
+	if _, err := a.db.ExecContext(ctx, addMemberQuery, accountID, userID, role); err != nil {
+		return fmt.Errorf("failed to add member: %w", err)
+	}
+	return nil
+}
+
+func (a *authRepo) UpdateMember(ctx context.Context, accountID, userID uuid.UUID, role models.Role) error {
+	result, err := a.db.ExecContext(ctx, updateMemberQuery, role, accountID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update member: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rowsaffected %v", err)
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (a *authRepo) RemoveMember(ctx context.Context, accountID, userID uuid.UUID) error {
+	result, err := a.db.ExecContext(ctx, removeMemberQuery, accountID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rowsaffected %v", err)
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (a *authRepo) GetMember(ctx context.Context, accountID, userID uuid.UUID) (*models.AccountMember, error) {
+	member := &models.AccountMember{}
+	if err := a.db.GetContext(ctx, member, getMemberQuery, accountID, userID); err != nil {
+		return nil, fmt.Errorf("failed to get member: %w", err)
+	}
+	return member, nil
+}
+
+func (a *authRepo) ListMembers(ctx context.Context, accountID uuid.UUID) ([]models.AccountMember, error) {
+	members := []models.AccountMember{}
+	if err := a.db.SelectContext(ctx, &members, listMembersQuery, accountID); err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+	return members, nil
+}
+
+func (a *authRepo) ListMemberAccounts(ctx context.Context, userID uuid.UUID) ([]models.MemberAccount, error) {
+	accounts := []models.MemberAccount{}
+	if err := a.db.SelectContext(ctx, &accounts, listMemberAccountsQuery, userID); err != nil {
+		return nil, fmt.Errorf("failed to list member accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+func (a *authRepo) GetIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	identity := &models.UserIdentity{}
+	if err := a.db.GetContext(ctx, identity, getIdentityQuery, provider, subject); err != nil {
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+	return identity, nil
+}
+
+func (a *authRepo) SaveIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	if err := a.db.GetContext(ctx, identity, saveIdentityQuery, identity.Provider, identity.Subject, identity.UserID, identity.Email); err != nil {
+		return fmt.Errorf("failed to save identity: %w", err)
+	}
+	return nil
+}
@@ -14,12 +14,36 @@ const (
 				`
 	deleteUserQuery = `DELETE FROM users WHERE user_id = $1`
 
-	getUserQuery = `SELECT user_id, fullname,username, email, role, created_at, updated_at  
+	getUserQuery = `SELECT user_id, fullname,username, email, role, plan, created_at, updated_at  
 					 FROM users 
 					 WHERE user_id = $1`
-	getUserByEmail = `SELECT user_id , fullname, username ,password, email, role, api_key, storage_quota_db, created_at, updated_at
+	getUserByEmail = `SELECT user_id , fullname, username ,password, email, role, api_key, storage_quota_db, plan, created_at, updated_at
 						FROM users WHERE email = $1`
 	//getTotalCount = "SELECT COUNT(id) FROM users WHERE first_name ILIKE '%' || $1 || '%' or last_name ILIKE '%' || $1 || '%' "
 	createApiKey         = "UPDATE users SET api_key = $1 WHERE user_id = $2"
 	getStorageUsageQuery = `SELECT user_id, SUM(file_size) as total_size FROM video_files WHERE user_id = $1 GROUP BY user_id`
+
+	addMemberQuery = `INSERT INTO account_members (account_id, user_id, role, created_at, updated_at)
+						VALUES ($1, $2, $3, now(), now())`
+	updateMemberQuery = `UPDATE account_members SET role = $1, updated_at = now()
+						WHERE account_id = $2 AND user_id = $3`
+	removeMemberQuery = `DELETE FROM account_members WHERE account_id = $1 AND user_id = $2`
+	getMemberQuery    = `SELECT m.account_id, m.user_id, u.email, u.username, m.role, m.created_at, m.updated_at
+						FROM account_members m JOIN users u ON u.user_id = m.user_id
+						WHERE m.account_id = $1 AND m.user_id = $2`
+	listMembersQuery = `SELECT m.account_id, m.user_id, u.email, u.username, m.role, m.created_at, m.updated_at
+						FROM account_members m JOIN users u ON u.user_id = m.user_id
+						WHERE m.account_id = $1
+						ORDER BY m.created_at`
+	getIdentityQuery = `SELECT provider, subject, user_id, email, created_at, last_login_at
+						FROM user_identities WHERE provider = $1 AND subject = $2`
+	saveIdentityQuery = `INSERT INTO user_identities (provider, subject, user_id, email, created_at, last_login_at)
+						VALUES ($1, $2, $3, $4, now(), now())
+						ON CONFLICT (provider, subject) DO UPDATE
+						SET email = EXCLUDED.email, last_login_at = now()
+						RETURNING provider, subject, user_id, email, created_at, last_login_at`
+	listMemberAccountsQuery = `SELECT m.account_id, u.email, u.username, m.role, m.created_at
+						FROM account_members m JOIN users u ON u.user_id = m.account_id
+						WHERE m.user_id = $1
+						ORDER BY m.created_at`
 )
@@ -2,10 +2,27 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
 	"github.com/google/uuid"
 )
 
+// ErrAlreadyMember is returned when a user is added to an account twice
+var ErrAlreadyMember = errors.New("user is already a member of this account")
+
+var (
+	// ErrUnknownProvider is returned for OAuth providers that are not
+	// configured
+	ErrUnknownProvider = errors.New("unknown oauth provider")
+	// ErrEmailNotVerified is returned when signing in with a provider account
+	// whose email the provider has not verified, which could otherwise be
+	// used to take over the user with that email
+	ErrEmailNotVerified = errors.New("email is not verified by the provider")
+	// ErrSSORequired is returned for password sign ins and registrations with
+	// an email of a domain that must sign in with a provider
+	ErrSSORequired = errors.New("this email must sign in with single sign-on")
+)
+
 type UseCase interface {
 	Register(ctx context.Context, user *models.User) (*models.UserWithToken, error)
 	Login(ctx context.Context, user *models.User) (*models.UserWithToken, error)
@@ -14,4 +31,13 @@ type UseCase interface {
 	GetByID(ctx context.Context, userID uuid.UUID) (*models.User, error)
 	GenerateApiKey(ctx context.Context, userID uuid.UUID) (string, error)
 	GetUserStorageStats(ctx context.Context) (*models.StorageUsage, error)
+	AddMember(ctx context.Context, input *models.AddMemberInput) (*models.AccountMember, error)
+	UpdateMember(ctx context.Context, memberID uuid.UUID, input *models.UpdateMemberInput) (*models.AccountMember, error)
+	RemoveMember(ctx context.Context, memberID uuid.UUID) error
+	ListMembers(ctx context.Context) ([]models.AccountMember, error)
+	ListMemberAccounts(ctx context.Context) ([]models.MemberAccount, error)
+	GetMemberRole(ctx context.Context, accountID, userID uuid.UUID) (models.Role, error)
+	OAuthProviders() []string
+	OAuthAuthURL(provider, state, verifier string) (string, error)
+	OAuthLogin(ctx context.Context, provider, code, verifier string) (*models.UserWithToken, error)
 }
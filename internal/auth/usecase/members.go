@@ -0,0 +1,124 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/auth"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+)
+
+// AddMember grants the user with the input's email a role on the account in
+// the context
+func (u *authUC) AddMember(ctx context.Context, input *models.AddMemberInput) (*models.AccountMember, error) {
+	account, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		u.logger.Errorf("AddMember - failed to get user from context: %v", err)
+		return nil, fmt.Errorf("failed to get user from context: %w", err)
+	}
+	if !models.IsMemberRole(input.Role) {
+		return nil, fmt.Errorf("invalid role: %s", input.Role)
+	}
+
+	user, err := u.authRepo.FindByEmail(ctx, &models.User{Email: input.Email})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("user with email %s does not exist", input.Email)
+		}
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	if user.UserID == account.UserID {
+		return nil, fmt.Errorf("an account cannot be its own member")
+	}
+	if _, err := u.authRepo.GetMember(ctx, account.UserID, user.UserID); err == nil {
+		return nil, auth.ErrAlreadyMember
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		u.logger.Errorf("AddMember - GetMember error: %v", err)
+		return nil, fmt.Errorf("failed to get member: %w", err)
+	}
+
+	if err := u.authRepo.AddMember(ctx, account.UserID, user.UserID, input.Role); err != nil {
+		u.logger.Errorf("AddMember - AddMember error: %v", err)
+		return nil, fmt.Errorf("failed to add member: %w", err)
+	}
+	return u.authRepo.GetMember(ctx, account.UserID, user.UserID)
+}
+
+// UpdateMember changes the role of a member of the account in the context
+func (u *authUC) UpdateMember(ctx context.Context, memberID uuid.UUID, input *models.UpdateMemberInput) (*models.AccountMember, error) {
+	account, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		u.logger.Errorf("UpdateMember - failed to get user from context: %v", err)
+		return nil, fmt.Errorf("failed to get user from context: %w", err)
+	}
+	if !models.IsMemberRole(input.Role) {
+		return nil, fmt.Errorf("invalid role: %s", input.Role)
+	}
+
+	if err := u.authRepo.UpdateMember(ctx, account.UserID, memberID, input.Role); err != nil {
+		u.logger.Errorf("UpdateMember - UpdateMember error: %v", err)
+		return nil, fmt.Errorf("failed to update member: %w", err)
+	}
+	return u.authRepo.GetMember(ctx, account.UserID, memberID)
+}
+
+// RemoveMember revokes the access of a member to the account in the context
+func (u *authUC) RemoveMember(ctx context.Context, memberID uuid.UUID) error {
+	account, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		u.logger.Errorf("RemoveMember - failed to get user from context: %v", err)
+		return fmt.Errorf("failed to get user from context: %w", err)
+	}
+	if err := u.authRepo.RemoveMember(ctx, account.UserID, memberID); err != nil {
+		u.logger.Errorf("RemoveMember - RemoveMember error: %v", err)
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+	return nil
+}
+
+// ListMembers lists the members of the account in the context
+func (u *authUC) ListMembers(ctx context.Context) ([]models.AccountMember, error) {
+	account, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		u.logger.Errorf("ListMembers - failed to get user from context: %v", err)
+		return nil, fmt.Errorf("failed to get user from context: %w", err)
+	}
+	members, err := u.authRepo.ListMembers(ctx, account.UserID)
+	if err != nil {
+		u.logger.Errorf("ListMembers - ListMembers error: %v", err)
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+	return members, nil
+}
+
+// ListMemberAccounts lists the accounts the signed in user is a member of
+func (u *authUC) ListMemberAccounts(ctx context.Context) ([]models.MemberAccount, error) {
+	user, err := utils.GetActorFromCtx(ctx)
+	if err != nil {
+		u.logger.Errorf("ListMemberAccounts - failed to get user from context: %v", err)
+		return nil, fmt.Errorf("failed to get user from context: %w", err)
+	}
+	accounts, err := u.authRepo.ListMemberAccounts(ctx, user.UserID)
+	if err != nil {
+		u.logger.Errorf("ListMemberAccounts - ListMemberAccounts error: %v", err)
+		return nil, fmt.Errorf("failed to list member accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+// GetMemberRole returns the role of a user on an account. A user holds
+// AdminRole on their own account.
+func (u *authUC) GetMemberRole(ctx context.Context, accountID, userID uuid.UUID) (models.Role, error) {
+	if accountID == userID {
+		return models.AdminRole, nil
+	}
+	member, err := u.authRepo.GetMember(ctx, accountID, userID)
+	if err != nil {
+		return "", err
+	}
+	return member.Role, nil
+}
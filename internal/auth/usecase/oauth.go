@@ -0,0 +1,171 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/auth"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/oauth"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+)
+
+// maxNameLength is the longest username and fullname users are created with
+const maxNameLength = 30
+
+// oauthProviders returns the providers enabled in cfg by name
+func oauthProviders(cfg config.OAuthConfig) map[string]*oauth.Provider {
+	redirectURL := func(name string) string {
+		return strings.TrimSuffix(cfg.RedirectBaseURL, "/") + "/api/v1/auth/oauth/" + name + "/callback"
+	}
+
+	providers := make(map[string]*oauth.Provider)
+	if cfg.Google.ClientID != "" {
+		providers["google"] = oauth.Google(cfg.Google.ClientID, cfg.Google.ClientSecret, redirectURL("google"))
+	}
+	if cfg.GitHub.ClientID != "" {
+		providers["github"] = oauth.GitHub(cfg.GitHub.ClientID, cfg.GitHub.ClientSecret, redirectURL("github"))
+	}
+	return providers
+}
+
+// OAuthProviders lists the names of the enabled providers
+func (u *authUC) OAuthProviders() []string {
+	names := make([]string, 0, len(u.providers))
+	for name := range u.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OAuthAuthURL is where the browser is sent to sign in with provider
+func (u *authUC) OAuthAuthURL(provider, state, verifier string) (string, error) {
+	p, ok := u.providers[provider]
+	if !ok {
+		return "", auth.ErrUnknownProvider
+	}
+	return p.AuthCodeURL(state, verifier), nil
+}
+
+// OAuthLogin signs in the user of the provider account the code was issued
+// for. Accounts signing in for the first time are linked to the user with
+// their email, or a new user when there is none, as long as the provider
+// verified the email.
+func (u *authUC) OAuthLogin(ctx context.Context, provider, code, verifier string) (*models.UserWithToken, error) {
+	p, ok := u.providers[provider]
+	if !ok {
+		return nil, auth.ErrUnknownProvider
+	}
+
+	token, err := p.Exchange(ctx, code, verifier)
+	if err != nil {
+		u.logger.Errorf("OAuthLogin - %s exchange error: %v", provider, err)
+		return nil, err
+	}
+	identity, err := p.Identity(ctx, token)
+	if err != nil {
+		u.logger.Errorf("OAuthLogin - %s identity error: %v", provider, err)
+		return nil, err
+	}
+
+	user, err := u.oauthUser(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.authRepo.SaveIdentity(ctx, &models.UserIdentity{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		UserID:   user.UserID,
+		Email:    strings.ToLower(identity.Email),
+	}); err != nil {
+		return nil, err
+	}
+
+	user.SanitizePassword()
+	jwtToken, err := utils.GenerateJWTToken(user, u.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate jwt token: %v", err)
+	}
+	return &models.UserWithToken{
+		User:  user,
+		Token: jwtToken,
+	}, nil
+}
+
+// oauthUser returns the user identity is linked to, linking it first when
+// it signs in for the first time
+func (u *authUC) oauthUser(ctx context.Context, identity *oauth.Identity) (*models.User, error) {
+	linked, err := u.authRepo.GetIdentity(ctx, identity.Provider, identity.Subject)
+	if err == nil {
+		return u.authRepo.GetByID(ctx, linked.UserID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// Anyone can add an unverified email to their provider account, linking
+	// on it would hand them the user with that email
+	if !identity.EmailVerified {
+		return nil, auth.ErrEmailNotVerified
+	}
+
+	existUser, err := u.authRepo.FindByEmail(ctx, &models.User{Email: strings.ToLower(identity.Email)})
+	if err == nil {
+		return existUser, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// Users created here sign in with the provider only; their password is
+	// random and never shown
+	password, err := oauth.NewVerifier()
+	if err != nil {
+		return nil, err
+	}
+	name := identity.Name
+	if name == "" {
+		name = strings.Split(identity.Email, "@")[0]
+	}
+	if runes := []rune(name); len(runes) > maxNameLength {
+		name = string(runes[:maxNameLength])
+	}
+	user := &models.User{
+		Username: name,
+		Email:    identity.Email,
+		Password: password,
+		Fullname: name,
+	}
+	if err = user.PrepareCreate(); err != nil {
+		return nil, fmt.Errorf("failed to prepare user for create: %v", err)
+	}
+	user.APIkey = uuid.New().String()
+	createUser, err := u.authRepo.Register(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %v", err)
+	}
+	return createUser, nil
+}
+
+// ssoRequired reports whether users with email must sign in with a provider
+func (u *authUC) ssoRequired(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(strings.TrimSpace(email[at+1:]))
+	for _, enforced := range u.cfg.OAuth.EnforcedDomains {
+		if strings.EqualFold(domain, strings.TrimSpace(enforced)) {
+			return true
+		}
+	}
+	return false
+}
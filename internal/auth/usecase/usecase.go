@@ -10,6 +10,7 @@ import (
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/oauth"
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
 	"github.com/google/uuid"
 )
@@ -20,20 +21,25 @@ const (
 )
 
 type authUC struct {
-	cfg      *config.Config
-	authRepo auth.Repository
-	logger   logger.Logger
+	cfg       *config.Config
+	authRepo  auth.Repository
+	logger    logger.Logger
+	providers map[string]*oauth.Provider
 }
 
 func NewAuthUseCase(cfg *config.Config, authRepo auth.Repository, log logger.Logger) auth.UseCase {
 	return &authUC{
-		cfg:      cfg,
-		authRepo: authRepo,
-		logger:   log,
+		cfg:       cfg,
+		authRepo:  authRepo,
+		logger:    log,
+		providers: oauthProviders(cfg.OAuth),
 	}
 }
 
 func (u *authUC) Register(ctx context.Context, user *models.User) (*models.UserWithToken, error) {
+	if u.ssoRequired(user.Email) {
+		return nil, auth.ErrSSORequired
+	}
 	existUser, err := u.authRepo.FindByEmail(ctx, user)
 	if existUser != nil || err == nil {
 		return nil, fmt.Errorf("user with email %s already exists", user.Email)
@@ -61,6 +67,9 @@ func (u *authUC) Register(ctx context.Context, user *models.User) (*models.UserW
 }
 
 func (u *authUC) Login(ctx context.Context, user *models.User) (*models.UserWithToken, error) {
+	if u.ssoRequired(user.Email) {
+		return nil, auth.ErrSSORequired
+	}
 	existUser, err := u.authRepo.FindByEmail(ctx, user)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -7,15 +7,31 @@ import (
 )
 
 type Config struct {
-	Server    ServerConfig
-	Postgres  DBConfig
-	Redis     RedisConfig
-	S3        S3Config
-	Session   Session
-	Cookie    Cookie
-	Logger    Logger
-	Worker    WorkerConfig
-	Container ContainerConfig
+	Server     ServerConfig
+	Postgres   DBConfig
+	Redis      RedisConfig
+	S3         S3Config
+	Session    Session
+	Cookie     Cookie
+	Logger     Logger
+	Worker     WorkerConfig
+	Container  ContainerConfig
+	Analytics  AnalyticsConfig
+	Encryption EncryptionConfig
+	Captions   CaptionConfig
+	Watermark  WatermarkConfig
+	DRM        DRMConfig
+	Ingest     IngestConfig
+	Queue      QueueConfig
+	MTLS       MTLSConfig
+	Playback   PlaybackConfig
+	Trash      TrashConfig
+	RateLimit  RateLimitConfig
+	Pricing    PricingConfig
+	Billing    BillingConfig
+	OAuth      OAuthConfig
+	Auth       AuthConfig
+	Scan       ScanConfig
 	// RabbitMQ  RabbitMQConfig
 }
 
@@ -24,6 +40,15 @@ type ServerConfig struct {
 	Port         string
 	Mode         string
 	JwtSecretKey string
+	// GRPCPort is the address the gRPC API listens on, e.g. ":9090". It is
+	// not served when empty. Calls authenticate with access tokens, so
+	// Auth.Mode must enable them.
+	GRPCPort string
+	// TrustedProxies are the CIDRs of the load balancers and reverse proxies
+	// in front of the API. Client IPs, used for rate limits and audit logs,
+	// are read from X-Forwarded-For only as far as these forwarded them, and
+	// are the peer address when none are set.
+	TrustedProxies []string
 }
 
 // type RabbitMQConfig struct {
@@ -33,8 +58,319 @@ type ServerConfig struct {
 // }
 
 type WorkerConfig struct {
-	WorkerCount int
-	MaxCPUUsage float64
+	WorkerCount  int
+	MaxCPUUsage  float64
+	DrainTimeout int
+	MetricsPort  string
+	// StallWindow is how long, in seconds, a job may go without progress
+	// before it is reported as stalled if its worker is gone as well
+	StallWindow int
+	// APICgroupPath is the cgroup of an API server sharing the host. When set,
+	// the CPU it uses is taken out of the worker's encode budget.
+	APICgroupPath string
+	// CPUHeadroom is the percentage of host CPU kept free on top of what the
+	// API is currently using
+	CPUHeadroom float64
+	// NVENCSessionsPerGPU is how many concurrent hardware encodes each GPU
+	// takes. Defaults to the consumer driver limit when unset.
+	NVENCSessionsPerGPU int
+	Sandbox             SandboxConfig
+	ChildLimits         ChildLimitsConfig
+	Tools               ToolsConfig
+	// Outputs of at least MultipartThreshold MB are uploaded in parts of
+	// MultipartPartSize MB, MultipartConcurrency at a time
+	MultipartThreshold   int
+	MultipartPartSize    int
+	MultipartConcurrency int
+	// MaxJobsPerUser caps how many jobs of one user are processed at once
+	// across all workers. Zero means no cap.
+	MaxJobsPerUser int
+	// RecommendationsPath is a file written by cmd/benchmark whose encoder
+	// presets replace the ones guessed from the core count
+	RecommendationsPath string
+	// WorkDir is where jobs are processed, each in its own subdirectory.
+	// Defaults to tmp_segments in the current directory.
+	WorkDir string
+	// DiskFactorPerQuality is the disk a job needs per quality of its ladder
+	// as a multiple of its source size, on top of twice the source size for
+	// the source and its split segments
+	DiskFactorPerQuality float64
+	// MinFreeDiskMB is kept free on the work disk; jobs that would eat into
+	// it wait until enough space is released
+	MinFreeDiskMB int
+	// ProgressInterval is the least time, in seconds, between two progress
+	// notifications of a job. Defaults to 2.
+	ProgressInterval int
+	// MaxRemoteSourceMB caps the size of sources fetched from a URL.
+	// Defaults to 10240.
+	MaxRemoteSourceMB int
+}
+
+// SandboxConfig runs ffmpeg and ffprobe under bubblewrap with no network and
+// a read-only filesystem apart from the job directories.
+type SandboxConfig struct {
+	Enabled   bool
+	BwrapPath string
+	// SeccompProfile is a compiled BPF seccomp filter applied on top
+	SeccompProfile string
+	// GPUDevices exposes /dev to the sandbox so NVENC keeps working
+	GPUDevices bool
+}
+
+// ChildLimitsConfig lowers the priority of ffmpeg, the packagers and whisper
+// so the worker and an API on the same host are not starved by them.
+type ChildLimitsConfig struct {
+	// Nice is the niceness, 0 to 19, the tools run with
+	Nice int
+	// IOClass is realtime, best-effort or idle, with IOLevel the priority
+	// within the first two from 0 (highest) to 7
+	IOClass string
+	IOLevel int
+	// Cgroup is a cgroup v2 directory, relative to /sys/fs/cgroup unless
+	// absolute, whose CPUs and MemoryMB cap all tools of the worker together.
+	// It must not be the worker's own cgroup. Only used on Linux.
+	Cgroup   string
+	CPUs     float64
+	MemoryMB int
+}
+
+// ToolsConfig pins the media tools the worker runs. Paths default to the
+// tools in PATH and minimum versions to the oldest the pipeline is tested
+// with; the worker refuses to start with anything older.
+type ToolsConfig struct {
+	FFmpegPath       string
+	FFprobePath      string
+	MP4FragmentPath  string
+	MP4DashPath      string
+	MinFFmpegVersion string
+	MinBento4Version string
+}
+
+type AnalyticsConfig struct {
+	GeoIPDatabase string
+	// Raw views, watch sessions, heartbeats and QoE beacons are kept for this
+	// many months. Zero keeps them forever.
+	ViewRetentionMonths      int
+	SessionRetentionMonths   int
+	HeartbeatRetentionMonths int
+	QoERetentionMonths       int
+	// PruneInterval is how often, in seconds, expired analytics are pruned
+	// and upcoming monthly partitions created
+	PruneInterval int
+	// ExportMaxSyncDays is the longest time range, in days, exported in the
+	// response. Longer ranges are written to ExportBucket in the background.
+	ExportMaxSyncDays int
+	// ExportBucket keeps background exports. Defaults to the input bucket,
+	// which unlike the output bucket is never served publicly.
+	ExportBucket string
+	// Views are buffered and written in batches of up to ViewBatchSize at
+	// least every ViewFlushInterval milliseconds. Views recorded while
+	// ViewBufferSize are waiting are rejected.
+	ViewBufferSize    int
+	ViewBatchSize     int
+	ViewFlushInterval int
+	// Access logs of the output bucket or its CDN written to CDNLogBucket
+	// under CDNLogPrefix are ingested every CDNLogInterval seconds to
+	// attribute delivered bytes to videos. CDNLogFormat is "s3" or
+	// "cloudfront". Ingestion is off while CDNLogBucket is empty.
+	CDNLogBucket   string
+	CDNLogPrefix   string
+	CDNLogFormat   string
+	CDNLogInterval int
+	// CountryHeader is the header the CDN in front of the API sets to the
+	// viewer's ISO country code, such as CF-IPCountry or
+	// CloudFront-Viewer-Country. Clients can send any header, so it may only
+	// be set when every request reaches the API through that CDN. Views are
+	// located by their IP while it is empty.
+	CountryHeader string
+}
+
+type EncryptionConfig struct {
+	// MasterKey is the base64 encoded 256-bit customer master key used to
+	// wrap per-video data keys. Encryption is unavailable when it is empty.
+	MasterKey string
+	// PlaybackProxyURL is the public API base URL encrypted videos are
+	// streamed through, e.g. https://api.example.com/api/v1
+	PlaybackProxyURL string
+}
+
+// CaptionConfig configures caption generation. Audio is sent to ServiceURL
+// when it is set and transcribed with whisper.cpp otherwise.
+type CaptionConfig struct {
+	WhisperBinary string
+	WhisperModel  string
+	ServiceURL    string
+	ServiceAPIKey string
+	ServiceModel  string
+	// Timeout is the maximum time in seconds a transcription may take
+	Timeout int
+}
+
+// WatermarkConfig is the platform branding burned into videos of users who
+// are not on a paid plan. It is disabled when ImagePath is empty.
+type WatermarkConfig struct {
+	ImagePath string
+	// Position is one of top-left, top-right, bottom-left or bottom-right
+	Position string
+	// Scale is the watermark width relative to the video width
+	Scale   float64
+	Opacity float64
+	// Margin is the distance from the video edges in pixels
+	Margin int
+}
+
+// DRMConfig configures DRM packaging. Content keys are requested from
+// KeyServerURL, a KMS or DRM proxy, and DRM is unavailable when it is empty.
+type DRMConfig struct {
+	KeyServerURL   string
+	KeyServerToken string
+	// Scheme is the common encryption scheme, cenc (Widevine only) or cbcs
+	// (Widevine and FairPlay). Defaults to cbcs.
+	Scheme                 string
+	WidevineProvider       string
+	WidevineLicenseURL     string
+	FairPlayLicenseURL     string
+	FairPlayCertificateURL string
+	// LicenseSigningKey signs the license acquisition URLs handed to players
+	LicenseSigningKey string
+	// LicenseURLTTL is how long in seconds a signed license URL is valid
+	LicenseURLTTL int
+}
+
+// IngestConfig configures ingestion of files dropped in the input bucket.
+// S3 event notifications are delivered through SNS to the ingest endpoint,
+// which is disabled when Token is empty.
+type IngestConfig struct {
+	// Prefix is the watched key prefix. Files must be stored under
+	// <Prefix><user id>/ to be ingested for that user.
+	Prefix string
+	// Token authenticates notifications. SNS cannot set headers, so it is
+	// passed as the token query parameter of the subscription URL.
+	Token string
+}
+
+// QueueConfig selects the broker jobs are dispatched through. Job state is
+// kept in Redis whichever backend is used.
+type QueueConfig struct {
+	// Backend is redis, sqs or nats. Defaults to redis.
+	Backend string
+	SQS     SQSQueueConfig
+	NATS    NATSQueueConfig
+}
+
+// SQSQueueConfig maps job queue keys to SQS queues. A key is sent to the
+// queue at QueueURLPrefix followed by the key, with ':' replaced by '-'.
+type SQSQueueConfig struct {
+	// QueueURLPrefix is e.g. https://sqs.us-east-1.amazonaws.com/123456789012/
+	QueueURLPrefix string
+	Region         string
+	// AccessKey and SecretKey fall back to the default credential chain
+	AccessKey string
+	SecretKey string
+}
+
+// NATSQueueConfig dispatches jobs through a JetStream work queue stream,
+// which is created if it does not exist.
+type NATSQueueConfig struct {
+	URL    string
+	Stream string
+	Token  string
+	User   string
+	// Password authenticates User
+	Password string
+}
+
+// MTLSConfig enables mutual TLS on the internal HTTP surfaces: the worker
+// metrics endpoint and the admin API. It is enabled when CertFile is set.
+// The files are reloaded when they change, so rotated certificates are
+// picked up without a restart.
+type MTLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// CAFile holds the CAs peer certificates must be issued by
+	CAFile string
+	// AllowedNames restricts peers to certificates with one of these DNS or
+	// URI SANs, such as SPIFFE IDs
+	AllowedNames []string
+	// Outbound presents the certificate on the worker's calls to the DRM key
+	// server and caption service, whose certificates must then be issued by
+	// CAFile as well
+	Outbound bool
+}
+
+// PlaybackConfig enables signed playback tokens. When TokenSecrets is set,
+// the playback URLs of videos point at the API's playback origin with a
+// token for the viewer instead of at the CDN, so the output bucket does not
+// need to be public.
+type PlaybackConfig struct {
+	// TokenSecrets sign the tokens. The first is current; the others are
+	// being rotated out and only verified.
+	TokenSecrets []string
+	// TokenTTL is how long in seconds a token is valid
+	TokenTTL int
+	// OriginURL is the public API base URL the origin is served at, e.g.
+	// https://api.example.com/api/v1. Defaults to Encryption.PlaybackProxyURL.
+	OriginURL string
+	// Proxy streams every object through the API. Otherwise only playlists
+	// are, and segments are redirected to short lived presigned URLs.
+	Proxy bool
+}
+
+// TrashConfig controls how long deleted videos can be restored
+type TrashConfig struct {
+	// RetentionDays is how long a deleted video stays in the trash before it
+	// is purged. Defaults to 30 days.
+	RetentionDays int
+	// PurgeInterval is how often, in seconds, expired videos are purged
+	PurgeInterval int
+}
+
+// RateLimitConfig limits how often clients may call the endpoints that cost
+// the most: starting uploads, submitting jobs and writing analytics. Buckets
+// are kept in Redis so the limits hold across API instances.
+type RateLimitConfig struct {
+	Enabled   bool
+	Upload    RateLimitRule
+	Jobs      RateLimitRule
+	Analytics RateLimitRule
+}
+
+// RateLimitRule sizes the token buckets of a group of endpoints, one per
+// client IP and one per user. A bucket holds up to Burst requests and refills
+// at Rate requests per second; a rule with a zero rate does not limit.
+type RateLimitRule struct {
+	IPRate    float64
+	IPBurst   int
+	UserRate  float64
+	UserBurst int
+}
+
+// PricingConfig prices encoding, both in estimates and in the usage the
+// workers record. Both maps are keyed by codec and then hardware class, e.g.
+// Rates["av1"]["gpu"]; combinations left out fall back to built-in speeds and
+// a zero rate.
+type PricingConfig struct {
+	Currency string
+	// Rates are the price of a minute of compute
+	Rates map[string]map[string]float64
+	// Speeds are how many seconds of 1080p source a worker encodes per second
+	// of compute, e.g. 2 for twice realtime
+	Speeds map[string]map[string]float64
+}
+
+// BillingConfig delivers the billing events of completed jobs to an external
+// billing system. Events are always written to billing_events; they are also
+// posted to WebhookURL, signed with WebhookSecrets, when it is set.
+type BillingConfig struct {
+	WebhookURL string
+	// WebhookSecrets sign the requests. The first is current; the others are
+	// being rotated out.
+	WebhookSecrets []string
+	// DeliveryInterval is how often, in seconds, pending events are sent
+	DeliveryInterval int
+	// MaxAttempts is how many times an event is sent before it is left for
+	// an operator to replay. Defaults to 10.
+	MaxAttempts int
 }
 
 type Session struct {
@@ -70,6 +406,69 @@ type RedisConfig struct {
 	JobQueueKey   string
 }
 
+// ScanConfig scans sources for malware after they are downloaded and before
+// they are encoded. Infected sources are moved to QuarantinePrefix in the
+// input bucket and their videos rejected.
+type ScanConfig struct {
+	// Backend is clamav or http. Sources are not scanned when it is empty.
+	Backend string
+	// ClamAVAddr is the host:port or unix socket of clamd
+	ClamAVAddr string
+	// APIURL is posted each source by the http backend, authenticated with
+	// APIKey when it is set
+	APIURL string
+	APIKey string
+	// Timeout is the maximum time in seconds a scan may take. Defaults to 10
+	// minutes.
+	Timeout int
+	// FailOpen encodes sources that could not be scanned, e.g. because the
+	// scanner is down, instead of failing their jobs
+	FailOpen bool
+	// QuarantinePrefix is where infected sources are moved. Defaults to
+	// quarantine/.
+	QuarantinePrefix string
+	// AlertWebhookURL is posted an alert for every infected source, signed
+	// with AlertWebhookSecrets
+	AlertWebhookURL     string
+	AlertWebhookSecrets []string
+}
+
+// AuthConfig selects how clients authenticate
+type AuthConfig struct {
+	// Mode is session for cookie sessions, jwt for short lived bearer access
+	// tokens renewed with rotating refresh tokens, for clients that cannot
+	// keep cookies, or both. Defaults to session.
+	Mode string
+	// AccessTokenExpire is how long access tokens are valid, in seconds.
+	// Defaults to 15 minutes.
+	AccessTokenExpire int
+	// RefreshTokenExpire is how long, in seconds, a client may go without
+	// refreshing before it has to sign in again. Defaults to 30 days.
+	RefreshTokenExpire int
+}
+
+// OAuthConfig enables signing in with Google and GitHub. A provider is
+// enabled once its client ID is set.
+type OAuthConfig struct {
+	// RedirectBaseURL is the public URL of the API, e.g.
+	// https://api.example.com. Providers redirect back to
+	// <RedirectBaseURL>/api/v1/auth/oauth/<provider>/callback.
+	RedirectBaseURL string
+	// SuccessURL is where the browser is sent once signed in. Without it the
+	// callback responds with the user and token.
+	SuccessURL string
+	// EnforcedDomains are the email domains whose users must sign in with a
+	// provider; password sign in and registration are refused for them
+	EnforcedDomains []string
+	Google          OAuthProviderConfig
+	GitHub          OAuthProviderConfig
+}
+
+type OAuthProviderConfig struct {
+	ClientID     string
+	ClientSecret string
+}
+
 type S3Config struct {
 	Endpoint     string
 	Region       string
@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// auditBodyLimit is the largest JSON body summarized in the audit log.
+	// Larger bodies are passed through and left out of the summary.
+	auditBodyLimit = 64 << 10
+	// auditValueLimit is the length string values are cut to
+	auditValueLimit = 200
+	// auditTimeout bounds writing an entry once the request is done
+	auditTimeout = 2 * time.Second
+)
+
+// auditResourceParams are the path parameters naming what an action is on,
+// in the order they are looked for
+var auditResourceParams = []string{"video_id", "job_id", "folder_id", "upload_id", "member_id", "session_id", "user_id"}
+
+// auditSecretFields are parts of field names whose values are redacted
+var auditSecretFields = []string{"password", "secret", "token", "api_key"}
+
+// Audit records action in the audit log of the account a request acts on
+// when the handler succeeds. The entry names the signed in user, their IP and
+// a summary of the JSON body. An entry that cannot be written is logged and
+// does not fail the request. It must run after AuthSessionMiddleware.
+func (mw *MiddlewareManager) Audit(action models.AuditAction) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if mw.auditUC == nil {
+				return next(c)
+			}
+
+			var body []byte
+			req := c.Request()
+			if strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) && req.Body != nil {
+				buf, err := io.ReadAll(io.LimitReader(req.Body, auditBodyLimit+1))
+				if err != nil {
+					return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+				}
+				req.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(buf), req.Body), req.Body}
+				if len(buf) <= auditBodyLimit {
+					body = buf
+				}
+			}
+
+			if err := next(c); err != nil {
+				return err
+			}
+			status := c.Response().Status
+			if status >= http.StatusBadRequest {
+				return nil
+			}
+
+			entry := &models.AuditLog{
+				Action:     action,
+				ResourceID: auditResource(c),
+				Method:     req.Method,
+				Path:       c.Path(),
+				IP:         c.RealIP(),
+				StatusCode: status,
+				Payload:    summarizePayload(body),
+			}
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), auditTimeout)
+			defer cancel()
+			if err := mw.auditUC.Record(ctx, entry); err != nil {
+				mw.logger.Errorf("Audit RequestID: %s, Action: %s, ERROR: %v", utils.GetRequestID(c), action, err)
+			}
+			return nil
+		}
+	}
+}
+
+// auditResource returns the first path parameter naming what an action is on
+func auditResource(c echo.Context) string {
+	for _, param := range auditResourceParams {
+		if value := c.Param(param); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// summarizePayload keeps the top level fields of a JSON object body. Secrets
+// are redacted, long strings cut short and nested values replaced by their
+// size, so entries stay small and never hold credentials.
+func summarizePayload(body []byte) json.RawMessage {
+	var fields map[string]any
+	if len(body) == 0 || json.Unmarshal(body, &fields) != nil {
+		return json.RawMessage("{}")
+	}
+
+	summary := make(map[string]any, len(fields))
+	for key, value := range fields {
+		if isAuditSecret(key) {
+			summary[key] = "[redacted]"
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			if len(v) > auditValueLimit {
+				v = v[:auditValueLimit] + "..."
+			}
+			summary[key] = v
+		case []any:
+			summary[key] = fmt.Sprintf("[%d items]", len(v))
+		case map[string]any:
+			summary[key] = fmt.Sprintf("{%d fields}", len(v))
+		default:
+			summary[key] = v
+		}
+	}
+	out, err := json.Marshal(summary)
+	if err != nil {
+		return json.RawMessage("{}")
+	}
+	return out
+}
+
+func isAuditSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range auditSecretFields {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
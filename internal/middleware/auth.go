@@ -22,8 +22,26 @@ import (
 type UserCtxKey struct {
 }
 
+// AuthSessionMiddleware authenticates the request with its session cookie or,
+// when access tokens are enabled, its bearer access token
 func (mw *MiddlewareManager) AuthSessionMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
+		if token, ok := mw.bearerToken(c); ok {
+			user, err := mw.userFromAccessToken(c.Request().Context(), token)
+			if err != nil {
+				mw.logger.Errorf("userFromAccessToken RequestID: %s, Error: %s",
+					utils.GetRequestID(c),
+					err.Error(),
+				)
+				return c.JSON(http.StatusUnauthorized, httpErrors.NewUnauthorizedError(httpErrors.Unauthorized))
+			}
+			mw.setUser(c, user)
+			return next(c)
+		}
+		if !utils.SessionsEnabled(mw.cfg) {
+			return c.JSON(http.StatusUnauthorized, httpErrors.NewUnauthorizedError(httpErrors.Unauthorized))
+		}
+
 		cookie, err := c.Cookie(mw.cfg.Session.Name)
 		if err != nil {
 			if errors.Is(err, http.ErrNoCookie) {
@@ -64,15 +82,108 @@ func (mw *MiddlewareManager) AuthSessionMiddleware(next echo.HandlerFunc) echo.H
 
 		c.Set("sid", cookie.Value)
 		c.Set("uid", sess.SessionID)
-		c.Set("user", user)
+		mw.setUser(c, user)
+		mw.refreshSession(c, cookie.Value, sess)
 
-		ctx := context.WithValue(c.Request().Context(), utils.CtxUserKey, user)
-		c.SetRequest(c.Request().WithContext(ctx))
+		return next(c)
+	}
+}
+
+// OptionalAuthSessionMiddleware attaches the user to the request when it
+// carries a valid session and lets anonymous requests through otherwise.
+func (mw *MiddlewareManager) OptionalAuthSessionMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if token, ok := mw.bearerToken(c); ok {
+			if user, err := mw.userFromAccessToken(c.Request().Context(), token); err == nil {
+				mw.setUser(c, user)
+			}
+			return next(c)
+		}
+		if !utils.SessionsEnabled(mw.cfg) {
+			return next(c)
+		}
+
+		cookie, err := c.Cookie(mw.cfg.Session.Name)
+		if err != nil || cookie.Value == "" {
+			return next(c)
+		}
+
+		sess, err := mw.sessUC.GetSessionByID(context.Background(), cookie.Value)
+		if err != nil || sess == nil {
+			return next(c)
+		}
+
+		user, err := mw.authUC.GetByID(context.Background(), sess.UserID)
+		if err != nil || user == nil {
+			return next(c)
+		}
+
+		c.Set("sid", cookie.Value)
+		c.Set("uid", sess.SessionID)
+		mw.setUser(c, user)
+		mw.refreshSession(c, cookie.Value, sess)
 
 		return next(c)
 	}
 }
 
+// setUser attaches the authenticated user to the request
+func (mw *MiddlewareManager) setUser(c echo.Context, user *models.User) {
+	c.Set("user", user)
+	ctx := context.WithValue(c.Request().Context(), utils.CtxUserKey, user)
+	c.SetRequest(c.Request().WithContext(ctx))
+}
+
+// bearerToken returns the access token of a request authenticating with
+// one. Without access tokens enabled the Authorization header is ignored.
+func (mw *MiddlewareManager) bearerToken(c echo.Context) (string, bool) {
+	if !utils.TokensEnabled(mw.cfg) {
+		return "", false
+	}
+	header := c.Request().Header.Get("Authorization")
+	if len(header) < len("Bearer ") || !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return "", false
+	}
+	token := strings.TrimSpace(header[len("Bearer "):])
+	return token, token != ""
+}
+
+// userFromAccessToken returns the user an access token was issued to.
+// Access tokens are not looked up, so they stay valid until they expire even
+// when their device is signed out.
+func (mw *MiddlewareManager) userFromAccessToken(ctx context.Context, token string) (*models.User, error) {
+	claims, err := utils.ValidateToken(token, mw.cfg.Server.JwtSecretKey)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user id claim: %w", err)
+	}
+	user, err := mw.authUC.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %s not found", userID)
+	}
+	return user, nil
+}
+
+// refreshSession keeps a session in use from expiring, along with its cookie.
+// A failed refresh is logged and does not fail the request; the session
+// simply expires at its previous time.
+func (mw *MiddlewareManager) refreshSession(c echo.Context, sessionID string, sess *models.Session) {
+	refreshed, err := mw.sessUC.RefreshSession(c.Request().Context(), sessionID, sess)
+	if err != nil {
+		mw.logger.Errorf("RefreshSession RequestID: %s, Error: %s", utils.GetRequestID(c), err.Error())
+		return
+	}
+	if refreshed {
+		c.SetCookie(utils.CreateSessionCookie(mw.cfg, sessionID))
+	}
+}
+
 func (mw *MiddlewareManager) AuthJWTMiddleware(authUC auth.UseCase, cfg *config.Config) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
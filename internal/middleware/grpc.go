@@ -0,0 +1,299 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/idempotency"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// grpcAccountMetadata is AccountHeader for gRPC calls; metadata keys are
+	// lower case
+	grpcAccountMetadata = "x-account-id"
+	// grpcIdempotencyMetadata is IdempotencyKeyHeader for gRPC calls
+	grpcIdempotencyMetadata = "idempotency-key"
+	// grpcProtobufContentType is the content type stored with the responses
+	// of idempotent gRPC calls
+	grpcProtobufContentType = "application/protobuf"
+)
+
+// GRPCUnaryAuth authenticates unary gRPC calls like AuthSessionMiddleware,
+// AccountMiddleware and Authorize together. Calls need a bearer access token
+// in the authorization metadata and the permission permissions lists for
+// their method; methods that are not listed are refused.
+func (mw *MiddlewareManager) GRPCUnaryAuth(permissions map[string]models.Permission) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := mw.authenticateGRPC(ctx, info.FullMethod, permissions)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// GRPCStreamAuth is GRPCUnaryAuth for streaming calls
+func (mw *MiddlewareManager) GRPCStreamAuth(permissions map[string]models.Permission) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := mw.authenticateGRPC(ss.Context(), info.FullMethod, permissions)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// GRPCUnaryAudit records the unary calls of the methods in actions in the
+// audit log, like Audit. The request is summarized as its JSON form would
+// be. It must run after GRPCUnaryAuth.
+func (mw *MiddlewareManager) GRPCUnaryAudit(actions map[string]models.AuditAction) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		action, ok := actions[info.FullMethod]
+		if !ok || mw.auditUC == nil {
+			return handler(ctx, req)
+		}
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+
+		var body []byte
+		if msg, ok := req.(proto.Message); ok {
+			body, _ = protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+		}
+		entry := &models.AuditLog{
+			Action:     action,
+			Method:     "GRPC",
+			Path:       info.FullMethod,
+			IP:         grpcPeerIP(ctx),
+			StatusCode: http.StatusOK,
+			Payload:    summarizePayload(body),
+		}
+		auditCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), auditTimeout)
+		defer cancel()
+		if err := mw.auditUC.Record(auditCtx, entry); err != nil {
+			mw.logger.Errorf("Audit Method: %s, Action: %s, ERROR: %v", info.FullMethod, action, err)
+		}
+		return resp, nil
+	}
+}
+
+// GRPCUnaryRateLimit limits the unary calls of methods like rateLimit does
+// the REST endpoints named endpoint, sharing their buckets. Calls over a
+// limit fail with ResourceExhausted and the seconds to wait in the
+// retry-after trailer. It must run after GRPCUnaryAuth.
+func (mw *MiddlewareManager) GRPCUnaryRateLimit(endpoint string, rule config.RateLimitRule, methods ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !slices.Contains(methods, info.FullMethod) {
+			return handler(ctx, req)
+		}
+		retryAfter, limited := mw.checkRateLimit(ctx, endpoint, rule, grpcPeerIP(ctx), info.FullMethod)
+		if limited {
+			_ = grpc.SetTrailer(ctx, metadata.Pairs("retry-after", strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))))
+			return nil, status.Error(codes.ResourceExhausted, rateLimitMessage(retryAfter))
+		}
+		return handler(ctx, req)
+	}
+}
+
+// GRPCUnaryIdempotent is Idempotent for the unary calls of the methods in
+// responses, which make the empty response message of each. The key is sent
+// in the idempotency-key metadata; a retry with the same key and request gets
+// the response of the first call, while it is in progress Aborted and with a
+// different request FailedPrecondition. It must run after GRPCUnaryAuth.
+func (mw *MiddlewareManager) GRPCUnaryIdempotent(responses map[string]func() proto.Message) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		newResponse, ok := responses[info.FullMethod]
+		md, _ := metadata.FromIncomingContext(ctx)
+		keys := md.Get(grpcIdempotencyMetadata)
+		if !ok || len(keys) == 0 || keys[0] == "" || mw.idempotency == nil {
+			return handler(ctx, req)
+		}
+		key := keys[0]
+		if len(key) > idempotencyKeyMaxLength {
+			return nil, status.Error(codes.InvalidArgument, "Idempotency key is too long")
+		}
+		user, err := utils.GetUserFromCtx(ctx)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+		}
+		msg, ok := req.(proto.Message)
+		if !ok {
+			return handler(ctx, req)
+		}
+		body, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "Invalid request payload")
+		}
+
+		hash := sha256.New()
+		hash.Write([]byte(info.FullMethod + "\n"))
+		hash.Write(body)
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+		scopedKey := user.UserID.String() + ":" + info.FullMethod + ":" + key
+
+		stored, err := mw.idempotency.Begin(ctx, scopedKey, fingerprint, idempotencyLockTTL)
+		switch {
+		case errors.Is(err, idempotency.ErrInProgress):
+			return nil, status.Error(codes.Aborted, err.Error())
+		case errors.Is(err, idempotency.ErrMismatch):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		case err != nil:
+			mw.logger.Warnf("GRPCUnaryIdempotent Method: %s, ERROR: %v", info.FullMethod, err)
+			return handler(ctx, req)
+		case stored != nil:
+			resp := newResponse()
+			if err := proto.Unmarshal(stored.Body, resp); err != nil {
+				mw.logger.Errorf("GRPCUnaryIdempotent Method: %s, ERROR: %v", info.FullMethod, err)
+				return nil, status.Error(codes.Internal, "failed to replay response")
+			}
+			_ = grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(IdempotentReplayedHeader), "true"))
+			return resp, nil
+		}
+
+		resp, handlerErr := handler(ctx, req)
+		storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), idempotencyTimeout)
+		defer cancel()
+		respMsg, ok := resp.(proto.Message)
+		if handlerErr != nil || !ok {
+			if err := mw.idempotency.Release(storeCtx, scopedKey); err != nil {
+				mw.logger.Warnf("GRPCUnaryIdempotent Method: %s, ERROR: %v", info.FullMethod, err)
+			}
+			return resp, handlerErr
+		}
+		respBody, err := proto.Marshal(respMsg)
+		if err != nil {
+			mw.logger.Errorf("GRPCUnaryIdempotent Method: %s, ERROR: %v", info.FullMethod, err)
+			return resp, nil
+		}
+		response := &idempotency.Response{
+			Fingerprint: fingerprint,
+			Status:      http.StatusOK,
+			ContentType: grpcProtobufContentType,
+			Body:        respBody,
+		}
+		if err := mw.idempotency.Complete(storeCtx, scopedKey, response, idempotencyTTL); err != nil {
+			mw.logger.Errorf("GRPCUnaryIdempotent Method: %s, ERROR: %v", info.FullMethod, err)
+		}
+		return resp, nil
+	}
+}
+
+// grpcPeerIP returns the IP a call came from
+func grpcPeerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// authenticatedStream is a server stream whose context carries the user
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticateGRPC returns ctx with the user of the call's access token, or
+// the account it acts on, and checks the user may call method
+func (mw *MiddlewareManager) authenticateGRPC(ctx context.Context, method string, permissions map[string]models.Permission) (context.Context, error) {
+	permission, ok := permissions[method]
+	if !ok {
+		return nil, status.Error(codes.PermissionDenied, "Forbidden")
+	}
+	if !utils.TokensEnabled(mw.cfg) {
+		return nil, status.Error(codes.Unauthenticated, "access tokens are not enabled")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	token, ok := grpcBearerToken(md)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	user, err := mw.userFromAccessToken(ctx, token)
+	if err != nil {
+		mw.logger.Errorf("userFromAccessToken Method: %s, Error: %s", method, err.Error())
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	ctx = context.WithValue(ctx, utils.CtxUserKey, user)
+
+	role := models.AdminRole
+	if values := md.Get(grpcAccountMetadata); len(values) > 0 && values[0] != "" && values[0] != user.UserID.String() {
+		accountID, err := uuid.Parse(values[0])
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "Invalid account id")
+		}
+		role, err = mw.authUC.GetMemberRole(ctx, accountID, user.UserID)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				mw.logger.Errorf("GetMemberRole Method: %s, UserID: %s, AccountID: %s, Error: %s",
+					method,
+					user.UserID.String(),
+					accountID.String(),
+					err.Error(),
+				)
+			}
+			return nil, status.Error(codes.PermissionDenied, "Forbidden")
+		}
+		account, err := mw.authUC.GetByID(ctx, accountID)
+		if err != nil {
+			mw.logger.Errorf("GetByID Method: %s, AccountID: %s, Error: %s", method, accountID.String(), err.Error())
+			return nil, status.Error(codes.PermissionDenied, "Forbidden")
+		}
+		ctx = context.WithValue(ctx, utils.CtxActorKey, user)
+		ctx = context.WithValue(ctx, utils.CtxUserKey, account)
+	}
+
+	if !role.Can(permission) {
+		mw.logger.Errorf("Error: permission denied Method: %s, UserID: %s, Role: %s, Permission: %s",
+			method,
+			user.UserID.String(),
+			role,
+			permission,
+		)
+		return nil, status.Error(codes.PermissionDenied, "Forbidden")
+	}
+	return ctx, nil
+}
+
+// grpcBearerToken returns the access token in the authorization metadata
+func grpcBearerToken(md metadata.MD) (string, bool) {
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return "", false
+	}
+	header := values[0]
+	if len(header) < len("Bearer ") || !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return "", false
+	}
+	token := strings.TrimSpace(header[len("Bearer "):])
+	return token, token != ""
+}
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/idempotency"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// IdempotencyKeyHeader carries the key a client retries a request with
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed for a key
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	idempotencyKeyMaxLength = 255
+	// idempotencyBodyLimit is the largest body a keyed request may have
+	idempotencyBodyLimit = 1 << 20
+	// idempotencyLockTTL is how long a request holds its key before a retry
+	// may run it again, should the API instance die midway
+	idempotencyLockTTL = 5 * time.Minute
+	// idempotencyTTL is how long responses are replayed for
+	idempotencyTTL = 24 * time.Hour
+	// idempotencyTimeout bounds storing a response once the request is done
+	idempotencyTimeout = 2 * time.Second
+)
+
+// responseRecorder keeps a copy of the body written to a response
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// Idempotent makes a request sent with an Idempotency-Key header run at most
+// once per user and route. A retry with the same key and body gets the
+// response of the first request, while it is in progress a 409 and with a
+// different body a 422. Only successful responses are kept, so failed
+// requests can be retried with the same key. Keys are not enforced while
+// Redis cannot be reached. It must run after AuthSessionMiddleware.
+func (mw *MiddlewareManager) Idempotent(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.Request().Header.Get(IdempotencyKeyHeader)
+		if key == "" || mw.idempotency == nil {
+			return next(c)
+		}
+		if len(key) > idempotencyKeyMaxLength {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Idempotency key is too long"})
+		}
+		user, err := utils.GetUserFromCtx(c.Request().Context())
+		if err != nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		}
+
+		req := c.Request()
+		body, err := io.ReadAll(io.LimitReader(req.Body, idempotencyBodyLimit+1))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		if len(body) > idempotencyBodyLimit {
+			return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": "Request body too large"})
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(req.Method + " " + req.URL.Path + "\n"))
+		hash.Write(body)
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+		scopedKey := user.UserID.String() + ":" + c.Path() + ":" + key
+
+		stored, err := mw.idempotency.Begin(req.Context(), scopedKey, fingerprint, idempotencyLockTTL)
+		switch {
+		case errors.Is(err, idempotency.ErrInProgress):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		case errors.Is(err, idempotency.ErrMismatch):
+			return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		case err != nil:
+			mw.logger.Warnf("Idempotent RequestID: %s, ERROR: %v", utils.GetRequestID(c), err)
+			return next(c)
+		case stored != nil:
+			c.Response().Header().Set(IdempotentReplayedHeader, "true")
+			return c.Blob(stored.Status, stored.ContentType, stored.Body)
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+		c.Response().Writer = recorder
+		handlerErr := next(c)
+		c.Response().Writer = recorder.ResponseWriter
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), idempotencyTimeout)
+		defer cancel()
+		status := c.Response().Status
+		if handlerErr != nil || status < 200 || status >= 300 {
+			if err := mw.idempotency.Release(ctx, scopedKey); err != nil {
+				mw.logger.Warnf("Idempotent RequestID: %s, ERROR: %v", utils.GetRequestID(c), err)
+			}
+			return handlerErr
+		}
+		response := &idempotency.Response{
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: c.Response().Header().Get(echo.HeaderContentType),
+			Body:        recorder.body.Bytes(),
+		}
+		if err := mw.idempotency.Complete(ctx, scopedKey, response, idempotencyTTL); err != nil {
+			mw.logger.Errorf("Idempotent RequestID: %s, ERROR: %v", utils.GetRequestID(c), err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/i18n"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	headerAcceptLanguage  = "Accept-Language"
+	headerContentLanguage = "Content-Language"
+)
+
+// LocalizeErrors negotiates a locale from Accept-Language and translates the
+// message of JSON error responses into it. Handlers keep writing
+// English messages; responses the catalog has no translation for are sent
+// unchanged.
+func (mw *MiddlewareManager) LocalizeErrors(next echo.HandlerFunc) echo.HandlerFunc {
+	catalog, err := i18n.Default()
+	if err != nil {
+		mw.logger.Errorf("Error messages will not be localized: %v", err)
+		return next
+	}
+
+	return func(c echo.Context) error {
+		locale := catalog.Match(c.Request().Header.Get(headerAcceptLanguage))
+		ctx := context.WithValue(c.Request().Context(), utils.CtxLocaleKey, locale)
+		c.SetRequest(c.Request().WithContext(ctx))
+		c.Response().Header().Add(echo.HeaderVary, headerAcceptLanguage)
+		if locale == i18n.DefaultLocale {
+			return next(c)
+		}
+
+		res := c.Response()
+		writer := &localizedErrorWriter{ResponseWriter: res.Writer}
+		res.Writer = writer
+		defer func() { res.Writer = writer.ResponseWriter }()
+
+		// Errors returned by the handler are rendered here so that they go
+		// through the writer too
+		if err := next(c); err != nil {
+			c.Error(err)
+		}
+
+		if writer.status != 0 {
+			body := translateErrorBody(catalog, locale, writer.body.Bytes())
+			writer.Header().Set(headerContentLanguage, locale)
+			writer.ResponseWriter.WriteHeader(writer.status)
+			if _, err := writer.ResponseWriter.Write(body); err != nil {
+				mw.logger.Warnf("Failed to write localized error: %v", err)
+			}
+		}
+		return nil
+	}
+}
+
+// localizedErrorWriter holds back JSON error responses so their message can
+// be translated. Everything else is written straight through.
+type localizedErrorWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *localizedErrorWriter) WriteHeader(code int) {
+	if code >= http.StatusBadRequest && strings.HasPrefix(w.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		w.status = code
+		// The body changes size once translated
+		w.Header().Del(echo.HeaderContentLength)
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *localizedErrorWriter) Write(b []byte) (int, error) {
+	if w.status != 0 {
+		return w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *localizedErrorWriter) Flush() {
+	if w.status != 0 {
+		return
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// translateErrorBody translates the message of a JSON error. Handlers put it
+// under "error" and Echo's error handler under "message"; bodies with neither
+// are left untouched.
+func translateErrorBody(catalog *i18n.Catalog, locale string, body []byte) []byte {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return body
+	}
+
+	translated := false
+	for _, key := range []string{"error", "message"} {
+		if message, ok := payload[key].(string); ok {
+			payload[key] = catalog.Error(locale, message)
+			translated = true
+		}
+	}
+	if !translated {
+		return body
+	}
+
+	out, err := json.Marshal(payload)
+	if err != nil {
+		return body
+	}
+	return append(out, '\n')
+}
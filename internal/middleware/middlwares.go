@@ -1,21 +1,27 @@
 package middleware
 
 import (
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/audit"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/auth"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/session"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/idempotency"
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/ratelimit"
 )
 
 type MiddlewareManager struct {
-	authUC  auth.UseCase
-	sessUC  session.UCSession
-	cfg     *config.Config
-	origins []string
-	logger  logger.Logger
+	authUC      auth.UseCase
+	auditUC     audit.UseCase
+	sessUC      session.UCSession
+	cfg         *config.Config
+	origins     []string
+	logger      logger.Logger
+	limiter     *ratelimit.Limiter
+	idempotency *idempotency.Store
 }
 
 // Middleware manager constructor
-func NewMiddlewareManager(authUC auth.UseCase, cfg *config.Config, origins []string, sessUC session.UCSession, logger logger.Logger) *MiddlewareManager {
-	return &MiddlewareManager{authUC: authUC, cfg: cfg, origins: origins, sessUC: sessUC, logger: logger}
+func NewMiddlewareManager(authUC auth.UseCase, auditUC audit.UseCase, cfg *config.Config, origins []string, sessUC session.UCSession, limiter *ratelimit.Limiter, idempotency *idempotency.Store, logger logger.Logger) *MiddlewareManager {
+	return &MiddlewareManager{authUC: authUC, auditUC: auditUC, cfg: cfg, origins: origins, sessUC: sessUC, limiter: limiter, idempotency: idempotency, logger: logger}
 }
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/mtls"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// ClientCertMiddleware requires a client certificate issued by the mTLS CA
+// when mTLS is enabled. The API serves browsers as well, so certificates are
+// only requested during the handshake and enforced on the routes using this.
+func (mw *MiddlewareManager) ClientCertMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if mw.cfg.MTLS.CertFile == "" {
+			return next(c)
+		}
+		if err := mtls.VerifyRequest(c.Request().TLS); err != nil {
+			mw.logger.Warnf("ClientCertMiddleware RequestID: %s, IP: %s, ERROR: %v",
+				utils.GetRequestID(c),
+				c.RealIP(),
+				err,
+			)
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "Client certificate required"})
+		}
+		return next(c)
+	}
+}
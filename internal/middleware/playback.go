@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/playbacktoken"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// PlaybackTokenMiddleware authorizes playback origin requests with the signed
+// token in the path instead of a session, since players and CDNs fetching
+// segments carry no cookies. The token must be issued for the video in the
+// path; its claims are put in the request context.
+func (mw *MiddlewareManager) PlaybackTokenMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		signer, err := playbacktoken.NewSigner(mw.cfg.Playback.TokenSecrets...)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Playback tokens are not enabled"})
+		}
+
+		claims, err := signer.Verify(c.Param("token"), time.Now())
+		if err == nil && claims.VideoID.String() != c.Param("video_id") {
+			err = fmt.Errorf("token issued for video %s", claims.VideoID)
+		}
+		if err != nil {
+			mw.logger.Warnf("PlaybackTokenMiddleware RequestID: %s, IP: %s, ERROR: %v",
+				utils.GetRequestID(c),
+				c.RealIP(),
+				err,
+			)
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "Invalid playback token"})
+		}
+
+		ctx := context.WithValue(c.Request().Context(), utils.CtxPlaybackClaimsKey, claims)
+		c.SetRequest(c.Request().WithContext(ctx))
+		return next(c)
+	}
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/httpErrors"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	rateLimitedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_rate_limited_requests_total",
+		Help: "Number of requests rejected by a rate limit",
+	}, []string{"endpoint", "scope"})
+
+	rateLimitErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_rate_limit_errors_total",
+		Help: "Number of requests let through because their rate limit could not be checked",
+	}, []string{"endpoint"})
+)
+
+// rateBucket is the bucket of a client a request is counted against
+type rateBucket struct {
+	scope, key string
+	rate       float64
+	burst      int
+}
+
+// UploadRateLimit limits the endpoints that start uploads
+func (mw *MiddlewareManager) UploadRateLimit(next echo.HandlerFunc) echo.HandlerFunc {
+	return mw.rateLimit("upload", mw.cfg.RateLimit.Upload, next)
+}
+
+// JobRateLimit limits the endpoints that submit encode jobs
+func (mw *MiddlewareManager) JobRateLimit(next echo.HandlerFunc) echo.HandlerFunc {
+	return mw.rateLimit("jobs", mw.cfg.RateLimit.Jobs, next)
+}
+
+// AnalyticsRateLimit limits the endpoints that record analytics
+func (mw *MiddlewareManager) AnalyticsRateLimit(next echo.HandlerFunc) echo.HandlerFunc {
+	return mw.rateLimit("analytics", mw.cfg.RateLimit.Analytics, next)
+}
+
+// rateLimit limits the requests of a group of endpoints, named by endpoint in
+// metrics and bucket keys, with a bucket per client IP and, when the request
+// is authenticated, per user. Requests over either limit get a 429 with
+// Retry-After. Limits are not enforced while Redis cannot be reached rather
+// than failing every request.
+func (mw *MiddlewareManager) rateLimit(endpoint string, rule config.RateLimitRule, next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		retryAfter, limited := mw.checkRateLimit(c.Request().Context(), endpoint, rule, c.RealIP(), utils.GetRequestID(c))
+		if limited {
+			c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			return c.JSON(http.StatusTooManyRequests, httpErrors.NewTooManyRequestsError(rateLimitMessage(retryAfter)))
+		}
+		return next(c)
+	}
+}
+
+// checkRateLimit counts a request of the client at ip, and of the user in
+// ctx if any, against the buckets of endpoint and reports whether it is over
+// a limit and when to retry
+func (mw *MiddlewareManager) checkRateLimit(ctx context.Context, endpoint string, rule config.RateLimitRule, ip, requestID string) (time.Duration, bool) {
+	if !mw.cfg.RateLimit.Enabled || mw.limiter == nil {
+		return 0, false
+	}
+
+	buckets := []rateBucket{{"ip", ip, rule.IPRate, rule.IPBurst}}
+	if user, err := utils.GetUserFromCtx(ctx); err == nil {
+		buckets = append(buckets, rateBucket{"user", user.UserID.String(), rule.UserRate, rule.UserBurst})
+	}
+
+	for _, bucket := range buckets {
+		if bucket.rate <= 0 {
+			continue
+		}
+		key := fmt.Sprintf("%s:%s:%s", endpoint, bucket.scope, bucket.key)
+		allowed, retryAfter, err := mw.limiter.Allow(ctx, key, bucket.rate, max(bucket.burst, 1))
+		if err != nil {
+			rateLimitErrorsTotal.WithLabelValues(endpoint).Inc()
+			mw.logger.Warnf("RateLimit RequestID: %s, Endpoint: %s, ERROR: %v", requestID, endpoint, err)
+			continue
+		}
+		if !allowed {
+			rateLimitedTotal.WithLabelValues(endpoint, bucket.scope).Inc()
+			return retryAfter, true
+		}
+	}
+	return 0, false
+}
+
+func rateLimitMessage(retryAfter time.Duration) string {
+	return fmt.Sprintf("rate limit exceeded, retry in %s", retryAfter.Round(100*time.Millisecond))
+}
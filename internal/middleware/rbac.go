@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// AccountHeader names the account a member acts on. Requests without it act
+// on the signed in user's own account.
+const AccountHeader = "X-Account-ID"
+
+// accountRoleKey is where AccountMiddleware keeps the role of the signed in
+// user on the account the request acts on
+const accountRoleKey = "account_role"
+
+// AccountMiddleware resolves the account a request acts on. When a member
+// names another account in AccountHeader, the account's user replaces the
+// signed in one in the request context, so the usecases scope everything to
+// it, and the signed in user is kept as the actor. It must run after
+// AuthSessionMiddleware; Authorize then checks the member's role.
+func (mw *MiddlewareManager) AccountMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, err := utils.GetUserFromCtx(c.Request().Context())
+		if err != nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		}
+
+		header := c.Request().Header.Get(AccountHeader)
+		if header == "" || header == user.UserID.String() {
+			c.Set(accountRoleKey, models.AdminRole)
+			return next(c)
+		}
+		accountID, err := uuid.Parse(header)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid account id"})
+		}
+
+		role, err := mw.authUC.GetMemberRole(c.Request().Context(), accountID, user.UserID)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				mw.logger.Errorf("GetMemberRole RequestID: %s, UserID: %s, AccountID: %s, Error: %s",
+					utils.GetRequestID(c),
+					user.UserID.String(),
+					accountID.String(),
+					err.Error(),
+				)
+			}
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "Forbidden"})
+		}
+		account, err := mw.authUC.GetByID(c.Request().Context(), accountID)
+		if err != nil {
+			mw.logger.Errorf("GetByID RequestID: %s, AccountID: %s, Error: %s",
+				utils.GetRequestID(c),
+				accountID.String(),
+				err.Error(),
+			)
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "Forbidden"})
+		}
+
+		c.Set(accountRoleKey, role)
+		ctx := context.WithValue(c.Request().Context(), utils.CtxActorKey, user)
+		ctx = context.WithValue(ctx, utils.CtxUserKey, account)
+		c.SetRequest(c.Request().WithContext(ctx))
+
+		return next(c)
+	}
+}
+
+// Authorize lets a request through when the role of the signed in user on
+// the account it acts on grants permission. Routes without AccountMiddleware
+// act on the user's own account, where every permission is held.
+func (mw *MiddlewareManager) Authorize(permission models.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			role, ok := c.Get(accountRoleKey).(models.Role)
+			if !ok {
+				role = models.AdminRole
+			}
+			if role.Can(permission) {
+				return next(c)
+			}
+
+			user, _ := utils.GetActorFromCtx(c.Request().Context())
+			userID := ""
+			if user != nil {
+				userID = user.UserID.String()
+			}
+			mw.logger.Errorf("Error: permission denied RequestID: %s, UserID: %s, Role: %s, Permission: %s",
+				utils.GetRequestID(c),
+				userID,
+				role,
+				permission,
+			)
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "Forbidden"})
+		}
+	}
+}
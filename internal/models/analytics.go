@@ -15,6 +15,38 @@ type VideoView struct {
 	UserAgent string    `json:"user_agent" db:"user_agent"`
 	Timestamp time.Time `json:"timestamp" db:"timestamp"`
 	Duration  int64     `json:"duration" db:"duration"` // Duration watched in seconds
+
+	// Derived from IP and UserAgent when the view is recorded
+	Country    string `json:"country" db:"country"`
+	Region     string `json:"region" db:"region"`
+	DeviceType string `json:"device_type" db:"device_type"`
+	Browser    string `json:"browser" db:"browser"`
+	OS         string `json:"os" db:"os"`
+	// Referrer is the host of the page the video was played on
+	Referrer string `json:"referrer" db:"referrer"`
+}
+
+// GeoBreakdown represents views of a video from a single country/region
+type GeoBreakdown struct {
+	Country       string `json:"country" db:"country"`
+	Region        string `json:"region" db:"region"`
+	Views         int64  `json:"views" db:"views"`
+	UniqueViewers int64  `json:"unique_viewers" db:"unique_viewers"`
+	WatchTime     int64  `json:"watch_time" db:"watch_time"` // In seconds
+}
+
+// BreakdownItem represents views for a single value of a breakdown dimension
+type BreakdownItem struct {
+	Name      string `json:"name" db:"name"`
+	Views     int64  `json:"views" db:"views"`
+	WatchTime int64  `json:"watch_time" db:"watch_time"` // In seconds
+}
+
+// DeviceBreakdown represents views of a video split by device, browser and OS
+type DeviceBreakdown struct {
+	Devices          []*BreakdownItem `json:"devices"`
+	Browsers         []*BreakdownItem `json:"browsers"`
+	OperatingSystems []*BreakdownItem `json:"operating_systems"`
 }
 
 // VideoWatchSession represents a viewing session of a video
@@ -29,6 +61,55 @@ type VideoWatchSession struct {
 	Completed     bool      `json:"completed" db:"completed"`           // Whether the video was watched to completion
 }
 
+// VideoHeartbeat is a playback position update sent periodically by a player
+type VideoHeartbeat struct {
+	ID        int64     `json:"id" db:"id"`
+	VideoID   uuid.UUID `json:"video_id" db:"video_id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	SessionID string    `json:"session_id" db:"session_id"`
+	Position  int64     `json:"position" db:"position"` // Playback position in seconds
+	Timestamp time.Time `json:"timestamp" db:"timestamp"`
+}
+
+// RetentionPoint is the number of sessions that watched a bucket of a video
+type RetentionPoint struct {
+	Position  int64   `json:"position" db:"position"` // Start of the bucket in seconds
+	Viewers   int64   `json:"viewers" db:"viewers"`
+	Retention float64 `json:"retention"` // Percentage of all sessions still watching
+}
+
+// RetentionCurve represents where viewers of a video drop off
+type RetentionCurve struct {
+	VideoID       uuid.UUID         `json:"video_id"`
+	BucketSize    int               `json:"bucket_size"` // In seconds
+	TotalSessions int64             `json:"total_sessions"`
+	Points        []*RetentionPoint `json:"points"`
+}
+
+// HeatmapBucket is the share of sessions that reached a slice of their
+// video's length, e.g. 40-45% of the way through
+type HeatmapBucket struct {
+	Bucket       int     `json:"bucket" db:"bucket"`
+	StartPercent float64 `json:"start_percent"`
+	EndPercent   float64 `json:"end_percent"`
+	Viewers      int64   `json:"viewers" db:"viewers"`
+	Retention    float64 `json:"retention"` // Percentage of all sessions that reached the bucket
+	DropOff      float64 `json:"drop_off"`  // Percentage points lost since the previous bucket
+}
+
+// AccountHeatmap aggregates retention across all videos of a user, with
+// positions normalized to each video's length so videos of different
+// durations can be compared
+type AccountHeatmap struct {
+	UserID        uuid.UUID        `json:"user_id"`
+	Buckets       int              `json:"buckets"`
+	Videos        int64            `json:"videos" db:"videos"`
+	TotalSessions int64            `json:"total_sessions" db:"total_sessions"`
+	Points        []*HeatmapBucket `json:"points"`
+	// SteepestDropOff is the bucket where the most viewers leave
+	SteepestDropOff *HeatmapBucket `json:"steepest_drop_off,omitempty"`
+}
+
 // VideoEngagement represents engagement metrics for a video
 type VideoEngagement struct {
 	VideoID          uuid.UUID `json:"video_id" db:"video_id"`
@@ -66,8 +147,18 @@ type AnalyticsSummary struct {
 	TotalViews        int64     `json:"total_views"`
 	TotalWatchTime    int64     `json:"total_watch_time"` // In seconds
 	AvgEngagementScore float64  `json:"avg_engagement_score"`
+	// ConcurrentViewers is the number of sessions watching the account's
+	// videos right now
+	ConcurrentViewers int64 `json:"concurrent_viewers"`
 	RecentVideos      []*VideoPerformance `json:"recent_videos"`
 	TopVideos         []*VideoPerformance `json:"top_videos"`
+	// Period is the range the trends and breakdowns below cover; trends
+	// compare it with the period of the same length before it
+	Period       AnalyticsTimeRange  `json:"period"`
+	Trends       SummaryTrends       `json:"trends"`
+	ByFolder     []*SummaryBreakdown `json:"by_folder"`
+	ByTag        []*SummaryBreakdown `json:"by_tag"`
+	TopReferrers []*SummaryBreakdown `json:"top_referrers"`
 }
 
 // AnalyticsTimeRange represents a time range for analytics queries
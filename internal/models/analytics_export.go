@@ -0,0 +1,79 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExportDataset is the analytics table an export is read from
+type ExportDataset string
+
+const (
+	ExportViews    ExportDataset = "views"
+	ExportSessions ExportDataset = "sessions"
+)
+
+// ExportFormat is the file format of an export
+type ExportFormat string
+
+const (
+	ExportCSV    ExportFormat = "csv"
+	ExportNDJSON ExportFormat = "ndjson"
+)
+
+type ExportStatus string
+
+const (
+	ExportPending   ExportStatus = "pending"
+	ExportRunning   ExportStatus = "running"
+	ExportCompleted ExportStatus = "completed"
+	ExportFailed    ExportStatus = "failed"
+)
+
+// AnalyticsExportInput selects the rows of an export. Only the caller's
+// videos are exported, optionally narrowed down to one of them.
+type AnalyticsExportInput struct {
+	Dataset   ExportDataset      `json:"dataset" validate:"required,oneof=views sessions"`
+	Format    ExportFormat       `json:"format" validate:"required,oneof=csv ndjson"`
+	VideoID   *uuid.UUID         `json:"video_id,omitempty"`
+	TimeRange AnalyticsTimeRange `json:"time_range"`
+}
+
+// ContentType returns the MIME type of the export
+func (i *AnalyticsExportInput) ContentType() string {
+	if i.Format == ExportNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv"
+}
+
+// FileName names the file the export is downloaded as
+func (i *AnalyticsExportInput) FileName() string {
+	return fmt.Sprintf("%s_%s_%s.%s", i.Dataset,
+		i.TimeRange.StartDate.Format("2006-01-02"),
+		i.TimeRange.EndDate.Format("2006-01-02"),
+		i.Format,
+	)
+}
+
+// AnalyticsExport is an export written to object storage in the background
+type AnalyticsExport struct {
+	ExportID    uuid.UUID     `json:"export_id" db:"export_id"`
+	UserID      uuid.UUID     `json:"user_id" db:"user_id"`
+	Dataset     ExportDataset `json:"dataset" db:"dataset"`
+	Format      ExportFormat  `json:"format" db:"format"`
+	VideoID     *uuid.UUID    `json:"video_id,omitempty" db:"video_id"`
+	StartDate   time.Time     `json:"start_date" db:"start_date"`
+	EndDate     time.Time     `json:"end_date" db:"end_date"`
+	Status      ExportStatus  `json:"status" db:"status"`
+	ObjectKey   *string       `json:"-" db:"object_key"`
+	Rows        int64         `json:"rows" db:"rows"`
+	Error       *string       `json:"error,omitempty" db:"error"`
+	CreatedAt   time.Time     `json:"created_at" db:"created_at"`
+	CompletedAt *time.Time    `json:"completed_at,omitempty" db:"completed_at"`
+	// DownloadURL is a presigned URL of the finished export
+	DownloadURL string     `json:"download_url,omitempty" db:"-"`
+	URLExpires  *time.Time `json:"download_url_expires_at,omitempty" db:"-"`
+}
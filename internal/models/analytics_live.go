@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LiveHeartbeat marks a session as watching a video right now. Unlike
+// VideoHeartbeat it is not stored and only feeds the concurrent viewer counts.
+type LiveHeartbeat struct {
+	VideoID   uuid.UUID `json:"video_id" validate:"required"`
+	SessionID string    `json:"session_id" validate:"required,lte=64"`
+}
+
+// VideoViewers is the number of sessions watching a video right now
+type VideoViewers struct {
+	VideoID uuid.UUID `json:"video_id"`
+	Viewers int64     `json:"viewers"`
+}
+
+// AccountViewers is the number of sessions watching the videos of an account
+// right now
+type AccountViewers struct {
+	UserID  uuid.UUID `json:"user_id"`
+	Viewers int64     `json:"viewers"`
+}
+
+// ConcurrentViewers is the number of sessions watching the videos of an
+// account right now
+type ConcurrentViewers struct {
+	Viewers int64           `json:"viewers"`
+	Videos  []*VideoViewers `json:"videos"`
+	// Window is how recently, in seconds, a session must have sent a
+	// heartbeat to be counted
+	Window int       `json:"window"`
+	AsOf   time.Time `json:"as_of"`
+}
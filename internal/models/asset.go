@@ -0,0 +1,95 @@
+package models
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// AssetKind is what kind of media a video record holds. Audio files and
+// images go through the same upload, queue and playback info as videos but
+// are processed by pipelines of their own.
+type AssetKind string
+
+const (
+	AssetVideo AssetKind = "video"
+	// AssetAudio is packaged as HLS audio renditions with a waveform
+	AssetAudio AssetKind = "audio"
+	// AssetImage is resized into responsive variants
+	AssetImage AssetKind = "image"
+)
+
+var (
+	audioFormats = map[string]bool{
+		"mp3": true, "wav": true, "flac": true, "aac": true, "m4a": true,
+		"ogg": true, "oga": true, "opus": true, "wma": true, "aiff": true, "aif": true,
+	}
+	imageFormats = map[string]bool{
+		"jpg": true, "jpeg": true, "png": true, "webp": true, "gif": true,
+		"bmp": true, "tif": true, "tiff": true, "heic": true, "avif": true,
+	}
+)
+
+// DetectAssetKind guesses the kind of an upload from its format, or the
+// extension of its file name when the format is not known. Anything that is
+// not a known audio or image format is taken to be a video.
+func DetectAssetKind(format, fileName string) AssetKind {
+	for _, name := range []string{format, strings.TrimPrefix(filepath.Ext(fileName), ".")} {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case audioFormats[name]:
+			return AssetAudio
+		case imageFormats[name]:
+			return AssetImage
+		}
+	}
+	return AssetVideo
+}
+
+// AssetKind is the kind of asset the input uploads, as given or detected
+func (in *VideoUploadInput) AssetKind() AssetKind {
+	if in.Kind != "" {
+		return in.Kind
+	}
+	return DetectAssetKind(in.Format, in.FileName)
+}
+
+// JobType is the type of the job processing an asset of kind
+func (k AssetKind) JobType() JobType {
+	switch k {
+	case AssetAudio:
+		return JobTypeAudio
+	case AssetImage:
+		return JobTypeImage
+	default:
+		return JobTypeEncode
+	}
+}
+
+// AudioQuality is the quality key of the audio rendition of bitrate kbps in
+// playback info, e.g. audio_128k
+func AudioQuality(bitrate int) VideoQuality {
+	return VideoQuality(fmt.Sprintf("audio_%dk", bitrate))
+}
+
+// ImageVariant is a resized copy of an image, for srcset and the like
+type ImageVariant struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Format string `json:"format"`
+	Size   int64  `json:"size"`
+}
+
+// Waveform is the peaks of an audio file in the JSON format of BBC's
+// audiowaveform, which most waveform renderers read. Data holds a minimum
+// and a maximum per pixel, scaled to Bits.
+type Waveform struct {
+	Version         int    `json:"version"`
+	Channels        int    `json:"channels"`
+	SampleRate      int    `json:"sample_rate"`
+	SamplesPerPixel int    `json:"samples_per_pixel"`
+	Bits            int    `json:"bits"`
+	Length          int    `json:"length"`
+	Data            []int8 `json:"data"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditAction names a mutating action recorded in the audit log
+type AuditAction string
+
+const (
+	AuditVideoUpload      AuditAction = "video.upload"
+	AuditVideoUpdate      AuditAction = "video.update"
+	AuditVideoDelete      AuditAction = "video.delete"
+	AuditVideoRestore     AuditAction = "video.restore"
+	AuditVideoPurge       AuditAction = "video.purge"
+	AuditJobSubmit        AuditAction = "job.submit"
+	AuditPlaybackRollback AuditAction = "playback.rollback"
+	AuditFolderCreate     AuditAction = "folder.create"
+	AuditFolderUpdate     AuditAction = "folder.update"
+	AuditFolderDelete     AuditAction = "folder.delete"
+	AuditProfileUpdate    AuditAction = "profile.update"
+	AuditMemberAdd        AuditAction = "member.add"
+	AuditMemberUpdate     AuditAction = "member.update"
+	AuditMemberRemove     AuditAction = "member.remove"
+	AuditSessionRevoke    AuditAction = "session.revoke"
+)
+
+// AuditLog is an action a user took on an account. ActorID differs from
+// AccountID when a member acted on the account. Payload summarizes the
+// request, with secrets redacted and long values cut short.
+type AuditLog struct {
+	ID         int64           `json:"id" db:"id"`
+	AccountID  uuid.UUID       `json:"account_id" db:"account_id"`
+	ActorID    uuid.UUID       `json:"actor_id" db:"actor_id"`
+	ActorEmail string          `json:"actor_email" db:"actor_email"`
+	Action     AuditAction     `json:"action" db:"action"`
+	ResourceID string          `json:"resource_id,omitempty" db:"resource_id"`
+	Method     string          `json:"method" db:"method"`
+	Path       string          `json:"path" db:"path"`
+	IP         string          `json:"ip" db:"ip"`
+	StatusCode int             `json:"status_code" db:"status_code"`
+	Payload    json.RawMessage `json:"payload" db:"payload"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+}
+
+// AuditLogFilter narrows the audit log of an account. Zero fields match
+// every entry.
+type AuditLogFilter struct {
+	Action  AuditAction
+	ActorID *uuid.UUID
+	From    *time.Time
+	To      *time.Time
+}
+
+type AuditLogList struct {
+	Logs       []*AuditLog `json:"logs"`
+	TotalCount int         `json:"total_count"`
+	Page       int         `json:"page"`
+	PageSize   int         `json:"page_size"`
+	HasMore    bool        `json:"has_more"`
+}
@@ -9,4 +9,7 @@ type UploadInput struct {
 	Size       int64     `json:"size,required"`
 	Key        string    `json:"key,required"`
 	BucketName string    `json:"bucket_name,required"`
+	// ContentDisposition is sent with the object when set, e.g. to serve
+	// downloads as attachments
+	ContentDisposition string `json:"content_disposition,omitempty"`
 }
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BillingTier groups encode minutes by codec and the height of the top
+// rendition, e.g. h264-hd, which is what operators put a price on
+type BillingTier string
+
+// BillingTierFor returns the tier of an encode with codec whose top
+// rendition is height pixels tall
+func BillingTierFor(codec Codec, height int) BillingTier {
+	class := "sd"
+	switch {
+	case height > 1080:
+		class = "uhd"
+	case height >= 720:
+		class = "hd"
+	}
+	return BillingTier(string(codec) + "-" + class)
+}
+
+// BillingEventType names what a billing event meters
+type BillingEventType string
+
+const (
+	// BillingJobCompleted is emitted once for every job that completes
+	BillingJobCompleted BillingEventType = "job.completed"
+)
+
+// BillingEvent meters what a completed job costs its user. EncodeMinutes is
+// minutes of source encoded in Tier, StorageBytes what the job added to the
+// output bucket and EgressBytesPerView an estimate of the bytes one view of
+// the top rendition serves. EventID is stable across webhook retries so
+// receivers can drop duplicates.
+type BillingEvent struct {
+	ID                 int64            `json:"-" db:"id"`
+	EventID            uuid.UUID        `json:"event_id" db:"event_id"`
+	Type               BillingEventType `json:"type" db:"type"`
+	JobID              string           `json:"job_id" db:"job_id"`
+	VideoID            string           `json:"video_id" db:"video_id"`
+	UserID             string           `json:"user_id" db:"user_id"`
+	JobType            JobType          `json:"job_type" db:"job_type"`
+	Tier               BillingTier      `json:"tier" db:"tier"`
+	EncodeMinutes      float64          `json:"encode_minutes" db:"encode_minutes"`
+	StorageBytes       int64            `json:"storage_bytes" db:"storage_bytes"`
+	EgressBytesPerView int64            `json:"egress_bytes_per_view" db:"egress_bytes_per_view"`
+	CreatedAt          time.Time        `json:"created_at" db:"created_at"`
+	DeliveredAt        *time.Time       `json:"-" db:"delivered_at"`
+	Attempts           int              `json:"-" db:"attempts"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"fmt"
+)
+
+// MaxChapters is how many chapters a video may have
+const MaxChapters = 500
+
+// Chapter is a titled section of a video. Times are in seconds from the
+// start of the output; End is the start of the next chapter or the end of
+// the video when it is not given.
+type Chapter struct {
+	Title string  `json:"title" validate:"required,lte=200"`
+	Start float64 `json:"start" validate:"gte=0"`
+	End   float64 `json:"end,omitempty" validate:"omitempty,gtfield=Start"`
+}
+
+// ValidateChapters checks that chapters are in order and do not overlap
+func ValidateChapters(chapters []Chapter) error {
+	if len(chapters) > MaxChapters {
+		return fmt.Errorf("a video can have at most %d chapters", MaxChapters)
+	}
+	for i := 1; i < len(chapters); i++ {
+		prev, chapter := chapters[i-1], chapters[i]
+		if chapter.Start <= prev.Start {
+			return fmt.Errorf("chapter %q must start after chapter %q", chapter.Title, prev.Title)
+		}
+		if prev.End > chapter.Start {
+			return fmt.Errorf("chapter %q overlaps chapter %q", prev.Title, chapter.Title)
+		}
+	}
+	return nil
+}
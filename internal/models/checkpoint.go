@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+type JobStage string
+
+const (
+	StageDownloaded JobStage = "downloaded"
+	StageSplit      JobStage = "split"
+	StageEncoding   JobStage = "encoding"
+	StageEncoded    JobStage = "encoded"
+	StagePackaged   JobStage = "packaged"
+	StageUploaded   JobStage = "uploaded"
+)
+
+// JobCheckpoint records how far a job got before its worker was drained so
+// another worker can pick it up without starting from scratch.
+type JobCheckpoint struct {
+	ResumeToken       string                 `json:"resume_token"`
+	JobID             string                 `json:"job_id"`
+	VideoID           string                 `json:"video_id"`
+	Stage             JobStage               `json:"stage"`
+	SegmentDuration   float64                `json:"segment_duration"`
+	SegmentCount      int                    `json:"segment_count"`
+	CompletedSegments map[VideoQuality][]int `json:"completed_segments"`
+	ArtifactPrefix    string                 `json:"artifact_prefix"`
+	SourceEncrypted   bool                   `json:"source_encrypted"`
+	CreatedAt         time.Time              `json:"created_at"`
+}
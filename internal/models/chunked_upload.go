@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// ChunkedUploadInput starts an upload sent through the API in chunks. Every
+// chunk but the last must be exactly ChunkSize bytes.
+type ChunkedUploadInput struct {
+	VideoUploadInput
+	ChunkSize int64 `json:"chunk_size" validate:"required,min=262144,max=33554432"`
+	// Checksum is the hex encoded SHA-256 of the whole file
+	Checksum string `json:"checksum" validate:"required,len=64,hexadecimal"`
+}
+
+type ChunkedUpload struct {
+	UploadID    string           `json:"upload_id"`
+	UserID      string           `json:"user_id"`
+	Video       VideoUploadInput `json:"video"`
+	ChunkSize   int64            `json:"chunk_size"`
+	TotalChunks int              `json:"total_chunks"`
+	Checksum    string           `json:"checksum"`
+	CreatedAt   time.Time        `json:"created_at"`
+	// ChunkChecksums maps the index of every received chunk to its SHA-256
+	ChunkChecksums map[int]string `json:"-"`
+}
+
+// ChunkUploadStatus lets clients resume an upload by sending only the chunks
+// the server does not have yet. Streamed uploads resume at Offset, the end of
+// the chunks received from the start of the file.
+type ChunkUploadStatus struct {
+	UploadID       string `json:"upload_id"`
+	TotalChunks    int    `json:"total_chunks"`
+	ReceivedChunks []int  `json:"received_chunks"`
+	MissingChunks  []int  `json:"missing_chunks"`
+	Offset         int64  `json:"offset"`
+}
+
+// UploadProgress is how far an upload streamed through the API has come.
+// Received counts the bytes that arrived, including those of a chunk still
+// being received, while an interrupted stream resumes at Offset.
+type UploadProgress struct {
+	UploadID       string    `json:"upload_id"`
+	FileSize       int64     `json:"file_size"`
+	Received       int64     `json:"received"`
+	Offset         int64     `json:"offset"`
+	Percent        float64   `json:"percent"`
+	BytesPerSecond int64     `json:"bytes_per_second"`
+	Active         bool      `json:"active"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RenditionOther groups delivered files that belong to no single rendition,
+// such as playlists, manifests and thumbnails
+const RenditionOther = "other"
+
+// DeliveryStat is what the CDN delivered of one rendition of a video on a day
+type DeliveryStat struct {
+	VideoID   uuid.UUID `json:"video_id" db:"video_id"`
+	Rendition string    `json:"rendition" db:"rendition"`
+	Day       time.Time `json:"day" db:"day"`
+	Bytes     int64     `json:"bytes" db:"bytes"`
+	Requests  int64     `json:"requests" db:"requests"`
+}
+
+// DeliveryTotal is what the CDN delivered of a group of files, such as a
+// rendition, a day or a video
+type DeliveryTotal struct {
+	Key      string `json:"key" db:"key"`
+	Bytes    int64  `json:"bytes" db:"bytes"`
+	Requests int64  `json:"requests" db:"requests"`
+}
+
+// VideoDelivery is what the CDN delivered of a video, per rendition and day
+type VideoDelivery struct {
+	VideoID     uuid.UUID        `json:"video_id"`
+	Bytes       int64            `json:"bytes"`
+	Requests    int64            `json:"requests"`
+	ByRendition []*DeliveryTotal `json:"by_rendition"`
+	ByDay       []*DeliveryTotal `json:"by_day"`
+}
+
+// AccountDelivery is what the CDN delivered of a user's videos, with the
+// videos that used the most bandwidth first
+type AccountDelivery struct {
+	Bytes    int64            `json:"bytes"`
+	Requests int64            `json:"requests"`
+	ByVideo  []*DeliveryTotal `json:"by_video"`
+}
@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+type EncodePlanStatus string
+
+const (
+	EncodePlanPending   EncodePlanStatus = "pending"
+	EncodePlanCompleted EncodePlanStatus = "completed"
+	EncodePlanFailed    EncodePlanStatus = "failed"
+)
+
+// EncodePlanInput is the profile a video would be encoded with. Codec,
+// Qualities and OutputFormats get the defaults of a new job.
+type EncodePlanInput struct {
+	Codec                  Codec              `json:"codec" validate:"omitempty,oneof=h264 av1 vp9"`
+	Qualities              []InputQualityInfo `json:"qualities" validate:"dive"`
+	OutputFormats          []PlaybackFormat   `json:"output_formats" validate:"dive"`
+	EnablePerTitleEncoding bool               `json:"enable_per_title_encoding"`
+}
+
+// PlanSource is what the probe found in the source. Duration is in seconds.
+type PlanSource struct {
+	Width         int     `json:"width"`
+	Height        int     `json:"height"`
+	DisplayWidth  int     `json:"display_width"`
+	DisplayHeight int     `json:"display_height"`
+	Duration      float64 `json:"duration"`
+	FrameRate     float64 `json:"frame_rate,omitempty"`
+	HDR           string  `json:"hdr,omitempty"`
+	ColorSpace    string  `json:"color_space,omitempty"`
+}
+
+// RenditionPlan is how one rendition of the ladder would be encoded. Bitrate
+// is the video bitrate in kbps and PredictedBytes includes the audio track.
+// FFmpegArgs are the arguments of a segment, with placeholders for the
+// segment and output paths.
+type RenditionPlan struct {
+	Quality        VideoQuality `json:"quality"`
+	Resolution     string       `json:"resolution"`
+	Bitrate        int          `json:"bitrate"`
+	FrameRate      float64      `json:"frame_rate,omitempty"`
+	Encoder        string       `json:"encoder"`
+	EncoderPreset  string       `json:"encoder_preset,omitempty"`
+	HWAccel        string       `json:"hw_accel"`
+	HDR            bool         `json:"hdr"`
+	ToneMapped     bool         `json:"tone_mapped"`
+	PredictedBytes int64        `json:"predicted_bytes"`
+	FFmpegArgs     []string     `json:"ffmpeg_args"`
+}
+
+// EncodePlan is what a dry run of a job decided: the renditions a source
+// would be encoded into, their predicted sizes and the ffmpeg arguments, as
+// worked out by a worker without encoding anything.
+type EncodePlan struct {
+	JobID       string           `json:"job_id"`
+	VideoID     string           `json:"video_id"`
+	Status      EncodePlanStatus `json:"status"`
+	Error       string           `json:"error,omitempty"`
+	Input       EncodePlanInput  `json:"input"`
+	WorkerID    string           `json:"worker_id,omitempty"`
+	Source      *PlanSource      `json:"source,omitempty"`
+	Renditions  []RenditionPlan  `json:"renditions,omitempty"`
+	TotalBytes  int64            `json:"total_bytes,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+}
@@ -9,6 +9,27 @@ type Codec string
 const (
 	CodecH264 Codec = "h264"
 	CodecAV1  Codec = "av1"
+	// CodecVP9 is for devices that decode VP9 but not AV1 in hardware, such
+	// as older Android phones and Chromecasts
+	CodecVP9 Codec = "vp9"
+)
+
+// JobType is what a job does with a video. Jobs without a type are encodes.
+type JobType string
+
+const (
+	JobTypeEncode JobType = "encode"
+	// JobTypeRepackage repackages the renditions of a finished encode, e.g. with
+	// a new segment duration or DRM, without running the encoders again
+	JobTypeRepackage JobType = "repackage"
+	// JobTypeAudio packages a standalone audio file as HLS audio renditions
+	// and renders its waveform
+	JobTypeAudio JobType = "audio"
+	// JobTypeImage resizes an image into responsive variants
+	JobTypeImage JobType = "image"
+	// JobTypePlan probes a source and works out how it would be encoded
+	// without encoding it; the video and its outputs are left alone
+	JobTypePlan JobType = "plan"
 )
 
 const (
@@ -16,6 +37,8 @@ const (
 	JobStatusProcessing JobStatus = "in_progress"
 	JobStatusCompleted  JobStatus = "completed"
 	JobStatusFailed     JobStatus = "failed"
+	// JobStatusRejected videos had a source that failed the malware scan
+	JobStatusRejected JobStatus = "rejected"
 )
 
 type EncodeJob struct {
@@ -34,4 +57,63 @@ type EncodeJob struct {
 	Status                 JobStatus          `json:"status" db:"status" redis:"status" validate:"required"`
 	StartedAt              time.Time          `json:"started_at" db:"started_at" redis:"started_at" validate:"omitempty"`
 	CompletedAt            time.Time          `json:"completed_at" db:"completed_at" redis:"completed_at" validate:"omitempty"`
+	ResumeToken            string             `json:"resume_token,omitempty" db:"resume_token" redis:"resume_token" validate:"omitempty"`
+	EncryptedDataKey       []byte             `json:"encrypted_data_key,omitempty" db:"encrypted_data_key" redis:"-" validate:"omitempty"`
+	GenerateCaptions       bool               `json:"generate_captions,omitempty" db:"generate_captions" redis:"generate_captions" validate:"omitempty"`
+	CaptionLanguage        string             `json:"caption_language,omitempty" db:"caption_language" redis:"caption_language" validate:"omitempty"`
+	EnableDownloads        bool               `json:"enable_downloads,omitempty" db:"enable_downloads" redis:"enable_downloads" validate:"omitempty"`
+	DownloadQuality        VideoQuality       `json:"download_quality,omitempty" db:"download_quality" redis:"download_quality" validate:"omitempty"`
+	UserPlan               Plan               `json:"user_plan,omitempty" db:"user_plan" redis:"user_plan" validate:"omitempty"`
+	EnableDRM              bool               `json:"enable_drm,omitempty" db:"enable_drm" redis:"enable_drm" validate:"omitempty"`
+	FailureReason          FailureReason      `json:"failure_reason,omitempty" db:"failure_reason" redis:"failure_reason" validate:"omitempty"`
+	FailureMessage         string             `json:"failure_message,omitempty" db:"failure_message" redis:"failure_message" validate:"omitempty"`
+	FailureDescription     string             `json:"failure_description,omitempty" db:"-" redis:"-" validate:"omitempty"`
+	Thumbnails             *ThumbnailPolicy   `json:"thumbnails,omitempty" db:"-" redis:"-" validate:"omitempty"`
+	PreviewFormat          PreviewFormat      `json:"preview_format,omitempty" db:"-" redis:"-" validate:"omitempty"`
+	LowLatencyHLS          bool               `json:"low_latency_hls,omitempty" db:"-" redis:"-" validate:"omitempty"`
+	StorageEstimate        *OutputEstimate    `json:"storage_estimate,omitempty" db:"-" redis:"-" validate:"omitempty"`
+	Type                   JobType            `json:"type,omitempty" db:"-" redis:"-" validate:"omitempty"`
+	// SegmentDuration is the target segment length in seconds of a repackage
+	SegmentDuration int `json:"segment_duration,omitempty" db:"-" redis:"-" validate:"omitempty"`
+	// IntegrityManifest uploads a sidecar with the digest of every packaged file
+	IntegrityManifest bool `json:"integrity_manifest,omitempty" db:"-" redis:"-" validate:"omitempty"`
+	// NotBefore is when the job may start at the earliest; it is queued as
+	// soon as it is created when nil
+	NotBefore *time.Time `json:"not_before,omitempty" db:"-" redis:"not_before" validate:"omitempty"`
+	// PlaybackVersion is set on re-encodes of a finished video. Their
+	// outputs go to a versioned prefix and become active when they complete.
+	PlaybackVersion int `json:"playback_version,omitempty" db:"-" redis:"-" validate:"omitempty"`
+	// IntroS3Key and OutroS3Key are clips in the input bucket joined to the
+	// start and end of the source before it is encoded
+	IntroS3Key string `json:"intro_s3_key,omitempty" db:"-" redis:"-" validate:"omitempty"`
+	OutroS3Key string `json:"outro_s3_key,omitempty" db:"-" redis:"-" validate:"omitempty"`
+	// Chapters are the chapters the user gave, relative to the source
+	Chapters []Chapter `json:"chapters,omitempty" db:"-" redis:"-" validate:"omitempty"`
+	// SourceURL is where the worker fetches the source of a job submitted
+	// with a URL from; it stores it under InputS3Key first
+	SourceURL string `json:"source_url,omitempty" db:"-" redis:"-" validate:"omitempty"`
+	// BypassCache encodes the source even when an earlier job of the user
+	// encoded it with the same profile
+	BypassCache bool `json:"bypass_cache,omitempty" db:"-" redis:"-" validate:"omitempty"`
+	// Stage is what a running job is doing and StageProgress the percentage
+	// of it that is done
+	Stage         ProgressStage `json:"stage,omitempty" db:"-" redis:"stage" validate:"omitempty"`
+	StageProgress float64       `json:"stage_progress,omitempty" db:"-" redis:"stage_progress" validate:"omitempty"`
+	// EstimatedCompletion is when the worker running the job expects it to
+	// finish, as of its last progress update
+	EstimatedCompletion *time.Time `json:"estimated_completion,omitempty" db:"-" redis:"estimated_completion" validate:"omitempty"`
+}
+
+// RepackageInput configures a repackage job. DRM cannot be removed from
+// output that is already protected.
+type RepackageInput struct {
+	SegmentDuration   int  `json:"segment_duration" validate:"omitempty,min=1,max=30"`
+	EnableDRM         bool `json:"enable_drm"`
+	IntegrityManifest bool `json:"integrity_manifest"`
+}
+
+// RepackageSummary reports what a library-wide repackage enqueued.
+type RepackageSummary struct {
+	Queued  int `json:"queued"`
+	Skipped int `json:"skipped"`
 }
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// JobEnvironment is a snapshot of the tools and settings a worker encoded a
+// job with, kept so output differences across fleet upgrades can be traced
+// back to tool versions.
+type JobEnvironment struct {
+	JobID               string `json:"job_id"`
+	VideoID             string `json:"video_id"`
+	WorkerID            string `json:"worker_id"`
+	Hostname            string `json:"hostname"`
+	OS                  string `json:"os"`
+	Arch                string `json:"arch"`
+	CPUCount            int    `json:"cpu_count"`
+	FFmpegVersion       string `json:"ffmpeg_version"`
+	FFmpegConfiguration string `json:"ffmpeg_configuration"`
+	Codec               Codec  `json:"codec"`
+	Encoder             string `json:"encoder"`
+	HWAccel             string `json:"hwaccel"`
+	EncoderPreset       string `json:"encoder_preset"`
+	// QualityLadder lists the renditions as resolution@bitrate
+	QualityLadder map[VideoQuality]string `json:"quality_ladder"`
+	// Tools maps packaging tools to the version reported by them
+	Tools      map[string]string `json:"tools"`
+	CapturedAt time.Time         `json:"captured_at"`
+}
@@ -0,0 +1,115 @@
+package models
+
+import "time"
+
+const (
+	// EstimateAudioBitrate is the AAC bitrate, in kbps, muxed into every
+	// rendition
+	EstimateAudioBitrate = 128
+	// estimateContainerOverhead covers fMP4 boxes, manifests and thumbnails
+	estimateContainerOverhead = 1.05
+)
+
+// EstimateRenditionBytes is the expected size of a rendition of duration
+// seconds at a video bitrate in kbps, with its audio track
+func EstimateRenditionBytes(videoBitrate int, duration float64) int64 {
+	bitrate := videoBitrate + EstimateAudioBitrate
+	return int64(float64(bitrate) * 1000 / 8 * duration * estimateContainerOverhead)
+}
+
+// RenditionEstimate is the expected size of one rendition of the ladder.
+// Bitrate is in kbps and includes the audio track.
+type RenditionEstimate struct {
+	Resolution string `json:"resolution"`
+	Bitrate    int    `json:"bitrate"`
+	Bytes      int64  `json:"bytes"`
+}
+
+// OutputEstimate is the expected storage footprint of a job's output, worked
+// out from the ladder, duration and target bitrates before anything is
+// encoded. QuotaBytes is zero when the user's quota is unknown.
+type OutputEstimate struct {
+	Renditions     []RenditionEstimate `json:"renditions"`
+	TotalBytes     int64               `json:"total_bytes"`
+	QuotaBytes     int64               `json:"quota_bytes"`
+	UsedBytes      int64               `json:"used_bytes"`
+	RemainingBytes int64               `json:"remaining_bytes"`
+	ExceedsQuota   bool                `json:"exceeds_quota"`
+	Warning        string              `json:"warning,omitempty"`
+}
+
+// HardwareClass is the kind of worker a job is encoded on, which sets how
+// fast it encodes and what a minute of its compute costs
+type HardwareClass string
+
+const (
+	HardwareCPU HardwareClass = "cpu"
+	HardwareGPU HardwareClass = "gpu"
+)
+
+// EncodeProfile is which renditions an estimate counts
+type EncodeProfile string
+
+const (
+	// ProfileLadder encodes every rendition of the default ladder up to the
+	// resolution, as jobs without their own qualities do
+	ProfileLadder EncodeProfile = "ladder"
+	// ProfileSingle encodes the resolution only
+	ProfileSingle EncodeProfile = "single"
+)
+
+// CostEstimateInput describes a source to price. Duration is in seconds and
+// Resolution is the highest rendition. Codec, Profile and HardwareClass
+// default to h264, ladder and cpu.
+type CostEstimateInput struct {
+	Duration      float64       `json:"duration" validate:"required,gt=0"`
+	Resolution    VideoQuality  `json:"resolution" validate:"required,oneof=1080p 720p 480p 360p"`
+	Codec         Codec         `json:"codec" validate:"omitempty,oneof=h264 av1 vp9"`
+	Profile       EncodeProfile `json:"profile" validate:"omitempty,oneof=ladder single"`
+	HardwareClass HardwareClass `json:"hardware_class" validate:"omitempty,oneof=cpu gpu"`
+}
+
+// CostEstimate is the expected compute time and price of encoding a source.
+// EncodeSeconds is compute time, which is what jobs are billed by.
+type CostEstimate struct {
+	Codec         Codec          `json:"codec"`
+	Profile       EncodeProfile  `json:"profile"`
+	HardwareClass HardwareClass  `json:"hardware_class"`
+	Renditions    []VideoQuality `json:"renditions"`
+	EncodeSeconds float64        `json:"encode_seconds"`
+	RatePerMinute float64        `json:"rate_per_minute"`
+	Cost          float64        `json:"cost"`
+	Currency      string         `json:"currency"`
+	Warning       string         `json:"warning,omitempty"`
+}
+
+// UsageOutcome is how an attempt at a job ended
+type UsageOutcome string
+
+const (
+	UsageCompleted UsageOutcome = "completed"
+	UsageFailed    UsageOutcome = "failed"
+	// UsageInterrupted attempts were checkpointed and resumed by another one
+	UsageInterrupted UsageOutcome = "interrupted"
+)
+
+// JobUsage is the compute an attempt at a job took, recorded by the worker
+// for reconciliation with estimates and billing. ComputeSeconds is the wall
+// time the attempt held a worker slot; Cost prices it at the rate in force
+// when it ran.
+type JobUsage struct {
+	JobID          string        `json:"job_id" db:"job_id"`
+	VideoID        string        `json:"video_id" db:"video_id"`
+	UserID         string        `json:"user_id" db:"user_id"`
+	JobType        JobType       `json:"job_type" db:"job_type"`
+	Codec          Codec         `json:"codec" db:"codec"`
+	HardwareClass  HardwareClass `json:"hardware_class" db:"hardware_class"`
+	Resolution     VideoQuality  `json:"resolution" db:"resolution"`
+	Outcome        UsageOutcome  `json:"outcome" db:"outcome"`
+	SourceSeconds  float64       `json:"source_seconds" db:"source_seconds"`
+	ComputeSeconds float64       `json:"compute_seconds" db:"compute_seconds"`
+	Cost           float64       `json:"cost" db:"cost"`
+	Currency       string        `json:"currency" db:"currency"`
+	StartedAt      time.Time     `json:"started_at" db:"started_at"`
+	FinishedAt     time.Time     `json:"finished_at" db:"finished_at"`
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MinETAProgress is the job progress from which its ETA is extrapolated
+	// from its own rate rather than the historical throughput of its profile
+	MinETAProgress = 5
+	// ETAHistoryWindow is how far back finished jobs are averaged to
+	// estimate how long a job takes
+	ETAHistoryWindow = 7 * 24 * time.Hour
+)
+
+// EncodeThroughput is how fast completed encodes of one profile ran. Speed is
+// the seconds of source encoded per wall-clock second of a job, from
+// download to upload.
+type EncodeThroughput struct {
+	Codec         Codec         `json:"codec" db:"codec"`
+	Resolution    VideoQuality  `json:"resolution" db:"resolution"`
+	HardwareClass HardwareClass `json:"hardware_class" db:"hardware_class"`
+	Speed         float64       `json:"speed" db:"speed"`
+	Jobs          int           `json:"jobs" db:"jobs"`
+}
+
+// ThroughputSpeed returns the speed of the profile in rows closest to codec,
+// resolution and hardware class: the exact profile if it has jobs, else the
+// codec on that hardware at any resolution weighted by jobs. An empty
+// resolution matches any.
+func ThroughputSpeed(rows []*EncodeThroughput, codec Codec, resolution VideoQuality, hardware HardwareClass) (float64, bool) {
+	var weighted float64
+	var jobs int
+	for _, row := range rows {
+		if row.Codec != codec || row.HardwareClass != hardware || row.Speed <= 0 {
+			continue
+		}
+		if resolution != "" && row.Resolution == resolution {
+			return row.Speed, true
+		}
+		weighted += row.Speed * float64(row.Jobs)
+		jobs += row.Jobs
+	}
+	if jobs == 0 {
+		return 0, false
+	}
+	return weighted / float64(jobs), true
+}
+
+// EstimateRemaining returns the seconds until a job that started elapsed ago
+// and is progress percent done finishes encoding sourceSeconds at speed. It
+// returns false when there is nothing to base an estimate on.
+func EstimateRemaining(elapsed time.Duration, progress, sourceSeconds, speed float64) (float64, bool) {
+	if progress >= 100 {
+		return 0, true
+	}
+	if progress >= MinETAProgress && elapsed > 0 {
+		return elapsed.Seconds() * (100 - progress) / progress, true
+	}
+	if sourceSeconds > 0 && speed > 0 {
+		return max(sourceSeconds/speed-elapsed.Seconds(), 1), true
+	}
+	return 0, false
+}
+
+// ResolutionClass is the highest rendition of the job, which its encode time
+// mostly depends on, or empty when its qualities do not say.
+func (j *EncodeJob) ResolutionClass() VideoQuality {
+	var class VideoQuality
+	height := 0
+	for _, quality := range j.Qualities {
+		name := quality.Resolution
+		if quality.Quality != "" {
+			name = string(quality.Quality)
+		}
+		// Qualities are named by height, e.g. 720p or 1080p60
+		digits, _, _ := strings.Cut(name, "p")
+		h, err := strconv.Atoi(digits)
+		if err != nil || h <= height {
+			continue
+		}
+		height = h
+		class = VideoQuality(digits + "p")
+	}
+	return class
+}
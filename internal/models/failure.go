@@ -0,0 +1,30 @@
+package models
+
+// FailureReason classifies why a job failed so clients can tell users what
+// went wrong without parsing error messages.
+type FailureReason string
+
+const (
+	FailureDownload           FailureReason = "download_failed"
+	FailureProbe              FailureReason = "probe_failed"
+	FailureEncode             FailureReason = "encode_failed"
+	FailurePackage            FailureReason = "package_failed"
+	FailureUpload             FailureReason = "upload_failed"
+	FailureTimeout            FailureReason = "timeout"
+	FailureCancelled          FailureReason = "cancelled"
+	FailureStorageUnavailable FailureReason = "storage_unavailable"
+	// FailureInternal is used for errors outside of the pipeline stages, such
+	// as a key that cannot be unwrapped
+	FailureInternal FailureReason = "internal_error"
+	// FailureWorkerLost is used when the workers a job was handed to kept
+	// dying before finishing it
+	FailureWorkerLost FailureReason = "worker_lost"
+	// FailureInvalidSource is used for sources rejected by pre-flight
+	// validation, see ValidateSource
+	FailureInvalidSource FailureReason = "invalid_source"
+	// FailureMalware is used for sources the malware scan found infected.
+	// Their videos are rejected rather than failed.
+	FailureMalware FailureReason = "malware_detected"
+	// FailureScan is used for sources that could not be scanned for malware
+	FailureScan FailureReason = "scan_failed"
+)
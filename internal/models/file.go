@@ -1,9 +1,19 @@
 package models
 
 import (
+	"crypto/subtle"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type Visibility string
+
+const (
+	VisibilityPrivate  Visibility = "private"
+	VisibilityUnlisted Visibility = "unlisted"
+	VisibilityPublic   Visibility = "public"
 )
 
 type VideoFile struct {
@@ -20,6 +30,57 @@ type VideoFile struct {
 	UploadedAt   time.Time     `json:"uploaded_at" db:"uploaded_at" redis:"uploaded_at" validate:"omitempty"`
 	PlaybackInfo *PlaybackInfo `json:"-"`
 	UpdatedAt    time.Time     `json:"updated_at" db:"updated_at" redis:"updated_at" validate:"omitempty"`
+	Encrypted    bool          `json:"encrypted" db:"encrypted" redis:"encrypted" validate:"omitempty"`
+	// EncryptedDataKey is the video's data key wrapped by the KMS
+	EncryptedDataKey []byte     `json:"-" db:"encrypted_data_key" redis:"-"`
+	Visibility       Visibility `json:"visibility" db:"visibility" redis:"visibility" validate:"omitempty"`
+	ShareToken       *string    `json:"share_token,omitempty" db:"share_token" redis:"-" validate:"omitempty"`
+	FolderID         *uuid.UUID `json:"folder_id" db:"folder_id" redis:"-" validate:"omitempty"`
+	// WorkerID identifies the worker instance processing the video
+	WorkerID          *string    `json:"worker_id,omitempty" db:"worker_id" redis:"-" validate:"omitempty"`
+	ProgressUpdatedAt *time.Time `json:"progress_updated_at,omitempty" db:"progress_updated_at" redis:"-" validate:"omitempty"`
+	// Stalled is set when processing has made no progress within the stall
+	// window and the owning worker stopped sending heartbeats
+	Stalled bool `json:"stalled" db:"-" redis:"-"`
+	// DRMKeyID is the hex key ID of DRM packaged videos
+	DRMKeyID  *string `json:"drm_key_id,omitempty" db:"drm_key_id" redis:"-" validate:"omitempty"`
+	DRMScheme *string `json:"drm_scheme,omitempty" db:"drm_scheme" redis:"-" validate:"omitempty"`
+	// Title, Description and Tags are what the library is searched by; the
+	// file name stands in for an empty title
+	Title       string         `json:"title" db:"title" redis:"-" validate:"omitempty,lte=255"`
+	Description string         `json:"description" db:"description" redis:"-" validate:"omitempty"`
+	Tags        pq.StringArray `json:"tags" db:"tags" redis:"-" validate:"omitempty"`
+	// DeletedAt is set while the video is in the trash; PurgeAt is when it
+	// will be deleted for good
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at" redis:"-"`
+	PurgeAt   *time.Time `json:"purge_at,omitempty" db:"-" redis:"-"`
+	// Rank and Highlight are only set on search results
+	Rank      float64 `json:"rank,omitempty" db:"rank" redis:"-"`
+	Highlight *string `json:"highlight,omitempty" db:"highlight" redis:"-"`
+	// Kind is whether the record holds a video, an audio file or an image
+	Kind AssetKind `json:"kind" db:"kind" redis:"-"`
+}
+
+// CanView reports whether userID may play the video. Unlisted videos can be
+// played by anyone holding the share token; userID is uuid.Nil for anonymous
+// viewers. Videos in the trash cannot be played by anyone.
+func (v *VideoFile) CanView(userID uuid.UUID, shareToken string) bool {
+	if v.DeletedAt != nil {
+		return false
+	}
+	if userID != uuid.Nil && v.UserID == userID {
+		return true
+	}
+
+	switch v.Visibility {
+	case VisibilityPublic:
+		return true
+	case VisibilityUnlisted:
+		return v.ShareToken != nil && shareToken != "" &&
+			subtle.ConstantTimeCompare([]byte(*v.ShareToken), []byte(shareToken)) == 1
+	default:
+		return false
+	}
 }
 
 type FilterOptions struct {
@@ -38,12 +99,51 @@ type VideoList struct {
 }
 
 type VideoUploadInput struct {
-	FileName               string             `json:"filename" validate:"required,lte=255"`
-	FileSize               int64              `json:"file_size" validate:"required"`
-	Duration               int64              `json:"duration" validate:"required"`
+	FileName               string             `json:"filename" validate:"required_without=SourceURL,lte=255"`
+	FileSize               int64              `json:"file_size" validate:"required_without=SourceURL"`
+	Duration               int64              `json:"duration" validate:"omitempty,min=0"`
 	Codec                  Codec              `json:"codec" validate:"required"`
-	Format                 string             `json:"format" validate:"required,lte=20"`
+	Format                 string             `json:"format" validate:"required_without=SourceURL,lte=20"`
 	Qualities              []InputQualityInfo `json:"qualities" validate:"dive"`
 	OutputFormats          []PlaybackFormat   `json:"output_formats" validate:"dive"`
 	EnablePerTitleEncoding bool               `json:"enable_per_title_encoding"`
+	Encrypt                bool               `json:"encrypt"`
+	Visibility             Visibility         `json:"visibility" validate:"omitempty,oneof=private unlisted public"`
+	GenerateCaptions       bool               `json:"generate_captions"`
+	CaptionLanguage        string             `json:"caption_language" validate:"omitempty,lte=10"`
+	EnableDownloads        bool               `json:"enable_downloads"`
+	// DownloadQuality limits downloads to a single quality; all are offered when empty
+	DownloadQuality VideoQuality `json:"download_quality" validate:"omitempty,oneof=1080p 720p 480p 360p"`
+	// EnableDRM packages the video with Widevine/FairPlay protection
+	EnableDRM bool `json:"enable_drm"`
+	// Thumbnails configures which stills are generated; see ThumbnailPolicy
+	Thumbnails *ThumbnailPolicy `json:"thumbnails,omitempty"`
+	// PreviewFormat is the format of the animated preview, WebP by default
+	PreviewFormat PreviewFormat `json:"preview_format,omitempty" validate:"omitempty,oneof=webp gif mp4"`
+	// LowLatencyHLS adds an LL-HLS rendition with partial segments
+	LowLatencyHLS bool `json:"low_latency_hls"`
+	// IntegrityManifest uploads a sidecar with the digest of every packaged file
+	IntegrityManifest bool `json:"integrity_manifest"`
+	// NotBefore schedules the encode to start no earlier than this time
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	// IntroS3Key and OutroS3Key are uploads of the user played before and
+	// after the video in every rendition
+	IntroS3Key string `json:"intro_s3_key,omitempty" validate:"omitempty,lte=1024"`
+	OutroS3Key string `json:"outro_s3_key,omitempty" validate:"omitempty,lte=1024"`
+	// Chapters mark sections of the video; chapters embedded in the source
+	// are used when none are given
+	Chapters []Chapter `json:"chapters,omitempty" validate:"omitempty,dive"`
+	// SourceURL is an http(s) URL the source is fetched from instead of an
+	// upload. The file name, size, duration and format may be left out then.
+	SourceURL string `json:"source_url,omitempty" validate:"omitempty,url,lte=2048"`
+	// BypassCache encodes the source even when the user already encoded the
+	// same file with the same settings
+	BypassCache bool `json:"bypass_cache"`
+	// Kind is detected from the format or file name when it is left out.
+	// Video only options are refused for audio files and images.
+	Kind AssetKind `json:"kind,omitempty" validate:"omitempty,oneof=video audio image"`
+}
+
+type VisibilityInput struct {
+	Visibility Visibility `json:"visibility" validate:"required,oneof=private unlisted public"`
 }
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"math/bits"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// FingerprintMaxDistance is the most bits two frame hashes may differ in
+	// for the frames to count as the same picture
+	FingerprintMaxDistance = 10
+	// fingerprintMinBits and fingerprintMaxBits bound the set bits of a frame
+	// hash worth matching; flat frames like black or fades hash to almost all
+	// zeroes or ones and would match any video
+	fingerprintMinBits = 4
+	fingerprintMaxBits = 60
+)
+
+// VideoFingerprint is the perceptual fingerprint of a video's source: a
+// 64 bit difference hash of a frame taken every Interval seconds. Frames that
+// look alike hash to values a few bits apart, so re-encodes, resizes and
+// trimmed copies of a video share most of their hashes.
+type VideoFingerprint struct {
+	VideoID   uuid.UUID     `json:"video_id" db:"video_id"`
+	Interval  float64       `json:"interval" db:"interval"`
+	Hashes    pq.Int64Array `json:"-" db:"hashes"`
+	FileName  string        `json:"file_name,omitempty" db:"file_name"`
+	CreatedAt time.Time     `json:"created_at" db:"created_at"`
+}
+
+// DuplicateMatch is a video that looks like another. Similarity is the share
+// of the frames of the shorter of the two found in the other; MatchedSeconds
+// is how much of the video they share.
+type DuplicateMatch struct {
+	VideoID        uuid.UUID `json:"video_id"`
+	FileName       string    `json:"file_name"`
+	Similarity     float64   `json:"similarity"`
+	MatchedSeconds float64   `json:"matched_seconds"`
+}
+
+// DuplicateReport lists the videos of an account that look like a video,
+// most similar first
+type DuplicateReport struct {
+	VideoID       uuid.UUID         `json:"video_id"`
+	MinSimilarity float64           `json:"min_similarity"`
+	Matches       []*DuplicateMatch `json:"matches"`
+}
+
+// CompareFingerprints returns how alike two fingerprints are as the share of
+// the distinctive frames of the shorter one found in the other, and how many
+// seconds of it those frames cover. Frame order is ignored, so copies that
+// were cut or rearranged still match.
+func CompareFingerprints(a, b *VideoFingerprint) (similarity, matchedSeconds float64) {
+	query, other := distinctiveHashes(a.Hashes), distinctiveHashes(b.Hashes)
+	interval := a.Interval
+	if len(other) < len(query) {
+		query, other = other, query
+		interval = b.Interval
+	}
+	if len(query) == 0 {
+		return 0, 0
+	}
+
+	matched := 0
+	for _, hash := range query {
+		for _, candidate := range other {
+			if bits.OnesCount64(hash^candidate) <= FingerprintMaxDistance {
+				matched++
+				break
+			}
+		}
+	}
+	return float64(matched) / float64(len(query)), float64(matched) * interval
+}
+
+func distinctiveHashes(hashes []int64) []uint64 {
+	distinct := make([]uint64, 0, len(hashes))
+	for _, hash := range hashes {
+		if set := bits.OnesCount64(uint64(hash)); set >= fingerprintMinBits && set <= fingerprintMaxBits {
+			distinct = append(distinct, uint64(hash))
+		}
+	}
+	return distinct
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Folder struct {
+	FolderID uuid.UUID `json:"folder_id" db:"folder_id" validate:"omitempty"`
+	UserID   uuid.UUID `json:"user_id" db:"user_id" validate:"omitempty"`
+	// ParentID is nil for folders at the root of the library
+	ParentID  *uuid.UUID `json:"parent_id" db:"parent_id" validate:"omitempty"`
+	Name      string     `json:"name" db:"name" validate:"required,lte=255"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type FolderInput struct {
+	Name     string     `json:"name" validate:"required,lte=255"`
+	ParentID *uuid.UUID `json:"parent_id" validate:"omitempty"`
+}
+
+type RenameFolderInput struct {
+	Name string `json:"name" validate:"required,lte=255"`
+}
+
+// MoveFolderInput moves a folder under ParentID, or to the root when nil
+type MoveFolderInput struct {
+	ParentID *uuid.UUID `json:"parent_id" validate:"omitempty"`
+}
+
+// MoveVideoInput moves a video into FolderID, or to the root when nil
+type MoveVideoInput struct {
+	FolderID *uuid.UUID `json:"folder_id" validate:"omitempty"`
+}
@@ -0,0 +1,41 @@
+package models
+
+// S3Event is an S3 bucket notification. Test events sent when notifications
+// are configured have no records.
+type S3Event struct {
+	Records []S3EventRecord `json:"Records"`
+}
+
+type S3EventRecord struct {
+	EventName string `json:"eventName"`
+	S3        struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			// Key is URL encoded, with spaces as '+'
+			Key  string `json:"key"`
+			Size int64  `json:"size"`
+		} `json:"object"`
+	} `json:"s3"`
+}
+
+// SNSMessage is the envelope SNS posts to HTTP subscribers.
+type SNSMessage struct {
+	Type         string `json:"Type"`
+	MessageID    string `json:"MessageId"`
+	TopicArn     string `json:"TopicArn"`
+	Message      string `json:"Message"`
+	SubscribeURL string `json:"SubscribeURL"`
+}
+
+const (
+	SNSTypeNotification             = "Notification"
+	SNSTypeSubscriptionConfirmation = "SubscriptionConfirmation"
+)
+
+// IngestSummary reports what an S3 event ingested.
+type IngestSummary struct {
+	Jobs    []string `json:"jobs"`
+	Skipped int      `json:"skipped"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// MalwareDetected is the event of the alert sent for an infected source
+const MalwareDetected = "malware.detected"
+
+// MalwareAlert is posted to the scan alert webhook when the source of a job
+// is found infected. QuarantineKey is where the source was moved in the input
+// bucket, empty when moving it failed.
+type MalwareAlert struct {
+	Event         string    `json:"event"`
+	JobID         string    `json:"job_id"`
+	VideoID       string    `json:"video_id"`
+	UserID        string    `json:"user_id"`
+	SourceKey     string    `json:"source_key"`
+	QuarantineKey string    `json:"quarantine_key,omitempty"`
+	Signature     string    `json:"signature"`
+	DetectedAt    time.Time `json:"detected_at"`
+}
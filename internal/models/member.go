@@ -0,0 +1,86 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Permission is an action on an account that its roles grant
+type Permission string
+
+const (
+	PermissionVideosRead     Permission = "videos:read"
+	PermissionVideosWrite    Permission = "videos:write"
+	PermissionAnalyticsRead  Permission = "analytics:read"
+	PermissionAnalyticsWrite Permission = "analytics:write"
+	PermissionMembersManage  Permission = "members:manage"
+	PermissionAuditRead      Permission = "audit:read"
+)
+
+// rolePermissions are the permissions of each role on an account. Editors
+// share the video library, viewers only look at it and its analytics.
+var rolePermissions = map[Role][]Permission{
+	AdminRole: {
+		PermissionVideosRead, PermissionVideosWrite,
+		PermissionAnalyticsRead, PermissionAnalyticsWrite,
+		PermissionMembersManage, PermissionAuditRead,
+	},
+	EditorRole: {
+		PermissionVideosRead, PermissionVideosWrite,
+		PermissionAnalyticsRead, PermissionAnalyticsWrite,
+	},
+	ViewerRole: {
+		PermissionVideosRead,
+		PermissionAnalyticsRead,
+	},
+}
+
+// Can reports whether the role grants permission on an account
+func (r Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMemberRole reports whether an account can grant the role to a member
+func IsMemberRole(role Role) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// AccountMember is a user granted a role on the account of another user.
+// Email and Username are the member's.
+type AccountMember struct {
+	AccountID uuid.UUID `json:"account_id" db:"account_id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Email     string    `json:"email" db:"email"`
+	Username  string    `json:"username" db:"username"`
+	Role      Role      `json:"role" db:"role"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// MemberAccount is an account the user is a member of. Email and Username are
+// the account's.
+type MemberAccount struct {
+	AccountID uuid.UUID `json:"account_id" db:"account_id"`
+	Email     string    `json:"email" db:"email"`
+	Username  string    `json:"username" db:"username"`
+	Role      Role      `json:"role" db:"role"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// AddMemberInput grants the user with Email a role on the account
+type AddMemberInput struct {
+	Email string `json:"email" validate:"required,email,lte=60"`
+	Role  Role   `json:"role" validate:"required,oneof=admin editor viewer"`
+}
+
+// UpdateMemberInput changes the role of a member
+type UpdateMemberInput struct {
+	Role Role `json:"role" validate:"required,oneof=admin editor viewer"`
+}
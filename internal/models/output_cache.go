@@ -0,0 +1,77 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutputCacheEntry points at the outputs of a finished encode of a source,
+// identified by its SHA-256, with a profile. A job of the same user with the
+// same source and profile copies those outputs instead of encoding again.
+type OutputCacheEntry struct {
+	UserID       uuid.UUID `json:"user_id" db:"user_id"`
+	SourceHash   string    `json:"source_hash" db:"source_hash"`
+	ProfileHash  string    `json:"profile_hash" db:"profile_hash"`
+	VideoID      uuid.UUID `json:"video_id" db:"video_id"`
+	OutputPrefix string    `json:"output_prefix" db:"output_prefix"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// encodeProfile is every setting of a job that changes what it outputs
+type encodeProfile struct {
+	Codec                  Codec              `json:"codec"`
+	Qualities              []InputQualityInfo `json:"qualities"`
+	OutputFormats          []PlaybackFormat   `json:"output_formats"`
+	EnablePerTitleEncoding bool               `json:"enable_per_title_encoding"`
+	GenerateCaptions       bool               `json:"generate_captions"`
+	CaptionLanguage        string             `json:"caption_language"`
+	EnableDownloads        bool               `json:"enable_downloads"`
+	DownloadQuality        VideoQuality       `json:"download_quality"`
+	UserPlan               Plan               `json:"user_plan"`
+	Thumbnails             *ThumbnailPolicy   `json:"thumbnails"`
+	PreviewFormat          PreviewFormat      `json:"preview_format"`
+	LowLatencyHLS          bool               `json:"low_latency_hls"`
+	IntegrityManifest      bool               `json:"integrity_manifest"`
+	IntroS3Key             string             `json:"intro_s3_key"`
+	OutroS3Key             string             `json:"outro_s3_key"`
+	Chapters               []Chapter          `json:"chapters"`
+}
+
+// ProfileHash returns the hex SHA-256 of the settings of the job that change
+// its outputs, so two jobs with the same hash encode a source the same way.
+func (j *EncodeJob) ProfileHash() string {
+	profile, _ := json.Marshal(encodeProfile{
+		Codec:                  j.Codec,
+		Qualities:              j.Qualities,
+		OutputFormats:          j.OutputFormats,
+		EnablePerTitleEncoding: j.EnablePerTitleEncoding,
+		GenerateCaptions:       j.GenerateCaptions,
+		CaptionLanguage:        j.CaptionLanguage,
+		EnableDownloads:        j.EnableDownloads,
+		DownloadQuality:        j.DownloadQuality,
+		UserPlan:               j.UserPlan,
+		Thumbnails:             j.Thumbnails,
+		PreviewFormat:          j.PreviewFormat,
+		LowLatencyHLS:          j.LowLatencyHLS,
+		IntegrityManifest:      j.IntegrityManifest,
+		IntroS3Key:             j.IntroS3Key,
+		OutroS3Key:             j.OutroS3Key,
+		Chapters:               j.Chapters,
+	})
+	sum := sha256.Sum256(profile)
+	return hex.EncodeToString(sum[:])
+}
+
+// Cacheable reports whether the outputs of the job may be shared with other
+// jobs of its user. Encrypted and DRM outputs are bound to the keys of their
+// video, and re-encodes and repackages write to an existing video.
+func (j *EncodeJob) Cacheable() bool {
+	return (j.Type == "" || j.Type == JobTypeEncode) &&
+		j.PlaybackVersion == 0 &&
+		len(j.EncryptedDataKey) == 0 &&
+		!j.EnableDRM
+}
@@ -19,24 +19,34 @@ const (
 	Quality480P   VideoQuality = "480p"
 	Quality360P   VideoQuality = "360p"
 	QualityMaster VideoQuality = "master"
+	// High frame rate variants of 50 or 60 fps, as the source
+	Quality1080P60 VideoQuality = "1080p60"
+	Quality720P60  VideoQuality = "720p60"
 )
 
 type InputQualityInfo struct {
-	Resolution string `json:"resolution"`
-	Bitrate    int    `json:"bitrate"`
-	MaxBitrate int    `json:"max_bitrate"`
-	MinBitrate int    `json:"min_bitrate"`
+	Quality    VideoQuality `json:"quality,omitempty"`
+	Resolution string       `json:"resolution"`
+	Bitrate    int          `json:"bitrate"`
+	MaxBitrate int          `json:"max_bitrate"`
+	MinBitrate int          `json:"min_bitrate"`
+	FrameRate  float64      `json:"frame_rate,omitempty"`
 }
 
 type PlaybackURLs struct {
 	HLS  string `json:"hls"`
 	DASH string `json:"dash"`
+	// MP4 is the progressive download of the quality, if one was requested
+	MP4 string `json:"mp4,omitempty"`
+	// LLHLS is the low-latency HLS playlist, if one was requested
+	LLHLS string `json:"ll_hls,omitempty"`
 }
 
 type QualityInfo struct {
 	URLs       PlaybackURLs `json:"urls"`
 	Resolution string       `json:"resolution"`
 	Bitrate    int          `json:"bitrate"`
+	FrameRate  float64      `json:"frame_rate,omitempty"`
 }
 
 type PlaybackInfo struct {
@@ -44,6 +54,8 @@ type PlaybackInfo struct {
 	Title        string                       `json:"title" db:"title" validate:"required,lte=255"`
 	Duration     float64                      `json:"duration" db:"duration" validate:"omitempty"`
 	Thumbnail    string                       `json:"thumbnail" db:"thumbnail" validate:"omitempty"`
+	Thumbnails   []string                     `json:"thumbnails" db:"thumbnails" validate:"omitempty"`
+	Preview      string                       `json:"preview,omitempty" db:"preview" validate:"omitempty"`
 	Qualities    map[VideoQuality]QualityInfo `json:"qualities" db:"qualities" validate:"omitempty"`
 	Subtitles    []string                     `json:"subtitles" db:"subtitles" validate:"omitempty"`
 	Format       PlaybackFormat               `json:"format" db:"format" validate:"omitempty"`
@@ -51,6 +63,34 @@ type PlaybackInfo struct {
 	ErrorMessage string                       `json:"error_message" db:"error_message" validate:"omitempty"`
 	CreatedAt    time.Time                    `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time                    `json:"updated_at" db:"updated_at"`
+	// Version is the playback version the info points at; 0 is the
+	// original encode
+	Version int `json:"version" db:"version"`
+	// Chapters are the chapter markers of the video, in order
+	Chapters []Chapter `json:"chapters" db:"chapters"`
+	// DRM is set for DRM packaged videos and is not stored with the rest
+	DRM *DRMInfo `json:"drm,omitempty" db:"-"`
+	// TokenExpiresAt is when the playback token in the URLs expires, if
+	// playback tokens are enabled. Players should fetch the info again
+	// before then.
+	TokenExpiresAt *time.Time `json:"token_expires_at,omitempty" db:"-"`
+	// Kind is the kind of asset played. Audio is played from the HLS URLs of
+	// Qualities and drawn from the Waveform JSON; images have no qualities
+	// and are shown from Images.
+	Kind     AssetKind      `json:"kind" db:"kind"`
+	Waveform string         `json:"waveform,omitempty" db:"waveform"`
+	Images   []ImageVariant `json:"images,omitempty" db:"images"`
+}
+
+// DRMInfo tells players how to acquire licenses for a DRM packaged video.
+// License URLs are signed for the viewer and expire at ExpiresAt.
+type DRMInfo struct {
+	KeyID                  string    `json:"key_id"`
+	Scheme                 string    `json:"scheme"`
+	WidevineLicenseURL     string    `json:"widevine_license_url,omitempty"`
+	FairPlayLicenseURL     string    `json:"fairplay_license_url,omitempty"`
+	FairPlayCertificateURL string    `json:"fairplay_certificate_url,omitempty"`
+	ExpiresAt              time.Time `json:"expires_at"`
 }
 
 func (p *PlaybackInfo) GetPlaybackURL(format PlaybackFormat, quality VideoQuality) string {
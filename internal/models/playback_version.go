@@ -0,0 +1,57 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReencodeInput is the profile a finished video is encoded again with. The
+// new outputs are written next to the current ones and replace them for
+// viewers only once the encode completes.
+type ReencodeInput struct {
+	Codec                  Codec              `json:"codec"`
+	Qualities              []InputQualityInfo `json:"qualities" validate:"dive"`
+	OutputFormats          []PlaybackFormat   `json:"output_formats" validate:"dive"`
+	EnablePerTitleEncoding bool               `json:"enable_per_title_encoding"`
+	GenerateCaptions       bool               `json:"generate_captions"`
+	CaptionLanguage        string             `json:"caption_language" validate:"omitempty,lte=10"`
+	EnableDownloads        bool               `json:"enable_downloads"`
+	DownloadQuality        VideoQuality       `json:"download_quality" validate:"omitempty,oneof=1080p 720p 480p 360p"`
+	EnableDRM              bool               `json:"enable_drm"`
+	Thumbnails             *ThumbnailPolicy   `json:"thumbnails,omitempty"`
+	PreviewFormat          PreviewFormat      `json:"preview_format,omitempty" validate:"omitempty,oneof=webp gif mp4"`
+	LowLatencyHLS          bool               `json:"low_latency_hls"`
+	IntegrityManifest      bool               `json:"integrity_manifest"`
+	IntroS3Key             string             `json:"intro_s3_key,omitempty" validate:"omitempty,lte=1024"`
+	OutroS3Key             string             `json:"outro_s3_key,omitempty" validate:"omitempty,lte=1024"`
+	Chapters               []Chapter          `json:"chapters,omitempty" validate:"omitempty,dive"`
+}
+
+// PlaybackVersion is one encode of a video's outputs. The active version is
+// what the playback info points at; the others can be rolled back to.
+type PlaybackVersion struct {
+	VideoID uuid.UUID `json:"video_id" db:"video_id"`
+	Version int       `json:"version" db:"version"`
+	JobID   *string   `json:"job_id,omitempty" db:"job_id"`
+	Status  JobStatus `json:"status" db:"status"`
+	// Profile is what the version was encoded with; it is unknown for the
+	// original encode
+	Profile      *ReencodeInput `json:"profile,omitempty" db:"-"`
+	Qualities    []VideoQuality `json:"qualities" db:"-"`
+	ErrorMessage *string        `json:"error_message,omitempty" db:"error_message"`
+	Active       bool           `json:"active" db:"active"`
+	CreatedAt    time.Time      `json:"created_at" db:"created_at"`
+	CompletedAt  *time.Time     `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// PlaybackVersionPath returns the directory under a video's output prefix
+// that a version's outputs are stored in. The original encode is stored at
+// the prefix itself.
+func PlaybackVersionPath(version int) string {
+	if version <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("v%d", version)
+}
@@ -0,0 +1,62 @@
+package models
+
+// PlayerConfig bundles everything a web player needs to start playback of a
+// video, shaped so it can be passed to hls.js or Shaka Player directly.
+type PlayerConfig struct {
+	VideoID  string  `json:"video_id"`
+	Title    string  `json:"title"`
+	Duration float64 `json:"duration"`
+	Poster   string  `json:"poster"`
+	Preview  string  `json:"preview,omitempty"`
+	// Storyboard is the WebVTT thumbnail track used for seek previews
+	Storyboard string            `json:"storyboard,omitempty"`
+	Sources    []PlayerSource    `json:"sources"`
+	Qualities  []PlayerQuality   `json:"qualities"`
+	Captions   []PlayerCaption   `json:"captions"`
+	Chapters   []PlayerChapter   `json:"chapters"`
+	Protection *PlayerProtection `json:"protection,omitempty"`
+	Analytics  PlayerAnalytics   `json:"analytics"`
+}
+
+type PlayerSource struct {
+	Src    string         `json:"src"`
+	Type   string         `json:"type"`
+	Format PlaybackFormat `json:"format"`
+}
+
+type PlayerQuality struct {
+	Name       VideoQuality `json:"name"`
+	Resolution string       `json:"resolution"`
+	Bitrate    int          `json:"bitrate"`
+}
+
+type PlayerCaption struct {
+	Src      string `json:"src"`
+	Language string `json:"language"`
+	Label    string `json:"label"`
+	Kind     string `json:"kind"`
+}
+
+type PlayerChapter struct {
+	Title     string  `json:"title"`
+	StartTime float64 `json:"start_time"`
+}
+
+// PlayerProtection describes how protected content must be requested.
+// Encrypted videos are decrypted by the playback proxy, so players only need
+// to send credentials with every manifest and segment request.
+type PlayerProtection struct {
+	Encrypted       bool `json:"encrypted"`
+	WithCredentials bool `json:"with_credentials"`
+	// DRM carries the license servers of DRM packaged videos
+	DRM *DRMInfo `json:"drm,omitempty"`
+}
+
+// PlayerAnalytics lists the beacon endpoints a player reports playback to
+type PlayerAnalytics struct {
+	ViewURL           string `json:"view_url"`
+	SessionStartURL   string `json:"session_start_url"`
+	SessionEndURL     string `json:"session_end_url"`
+	HeartbeatURL      string `json:"heartbeat_url"`
+	HeartbeatInterval int    `json:"heartbeat_interval"` // In seconds
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// ProgressStage names what a running job is doing in its progress
+// notifications. Encodes report a stage per quality, see EncodingStage.
+type ProgressStage string
+
+const (
+	ProgressDownloading ProgressStage = "downloading"
+	ProgressScanning    ProgressStage = "scanning"
+	ProgressProbing     ProgressStage = "probing"
+	ProgressSplitting   ProgressStage = "splitting"
+	ProgressPackaging   ProgressStage = "packaging"
+	ProgressUploading   ProgressStage = "uploading"
+	// ProgressRenditions is the stage in which audio renditions or image
+	// variants are made, and ProgressWaveform the rendering of a waveform
+	ProgressRenditions ProgressStage = "renditions"
+	ProgressWaveform   ProgressStage = "waveform"
+)
+
+// EncodingStage is the stage of encoding a job's rendition of quality, e.g.
+// encoding_720p
+func EncodingStage(quality VideoQuality) ProgressStage {
+	return ProgressStage("encoding_" + string(quality))
+}
+
+// JobProgress is a progress notification of a running job. StageProgress is
+// the percentage of Stage that is done and Progress that of the whole job.
+// ETA is the estimated seconds until the job finishes, nil while unknown.
+type JobProgress struct {
+	JobID         string        `json:"job_id"`
+	Stage         ProgressStage `json:"stage"`
+	StageProgress float64       `json:"stage_progress"`
+	Progress      float64       `json:"progress"`
+	ETA           *int64        `json:"eta,omitempty"`
+	Timestamp     time.Time     `json:"timestamp"`
+}
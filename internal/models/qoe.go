@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QoEBeacon is a playback quality report sent periodically by a player. The
+// counts and durations cover the time since the session's previous beacon,
+// so the beacons of a session add up to its whole playback. Durations are in
+// milliseconds.
+type QoEBeacon struct {
+	ID        int64     `json:"id" db:"id"`
+	VideoID   uuid.UUID `json:"video_id" db:"video_id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	SessionID string    `json:"session_id" db:"session_id"`
+	// PlaybackVersion is the version of the outputs being played, as given in
+	// the playback info, so encodes of a video can be compared
+	PlaybackVersion int          `json:"playback_version" db:"playback_version"`
+	Rendition       VideoQuality `json:"rendition" db:"rendition"`
+	Bitrate         int          `json:"bitrate" db:"bitrate"` // Bits per second
+	// StartupTime is the time to the first frame. Only the first beacon of a
+	// session sends it.
+	StartupTime      *int64    `json:"startup_time,omitempty" db:"startup_time"`
+	RebufferCount    int64     `json:"rebuffer_count" db:"rebuffer_count"`
+	RebufferDuration int64     `json:"rebuffer_duration" db:"rebuffer_duration"`
+	BitrateSwitches  int64     `json:"bitrate_switches" db:"bitrate_switches"`
+	PlayTime         int64     `json:"play_time" db:"play_time"`
+	Timestamp        time.Time `json:"timestamp" db:"timestamp"`
+}
+
+// QoEMetrics aggregates the beacons of a video, or of one playback version or
+// rendition of it. Durations are in milliseconds.
+type QoEMetrics struct {
+	// Key is the playback version or rendition the metrics are grouped by
+	Key                string  `json:"key,omitempty" db:"key"`
+	Sessions           int64   `json:"sessions" db:"sessions"`
+	RebufferedSessions int64   `json:"rebuffered_sessions" db:"rebuffered_sessions"`
+	PlayTime           int64   `json:"play_time" db:"play_time"`
+	StartupTimeAvg     float64 `json:"startup_time_avg" db:"startup_time_avg"`
+	StartupTimeP50     float64 `json:"startup_time_p50" db:"startup_time_p50"`
+	StartupTimeP95     float64 `json:"startup_time_p95" db:"startup_time_p95"`
+	RebufferCount      int64   `json:"rebuffer_count" db:"rebuffer_count"`
+	RebufferDuration   int64   `json:"rebuffer_duration" db:"rebuffer_duration"`
+	// RebufferRatio is the percentage of the time spent waiting for the
+	// player to rebuffer rather than playing
+	RebufferRatio   float64 `json:"rebuffer_ratio"`
+	BitrateSwitches int64   `json:"bitrate_switches" db:"bitrate_switches"`
+	// AverageBitrate is the bitrate played, weighted by play time
+	AverageBitrate float64 `json:"average_bitrate" db:"average_bitrate"`
+}
+
+// VideoQoE is the playback quality of a video overall, per playback version
+// and per rendition
+type VideoQoE struct {
+	VideoID     uuid.UUID     `json:"video_id"`
+	Overall     *QoEMetrics   `json:"overall"`
+	ByVersion   []*QoEMetrics `json:"by_version"`
+	ByRendition []*QoEMetrics `json:"by_rendition"`
+}
@@ -0,0 +1,29 @@
+package models
+
+// QueueDepth is the number of jobs waiting on a job queue.
+type QueueDepth struct {
+	Name  string `json:"name"`
+	Key   string `json:"key"`
+	Depth int64  `json:"depth"`
+}
+
+// QueuedJob is a payload waiting on a job queue. Job is nil when the payload
+// cannot be decoded, in which case Error says why.
+type QueuedJob struct {
+	Index   int64      `json:"index"`
+	Payload string     `json:"payload"`
+	Job     *EncodeJob `json:"job,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// QueuePage is a window of a job queue in the order workers take the jobs.
+type QueuePage struct {
+	Queue QueueDepth   `json:"queue"`
+	Jobs  []*QueuedJob `json:"jobs"`
+}
+
+// PurgeJobInput identifies a queued payload by its exact content, which
+// stays valid while workers pop jobs ahead of it.
+type PurgeJobInput struct {
+	Payload string `json:"payload" validate:"required"`
+}
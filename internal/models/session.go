@@ -1,9 +1,34 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // Session model
 type Session struct {
 	SessionID string    `json:"session_id" redis:"session_id"`
 	UserID    uuid.UUID `json:"user_id" redis:"user_id"`
+
+	// DeviceID names the session in the list of a user's devices. Unlike
+	// SessionID it does not grant access, so it is safe to show to clients.
+	DeviceID   string    `json:"device_id" redis:"device_id"`
+	UserAgent  string    `json:"user_agent" redis:"user_agent"`
+	IP         string    `json:"ip" redis:"ip"`
+	CreatedAt  time.Time `json:"created_at" redis:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at" redis:"last_seen_at"`
+}
+
+// Device is an active session of a user as shown to them. Current marks the
+// session of the request.
+type Device struct {
+	ID         string    `json:"id"`
+	Current    bool      `json:"current"`
+	DeviceType string    `json:"device_type"`
+	Browser    string    `json:"browser"`
+	OS         string    `json:"os"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
 }
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+type SmokeTestStatus string
+
+const (
+	SmokeTestRunning SmokeTestStatus = "running"
+	SmokeTestPassed  SmokeTestStatus = "passed"
+	SmokeTestFailed  SmokeTestStatus = "failed"
+)
+
+// SmokeTestInput describes the synthetic source to generate and the profile
+// it is encoded with.
+type SmokeTestInput struct {
+	Duration               int              `json:"duration" validate:"omitempty,min=1,max=300"`
+	Width                  int              `json:"width" validate:"omitempty,min=64,max=3840"`
+	Height                 int              `json:"height" validate:"omitempty,min=64,max=2160"`
+	Codec                  Codec            `json:"codec" validate:"omitempty,oneof=h264 av1 vp9"`
+	OutputFormats          []PlaybackFormat `json:"output_formats" validate:"dive"`
+	EnablePerTitleEncoding bool             `json:"enable_per_title_encoding"`
+}
+
+// SmokeTestCheck is the outcome of a single validation of the encoded output.
+type SmokeTestCheck struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// SmokeTest tracks a synthetic video through the pipeline. Timings are in
+// seconds; ProcessingTime and TotalTime are set once the job has finished.
+type SmokeTest struct {
+	VideoID        string           `json:"video_id"`
+	JobID          string           `json:"job_id"`
+	Input          SmokeTestInput   `json:"input"`
+	Status         SmokeTestStatus  `json:"status"`
+	GenerateTime   float64          `json:"generate_time"`
+	UploadTime     float64          `json:"upload_time"`
+	ProcessingTime float64          `json:"processing_time,omitempty"`
+	TotalTime      float64          `json:"total_time,omitempty"`
+	Checks         []SmokeTestCheck `json:"checks"`
+	StartedAt      time.Time        `json:"started_at"`
+	QueuedAt       time.Time        `json:"queued_at"`
+	FinishedAt     *time.Time       `json:"finished_at,omitempty"`
+}
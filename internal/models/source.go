@@ -0,0 +1,187 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// SourceReport describes the file a user uploaded as ffprobe saw it when the
+// worker picked it up. Probe is ffprobe's full output, for anything the
+// summary leaves out.
+type SourceReport struct {
+	VideoID   string          `json:"video_id,omitempty"`
+	Container SourceContainer `json:"container"`
+	Streams   []SourceStream  `json:"streams"`
+	Probe     json.RawMessage `json:"probe"`
+}
+
+// SourceContainer is the format of an uploaded file
+type SourceContainer struct {
+	Format     string            `json:"format"`
+	FormatName string            `json:"format_name"`
+	Duration   float64           `json:"duration"`
+	Size       int64             `json:"size"`
+	Bitrate    int64             `json:"bitrate"`
+	Tags       map[string]string `json:"tags,omitempty"`
+}
+
+// SourceStream is a stream of an uploaded file. Fields that do not apply to
+// the stream's type are left out.
+type SourceStream struct {
+	Index     int    `json:"index"`
+	Type      string `json:"type"`
+	Codec     string `json:"codec"`
+	CodecName string `json:"codec_name,omitempty"`
+	CodecTag  string `json:"codec_tag,omitempty"`
+	Profile   string `json:"profile,omitempty"`
+	Bitrate   int64  `json:"bitrate,omitempty"`
+	Language  string `json:"language,omitempty"`
+	Title     string `json:"title,omitempty"`
+
+	Width          int     `json:"width,omitempty"`
+	Height         int     `json:"height,omitempty"`
+	PixelFormat    string  `json:"pixel_format,omitempty"`
+	BitDepth       int     `json:"bit_depth,omitempty"`
+	FrameRate      float64 `json:"frame_rate,omitempty"`
+	ColorRange     string  `json:"color_range,omitempty"`
+	ColorSpace     string  `json:"color_space,omitempty"`
+	ColorTransfer  string  `json:"color_transfer,omitempty"`
+	ColorPrimaries string  `json:"color_primaries,omitempty"`
+
+	SampleRate    int    `json:"sample_rate,omitempty"`
+	Channels      int    `json:"channels,omitempty"`
+	ChannelLayout string `json:"channel_layout,omitempty"`
+}
+
+// ffprobeOutput is the part of `ffprobe -show_format -show_streams -of json`
+// a SourceReport summarizes
+type ffprobeOutput struct {
+	Format struct {
+		FormatName     string            `json:"format_name"`
+		FormatLongName string            `json:"format_long_name"`
+		Duration       string            `json:"duration"`
+		Size           string            `json:"size"`
+		BitRate        string            `json:"bit_rate"`
+		Tags           map[string]string `json:"tags"`
+	} `json:"format"`
+	Streams []struct {
+		Index            int               `json:"index"`
+		CodecType        string            `json:"codec_type"`
+		CodecName        string            `json:"codec_name"`
+		CodecLongName    string            `json:"codec_long_name"`
+		CodecTagString   string            `json:"codec_tag_string"`
+		Profile          string            `json:"profile"`
+		BitRate          string            `json:"bit_rate"`
+		Width            int               `json:"width"`
+		Height           int               `json:"height"`
+		PixFmt           string            `json:"pix_fmt"`
+		BitsPerRawSample string            `json:"bits_per_raw_sample"`
+		AvgFrameRate     string            `json:"avg_frame_rate"`
+		ColorRange       string            `json:"color_range"`
+		ColorSpace       string            `json:"color_space"`
+		ColorTransfer    string            `json:"color_transfer"`
+		ColorPrimaries   string            `json:"color_primaries"`
+		SampleRate       string            `json:"sample_rate"`
+		Channels         int               `json:"channels"`
+		ChannelLayout    string            `json:"channel_layout"`
+		Tags             map[string]string `json:"tags"`
+	} `json:"streams"`
+}
+
+// StripProbeFilename removes the name of the probed file from ffprobe's JSON
+// output. It is a local path or a signed URL, neither of which means anything
+// to users.
+func StripProbeFilename(output []byte) ([]byte, error) {
+	var probe map[string]any
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	if format, ok := probe["format"].(map[string]any); ok {
+		delete(format, "filename")
+	}
+	return json.Marshal(probe)
+}
+
+// pixelFormatDepth matches the bit depth in pixel format names like yuv420p10le
+var pixelFormatDepth = regexp.MustCompile(`p(\d{2})(le|be)$`)
+
+// NewSourceReport summarizes the ffprobe output the worker stored for a video
+func NewSourceReport(videoID string, probe []byte) (*SourceReport, error) {
+	var output ffprobeOutput
+	if err := json.Unmarshal(probe, &output); err != nil {
+		return nil, fmt.Errorf("failed to parse source metadata: %w", err)
+	}
+
+	format := output.Format
+	report := &SourceReport{
+		VideoID: videoID,
+		Container: SourceContainer{
+			Format:     format.FormatLongName,
+			FormatName: format.FormatName,
+			Duration:   parseProbeFloat(format.Duration),
+			Size:       parseProbeInt(format.Size),
+			Bitrate:    parseProbeInt(format.BitRate),
+			Tags:       format.Tags,
+		},
+		Streams: make([]SourceStream, 0, len(output.Streams)),
+		Probe:   probe,
+	}
+	for _, s := range output.Streams {
+		stream := SourceStream{
+			Index:     s.Index,
+			Type:      s.CodecType,
+			Codec:     s.CodecName,
+			CodecName: s.CodecLongName,
+			CodecTag:  s.CodecTagString,
+			Profile:   s.Profile,
+			Bitrate:   parseProbeInt(s.BitRate),
+			Language:  s.Tags["language"],
+			Title:     s.Tags["title"],
+		}
+		switch s.CodecType {
+		case "video":
+			stream.Width, stream.Height = s.Width, s.Height
+			stream.PixelFormat = s.PixFmt
+			stream.BitDepth = int(parseProbeInt(s.BitsPerRawSample))
+			if stream.BitDepth == 0 && s.PixFmt != "" {
+				stream.BitDepth = 8
+				if m := pixelFormatDepth.FindStringSubmatch(s.PixFmt); m != nil {
+					stream.BitDepth, _ = strconv.Atoi(m[1])
+				}
+			}
+			stream.FrameRate = parseProbeRate(s.AvgFrameRate)
+			stream.ColorRange = s.ColorRange
+			stream.ColorSpace = s.ColorSpace
+			stream.ColorTransfer = s.ColorTransfer
+			stream.ColorPrimaries = s.ColorPrimaries
+		case "audio":
+			stream.SampleRate = int(parseProbeInt(s.SampleRate))
+			stream.Channels = s.Channels
+			stream.ChannelLayout = s.ChannelLayout
+		}
+		report.Streams = append(report.Streams, stream)
+	}
+	return report, nil
+}
+
+// ffprobe prints most numbers as strings, and "N/A" when it has none
+func parseProbeInt(value string) int64 {
+	n, _ := strconv.ParseInt(value, 10, 64)
+	return n
+}
+
+func parseProbeFloat(value string) float64 {
+	f, _ := strconv.ParseFloat(value, 64)
+	return f
+}
+
+// parseProbeRate parses a rational such as 30000/1001
+func parseProbeRate(value string) float64 {
+	var num, den float64
+	if _, err := fmt.Sscanf(value, "%g/%g", &num, &den); err != nil || den == 0 {
+		return 0
+	}
+	return num / den
+}
@@ -0,0 +1,88 @@
+package models
+
+import "time"
+
+// JobEventType is a step of a job's lifecycle.
+type JobEventType string
+
+const (
+	JobEventQueued    JobEventType = "queued"
+	JobEventStarted   JobEventType = "started"
+	JobEventCompleted JobEventType = "completed"
+	JobEventFailed    JobEventType = "failed"
+)
+
+// StatsInterval is the width of the buckets throughput stats are grouped in.
+type StatsInterval string
+
+const (
+	StatsIntervalHour StatsInterval = "hour"
+	StatsIntervalDay  StatsInterval = "day"
+)
+
+// JobEvent records a job entering a lifecycle step. A job retried or resumed
+// from a checkpoint is queued and started again under the same ID.
+type JobEvent struct {
+	JobID         string        `json:"job_id" db:"job_id"`
+	VideoID       string        `json:"video_id" db:"video_id"`
+	Event         JobEventType  `json:"event" db:"event"`
+	JobType       JobType       `json:"job_type" db:"job_type"`
+	Codec         Codec         `json:"codec" db:"codec"`
+	FailureReason FailureReason `json:"failure_reason,omitempty" db:"failure_reason"`
+	CreatedAt     time.Time     `json:"created_at" db:"created_at"`
+}
+
+// ThroughputQuery selects the window of throughput stats. From and To default
+// to the last day.
+type ThroughputQuery struct {
+	Interval StatsInterval
+	From     time.Time
+	To       time.Time
+}
+
+// ThroughputRow holds the jobs of one profile that finished in one bucket, as
+// summed up by the database.
+type ThroughputRow struct {
+	Bucket         time.Time `db:"bucket"`
+	Profile        string    `db:"profile"`
+	Finished       int       `db:"finished"`
+	Failed         int       `db:"failed"`
+	QueueWaitTotal float64   `db:"queue_wait_total"`
+	QueueWaitCount int       `db:"queue_wait_count"`
+	EncodeTotal    float64   `db:"encode_total"`
+	EncodeCount    int       `db:"encode_count"`
+}
+
+// ThroughputStats is the pipeline's throughput over time. Jobs are counted in
+// the bucket they finished in.
+type ThroughputStats struct {
+	Interval StatsInterval       `json:"interval"`
+	From     time.Time           `json:"from"`
+	To       time.Time           `json:"to"`
+	Buckets  []*ThroughputBucket `json:"buckets"`
+}
+
+type ThroughputBucket struct {
+	Start       time.Time `json:"start"`
+	Completed   int       `json:"completed"`
+	Failed      int       `json:"failed"`
+	JobsPerHour float64   `json:"jobs_per_hour"`
+	FailureRate float64   `json:"failure_rate"`
+	// AvgQueueWait is the mean time, in seconds, between a job being queued
+	// and a worker starting it
+	AvgQueueWait float64 `json:"avg_queue_wait"`
+	// AvgEncodeDuration is the mean time, in seconds, completed jobs took
+	// from their last start
+	AvgEncodeDuration float64              `json:"avg_encode_duration"`
+	Profiles          []*ProfileThroughput `json:"profiles"`
+}
+
+// ProfileThroughput breaks a bucket down by profile: the codec of encodes,
+// or repackage for repackage jobs.
+type ProfileThroughput struct {
+	Profile           string  `json:"profile"`
+	Completed         int     `json:"completed"`
+	Failed            int     `json:"failed"`
+	AvgQueueWait      float64 `json:"avg_queue_wait"`
+	AvgEncodeDuration float64 `json:"avg_encode_duration"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// ProcessingState is the state of a video as shown to its owner. It folds the
+// status of the video, of its last job and the stall detection into one value.
+type ProcessingState string
+
+const (
+	// StateUploaded videos have a source but no job was submitted yet
+	StateUploaded   ProcessingState = "uploaded"
+	StateQueued     ProcessingState = "queued"
+	StateProcessing ProcessingState = "processing"
+	// StateStalled videos are processing but their worker stopped making
+	// progress, see VideoFile.Stalled
+	StateStalled ProcessingState = "stalled"
+	StateReady   ProcessingState = "ready"
+	StateFailed  ProcessingState = "failed"
+	// StateRejected videos had a source that failed the malware scan
+	StateRejected ProcessingState = "rejected"
+)
+
+// VideoStatus is everything known about the processing of a video: its
+// progress in Postgres, the job hash in Redis and an estimate of when it
+// finishes. Job fields are empty when no job was recorded for the video or
+// its hash has expired.
+type VideoStatus struct {
+	VideoID       string          `json:"video_id"`
+	State         ProcessingState `json:"state"`
+	Status        JobStatus       `json:"status"`
+	Progress      float64         `json:"progress"`
+	JobID         string          `json:"job_id,omitempty"`
+	JobType       JobType         `json:"job_type,omitempty"`
+	Stage         ProgressStage   `json:"stage,omitempty"`
+	StageProgress float64         `json:"stage_progress,omitempty"`
+	QueuedAt      *time.Time      `json:"queued_at,omitempty"`
+	StartedAt     *time.Time      `json:"started_at,omitempty"`
+	CompletedAt   *time.Time      `json:"completed_at,omitempty"`
+	// ETA is the estimated number of seconds until the job finishes, nil
+	// when there is no history to base it on
+	ETA       *int64     `json:"eta,omitempty"`
+	Error     *JobError  `json:"error,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// JobError describes why the last job of a video failed. Reason is from the
+// FailureReason taxonomy, Description explains it in the request's locale
+// and Message is the underlying error.
+type JobError struct {
+	Reason      FailureReason `json:"reason"`
+	Description string        `json:"description,omitempty"`
+	Message     string        `json:"message,omitempty"`
+}
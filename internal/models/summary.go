@@ -0,0 +1,46 @@
+package models
+
+// SummaryBreakdown is the activity of one group of an account's videos, such
+// as a folder, a tag or a referrer, within the summary period
+type SummaryBreakdown struct {
+	// Key identifies the group: the folder ID, tag or referrer host. Videos
+	// outside of any folder are grouped under an empty key.
+	Key       string `json:"key" db:"key"`
+	Name      string `json:"name" db:"name"`
+	Videos    int64  `json:"videos" db:"videos"`
+	Views     int64  `json:"views" db:"views"`
+	WatchTime int64  `json:"watch_time" db:"watch_time"`
+}
+
+// ViewTotals are the views of an account's videos within a period
+type ViewTotals struct {
+	Views         int64 `db:"views"`
+	UniqueViewers int64 `db:"unique_viewers"`
+	WatchTime     int64 `db:"watch_time"`
+}
+
+// Trend compares a metric with the previous period of the same length.
+// Change is the difference in percent, nil when the previous period was zero.
+type Trend struct {
+	Current  int64    `json:"current"`
+	Previous int64    `json:"previous"`
+	Change   *float64 `json:"change"`
+}
+
+// NewTrend compares current with previous
+func NewTrend(current, previous int64) Trend {
+	trend := Trend{Current: current, Previous: previous}
+	if previous > 0 {
+		change := float64(current-previous) / float64(previous) * 100
+		trend.Change = &change
+	}
+	return trend
+}
+
+// SummaryTrends are the account's metrics in the summary period against the
+// period before it
+type SummaryTrends struct {
+	Views         Trend `json:"views"`
+	UniqueViewers Trend `json:"unique_viewers"`
+	WatchTime     Trend `json:"watch_time"`
+}
@@ -0,0 +1,40 @@
+package models
+
+// ThumbnailMode selects how the stills of a video are picked.
+type ThumbnailMode string
+
+const (
+	// ThumbnailModeEven takes Count stills spread evenly over the video
+	ThumbnailModeEven ThumbnailMode = "even"
+	// ThumbnailModeTimestamps takes a still at each of the given timestamps
+	ThumbnailModeTimestamps ThumbnailMode = "timestamps"
+	// ThumbnailModeSmart lets ffmpeg's thumbnail filter pick the most
+	// representative frame of Count equal parts of the video
+	ThumbnailModeSmart ThumbnailMode = "smart"
+)
+
+const MaxThumbnails = 20
+
+// PreviewFormat is the format of the short animated preview of a video
+// shown when hovering it in a gallery
+type PreviewFormat string
+
+const (
+	PreviewWebP PreviewFormat = "webp"
+	PreviewGIF  PreviewFormat = "gif"
+	PreviewMP4  PreviewFormat = "mp4"
+)
+
+// ThumbnailPolicy configures thumbnail generation for a job. Without one a
+// single still is taken 10% into the video.
+type ThumbnailPolicy struct {
+	Mode  ThumbnailMode `json:"mode" validate:"required,oneof=even timestamps smart"`
+	Count int           `json:"count,omitempty" validate:"omitempty,min=1,max=20"`
+	// Timestamps are in seconds from the start of the video
+	Timestamps []float64 `json:"timestamps,omitempty" validate:"required_if=Mode timestamps,omitempty,max=20,dive,min=0"`
+}
+
+// PosterInput picks one of a video's generated thumbnails as its poster.
+type PosterInput struct {
+	Index *int `json:"index" validate:"required,min=0"`
+}
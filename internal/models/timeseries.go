@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TimeSeriesMetric is what a time series of a video counts per bucket
+type TimeSeriesMetric string
+
+const (
+	MetricViews         TimeSeriesMetric = "views"
+	MetricUniqueViewers TimeSeriesMetric = "unique_viewers"
+	// MetricWatchTime is the seconds watched
+	MetricWatchTime TimeSeriesMetric = "watch_time"
+)
+
+// TimeSeriesInterval is the width of the buckets of a time series, named
+// like the date_trunc field that starts them
+type TimeSeriesInterval string
+
+const (
+	IntervalHour TimeSeriesInterval = "hour"
+	IntervalDay  TimeSeriesInterval = "day"
+	IntervalWeek TimeSeriesInterval = "week"
+)
+
+// Duration is the width of a bucket
+func (i TimeSeriesInterval) Duration() time.Duration {
+	switch i {
+	case IntervalHour:
+		return time.Hour
+	case IntervalDay:
+		return 24 * time.Hour
+	case IntervalWeek:
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+// TimeSeriesPoint is the value of a metric in the bucket starting at Bucket
+type TimeSeriesPoint struct {
+	Bucket time.Time `json:"bucket" db:"bucket"`
+	Value  float64   `json:"value" db:"value"`
+}
+
+// TimeSeries is a metric of a video bucketed over time. Every bucket between
+// From and To has a point, including those without views, and buckets start
+// at UTC boundaries; weeks start on Monday.
+type TimeSeries struct {
+	VideoID  uuid.UUID          `json:"video_id"`
+	Metric   TimeSeriesMetric   `json:"metric"`
+	Interval TimeSeriesInterval `json:"interval"`
+	From     time.Time          `json:"from"`
+	To       time.Time          `json:"to"`
+	Points   []*TimeSeriesPoint `json:"points"`
+}
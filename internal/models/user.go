@@ -12,9 +12,20 @@ import (
 
 type Role string
 
+// AdminRole and UserRole are the roles of accounts on the platform. AdminRole,
+// EditorRole and ViewerRole are also the roles an account grants its members.
 const (
-	AdminRole Role = "admin"
-	UserRole  Role = "user"
+	AdminRole  Role = "admin"
+	UserRole   Role = "user"
+	EditorRole Role = "editor"
+	ViewerRole Role = "viewer"
+)
+
+type Plan string
+
+const (
+	PlanFree Plan = "free"
+	PlanPaid Plan = "paid"
 )
 
 type User struct {
@@ -26,6 +37,7 @@ type User struct {
 	APIkey       string    `json:"api_key" db:"api_key" redis:"api_key" validate:"omitempty"`
 	Role         Role      `json:"role" db:"role" redis:"role" validate:"required,oneof=admin user,lte=10"`
 	StorageQuota int64     `json:"storage_quota_db" db:"storage_quota_db" redis:"storage_quota_db"`
+	Plan         Plan      `json:"plan" db:"plan" redis:"plan"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at" redis:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at" db:"updated_at" redis:"updated_at"`
 }
@@ -37,9 +49,13 @@ type StorageUsage struct {
 	UsagePercent float64
 }
 
+// UserWithToken is a signed in user. With access tokens enabled Token is an
+// access token valid for ExpiresIn seconds, renewed with RefreshToken.
 type UserWithToken struct {
-	User  *User  `json:"user"`
-	Token string `json:"token"`
+	User         *User  `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in,omitempty"`
 }
 
 func (u *User) SanitizePassword() {
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SourceIssueCode identifies why a source fails pre-flight validation
+type SourceIssueCode string
+
+const (
+	SourceUnsupportedContainer SourceIssueCode = "unsupported_container"
+	SourceUnsupportedCodec     SourceIssueCode = "unsupported_codec"
+	SourceNoVideo              SourceIssueCode = "no_video"
+	SourceZeroDuration         SourceIssueCode = "zero_duration"
+	SourceDRMProtected         SourceIssueCode = "drm_protected"
+	SourceInvalidResolution    SourceIssueCode = "invalid_resolution"
+)
+
+const (
+	// MinSourceDimension and MaxSourceDimension bound the width and height of
+	// the video of a source
+	MinSourceDimension = 16
+	MaxSourceDimension = 8192
+)
+
+// SourceIssue is a reason a source cannot be encoded
+type SourceIssue struct {
+	Code    SourceIssueCode `json:"code"`
+	Message string          `json:"message"`
+}
+
+// SourceValidation is the result of validating a source before encoding it
+type SourceValidation struct {
+	Valid  bool          `json:"valid"`
+	Issues []SourceIssue `json:"issues"`
+	Source *SourceReport `json:"source,omitempty"`
+}
+
+// ValidateSourceInput names an upload of the user to validate
+type ValidateSourceInput struct {
+	S3Key string `json:"s3_key" validate:"required,lte=1024"`
+}
+
+// supportedContainers are the ffprobe format names sources may have. ffprobe
+// names a format by all its aliases, e.g. "mov,mp4,m4a,3gp,3g2,mj2".
+var supportedContainers = map[string]bool{
+	"mov": true, "mp4": true, "matroska": true, "webm": true, "avi": true,
+	"mpegts": true, "mpeg": true, "flv": true, "asf": true, "mxf": true,
+	"ogg": true,
+}
+
+// supportedVideoCodecs are the video codecs the encoders can decode
+var supportedVideoCodecs = map[string]bool{
+	"h264": true, "hevc": true, "vp8": true, "vp9": true, "av1": true,
+	"mpeg1video": true, "mpeg2video": true, "mpeg4": true, "h263": true,
+	"msmpeg4v3": true, "wmv3": true, "vc1": true, "prores": true,
+	"dnxhd": true, "mjpeg": true, "theora": true, "ffv1": true,
+}
+
+// encryptedCodecTags are the sample entries of streams protected by common
+// encryption or FairPlay
+var encryptedCodecTags = map[string]bool{
+	"encv": true, "enca": true, "encs": true, "drmi": true, "drms": true,
+}
+
+// ValidateSource checks that a source can be encoded: it is in a supported
+// container, has a video stream in a supported codec and of a sane size, has
+// a duration and is not DRM protected. Audio and subtitle codecs are not
+// checked since streams that cannot be used are dropped.
+func ValidateSource(report *SourceReport) []SourceIssue {
+	issues := []SourceIssue{}
+	add := func(code SourceIssueCode, format string, args ...any) {
+		issues = append(issues, SourceIssue{Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	container := false
+	for _, name := range strings.Split(report.Container.FormatName, ",") {
+		container = container || supportedContainers[name]
+	}
+	if !container {
+		add(SourceUnsupportedContainer, "container %q is not supported", report.Container.FormatName)
+	}
+	if report.Container.Duration <= 0 {
+		add(SourceZeroDuration, "source has no duration")
+	}
+
+	var video *SourceStream
+	for i, stream := range report.Streams {
+		if encryptedCodecTags[stream.CodecTag] {
+			add(SourceDRMProtected, "%s stream %d is encrypted", stream.Type, stream.Index)
+		}
+		if stream.Type == "video" && video == nil && !isAttachedPicture(stream) {
+			video = &report.Streams[i]
+		}
+	}
+	if video == nil {
+		add(SourceNoVideo, "source has no video stream")
+		return issues
+	}
+	if !supportedVideoCodecs[video.Codec] {
+		add(SourceUnsupportedCodec, "video codec %q is not supported", video.Codec)
+	}
+	if video.Width < MinSourceDimension || video.Height < MinSourceDimension ||
+		video.Width > MaxSourceDimension || video.Height > MaxSourceDimension {
+		add(SourceInvalidResolution, "resolution %dx%d is outside %d to %d pixels",
+			video.Width, video.Height, MinSourceDimension, MaxSourceDimension)
+	}
+	return issues
+}
+
+// isAttachedPicture reports whether a video stream is cover art, which
+// ffprobe lists as a video stream of a single image
+func isAttachedPicture(stream SourceStream) bool {
+	switch stream.Codec {
+	case "png", "bmp", "gif", "webp":
+		return true
+	}
+	return stream.Codec == "mjpeg" && stream.FrameRate == 0
+}
+
+// SourceIssuesError joins issues into one error message with their codes
+func SourceIssuesError(issues []SourceIssue) error {
+	messages := make([]string, len(issues))
+	for i, issue := range issues {
+		messages[i] = fmt.Sprintf("%s: %s", issue.Code, issue.Message)
+	}
+	return fmt.Errorf("source rejected: %s", strings.Join(messages, "; "))
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// VideoDeletionOptions controls how a video is deleted
+type VideoDeletionOptions struct {
+	// DryRun reports what would be deleted without deleting anything
+	DryRun bool
+	// Async deletes the stored objects in the background
+	Async bool
+}
+
+// VideoDeletion is the storage cleanup of a deleted video. It outlives the
+// video's row, so it carries everything needed to find the objects.
+type VideoDeletion struct {
+	VideoID      uuid.UUID `json:"video_id"`
+	SourceBucket string    `json:"source_bucket"`
+	SourceKey    string    `json:"source_key"`
+	OutputBucket string    `json:"output_bucket"`
+	OutputPrefix string    `json:"output_prefix"`
+	RequestedAt  time.Time `json:"requested_at"`
+	Attempts     int       `json:"attempts"`
+}
+
+// VideoDeletionReport lists what deleting a video removed, or would remove
+// in a dry run
+type VideoDeletionReport struct {
+	VideoID uuid.UUID `json:"video_id"`
+	DryRun  bool      `json:"dry_run"`
+	// Rows counts the database rows of the video by table
+	Rows         map[string]int64 `json:"rows"`
+	SourceObject string           `json:"source_object"`
+	OutputPrefix string           `json:"output_prefix"`
+	// Objects are the output objects, listed on dry runs and synchronous
+	// deletes
+	Objects     []string `json:"objects,omitempty"`
+	ObjectCount int      `json:"object_count"`
+	// Queued is set when the objects are deleted in the background
+	Queued bool `json:"queued"`
+}
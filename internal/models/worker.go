@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// WorkerCapabilities is what a worker advertises about its hardware so jobs
+// can be routed to workers that encode them best.
+type WorkerCapabilities struct {
+	WorkerID string `json:"worker_id"`
+	GPUs     int    `json:"gpus"`
+	// NVENCSessions is the number of concurrent hardware encodes the GPUs allow
+	NVENCSessions int `json:"nvenc_sessions"`
+	// Codecs and HWAccels are what the worker's ffmpeg can encode and the
+	// hardware it can encode on
+	Codecs    []Codec   `json:"codecs"`
+	HWAccels  []string  `json:"hw_accels"`
+	Queues    []string  `json:"queues"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// HasGPU reports whether the worker can take hardware encoding jobs.
+func (c *WorkerCapabilities) HasGPU() bool {
+	return c.NVENCSessions > 0
+}
+
+// RunningJob is a job a worker is processing right now.
+type RunningJob struct {
+	JobID    string `json:"job_id"`
+	VideoID  string `json:"video_id"`
+	UserID   string `json:"user_id"`
+	WorkerID string `json:"worker_id"`
+	// Stage is the last pipeline stage the job completed; empty while the
+	// source is still downloading
+	Stage     JobStage  `json:"stage"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// WorkerStatus is what a worker reports with every heartbeat.
+type WorkerStatus struct {
+	WorkerID string `json:"worker_id"`
+	Draining bool   `json:"draining"`
+	// Slots is how many jobs the worker runs at once and FreeSlots how many
+	// more it would take right now
+	Slots     int          `json:"slots"`
+	FreeSlots int          `json:"free_slots"`
+	Jobs      []RunningJob `json:"jobs"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
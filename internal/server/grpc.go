@@ -0,0 +1,368 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/server/videopb"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/drm"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/kms"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// defaultWatchInterval and minWatchInterval bound how often
+	// WatchVideoStatus checks the status of a video
+	defaultWatchInterval = time.Second
+	minWatchInterval     = 250 * time.Millisecond
+)
+
+// grpcPermissions is the permission each gRPC method needs, as on the REST
+// routes they mirror
+var grpcPermissions = map[string]models.Permission{
+	videopb.VideoService_CreateJob_FullMethodName:        models.PermissionVideosWrite,
+	videopb.VideoService_GetJob_FullMethodName:           models.PermissionVideosRead,
+	videopb.VideoService_GetVideoStatus_FullMethodName:   models.PermissionVideosRead,
+	videopb.VideoService_WatchVideoStatus_FullMethodName: models.PermissionVideosRead,
+	videopb.VideoService_GetPlaybackInfo_FullMethodName:  models.PermissionVideosRead,
+}
+
+// grpcAuditActions are the gRPC methods recorded in the audit log
+var grpcAuditActions = map[string]models.AuditAction{
+	videopb.VideoService_CreateJob_FullMethodName: models.AuditJobSubmit,
+}
+
+// grpcIdempotentMethods are the gRPC methods that take an idempotency key,
+// with the response message each replays
+var grpcIdempotentMethods = map[string]func() proto.Message{
+	videopb.VideoService_CreateJob_FullMethodName: func() proto.Message { return &videopb.Job{} },
+}
+
+// videoService serves the gRPC API from the same use cases as the REST API
+type videoService struct {
+	videopb.UnimplementedVideoServiceServer
+	videoUC videofiles.UseCase
+	logger  logger.Logger
+}
+
+func newVideoService(videoUC videofiles.UseCase, logger logger.Logger) *videoService {
+	return &videoService{videoUC: videoUC, logger: logger}
+}
+
+func (s *videoService) CreateJob(ctx context.Context, req *videopb.CreateJobRequest) (*videopb.Job, error) {
+	input := &models.VideoUploadInput{
+		FileName:               req.GetFilename(),
+		FileSize:               req.GetFileSize(),
+		Duration:               req.GetDuration(),
+		Codec:                  models.Codec(req.GetCodec()),
+		Format:                 req.GetFormat(),
+		Qualities:              qualitiesFromProto(req.GetQualities()),
+		EnablePerTitleEncoding: req.GetEnablePerTitleEncoding(),
+		Encrypt:                req.GetEncrypt(),
+		Visibility:             models.Visibility(req.GetVisibility()),
+		GenerateCaptions:       req.GetGenerateCaptions(),
+		CaptionLanguage:        req.GetCaptionLanguage(),
+		EnableDownloads:        req.GetEnableDownloads(),
+		EnableDRM:              req.GetEnableDrm(),
+		SourceURL:              req.GetSourceUrl(),
+	}
+	for _, format := range req.GetOutputFormats() {
+		input.OutputFormats = append(input.OutputFormats, models.PlaybackFormat(format))
+	}
+	if req.GetNotBefore() != nil {
+		notBefore := req.GetNotBefore().AsTime()
+		input.NotBefore = &notBefore
+	}
+
+	job, err := s.videoUC.CreateJob(ctx, input)
+	if err != nil {
+		return nil, s.grpcError("CreateJob", err)
+	}
+	return jobToProto(job), nil
+}
+
+func (s *videoService) GetJob(ctx context.Context, req *videopb.GetJobRequest) (*videopb.Job, error) {
+	job, err := s.videoUC.GetJob(ctx, req.GetJobId())
+	if err != nil {
+		return nil, s.grpcError("GetJob", err)
+	}
+	return jobToProto(job), nil
+}
+
+func (s *videoService) GetVideoStatus(ctx context.Context, req *videopb.GetVideoStatusRequest) (*videopb.VideoStatus, error) {
+	videoID, err := uuid.Parse(req.GetVideoId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid video id")
+	}
+	videoStatus, err := s.videoUC.GetVideoStatus(ctx, videoID)
+	if err != nil {
+		return nil, s.grpcError("GetVideoStatus", err)
+	}
+	return videoStatusToProto(videoStatus), nil
+}
+
+// WatchVideoStatus polls the status of the video and sends every change.
+// The status is read from Postgres and Redis like GetVideoStatus, so a
+// stream costs the same as a client polling at the interval, without the
+// round trips and without sending statuses that did not change.
+func (s *videoService) WatchVideoStatus(req *videopb.WatchVideoStatusRequest, stream videopb.VideoService_WatchVideoStatusServer) error {
+	videoID, err := uuid.Parse(req.GetVideoId())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "Invalid video id")
+	}
+	interval := defaultWatchInterval
+	if req.GetIntervalMs() > 0 {
+		interval = max(time.Duration(req.GetIntervalMs())*time.Millisecond, minWatchInterval)
+	}
+
+	ctx := stream.Context()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var last *models.VideoStatus
+	for {
+		videoStatus, err := s.videoUC.GetVideoStatus(ctx, videoID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return status.FromContextError(ctx.Err()).Err()
+			}
+			return s.grpcError("WatchVideoStatus", err)
+		}
+		if last == nil || statusChanged(last, videoStatus) {
+			if err := stream.Send(videoStatusToProto(videoStatus)); err != nil {
+				return err
+			}
+			last = videoStatus
+		}
+		if videoFinished(videoStatus) {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+func (s *videoService) GetPlaybackInfo(ctx context.Context, req *videopb.GetPlaybackInfoRequest) (*videopb.PlaybackInfo, error) {
+	videoID, err := uuid.Parse(req.GetVideoId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid video id")
+	}
+	info, err := s.videoUC.GetPlaybackInfo(ctx, videoID, req.GetToken())
+	if err != nil {
+		return nil, s.grpcError("GetPlaybackInfo", err)
+	}
+	return playbackInfoToProto(info), nil
+}
+
+// grpcError maps an error of the video use case to the status of its kind.
+// Unexpected errors are logged and their details kept from the client.
+func (s *videoService) grpcError(method string, err error) error {
+	switch {
+	case errors.Is(err, videofiles.ErrVideoNotFound), errors.Is(err, videofiles.ErrJobNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, videofiles.ErrVideoForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, videofiles.ErrInvalidInput):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, kms.ErrNotConfigured), errors.Is(err, drm.ErrNotConfigured):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	s.logger.Errorf("gRPC %s - ERROR: %v", method, err)
+	return status.Error(codes.Internal, "Internal Server Error")
+}
+
+// statusChanged reports whether a watcher should be sent next
+func statusChanged(last, next *models.VideoStatus) bool {
+	return last.State != next.State || last.Status != next.Status || last.Stage != next.Stage ||
+		last.Progress != next.Progress || last.StageProgress != next.StageProgress || last.JobID != next.JobID
+}
+
+// videoFinished reports whether a video is in a state its job does not
+// leave on its own
+func videoFinished(videoStatus *models.VideoStatus) bool {
+	switch videoStatus.State {
+	case models.StateReady, models.StateFailed, models.StateRejected:
+		return true
+	}
+	return false
+}
+
+func qualitiesFromProto(qualities []*videopb.Quality) []models.InputQualityInfo {
+	var out []models.InputQualityInfo
+	for _, q := range qualities {
+		out = append(out, models.InputQualityInfo{
+			Quality:    models.VideoQuality(q.GetQuality()),
+			Resolution: q.GetResolution(),
+			Bitrate:    int(q.GetBitrate()),
+			MaxBitrate: int(q.GetMaxBitrate()),
+			MinBitrate: int(q.GetMinBitrate()),
+			FrameRate:  q.GetFrameRate(),
+		})
+	}
+	return out
+}
+
+func jobToProto(job *models.EncodeJob) *videopb.Job {
+	out := &videopb.Job{
+		JobId:          job.JobID,
+		VideoId:        job.VideoID,
+		Status:         string(job.Status),
+		Type:           string(job.Type),
+		Codec:          string(job.Codec),
+		Progress:       job.Progress,
+		Stage:          string(job.Stage),
+		StageProgress:  job.StageProgress,
+		StartedAt:      timestampProto(&job.StartedAt),
+		CompletedAt:    timestampProto(&job.CompletedAt),
+		FailureReason:  string(job.FailureReason),
+		FailureMessage: job.FailureMessage,
+	}
+	for _, q := range job.Qualities {
+		out.Qualities = append(out.Qualities, &videopb.Quality{
+			Quality:    string(q.Quality),
+			Resolution: q.Resolution,
+			Bitrate:    int32(q.Bitrate),
+			MaxBitrate: int32(q.MaxBitrate),
+			MinBitrate: int32(q.MinBitrate),
+			FrameRate:  q.FrameRate,
+		})
+	}
+	for _, format := range job.OutputFormats {
+		out.OutputFormats = append(out.OutputFormats, string(format))
+	}
+	return out
+}
+
+func videoStatusToProto(videoStatus *models.VideoStatus) *videopb.VideoStatus {
+	out := &videopb.VideoStatus{
+		VideoId:       videoStatus.VideoID,
+		State:         string(videoStatus.State),
+		Status:        string(videoStatus.Status),
+		Progress:      videoStatus.Progress,
+		JobId:         videoStatus.JobID,
+		JobType:       string(videoStatus.JobType),
+		Stage:         string(videoStatus.Stage),
+		StageProgress: videoStatus.StageProgress,
+		QueuedAt:      timestampProto(videoStatus.QueuedAt),
+		StartedAt:     timestampProto(videoStatus.StartedAt),
+		CompletedAt:   timestampProto(videoStatus.CompletedAt),
+		EtaSeconds:    -1,
+		UpdatedAt:     timestampProto(videoStatus.UpdatedAt),
+	}
+	if videoStatus.ETA != nil {
+		out.EtaSeconds = *videoStatus.ETA
+	}
+	if videoStatus.Error != nil {
+		out.Error = &videopb.JobError{
+			Reason:      string(videoStatus.Error.Reason),
+			Description: videoStatus.Error.Description,
+			Message:     videoStatus.Error.Message,
+		}
+	}
+	return out
+}
+
+func playbackInfoToProto(info *models.PlaybackInfo) *videopb.PlaybackInfo {
+	out := &videopb.PlaybackInfo{
+		VideoId:        info.VideoID,
+		Title:          info.Title,
+		Status:         string(info.Status),
+		Kind:           string(info.Kind),
+		Duration:       info.Duration,
+		Thumbnail:      info.Thumbnail,
+		Subtitles:      info.Subtitles,
+		TokenExpiresAt: timestampProto(info.TokenExpiresAt),
+	}
+	for quality, q := range info.Qualities {
+		out.Renditions = append(out.Renditions, &videopb.PlaybackRendition{
+			Quality:    string(quality),
+			Resolution: q.Resolution,
+			Bitrate:    int32(q.Bitrate),
+			FrameRate:  q.FrameRate,
+			HlsUrl:     q.URLs.HLS,
+			DashUrl:    q.URLs.DASH,
+			Mp4Url:     q.URLs.MP4,
+		})
+	}
+	sort.Slice(out.Renditions, func(i, j int) bool {
+		return out.Renditions[i].Quality < out.Renditions[j].Quality
+	})
+	return out
+}
+
+// timestampProto converts an optional time, leaving unset and zero times out
+func timestampProto(t *time.Time) *timestamppb.Timestamp {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+// newGRPCServer returns the gRPC server of the API. With tlsConfig the
+// server uses TLS, the same as the REST API.
+func (s *Server) newGRPCServer(tlsConfig *tls.Config) *grpc.Server {
+	opts := []grpc.ServerOption{
+		// In the order of the middleware of the REST routes
+		grpc.ChainUnaryInterceptor(
+			s.mw.GRPCUnaryAuth(grpcPermissions),
+			s.mw.GRPCUnaryIdempotent(grpcIdempotentMethods),
+			s.mw.GRPCUnaryAudit(grpcAuditActions),
+			s.mw.GRPCUnaryRateLimit("jobs", s.cfg.RateLimit.Jobs, videopb.VideoService_CreateJob_FullMethodName),
+		),
+		grpc.ChainStreamInterceptor(s.mw.GRPCStreamAuth(grpcPermissions)),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(grpcTLSConfig(tlsConfig))))
+	}
+	grpcServer := grpc.NewServer(opts...)
+	videopb.RegisterVideoServiceServer(grpcServer, s.videoService)
+	return grpcServer
+}
+
+// grpcTLSConfig makes tlsConfig negotiate HTTP/2, which gRPC clients
+// require, including in the configs it returns per client
+func grpcTLSConfig(tlsConfig *tls.Config) *tls.Config {
+	cfg := tlsConfig.Clone()
+	cfg.NextProtos = []string{"h2"}
+	if getConfig := cfg.GetConfigForClient; getConfig != nil {
+		cfg.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			clientConfig, err := getConfig(hello)
+			if err != nil || clientConfig == nil {
+				return clientConfig, err
+			}
+			clientConfig = clientConfig.Clone()
+			clientConfig.NextProtos = []string{"h2"}
+			return clientConfig, nil
+		}
+	}
+	return cfg
+}
+
+// stopGRPCServer lets calls in flight finish until ctx is done, then closes
+// the ones left, such as status streams of videos still processing
+func stopGRPCServer(ctx context.Context, grpcServer *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		grpcServer.Stop()
+	}
+}
@@ -1,11 +1,16 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"net/http"
 
 	analyticsHttp "github.com/amankumarsingh77/cloud-video-encoder/internal/analytics/delivery/http"
 	analyticsRepository "github.com/amankumarsingh77/cloud-video-encoder/internal/analytics/repository"
 	analyticsUsecase "github.com/amankumarsingh77/cloud-video-encoder/internal/analytics/usecase"
+	auditHttp "github.com/amankumarsingh77/cloud-video-encoder/internal/audit/delivery/http"
+	auditRepository "github.com/amankumarsingh77/cloud-video-encoder/internal/audit/repository"
+	auditUsecase "github.com/amankumarsingh77/cloud-video-encoder/internal/audit/usecase"
 	authHttp "github.com/amankumarsingh77/cloud-video-encoder/internal/auth/delivery/http"
 	authRepository "github.com/amankumarsingh77/cloud-video-encoder/internal/auth/repository"
 	authUsecase "github.com/amankumarsingh77/cloud-video-encoder/internal/auth/usecase"
@@ -15,8 +20,14 @@ import (
 	videoHttp "github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles/delivery/http"
 	videoRepository "github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles/repository"
 	videoUsecase "github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles/usecase"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/geoip"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/idempotency"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/kms"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/ratelimit"
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
 	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func (s *Server) MapHandlers(e *echo.Echo) error {
@@ -24,35 +35,77 @@ func (s *Server) MapHandlers(e *echo.Echo) error {
 	aRepo := authRepository.NewAuthRepo(s.db)
 	nRepo := videoRepository.NewVideoRepo(s.db)
 	vAWSRepo := videoRepository.NewAwsRepository(s.s3Client, s.preSignClient)
-	vRedisRepo := videoRepository.NewVideoRedisRepo(s.redisClient)
+	jobQueue, err := videoRepository.NewQueue(s.cfg, s.redisClient)
+	if err != nil {
+		return err
+	}
+	vRedisRepo := videoRepository.NewVideoRedisRepo(s.redisClient, jobQueue)
 	sRepo := sessionRepository.NewSessionRepository(s.redisClient, s.cfg)
 	analyticsRepo := analyticsRepository.NewPostgresRepository(s.db, s.logger)
+	liveRepo := analyticsRepository.NewLiveRepository(s.redisClient)
+	auditRepo := auditRepository.NewAuditRepo(s.db)
+
+	keyManager, err := kms.New(s.cfg.Encryption.MasterKey)
+	if err != nil && !errors.Is(err, kms.ErrNotConfigured) {
+		return err
+	}
 
 	// Use cases
 	authUC := authUsecase.NewAuthUseCase(s.cfg, aRepo, s.logger)
-	videoUC := videoUsecase.NewVideoUseCase(s.cfg, nRepo, vRedisRepo, vAWSRepo, s.logger)
+	videoUC := videoUsecase.NewVideoUseCase(s.cfg, nRepo, vRedisRepo, vAWSRepo, keyManager, s.logger)
 	sessUC := usecase.NewSessionUseCase(sRepo, s.cfg)
-	analyticsUC := analyticsUsecase.NewAnalyticsUseCase(analyticsRepo, s.logger)
+	geoResolver, err := geoip.NewResolver(s.cfg.Analytics.GeoIPDatabase)
+	if err != nil {
+		return err
+	}
+	s.viewBuffer = analyticsUsecase.NewViewBuffer(analyticsRepo, s.cfg.Analytics, s.logger)
+	analyticsUC := analyticsUsecase.NewAnalyticsUseCase(s.cfg, analyticsRepo, liveRepo, geoResolver, vAWSRepo, s.viewBuffer, s.logger)
+	auditUC := auditUsecase.NewAuditUseCase(auditRepo, s.logger)
+
+	// Background jobs
+	go videoUsecase.NewDeletionJob(vRedisRepo, vAWSRepo, s.logger).Run(context.Background())
+	go videoUsecase.NewTrashPurgeJob(s.cfg, nRepo, vRedisRepo, s.logger).Run(context.Background())
+	go analyticsUsecase.NewRetentionJob(analyticsRepo, s.cfg.Analytics, s.logger).Run(context.Background())
+	go analyticsUsecase.NewDeliveryLogJob(analyticsRepo, vAWSRepo, s.cfg.Analytics, s.logger).Run(context.Background())
+	// Buffered views are written until the server shuts down
+	var viewsCtx context.Context
+	viewsCtx, s.stopViewBuffer = context.WithCancel(context.Background())
+	go s.viewBuffer.Run(viewsCtx)
 
 	// Handlers
 	authHandlers := authHttp.NewAuthHandler(s.cfg, authUC, sessUC, s.logger)
 	videoHandlers := videoHttp.NewVideoHandler(videoUC)
-	analyticsHandlers := analyticsHttp.NewAnalyticsHandlers(analyticsUC, s.logger)
+	analyticsHandlers := analyticsHttp.NewAnalyticsHandlers(s.cfg, analyticsUC, s.logger)
+	auditHandlers := auditHttp.NewAuditHandler(auditUC)
 
 	// Middleware
-	mw := middleware.NewMiddlewareManager(authUC, s.cfg, []string{"*"}, sessUC, s.logger)
+	limiter := ratelimit.NewLimiter(s.redisClient, "ratelimit:")
+	idempotencyStore := idempotency.NewStore(s.redisClient, "idempotency:")
+	mw := middleware.NewMiddlewareManager(authUC, auditUC, s.cfg, []string{"*"}, sessUC, limiter, idempotencyStore, s.logger)
+
+	// The gRPC API is served by Run from the same use cases and middleware
+	s.videoService = newVideoService(videoUC, s.logger)
+	s.mw = mw
 
 	// API groups
-	v1 := e.Group("/api/v1")
+	v1 := e.Group("/api/v1", mw.LocalizeErrors)
 	health := v1.Group("/health")
 	authGroup := v1.Group("/auth")
 	videoGroup := v1.Group("/video")
 	analyticsGroup := v1.Group("/analytics")
+	adminGroup := v1.Group("/admin")
+	auditGroup := v1.Group("/audit-logs")
 
 	// Map routes
 	authHttp.MapAuthRoutes(authGroup, authHandlers, mw, authUC, s.cfg)
 	videoHttp.MapVideoRoutes(videoGroup, videoHandlers, mw)
+	videoHttp.MapAdminRoutes(adminGroup, videoHandlers, mw)
 	analyticsHttp.MapAnalyticsRoutes(analyticsGroup, analyticsHandlers, mw)
+	auditHttp.MapAuditRoutes(auditGroup, auditHandlers, mw)
+
+	// Concurrent viewers are read from Redis when scraped
+	prometheus.MustRegister(analyticsUsecase.NewViewersCollector(liveRepo, s.logger))
+	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
 
 	health.GET("", func(c echo.Context) error {
 		s.logger.Infof("Health check RequestID: %s", utils.GetRequestID(c))
@@ -2,19 +2,25 @@ package server
 
 import (
 	"context"
+	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	analyticsUsecase "github.com/amankumarsingh77/cloud-video-encoder/internal/analytics/usecase"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
+	apiMiddleware "github.com/amankumarsingh77/cloud-video-encoder/internal/middleware"
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/mtls"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/go-redis/redis/v8"
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"google.golang.org/grpc"
 )
 
 const (
@@ -30,6 +36,15 @@ type Server struct {
 	s3Client      *s3.Client
 	preSignClient *s3.PresignClient
 	logger        logger.Logger
+
+	// viewBuffer holds video views until they are written; stopViewBuffer
+	// makes it write what is left
+	viewBuffer     *analyticsUsecase.ViewBuffer
+	stopViewBuffer context.CancelFunc
+
+	// videoService and mw serve the gRPC API, when it is enabled
+	videoService *videoService
+	mw           *apiMiddleware.MiddlewareManager
 }
 
 func NewServer(cfg *config.Config, db *sqlx.DB, redisClient *redis.Client, s3Client *s3.Client, preSignClient *s3.PresignClient, logger logger.Logger) *Server {
@@ -45,6 +60,11 @@ func NewServer(cfg *config.Config, db *sqlx.DB, redisClient *redis.Client, s3Cli
 }
 
 func (s *Server) Run() error {
+	ipExtractor, err := newIPExtractor(s.cfg.Server.TrustedProxies)
+	if err != nil {
+		return err
+	}
+	s.echo.IPExtractor = ipExtractor
 	if err := s.MapHandlers(s.echo); err != nil {
 		return nil
 	}
@@ -52,8 +72,8 @@ func (s *Server) Run() error {
 	s.echo.Server.ReadTimeout = time.Second * s.echo.Server.ReadTimeout
 	s.echo.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins:     []string{"http://localhost:5173","https://streamscale-dev.aksdev.me","https://aksdev.me"}, // Add your frontend URLs here
-		AllowMethods:     []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions},
-		AllowHeaders:     []string{"Content-Type", "Authorization"},
+		AllowMethods:     []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions},
+		AllowHeaders:     []string{"Content-Type", "Authorization", "X-Account-ID", "Idempotency-Key", "Upload-Offset"},
 		AllowCredentials: true, // This is crucial for cookies
 		MaxAge:           300,  // Optional: cache preflight requests
 	}))
@@ -63,11 +83,38 @@ func (s *Server) Run() error {
 		IdleTimeout:  time.Second * s.echo.Server.IdleTimeout,
 		WriteTimeout: time.Second * s.echo.Server.WriteTimeout,
 	}
+	// Admin routes check the client certificate; other clients need none
+	if s.cfg.MTLS.CertFile != "" {
+		reloader, err := mtls.NewReloader(mtls.Options{
+			CertFile:     s.cfg.MTLS.CertFile,
+			KeyFile:      s.cfg.MTLS.KeyFile,
+			CAFile:       s.cfg.MTLS.CAFile,
+			AllowedNames: s.cfg.MTLS.AllowedNames,
+		})
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = reloader.ServerConfig(false)
+	}
 	go func() {
 		if err := s.echo.StartServer(server); err != nil {
 			s.logger.Fatal("error starting  Server: ", err)
 		}
 	}()
+	var grpcServer *grpc.Server
+	if s.cfg.Server.GRPCPort != "" {
+		listener, err := net.Listen("tcp", s.cfg.Server.GRPCPort)
+		if err != nil {
+			return err
+		}
+		grpcServer = s.newGRPCServer(server.TLSConfig)
+		go func() {
+			s.logger.Infof("gRPC server listening on %s", s.cfg.Server.GRPCPort)
+			if err := grpcServer.Serve(listener); err != nil {
+				s.logger.Fatal("error starting gRPC Server: ", err)
+			}
+		}()
+	}
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGTERM, os.Interrupt)
 	<-quit
@@ -75,5 +122,39 @@ func (s *Server) Run() error {
 	ctx, shutdown := context.WithTimeout(context.Background(), time.Second*ctxTimeout)
 	defer shutdown()
 	s.logger.Infof("shutting down server")
-	return s.echo.Server.Shutdown(ctx)
+	err = s.echo.Server.Shutdown(ctx)
+	if grpcServer != nil {
+		stopGRPCServer(ctx, grpcServer)
+	}
+
+	// Requests are done, so no more views come in
+	s.stopViewBuffer()
+	select {
+	case <-s.viewBuffer.Done():
+	case <-ctx.Done():
+		s.logger.Warnf("shutting down before buffered views were written")
+	}
+	return err
+}
+
+// newIPExtractor returns how client IPs are read from requests: from
+// X-Forwarded-For as far as it was written by trustedProxies, or from the
+// peer address when there are none, so clients cannot pick their IP
+func newIPExtractor(trustedProxies []string) (echo.IPExtractor, error) {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, cidr := range trustedProxies {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+		}
+		options = append(options, echo.TrustIPRange(network))
+	}
+	return echo.ExtractIPFromXFFHeader(options...), nil
 }
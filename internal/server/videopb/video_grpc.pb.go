@@ -0,0 +1,303 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: video.proto
+
+package videopb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	VideoService_CreateJob_FullMethodName        = "/streamscale.video.v1.VideoService/CreateJob"
+	VideoService_GetJob_FullMethodName           = "/streamscale.video.v1.VideoService/GetJob"
+	VideoService_GetVideoStatus_FullMethodName   = "/streamscale.video.v1.VideoService/GetVideoStatus"
+	VideoService_WatchVideoStatus_FullMethodName = "/streamscale.video.v1.VideoService/WatchVideoStatus"
+	VideoService_GetPlaybackInfo_FullMethodName  = "/streamscale.video.v1.VideoService/GetPlaybackInfo"
+)
+
+// VideoServiceClient is the client API for VideoService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type VideoServiceClient interface {
+	// CreateJob queues the encode of an uploaded file, or of a source URL.
+	// Retries sent with the same "idempotency-key" metadata return the first
+	// job instead of queueing another.
+	CreateJob(ctx context.Context, in *CreateJobRequest, opts ...grpc.CallOption) (*Job, error)
+	// GetJob returns a job by its id
+	GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error)
+	// GetVideoStatus returns the processing state of a video
+	GetVideoStatus(ctx context.Context, in *GetVideoStatusRequest, opts ...grpc.CallOption) (*VideoStatus, error)
+	// WatchVideoStatus sends the status of a video whenever its state, stage
+	// or progress changes, and ends once the video is ready, failed or
+	// rejected
+	WatchVideoStatus(ctx context.Context, in *WatchVideoStatusRequest, opts ...grpc.CallOption) (VideoService_WatchVideoStatusClient, error)
+	// GetPlaybackInfo returns the playback URLs of a video
+	GetPlaybackInfo(ctx context.Context, in *GetPlaybackInfoRequest, opts ...grpc.CallOption) (*PlaybackInfo, error)
+}
+
+type videoServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVideoServiceClient(cc grpc.ClientConnInterface) VideoServiceClient {
+	return &videoServiceClient{cc}
+}
+
+func (c *videoServiceClient) CreateJob(ctx context.Context, in *CreateJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	out := new(Job)
+	err := c.cc.Invoke(ctx, VideoService_CreateJob_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *videoServiceClient) GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	out := new(Job)
+	err := c.cc.Invoke(ctx, VideoService_GetJob_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *videoServiceClient) GetVideoStatus(ctx context.Context, in *GetVideoStatusRequest, opts ...grpc.CallOption) (*VideoStatus, error) {
+	out := new(VideoStatus)
+	err := c.cc.Invoke(ctx, VideoService_GetVideoStatus_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *videoServiceClient) WatchVideoStatus(ctx context.Context, in *WatchVideoStatusRequest, opts ...grpc.CallOption) (VideoService_WatchVideoStatusClient, error) {
+	stream, err := c.cc.NewStream(ctx, &VideoService_ServiceDesc.Streams[0], VideoService_WatchVideoStatus_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &videoServiceWatchVideoStatusClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type VideoService_WatchVideoStatusClient interface {
+	Recv() (*VideoStatus, error)
+	grpc.ClientStream
+}
+
+type videoServiceWatchVideoStatusClient struct {
+	grpc.ClientStream
+}
+
+func (x *videoServiceWatchVideoStatusClient) Recv() (*VideoStatus, error) {
+	m := new(VideoStatus)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *videoServiceClient) GetPlaybackInfo(ctx context.Context, in *GetPlaybackInfoRequest, opts ...grpc.CallOption) (*PlaybackInfo, error) {
+	out := new(PlaybackInfo)
+	err := c.cc.Invoke(ctx, VideoService_GetPlaybackInfo_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// VideoServiceServer is the server API for VideoService service.
+// All implementations must embed UnimplementedVideoServiceServer
+// for forward compatibility
+type VideoServiceServer interface {
+	// CreateJob queues the encode of an uploaded file, or of a source URL.
+	// Retries sent with the same "idempotency-key" metadata return the first
+	// job instead of queueing another.
+	CreateJob(context.Context, *CreateJobRequest) (*Job, error)
+	// GetJob returns a job by its id
+	GetJob(context.Context, *GetJobRequest) (*Job, error)
+	// GetVideoStatus returns the processing state of a video
+	GetVideoStatus(context.Context, *GetVideoStatusRequest) (*VideoStatus, error)
+	// WatchVideoStatus sends the status of a video whenever its state, stage
+	// or progress changes, and ends once the video is ready, failed or
+	// rejected
+	WatchVideoStatus(*WatchVideoStatusRequest, VideoService_WatchVideoStatusServer) error
+	// GetPlaybackInfo returns the playback URLs of a video
+	GetPlaybackInfo(context.Context, *GetPlaybackInfoRequest) (*PlaybackInfo, error)
+	mustEmbedUnimplementedVideoServiceServer()
+}
+
+// UnimplementedVideoServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedVideoServiceServer struct {
+}
+
+func (UnimplementedVideoServiceServer) CreateJob(context.Context, *CreateJobRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateJob not implemented")
+}
+func (UnimplementedVideoServiceServer) GetJob(context.Context, *GetJobRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJob not implemented")
+}
+func (UnimplementedVideoServiceServer) GetVideoStatus(context.Context, *GetVideoStatusRequest) (*VideoStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetVideoStatus not implemented")
+}
+func (UnimplementedVideoServiceServer) WatchVideoStatus(*WatchVideoStatusRequest, VideoService_WatchVideoStatusServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchVideoStatus not implemented")
+}
+func (UnimplementedVideoServiceServer) GetPlaybackInfo(context.Context, *GetPlaybackInfoRequest) (*PlaybackInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPlaybackInfo not implemented")
+}
+func (UnimplementedVideoServiceServer) mustEmbedUnimplementedVideoServiceServer() {}
+
+// UnsafeVideoServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VideoServiceServer will
+// result in compilation errors.
+type UnsafeVideoServiceServer interface {
+	mustEmbedUnimplementedVideoServiceServer()
+}
+
+func RegisterVideoServiceServer(s grpc.ServiceRegistrar, srv VideoServiceServer) {
+	s.RegisterService(&VideoService_ServiceDesc, srv)
+}
+
+func _VideoService_CreateJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).CreateJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VideoService_CreateJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).CreateJob(ctx, req.(*CreateJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_GetJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).GetJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VideoService_GetJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).GetJob(ctx, req.(*GetJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_GetVideoStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVideoStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).GetVideoStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VideoService_GetVideoStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).GetVideoStatus(ctx, req.(*GetVideoStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_WatchVideoStatus_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchVideoStatusRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VideoServiceServer).WatchVideoStatus(m, &videoServiceWatchVideoStatusServer{stream})
+}
+
+type VideoService_WatchVideoStatusServer interface {
+	Send(*VideoStatus) error
+	grpc.ServerStream
+}
+
+type videoServiceWatchVideoStatusServer struct {
+	grpc.ServerStream
+}
+
+func (x *videoServiceWatchVideoStatusServer) Send(m *VideoStatus) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _VideoService_GetPlaybackInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPlaybackInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).GetPlaybackInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VideoService_GetPlaybackInfo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).GetPlaybackInfo(ctx, req.(*GetPlaybackInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// VideoService_ServiceDesc is the grpc.ServiceDesc for VideoService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var VideoService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "streamscale.video.v1.VideoService",
+	HandlerType: (*VideoServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateJob",
+			Handler:    _VideoService_CreateJob_Handler,
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    _VideoService_GetJob_Handler,
+		},
+		{
+			MethodName: "GetVideoStatus",
+			Handler:    _VideoService_GetVideoStatus_Handler,
+		},
+		{
+			MethodName: "GetPlaybackInfo",
+			Handler:    _VideoService_GetPlaybackInfo_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchVideoStatus",
+			Handler:       _VideoService_WatchVideoStatus_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "video.proto",
+}
@@ -4,6 +4,7 @@ package session
 import (
 	"context"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
 )
 
 // Session repository
@@ -11,4 +12,9 @@ type SessRepository interface {
 	CreateSession(ctx context.Context, session *models.Session, expire int) (string, error)
 	GetSessionByID(ctx context.Context, sessionID string) (*models.Session, error)
 	DeleteByID(ctx context.Context, sessionID string) error
+	RefreshSession(ctx context.Context, sessionID string, session *models.Session, expire int) error
+	GetUserSessions(ctx context.Context, userID uuid.UUID) (map[string]*models.Session, error)
+	SaveRefreshToken(ctx context.Context, token, sessionID string, expire int) error
+	UseRefreshToken(ctx context.Context, token string, expire int) (string, error)
+	GetRefreshTokenSession(ctx context.Context, token string) (string, error)
 }
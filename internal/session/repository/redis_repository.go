@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -17,6 +19,13 @@ import (
 
 const (
 	basePrefix = "api-session:"
+	// userIndexPrefix keys the set of session keys of each user
+	userIndexPrefix = "api-session-user:"
+	// refreshPrefix keys the session of each refresh token and
+	// refreshUsedPrefix marks the tokens that were exchanged. Tokens are
+	// keyed by their hash so the keys do not grant access.
+	refreshPrefix     = "api-refresh:"
+	refreshUsedPrefix = "api-refresh-used:"
 )
 
 // Session repository
@@ -41,7 +50,12 @@ func (s *sessionRepo) CreateSession(ctx context.Context, sess *models.Session, e
 	if err != nil {
 		return "", errors.WithMessage(err, "sessionRepo.CreateSession.json.Marshal")
 	}
-	if err = s.redisClient.Set(ctx, sessionKey, sessBytes, time.Second*time.Duration(expire)).Err(); err != nil {
+	ttl := time.Second * time.Duration(expire)
+	if _, err = s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, sessionKey, sessBytes, ttl)
+		s.index(ctx, pipe, sess.UserID, sessionKey, ttl)
+		return nil
+	}); err != nil {
 		return "", errors.Wrap(err, "sessionRepo.CreateSession.redisClient.Set")
 	}
 	return sessionKey, nil
@@ -63,13 +77,147 @@ func (s *sessionRepo) GetSessionByID(ctx context.Context, sessionID string) (*mo
 
 // Delete session by id
 func (s *sessionRepo) DeleteByID(ctx context.Context, sessionID string) error {
+	sess, err := s.GetSessionByID(ctx, sessionID)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return errors.Wrap(err, "sessionRepo.DeleteByID.GetSessionByID")
+	}
 
-	if err := s.redisClient.Del(ctx, sessionID).Err(); err != nil {
+	if _, err := s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, sessionID)
+		if sess != nil {
+			pipe.SRem(ctx, s.userKey(sess.UserID), sessionID)
+		}
+		return nil
+	}); err != nil {
 		return errors.Wrap(err, "sessionRepo.DeleteByID")
 	}
 	return nil
 }
 
+// RefreshSession stores the session again and restarts its expiry. A session
+// deleted meanwhile, e.g. revoked from another device, stays deleted.
+func (s *sessionRepo) RefreshSession(ctx context.Context, sessionID string, sess *models.Session, expire int) error {
+	sessBytes, err := json.Marshal(sess)
+	if err != nil {
+		return errors.WithMessage(err, "sessionRepo.RefreshSession.json.Marshal")
+	}
+
+	ttl := time.Second * time.Duration(expire)
+	stored, err := s.redisClient.SetXX(ctx, sessionID, sessBytes, ttl).Result()
+	if err != nil {
+		return errors.Wrap(err, "sessionRepo.RefreshSession.redisClient.SetXX")
+	}
+	if !stored {
+		return nil
+	}
+	// Sessions created before the index existed are added on their first
+	// refresh
+	if _, err := s.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		s.index(ctx, pipe, sess.UserID, sessionID, ttl)
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "sessionRepo.RefreshSession.index")
+	}
+	return nil
+}
+
+// GetUserSessions returns the live sessions of a user by their id. Expired
+// sessions still in the user's index are removed from it.
+func (s *sessionRepo) GetUserSessions(ctx context.Context, userID uuid.UUID) (map[string]*models.Session, error) {
+	userKey := s.userKey(userID)
+	sessionIDs, err := s.redisClient.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, "sessionRepo.GetUserSessions.redisClient.SMembers")
+	}
+	sessions := make(map[string]*models.Session, len(sessionIDs))
+	if len(sessionIDs) == 0 {
+		return sessions, nil
+	}
+
+	values, err := s.redisClient.MGet(ctx, sessionIDs...).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, "sessionRepo.GetUserSessions.redisClient.MGet")
+	}
+	var expired []interface{}
+	for i, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			expired = append(expired, sessionIDs[i])
+			continue
+		}
+		sess := &models.Session{}
+		if err := json.Unmarshal([]byte(raw), sess); err != nil {
+			return nil, errors.Wrap(err, "sessionRepo.GetUserSessions.json.Unmarshal")
+		}
+		sessions[sessionIDs[i]] = sess
+	}
+	if len(expired) > 0 {
+		if err := s.redisClient.SRem(ctx, userKey, expired...).Err(); err != nil {
+			return nil, errors.Wrap(err, "sessionRepo.GetUserSessions.redisClient.SRem")
+		}
+	}
+	return sessions, nil
+}
+
+// SaveRefreshToken stores a refresh token of the session sessionID
+func (s *sessionRepo) SaveRefreshToken(ctx context.Context, token, sessionID string, expire int) error {
+	ttl := time.Second * time.Duration(expire)
+	if err := s.redisClient.Set(ctx, refreshPrefix+hashToken(token), sessionID, ttl).Err(); err != nil {
+		return errors.Wrap(err, "sessionRepo.SaveRefreshToken.redisClient.Set")
+	}
+	return nil
+}
+
+// UseRefreshToken exchanges a refresh token and returns its session. A token
+// exchanged before returns its session with session.ErrRefreshTokenReused.
+// Marking the token used is atomic, so of concurrent exchanges only one
+// succeeds.
+func (s *sessionRepo) UseRefreshToken(ctx context.Context, token string, expire int) (string, error) {
+	sessionID, err := s.GetRefreshTokenSession(ctx, token)
+	if err != nil {
+		return "", err
+	}
+
+	ttl := time.Second * time.Duration(expire)
+	first, err := s.redisClient.SetNX(ctx, refreshUsedPrefix+hashToken(token), 1, ttl).Result()
+	if err != nil {
+		return "", errors.Wrap(err, "sessionRepo.UseRefreshToken.redisClient.SetNX")
+	}
+	if !first {
+		return sessionID, session.ErrRefreshTokenReused
+	}
+	return sessionID, nil
+}
+
+// GetRefreshTokenSession returns the session of a refresh token
+func (s *sessionRepo) GetRefreshTokenSession(ctx context.Context, token string) (string, error) {
+	sessionID, err := s.redisClient.Get(ctx, refreshPrefix+hashToken(token)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", session.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "sessionRepo.GetRefreshTokenSession.redisClient.Get")
+	}
+	return sessionID, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// index adds a session to the index of its user, which lives as long as the
+// user's most recently refreshed session
+func (s *sessionRepo) index(ctx context.Context, pipe redis.Pipeliner, userID uuid.UUID, sessionID string, ttl time.Duration) {
+	userKey := s.userKey(userID)
+	pipe.SAdd(ctx, userKey, sessionID)
+	pipe.Expire(ctx, userKey, ttl)
+}
+
 func (s *sessionRepo) createKey(sessionID string) string {
 	return fmt.Sprintf("%s:%s", s.basePrefix, sessionID)
 }
+
+func (s *sessionRepo) userKey(userID uuid.UUID) string {
+	return userIndexPrefix + userID.String()
+}
@@ -3,7 +3,22 @@ package session
 
 import (
 	"context"
+	"errors"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
+)
+
+// ErrDeviceNotFound is returned when revoking a session the user does not have
+var ErrDeviceNotFound = errors.New("device not found")
+
+var (
+	// ErrInvalidRefreshToken is returned for refresh tokens that are unknown,
+	// expired or whose device was signed out
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned for refresh tokens that were already
+	// exchanged. Only a stolen copy or a replay presents one twice, so the
+	// device they belong to is signed out.
+	ErrRefreshTokenReused = errors.New("refresh token was already used")
 )
 
 // Session use case
@@ -11,4 +26,11 @@ type UCSession interface {
 	CreateSession(ctx context.Context, session *models.Session, expire int) (string, error)
 	GetSessionByID(ctx context.Context, sessionID string) (*models.Session, error)
 	DeleteByID(ctx context.Context, sessionID string) error
+	RefreshSession(ctx context.Context, sessionID string, session *models.Session) (bool, error)
+	ListDevices(ctx context.Context, userID uuid.UUID, currentSessionID string) ([]*models.Device, error)
+	RevokeDevice(ctx context.Context, userID uuid.UUID, deviceID string) error
+	RevokeAllDevices(ctx context.Context, userID uuid.UUID, keepSessionID string) (int, error)
+	IssueRefreshToken(ctx context.Context, sessionID string) (string, error)
+	RotateRefreshToken(ctx context.Context, token string) (string, *models.Session, error)
+	RevokeRefreshToken(ctx context.Context, token string) error
 }
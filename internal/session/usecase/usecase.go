@@ -2,11 +2,25 @@ package usecase
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/session"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/useragent"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
 )
 
+// RefreshInterval is how long a session is used before its expiry restarts.
+// Refreshing on every request would write to Redis for each of them.
+const RefreshInterval = time.Minute
+
 // Session use case
 type sessionUC struct {
 	sessionRepo session.SessRepository
@@ -20,6 +34,10 @@ func NewSessionUseCase(sessionRepo session.SessRepository, cfg *config.Config) s
 
 // Create new session
 func (u *sessionUC) CreateSession(ctx context.Context, session *models.Session, expire int) (string, error) {
+	now := time.Now()
+	session.DeviceID = uuid.New().String()
+	session.CreatedAt = now
+	session.LastSeenAt = now
 	return u.sessionRepo.CreateSession(ctx, session, expire)
 }
 
@@ -33,3 +51,151 @@ func (u *sessionUC) DeleteByID(ctx context.Context, sessionID string) error {
 func (u *sessionUC) GetSessionByID(ctx context.Context, sessionID string) (*models.Session, error) {
 	return u.sessionRepo.GetSessionByID(ctx, sessionID)
 }
+
+// RefreshSession records activity on a session and restarts its expiry, so
+// sessions in use do not expire. It reports whether the session was
+// refreshed, which happens at most once per RefreshInterval.
+func (u *sessionUC) RefreshSession(ctx context.Context, sessionID string, session *models.Session) (bool, error) {
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) < RefreshInterval {
+		return false, nil
+	}
+	// Sessions created before devices were tracked get an id on first use
+	if session.DeviceID == "" {
+		session.DeviceID = uuid.New().String()
+	}
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = now
+	}
+	session.LastSeenAt = now
+	if err := u.sessionRepo.RefreshSession(ctx, sessionID, session, u.cfg.Session.Expire); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ListDevices returns the active sessions of a user, most recently used
+// first. currentSessionID marks the session of the request.
+func (u *sessionUC) ListDevices(ctx context.Context, userID uuid.UUID, currentSessionID string) ([]*models.Device, error) {
+	sessions, err := u.sessionRepo.GetUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make([]*models.Device, 0, len(sessions))
+	for sessionID, sess := range sessions {
+		agent := useragent.Parse(sess.UserAgent)
+		devices = append(devices, &models.Device{
+			ID:         sess.DeviceID,
+			Current:    sessionID == currentSessionID,
+			DeviceType: agent.DeviceType,
+			Browser:    agent.Browser,
+			OS:         agent.OS,
+			IP:         sess.IP,
+			CreatedAt:  sess.CreatedAt,
+			LastSeenAt: sess.LastSeenAt,
+		})
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].LastSeenAt.After(devices[j].LastSeenAt)
+	})
+	return devices, nil
+}
+
+// RevokeDevice signs the user out of the session with deviceID
+func (u *sessionUC) RevokeDevice(ctx context.Context, userID uuid.UUID, deviceID string) error {
+	sessions, err := u.sessionRepo.GetUserSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for sessionID, sess := range sessions {
+		if deviceID != "" && sess.DeviceID == deviceID {
+			return u.sessionRepo.DeleteByID(ctx, sessionID)
+		}
+	}
+	return session.ErrDeviceNotFound
+}
+
+// RevokeAllDevices signs the user out of every session but keepSessionID,
+// which may be empty to sign out everywhere, and returns how many were
+// revoked
+func (u *sessionUC) RevokeAllDevices(ctx context.Context, userID uuid.UUID, keepSessionID string) (int, error) {
+	sessions, err := u.sessionRepo.GetUserSessions(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	revoked := 0
+	for sessionID := range sessions {
+		if sessionID == keepSessionID {
+			continue
+		}
+		if err := u.sessionRepo.DeleteByID(ctx, sessionID); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// IssueRefreshToken returns the first refresh token of the session
+// sessionID. Token clients are signed in with a session like cookie clients,
+// so their devices are listed and revoked along with the others, and stay
+// signed in as long as they keep refreshing.
+func (u *sessionUC) IssueRefreshToken(ctx context.Context, sessionID string) (string, error) {
+	return u.newRefreshToken(ctx, sessionID)
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one and returns the
+// session of the device. Each token is exchanged once; presenting it again
+// signs the device out.
+func (u *sessionUC) RotateRefreshToken(ctx context.Context, token string) (string, *models.Session, error) {
+	expire := utils.RefreshTokenExpire(u.cfg)
+	sessionID, err := u.sessionRepo.UseRefreshToken(ctx, token, expire)
+	if errors.Is(err, session.ErrRefreshTokenReused) {
+		if delErr := u.sessionRepo.DeleteByID(ctx, sessionID); delErr != nil {
+			return "", nil, fmt.Errorf("failed to revoke device of reused token: %w", delErr)
+		}
+		return "", nil, err
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	// The device may have been signed out since the token was issued
+	sess, err := u.sessionRepo.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return "", nil, session.ErrInvalidRefreshToken
+	}
+	sess.LastSeenAt = time.Now()
+	if err := u.sessionRepo.RefreshSession(ctx, sessionID, sess, expire); err != nil {
+		return "", nil, err
+	}
+
+	next, err := u.newRefreshToken(ctx, sessionID)
+	if err != nil {
+		return "", nil, err
+	}
+	return next, sess, nil
+}
+
+// RevokeRefreshToken signs out the device of a refresh token
+func (u *sessionUC) RevokeRefreshToken(ctx context.Context, token string) error {
+	sessionID, err := u.sessionRepo.GetRefreshTokenSession(ctx, token)
+	if err != nil {
+		return err
+	}
+	return u.sessionRepo.DeleteByID(ctx, sessionID)
+}
+
+// newRefreshToken generates a refresh token of the session sessionID
+func (u *sessionUC) newRefreshToken(ctx context.Context, sessionID string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	if err := u.sessionRepo.SaveRefreshToken(ctx, token, sessionID, utils.RefreshTokenExpire(u.cfg)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
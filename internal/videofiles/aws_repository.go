@@ -2,15 +2,27 @@ package videofiles
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
+// ErrStorageUnavailable is returned while the object storage circuit breaker
+// is open. Jobs failing with it are retried once storage recovers.
+var ErrStorageUnavailable = errors.New("storage_unavailable")
+
 type AWSRepository interface {
 	GetPresignedURL(ctx context.Context, input *models.UploadInput) (string, error)
 	PutObject(ctx context.Context, input models.UploadInput) (*s3.PutObjectOutput, error)
+	PutObjectMultipart(ctx context.Context, input models.UploadInput, partSize int64, concurrency int) error
+	GetPresignedObjectURL(ctx context.Context, bucket, filename string, expires time.Duration) (string, error)
 	GetObject(ctx context.Context, bucket, filename string) (*s3.GetObjectOutput, error)
 	ListObjects(ctx context.Context, bucket string) ([]string, error)
+	ListObjectsWithPrefix(ctx context.Context, bucket, prefix string) ([]string, error)
 	RemoveObject(ctx context.Context, bucket, filename string) error
+	CopyObject(ctx context.Context, bucket, srcKey, dstKey string) error
+	ProbeStorage(ctx context.Context, bucket string) error
+	StorageAvailable() bool
 }
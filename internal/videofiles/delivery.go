@@ -7,11 +7,66 @@ type Handler interface {
 	UploadVideo() echo.HandlerFunc
 	ListVideos() echo.HandlerFunc
 	GetVideoByID() echo.HandlerFunc
+	GetVideoStatus() echo.HandlerFunc
 	DeleteVideo() echo.HandlerFunc
+	RestoreVideo() echo.HandlerFunc
+	ListTrash() echo.HandlerFunc
+	PurgeVideo() echo.HandlerFunc
 	GetPlaybackInfo() echo.HandlerFunc
+	GetPlayerConfig() echo.HandlerFunc
 	SearchVideos() echo.HandlerFunc
 	UpdateVideo() echo.HandlerFunc
 	CreateJob() echo.HandlerFunc
+	CreateUncachedJob() echo.HandlerFunc
+	GetJob() echo.HandlerFunc
+	EstimateOutputSize() echo.HandlerFunc
+	EstimateCost() echo.HandlerFunc
+	StreamVideo() echo.HandlerFunc
+	ServeOrigin() echo.HandlerFunc
+	UpdateVisibility() echo.HandlerFunc
+	RotateShareToken() echo.HandlerFunc
+	SetPoster() echo.HandlerFunc
+	MoveVideo() echo.HandlerFunc
+	ListStalledVideos() echo.HandlerFunc
+	ListJobEnvironments() echo.HandlerFunc
+	GetSourceReport() echo.HandlerFunc
+	FindDuplicates() echo.HandlerFunc
+	ValidateSource() echo.HandlerFunc
+	StartSmokeTest() echo.HandlerFunc
+	GetSmokeTest() echo.HandlerFunc
+
+	ListQueueDepths() echo.HandlerFunc
+	PeekQueue() echo.HandlerFunc
+	GetJobHash() echo.HandlerFunc
+	PurgeQueuedJob() echo.HandlerFunc
+	ListWorkers() echo.HandlerFunc
+	ListRunningJobs() echo.HandlerFunc
+	ListFailedJobs() echo.HandlerFunc
+	GetThroughputStats() echo.HandlerFunc
+	RequeueJob() echo.HandlerFunc
+	CancelJob() echo.HandlerFunc
+	RepackageVideo() echo.HandlerFunc
+	ReencodeVideo() echo.HandlerFunc
+	PlanEncode() echo.HandlerFunc
+	GetEncodePlan() echo.HandlerFunc
+	ListPlaybackVersions() echo.HandlerFunc
+	RollbackPlaybackVersion() echo.HandlerFunc
+	RepackageLibrary() echo.HandlerFunc
+	IngestS3Event() echo.HandlerFunc
+
+	CreateChunkedUpload() echo.HandlerFunc
+	UploadChunk() echo.HandlerFunc
+	GetChunkedUploadStatus() echo.HandlerFunc
+	CompleteChunkedUpload() echo.HandlerFunc
+	StreamUpload() echo.HandlerFunc
+	GetUploadProgress() echo.HandlerFunc
+
+	CreateFolder() echo.HandlerFunc
+	ListFolders() echo.HandlerFunc
+	ListFolderVideos() echo.HandlerFunc
+	RenameFolder() echo.HandlerFunc
+	MoveFolder() echo.HandlerFunc
+	DeleteFolder() echo.HandlerFunc
 
 	//GetVideoThumbnail() echo.HandlerFunc  // Coming soon ;)
 }
@@ -1,11 +1,17 @@
 package http
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
@@ -63,13 +69,33 @@ func (h *videoHandler) GetVideoByID() echo.HandlerFunc {
 	}
 }
 
+// GetVideoStatus returns the processing state, progress, stage, ETA and
+// failure of a video in one response
+func (h *videoHandler) GetVideoStatus() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		status, err := h.videoUC.GetVideoStatus(c.Request().Context(), videoID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, status)
+	}
+}
+
 func (h *videoHandler) ListVideos() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		pagination, err := utils.GetPaginationFromCtx(c)
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
-		videos, err := h.videoUC.ListVideos(c.Request().Context(), pagination)
+		folderID, err := parseOptionalUUID(c.QueryParam("folder_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid folder id"})
+		}
+		videos, err := h.videoUC.ListVideos(c.Request().Context(), folderID, pagination)
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
@@ -87,7 +113,8 @@ func (h *videoHandler) SearchVideos() echo.HandlerFunc {
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
-		videos, err := h.videoUC.SearchVideos(c.Request().Context(), query, pagination)
+		highlight, _ := strconv.ParseBool(c.QueryParam("highlight"))
+		videos, err := h.videoUC.SearchVideos(c.Request().Context(), query, highlight, pagination)
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
@@ -101,11 +128,58 @@ func (h *videoHandler) DeleteVideo() echo.HandlerFunc {
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
 		}
-		err = h.videoUC.DeleteVideo(c.Request().Context(), videoID)
+		video, err := h.videoUC.DeleteVideo(c.Request().Context(), videoID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, video)
+	}
+}
+
+func (h *videoHandler) RestoreVideo() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		video, err := h.videoUC.RestoreVideo(c.Request().Context(), videoID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, video)
+	}
+}
+
+func (h *videoHandler) ListTrash() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		pagination, err := utils.GetPaginationFromCtx(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		videos, err := h.videoUC.ListTrash(c.Request().Context(), pagination)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, videos)
+	}
+}
+
+func (h *videoHandler) PurgeVideo() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		dryRun, _ := strconv.ParseBool(c.QueryParam("dry_run"))
+		async, _ := strconv.ParseBool(c.QueryParam("async"))
+		report, err := h.videoUC.PurgeVideo(c.Request().Context(), videoID, models.VideoDeletionOptions{
+			DryRun: dryRun,
+			Async:  async,
+		})
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
-		return c.JSON(http.StatusOK, map[string]string{"message": "Video deleted successfully"})
+		return c.JSON(http.StatusOK, report)
 	}
 }
 
@@ -129,7 +203,7 @@ func (h *videoHandler) GetPlaybackInfo() echo.HandlerFunc {
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
 		}
-		playbackInfo, err := h.videoUC.GetPlaybackInfo(c.Request().Context(), videoID)
+		playbackInfo, err := h.videoUC.GetPlaybackInfo(c.Request().Context(), videoID, c.QueryParam("token"))
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
@@ -137,6 +211,20 @@ func (h *videoHandler) GetPlaybackInfo() echo.HandlerFunc {
 	}
 }
 
+func (h *videoHandler) GetPlayerConfig() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		playerConfig, err := h.videoUC.GetPlayerConfig(c.Request().Context(), videoID, c.QueryParam("token"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, playerConfig)
+	}
+}
+
 func (h *videoHandler) CreateJob() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		input := &models.VideoUploadInput{}
@@ -152,3 +240,805 @@ func (h *videoHandler) CreateJob() echo.HandlerFunc {
 		return c.JSON(http.StatusOK, job)
 	}
 }
+
+// CreateUncachedJob takes the same payload as CreateJob and always encodes
+// the source, even when the user encoded the same file with the same
+// settings before and its outputs could be copied.
+func (h *videoHandler) CreateUncachedJob() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		input := &models.VideoUploadInput{}
+		if err := c.Bind(input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		input.BypassCache = true
+
+		job, err := h.videoUC.CreateJob(c.Request().Context(), input)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+
+		return c.JSON(http.StatusOK, job)
+	}
+}
+
+// EstimateOutputSize takes the same payload as CreateJob and reports the
+// storage the output would need without queueing anything.
+func (h *videoHandler) EstimateOutputSize() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		input := &models.VideoUploadInput{}
+		if err := c.Bind(input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		estimate, err := h.videoUC.EstimateOutputSize(c.Request().Context(), input)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, estimate)
+	}
+}
+
+// EstimateCost reports the encode time and cost of a source from its
+// duration, resolution, codec and profile
+func (h *videoHandler) EstimateCost() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		input := &models.CostEstimateInput{}
+		if err := c.Bind(input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		estimate, err := h.videoUC.EstimateCost(c.Request().Context(), input)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, estimate)
+	}
+}
+
+// ValidateSource runs the pre-flight checks on an upload before a job is
+// created for it
+func (h *videoHandler) ValidateSource() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		input := &models.ValidateSourceInput{}
+		if err := c.Bind(input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		validation, err := h.videoUC.ValidateSource(c.Request().Context(), input)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, validation)
+	}
+}
+
+func (h *videoHandler) GetJob() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		job, err := h.videoUC.GetJob(c.Request().Context(), c.Param("job_id"))
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, job)
+	}
+}
+
+// StreamVideo proxies playlists and segments of encrypted videos, which cannot
+// be served from the CDN because S3 only decrypts them with the video's key.
+// Unlisted videos are authorized by the share token in the token query param.
+func (h *videoHandler) StreamVideo() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		object, err := h.videoUC.GetStreamObject(c.Request().Context(), videoID, c.Param("*"), c.QueryParam("token"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		defer object.Body.Close()
+
+		contentType := aws.ToString(object.ContentType)
+		if contentType == "" {
+			contentType = echo.MIMEOctetStream
+		}
+		c.Response().Header().Set(echo.HeaderCacheControl, "private, no-store")
+		return c.Stream(http.StatusOK, contentType, object.Body)
+	}
+}
+
+// ServeOrigin serves the outputs of a video to holders of a playback token,
+// so the output bucket can stay private. It is the origin the CDN or the
+// player fetches playlists and segments from.
+func (h *videoHandler) ServeOrigin() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		origin, err := h.videoUC.GetOriginObject(c.Request().Context(), videoID, c.Param("*"))
+		if err != nil {
+			if errors.Is(err, videofiles.ErrPlaybackForbidden) {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+			}
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+
+		// Objects are only served to token holders, so shared caches must
+		// not keep them
+		c.Response().Header().Set(echo.HeaderCacheControl, "private, no-store")
+		if origin.RedirectURL != "" {
+			return c.Redirect(http.StatusFound, origin.RedirectURL)
+		}
+		defer origin.Object.Body.Close()
+
+		contentType := aws.ToString(origin.Object.ContentType)
+		if contentType == "" {
+			contentType = echo.MIMEOctetStream
+		}
+		return c.Stream(http.StatusOK, contentType, origin.Object.Body)
+	}
+}
+
+func (h *videoHandler) UpdateVisibility() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		input := &models.VisibilityInput{}
+		if err := c.Bind(input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		video, err := h.videoUC.UpdateVisibility(c.Request().Context(), videoID, input)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, video)
+	}
+}
+
+func (h *videoHandler) RotateShareToken() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		video, err := h.videoUC.RotateShareToken(c.Request().Context(), videoID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, video)
+	}
+}
+
+func (h *videoHandler) SetPoster() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		input := &models.PosterInput{}
+		if err := c.Bind(input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		playbackInfo, err := h.videoUC.SetPoster(c.Request().Context(), videoID, input)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, playbackInfo)
+	}
+}
+
+func (h *videoHandler) MoveVideo() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		input := &models.MoveVideoInput{}
+		if err := c.Bind(input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		if err = h.videoUC.MoveVideo(c.Request().Context(), videoID, input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "Video moved successfully"})
+	}
+}
+
+func (h *videoHandler) ListStalledVideos() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videos, err := h.videoUC.ListStalledVideos(c.Request().Context())
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, videos)
+	}
+}
+
+func (h *videoHandler) ListJobEnvironments() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		envs, err := h.videoUC.ListJobEnvironments(c.Request().Context(), videoID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, envs)
+	}
+}
+
+// GetSourceReport returns what the uploaded source of a video contains
+func (h *videoHandler) GetSourceReport() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		report, err := h.videoUC.GetSourceReport(c.Request().Context(), videoID)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, report)
+	}
+}
+
+// FindDuplicates lists the videos of the account that look like a video.
+// min_similarity, between 0 and 1, sets how alike they must be.
+func (h *videoHandler) FindDuplicates() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		var minSimilarity float64
+		if raw := c.QueryParam("min_similarity"); raw != "" {
+			if minSimilarity, err = strconv.ParseFloat(raw, 64); err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid min_similarity"})
+			}
+		}
+		report, err := h.videoUC.FindDuplicates(c.Request().Context(), videoID, minSimilarity)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, report)
+	}
+}
+
+// StartSmokeTest runs a generated test video through the pipeline to check a
+// new environment end to end.
+func (h *videoHandler) StartSmokeTest() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		input := &models.SmokeTestInput{}
+		if err := c.Bind(input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		test, err := h.videoUC.StartSmokeTest(c.Request().Context(), input)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusAccepted, test)
+	}
+}
+
+func (h *videoHandler) GetSmokeTest() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		test, err := h.videoUC.GetSmokeTest(c.Request().Context(), videoID)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, test)
+	}
+}
+
+func (h *videoHandler) ListQueueDepths() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		depths, err := h.videoUC.ListQueueDepths(c.Request().Context())
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, depths)
+	}
+}
+
+// PeekQueue lists queued jobs without dequeueing them.
+func (h *videoHandler) PeekQueue() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		pagination, err := utils.GetPaginationFromCtx(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		page, err := h.videoUC.PeekQueue(c.Request().Context(), c.Param("queue"), pagination)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, page)
+	}
+}
+
+func (h *videoHandler) GetJobHash() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		fields, err := h.videoUC.GetJobHash(c.Request().Context(), c.Param("job_id"))
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, fields)
+	}
+}
+
+// PurgeQueuedJob removes a payload, typically a malformed one, from a queue.
+func (h *videoHandler) PurgeQueuedJob() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		input := &models.PurgeJobInput{}
+		if err := c.Bind(input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		if err := h.videoUC.PurgeQueuedJob(c.Request().Context(), c.Param("queue"), input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "Job purged successfully"})
+	}
+}
+
+func (h *videoHandler) ListWorkers() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		workers, err := h.videoUC.ListWorkers(c.Request().Context())
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, workers)
+	}
+}
+
+func (h *videoHandler) ListRunningJobs() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		jobs, err := h.videoUC.ListRunningJobs(c.Request().Context())
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, jobs)
+	}
+}
+
+func (h *videoHandler) ListFailedJobs() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		limit := 0
+		if raw := c.QueryParam("limit"); raw != "" {
+			var err error
+			if limit, err = strconv.Atoi(raw); err != nil || limit <= 0 {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid limit"})
+			}
+		}
+		jobs, err := h.videoUC.ListFailedJobs(c.Request().Context(), limit)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, jobs)
+	}
+}
+
+// GetThroughputStats returns job throughput over time. The range is given as
+// RFC 3339 from and to parameters and grouped by the hour or day interval.
+func (h *videoHandler) GetThroughputStats() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		query := &models.ThroughputQuery{Interval: models.StatsInterval(c.QueryParam("interval"))}
+		for param, value := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+			raw := c.QueryParam(param)
+			if raw == "" {
+				continue
+			}
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid " + param + " time"})
+			}
+			*value = parsed
+		}
+		stats, err := h.videoUC.GetThroughputStats(c.Request().Context(), query)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, stats)
+	}
+}
+
+func (h *videoHandler) RequeueJob() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		job, err := h.videoUC.RequeueJob(c.Request().Context(), c.Param("job_id"))
+		if err != nil {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusAccepted, job)
+	}
+}
+
+func (h *videoHandler) CancelJob() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if err := h.videoUC.CancelJob(c.Request().Context(), c.Param("job_id")); err != nil {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "Job cancelled successfully"})
+	}
+}
+
+func (h *videoHandler) RepackageVideo() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		input := &models.RepackageInput{}
+		if err := c.Bind(input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		job, err := h.videoUC.RepackageVideo(c.Request().Context(), videoID, input)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusAccepted, job)
+	}
+}
+
+// ReencodeVideo queues an encode of a finished video with a new profile into
+// a new playback version.
+func (h *videoHandler) ReencodeVideo() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		input := &models.ReencodeInput{}
+		if err := c.Bind(input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		job, err := h.videoUC.ReencodeVideo(c.Request().Context(), videoID, input)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusAccepted, job)
+	}
+}
+
+// PlanEncode queues a dry run of a video with a profile. The plan is
+// pending until a worker has probed the source.
+func (h *videoHandler) PlanEncode() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		input := &models.EncodePlanInput{}
+		if err := c.Bind(input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		plan, err := h.videoUC.PlanEncode(c.Request().Context(), videoID, input)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusAccepted, plan)
+	}
+}
+
+func (h *videoHandler) GetEncodePlan() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		plan, err := h.videoUC.GetEncodePlan(c.Request().Context(), videoID, c.Param("job_id"))
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, plan)
+	}
+}
+
+func (h *videoHandler) ListPlaybackVersions() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		versions, err := h.videoUC.ListPlaybackVersions(c.Request().Context(), videoID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, versions)
+	}
+}
+
+func (h *videoHandler) RollbackPlaybackVersion() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		videoID, err := uuid.Parse(c.Param("video_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid video id"})
+		}
+		version, err := strconv.Atoi(c.Param("version"))
+		if err != nil || version < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid version"})
+		}
+		versions, err := h.videoUC.RollbackPlaybackVersion(c.Request().Context(), videoID, version)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, versions)
+	}
+}
+
+// RepackageLibrary queues a repackage of every finished video, for format
+// migrations.
+func (h *videoHandler) RepackageLibrary() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		input := &models.RepackageInput{}
+		if err := c.Bind(input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		summary, err := h.videoUC.RepackageLibrary(c.Request().Context(), input)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusAccepted, summary)
+	}
+}
+
+// maxIngestEventSize bounds the body of an S3 notification. SNS messages are
+// at most 256KB.
+const maxIngestEventSize = 512 << 10
+
+// IngestS3Event accepts S3 notifications either raw or wrapped by SNS, which
+// posts them as text/plain and so cannot be bound. SNS subscription
+// confirmations are answered as well.
+func (h *videoHandler) IngestS3Event() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxIngestEventSize))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		ctx := c.Request().Context()
+		token := c.QueryParam("token")
+
+		event := &models.S3Event{}
+		if messageType := c.Request().Header.Get("x-amz-sns-message-type"); messageType != "" {
+			message := &models.SNSMessage{}
+			if err = json.Unmarshal(body, message); err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+			}
+			switch message.Type {
+			case models.SNSTypeSubscriptionConfirmation:
+				if err = h.videoUC.ConfirmIngestSubscription(ctx, token, message); err != nil {
+					return c.JSON(ingestErrorStatus(err), map[string]string{"error": err.Error()})
+				}
+				return c.JSON(http.StatusOK, map[string]string{"message": "Subscription confirmed successfully"})
+			case models.SNSTypeNotification:
+				body = []byte(message.Message)
+			default:
+				return c.NoContent(http.StatusNoContent)
+			}
+		}
+		if err = json.Unmarshal(body, event); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+
+		summary, err := h.videoUC.IngestS3Event(ctx, token, event)
+		if err != nil {
+			return c.JSON(ingestErrorStatus(err), map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusAccepted, summary)
+	}
+}
+
+func ingestErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, videofiles.ErrIngestDisabled):
+		return http.StatusNotFound
+	case errors.Is(err, videofiles.ErrIngestUnauthorized):
+		return http.StatusUnauthorized
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func (h *videoHandler) CreateChunkedUpload() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		input := &models.ChunkedUploadInput{}
+		if err := c.Bind(input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		upload, err := h.videoUC.CreateChunkedUpload(c.Request().Context(), input)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusCreated, upload)
+	}
+}
+
+// UploadChunk takes the raw chunk as the request body and its hex encoded
+// SHA-256 in the X-Chunk-Checksum header.
+func (h *videoHandler) UploadChunk() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		index, err := strconv.Atoi(c.Param("index"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid chunk index"})
+		}
+		status, err := h.videoUC.UploadChunk(c.Request().Context(), c.Param("upload_id"), index, c.Request().Header.Get("X-Chunk-Checksum"), c.Request().Body)
+		if err != nil {
+			return chunkErrorResponse(c, err)
+		}
+		return c.JSON(http.StatusOK, status)
+	}
+}
+
+func (h *videoHandler) GetChunkedUploadStatus() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		status, err := h.videoUC.GetChunkedUploadStatus(c.Request().Context(), c.Param("upload_id"))
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, status)
+	}
+}
+
+func (h *videoHandler) CompleteChunkedUpload() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		video, err := h.videoUC.CompleteChunkedUpload(c.Request().Context(), c.Param("upload_id"))
+		if err != nil {
+			return chunkErrorResponse(c, err)
+		}
+		return c.JSON(http.StatusCreated, video)
+	}
+}
+
+// StreamUpload receives the file of a chunked upload as the request body,
+// starting at the offset in the Upload-Offset header
+func (h *videoHandler) StreamUpload() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var offset int64
+		if header := c.Request().Header.Get("Upload-Offset"); header != "" {
+			parsed, err := strconv.ParseInt(header, 10, 64)
+			if err != nil || parsed < 0 {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid Upload-Offset header"})
+			}
+			offset = parsed
+		}
+		progress, err := h.videoUC.StreamUpload(c.Request().Context(), c.Param("upload_id"), offset, c.Request().Body)
+		if err != nil {
+			var offsetErr *videofiles.UploadOffsetError
+			if errors.As(err, &offsetErr) {
+				return c.JSON(http.StatusConflict, offsetErr)
+			}
+			return chunkErrorResponse(c, err)
+		}
+		c.Response().Header().Set("Upload-Offset", strconv.FormatInt(progress.Offset, 10))
+		return c.JSON(http.StatusOK, progress)
+	}
+}
+
+func (h *videoHandler) GetUploadProgress() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		progress, err := h.videoUC.GetUploadProgress(c.Request().Context(), c.Param("upload_id"))
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, progress)
+	}
+}
+
+// chunkErrorResponse reports missing or corrupt chunks with the hints the
+// client needs to retry them.
+func chunkErrorResponse(c echo.Context, err error) error {
+	var chunkErr *videofiles.ChunkError
+	if errors.As(err, &chunkErr) {
+		return c.JSON(http.StatusUnprocessableEntity, chunkErr)
+	}
+	return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+}
+
+func (h *videoHandler) CreateFolder() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		input := &models.FolderInput{}
+		if err := c.Bind(input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		folder, err := h.videoUC.CreateFolder(c.Request().Context(), input)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusCreated, folder)
+	}
+}
+
+func (h *videoHandler) ListFolders() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		parentID, err := parseOptionalUUID(c.QueryParam("parent_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid parent id"})
+		}
+		folders, err := h.videoUC.ListFolders(c.Request().Context(), parentID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, folders)
+	}
+}
+
+func (h *videoHandler) ListFolderVideos() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		folderID, err := uuid.Parse(c.Param("folder_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid folder id"})
+		}
+		pagination, err := utils.GetPaginationFromCtx(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		videos, err := h.videoUC.ListVideos(c.Request().Context(), &folderID, pagination)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, videos)
+	}
+}
+
+func (h *videoHandler) RenameFolder() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		folderID, err := uuid.Parse(c.Param("folder_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid folder id"})
+		}
+		input := &models.RenameFolderInput{}
+		if err := c.Bind(input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		folder, err := h.videoUC.RenameFolder(c.Request().Context(), folderID, input)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, folder)
+	}
+}
+
+func (h *videoHandler) MoveFolder() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		folderID, err := uuid.Parse(c.Param("folder_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid folder id"})
+		}
+		input := &models.MoveFolderInput{}
+		if err := c.Bind(input); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		}
+		folder, err := h.videoUC.MoveFolder(c.Request().Context(), folderID, input)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, folder)
+	}
+}
+
+func (h *videoHandler) DeleteFolder() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		folderID, err := uuid.Parse(c.Param("folder_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid folder id"})
+		}
+		if err = h.videoUC.DeleteFolder(c.Request().Context(), folderID); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "Folder deleted successfully"})
+	}
+}
+
+// parseOptionalUUID parses an optional id query param, returning nil when it
+// is not set.
+func parseOptionalUUID(value string) (*uuid.UUID, error) {
+	if value == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
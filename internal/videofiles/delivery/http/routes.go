@@ -2,19 +2,94 @@ package http
 
 import (
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/middleware"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
 	"github.com/labstack/echo/v4"
 )
 
 func MapVideoRoutes(videoGroup *echo.Group, h videofiles.Handler, mw *middleware.MiddlewareManager) {
+	// Public and unlisted videos can be played without an account. Echo applies
+	// group middleware when a route is added, so this must come before Use.
+	videoGroup.GET("/:video_id/playback-info", h.GetPlaybackInfo(), mw.OptionalAuthSessionMiddleware)
+	videoGroup.GET("/:video_id/player-config", h.GetPlayerConfig(), mw.OptionalAuthSessionMiddleware)
+	// Encrypted videos are streamed through the API, to anonymous viewers too
+	videoGroup.GET("/:video_id/stream/*", h.StreamVideo(), mw.OptionalAuthSessionMiddleware)
+	// The playback origin is authorized by the token in its path
+	videoGroup.GET("/:video_id/play/:token/*", h.ServeOrigin(), mw.PlaybackTokenMiddleware)
+	// S3 notifications authenticate with the ingest token instead of a session
+	videoGroup.POST("/ingest/s3", h.IngestS3Event())
+
 	videoGroup.Use(mw.AuthSessionMiddleware)
-	videoGroup.POST("/get-upload-url", h.GetPresignUpload())
-	videoGroup.POST("/upload", h.UploadVideo())
-	videoGroup.GET("/:video_id", h.GetVideoByID())
-	videoGroup.GET("/list-videos", h.ListVideos())
-	videoGroup.GET("/search", h.SearchVideos())
-	videoGroup.DELETE("/:video_id", h.DeleteVideo())
-	videoGroup.PUT("/:video_id", h.UpdateVideo())
-	videoGroup.GET("/:video_id/playback-info", h.GetPlaybackInfo())
-	videoGroup.POST("/create-job", h.CreateJob())
+	// Members of an account act on it with the permissions of their role
+	videoGroup.Use(mw.AccountMiddleware)
+	canRead := mw.Authorize(models.PermissionVideosRead)
+	canWrite := mw.Authorize(models.PermissionVideosWrite)
+	videoGroup.POST("/get-upload-url", h.GetPresignUpload(), canWrite, mw.Audit(models.AuditVideoUpload), mw.UploadRateLimit)
+	videoGroup.POST("/upload", h.UploadVideo(), canWrite, mw.Audit(models.AuditVideoUpload), mw.UploadRateLimit)
+	videoGroup.GET("/:video_id", h.GetVideoByID(), canRead)
+	videoGroup.GET("/:video_id/status", h.GetVideoStatus(), canRead)
+	videoGroup.GET("/list-videos", h.ListVideos(), canRead)
+	videoGroup.GET("/search", h.SearchVideos(), canRead)
+	videoGroup.GET("/trash", h.ListTrash(), canRead)
+	videoGroup.DELETE("/:video_id", h.DeleteVideo(), canWrite, mw.Audit(models.AuditVideoDelete))
+	videoGroup.POST("/:video_id/restore", h.RestoreVideo(), canWrite, mw.Audit(models.AuditVideoRestore))
+	videoGroup.DELETE("/:video_id/purge", h.PurgeVideo(), canWrite, mw.Audit(models.AuditVideoPurge))
+	videoGroup.PUT("/:video_id", h.UpdateVideo(), canWrite, mw.Audit(models.AuditVideoUpdate))
+	videoGroup.PUT("/:video_id/visibility", h.UpdateVisibility(), canWrite, mw.Audit(models.AuditVideoUpdate))
+	videoGroup.POST("/:video_id/share-token", h.RotateShareToken(), canWrite, mw.Audit(models.AuditVideoUpdate))
+	videoGroup.PATCH("/:video_id/poster", h.SetPoster(), canWrite, mw.Audit(models.AuditVideoUpdate))
+	videoGroup.POST("/create-job", h.CreateJob(), canWrite, mw.Idempotent, mw.Audit(models.AuditJobSubmit), mw.JobRateLimit)
+	videoGroup.POST("/create-job/uncached", h.CreateUncachedJob(), canWrite, mw.Idempotent, mw.Audit(models.AuditJobSubmit), mw.JobRateLimit)
+	videoGroup.POST("/estimate-job", h.EstimateOutputSize(), canRead)
+	videoGroup.POST("/estimate", h.EstimateCost(), canRead)
+	videoGroup.POST("/validate", h.ValidateSource(), canWrite, mw.UploadRateLimit)
+	videoGroup.GET("/jobs/:job_id", h.GetJob(), canRead)
+	videoGroup.PUT("/:video_id/folder", h.MoveVideo(), canWrite, mw.Audit(models.AuditVideoUpdate))
+	videoGroup.GET("/:video_id/environments", h.ListJobEnvironments(), canRead)
+	videoGroup.GET("/:video_id/source", h.GetSourceReport(), canRead)
+	videoGroup.GET("/:video_id/duplicates", h.FindDuplicates(), canRead)
+	videoGroup.POST("/:video_id/repackage", h.RepackageVideo(), canWrite, mw.Idempotent, mw.Audit(models.AuditJobSubmit), mw.JobRateLimit)
+	videoGroup.POST("/:video_id/reencode", h.ReencodeVideo(), canWrite, mw.Idempotent, mw.Audit(models.AuditJobSubmit), mw.JobRateLimit)
+	videoGroup.POST("/:video_id/plan", h.PlanEncode(), canWrite, mw.JobRateLimit)
+	videoGroup.GET("/:video_id/plan/:job_id", h.GetEncodePlan(), canRead)
+	videoGroup.GET("/:video_id/versions", h.ListPlaybackVersions(), canRead)
+	videoGroup.POST("/:video_id/versions/:version/rollback", h.RollbackPlaybackVersion(), canWrite, mw.Audit(models.AuditPlaybackRollback))
+
+	videoGroup.POST("/uploads", h.CreateChunkedUpload(), canWrite, mw.Audit(models.AuditVideoUpload), mw.UploadRateLimit)
+	videoGroup.GET("/uploads/:upload_id", h.GetChunkedUploadStatus(), canRead)
+	videoGroup.PUT("/uploads/:upload_id/chunks/:index", h.UploadChunk(), canWrite)
+	videoGroup.PATCH("/uploads/:upload_id", h.StreamUpload(), canWrite)
+	videoGroup.GET("/uploads/:upload_id/progress", h.GetUploadProgress(), canRead)
+	videoGroup.POST("/uploads/:upload_id/complete", h.CompleteChunkedUpload(), canWrite, mw.Audit(models.AuditVideoUpload))
+
+	videoGroup.POST("/folders", h.CreateFolder(), canWrite, mw.Audit(models.AuditFolderCreate))
+	videoGroup.GET("/folders", h.ListFolders(), canRead)
+	videoGroup.GET("/folders/:folder_id/videos", h.ListFolderVideos(), canRead)
+	videoGroup.PUT("/folders/:folder_id", h.RenameFolder(), canWrite, mw.Audit(models.AuditFolderUpdate))
+	videoGroup.PUT("/folders/:folder_id/parent", h.MoveFolder(), canWrite, mw.Audit(models.AuditFolderUpdate))
+	videoGroup.DELETE("/folders/:folder_id", h.DeleteFolder(), canWrite, mw.Audit(models.AuditFolderDelete))
+
+	adminOnly := mw.RoleBasedAuthMiddleware([]models.Role{models.AdminRole})
+	videoGroup.GET("/admin/stalled", h.ListStalledVideos(), mw.ClientCertMiddleware, adminOnly)
+	videoGroup.POST("/admin/smoke-tests", h.StartSmokeTest(), mw.ClientCertMiddleware, adminOnly)
+	videoGroup.GET("/admin/smoke-tests/:video_id", h.GetSmokeTest(), mw.ClientCertMiddleware, adminOnly)
+}
+
+// MapAdminRoutes maps the queue and worker introspection endpoints. Every
+// route needs an admin session, and a client certificate when mTLS is enabled.
+func MapAdminRoutes(adminGroup *echo.Group, h videofiles.Handler, mw *middleware.MiddlewareManager) {
+	adminGroup.Use(mw.ClientCertMiddleware)
+	adminGroup.Use(mw.AuthSessionMiddleware)
+	adminGroup.Use(mw.RoleBasedAuthMiddleware([]models.Role{models.AdminRole}))
+	adminGroup.GET("/queues", h.ListQueueDepths())
+	adminGroup.GET("/queues/:queue/jobs", h.PeekQueue())
+	adminGroup.DELETE("/queues/:queue/jobs", h.PurgeQueuedJob())
+	adminGroup.GET("/workers", h.ListWorkers())
+	adminGroup.GET("/jobs/running", h.ListRunningJobs())
+	adminGroup.GET("/jobs/failed", h.ListFailedJobs())
+	adminGroup.GET("/stats/throughput", h.GetThroughputStats())
+	adminGroup.GET("/jobs/:job_id/hash", h.GetJobHash())
+	adminGroup.POST("/jobs/:job_id/requeue", h.RequeueJob())
+	adminGroup.POST("/jobs/:job_id/cancel", h.CancelJob())
+	adminGroup.POST("/repackage", h.RepackageLibrary())
 }
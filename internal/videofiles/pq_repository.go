@@ -2,6 +2,7 @@ package videofiles
 
 import (
 	"context"
+	"time"
 
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
@@ -10,12 +11,59 @@ import (
 
 type Repository interface {
 	CreateVideo(ctx context.Context, videoFile *models.VideoFile) (*models.VideoFile, error)
-	GetVideos(ctx context.Context, userID uuid.UUID, pq *utils.Pagination) (*models.VideoList, error)
+	GetVideos(ctx context.Context, userID uuid.UUID, folderID *uuid.UUID, pq *utils.Pagination) (*models.VideoList, error)
 	GetVideoByID(ctx context.Context, videoID uuid.UUID) (*models.VideoFile, error)
 	UpdateVideo(ctx context.Context, video *models.VideoFile) (*models.VideoFile, error)
-	GetVideosByQuery(ctx context.Context, userID uuid.UUID, query string, pq *utils.Pagination) (*models.VideoList, error)
-	DeleteVideo(ctx context.Context, userID uuid.UUID, videoID uuid.UUID) error
+	GetVideosByQuery(ctx context.Context, userID uuid.UUID, query string, highlight bool, pq *utils.Pagination) (*models.VideoList, error)
+	DeleteVideo(ctx context.Context, userID uuid.UUID, videoID uuid.UUID) (map[string]int64, error)
+	CountVideoRows(ctx context.Context, videoID uuid.UUID) (map[string]int64, error)
+	PurgeTrashedVideo(ctx context.Context, videoID uuid.UUID, deletedBefore time.Time) (map[string]int64, error)
+	TrashVideo(ctx context.Context, userID uuid.UUID, videoID uuid.UUID) (time.Time, error)
+	RestoreVideo(ctx context.Context, userID uuid.UUID, videoID uuid.UUID) error
+	GetTrashedVideos(ctx context.Context, userID uuid.UUID, pq *utils.Pagination) (*models.VideoList, error)
+	GetExpiredTrashedVideos(ctx context.Context, deletedBefore time.Time, limit int) ([]*models.VideoFile, error)
 	GetPlaybackInfo(ctx context.Context, videoID uuid.UUID) (*models.PlaybackInfo, error)
+	CreatePlaybackVersion(ctx context.Context, videoID uuid.UUID, jobID string, profile *models.ReencodeInput) (int, error)
+	ActivatePlaybackVersion(ctx context.Context, videoID uuid.UUID, version int, info *models.PlaybackInfo, drmKeyID, drmScheme string) error
+	RollbackPlaybackVersion(ctx context.Context, videoID uuid.UUID, version int) error
+	FailPlaybackVersion(ctx context.Context, videoID uuid.UUID, version int, message string) error
+	GetPlaybackVersions(ctx context.Context, videoID uuid.UUID) ([]*models.PlaybackVersion, error)
 	CreatePlaybackInfo(ctx context.Context, videoID uuid.UUID, info *models.PlaybackInfo) error
+	SetPlaybackError(ctx context.Context, videoID uuid.UUID, title, message string) error
+	SetPoster(ctx context.Context, videoID uuid.UUID, thumbnail string) error
 	UpdateVideoProgress(ctx context.Context, videoID uuid.UUID, status models.JobStatus, progress float64) error
+	UpdateVisibility(ctx context.Context, video *models.VideoFile) error
+	SetVideoWorker(ctx context.Context, videoID uuid.UUID, workerID string) error
+	SetVideoDRMKey(ctx context.Context, videoID uuid.UUID, keyID, scheme string) error
+	GetStaleVideos(ctx context.Context, progressBefore time.Time) ([]*models.VideoFile, error)
+	GetCompletedVideos(ctx context.Context) ([]*models.VideoFile, error)
+	VideoExistsByS3Key(ctx context.Context, bucket, key string) (bool, error)
+	CreateJobEnvironment(ctx context.Context, videoID uuid.UUID, env *models.JobEnvironment) error
+	GetJobEnvironments(ctx context.Context, videoID uuid.UUID) ([]*models.JobEnvironment, error)
+	SetSourceMetadata(ctx context.Context, videoID uuid.UUID, probe []byte) error
+	GetSourceMetadata(ctx context.Context, videoID uuid.UUID) ([]byte, error)
+	SaveFingerprint(ctx context.Context, fingerprint *models.VideoFingerprint) error
+	GetFingerprint(ctx context.Context, videoID uuid.UUID) (*models.VideoFingerprint, error)
+	GetUserFingerprints(ctx context.Context, userID uuid.UUID) ([]*models.VideoFingerprint, error)
+	GetOutputCache(ctx context.Context, userID uuid.UUID, sourceHash, profileHash string) (*models.OutputCacheEntry, error)
+	SaveOutputCache(ctx context.Context, entry *models.OutputCacheEntry) error
+	CreateJobEvent(ctx context.Context, event *models.JobEvent) error
+	GetLastJobEvents(ctx context.Context, videoID uuid.UUID) ([]*models.JobEvent, error)
+	CreateJobUsage(ctx context.Context, usage *models.JobUsage) error
+	CreateBillingEvent(ctx context.Context, event *models.BillingEvent) error
+	ClaimBillingEvents(ctx context.Context, limit int, backoff time.Duration, maxAttempts int) ([]*models.BillingEvent, error)
+	MarkBillingEventDelivered(ctx context.Context, id int64) error
+	GetJobThroughput(ctx context.Context, interval models.StatsInterval, from, to time.Time) ([]*models.ThroughputRow, error)
+	GetEncodeThroughput(ctx context.Context, since time.Time) ([]*models.EncodeThroughput, error)
+	GetUserPlan(ctx context.Context, userID uuid.UUID) (models.Plan, error)
+	GetStorageQuota(ctx context.Context, userID uuid.UUID) (int64, error)
+	GetStorageUsage(ctx context.Context, userID uuid.UUID) (int64, error)
+	MoveVideo(ctx context.Context, userID uuid.UUID, videoID uuid.UUID, folderID *uuid.UUID) error
+
+	CreateFolder(ctx context.Context, folder *models.Folder) (*models.Folder, error)
+	GetFolderByID(ctx context.Context, folderID uuid.UUID) (*models.Folder, error)
+	GetFolders(ctx context.Context, userID uuid.UUID, parentID *uuid.UUID) ([]*models.Folder, error)
+	UpdateFolder(ctx context.Context, folder *models.Folder) error
+	DeleteFolder(ctx context.Context, userID uuid.UUID, folderID uuid.UUID) error
+	IsFolderDescendant(ctx context.Context, folderID uuid.UUID, candidateID uuid.UUID) (bool, error)
 }
@@ -0,0 +1,39 @@
+package videofiles
+
+import (
+	"context"
+	"errors"
+)
+
+const (
+	QueueBackendRedis = "redis"
+	QueueBackendSQS   = "sqs"
+	QueueBackendNATS  = "nats"
+)
+
+var (
+	// ErrQueueEmpty is returned by Queue.Pop when no job arrived in time
+	ErrQueueEmpty = errors.New("queue is empty")
+	// ErrQueueNotBrowsable is returned when listing or removing queued jobs
+	// on a backend that only supports dispatching them
+	ErrQueueNotBrowsable = errors.New("the queue backend cannot list queued jobs")
+)
+
+// Queue dispatches job payloads to workers. Keys name the queues, such as
+// the CPU queue and its GPU counterpart; backends map them to their own
+// queues. Payloads are removed once popped; jobs lost with their worker are
+// recovered from the job hashes, not redelivered by the broker.
+type Queue interface {
+	Push(ctx context.Context, key string, payload []byte) error
+	// Pop waits briefly for a payload on the first non-empty queue in keys
+	// and returns ErrQueueEmpty if none arrived
+	Pop(ctx context.Context, keys ...string) ([]byte, error)
+	Length(ctx context.Context, key string) (int64, error)
+}
+
+// QueueBrowser is implemented by queues whose jobs can be listed and removed
+// before they are dispatched, which the admin API relies on.
+type QueueBrowser interface {
+	Peek(ctx context.Context, key string, start, stop int64) ([]string, error)
+	Remove(ctx context.Context, key string, payload []byte) (int64, error)
+}
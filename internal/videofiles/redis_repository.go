@@ -2,6 +2,7 @@ package videofiles
 
 import (
 	"context"
+	"time"
 
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
 	"github.com/go-redis/redis/v8"
@@ -11,9 +12,83 @@ type RedisRepository interface {
 	EnqueueJob(ctx context.Context, key string, videoJob *models.EncodeJob) error
 	GetJobDetails(ctx context.Context, jobID string) (*models.EncodeJob, error)
 	GetJobStatus(ctx context.Context, key string, jobID string) (models.JobStatus, error)
-	UpdateProgress(ctx context.Context, jobID string, key string, progress float64) error
+	UpdateProgress(ctx context.Context, key string, progress *models.JobProgress) error
 	UpdateStatus(ctx context.Context, jobID string, key string, status models.JobStatus) error
+	UpdateFailureReason(ctx context.Context, jobID string, reason models.FailureReason, message string) error
 	SubscribeToJobs(ctx context.Context, key string) *redis.PubSub
 	GetRedisClient() *redis.Client
-	DequeueJob(ctx context.Context, key string) (*models.EncodeJob, error)
+	DequeueJob(ctx context.Context, keys ...string) (*models.EncodeJob, error)
+	StartJob(ctx context.Context, jobID string) error
+	DeferJob(ctx context.Context, key string, job *models.EncodeJob, until time.Time) error
+	PromoteDeferredJobs(ctx context.Context, now time.Time) (int, error)
+	AcquireUserJobSlot(ctx context.Context, userID, jobID string, limit int) (bool, error)
+	ReleaseUserJobSlot(ctx context.Context, userID, jobID string) error
+	SaveCheckpoint(ctx context.Context, checkpoint *models.JobCheckpoint) error
+	GetCheckpoint(ctx context.Context, resumeToken string) (*models.JobCheckpoint, error)
+	DeleteCheckpoint(ctx context.Context, resumeToken string) error
+	SetWorkerHeartbeat(ctx context.Context, workerID string, ttl time.Duration) error
+	IsWorkerAlive(ctx context.Context, workerID string) (bool, error)
+	SetWorkerRunningJobs(ctx context.Context, workerID string, jobs int, ttl time.Duration) error
+	SetWorkerStatus(ctx context.Context, status *models.WorkerStatus, ttl time.Duration) error
+	SetJobHeartbeat(ctx context.Context, jobID, workerID string, ttl time.Duration) error
+	ClearJobHeartbeat(ctx context.Context, jobID string) error
+	ListOrphanedJobs(ctx context.Context) (map[string]string, error)
+	ClaimOrphanedJob(ctx context.Context, jobID string) (int64, error)
+	ListWorkerStatuses(ctx context.Context) ([]*models.WorkerStatus, error)
+	ListFailedJobs(ctx context.Context, limit int64) ([]string, error)
+	PublishJobCancel(ctx context.Context, jobID string) error
+	QueueLength(ctx context.Context, key string) (int64, error)
+	PeekQueue(ctx context.Context, key string, start, stop int64) ([]string, error)
+	QueuePosition(ctx context.Context, key, jobID string) (int64, error)
+	RemoveQueuedPayload(ctx context.Context, key, payload string) (int64, error)
+	GetJobHash(ctx context.Context, jobID string) (map[string]string, error)
+	SetWorkerCapabilities(ctx context.Context, caps *models.WorkerCapabilities, ttl time.Duration) error
+	ListWorkerCapabilities(ctx context.Context) ([]*models.WorkerCapabilities, error)
+	SaveSmokeTest(ctx context.Context, test *models.SmokeTest, ttl time.Duration) error
+	GetSmokeTest(ctx context.Context, videoID string) (*models.SmokeTest, error)
+	SaveEncodePlan(ctx context.Context, plan *models.EncodePlan, ttl time.Duration) error
+	GetEncodePlan(ctx context.Context, jobID string) (*models.EncodePlan, error)
+	SaveChunkedUpload(ctx context.Context, upload *models.ChunkedUpload, ttl time.Duration) error
+	GetChunkedUpload(ctx context.Context, uploadID string) (*models.ChunkedUpload, error)
+	SetChunkChecksum(ctx context.Context, uploadID string, index int, checksum string) error
+	DeleteChunkChecksum(ctx context.Context, uploadID string, index int) error
+	DeleteChunkedUpload(ctx context.Context, uploadID string) error
+	SetUploadProgress(ctx context.Context, progress *models.UploadProgress, ttl time.Duration) error
+	GetUploadProgress(ctx context.Context, uploadID string) (*models.UploadProgress, error)
+	EnqueueVideoDeletion(ctx context.Context, deletion *models.VideoDeletion) error
+	DequeueVideoDeletion(ctx context.Context, timeout time.Duration) (*models.VideoDeletion, error)
+}
+
+const (
+	// FailedJobsKey is a sorted set of failed job IDs scored by failure time
+	FailedJobsKey = "jobs:failed"
+	// FailedJobsKept is how many failures FailedJobsKey remembers
+	FailedJobsKept = 1000
+	// JobCancelChannel carries the IDs of jobs workers should stop
+	JobCancelChannel = "job_cancel_channel"
+	// ProcessingJobsKey is a hash of the jobs being processed and the
+	// workers processing them
+	ProcessingJobsKey = "jobs:processing"
+	// DeferredJobsKey is a sorted set of jobs held back from their queue,
+	// scored by when they may be queued again
+	DeferredJobsKey = "jobs:deferred"
+	// UserJobsKeyFmt is the set of jobs of a user that are being processed
+	UserJobsKeyFmt = "user:jobs:%s"
+	// VideoDeletionsKey is the queue of stored objects of deleted videos
+	// still to be removed
+	VideoDeletionsKey = "videos:deletions"
+)
+
+// GPUQueueSuffix is appended to a job queue key to get the queue of jobs
+// routed to GPU workers.
+const GPUQueueSuffix = ":gpu"
+
+// JobQueue returns the queue a job encoded with codec is enqueued on. Codecs
+// with hardware encoders go to the GPU queue while GPU workers are available;
+// everything else, such as software AV1, stays on the CPU queue.
+func JobQueue(key string, codec models.Codec, gpuAvailable bool) string {
+	if gpuAvailable && codec == models.CodecH264 {
+		return key + GPUQueueSuffix
+	}
+	return key
 }
@@ -2,25 +2,56 @@ package repository
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/url"
 	"regexp"
+	"sync"
 	"time"
 
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/circuitbreaker"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/kms"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	storageBreakerName      = "s3"
+	storageFailureThreshold = 5
+	storageOpenTimeout      = 30 * time.Second
+	sseCustomerAlgorithm    = "AES256"
+	// minPartSize and maxParts are the S3 multipart upload limits
+	minPartSize  = 5 << 20
+	maxParts     = 10000
+	abortTimeout = 30 * time.Second
 )
 
 type awsRepository struct {
 	client        *s3.Client
 	preSignClient *s3.PresignClient
+	breaker       *circuitbreaker.Breaker
 }
 
 func NewAwsRepository(awsClient *s3.Client, preSignClient *s3.PresignClient) videofiles.AWSRepository {
+	breaker := circuitbreaker.New(storageBreakerName, storageFailureThreshold, storageOpenTimeout)
+	breaker.OnStateChange(func(state circuitbreaker.State) {
+		log.Printf("Storage circuit breaker is now %s", state)
+	})
+
 	return &awsRepository{
 		preSignClient: preSignClient,
 		client:        awsClient,
+		breaker:       breaker,
 	}
 }
 
@@ -46,6 +77,23 @@ func (a *awsRepository) GetPresignedURL(ctx context.Context, input *models.Uploa
 	return pubObjectReq.URL, nil
 }
 
+// GetPresignedObjectURL returns a URL an object can be downloaded from
+// without credentials until it expires.
+func (a *awsRepository) GetPresignedObjectURL(ctx context.Context, bucket, filename string, expires time.Duration) (string, error) {
+	getObjectReq, err := a.preSignClient.PresignGetObject(
+		ctx,
+		&s3.GetObjectInput{
+			Bucket: &bucket,
+			Key:    &filename,
+		},
+		s3.WithPresignExpires(expires),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign get object : %w", err)
+	}
+	return getObjectReq.URL, nil
+}
+
 // This thing is useless as not more than 10 users can upload videos at once. But just letting it be here.
 func (a *awsRepository) PutObject(ctx context.Context, input models.UploadInput) (*s3.PutObjectOutput, error) {
 	//pattern := `^.+\.(mp4|mkv|avi|mov|wmv|flv|webm|m4v|mpeg|mpg|3gp|ogv|vob|ts|mxf|)$`
@@ -54,22 +102,162 @@ func (a *awsRepository) PutObject(ctx context.Context, input models.UploadInput)
 	//	return nil, fmt.Errorf("invalid file format: %s", input.Name)
 	//}
 	log.Println(input)
-	res, err := a.client.PutObject(
-		ctx,
-		&s3.PutObjectInput{
-			Bucket:        &input.BucketName,
-			Key:           &input.Key,
-			ContentType:   &input.MimeType,
-			ContentLength: &input.Size,
-			Body:          input.File,
-		},
-	)
+	if err := a.breaker.Allow(); err != nil {
+		return nil, fmt.Errorf("failed to upload file : %w", videofiles.ErrStorageUnavailable)
+	}
+	putInput := &s3.PutObjectInput{
+		Bucket:        &input.BucketName,
+		Key:           &input.Key,
+		ContentType:   &input.MimeType,
+		ContentLength: &input.Size,
+		Body:          input.File,
+	}
+	if input.ContentDisposition != "" {
+		putInput.ContentDisposition = &input.ContentDisposition
+	}
+	if algorithm, key, keyMD5, ok := sseCustomerKey(ctx); ok {
+		putInput.SSECustomerAlgorithm = algorithm
+		putInput.SSECustomerKey = key
+		putInput.SSECustomerKeyMD5 = keyMD5
+	}
+	res, err := a.client.PutObject(ctx, putInput)
+	a.record(err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to upload file : %w", err)
 	}
 	return res, nil
 }
 
+// PutObjectMultipart uploads input in parts of partSize bytes, concurrency of
+// them at a time. input.File must be an io.ReaderAt so parts can be read
+// independently, and retried by the SDK. The upload is aborted if any part
+// fails so no orphaned parts are left to be billed for.
+func (a *awsRepository) PutObjectMultipart(ctx context.Context, input models.UploadInput, partSize int64, concurrency int) error {
+	file, ok := input.File.(io.ReaderAt)
+	if !ok {
+		return fmt.Errorf("multipart upload of %s needs a seekable file", input.Key)
+	}
+	if partSize < minPartSize {
+		partSize = minPartSize
+	}
+	// S3 allows at most maxParts parts
+	if parts := (input.Size + partSize - 1) / partSize; parts > maxParts {
+		partSize = (input.Size + maxParts - 1) / maxParts
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if err := a.breaker.Allow(); err != nil {
+		return fmt.Errorf("failed to upload file : %w", videofiles.ErrStorageUnavailable)
+	}
+
+	createInput := &s3.CreateMultipartUploadInput{
+		Bucket:      &input.BucketName,
+		Key:         &input.Key,
+		ContentType: &input.MimeType,
+	}
+	if input.ContentDisposition != "" {
+		createInput.ContentDisposition = &input.ContentDisposition
+	}
+	algorithm, key, keyMD5, encrypted := sseCustomerKey(ctx)
+	if encrypted {
+		createInput.SSECustomerAlgorithm = algorithm
+		createInput.SSECustomerKey = key
+		createInput.SSECustomerKeyMD5 = keyMD5
+	}
+	upload, err := a.client.CreateMultipartUpload(ctx, createInput)
+	a.record(err)
+	if err != nil {
+		return fmt.Errorf("failed to start multipart upload : %w", err)
+	}
+
+	partCount := int((input.Size + partSize - 1) / partSize)
+	completed := make([]types.CompletedPart, partCount)
+	uploadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var uploadErr error
+	parts := make(chan int32)
+	for i := 0; i < min(concurrency, partCount); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for number := range parts {
+				offset := int64(number-1) * partSize
+				size := min(partSize, input.Size-offset)
+				partInput := &s3.UploadPartInput{
+					Bucket:        &input.BucketName,
+					Key:           &input.Key,
+					UploadId:      upload.UploadId,
+					PartNumber:    aws.Int32(number),
+					ContentLength: aws.Int64(size),
+					Body:          io.NewSectionReader(file, offset, size),
+				}
+				if encrypted {
+					partInput.SSECustomerAlgorithm = algorithm
+					partInput.SSECustomerKey = key
+					partInput.SSECustomerKeyMD5 = keyMD5
+				}
+				res, err := a.client.UploadPart(uploadCtx, partInput)
+				a.record(err)
+				if err != nil {
+					errOnce.Do(func() {
+						uploadErr = fmt.Errorf("failed to upload part %d : %w", number, err)
+						cancel()
+					})
+					continue
+				}
+				completed[number-1] = types.CompletedPart{ETag: res.ETag, PartNumber: aws.Int32(number)}
+			}
+		}()
+	}
+
+feed:
+	for number := int32(1); number <= int32(partCount); number++ {
+		select {
+		case parts <- number:
+		case <-uploadCtx.Done():
+			break feed
+		}
+	}
+	close(parts)
+	wg.Wait()
+
+	if uploadErr == nil && ctx.Err() != nil {
+		uploadErr = ctx.Err()
+	}
+	if uploadErr == nil {
+		_, err = a.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          &input.BucketName,
+			Key:             &input.Key,
+			UploadId:        upload.UploadId,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+			// SSE-C parameters are required here as well
+			SSECustomerAlgorithm: createInput.SSECustomerAlgorithm,
+			SSECustomerKey:       createInput.SSECustomerKey,
+			SSECustomerKeyMD5:    createInput.SSECustomerKeyMD5,
+		})
+		a.record(err)
+		if err == nil {
+			return nil
+		}
+		uploadErr = fmt.Errorf("failed to complete multipart upload : %w", err)
+	}
+
+	abortCtx, abortCancel := context.WithTimeout(context.WithoutCancel(ctx), abortTimeout)
+	defer abortCancel()
+	if _, err := a.client.AbortMultipartUpload(abortCtx, &s3.AbortMultipartUploadInput{
+		Bucket:   &input.BucketName,
+		Key:      &input.Key,
+		UploadId: upload.UploadId,
+	}); err != nil {
+		log.Printf("Failed to abort multipart upload of %s: %v", input.Key, err)
+	}
+	return uploadErr
+}
+
 func (a *awsRepository) ListObjects(ctx context.Context, bucket string) ([]string, error) {
 	res, err := a.client.ListObjectsV2(
 		ctx,
@@ -87,21 +275,121 @@ func (a *awsRepository) ListObjects(ctx context.Context, bucket string) ([]strin
 	return keys, nil
 }
 
+// ListObjectsWithPrefix returns every key under prefix, following pagination.
+func (a *awsRepository) ListObjectsWithPrefix(ctx context.Context, bucket, prefix string) ([]string, error) {
+	if err := a.breaker.Allow(); err != nil {
+		return nil, fmt.Errorf("failed to list objects : %w", videofiles.ErrStorageUnavailable)
+	}
+
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(a.client, &s3.ListObjectsV2Input{
+		Bucket: &bucket,
+		Prefix: &prefix,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		a.record(err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects : %w", err)
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, *obj.Key)
+		}
+	}
+	return keys, nil
+}
+
 func (a *awsRepository) GetObject(ctx context.Context, bucket, fileKey string) (*s3.GetObjectOutput, error) {
-	res, err := a.client.GetObject(
-		ctx,
-		&s3.GetObjectInput{
-			Bucket: &bucket,
-			Key:    &fileKey,
-		},
-	)
+	if err := a.breaker.Allow(); err != nil {
+		return nil, fmt.Errorf("failed to download file : %w", videofiles.ErrStorageUnavailable)
+	}
+	getInput := &s3.GetObjectInput{
+		Bucket: &bucket,
+		Key:    &fileKey,
+	}
+	if algorithm, key, keyMD5, ok := sseCustomerKey(ctx); ok {
+		getInput.SSECustomerAlgorithm = algorithm
+		getInput.SSECustomerKey = key
+		getInput.SSECustomerKeyMD5 = keyMD5
+	}
+	res, err := a.client.GetObject(ctx, getInput)
 	log.Println(bucket, fileKey)
+	a.record(err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to download file : %w", err)
 	}
 	return res, nil
 }
 
+// sseCustomerKey returns the SSE-C parameters for the data key carried by ctx.
+// Objects of encrypted videos are stored with the video's own key, so S3
+// never holds anything it can decrypt on its own.
+func sseCustomerKey(ctx context.Context) (algorithm, key, keyMD5 *string, ok bool) {
+	dataKey, ok := kms.DataKeyFromContext(ctx)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	sum := md5.Sum(dataKey)
+	return aws.String(sseCustomerAlgorithm),
+		aws.String(base64.StdEncoding.EncodeToString(dataKey)),
+		aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+		true
+}
+
+// ProbeStorage checks that the storage endpoint is reachable. It goes through
+// the circuit breaker so a successful probe closes a half-open breaker even
+// when no jobs are running.
+func (a *awsRepository) ProbeStorage(ctx context.Context, bucket string) error {
+	if err := a.breaker.Allow(); err != nil {
+		return videofiles.ErrStorageUnavailable
+	}
+	_, err := a.client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: &bucket,
+	})
+	a.record(err)
+	if err != nil {
+		return fmt.Errorf("storage probe failed : %w", err)
+	}
+	return nil
+}
+
+func (a *awsRepository) StorageAvailable() bool {
+	return a.breaker.State() != circuitbreaker.StateOpen
+}
+
+// record reports the outcome of a storage call to the breaker. Only server
+// errors and timeouts count as failures; client errors such as a missing key
+// say nothing about the health of the endpoint.
+func (a *awsRepository) record(err error) {
+	if err == nil || !isStorageOutage(err) {
+		a.breaker.Success()
+		return
+	}
+	a.breaker.Failure()
+}
+
+func isStorageOutage(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.HTTPStatusCode() >= 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var opErr *smithy.OperationError
+	return errors.As(err, &opErr) && !errors.As(err, new(smithy.APIError))
+}
+
 func (a *awsRepository) RemoveObject(ctx context.Context, bucket, filename string) error {
 	_, err := a.client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: &bucket,
@@ -112,3 +400,20 @@ func (a *awsRepository) RemoveObject(ctx context.Context, bucket, filename strin
 	}
 	return nil
 }
+
+// CopyObject copies an object within a bucket without downloading it
+func (a *awsRepository) CopyObject(ctx context.Context, bucket, srcKey, dstKey string) error {
+	if err := a.breaker.Allow(); err != nil {
+		return fmt.Errorf("failed to copy file : %w", videofiles.ErrStorageUnavailable)
+	}
+	_, err := a.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     &bucket,
+		Key:        &dstKey,
+		CopySource: aws.String(url.PathEscape(bucket + "/" + srcKey)),
+	})
+	a.record(err)
+	if err != nil {
+		return fmt.Errorf("failed to copy file : %w", err)
+	}
+	return nil
+}
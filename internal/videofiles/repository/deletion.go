@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// videoTables hold the rows of a video. Most cascade from video_files
+// already; deleting them explicitly lets the deletion be reported.
+var videoTables = []string{
+	"playback_info",
+	"playback_versions",
+	"video_views",
+	"video_watch_sessions",
+	"video_heartbeats",
+	"video_qoe_beacons",
+	"delivery_stats",
+	"video_engagement",
+	"job_environments",
+	"job_events",
+	"output_cache",
+	"video_fingerprints",
+	"encoding_jobs",
+}
+
+// CountVideoRows counts the rows of a video in every table holding them
+func (v *videoRepo) CountVideoRows(ctx context.Context, videoID uuid.UUID) (map[string]int64, error) {
+	rows := make(map[string]int64, len(videoTables))
+	for _, table := range videoTables {
+		var count int64
+		query := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE video_id = $1`, table)
+		if err := v.db.GetContext(ctx, &count, query, videoID); err != nil {
+			return nil, fmt.Errorf("failed to count %s rows: %w", table, err)
+		}
+		rows[table] = count
+	}
+	return rows, nil
+}
+
+// DeleteVideo deletes a video of the user and all of its rows in one
+// transaction and returns how many rows went from each table.
+func (v *videoRepo) DeleteVideo(ctx context.Context, userID uuid.UUID, videoID uuid.UUID) (map[string]int64, error) {
+	return v.deleteVideo(ctx, videoID, lockVideoQuery, videoID, userID)
+}
+
+// PurgeTrashedVideo deletes a video like DeleteVideo if it has been in the
+// trash since before deletedBefore. A video restored meanwhile is kept.
+func (v *videoRepo) PurgeTrashedVideo(ctx context.Context, videoID uuid.UUID, deletedBefore time.Time) (map[string]int64, error) {
+	return v.deleteVideo(ctx, videoID, lockTrashedVideoQuery, videoID, deletedBefore)
+}
+
+// deleteVideo deletes a video and its rows once lockQuery has found and
+// locked its row
+func (v *videoRepo) deleteVideo(ctx context.Context, videoID uuid.UUID, lockQuery string, args ...interface{}) (map[string]int64, error) {
+	tx, err := v.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked uuid.UUID
+	if err := tx.GetContext(ctx, &locked, lockQuery, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("no video found to delete: %w", err)
+		}
+		return nil, fmt.Errorf("failed to lock video: %w", err)
+	}
+
+	rows := make(map[string]int64, len(videoTables)+1)
+	for _, table := range videoTables {
+		res, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE video_id = $1`, table), videoID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete %s rows: %w", table, err)
+		}
+		rows[table], _ = res.RowsAffected()
+	}
+
+	res, err := tx.ExecContext(ctx, deleteVideoQuery, videoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete video: %w", err)
+	}
+	rows["video_files"], _ = res.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit video deletion: %w", err)
+	}
+	return rows, nil
+}
@@ -2,7 +2,9 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -39,19 +41,25 @@ func (v *videoRepo) CreateVideo(ctx context.Context, videoFile *models.VideoFile
 		videoFile.Status,
 		videoFile.S3Bucket,
 		videoFile.Format,
+		videoFile.Encrypted,
+		videoFile.EncryptedDataKey,
+		videoFile.Visibility,
+		videoFile.ShareToken,
+		videoFile.Kind,
 	).StructScan(video); err != nil {
 		return nil, fmt.Errorf("failed to create video: %w", err)
 	}
 	return video, nil
 }
 
-func (v *videoRepo) GetVideos(ctx context.Context, userID uuid.UUID, query *utils.Pagination) (*models.VideoList, error) {
+func (v *videoRepo) GetVideos(ctx context.Context, userID uuid.UUID, folderID *uuid.UUID, query *utils.Pagination) (*models.VideoList, error) {
 	var totalCount int
 	if err := v.db.GetContext(
 		ctx,
 		&totalCount,
 		getTotalVideosByUserIDQuery,
 		userID,
+		folderID,
 	); err != nil {
 		return nil, fmt.Errorf("failed to get total videos count: %w", err)
 	}
@@ -68,6 +76,7 @@ func (v *videoRepo) GetVideos(ctx context.Context, userID uuid.UUID, query *util
 		ctx,
 		getVideosByUserIDQuery,
 		userID,
+		folderID,
 		query.GetOffset(),
 		query.GetLimit(),
 	)
@@ -122,13 +131,17 @@ func (v *videoRepo) UpdateVideo(ctx context.Context, video *models.VideoFile) (*
 		&video.S3Bucket,
 		&video.Format,
 		&video.Status,
+		&video.Title,
+		&video.Description,
+		video.Tags,
+		&video.VideoID,
 	); err != nil {
 		return nil, fmt.Errorf("failed to update video: %w", err)
 	}
 	return videoFile, nil
 }
 
-func (v *videoRepo) GetVideosByQuery(ctx context.Context, userID uuid.UUID, query string, pq *utils.Pagination) (*models.VideoList, error) {
+func (v *videoRepo) GetVideosByQuery(ctx context.Context, userID uuid.UUID, query string, highlight bool, pq *utils.Pagination) (*models.VideoList, error) {
 	var totalCount int
 	if err := v.db.GetContext(
 		ctx,
@@ -155,6 +168,7 @@ func (v *videoRepo) GetVideosByQuery(ctx context.Context, userID uuid.UUID, quer
 		query,
 		pq.GetOffset(),
 		pq.GetLimit(),
+		highlight,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get videos by query: %w", err)
@@ -180,31 +194,18 @@ func (v *videoRepo) GetVideosByQuery(ctx context.Context, userID uuid.UUID, quer
 	}, nil
 }
 
-func (v *videoRepo) DeleteVideo(ctx context.Context, userID uuid.UUID, videoID uuid.UUID) error {
-	res, err := v.db.ExecContext(
-		ctx,
-		deleteVideoQuery,
-		videoID,
-		userID,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to delete video: %w", err)
-	}
-	count, _ := res.RowsAffected()
-	if count == 0 {
-		return fmt.Errorf("no video found to delete")
-	}
-	return nil
-}
-
 func (v *videoRepo) GetPlaybackInfo(ctx context.Context, videoID uuid.UUID) (*models.PlaybackInfo, error) {
 	query := `
 		SELECT
-			video_id, title, duration, thumbnail,
+			video_id, title, duration, thumbnail, thumbnails,
+			COALESCE(preview, '') as preview,
 			COALESCE(qualities::text, '{}') as qualities,
 			COALESCE(subtitles, ARRAY[]::text[]) as subtitles,
 			format, status, error_message,
-			created_at, updated_at
+			created_at, updated_at, version,
+			COALESCE(chapters::text, '[]') as chapters,
+			kind, COALESCE(waveform, '') as waveform,
+			COALESCE(images::text, '[]') as images
 		FROM playback_info
 		WHERE video_id = $1`
 
@@ -213,6 +214,8 @@ func (v *videoRepo) GetPlaybackInfo(ctx context.Context, videoID uuid.UUID) (*mo
 		Title        string                `db:"title"`
 		Duration     float64               `db:"duration"`
 		Thumbnail    string                `db:"thumbnail"`
+		Thumbnails   pq.StringArray        `db:"thumbnails"`
+		Preview      string                `db:"preview"`
 		QualitiesRaw string                `db:"qualities"`
 		Subtitles    pq.StringArray        `db:"subtitles"`
 		Format       models.PlaybackFormat `db:"format"`
@@ -220,6 +223,11 @@ func (v *videoRepo) GetPlaybackInfo(ctx context.Context, videoID uuid.UUID) (*mo
 		ErrorMessage string                `db:"error_message"`
 		CreatedAt    time.Time             `db:"created_at"`
 		UpdatedAt    time.Time             `db:"updated_at"`
+		Version      int                   `db:"version"`
+		ChaptersRaw  string                `db:"chapters"`
+		Kind         models.AssetKind      `db:"kind"`
+		Waveform     string                `db:"waveform"`
+		ImagesRaw    string                `db:"images"`
 	}
 
 	if err := v.db.QueryRowxContext(ctx, query, videoID).StructScan(&result); err != nil {
@@ -231,6 +239,8 @@ func (v *videoRepo) GetPlaybackInfo(ctx context.Context, videoID uuid.UUID) (*mo
 		Title:        result.Title,
 		Duration:     result.Duration,
 		Thumbnail:    result.Thumbnail,
+		Thumbnails:   []string(result.Thumbnails),
+		Preview:      result.Preview,
 		Qualities:    make(map[models.VideoQuality]models.QualityInfo),
 		Subtitles:    []string(result.Subtitles),
 		Format:       result.Format,
@@ -238,12 +248,21 @@ func (v *videoRepo) GetPlaybackInfo(ctx context.Context, videoID uuid.UUID) (*mo
 		ErrorMessage: result.ErrorMessage,
 		CreatedAt:    result.CreatedAt,
 		UpdatedAt:    result.UpdatedAt,
+		Version:      result.Version,
+		Kind:         result.Kind,
+		Waveform:     result.Waveform,
 	}
 
 	// Unmarshal qualities
 	if err := json.Unmarshal([]byte(result.QualitiesRaw), &playbackInfo.Qualities); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal qualities: %w", err)
 	}
+	if err := json.Unmarshal([]byte(result.ChaptersRaw), &playbackInfo.Chapters); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chapters: %w", err)
+	}
+	if err := json.Unmarshal([]byte(result.ImagesRaw), &playbackInfo.Images); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal images: %w", err)
+	}
 
 	return playbackInfo, nil
 }
@@ -252,20 +271,28 @@ func (v *videoRepo) CreatePlaybackInfo(ctx context.Context, videoID uuid.UUID, i
 	query := `
 		INSERT INTO playback_info (
 			video_id, title, duration, thumbnail, qualities, subtitles, format, status, error_message,
-			created_at, updated_at
+			thumbnails, version, chapters, preview, kind, waveform, images, created_at, updated_at
 		) VALUES (
 			$1, $2, $3, $4, $5, $6, $7, $8, $9,
+			$10, $11, $12, NULLIF($13, ''), COALESCE(NULLIF($14, ''), 'video'), NULLIF($15, ''), $16,
 			CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
 		)
 		ON CONFLICT (video_id) DO UPDATE SET
 			title = EXCLUDED.title,
 			duration = EXCLUDED.duration,
 			thumbnail = EXCLUDED.thumbnail,
+			thumbnails = EXCLUDED.thumbnails,
 			qualities = EXCLUDED.qualities,
 			subtitles = EXCLUDED.subtitles,
 			format = EXCLUDED.format,
 			status = EXCLUDED.status,
 			error_message = EXCLUDED.error_message,
+			version = EXCLUDED.version,
+			chapters = EXCLUDED.chapters,
+			preview = EXCLUDED.preview,
+			kind = EXCLUDED.kind,
+			waveform = EXCLUDED.waveform,
+			images = EXCLUDED.images,
 			updated_at = CURRENT_TIMESTAMP
 	`
 
@@ -273,6 +300,14 @@ func (v *videoRepo) CreatePlaybackInfo(ctx context.Context, videoID uuid.UUID, i
 	if err != nil {
 		return fmt.Errorf("failed to marshal qualities: %w", err)
 	}
+	chaptersJSON, err := json.Marshal(info.Chapters)
+	if err != nil {
+		return fmt.Errorf("failed to marshal chapters: %w", err)
+	}
+	imagesJSON, err := json.Marshal(info.Images)
+	if err != nil {
+		return fmt.Errorf("failed to marshal images: %w", err)
+	}
 
 	_, err = v.db.ExecContext(ctx, query,
 		videoID,
@@ -284,6 +319,13 @@ func (v *videoRepo) CreatePlaybackInfo(ctx context.Context, videoID uuid.UUID, i
 		info.Format,
 		info.Status,
 		info.ErrorMessage,
+		pq.Array(info.Thumbnails),
+		info.Version,
+		chaptersJSON,
+		info.Preview,
+		info.Kind,
+		info.Waveform,
+		imagesJSON,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create/update playback info: %w", err)
@@ -292,10 +334,46 @@ func (v *videoRepo) CreatePlaybackInfo(ctx context.Context, videoID uuid.UUID, i
 	return nil
 }
 
+// SetPoster changes the thumbnail shown before a video starts playing.
+func (v *videoRepo) SetPoster(ctx context.Context, videoID uuid.UUID, thumbnail string) error {
+	res, err := v.db.ExecContext(ctx, setPosterQuery, thumbnail, videoID)
+	if err != nil {
+		return fmt.Errorf("failed to set poster: %w", err)
+	}
+	count, _ := res.RowsAffected()
+	if count == 0 {
+		return fmt.Errorf("no playback info found for video")
+	}
+	return nil
+}
+
+func (v *videoRepo) UpdateVisibility(ctx context.Context, video *models.VideoFile) error {
+	res, err := v.db.ExecContext(
+		ctx,
+		updateVisibilityQuery,
+		video.Visibility,
+		video.ShareToken,
+		video.VideoID,
+		video.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update visibility: %w", err)
+	}
+	count, _ := res.RowsAffected()
+	if count == 0 {
+		return fmt.Errorf("no video found to update")
+	}
+	return nil
+}
+
 func (v *videoRepo) UpdateVideoProgress(ctx context.Context, videoID uuid.UUID, status models.JobStatus, progress float64) error {
 	query := `
 		UPDATE video_files
-		SET progress = $1, status = $2, updated_at = CURRENT_TIMESTAMP
+		SET progress = $1, status = $2, updated_at = CURRENT_TIMESTAMP,
+			progress_updated_at = CASE
+				WHEN progress IS DISTINCT FROM $1 OR status IS DISTINCT FROM $2 THEN CURRENT_TIMESTAMP
+				ELSE progress_updated_at
+			END
 		WHERE video_id = $3
 		RETURNING video_id`
 
@@ -305,3 +383,352 @@ func (v *videoRepo) UpdateVideoProgress(ctx context.Context, videoID uuid.UUID,
 	}
 	return nil
 }
+
+func (v *videoRepo) MoveVideo(ctx context.Context, userID uuid.UUID, videoID uuid.UUID, folderID *uuid.UUID) error {
+	res, err := v.db.ExecContext(
+		ctx,
+		moveVideoQuery,
+		folderID,
+		videoID,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to move video: %w", err)
+	}
+	count, _ := res.RowsAffected()
+	if count == 0 {
+		return fmt.Errorf("no video found to move")
+	}
+	return nil
+}
+
+func (v *videoRepo) CreateFolder(ctx context.Context, folder *models.Folder) (*models.Folder, error) {
+	created := &models.Folder{}
+	if err := v.db.QueryRowxContext(
+		ctx,
+		createFolderQuery,
+		folder.UserID,
+		folder.ParentID,
+		folder.Name,
+	).StructScan(created); err != nil {
+		return nil, fmt.Errorf("failed to create folder: %w", err)
+	}
+	return created, nil
+}
+
+func (v *videoRepo) GetFolderByID(ctx context.Context, folderID uuid.UUID) (*models.Folder, error) {
+	folder := &models.Folder{}
+	if err := v.db.GetContext(ctx, folder, getFolderByIDQuery, folderID); err != nil {
+		return nil, fmt.Errorf("failed to get folder by id: %w", err)
+	}
+	return folder, nil
+}
+
+func (v *videoRepo) GetFolders(ctx context.Context, userID uuid.UUID, parentID *uuid.UUID) ([]*models.Folder, error) {
+	folders := make([]*models.Folder, 0)
+	if err := v.db.SelectContext(ctx, &folders, getFoldersQuery, userID, parentID); err != nil {
+		return nil, fmt.Errorf("failed to get folders: %w", err)
+	}
+	return folders, nil
+}
+
+func (v *videoRepo) UpdateFolder(ctx context.Context, folder *models.Folder) error {
+	res, err := v.db.ExecContext(
+		ctx,
+		updateFolderQuery,
+		folder.Name,
+		folder.ParentID,
+		folder.FolderID,
+		folder.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update folder: %w", err)
+	}
+	count, _ := res.RowsAffected()
+	if count == 0 {
+		return fmt.Errorf("no folder found to update")
+	}
+	return nil
+}
+
+func (v *videoRepo) DeleteFolder(ctx context.Context, userID uuid.UUID, folderID uuid.UUID) error {
+	res, err := v.db.ExecContext(ctx, deleteFolderQuery, folderID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete folder: %w", err)
+	}
+	count, _ := res.RowsAffected()
+	if count == 0 {
+		return fmt.Errorf("no folder found to delete")
+	}
+	return nil
+}
+
+// IsFolderDescendant reports whether candidateID is folderID itself or nested
+// anywhere below it.
+func (v *videoRepo) IsFolderDescendant(ctx context.Context, folderID uuid.UUID, candidateID uuid.UUID) (bool, error) {
+	var descendant bool
+	if err := v.db.GetContext(ctx, &descendant, isFolderDescendantQuery, folderID, candidateID); err != nil {
+		return false, fmt.Errorf("failed to check folder hierarchy: %w", err)
+	}
+	return descendant, nil
+}
+
+func (v *videoRepo) SetVideoWorker(ctx context.Context, videoID uuid.UUID, workerID string) error {
+	if _, err := v.db.ExecContext(ctx, setVideoWorkerQuery, workerID, videoID); err != nil {
+		return fmt.Errorf("failed to set video worker: %w", err)
+	}
+	return nil
+}
+
+func (v *videoRepo) SetPlaybackError(ctx context.Context, videoID uuid.UUID, title, message string) error {
+	if _, err := v.db.ExecContext(ctx, setPlaybackErrorQuery, videoID, title, message); err != nil {
+		return fmt.Errorf("failed to set playback error: %w", err)
+	}
+	return nil
+}
+
+func (v *videoRepo) SetVideoDRMKey(ctx context.Context, videoID uuid.UUID, keyID, scheme string) error {
+	if _, err := v.db.ExecContext(ctx, setVideoDRMKeyQuery, keyID, scheme, videoID); err != nil {
+		return fmt.Errorf("failed to set video drm key: %w", err)
+	}
+	return nil
+}
+
+func (v *videoRepo) GetStaleVideos(ctx context.Context, progressBefore time.Time) ([]*models.VideoFile, error) {
+	videos := make([]*models.VideoFile, 0)
+	if err := v.db.SelectContext(ctx, &videos, getStaleVideosQuery, progressBefore); err != nil {
+		return nil, fmt.Errorf("failed to get stale videos: %w", err)
+	}
+	return videos, nil
+}
+
+func (v *videoRepo) GetCompletedVideos(ctx context.Context) ([]*models.VideoFile, error) {
+	videos := make([]*models.VideoFile, 0)
+	if err := v.db.SelectContext(ctx, &videos, getCompletedVideosQuery); err != nil {
+		return nil, fmt.Errorf("failed to get completed videos: %w", err)
+	}
+	return videos, nil
+}
+
+func (v *videoRepo) VideoExistsByS3Key(ctx context.Context, bucket, key string) (bool, error) {
+	var exists bool
+	if err := v.db.GetContext(ctx, &exists, videoExistsByS3KeyQuery, bucket, key); err != nil {
+		return false, fmt.Errorf("failed to check for video: %w", err)
+	}
+	return exists, nil
+}
+
+func (v *videoRepo) GetUserPlan(ctx context.Context, userID uuid.UUID) (models.Plan, error) {
+	var plan models.Plan
+	if err := v.db.GetContext(ctx, &plan, getUserPlanQuery, userID); err != nil {
+		return "", fmt.Errorf("failed to get user plan: %w", err)
+	}
+	return plan, nil
+}
+
+// GetStorageQuota returns the user's storage quota in GB.
+func (v *videoRepo) GetStorageQuota(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var quota sql.NullInt64
+	if err := v.db.GetContext(ctx, &quota, getStorageQuotaQuery, userID); err != nil {
+		return 0, fmt.Errorf("failed to get storage quota: %w", err)
+	}
+	return quota.Int64, nil
+}
+
+// GetStorageUsage returns the total size of the user's videos in bytes.
+func (v *videoRepo) GetStorageUsage(ctx context.Context, userID uuid.UUID) (int64, error) {
+	usage := struct {
+		UserID    uuid.UUID `db:"user_id"`
+		TotalSize int64     `db:"total_size"`
+	}{}
+	if err := v.db.GetContext(ctx, &usage, getStorageUsageQuery, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get storage usage: %w", err)
+	}
+	return usage.TotalSize, nil
+}
+
+func (v *videoRepo) CreateJobEnvironment(ctx context.Context, videoID uuid.UUID, env *models.JobEnvironment) error {
+	envJSON, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job environment: %w", err)
+	}
+	if _, err = v.db.ExecContext(ctx, createJobEnvironmentQuery, env.JobID, videoID, envJSON); err != nil {
+		return fmt.Errorf("failed to create job environment: %w", err)
+	}
+	return nil
+}
+
+func (v *videoRepo) CreateJobEvent(ctx context.Context, event *models.JobEvent) error {
+	jobType := event.JobType
+	if jobType == "" {
+		jobType = models.JobTypeEncode
+	}
+	if _, err := v.db.ExecContext(ctx, createJobEventQuery, event.JobID, event.VideoID, event.Event, jobType, event.Codec, event.FailureReason); err != nil {
+		return fmt.Errorf("failed to create job event: %w", err)
+	}
+	return nil
+}
+
+// GetLastJobEvents returns the events of the job of the video with the latest
+// event, oldest first. It returns no events when no job was recorded.
+func (v *videoRepo) GetLastJobEvents(ctx context.Context, videoID uuid.UUID) ([]*models.JobEvent, error) {
+	events := []*models.JobEvent{}
+	if err := v.db.SelectContext(ctx, &events, getJobEventsQuery, videoID); err != nil {
+		return nil, fmt.Errorf("failed to get job events: %w", err)
+	}
+	return events, nil
+}
+
+func (v *videoRepo) CreateJobUsage(ctx context.Context, usage *models.JobUsage) error {
+	jobType := usage.JobType
+	if jobType == "" {
+		jobType = models.JobTypeEncode
+	}
+	if _, err := v.db.ExecContext(ctx, createJobUsageQuery, usage.JobID, usage.VideoID, usage.UserID, jobType,
+		usage.Codec, usage.HardwareClass, usage.Outcome, usage.SourceSeconds, usage.ComputeSeconds, usage.Cost,
+		usage.Currency, usage.StartedAt, usage.FinishedAt, usage.Resolution); err != nil {
+		return fmt.Errorf("failed to create job usage: %w", err)
+	}
+	return nil
+}
+
+func (v *videoRepo) CreateBillingEvent(ctx context.Context, event *models.BillingEvent) error {
+	jobType := event.JobType
+	if jobType == "" {
+		jobType = models.JobTypeEncode
+	}
+	if _, err := v.db.ExecContext(ctx, createBillingEventQuery, event.EventID, event.Type, event.JobID, event.VideoID,
+		event.UserID, jobType, event.Tier, event.EncodeMinutes, event.StorageBytes, event.EgressBytesPerView); err != nil {
+		return fmt.Errorf("failed to create billing event: %w", err)
+	}
+	return nil
+}
+
+// ClaimBillingEvents takes up to limit undelivered events due for delivery
+// and counts an attempt against each. Events sent maxAttempts times are no
+// longer claimed.
+func (v *videoRepo) ClaimBillingEvents(ctx context.Context, limit int, backoff time.Duration, maxAttempts int) ([]*models.BillingEvent, error) {
+	var events []*models.BillingEvent
+	if err := v.db.SelectContext(ctx, &events, claimBillingEventsQuery, limit, backoff.Seconds(), maxAttempts); err != nil {
+		return nil, fmt.Errorf("failed to claim billing events: %w", err)
+	}
+	return events, nil
+}
+
+func (v *videoRepo) MarkBillingEventDelivered(ctx context.Context, id int64) error {
+	if _, err := v.db.ExecContext(ctx, markBillingEventDeliveredQuery, id); err != nil {
+		return fmt.Errorf("failed to mark billing event delivered: %w", err)
+	}
+	return nil
+}
+
+// jobEventLookback is how long before a stats window a job may have been
+// queued or started and still be paired with its end inside the window
+const jobEventLookback = "7 days"
+
+func (v *videoRepo) GetJobThroughput(ctx context.Context, interval models.StatsInterval, from, to time.Time) ([]*models.ThroughputRow, error) {
+	rows := []*models.ThroughputRow{}
+	if err := v.db.SelectContext(ctx, &rows, getJobThroughputQuery, string(interval), from, to, jobEventLookback); err != nil {
+		return nil, fmt.Errorf("failed to get job throughput: %w", err)
+	}
+	return rows, nil
+}
+
+// GetEncodeThroughput returns the speed of encodes completed since, per
+// codec, resolution and hardware class.
+func (v *videoRepo) GetEncodeThroughput(ctx context.Context, since time.Time) ([]*models.EncodeThroughput, error) {
+	rows := []*models.EncodeThroughput{}
+	if err := v.db.SelectContext(ctx, &rows, getEncodeThroughputQuery, since); err != nil {
+		return nil, fmt.Errorf("failed to get encode throughput: %w", err)
+	}
+	return rows, nil
+}
+
+func (v *videoRepo) GetJobEnvironments(ctx context.Context, videoID uuid.UUID) ([]*models.JobEnvironment, error) {
+	var rows [][]byte
+	if err := v.db.SelectContext(ctx, &rows, getJobEnvironmentsQuery, videoID); err != nil {
+		return nil, fmt.Errorf("failed to get job environments: %w", err)
+	}
+
+	envs := make([]*models.JobEnvironment, 0, len(rows))
+	for _, row := range rows {
+		env := &models.JobEnvironment{}
+		if err := json.Unmarshal(row, env); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal job environment: %w", err)
+		}
+		envs = append(envs, env)
+	}
+	return envs, nil
+}
+
+func (v *videoRepo) SetSourceMetadata(ctx context.Context, videoID uuid.UUID, probe []byte) error {
+	if _, err := v.db.ExecContext(ctx, setSourceMetadataQuery, videoID, probe); err != nil {
+		return fmt.Errorf("failed to set source metadata: %w", err)
+	}
+	return nil
+}
+
+// GetSourceMetadata returns the stored ffprobe output of a video's source, or
+// nil when it has not been probed yet
+func (v *videoRepo) GetSourceMetadata(ctx context.Context, videoID uuid.UUID) ([]byte, error) {
+	var probe []byte
+	if err := v.db.GetContext(ctx, &probe, getSourceMetadataQuery, videoID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("video not found")
+		}
+		return nil, fmt.Errorf("failed to get source metadata: %w", err)
+	}
+	return probe, nil
+}
+
+// SaveFingerprint stores the fingerprint of a video's source, replacing the
+// one of an earlier job
+func (v *videoRepo) SaveFingerprint(ctx context.Context, fingerprint *models.VideoFingerprint) error {
+	if _, err := v.db.ExecContext(ctx, saveFingerprintQuery, fingerprint.VideoID, fingerprint.Interval, fingerprint.Hashes); err != nil {
+		return fmt.Errorf("failed to save fingerprint: %w", err)
+	}
+	return nil
+}
+
+// GetFingerprint returns the fingerprint of a video, or sql.ErrNoRows when
+// its source has not been fingerprinted
+func (v *videoRepo) GetFingerprint(ctx context.Context, videoID uuid.UUID) (*models.VideoFingerprint, error) {
+	fingerprint := &models.VideoFingerprint{}
+	if err := v.db.GetContext(ctx, fingerprint, getFingerprintQuery, videoID); err != nil {
+		return nil, err
+	}
+	return fingerprint, nil
+}
+
+// GetUserFingerprints returns the fingerprints of every video of the user
+// that is not in the trash
+func (v *videoRepo) GetUserFingerprints(ctx context.Context, userID uuid.UUID) ([]*models.VideoFingerprint, error) {
+	var fingerprints []*models.VideoFingerprint
+	if err := v.db.SelectContext(ctx, &fingerprints, getUserFingerprintsQuery, userID); err != nil {
+		return nil, fmt.Errorf("failed to get fingerprints: %w", err)
+	}
+	return fingerprints, nil
+}
+
+// GetOutputCache returns the cached outputs of a source encoded with a
+// profile by the user, or sql.ErrNoRows when there are none
+func (v *videoRepo) GetOutputCache(ctx context.Context, userID uuid.UUID, sourceHash, profileHash string) (*models.OutputCacheEntry, error) {
+	entry := &models.OutputCacheEntry{}
+	if err := v.db.GetContext(ctx, entry, getOutputCacheQuery, userID, sourceHash, profileHash); err != nil {
+		return nil, fmt.Errorf("failed to get output cache: %w", err)
+	}
+	return entry, nil
+}
+
+// SaveOutputCache records the outputs of a finished encode, replacing those
+// of an earlier encode of the same source and profile
+func (v *videoRepo) SaveOutputCache(ctx context.Context, entry *models.OutputCacheEntry) error {
+	if _, err := v.db.ExecContext(ctx, saveOutputCacheQuery, entry.UserID, entry.SourceHash, entry.ProfileHash,
+		entry.VideoID, entry.OutputPrefix); err != nil {
+		return fmt.Errorf("failed to save output cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+	"github.com/go-redis/redis/v8"
+)
+
+// popTimeout is how long Pop waits for a job before giving up
+const popTimeout = time.Second
+
+// NewQueue returns the job queue backend selected in the configuration.
+func NewQueue(cfg *config.Config, redisClient *redis.Client) (videofiles.Queue, error) {
+	switch cfg.Queue.Backend {
+	case "", videofiles.QueueBackendRedis:
+		return NewRedisQueue(redisClient), nil
+	case videofiles.QueueBackendSQS:
+		return NewSQSQueue(cfg.Queue.SQS)
+	case videofiles.QueueBackendNATS:
+		return NewNATSQueue(cfg.Queue.NATS)
+	default:
+		return nil, fmt.Errorf("unknown queue backend %q", cfg.Queue.Backend)
+	}
+}
+
+// redisQueue keeps every queue in a Redis list. Workers pop from the head,
+// where jobs are pushed, so index 0 is the next job.
+type redisQueue struct {
+	redisClient *redis.Client
+}
+
+func NewRedisQueue(redisClient *redis.Client) videofiles.Queue {
+	return &redisQueue{redisClient: redisClient}
+}
+
+func (q *redisQueue) Push(ctx context.Context, key string, payload []byte) error {
+	if err := q.redisClient.LPush(ctx, key, payload).Err(); err != nil {
+		return fmt.Errorf("failed to push job: %w", err)
+	}
+	return nil
+}
+
+func (q *redisQueue) Pop(ctx context.Context, keys ...string) ([]byte, error) {
+	res, err := q.redisClient.BLPop(ctx, popTimeout, keys...).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, videofiles.ErrQueueEmpty
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to pop job from queue: %w", err)
+	}
+	return []byte(res[1]), nil
+}
+
+func (q *redisQueue) Length(ctx context.Context, key string) (int64, error) {
+	length, err := q.redisClient.LLen(ctx, key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get queue length: %w", err)
+	}
+	return length, nil
+}
+
+func (q *redisQueue) Peek(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	payloads, err := q.redisClient.LRange(ctx, key, start, stop).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue: %w", err)
+	}
+	return payloads, nil
+}
+
+func (q *redisQueue) Remove(ctx context.Context, key string, payload []byte) (int64, error) {
+	removed, err := q.redisClient.LRem(ctx, key, 0, payload).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove payload: %w", err)
+	}
+	return removed, nil
+}
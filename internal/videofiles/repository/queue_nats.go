@@ -0,0 +1,508 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+)
+
+const (
+	natsDefaultPort = "4222"
+	natsDialTimeout = 5 * time.Second
+	natsReqTimeout  = 5 * time.Second
+	// natsStatusNotFound is returned both for a pull with no messages and by
+	// the JetStream API for a missing stream
+	natsStatusNotFound = 404
+	natsStatusTimeout  = 408
+	natsStatusNoResp   = 503
+)
+
+// natsQueue dispatches jobs through a JetStream stream with work queue
+// retention. Every key is a subject of the stream with its own durable pull
+// consumer, and messages are acknowledged as soon as they are pulled. It
+// speaks the NATS protocol directly, using only what the queue needs.
+type natsQueue struct {
+	cfg config.NATSQueueConfig
+
+	mu          sync.Mutex
+	conn        *natsConn
+	streamReady bool
+	consumers   map[string]bool
+}
+
+func NewNATSQueue(cfg config.NATSQueueConfig) (videofiles.Queue, error) {
+	if cfg.URL == "" || cfg.Stream == "" {
+		return nil, errors.New("nats queue needs a url and a stream")
+	}
+	if strings.ContainsAny(cfg.Stream, ".*> ") {
+		return nil, fmt.Errorf("invalid nats stream name %q", cfg.Stream)
+	}
+	return &natsQueue{cfg: cfg, consumers: make(map[string]bool)}, nil
+}
+
+func (q *natsQueue) subject(key string) string {
+	return q.cfg.Stream + "." + strings.ReplaceAll(key, ":", ".")
+}
+
+func (q *natsQueue) durable(key string) string {
+	return strings.NewReplacer(":", "_", ".", "_").Replace(key)
+}
+
+func (q *natsQueue) Push(ctx context.Context, key string, payload []byte) error {
+	conn, err := q.prepare(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to push job: %w", err)
+	}
+	msg, err := conn.request(ctx, q.subject(key), payload, natsReqTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to push job: %w", err)
+	}
+	if err = decodeJSResponse(msg, nil); err != nil {
+		return fmt.Errorf("failed to push job: %w", err)
+	}
+	return nil
+}
+
+// Pop asks every consumer but the last for a job without waiting; the last
+// one is given popTimeout, so jobs on the earlier keys keep their priority.
+func (q *natsQueue) Pop(ctx context.Context, keys ...string) ([]byte, error) {
+	for i, key := range keys {
+		conn, err := q.prepare(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pop job from queue: %w", err)
+		}
+
+		pull := map[string]interface{}{"batch": 1, "no_wait": true}
+		if i == len(keys)-1 {
+			pull = map[string]interface{}{"batch": 1, "expires": popTimeout.Nanoseconds()}
+		}
+		body, _ := json.Marshal(pull)
+		subject := fmt.Sprintf("$JS.API.CONSUMER.MSG.NEXT.%s.%s", q.cfg.Stream, q.durable(key))
+		msg, err := conn.request(ctx, subject, body, popTimeout+natsReqTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pop job from queue: %w", err)
+		}
+		switch msg.status {
+		case 0:
+		case natsStatusNotFound, natsStatusTimeout:
+			continue
+		default:
+			return nil, fmt.Errorf("failed to pop job from queue: status %d", msg.status)
+		}
+
+		if err = conn.publish(msg.reply, "", []byte("+ACK")); err != nil {
+			// The job is redelivered once the ack wait expires
+			return nil, fmt.Errorf("failed to acknowledge job: %w", err)
+		}
+		return msg.data, nil
+	}
+	return nil, videofiles.ErrQueueEmpty
+}
+
+// Length returns how many jobs the key's consumer has yet to deliver.
+func (q *natsQueue) Length(ctx context.Context, key string) (int64, error) {
+	conn, err := q.prepare(ctx, key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get queue length: %w", err)
+	}
+	subject := fmt.Sprintf("$JS.API.CONSUMER.INFO.%s.%s", q.cfg.Stream, q.durable(key))
+	msg, err := conn.request(ctx, subject, nil, natsReqTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get queue length: %w", err)
+	}
+	var info struct {
+		NumPending int64 `json:"num_pending"`
+	}
+	if err = decodeJSResponse(msg, &info); err != nil {
+		return 0, fmt.Errorf("failed to get queue length: %w", err)
+	}
+	return info.NumPending, nil
+}
+
+// prepare returns a live connection, with the stream and, unless key is
+// empty, the key's consumer created. Everything is set up again after a
+// reconnect in case the server lost its state.
+func (q *natsQueue) prepare(ctx context.Context, key string) (*natsConn, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.conn == nil || q.conn.isClosed() {
+		conn, err := dialNATS(q.cfg)
+		if err != nil {
+			return nil, err
+		}
+		q.conn = conn
+		q.streamReady = false
+		q.consumers = make(map[string]bool)
+	}
+
+	if !q.streamReady {
+		if err := q.ensureStream(ctx); err != nil {
+			return nil, err
+		}
+		q.streamReady = true
+	}
+	if key != "" && !q.consumers[key] {
+		if err := q.ensureConsumer(ctx, key); err != nil {
+			return nil, err
+		}
+		q.consumers[key] = true
+	}
+	return q.conn, nil
+}
+
+func (q *natsQueue) ensureStream(ctx context.Context) error {
+	msg, err := q.conn.request(ctx, "$JS.API.STREAM.INFO."+q.cfg.Stream, nil, natsReqTimeout)
+	if err != nil {
+		return err
+	}
+	err = decodeJSResponse(msg, nil)
+	var apiErr *natsAPIError
+	if !errors.As(err, &apiErr) || apiErr.Code != natsStatusNotFound {
+		return err
+	}
+
+	stream, _ := json.Marshal(map[string]interface{}{
+		"name":      q.cfg.Stream,
+		"subjects":  []string{q.cfg.Stream + ".>"},
+		"retention": "workqueue",
+		"storage":   "file",
+	})
+	msg, err = q.conn.request(ctx, "$JS.API.STREAM.CREATE."+q.cfg.Stream, stream, natsReqTimeout)
+	if err != nil {
+		return err
+	}
+	if err = decodeJSResponse(msg, nil); err != nil {
+		return fmt.Errorf("failed to create stream %s: %w", q.cfg.Stream, err)
+	}
+	return nil
+}
+
+// ensureConsumer creates the key's durable consumer. Creating it again with
+// the same configuration is a no-op, so the API and every worker can do so.
+func (q *natsQueue) ensureConsumer(ctx context.Context, key string) error {
+	durable := q.durable(key)
+	consumer, _ := json.Marshal(map[string]interface{}{
+		"stream_name": q.cfg.Stream,
+		"config": map[string]interface{}{
+			"durable_name":   durable,
+			"ack_policy":     "explicit",
+			"deliver_policy": "all",
+			"filter_subject": q.subject(key),
+		},
+	})
+	subject := fmt.Sprintf("$JS.API.CONSUMER.DURABLE.CREATE.%s.%s", q.cfg.Stream, durable)
+	msg, err := q.conn.request(ctx, subject, consumer, natsReqTimeout)
+	if err != nil {
+		return err
+	}
+	if err = decodeJSResponse(msg, nil); err != nil {
+		return fmt.Errorf("failed to create consumer %s: %w", durable, err)
+	}
+	return nil
+}
+
+type natsAPIError struct {
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+}
+
+func (e *natsAPIError) Error() string {
+	return fmt.Sprintf("jetstream error %d: %s", e.Code, e.Description)
+}
+
+// decodeJSResponse decodes a JetStream API response into out, which may be
+// nil, and returns the API error it carries.
+func decodeJSResponse(msg *natsMsg, out interface{}) error {
+	if msg.status == natsStatusNoResp {
+		return errors.New("jetstream is not enabled on the server")
+	}
+	var resp struct {
+		Error *natsAPIError `json:"error"`
+	}
+	if err := json.Unmarshal(msg.data, &resp); err != nil {
+		return fmt.Errorf("invalid jetstream response: %w", err)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(msg.data, out)
+}
+
+type natsMsg struct {
+	reply  string
+	status int
+	data   []byte
+}
+
+// natsConn is a NATS client connection supporting publish and request. Each
+// request subscribes to its own inbox, since pulled JetStream messages keep
+// their original subject and can only be matched by subscription.
+type natsConn struct {
+	conn    net.Conn
+	inbox   string
+	writeMu sync.Mutex
+	writer  *bufio.Writer
+
+	mu      sync.Mutex
+	nextSID int
+	pending map[int]chan *natsMsg
+	closed  chan struct{}
+}
+
+func dialNATS(cfg config.NATSQueueConfig) (*natsConn, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nats url: %w", err)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), natsDefaultPort)
+	}
+
+	conn, err := net.DialTimeout("tcp", host, natsDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to nats: %w", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(natsDialTimeout))
+
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected nats greeting: %q", line)
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info)
+	if info.TLSRequired || u.Scheme == "tls" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err = tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("nats tls handshake failed: %w", err)
+		}
+		conn = tlsConn
+		reader = bufio.NewReader(conn)
+	}
+
+	connect, _ := json.Marshal(map[string]interface{}{
+		"verbose":       false,
+		"pedantic":      false,
+		"headers":       true,
+		"no_responders": true,
+		"lang":          "go",
+		"name":          "cloud-video-encoder",
+		"auth_token":    cfg.Token,
+		"user":          cfg.User,
+		"pass":          cfg.Password,
+	})
+	if _, err = fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send nats connect: %w", err)
+	}
+	if line, err = reader.ReadString('\n'); err != nil || !strings.HasPrefix(line, "PONG") {
+		conn.Close()
+		return nil, fmt.Errorf("nats connect rejected: %s", strings.TrimSpace(line))
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	id := make([]byte, 12)
+	_, _ = rand.Read(id)
+	c := &natsConn{
+		conn:    conn,
+		inbox:   "_INBOX." + hex.EncodeToString(id),
+		writer:  bufio.NewWriter(conn),
+		pending: make(map[int]chan *natsMsg),
+		closed:  make(chan struct{}),
+	}
+	go c.readLoop(reader)
+	return c, nil
+}
+
+func (c *natsConn) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *natsConn) write(format string, args ...interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := fmt.Fprintf(c.writer, format, args...); err != nil {
+		return err
+	}
+	return c.writer.Flush()
+}
+
+func (c *natsConn) publish(subject, reply string, data []byte) error {
+	if reply != "" {
+		subject += " " + reply
+	}
+	return c.write("PUB %s %d\r\n%s\r\n", subject, len(data), data)
+}
+
+// request publishes data to subject and waits for the first reply.
+func (c *natsConn) request(ctx context.Context, subject string, data []byte, timeout time.Duration) (*natsMsg, error) {
+	c.mu.Lock()
+	c.nextSID++
+	sid := c.nextSID
+	replies := make(chan *natsMsg, 1)
+	c.pending[sid] = replies
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, sid)
+		c.mu.Unlock()
+		_ = c.write("UNSUB %d\r\n", sid)
+	}()
+
+	reply := fmt.Sprintf("%s.%d", c.inbox, sid)
+	if err := c.write("SUB %s %d\r\n", reply, sid); err != nil {
+		c.close()
+		return nil, err
+	}
+	if err := c.publish(subject, reply, data); err != nil {
+		c.close()
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case msg := <-replies:
+		return msg, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("nats request to %s timed out", subject)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.closed:
+		return nil, errors.New("nats connection closed")
+	}
+}
+
+func (c *natsConn) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.isClosed() {
+		close(c.closed)
+		c.conn.Close()
+	}
+}
+
+func (c *natsConn) readLoop(reader *bufio.Reader) {
+	defer c.close()
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		op, args, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(op) {
+		case "PING":
+			if c.write("PONG\r\n") != nil {
+				return
+			}
+		case "MSG":
+			// MSG <subject> <sid> [reply-to] <#bytes>
+			fields := strings.Fields(args)
+			if len(fields) < 3 {
+				return
+			}
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil {
+				return
+			}
+			data, err := readPayload(reader, size)
+			if err != nil {
+				return
+			}
+			c.deliver(fields, 3, &natsMsg{data: data})
+		case "HMSG":
+			// HMSG <subject> <sid> [reply-to] <#header bytes> <#total bytes>
+			fields := strings.Fields(args)
+			if len(fields) < 4 {
+				return
+			}
+			headerSize, err1 := strconv.Atoi(fields[len(fields)-2])
+			size, err2 := strconv.Atoi(fields[len(fields)-1])
+			if err1 != nil || err2 != nil || headerSize > size {
+				return
+			}
+			data, err := readPayload(reader, size)
+			if err != nil {
+				return
+			}
+			c.deliver(fields, 4, &natsMsg{status: headerStatus(data[:headerSize]), data: data[headerSize:]})
+		}
+	}
+}
+
+// deliver hands a message to the request waiting on its subscription.
+// Messages have a reply subject when fields has more than minFields.
+func (c *natsConn) deliver(fields []string, minFields int, msg *natsMsg) {
+	if len(fields) > minFields {
+		msg.reply = fields[2]
+	}
+	sid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	replies, ok := c.pending[sid]
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case replies <- msg:
+	default:
+	}
+}
+
+func readPayload(reader *bufio.Reader, size int) ([]byte, error) {
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, err
+	}
+	return data[:size], nil
+}
+
+// headerStatus returns the status code of a "NATS/1.0 404 No Messages" style
+// header block, or 0 when there is none.
+func headerStatus(header []byte) int {
+	line, _, _ := strings.Cut(string(header), "\r\n")
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return 0
+	}
+	status, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0
+	}
+	return status
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+const (
+	sqsService     = "sqs"
+	sqsContentType = "application/x-amz-json-1.0"
+	sqsTimeout     = 10 * time.Second
+)
+
+// sqsQueue sends jobs to one SQS queue per key through the SQS JSON API.
+// Messages are deleted as soon as they are received, matching the Redis
+// queue where a popped job is gone.
+type sqsQueue struct {
+	prefix      string
+	endpoint    string
+	region      string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	client      *http.Client
+}
+
+func NewSQSQueue(cfg config.SQSQueueConfig) (videofiles.Queue, error) {
+	prefix, err := url.Parse(cfg.QueueURLPrefix)
+	if err != nil || prefix.Scheme == "" || prefix.Host == "" {
+		return nil, fmt.Errorf("invalid sqs queue url prefix %q", cfg.QueueURLPrefix)
+	}
+
+	opts := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(cfg.Region)}
+	if cfg.AccessKey != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKey, cfg.SecretKey, ""),
+		))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sqs configuration: %w", err)
+	}
+
+	return &sqsQueue{
+		prefix:      strings.TrimSuffix(cfg.QueueURLPrefix, "/") + "/",
+		endpoint:    prefix.Scheme + "://" + prefix.Host + "/",
+		region:      awsCfg.Region,
+		credentials: awsCfg.Credentials,
+		signer:      v4.NewSigner(),
+		client:      &http.Client{Timeout: sqsTimeout},
+	}, nil
+}
+
+// queueURL returns the URL of the queue of key. SQS queue names cannot
+// contain ':', which separates the GPU suffix.
+func (q *sqsQueue) queueURL(key string) string {
+	return q.prefix + strings.ReplaceAll(key, ":", "-")
+}
+
+func (q *sqsQueue) Push(ctx context.Context, key string, payload []byte) error {
+	input := map[string]interface{}{
+		"QueueUrl":    q.queueURL(key),
+		"MessageBody": string(payload),
+	}
+	if err := q.call(ctx, "SendMessage", input, nil); err != nil {
+		return fmt.Errorf("failed to push job: %w", err)
+	}
+	return nil
+}
+
+// Pop short polls every queue but the last, which is long polled, so jobs on
+// the earlier queues keep their priority.
+func (q *sqsQueue) Pop(ctx context.Context, keys ...string) ([]byte, error) {
+	for i, key := range keys {
+		wait := 0
+		if i == len(keys)-1 {
+			wait = int(popTimeout.Seconds())
+		}
+		input := map[string]interface{}{
+			"QueueUrl":            q.queueURL(key),
+			"MaxNumberOfMessages": 1,
+			"WaitTimeSeconds":     wait,
+		}
+		var output struct {
+			Messages []struct {
+				ReceiptHandle string `json:"ReceiptHandle"`
+				Body          string `json:"Body"`
+			} `json:"Messages"`
+		}
+		if err := q.call(ctx, "ReceiveMessage", input, &output); err != nil {
+			return nil, fmt.Errorf("failed to pop job from queue: %w", err)
+		}
+		if len(output.Messages) == 0 {
+			continue
+		}
+
+		message := output.Messages[0]
+		remove := map[string]interface{}{
+			"QueueUrl":      q.queueURL(key),
+			"ReceiptHandle": message.ReceiptHandle,
+		}
+		if err := q.call(ctx, "DeleteMessage", remove, nil); err != nil {
+			// The message becomes visible again and another worker takes it
+			return nil, fmt.Errorf("failed to delete received job: %w", err)
+		}
+		return []byte(message.Body), nil
+	}
+	return nil, videofiles.ErrQueueEmpty
+}
+
+// Length returns the approximate number of visible messages, which is all
+// SQS reports.
+func (q *sqsQueue) Length(ctx context.Context, key string) (int64, error) {
+	input := map[string]interface{}{
+		"QueueUrl":       q.queueURL(key),
+		"AttributeNames": []string{"ApproximateNumberOfMessages"},
+	}
+	var output struct {
+		Attributes map[string]string `json:"Attributes"`
+	}
+	if err := q.call(ctx, "GetQueueAttributes", input, &output); err != nil {
+		return 0, fmt.Errorf("failed to get queue length: %w", err)
+	}
+	length, err := strconv.ParseInt(output.Attributes["ApproximateNumberOfMessages"], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse queue length: %w", err)
+	}
+	return length, nil
+}
+
+// call invokes an SQS action and decodes its response into output, which
+// may be nil.
+func (q *sqsQueue) call(ctx context.Context, action string, input, output interface{}) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, q.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", sqsContentType)
+	req.Header.Set("X-Amz-Target", "AmazonSQS."+action)
+
+	creds, err := q.credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get aws credentials: %w", err)
+	}
+	hash := sha256.Sum256(body)
+	if err = q.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(hash[:]), sqsService, q.region, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(data, &apiErr)
+		return fmt.Errorf("%s failed with status %d: %s %s", action, resp.StatusCode, apiErr.Type, apiErr.Message)
+	}
+	if output == nil {
+		return nil
+	}
+	return json.Unmarshal(data, output)
+}
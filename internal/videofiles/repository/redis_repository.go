@@ -3,8 +3,10 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"time"
 
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
@@ -14,11 +16,15 @@ import (
 
 type videoRedisRepo struct {
 	redisClient *redis.Client
+	queue       videofiles.Queue
 }
 
-func NewVideoRedisRepo(redisClient *redis.Client) videofiles.RedisRepository {
+// NewVideoRedisRepo returns the Redis repository. Jobs are dispatched through
+// queue while their state stays in Redis.
+func NewVideoRedisRepo(redisClient *redis.Client, queue videofiles.Queue) videofiles.RedisRepository {
 	return &videoRedisRepo{
 		redisClient: redisClient,
+		queue:       queue,
 	}
 }
 
@@ -32,6 +38,9 @@ func (v *videoRedisRepo) EnqueueJob(ctx context.Context, key string, videoJob *m
 
 	pipe := v.redisClient.Pipeline()
 	log.Println(videoJob)
+	// A requeued job starts over without the failure and stage of its last
+	// attempt
+	pipe.HDel(ctx, jobKey, "failure_reason", "failure_message", "stage", "stage_progress", "estimated_completion")
 	pipe.HSet(ctx, jobKey, map[string]interface{}{
 		"job_id":        videoJob.JobID,
 		"user_id":       videoJob.UserID,
@@ -43,11 +52,30 @@ func (v *videoRedisRepo) EnqueueJob(ctx context.Context, key string, videoJob *m
 		"input_bucket":  videoJob.InputBucket,
 		"codec":         string(videoJob.Codec),
 		"output_bucket": videoJob.OutputBucket,
+		// The payload lets an admin requeue the job as it was submitted
+		"payload": string(jobJSON),
 	})
 
+	if videoJob.NotBefore != nil {
+		pipe.HSet(ctx, jobKey, "not_before", videoJob.NotBefore.Format(time.RFC3339))
+	}
+
 	pipe.Expire(ctx, jobKey, 24*time.Hour)
 
-	pipe.LPush(ctx, key, string(jobJSON))
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to execute Redis pipeline: %w", err)
+	}
+
+	// Scheduled jobs wait in the deferred set rather than being cycled
+	// through the queue until they are due
+	if videoJob.NotBefore != nil && videoJob.NotBefore.After(time.Now()) {
+		return v.DeferJob(ctx, key, videoJob, *videoJob.NotBefore)
+	}
+
+	if err = v.queue.Push(ctx, key, jobJSON); err != nil {
+		return err
+	}
 
 	notification := map[string]interface{}{
 		"job_id":    videoJob.JobID,
@@ -58,11 +86,8 @@ func (v *videoRedisRepo) EnqueueJob(ctx context.Context, key string, videoJob *m
 	if err != nil {
 		return fmt.Errorf("failed to marshal notification: %w", err)
 	}
-	pipe.Publish(ctx, "new_video_jobs_channel", notificationJSON)
-
-	_, err = pipe.Exec(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to execute Redis pipeline: %w", err)
+	if err = v.redisClient.Publish(ctx, "new_video_jobs_channel", notificationJSON).Err(); err != nil {
+		return fmt.Errorf("failed to publish job notification: %w", err)
 	}
 
 	return nil
@@ -96,22 +121,44 @@ func (v *videoRedisRepo) GetJobDetails(ctx context.Context, jobID string) (*mode
 		InputBucket:  jobData["input_bucket"],
 		OutputBucket: jobData["output_bucket"],
 	}
+	job.FailureReason = models.FailureReason(jobData["failure_reason"])
+	job.FailureMessage = jobData["failure_message"]
+	if progress, err := strconv.ParseFloat(jobData["progress"], 64); err == nil {
+		job.Progress = progress
+	}
+	job.Stage = models.ProgressStage(jobData["stage"])
+	if stageProgress, err := strconv.ParseFloat(jobData["stage_progress"], 64); err == nil {
+		job.StageProgress = stageProgress
+	}
+	if completedAt, err := time.Parse(time.RFC3339, jobData["completed_at"]); err == nil {
+		job.CompletedAt = completedAt
+	}
+	if notBefore, err := time.Parse(time.RFC3339, jobData["not_before"]); err == nil {
+		job.NotBefore = &notBefore
+	}
+	if completion, err := time.Parse(time.RFC3339, jobData["estimated_completion"]); err == nil {
+		job.EstimatedCompletion = &completion
+	}
 
 	return job, nil
 }
 
-func (v *videoRedisRepo) UpdateProgress(ctx context.Context, jobID string, key string, progress float64) error {
-	jobKey := fmt.Sprintf("job:%s", jobID)
+// UpdateProgress records the stage and progress of a running job and
+// publishes them on the job progress channel.
+func (v *videoRedisRepo) UpdateProgress(ctx context.Context, key string, progress *models.JobProgress) error {
+	jobKey := fmt.Sprintf("job:%s", progress.JobID)
 
 	pipe := v.redisClient.Pipeline()
-	pipe.HSet(ctx, jobKey, "progress", progress)
+	pipe.HSet(ctx, jobKey, "progress", progress.Progress, "stage", string(progress.Stage), "stage_progress", progress.StageProgress)
 
-	notification := map[string]interface{}{
-		"job_id":    jobID,
-		"progress":  progress,
-		"timestamp": time.Now().Format(time.RFC3339),
+	if progress.Timestamp.IsZero() {
+		progress.Timestamp = time.Now()
 	}
-	notificationJSON, err := json.Marshal(notification)
+	if progress.ETA != nil {
+		completion := progress.Timestamp.Add(time.Duration(*progress.ETA) * time.Second)
+		pipe.HSet(ctx, jobKey, "estimated_completion", completion.Format(time.RFC3339))
+	}
+	notificationJSON, err := json.Marshal(progress)
 	if err != nil {
 		return fmt.Errorf("failed to marshal progress notification: %w", err)
 	}
@@ -131,7 +178,7 @@ func (v *videoRedisRepo) UpdateStatus(ctx context.Context, jobID string, key str
 	pipe := v.redisClient.Pipeline()
 	pipe.HSet(ctx, jobKey, "status", string(status))
 
-	if status == models.JobStatusCompleted || status == models.JobStatusFailed {
+	if status == models.JobStatusCompleted || status == models.JobStatusFailed || status == models.JobStatusRejected {
 		pipe.HSet(ctx, jobKey, "completed_at", time.Now().Format(time.RFC3339))
 	}
 
@@ -154,6 +201,38 @@ func (v *videoRedisRepo) UpdateStatus(ctx context.Context, jobID string, key str
 	return nil
 }
 
+func (v *videoRedisRepo) UpdateFailureReason(ctx context.Context, jobID string, reason models.FailureReason, message string) error {
+	jobKey := fmt.Sprintf("job:%s", jobID)
+
+	pipe := v.redisClient.Pipeline()
+	pipe.HSet(ctx, jobKey, "failure_reason", string(reason), "failure_message", message)
+	pipe.ZAdd(ctx, videofiles.FailedJobsKey, &redis.Z{Score: float64(time.Now().Unix()), Member: jobID})
+	pipe.ZRemRangeByRank(ctx, videofiles.FailedJobsKey, 0, -videofiles.FailedJobsKept-1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to update failure reason: %w", err)
+	}
+
+	return nil
+}
+
+// ListFailedJobs returns the IDs of the most recently failed jobs, newest
+// first.
+func (v *videoRedisRepo) ListFailedJobs(ctx context.Context, limit int64) ([]string, error) {
+	jobIDs, err := v.redisClient.ZRevRange(ctx, videofiles.FailedJobsKey, 0, limit-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list failed jobs: %w", err)
+	}
+	return jobIDs, nil
+}
+
+// PublishJobCancel asks whichever worker runs a job to stop it.
+func (v *videoRedisRepo) PublishJobCancel(ctx context.Context, jobID string) error {
+	if err := v.redisClient.Publish(ctx, videofiles.JobCancelChannel, jobID).Err(); err != nil {
+		return fmt.Errorf("failed to publish job cancellation: %w", err)
+	}
+	return nil
+}
+
 func (v *videoRedisRepo) GetJobStatus(ctx context.Context, key string, jobID string) (models.JobStatus, error) {
 	jobKey := fmt.Sprintf("job:%s", jobID)
 	status, err := v.redisClient.HGet(ctx, jobKey, "status").Result()
@@ -164,41 +243,653 @@ func (v *videoRedisRepo) GetJobStatus(ctx context.Context, key string, jobID str
 	return models.JobStatus(status), nil
 }
 
-func (v *videoRedisRepo) DequeueJob(ctx context.Context, key string) (*models.EncodeJob, error) {
+// DequeueJob pops the next job from the first non-empty queue in keys. The
+// job is not marked as processing until StartJob, so a job the worker defers
+// is never reported as started.
+func (v *videoRedisRepo) DequeueJob(ctx context.Context, keys ...string) (*models.EncodeJob, error) {
 
-	res, err := v.redisClient.BLPop(ctx, time.Second, key).Result()
+	payload, err := v.queue.Pop(ctx, keys...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to pop job from queue: %w", err)
+		return nil, err
 	}
 
 	job := &models.EncodeJob{}
-	if err = json.Unmarshal([]byte(res[1]), job); err != nil {
+	if err = json.Unmarshal(payload, job); err != nil {
 		return nil, fmt.Errorf("error unmarshalling job: %v", err)
 	}
 
-	jobKey := fmt.Sprintf("job:%s", job.JobID)
+	return job, nil
+}
+
+// StartJob marks a dequeued job as processing
+func (v *videoRedisRepo) StartJob(ctx context.Context, jobID string) error {
+	jobKey := fmt.Sprintf("job:%s", jobID)
 	pipe := v.redisClient.Pipeline()
 
 	pipe.HSet(ctx, jobKey, "status", string(models.JobStatusProcessing))
 	pipe.HSet(ctx, jobKey, "started_at", time.Now().Format(time.RFC3339))
 
 	notification := map[string]interface{}{
-		"job_id":    job.JobID,
+		"job_id":    jobID,
 		"status":    string(models.JobStatusProcessing),
 		"timestamp": time.Now().Format(time.RFC3339),
 	}
 	notificationJSON, err := json.Marshal(notification)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal status notification: %w", err)
+		return fmt.Errorf("failed to marshal status notification: %w", err)
 	}
 	pipe.Publish(ctx, "job_status_channel", notificationJSON)
 
 	_, err = pipe.Exec(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update job status: %w", err)
+		return fmt.Errorf("failed to update job status: %w", err)
 	}
 
-	return job, nil
+	return nil
+}
+
+// deferredJob is a member of DeferredJobsKey: the job and the queue it goes
+// back to
+type deferredJob struct {
+	Queue string          `json:"queue"`
+	Job   json.RawMessage `json:"job"`
+}
+
+// DeferJob holds a queued job back until the given time, when
+// PromoteDeferredJobs pushes it onto key again. Its status stays queued.
+func (v *videoRedisRepo) DeferJob(ctx context.Context, key string, job *models.EncodeJob, until time.Time) error {
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job data: %w", err)
+	}
+	member, err := json.Marshal(deferredJob{Queue: key, Job: jobJSON})
+	if err != nil {
+		return fmt.Errorf("failed to marshal deferred job: %w", err)
+	}
+
+	err = v.redisClient.ZAdd(ctx, videofiles.DeferredJobsKey, &redis.Z{
+		Score:  float64(until.Unix()),
+		Member: member,
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to defer job: %w", err)
+	}
+	return nil
+}
+
+// PromoteDeferredJobs pushes the deferred jobs that are due by now back onto
+// their queues and returns how many it moved. A job is only pushed by the
+// caller that removed it from the set, so workers can promote concurrently.
+func (v *videoRedisRepo) PromoteDeferredJobs(ctx context.Context, now time.Time) (int, error) {
+	members, err := v.redisClient.ZRangeByScore(ctx, videofiles.DeferredJobsKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.Unix(), 10),
+	}).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list deferred jobs: %w", err)
+	}
+
+	promoted := 0
+	for _, member := range members {
+		removed, err := v.redisClient.ZRem(ctx, videofiles.DeferredJobsKey, member).Result()
+		if err != nil {
+			return promoted, fmt.Errorf("failed to claim deferred job: %w", err)
+		}
+		if removed == 0 {
+			continue
+		}
+
+		deferred := deferredJob{}
+		if err := json.Unmarshal([]byte(member), &deferred); err != nil {
+			continue
+		}
+		if err := v.queue.Push(ctx, deferred.Queue, deferred.Job); err != nil {
+			// Put it back rather than lose it
+			v.redisClient.ZAdd(ctx, videofiles.DeferredJobsKey, &redis.Z{Score: float64(now.Unix()), Member: member})
+			return promoted, err
+		}
+		promoted++
+	}
+	return promoted, nil
+}
+
+// acquireUserJobSlot adds a job to the running jobs of its user unless the
+// user already has limit of them. Holding a slot already counts as success.
+var acquireUserJobSlot = redis.NewScript(`
+if redis.call('SISMEMBER', KEYS[1], ARGV[1]) == 1 then
+	return 1
+end
+if redis.call('SCARD', KEYS[1]) >= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('SADD', KEYS[1], ARGV[1])
+redis.call('EXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+// AcquireUserJobSlot reports whether the job may run alongside the user's
+// other running jobs, and counts it among them if so. Slots are freed with
+// ReleaseUserJobSlot; the set expires with the job hashes in case a worker
+// dies holding one.
+func (v *videoRedisRepo) AcquireUserJobSlot(ctx context.Context, userID, jobID string, limit int) (bool, error) {
+	key := fmt.Sprintf(videofiles.UserJobsKeyFmt, userID)
+	acquired, err := acquireUserJobSlot.Run(ctx, v.redisClient, []string{key},
+		jobID, limit, int((24 * time.Hour).Seconds())).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire user job slot: %w", err)
+	}
+	return acquired == 1, nil
+}
+
+func (v *videoRedisRepo) ReleaseUserJobSlot(ctx context.Context, userID, jobID string) error {
+	key := fmt.Sprintf(videofiles.UserJobsKeyFmt, userID)
+	if err := v.redisClient.SRem(ctx, key, jobID).Err(); err != nil {
+		return fmt.Errorf("failed to release user job slot: %w", err)
+	}
+	return nil
+}
+
+func (v *videoRedisRepo) SaveCheckpoint(ctx context.Context, checkpoint *models.JobCheckpoint) error {
+	checkpointKey := fmt.Sprintf("checkpoint:%s", checkpoint.ResumeToken)
+	checkpointJSON, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+
+	jobKey := fmt.Sprintf("job:%s", checkpoint.JobID)
+	pipe := v.redisClient.Pipeline()
+	pipe.Set(ctx, checkpointKey, checkpointJSON, 24*time.Hour)
+	pipe.HSet(ctx, jobKey, "resume_token", checkpoint.ResumeToken)
+	pipe.HSet(ctx, jobKey, "stage", string(checkpoint.Stage))
+
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+func (v *videoRedisRepo) GetCheckpoint(ctx context.Context, resumeToken string) (*models.JobCheckpoint, error) {
+	checkpointKey := fmt.Sprintf("checkpoint:%s", resumeToken)
+
+	res, err := v.redisClient.Get(ctx, checkpointKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get checkpoint: %w", err)
+	}
+
+	checkpoint := &models.JobCheckpoint{}
+	if err = json.Unmarshal([]byte(res), checkpoint); err != nil {
+		return nil, fmt.Errorf("error unmarshalling checkpoint: %v", err)
+	}
+
+	return checkpoint, nil
+}
+
+func (v *videoRedisRepo) DeleteCheckpoint(ctx context.Context, resumeToken string) error {
+	checkpointKey := fmt.Sprintf("checkpoint:%s", resumeToken)
+	if err := v.redisClient.Del(ctx, checkpointKey).Err(); err != nil {
+		return fmt.Errorf("failed to delete checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// SetWorkerHeartbeat records that a worker is alive. The key expires after
+// ttl, so a worker that dies stops being reported as alive on its own.
+func (v *videoRedisRepo) SetWorkerHeartbeat(ctx context.Context, workerID string, ttl time.Duration) error {
+	heartbeatKey := fmt.Sprintf("worker:heartbeat:%s", workerID)
+	if err := v.redisClient.Set(ctx, heartbeatKey, time.Now().Format(time.RFC3339), ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set worker heartbeat: %w", err)
+	}
+
+	return nil
+}
+
+func (v *videoRedisRepo) IsWorkerAlive(ctx context.Context, workerID string) (bool, error) {
+	heartbeatKey := fmt.Sprintf("worker:heartbeat:%s", workerID)
+	count, err := v.redisClient.Exists(ctx, heartbeatKey).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to get worker heartbeat: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// SetWorkerRunningJobs records how many jobs a worker is processing, so the
+// autoscaler can tell an idle fleet from one that is busy with an empty queue.
+func (v *videoRedisRepo) SetWorkerRunningJobs(ctx context.Context, workerID string, jobs int, ttl time.Duration) error {
+	runningKey := fmt.Sprintf("worker:running:%s", workerID)
+	if err := v.redisClient.Set(ctx, runningKey, jobs, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set worker running jobs: %w", err)
+	}
+
+	return nil
+}
+
+func (v *videoRedisRepo) QueueLength(ctx context.Context, key string) (int64, error) {
+	return v.queue.Length(ctx, key)
+}
+
+// PeekQueue returns the raw payloads between start and stop without
+// dequeueing them. Index 0 is the next job.
+func (v *videoRedisRepo) PeekQueue(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	browser, ok := v.queue.(videofiles.QueueBrowser)
+	if !ok {
+		return nil, videofiles.ErrQueueNotBrowsable
+	}
+	return browser.Peek(ctx, key, start, stop)
+}
+
+// RemoveQueuedPayload removes a payload from a queue and returns how many
+// copies were removed.
+func (v *videoRedisRepo) RemoveQueuedPayload(ctx context.Context, key, payload string) (int64, error) {
+	browser, ok := v.queue.(videofiles.QueueBrowser)
+	if !ok {
+		return 0, videofiles.ErrQueueNotBrowsable
+	}
+	return browser.Remove(ctx, key, []byte(payload))
+}
+
+// maxQueueScan bounds how many queued jobs QueuePosition looks through
+const maxQueueScan = 1000
+
+// QueuePosition returns how many jobs of the queue are dispatched before the
+// job, or -1 when it is not among the first maxQueueScan.
+func (v *videoRedisRepo) QueuePosition(ctx context.Context, key, jobID string) (int64, error) {
+	browser, ok := v.queue.(videofiles.QueueBrowser)
+	if !ok {
+		return -1, videofiles.ErrQueueNotBrowsable
+	}
+	payload, err := v.redisClient.HGet(ctx, fmt.Sprintf("job:%s", jobID), "payload").Result()
+	if err != nil {
+		return -1, fmt.Errorf("failed to get job payload: %w", err)
+	}
+	queued, err := browser.Peek(ctx, key, 0, maxQueueScan-1)
+	if err != nil {
+		return -1, err
+	}
+	for i, member := range queued {
+		if member == payload {
+			return int64(i), nil
+		}
+	}
+	return -1, nil
+}
+
+// GetJobHash returns the job hash exactly as stored.
+func (v *videoRedisRepo) GetJobHash(ctx context.Context, jobID string) (map[string]string, error) {
+	jobKey := fmt.Sprintf("job:%s", jobID)
+	fields, err := v.redisClient.HGetAll(ctx, jobKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job hash: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("job not found")
+	}
+	return fields, nil
+}
+
+// SetWorkerCapabilities advertises what a worker can run. Like heartbeats, the
+// key expires after ttl so workers that are gone stop being considered.
+func (v *videoRedisRepo) SetWorkerCapabilities(ctx context.Context, caps *models.WorkerCapabilities, ttl time.Duration) error {
+	capsKey := fmt.Sprintf("worker:capabilities:%s", caps.WorkerID)
+	capsJSON, err := json.Marshal(caps)
+	if err != nil {
+		return fmt.Errorf("failed to marshal worker capabilities: %w", err)
+	}
+
+	if err := v.redisClient.Set(ctx, capsKey, capsJSON, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set worker capabilities: %w", err)
+	}
+
+	return nil
+}
+
+// SetWorkerStatus records the jobs a worker is running. It expires with the
+// worker's heartbeat.
+func (v *videoRedisRepo) SetWorkerStatus(ctx context.Context, status *models.WorkerStatus, ttl time.Duration) error {
+	statusKey := fmt.Sprintf("worker:status:%s", status.WorkerID)
+	statusJSON, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("failed to marshal worker status: %w", err)
+	}
+
+	if err := v.redisClient.Set(ctx, statusKey, statusJSON, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set worker status: %w", err)
+	}
+
+	return nil
+}
+
+// SetJobHeartbeat records that workerID is still processing a job. The job
+// is listed in ProcessingJobsKey until ClearJobHeartbeat, while the heartbeat
+// itself expires after ttl, so a job that outlives its heartbeat was orphaned.
+func (v *videoRedisRepo) SetJobHeartbeat(ctx context.Context, jobID, workerID string, ttl time.Duration) error {
+	heartbeatKey := fmt.Sprintf("job:heartbeat:%s", jobID)
+
+	pipe := v.redisClient.Pipeline()
+	pipe.Set(ctx, heartbeatKey, workerID, ttl)
+	pipe.HSet(ctx, videofiles.ProcessingJobsKey, jobID, workerID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to set job heartbeat: %w", err)
+	}
+
+	return nil
+}
+
+func (v *videoRedisRepo) ClearJobHeartbeat(ctx context.Context, jobID string) error {
+	heartbeatKey := fmt.Sprintf("job:heartbeat:%s", jobID)
+
+	pipe := v.redisClient.Pipeline()
+	pipe.Del(ctx, heartbeatKey)
+	pipe.HDel(ctx, videofiles.ProcessingJobsKey, jobID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to clear job heartbeat: %w", err)
+	}
+
+	return nil
+}
+
+// ListOrphanedJobs returns the jobs still listed as processing whose heartbeat
+// has expired, mapped to the worker that last processed them.
+func (v *videoRedisRepo) ListOrphanedJobs(ctx context.Context) (map[string]string, error) {
+	processing, err := v.redisClient.HGetAll(ctx, videofiles.ProcessingJobsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processing jobs: %w", err)
+	}
+	if len(processing) == 0 {
+		return nil, nil
+	}
+
+	pipe := v.redisClient.Pipeline()
+	exists := make(map[string]*redis.IntCmd, len(processing))
+	for jobID := range processing {
+		exists[jobID] = pipe.Exists(ctx, fmt.Sprintf("job:heartbeat:%s", jobID))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to check job heartbeats: %w", err)
+	}
+
+	orphaned := make(map[string]string)
+	for jobID, cmd := range exists {
+		if cmd.Val() == 0 {
+			orphaned[jobID] = processing[jobID]
+		}
+	}
+	return orphaned, nil
+}
+
+// ClaimOrphanedJob takes an orphaned job off ProcessingJobsKey so only one
+// reaper recovers it. It returns how many times the job has been recovered,
+// or 0 if another reaper claimed it first.
+func (v *videoRedisRepo) ClaimOrphanedJob(ctx context.Context, jobID string) (int64, error) {
+	removed, err := v.redisClient.HDel(ctx, videofiles.ProcessingJobsKey, jobID).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim orphaned job: %w", err)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	recoveries, err := v.redisClient.HIncrBy(ctx, fmt.Sprintf("job:%s", jobID), "recoveries", 1).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count job recoveries: %w", err)
+	}
+	return recoveries, nil
+}
+
+func (v *videoRedisRepo) ListWorkerStatuses(ctx context.Context) ([]*models.WorkerStatus, error) {
+	var workers []*models.WorkerStatus
+
+	iter := v.redisClient.Scan(ctx, 0, "worker:status:*", 100).Iterator()
+	for iter.Next(ctx) {
+		res, err := v.redisClient.Get(ctx, iter.Val()).Result()
+		if err != nil {
+			// The worker went away between the scan and the read
+			continue
+		}
+
+		status := &models.WorkerStatus{}
+		if err := json.Unmarshal([]byte(res), status); err != nil {
+			continue
+		}
+		workers = append(workers, status)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list worker statuses: %w", err)
+	}
+
+	return workers, nil
+}
+
+func (v *videoRedisRepo) ListWorkerCapabilities(ctx context.Context) ([]*models.WorkerCapabilities, error) {
+	var workers []*models.WorkerCapabilities
+
+	iter := v.redisClient.Scan(ctx, 0, "worker:capabilities:*", 100).Iterator()
+	for iter.Next(ctx) {
+		res, err := v.redisClient.Get(ctx, iter.Val()).Result()
+		if err != nil {
+			// The worker went away between the scan and the read
+			continue
+		}
+
+		caps := &models.WorkerCapabilities{}
+		if err := json.Unmarshal([]byte(res), caps); err != nil {
+			continue
+		}
+		workers = append(workers, caps)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list worker capabilities: %w", err)
+	}
+
+	return workers, nil
+}
+
+func (v *videoRedisRepo) SaveSmokeTest(ctx context.Context, test *models.SmokeTest, ttl time.Duration) error {
+	smokeTestKey := fmt.Sprintf("smoketest:%s", test.VideoID)
+	smokeTestJSON, err := json.Marshal(test)
+	if err != nil {
+		return fmt.Errorf("failed to marshal smoke test: %w", err)
+	}
+
+	if err := v.redisClient.Set(ctx, smokeTestKey, smokeTestJSON, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save smoke test: %w", err)
+	}
+
+	return nil
+}
+
+func (v *videoRedisRepo) GetSmokeTest(ctx context.Context, videoID string) (*models.SmokeTest, error) {
+	smokeTestKey := fmt.Sprintf("smoketest:%s", videoID)
+
+	res, err := v.redisClient.Get(ctx, smokeTestKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get smoke test: %w", err)
+	}
+
+	test := &models.SmokeTest{}
+	if err = json.Unmarshal([]byte(res), test); err != nil {
+		return nil, fmt.Errorf("error unmarshalling smoke test: %v", err)
+	}
+
+	return test, nil
+}
+
+func (v *videoRedisRepo) SaveEncodePlan(ctx context.Context, plan *models.EncodePlan, ttl time.Duration) error {
+	planKey := fmt.Sprintf("encodeplan:%s", plan.JobID)
+	planJSON, err := json.Marshal(plan)
+	if err != nil {
+		return fmt.Errorf("failed to marshal encode plan: %w", err)
+	}
+
+	if err := v.redisClient.Set(ctx, planKey, planJSON, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save encode plan: %w", err)
+	}
+
+	return nil
+}
+
+func (v *videoRedisRepo) GetEncodePlan(ctx context.Context, jobID string) (*models.EncodePlan, error) {
+	planKey := fmt.Sprintf("encodeplan:%s", jobID)
+
+	res, err := v.redisClient.Get(ctx, planKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encode plan: %w", err)
+	}
+
+	plan := &models.EncodePlan{}
+	if err = json.Unmarshal([]byte(res), plan); err != nil {
+		return nil, fmt.Errorf("error unmarshalling encode plan: %v", err)
+	}
+
+	return plan, nil
+}
+
+// SaveChunkedUpload stores a chunked upload session. The checksums of
+// received chunks are kept in a separate hash so chunks can be recorded
+// concurrently.
+func (v *videoRedisRepo) SaveChunkedUpload(ctx context.Context, upload *models.ChunkedUpload, ttl time.Duration) error {
+	uploadKey := fmt.Sprintf("chunkupload:%s", upload.UploadID)
+	uploadJSON, err := json.Marshal(upload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal chunked upload: %w", err)
+	}
+
+	if err := v.redisClient.Set(ctx, uploadKey, uploadJSON, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save chunked upload: %w", err)
+	}
+
+	return nil
+}
+
+func (v *videoRedisRepo) GetChunkedUpload(ctx context.Context, uploadID string) (*models.ChunkedUpload, error) {
+	uploadKey := fmt.Sprintf("chunkupload:%s", uploadID)
+
+	res, err := v.redisClient.Get(ctx, uploadKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunked upload: %w", err)
+	}
+
+	upload := &models.ChunkedUpload{}
+	if err = json.Unmarshal([]byte(res), upload); err != nil {
+		return nil, fmt.Errorf("error unmarshalling chunked upload: %v", err)
+	}
+
+	checksums, err := v.redisClient.HGetAll(ctx, uploadKey+":chunks").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunk checksums: %w", err)
+	}
+	upload.ChunkChecksums = make(map[int]string, len(checksums))
+	for field, checksum := range checksums {
+		index, err := strconv.Atoi(field)
+		if err != nil {
+			continue
+		}
+		upload.ChunkChecksums[index] = checksum
+	}
+
+	return upload, nil
+}
+
+func (v *videoRedisRepo) SetChunkChecksum(ctx context.Context, uploadID string, index int, checksum string) error {
+	uploadKey := fmt.Sprintf("chunkupload:%s", uploadID)
+	ttl, err := v.redisClient.TTL(ctx, uploadKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get chunked upload ttl: %w", err)
+	}
+
+	pipe := v.redisClient.Pipeline()
+	pipe.HSet(ctx, uploadKey+":chunks", strconv.Itoa(index), checksum)
+	if ttl > 0 {
+		pipe.Expire(ctx, uploadKey+":chunks", ttl)
+	}
+
+	if _, err = pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to set chunk checksum: %w", err)
+	}
+
+	return nil
+}
+
+func (v *videoRedisRepo) DeleteChunkChecksum(ctx context.Context, uploadID string, index int) error {
+	uploadKey := fmt.Sprintf("chunkupload:%s", uploadID)
+	if err := v.redisClient.HDel(ctx, uploadKey+":chunks", strconv.Itoa(index)).Err(); err != nil {
+		return fmt.Errorf("failed to delete chunk checksum: %w", err)
+	}
+
+	return nil
+}
+
+func (v *videoRedisRepo) DeleteChunkedUpload(ctx context.Context, uploadID string) error {
+	uploadKey := fmt.Sprintf("chunkupload:%s", uploadID)
+	if err := v.redisClient.Del(ctx, uploadKey, uploadKey+":chunks", uploadKey+":progress").Err(); err != nil {
+		return fmt.Errorf("failed to delete chunked upload: %w", err)
+	}
+
+	return nil
+}
+
+// SetUploadProgress stores the progress of a streamed upload, polled by
+// clients while the stream is received
+func (v *videoRedisRepo) SetUploadProgress(ctx context.Context, progress *models.UploadProgress, ttl time.Duration) error {
+	progressJSON, err := json.Marshal(progress)
+	if err != nil {
+		return fmt.Errorf("failed to marshal upload progress: %w", err)
+	}
+
+	progressKey := fmt.Sprintf("chunkupload:%s:progress", progress.UploadID)
+	if err := v.redisClient.Set(ctx, progressKey, progressJSON, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save upload progress: %w", err)
+	}
+
+	return nil
+}
+
+func (v *videoRedisRepo) GetUploadProgress(ctx context.Context, uploadID string) (*models.UploadProgress, error) {
+	progressKey := fmt.Sprintf("chunkupload:%s:progress", uploadID)
+
+	res, err := v.redisClient.Get(ctx, progressKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload progress: %w", err)
+	}
+
+	progress := &models.UploadProgress{}
+	if err = json.Unmarshal([]byte(res), progress); err != nil {
+		return nil, fmt.Errorf("error unmarshalling upload progress: %v", err)
+	}
+
+	return progress, nil
+}
+
+func (v *videoRedisRepo) EnqueueVideoDeletion(ctx context.Context, deletion *models.VideoDeletion) error {
+	deletionJSON, err := json.Marshal(deletion)
+	if err != nil {
+		return fmt.Errorf("failed to marshal video deletion: %w", err)
+	}
+	if err := v.redisClient.RPush(ctx, videofiles.VideoDeletionsKey, deletionJSON).Err(); err != nil {
+		return fmt.Errorf("failed to queue video deletion: %w", err)
+	}
+	return nil
+}
+
+// DequeueVideoDeletion waits up to timeout for the next video deletion. It
+// returns nil when there is none.
+func (v *videoRedisRepo) DequeueVideoDeletion(ctx context.Context, timeout time.Duration) (*models.VideoDeletion, error) {
+	res, err := v.redisClient.BLPop(ctx, timeout, videofiles.VideoDeletionsKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to dequeue video deletion: %w", err)
+	}
+
+	deletion := &models.VideoDeletion{}
+	if err := json.Unmarshal([]byte(res[1]), deletion); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal video deletion: %w", err)
+	}
+	return deletion, nil
 }
 
 func (v *videoRedisRepo) GetRedisClient() *redis.Client {
@@ -1,14 +1,15 @@
 package repository
 
 const (
-	createVideoQuery = `INSERT INTO video_files (user_id, file_name, file_size, duration, progress, s3_key, status,  s3_bucket, format) 
-					VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6, $7, $8, $9) RETURNING *`
-	getVideosByUserIDQuery = `SELECT video_id, user_id, file_name, file_size, duration, s3_key, s3_bucket, format, status, uploaded_at, updated_at FROM video_files
-					WHERE user_id = $1 ORDER BY uploaded_at OFFSET $2 LIMIT $3`
-	getVideoByIDQuery = `SELECT video_id, user_id, file_name, file_size, duration, s3_key, s3_bucket, format, progress, status, uploaded_at, updated_at FROM video_files
+	createVideoQuery = `INSERT INTO video_files (user_id, file_name, file_size, duration, progress, s3_key, status,  s3_bucket, format, encrypted, encrypted_data_key, visibility, share_token, kind) 
+					VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6, $7, $8, $9, $10, $11, $12, $13, COALESCE(NULLIF($14, ''), 'video'))
+					RETURNING video_id, user_id, file_name, file_size, duration, s3_key, s3_bucket, format, progress, status, uploaded_at, updated_at, encrypted, encrypted_data_key, visibility, share_token, folder_id, worker_id, progress_updated_at, drm_key_id, drm_scheme, title, description, tags, kind`
+	getVideosByUserIDQuery = `SELECT video_id, user_id, file_name, file_size, duration, s3_key, s3_bucket, format, progress, status, visibility, folder_id, worker_id, progress_updated_at, uploaded_at, updated_at FROM video_files
+					WHERE user_id = $1 AND ($2::uuid IS NULL OR folder_id = $2) AND deleted_at IS NULL ORDER BY uploaded_at OFFSET $3 LIMIT $4`
+	getVideoByIDQuery = `SELECT video_id, user_id, file_name, file_size, duration, s3_key, s3_bucket, format, progress, status, uploaded_at, updated_at, encrypted, encrypted_data_key, visibility, share_token, folder_id, worker_id, progress_updated_at, drm_key_id, drm_scheme, title, description, tags, deleted_at, kind FROM video_files
 					WHERE video_id = $1`
-	getTotalVideosByUserIDQuery = `SELECT COUNT(video_id) FROM video_files WHERE user_id = $1`
-	getTotalVideosCountQuery    = `SELECT COUNT(video_id) FROM video_files WHERE user_id = $1 AND file_name ILIKE '%' || $2 || '%'`
+	getTotalVideosByUserIDQuery = `SELECT COUNT(video_id) FROM video_files WHERE user_id = $1 AND ($2::uuid IS NULL OR folder_id = $2) AND deleted_at IS NULL`
+	getTotalVideosCountQuery    = `SELECT COUNT(video_id) FROM video_files WHERE user_id = $1 AND search_vector @@ websearch_to_tsquery('english', $2) AND deleted_at IS NULL`
 	updateVideoQuery            = `UPDATE video_files 
 									SET file_name = COALESCE(nullif($1, ''), file_name),
 									    file_size = COALESCE(nullif($2, 0), file_size),
@@ -16,12 +17,216 @@ const (
 									    s3_key = COALESCE(nullif($4, ''), s3_key),
 									    s3_bucket = COALESCE(nullif($5, ''), s3_bucket),
 									    format = COALESCE(nullif($6, ''), format),
-									    status = COALESCE(nullif($7, ''), status)
-									WHERE video_id = $8 `
-	getVideosBySearchQuery = `SELECT video_id, user_id, file_name, file_size, duration, s3_key, s3_bucket, format, status, uploaded_at, updated_at FROM video_files
-					WHERE user_id = $1 AND file_name ILIKE '%' || $2 || '%' ORDER BY uploaded_at OFFSET $3 LIMIT $4`
-	deleteVideoQuery     = `DELETE FROM video_files WHERE video_id = $1 AND user_id = $2`
+									    status = COALESCE(nullif($7, ''), status),
+									    title = COALESCE(nullif($8, ''), title),
+									    description = COALESCE(nullif($9, ''), description),
+									    tags = COALESCE($10, tags),
+									    updated_at = CURRENT_TIMESTAMP
+									WHERE video_id = $11
+									RETURNING video_id, user_id, file_name, file_size, duration, s3_key, s3_bucket, format, progress, status, uploaded_at, updated_at, title, description, tags`
+	// getVideosBySearchQuery ranks matches by relevance, and when $5 is set
+	// marks the matched words of the title and description with <mark>
+	getVideosBySearchQuery = `SELECT video_id, user_id, file_name, file_size, duration, s3_key, s3_bucket, format, status, visibility, folder_id, uploaded_at, updated_at, title, description, tags,
+					ts_rank_cd(search_vector, query) AS rank,
+					CASE WHEN $5 THEN ts_headline('english', COALESCE(NULLIF(title, ''), file_name) || ' ' || description, query,
+						'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5') END AS highlight
+					FROM video_files, websearch_to_tsquery('english', $2) query
+					WHERE user_id = $1 AND search_vector @@ query AND deleted_at IS NULL ORDER BY rank DESC, uploaded_at DESC OFFSET $3 LIMIT $4`
+	// lockVideoQuery and lockTrashedVideoQuery hold the row of a video being
+	// deleted so it cannot be restored halfway through
+	lockVideoQuery        = `SELECT video_id FROM video_files WHERE video_id = $1 AND user_id = $2 FOR UPDATE`
+	lockTrashedVideoQuery = `SELECT video_id FROM video_files WHERE video_id = $1 AND deleted_at < $2 FOR UPDATE`
+	deleteVideoQuery      = `DELETE FROM video_files WHERE video_id = $1`
+	trashVideoQuery       = `UPDATE video_files SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+					WHERE video_id = $1 AND user_id = $2 AND deleted_at IS NULL RETURNING deleted_at`
+	restoreVideoQuery = `UPDATE video_files SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+					WHERE video_id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`
+	getTrashedVideosQuery = `SELECT video_id, user_id, file_name, file_size, duration, s3_key, s3_bucket, format, progress, status, visibility, folder_id, title, uploaded_at, updated_at, deleted_at FROM video_files
+					WHERE user_id = $1 AND deleted_at IS NOT NULL ORDER BY deleted_at DESC OFFSET $2 LIMIT $3`
+	getTotalTrashedVideosQuery = `SELECT COUNT(video_id) FROM video_files WHERE user_id = $1 AND deleted_at IS NOT NULL`
+	// getExpiredTrashedVideosQuery lists videos of every user that have been
+	// in the trash since before $1, longest first
+	getExpiredTrashedVideosQuery = `SELECT video_id, user_id, file_name, s3_key, s3_bucket, deleted_at FROM video_files
+					WHERE deleted_at < $1 ORDER BY deleted_at LIMIT $2`
 	getPlaybackInfoQuery = `SELECT video_id, title, duration, thumbnail, qualities, subtitles, format, status, error_message, created_at, updated_at 
 						FROM playback_info WHERE video_id = $1`
+	// setPlaybackErrorQuery marks playback info as failed, keeping the outputs
+	// of an earlier successful run if there was one
+	setPlaybackErrorQuery = `INSERT INTO playback_info (video_id, title, duration, thumbnail, format, status, error_message)
+					VALUES ($1, $2, 0, '', 'hls', 'failed', $3)
+					ON CONFLICT (video_id) DO UPDATE SET status = 'failed', error_message = EXCLUDED.error_message, updated_at = CURRENT_TIMESTAMP`
+	setPosterQuery        = `UPDATE playback_info SET thumbnail = $1, updated_at = CURRENT_TIMESTAMP WHERE video_id = $2`
+	updateVisibilityQuery = `UPDATE video_files SET visibility = $1, share_token = $2, updated_at = CURRENT_TIMESTAMP
+					WHERE video_id = $3 AND user_id = $4`
+	moveVideoQuery = `UPDATE video_files SET folder_id = $1, updated_at = CURRENT_TIMESTAMP
+					WHERE video_id = $2 AND user_id = $3`
+
+	createFolderQuery  = `INSERT INTO folders (user_id, parent_id, name) VALUES ($1, $2, $3) RETURNING *`
+	getFolderByIDQuery = `SELECT folder_id, user_id, parent_id, name, created_at, updated_at FROM folders
+					WHERE folder_id = $1`
+	getFoldersQuery = `SELECT folder_id, user_id, parent_id, name, created_at, updated_at FROM folders
+					WHERE user_id = $1 AND parent_id IS NOT DISTINCT FROM $2 ORDER BY name`
+	updateFolderQuery = `UPDATE folders SET name = $1, parent_id = $2, updated_at = CURRENT_TIMESTAMP
+					WHERE folder_id = $3 AND user_id = $4`
+	deleteFolderQuery = `DELETE FROM folders WHERE folder_id = $1 AND user_id = $2`
+	// isFolderDescendantQuery reports whether $2 is $1 or one of its subfolders
+	isFolderDescendantQuery = `WITH RECURSIVE subfolders AS (
+						SELECT folder_id FROM folders WHERE folder_id = $1
+						UNION
+						SELECT f.folder_id FROM folders f JOIN subfolders s ON f.parent_id = s.folder_id
+					)
+					SELECT EXISTS (SELECT 1 FROM subfolders WHERE folder_id = $2)`
+	setVideoWorkerQuery = `UPDATE video_files SET worker_id = $1 WHERE video_id = $2`
+	setVideoDRMKeyQuery = `UPDATE video_files SET drm_key_id = $1, drm_scheme = $2 WHERE video_id = $3`
+	// getStaleVideosQuery lists processing videos whose progress has not moved
+	// since $1
+	getStaleVideosQuery = `SELECT video_id, user_id, file_name, file_size, duration, s3_key, s3_bucket, format, progress, status, visibility, folder_id, worker_id, progress_updated_at, uploaded_at, updated_at FROM video_files
+					WHERE status = 'in_progress' AND progress_updated_at < $1 ORDER BY progress_updated_at`
+	// getCompletedVideosQuery lists the finished videos of every user with what
+	// a repackage job needs; audio files and images have nothing to repackage
+	getCompletedVideosQuery = `SELECT video_id, user_id, file_name, file_size, s3_key, s3_bucket, format, status, encrypted, encrypted_data_key, drm_key_id, uploaded_at, updated_at, kind FROM video_files
+					WHERE status = 'completed' AND kind = 'video' AND deleted_at IS NULL ORDER BY uploaded_at`
+	videoExistsByS3KeyQuery   = `SELECT EXISTS (SELECT 1 FROM video_files WHERE s3_bucket = $1 AND s3_key = $2)`
+	createJobEnvironmentQuery = `INSERT INTO job_environments (job_id, video_id, environment) VALUES ($1, $2, $3)
+					ON CONFLICT (job_id) DO UPDATE SET environment = EXCLUDED.environment`
+	getJobEnvironmentsQuery = `SELECT environment FROM job_environments WHERE video_id = $1 ORDER BY created_at DESC`
+
+	setSourceMetadataQuery = `UPDATE video_files SET source_metadata = $2 WHERE video_id = $1`
+	getSourceMetadataQuery = `SELECT source_metadata FROM video_files WHERE video_id = $1`
+
+	saveFingerprintQuery = `INSERT INTO video_fingerprints (video_id, interval, hashes) VALUES ($1, $2, $3)
+					ON CONFLICT (video_id) DO UPDATE SET interval = EXCLUDED.interval, hashes = EXCLUDED.hashes, created_at = NOW()`
+	getFingerprintQuery = `SELECT f.video_id, f.interval, f.hashes, v.file_name, f.created_at
+					FROM video_fingerprints f JOIN video_files v ON v.video_id = f.video_id WHERE f.video_id = $1`
+	// Trashed videos are not reported as duplicates of anything
+	getUserFingerprintsQuery = `SELECT f.video_id, f.interval, f.hashes, v.file_name, f.created_at
+					FROM video_fingerprints f JOIN video_files v ON v.video_id = f.video_id
+					WHERE v.user_id = $1 AND v.deleted_at IS NULL`
+
+	// Outputs of trashed videos are not handed out, since they are purged
+	// along with the entry when the trash is emptied
+	getOutputCacheQuery = `SELECT c.user_id, c.source_hash, c.profile_hash, c.video_id, c.output_prefix, c.created_at
+					FROM output_cache c JOIN video_files v ON v.video_id = c.video_id
+					WHERE c.user_id = $1 AND c.source_hash = $2 AND c.profile_hash = $3 AND v.deleted_at IS NULL`
+	saveOutputCacheQuery = `INSERT INTO output_cache (user_id, source_hash, profile_hash, video_id, output_prefix)
+					VALUES ($1, $2, $3, $4, $5)
+					ON CONFLICT (user_id, source_hash, profile_hash) DO UPDATE
+					SET video_id = EXCLUDED.video_id, output_prefix = EXCLUDED.output_prefix, created_at = now()`
+
+	createJobUsageQuery = `INSERT INTO job_usage (job_id, video_id, user_id, job_type, codec, hardware_class, outcome,
+					source_seconds, compute_seconds, cost, currency, started_at, finished_at, resolution)
+					VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
+	getEncodeThroughputQuery = `SELECT codec, resolution, hardware_class,
+						SUM(source_seconds) / SUM(compute_seconds) AS speed, COUNT(*) AS jobs
+					FROM job_usage
+					WHERE outcome = 'completed' AND job_type = 'encode' AND finished_at >= $1
+						AND source_seconds > 0 AND compute_seconds > 0
+					GROUP BY codec, resolution, hardware_class`
+
+	createBillingEventQuery = `INSERT INTO billing_events (event_id, type, job_id, video_id, user_id, job_type, tier,
+					encode_minutes, storage_bytes, egress_bytes_per_view)
+					VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	// claimBillingEventsQuery takes up to $1 pending events due for delivery
+	// and pushes their next attempt back by $2 seconds per attempt made, so
+	// other workers skip them and failing receivers are retried less often
+	claimBillingEventsQuery = `UPDATE billing_events SET attempts = attempts + 1,
+					next_attempt_at = now() + make_interval(secs => $2 * (attempts + 1))
+					WHERE id IN (SELECT id FROM billing_events
+						WHERE delivered_at IS NULL AND next_attempt_at <= now() AND attempts < $3
+						ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED)
+					RETURNING id, event_id, type, job_id, video_id, user_id, job_type, tier, encode_minutes,
+						storage_bytes, egress_bytes_per_view, created_at, delivered_at, attempts`
+	markBillingEventDeliveredQuery = `UPDATE billing_events SET delivered_at = now() WHERE id = $1`
+
+	createJobEventQuery = `INSERT INTO job_events (job_id, video_id, event, job_type, codec, failure_reason)
+					VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))`
+	getJobEventsQuery = `SELECT job_id, video_id, event, job_type, codec, COALESCE(failure_reason, '') AS failure_reason, created_at
+					FROM job_events
+					WHERE job_id = (SELECT job_id FROM job_events WHERE video_id = $1 ORDER BY created_at DESC, id DESC LIMIT 1)
+					ORDER BY created_at, id`
+	// Every finished attempt is paired with the last start and queueing of
+	// its job before it. Events up to $4 before the window are read so jobs
+	// queued before it are still paired.
+	getJobThroughputQuery = `WITH attempts AS (
+						SELECT event, created_at,
+							CASE WHEN job_type = 'repackage' THEN job_type ELSE codec END AS profile,
+							MAX(created_at) FILTER (WHERE event = 'queued') OVER w AS queued_at,
+							MAX(created_at) FILTER (WHERE event = 'started') OVER w AS started_at
+						FROM job_events
+						WHERE created_at >= $2::timestamptz - $4::interval AND created_at < $3
+						WINDOW w AS (PARTITION BY job_id ORDER BY created_at, id ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW)
+					)
+					SELECT date_trunc($1, created_at) AS bucket, profile,
+						COUNT(*) AS finished,
+						COUNT(*) FILTER (WHERE event = 'failed') AS failed,
+						COALESCE(SUM(EXTRACT(EPOCH FROM started_at - queued_at)) FILTER (WHERE started_at >= queued_at), 0) AS queue_wait_total,
+						COUNT(*) FILTER (WHERE started_at >= queued_at) AS queue_wait_count,
+						COALESCE(SUM(EXTRACT(EPOCH FROM created_at - started_at)) FILTER (WHERE event = 'completed' AND started_at IS NOT NULL), 0) AS encode_total,
+						COUNT(*) FILTER (WHERE event = 'completed' AND started_at IS NOT NULL) AS encode_count
+					FROM attempts
+					WHERE event IN ('completed', 'failed') AND created_at >= $2
+					GROUP BY bucket, profile
+					ORDER BY bucket, profile`
+
+	// createPlaybackVersionQuery numbers a new version after every version of
+	// the video, including the active one if it was never recorded
+	createPlaybackVersionQuery = `INSERT INTO playback_versions (video_id, version, job_id, profile, status)
+					VALUES ($1, GREATEST(
+						(SELECT COALESCE(MAX(version), 0) FROM playback_versions WHERE video_id = $1),
+						(SELECT COALESCE(MAX(version), 0) FROM playback_info WHERE video_id = $1)) + 1, $2, $3, $4)
+					RETURNING version`
+	// snapshotPlaybackQuery records the active playback info as a version so
+	// it can be rolled back to. The poster may have changed since.
+	snapshotPlaybackQuery = `INSERT INTO playback_versions (video_id, version, status, title, duration, thumbnail, thumbnails, qualities, subtitles, chapters, preview, waveform, format, drm_key_id, drm_scheme, created_at, completed_at)
+					SELECT p.video_id, p.version, p.status, p.title, p.duration, p.thumbnail, p.thumbnails, p.qualities, COALESCE(p.subtitles, ARRAY[]::TEXT[]), p.chapters, p.preview, p.waveform, p.format,
+						v.drm_key_id, v.drm_scheme, COALESCE(p.created_at, CURRENT_TIMESTAMP), p.updated_at
+					FROM playback_info p JOIN video_files v ON v.video_id = p.video_id
+					WHERE p.video_id = $1 AND p.status = 'completed'
+					ON CONFLICT (video_id, version) DO UPDATE SET thumbnail = EXCLUDED.thumbnail`
+	completePlaybackVersionQuery = `UPDATE playback_versions SET status = 'completed', error_message = NULL,
+						title = $3, duration = $4, thumbnail = $5, thumbnails = $6, qualities = $7, subtitles = $8, format = $9,
+						drm_key_id = NULLIF($10, ''), drm_scheme = NULLIF($11, ''), chapters = $12, preview = NULLIF($13, ''), waveform = NULLIF($14, ''), completed_at = CURRENT_TIMESTAMP
+					WHERE video_id = $1 AND version = $2`
+	failPlaybackVersionQuery = `UPDATE playback_versions SET status = 'failed', error_message = $3, completed_at = CURRENT_TIMESTAMP
+					WHERE video_id = $1 AND version = $2`
+	// activatePlaybackVersionQuery points the playback info at a completed
+	// version
+	activatePlaybackVersionQuery = `INSERT INTO playback_info (video_id, title, duration, thumbnail, thumbnails, qualities, subtitles, chapters, preview, waveform, format, status, error_message, version, created_at, updated_at)
+					SELECT video_id, title, duration, thumbnail, thumbnails, qualities, subtitles, chapters, preview, waveform, format, 'completed', NULL, version, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+					FROM playback_versions WHERE video_id = $1 AND version = $2 AND status = 'completed'
+					ON CONFLICT (video_id) DO UPDATE SET
+						title = EXCLUDED.title,
+						duration = EXCLUDED.duration,
+						thumbnail = EXCLUDED.thumbnail,
+						thumbnails = EXCLUDED.thumbnails,
+						qualities = EXCLUDED.qualities,
+						subtitles = EXCLUDED.subtitles,
+						chapters = EXCLUDED.chapters,
+						preview = EXCLUDED.preview,
+						waveform = EXCLUDED.waveform,
+						format = EXCLUDED.format,
+						status = EXCLUDED.status,
+						error_message = NULL,
+						version = EXCLUDED.version,
+						updated_at = CURRENT_TIMESTAMP`
+	setVersionDRMKeyQuery = `UPDATE video_files v SET drm_key_id = pv.drm_key_id, drm_scheme = pv.drm_scheme
+					FROM playback_versions pv WHERE v.video_id = pv.video_id AND pv.video_id = $1 AND pv.version = $2`
+	// getPlaybackVersionsQuery lists the versions of a video, newest first,
+	// with the active playback info standing in for its version until it
+	// has been recorded
+	getPlaybackVersionsQuery = `SELECT pv.video_id, pv.version, pv.job_id, pv.status, pv.error_message, pv.created_at, pv.completed_at,
+						COALESCE(pv.profile::text, '') AS profile, pv.qualities::text AS qualities,
+						COALESCE(p.version = pv.version AND p.status = 'completed', false) AS active
+					FROM playback_versions pv LEFT JOIN playback_info p ON p.video_id = pv.video_id
+					WHERE pv.video_id = $1
+					UNION ALL
+					SELECT p.video_id, p.version, NULL, p.status, p.error_message, COALESCE(p.created_at, p.updated_at), p.updated_at,
+						'', COALESCE(p.qualities::text, '{}'), p.status = 'completed'
+					FROM playback_info p
+					WHERE p.video_id = $1 AND NOT EXISTS (SELECT 1 FROM playback_versions WHERE video_id = p.video_id AND version = p.version)
+					ORDER BY version DESC`
+
+	getUserPlanQuery     = `SELECT plan FROM users WHERE user_id = $1`
+	getStorageQuotaQuery = `SELECT storage_quota_db FROM users WHERE user_id = $1`
 	getStorageUsageQuery = `SELECT user_id, SUM(file_size) as total_size FROM video_files WHERE user_id = $1 GROUP BY user_id`
 )
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+)
+
+// TrashVideo moves a video of the user to the trash and returns when it was
+// deleted. It fails with sql.ErrNoRows when the user has no such video
+// outside the trash.
+func (v *videoRepo) TrashVideo(ctx context.Context, userID uuid.UUID, videoID uuid.UUID) (time.Time, error) {
+	var deletedAt time.Time
+	if err := v.db.GetContext(ctx, &deletedAt, trashVideoQuery, videoID, userID); err != nil {
+		return time.Time{}, fmt.Errorf("failed to trash video: %w", err)
+	}
+	return deletedAt, nil
+}
+
+// RestoreVideo takes a video of the user out of the trash
+func (v *videoRepo) RestoreVideo(ctx context.Context, userID uuid.UUID, videoID uuid.UUID) error {
+	res, err := v.db.ExecContext(ctx, restoreVideoQuery, videoID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to restore video: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to restore video: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("no video found in the trash")
+	}
+	return nil
+}
+
+// GetTrashedVideos lists the videos in the user's trash, most recently
+// deleted first
+func (v *videoRepo) GetTrashedVideos(ctx context.Context, userID uuid.UUID, pq *utils.Pagination) (*models.VideoList, error) {
+	var totalCount int
+	if err := v.db.GetContext(ctx, &totalCount, getTotalTrashedVideosQuery, userID); err != nil {
+		return nil, fmt.Errorf("failed to get total trashed videos count: %w", err)
+	}
+	videos := make([]*models.VideoFile, 0, pq.GetSize())
+	if totalCount > 0 {
+		if err := v.db.SelectContext(ctx, &videos, getTrashedVideosQuery, userID, pq.GetOffset(), pq.GetLimit()); err != nil {
+			return nil, fmt.Errorf("failed to get trashed videos: %w", err)
+		}
+	}
+	return &models.VideoList{
+		Videos:     videos,
+		TotalCount: utils.GetTotalPages(totalCount, pq.GetSize()),
+		Page:       pq.GetPage(),
+		PageSize:   pq.GetSize(),
+		HasMore:    utils.GetHasMore(pq.GetPage(), totalCount, pq.GetSize()),
+	}, nil
+}
+
+// GetExpiredTrashedVideos lists up to limit videos of any user that have
+// been in the trash since before deletedBefore
+func (v *videoRepo) GetExpiredTrashedVideos(ctx context.Context, deletedBefore time.Time, limit int) ([]*models.VideoFile, error) {
+	var videos []*models.VideoFile
+	if err := v.db.SelectContext(ctx, &videos, getExpiredTrashedVideosQuery, deletedBefore, limit); err != nil {
+		return nil, fmt.Errorf("failed to get expired trashed videos: %w", err)
+	}
+	return videos, nil
+}
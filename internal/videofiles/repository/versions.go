@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// CreatePlaybackVersion records a new version of a video about to be encoded
+// with profile and returns its number. The active playback info is recorded
+// as a version first so it is kept for rollback.
+func (v *videoRepo) CreatePlaybackVersion(ctx context.Context, videoID uuid.UUID, jobID string, profile *models.ReencodeInput) (int, error) {
+	profileJSON, err := json.Marshal(profile)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal profile: %w", err)
+	}
+
+	tx, err := v.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, snapshotPlaybackQuery, videoID); err != nil {
+		return 0, fmt.Errorf("failed to record active playback version: %w", err)
+	}
+	var version int
+	if err := tx.GetContext(ctx, &version, createPlaybackVersionQuery, videoID, jobID, profileJSON, models.JobStatusQueued); err != nil {
+		return 0, fmt.Errorf("failed to create playback version: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit playback version: %w", err)
+	}
+	return version, nil
+}
+
+// ActivatePlaybackVersion stores the outputs of a finished version and
+// switches the video's playback info and DRM key to it in one transaction,
+// so viewers see either the old version or the new one.
+func (v *videoRepo) ActivatePlaybackVersion(ctx context.Context, videoID uuid.UUID, version int, info *models.PlaybackInfo, drmKeyID, drmScheme string) error {
+	qualitiesJSON, err := json.Marshal(info.Qualities)
+	if err != nil {
+		return fmt.Errorf("failed to marshal qualities: %w", err)
+	}
+	chaptersJSON, err := json.Marshal(info.Chapters)
+	if err != nil {
+		return fmt.Errorf("failed to marshal chapters: %w", err)
+	}
+
+	tx, err := v.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, snapshotPlaybackQuery, videoID); err != nil {
+		return fmt.Errorf("failed to record active playback version: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, completePlaybackVersionQuery,
+		videoID,
+		version,
+		info.Title,
+		info.Duration,
+		info.Thumbnail,
+		pq.Array(info.Thumbnails),
+		qualitiesJSON,
+		pq.Array(info.Subtitles),
+		info.Format,
+		drmKeyID,
+		drmScheme,
+		chaptersJSON,
+		info.Preview,
+		info.Waveform,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to complete playback version: %w", err)
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		return fmt.Errorf("playback version %d of video %s not found", version, videoID)
+	}
+	if err := switchPlaybackVersion(ctx, tx, videoID, version); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit playback version: %w", err)
+	}
+	return nil
+}
+
+// RollbackPlaybackVersion switches the video's playback back to an earlier
+// completed version. The version being replaced is kept.
+func (v *videoRepo) RollbackPlaybackVersion(ctx context.Context, videoID uuid.UUID, version int) error {
+	tx, err := v.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, snapshotPlaybackQuery, videoID); err != nil {
+		return fmt.Errorf("failed to record active playback version: %w", err)
+	}
+	if err := switchPlaybackVersion(ctx, tx, videoID, version); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit playback rollback: %w", err)
+	}
+	return nil
+}
+
+// switchPlaybackVersion points the playback info and DRM key of a video at a
+// completed version
+func switchPlaybackVersion(ctx context.Context, tx *sqlx.Tx, videoID uuid.UUID, version int) error {
+	res, err := tx.ExecContext(ctx, activatePlaybackVersionQuery, videoID, version)
+	if err != nil {
+		return fmt.Errorf("failed to activate playback version: %w", err)
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		return fmt.Errorf("playback version %d is not completed", version)
+	}
+	if _, err := tx.ExecContext(ctx, setVersionDRMKeyQuery, videoID, version); err != nil {
+		return fmt.Errorf("failed to set drm key of playback version: %w", err)
+	}
+	return nil
+}
+
+// FailPlaybackVersion marks a version whose encode failed. Playback stays on
+// the active version.
+func (v *videoRepo) FailPlaybackVersion(ctx context.Context, videoID uuid.UUID, version int, message string) error {
+	if _, err := v.db.ExecContext(ctx, failPlaybackVersionQuery, videoID, version, message); err != nil {
+		return fmt.Errorf("failed to mark playback version failed: %w", err)
+	}
+	return nil
+}
+
+// GetPlaybackVersions lists the versions of a video, newest first
+func (v *videoRepo) GetPlaybackVersions(ctx context.Context, videoID uuid.UUID) ([]*models.PlaybackVersion, error) {
+	rows, err := v.db.QueryxContext(ctx, getPlaybackVersionsQuery, videoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get playback versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make([]*models.PlaybackVersion, 0)
+	for rows.Next() {
+		var row struct {
+			models.PlaybackVersion
+			ProfileRaw   string `db:"profile"`
+			QualitiesRaw string `db:"qualities"`
+		}
+		if err := rows.StructScan(&row); err != nil {
+			return nil, fmt.Errorf("failed to scan playback version: %w", err)
+		}
+		version := row.PlaybackVersion
+		if row.ProfileRaw != "" && row.ProfileRaw != "null" {
+			version.Profile = &models.ReencodeInput{}
+			if err := json.Unmarshal([]byte(row.ProfileRaw), version.Profile); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal profile: %w", err)
+			}
+		}
+		var qualities map[models.VideoQuality]json.RawMessage
+		if err := json.Unmarshal([]byte(row.QualitiesRaw), &qualities); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal qualities: %w", err)
+		}
+		version.Qualities = make([]models.VideoQuality, 0, len(qualities))
+		for quality := range qualities {
+			version.Qualities = append(version.Qualities, quality)
+		}
+		sort.Slice(version.Qualities, func(i, j int) bool { return version.Qualities[i] < version.Qualities[j] })
+		versions = append(versions, &version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan playback versions: %w", err)
+	}
+	return versions, nil
+}
@@ -2,22 +2,131 @@ package videofiles
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/google/uuid"
+	"io"
 )
 
+var (
+	// ErrIngestDisabled is returned by the S3 ingest endpoint when no ingest
+	// token is configured
+	ErrIngestDisabled = errors.New("s3 ingestion is not enabled")
+	// ErrIngestUnauthorized is returned for notifications with a wrong token
+	ErrIngestUnauthorized = errors.New("invalid ingest token")
+	// ErrPlaybackForbidden is returned by the playback origin when the
+	// viewer of a token may no longer play the video
+	ErrPlaybackForbidden = errors.New("unauthorized access to video")
+	// ErrVideoNotFound and ErrJobNotFound are returned for videos and jobs
+	// that do not exist or belong to another user
+	ErrVideoNotFound = errors.New("video not found")
+	ErrJobNotFound   = errors.New("job not found")
+	// ErrVideoForbidden is returned when the user may not access a video
+	ErrVideoForbidden = errors.New("unauthorized access to video")
+	// ErrInvalidInput wraps the reasons a job request is refused
+	ErrInvalidInput = errors.New("invalid input")
+)
+
+// OriginObject is an output object requested from the playback origin. Either
+// RedirectURL is set, or Object is and must be closed.
+type OriginObject struct {
+	RedirectURL string
+	Object      *s3.GetObjectOutput
+}
+
 type UseCase interface {
 	GetPresignUrl(ctx context.Context, input *models.UploadInput) (string, error)
 	CreateVideo(ctx context.Context, input *models.VideoUploadInput) (*models.VideoFile, error)
 	//UploadVideo(ctx context.Context, input *models.VideoUploadInput) (*models.VideoFile, error)
 	CreateJob(ctx context.Context, input *models.VideoUploadInput) (*models.EncodeJob, error)
+	GetJob(ctx context.Context, jobID string) (*models.EncodeJob, error)
+	EstimateOutputSize(ctx context.Context, input *models.VideoUploadInput) (*models.OutputEstimate, error)
+	EstimateCost(ctx context.Context, input *models.CostEstimateInput) (*models.CostEstimate, error)
 	GetVideo(ctx context.Context, videoID uuid.UUID) (*models.VideoFile, error)
-	ListVideos(ctx context.Context, pagination *utils.Pagination) (*models.VideoList, error)
-	SearchVideos(ctx context.Context, query string, pagination *utils.Pagination) (*models.VideoList, error)
-	DeleteVideo(ctx context.Context, videoID uuid.UUID) error
+	GetVideoStatus(ctx context.Context, videoID uuid.UUID) (*models.VideoStatus, error)
+	ListVideos(ctx context.Context, folderID *uuid.UUID, pagination *utils.Pagination) (*models.VideoList, error)
+	SearchVideos(ctx context.Context, query string, highlight bool, pagination *utils.Pagination) (*models.VideoList, error)
+	DeleteVideo(ctx context.Context, videoID uuid.UUID) (*models.VideoFile, error)
+	RestoreVideo(ctx context.Context, videoID uuid.UUID) (*models.VideoFile, error)
+	ListTrash(ctx context.Context, pagination *utils.Pagination) (*models.VideoList, error)
+	PurgeVideo(ctx context.Context, videoID uuid.UUID, opts models.VideoDeletionOptions) (*models.VideoDeletionReport, error)
 
 	UpdateVideo(ctx context.Context, video *models.VideoFile) error
 
-	GetPlaybackInfo(ctx context.Context, videoID uuid.UUID) (*models.PlaybackInfo, error)
+	GetPlaybackInfo(ctx context.Context, videoID uuid.UUID, shareToken string) (*models.PlaybackInfo, error)
+	GetPlayerConfig(ctx context.Context, videoID uuid.UUID, shareToken string) (*models.PlayerConfig, error)
+	UpdateVisibility(ctx context.Context, videoID uuid.UUID, input *models.VisibilityInput) (*models.VideoFile, error)
+	RotateShareToken(ctx context.Context, videoID uuid.UUID) (*models.VideoFile, error)
+	SetPoster(ctx context.Context, videoID uuid.UUID, input *models.PosterInput) (*models.PlaybackInfo, error)
+	GetStreamObject(ctx context.Context, videoID uuid.UUID, objectPath, shareToken string) (*s3.GetObjectOutput, error)
+	GetOriginObject(ctx context.Context, videoID uuid.UUID, objectPath string) (*OriginObject, error)
+	MoveVideo(ctx context.Context, videoID uuid.UUID, input *models.MoveVideoInput) error
+	ListStalledVideos(ctx context.Context) ([]*models.VideoFile, error)
+	ListJobEnvironments(ctx context.Context, videoID uuid.UUID) ([]*models.JobEnvironment, error)
+	GetSourceReport(ctx context.Context, videoID uuid.UUID) (*models.SourceReport, error)
+	FindDuplicates(ctx context.Context, videoID uuid.UUID, minSimilarity float64) (*models.DuplicateReport, error)
+	ValidateSource(ctx context.Context, input *models.ValidateSourceInput) (*models.SourceValidation, error)
+	StartSmokeTest(ctx context.Context, input *models.SmokeTestInput) (*models.SmokeTest, error)
+	GetSmokeTest(ctx context.Context, videoID uuid.UUID) (*models.SmokeTest, error)
+
+	ListQueueDepths(ctx context.Context) ([]*models.QueueDepth, error)
+	PeekQueue(ctx context.Context, name string, pagination *utils.Pagination) (*models.QueuePage, error)
+	GetJobHash(ctx context.Context, jobID string) (map[string]string, error)
+	PurgeQueuedJob(ctx context.Context, name string, input *models.PurgeJobInput) error
+	ListWorkers(ctx context.Context) ([]*models.WorkerStatus, error)
+	ListRunningJobs(ctx context.Context) ([]models.RunningJob, error)
+	ListFailedJobs(ctx context.Context, limit int) ([]*models.EncodeJob, error)
+	GetThroughputStats(ctx context.Context, query *models.ThroughputQuery) (*models.ThroughputStats, error)
+	RequeueJob(ctx context.Context, jobID string) (*models.EncodeJob, error)
+	CancelJob(ctx context.Context, jobID string) error
+	RepackageVideo(ctx context.Context, videoID uuid.UUID, input *models.RepackageInput) (*models.EncodeJob, error)
+	ReencodeVideo(ctx context.Context, videoID uuid.UUID, input *models.ReencodeInput) (*models.EncodeJob, error)
+	PlanEncode(ctx context.Context, videoID uuid.UUID, input *models.EncodePlanInput) (*models.EncodePlan, error)
+	GetEncodePlan(ctx context.Context, videoID uuid.UUID, jobID string) (*models.EncodePlan, error)
+	ListPlaybackVersions(ctx context.Context, videoID uuid.UUID) ([]*models.PlaybackVersion, error)
+	RollbackPlaybackVersion(ctx context.Context, videoID uuid.UUID, version int) ([]*models.PlaybackVersion, error)
+	RepackageLibrary(ctx context.Context, input *models.RepackageInput) (*models.RepackageSummary, error)
+	IngestS3Event(ctx context.Context, token string, event *models.S3Event) (*models.IngestSummary, error)
+	ConfirmIngestSubscription(ctx context.Context, token string, message *models.SNSMessage) error
+
+	CreateChunkedUpload(ctx context.Context, input *models.ChunkedUploadInput) (*models.ChunkedUpload, error)
+	UploadChunk(ctx context.Context, uploadID string, index int, checksum string, chunk io.Reader) (*models.ChunkUploadStatus, error)
+	GetChunkedUploadStatus(ctx context.Context, uploadID string) (*models.ChunkUploadStatus, error)
+	CompleteChunkedUpload(ctx context.Context, uploadID string) (*models.VideoFile, error)
+	StreamUpload(ctx context.Context, uploadID string, offset int64, body io.Reader) (*models.UploadProgress, error)
+	GetUploadProgress(ctx context.Context, uploadID string) (*models.UploadProgress, error)
+
+	CreateFolder(ctx context.Context, input *models.FolderInput) (*models.Folder, error)
+	ListFolders(ctx context.Context, parentID *uuid.UUID) ([]*models.Folder, error)
+	RenameFolder(ctx context.Context, folderID uuid.UUID, input *models.RenameFolderInput) (*models.Folder, error)
+	MoveFolder(ctx context.Context, folderID uuid.UUID, input *models.MoveFolderInput) (*models.Folder, error)
+	DeleteFolder(ctx context.Context, folderID uuid.UUID) error
+}
+
+// ChunkError is returned when a chunked upload cannot proceed because of a
+// missing or corrupt chunk. Retry tells the client whether sending the chunk
+// again can fix it.
+type ChunkError struct {
+	Message       string `json:"error"`
+	Chunk         int    `json:"chunk"`
+	Retry         bool   `json:"retry"`
+	MissingChunks []int  `json:"missing_chunks,omitempty"`
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("chunk %d: %s", e.Chunk, e.Message)
+}
+
+// UploadOffsetError is returned when a stream does not start where the
+// upload resumes. Offset is where the client should resume from.
+type UploadOffsetError struct {
+	Message string `json:"error"`
+	Offset  int64  `json:"offset"`
+}
+
+func (e *UploadOffsetError) Error() string {
+	return fmt.Sprintf("%s, resume at offset %d", e.Message, e.Offset)
 }
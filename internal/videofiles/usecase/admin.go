@@ -0,0 +1,196 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+	"github.com/google/uuid"
+)
+
+const DefaultFailedJobsLimit = 50
+
+// ListWorkers returns every live worker with the jobs it is running, as
+// reported by their last heartbeat.
+func (v *videoFileUC) ListWorkers(ctx context.Context) ([]*models.WorkerStatus, error) {
+	workers, err := v.redisRepo.ListWorkerStatuses(ctx)
+	if err != nil {
+		v.logger.Errorf("ListWorkers - failed to list worker statuses: %v", err)
+		return nil, fmt.Errorf("failed to list workers: %v", err)
+	}
+	sort.Slice(workers, func(i, j int) bool {
+		return workers[i].WorkerID < workers[j].WorkerID
+	})
+	return workers, nil
+}
+
+// ListRunningJobs returns the jobs in progress across all workers, oldest
+// first.
+func (v *videoFileUC) ListRunningJobs(ctx context.Context) ([]models.RunningJob, error) {
+	workers, err := v.ListWorkers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := []models.RunningJob{}
+	for _, worker := range workers {
+		jobs = append(jobs, worker.Jobs...)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].StartedAt.Before(jobs[j].StartedAt)
+	})
+	return jobs, nil
+}
+
+// ListFailedJobs returns the most recent failures, newest first. Jobs whose
+// hash has expired are left out.
+func (v *videoFileUC) ListFailedJobs(ctx context.Context, limit int) ([]*models.EncodeJob, error) {
+	if limit <= 0 {
+		limit = DefaultFailedJobsLimit
+	}
+	jobIDs, err := v.redisRepo.ListFailedJobs(ctx, int64(min(limit, videofiles.FailedJobsKept)))
+	if err != nil {
+		v.logger.Errorf("ListFailedJobs - failed to list failed jobs: %v", err)
+		return nil, fmt.Errorf("failed to list failed jobs: %v", err)
+	}
+
+	jobs := make([]*models.EncodeJob, 0, len(jobIDs))
+	for _, jobID := range jobIDs {
+		job, err := v.redisRepo.GetJobDetails(ctx, jobID)
+		if err != nil {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// RequeueJob enqueues a failed or cancelled job again exactly as it was
+// submitted. Jobs that are running or completed cannot be requeued.
+func (v *videoFileUC) RequeueJob(ctx context.Context, jobID string) (*models.EncodeJob, error) {
+	fields, err := v.redisRepo.GetJobHash(ctx, jobID)
+	if err != nil {
+		v.logger.Warnf("RequeueJob - failed to fetch job %s: %v", jobID, err)
+		return nil, videofiles.ErrJobNotFound
+	}
+	if models.JobStatus(fields["status"]) == models.JobStatusCompleted {
+		return nil, fmt.Errorf("job has already completed")
+	}
+	if workerID, err := v.jobWorker(ctx, jobID); err != nil {
+		return nil, err
+	} else if workerID != "" {
+		return nil, fmt.Errorf("job is running on worker %s, cancel it first", workerID)
+	}
+	if fields["payload"] == "" {
+		return nil, fmt.Errorf("job was queued without its payload and cannot be requeued")
+	}
+
+	job := &models.EncodeJob{}
+	if err = json.Unmarshal([]byte(fields["payload"]), job); err != nil {
+		v.logger.Errorf("RequeueJob - failed to decode payload of job %s: %v", jobID, err)
+		return nil, fmt.Errorf("invalid job payload: %v", err)
+	}
+	job.Status = models.JobStatusQueued
+	job.Progress = 0
+
+	if videoID, err := uuid.Parse(job.VideoID); err == nil {
+		if err = v.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusQueued, 0); err != nil {
+			v.logger.Warnf("RequeueJob - failed to reset video %s: %v", videoID, err)
+		}
+	}
+	v.recordJobEvent(ctx, job, models.JobEventQueued)
+	if err = v.redisRepo.EnqueueJob(ctx, v.jobQueue(ctx, job.Codec), job); err != nil {
+		v.logger.Errorf("RequeueJob - EnqueueJob error: %v", err)
+		return nil, fmt.Errorf("failed to queue the job: %v", err)
+	}
+	v.logger.Infof("Requeued job %s", jobID)
+	return job, nil
+}
+
+// CancelJob removes a job from the queues and stops it if a worker is
+// running it. The job is marked failed with the cancelled reason.
+func (v *videoFileUC) CancelJob(ctx context.Context, jobID string) error {
+	job, err := v.redisRepo.GetJobDetails(ctx, jobID)
+	if err != nil {
+		v.logger.Warnf("CancelJob - failed to fetch job %s: %v", jobID, err)
+		return videofiles.ErrJobNotFound
+	}
+	if job.Status == models.JobStatusCompleted || job.Status == models.JobStatusFailed || job.Status == models.JobStatusRejected {
+		return fmt.Errorf("job has already finished")
+	}
+
+	for _, name := range []string{CPUQueueName, GPUQueueName} {
+		if err = v.removeQueuedJob(ctx, name, jobID); err != nil {
+			return err
+		}
+	}
+
+	if err = v.redisRepo.UpdateStatus(ctx, jobID, v.cfg.Redis.JobQueueKey, models.JobStatusFailed); err != nil {
+		v.logger.Errorf("CancelJob - failed to update job status: %v", err)
+		return fmt.Errorf("failed to cancel job: %v", err)
+	}
+	if err = v.redisRepo.UpdateFailureReason(ctx, jobID, models.FailureCancelled, "job cancelled by an administrator"); err != nil {
+		v.logger.Errorf("CancelJob - failed to record failure reason: %v", err)
+	}
+	if videoID, err := uuid.Parse(job.VideoID); err == nil {
+		if err = v.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusFailed, 0); err != nil {
+			v.logger.Warnf("CancelJob - failed to update video %s: %v", videoID, err)
+		}
+	}
+
+	// Workers check the failure reason before starting a job, so this only
+	// matters for a job that is already running
+	if err = v.redisRepo.PublishJobCancel(ctx, jobID); err != nil {
+		v.logger.Errorf("CancelJob - failed to notify workers: %v", err)
+		return fmt.Errorf("failed to cancel job: %v", err)
+	}
+	v.logger.Infof("Cancelled job %s", jobID)
+	return nil
+}
+
+// removeQueuedJob removes every payload of a job from a queue.
+func (v *videoFileUC) removeQueuedJob(ctx context.Context, name, jobID string) error {
+	key, err := v.queueKey(name)
+	if err != nil {
+		return err
+	}
+	payloads, err := v.redisRepo.PeekQueue(ctx, key, 0, -1)
+	if errors.Is(err, videofiles.ErrQueueNotBrowsable) {
+		// The worker that dequeues the job skips it as cancelled
+		return nil
+	}
+	if err != nil {
+		v.logger.Errorf("CancelJob - failed to read %s: %v", key, err)
+		return fmt.Errorf("failed to read queue: %v", err)
+	}
+
+	for _, payload := range payloads {
+		queued := &models.EncodeJob{}
+		if json.Unmarshal([]byte(payload), queued) != nil || queued.JobID != jobID {
+			continue
+		}
+		if _, err = v.redisRepo.RemoveQueuedPayload(ctx, key, payload); err != nil {
+			v.logger.Errorf("CancelJob - failed to remove job from %s: %v", key, err)
+			return fmt.Errorf("failed to remove job from queue: %v", err)
+		}
+	}
+	return nil
+}
+
+// jobWorker returns the worker running a job, or an empty string.
+func (v *videoFileUC) jobWorker(ctx context.Context, jobID string) (string, error) {
+	jobs, err := v.ListRunningJobs(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, job := range jobs {
+		if job.JobID == jobID {
+			return job.WorkerID, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,18 @@
+package usecase
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// validateBumpers checks that the intro and outro clips of a job are uploads
+// of the user, so a job cannot splice in another user's source.
+func validateBumpers(userID uuid.UUID, keys ...string) error {
+	for _, key := range keys {
+		if key != "" && !ownsUpload(userID, key) {
+			return fmt.Errorf("bumper %q is not one of your uploads", key)
+		}
+	}
+	return nil
+}
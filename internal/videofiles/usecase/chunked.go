@@ -0,0 +1,288 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+)
+
+const ChunkedUploadTTL = 24 * time.Hour
+
+// CreateChunkedUpload starts an upload that is sent through the API in
+// chunks, each verified against its checksum as it arrives.
+func (v *videoFileUC) CreateChunkedUpload(ctx context.Context, input *models.ChunkedUploadInput) (*models.ChunkedUpload, error) {
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		v.logger.Errorf("CreateChunkedUpload - failed to get user from context: %v", err)
+		return nil, fmt.Errorf("unauthorized: %v", err)
+	}
+	if err = utils.ValidateStruct(ctx, input); err != nil {
+		v.logger.Errorf("CreateChunkedUpload - ValidateStruct error: %v", err)
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+	if input.SourceURL != "" {
+		return nil, fmt.Errorf("source urls are only accepted when creating a job")
+	}
+
+	upload := &models.ChunkedUpload{
+		UploadID:    uuid.New().String(),
+		UserID:      user.UserID.String(),
+		Video:       input.VideoUploadInput,
+		ChunkSize:   input.ChunkSize,
+		TotalChunks: int((input.FileSize + input.ChunkSize - 1) / input.ChunkSize),
+		Checksum:    strings.ToLower(input.Checksum),
+		CreatedAt:   time.Now(),
+	}
+	if err = v.redisRepo.SaveChunkedUpload(ctx, upload, ChunkedUploadTTL); err != nil {
+		v.logger.Errorf("CreateChunkedUpload - failed to save upload: %v", err)
+		return nil, fmt.Errorf("failed to create upload: %v", err)
+	}
+	return upload, nil
+}
+
+// UploadChunk stores a chunk after checking its size and SHA-256 checksum.
+// A corrupt chunk is rejected with a ChunkError asking the client to send it
+// again.
+func (v *videoFileUC) UploadChunk(ctx context.Context, uploadID string, index int, checksum string, chunk io.Reader) (*models.ChunkUploadStatus, error) {
+	upload, err := v.getChunkedUpload(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= upload.TotalChunks {
+		return nil, fmt.Errorf("chunk index must be between 0 and %d", upload.TotalChunks-1)
+	}
+	if checksum == "" {
+		return nil, fmt.Errorf("chunk checksum is required")
+	}
+
+	expectedSize := upload.ChunkSize
+	if index == upload.TotalChunks-1 {
+		expectedSize = upload.Video.FileSize - int64(index)*upload.ChunkSize
+	}
+
+	// Read one byte more than expected to detect oversized chunks
+	data, err := io.ReadAll(io.LimitReader(chunk, expectedSize+1))
+	if err != nil {
+		return nil, &videofiles.ChunkError{Message: fmt.Sprintf("failed to read chunk: %v", err), Chunk: index, Retry: true}
+	}
+	if int64(len(data)) != expectedSize {
+		return nil, &videofiles.ChunkError{
+			Message: fmt.Sprintf("expected %d bytes, got %d", expectedSize, len(data)),
+			Chunk:   index,
+			Retry:   true,
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	actual := hex.EncodeToString(sum[:])
+	if !strings.EqualFold(actual, checksum) {
+		v.logger.Warnf("UploadChunk - checksum mismatch for chunk %d of upload %s", index, uploadID)
+		return nil, &videofiles.ChunkError{Message: "checksum mismatch", Chunk: index, Retry: true}
+	}
+
+	if err = v.storeChunk(ctx, upload, index, data, actual); err != nil {
+		return nil, err
+	}
+	return chunkUploadStatus(upload), nil
+}
+
+// storeChunk stores a received chunk and records its checksum on the upload
+func (v *videoFileUC) storeChunk(ctx context.Context, upload *models.ChunkedUpload, index int, data []byte, checksum string) error {
+	_, err := v.awsRepo.PutObject(ctx, models.UploadInput{
+		File:       bytes.NewReader(data),
+		Name:       chunkObjectKey(upload.UploadID, index),
+		MimeType:   "application/octet-stream",
+		Size:       int64(len(data)),
+		Key:        chunkObjectKey(upload.UploadID, index),
+		BucketName: v.cfg.S3.InputBucket,
+	})
+	if err != nil {
+		v.logger.Errorf("UploadChunk - failed to store chunk: %v", err)
+		return &videofiles.ChunkError{Message: "failed to store chunk", Chunk: index, Retry: true}
+	}
+
+	if err = v.redisRepo.SetChunkChecksum(ctx, upload.UploadID, index, checksum); err != nil {
+		v.logger.Errorf("UploadChunk - failed to record chunk: %v", err)
+		return &videofiles.ChunkError{Message: "failed to record chunk", Chunk: index, Retry: true}
+	}
+	upload.ChunkChecksums[index] = checksum
+	return nil
+}
+
+// GetChunkedUploadStatus lists the chunks received so far so an interrupted
+// upload can be resumed.
+func (v *videoFileUC) GetChunkedUploadStatus(ctx context.Context, uploadID string) (*models.ChunkUploadStatus, error) {
+	upload, err := v.getChunkedUpload(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	return chunkUploadStatus(upload), nil
+}
+
+// CompleteChunkedUpload assembles the chunks, verifies every chunk again and
+// the checksum of the whole file, and only then stores the source and creates
+// the video record.
+func (v *videoFileUC) CompleteChunkedUpload(ctx context.Context, uploadID string) (*models.VideoFile, error) {
+	upload, err := v.getChunkedUpload(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := chunkUploadStatus(upload)
+	if len(status.MissingChunks) > 0 {
+		return nil, &videofiles.ChunkError{
+			Message:       fmt.Sprintf("%d chunks have not been received", len(status.MissingChunks)),
+			Chunk:         status.MissingChunks[0],
+			Retry:         true,
+			MissingChunks: status.MissingChunks,
+		}
+	}
+
+	assembled, err := os.CreateTemp("", "chunked-upload-")
+	if err != nil {
+		v.logger.Errorf("CompleteChunkedUpload - failed to create temp file: %v", err)
+		return nil, fmt.Errorf("failed to assemble upload: %v", err)
+	}
+	defer os.Remove(assembled.Name())
+	defer assembled.Close()
+
+	fileHash := sha256.New()
+	for index := 0; index < upload.TotalChunks; index++ {
+		if err = v.appendChunk(ctx, upload, index, io.MultiWriter(assembled, fileHash)); err != nil {
+			return nil, err
+		}
+	}
+
+	if actual := hex.EncodeToString(fileHash.Sum(nil)); actual != upload.Checksum {
+		v.logger.Warnf("CompleteChunkedUpload - checksum mismatch for upload %s: expected %s, got %s", uploadID, upload.Checksum, actual)
+		return nil, &videofiles.ChunkError{Message: "assembled file does not match its checksum", Chunk: -1, Retry: false}
+	}
+
+	if _, err = assembled.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to assemble upload: %v", err)
+	}
+	_, err = v.awsRepo.PutObject(ctx, models.UploadInput{
+		File:       assembled,
+		Name:       upload.Video.FileName,
+		MimeType:   "video/" + upload.Video.Format,
+		Size:       upload.Video.FileSize,
+		Key:        fmt.Sprintf("uploads/%s/%s", upload.UserID, upload.Video.FileName),
+		BucketName: v.cfg.S3.InputBucket,
+	})
+	if err != nil {
+		v.logger.Errorf("CompleteChunkedUpload - failed to store source: %v", err)
+		return nil, fmt.Errorf("failed to store upload: %v", err)
+	}
+
+	video, err := v.CreateVideo(ctx, &upload.Video)
+	if err != nil {
+		return nil, err
+	}
+
+	v.removeChunks(ctx, upload)
+	return video, nil
+}
+
+// appendChunk copies a stored chunk to w. A chunk whose content no longer
+// matches the checksum recorded on upload is forgotten so the client sends it
+// again.
+func (v *videoFileUC) appendChunk(ctx context.Context, upload *models.ChunkedUpload, index int, w io.Writer) error {
+	object, err := v.awsRepo.GetObject(ctx, v.cfg.S3.InputBucket, chunkObjectKey(upload.UploadID, index))
+	if err != nil {
+		v.logger.Errorf("CompleteChunkedUpload - failed to fetch chunk %d: %v", index, err)
+		return &videofiles.ChunkError{Message: "failed to fetch chunk", Chunk: index, Retry: true}
+	}
+	defer object.Body.Close()
+
+	chunkHash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(w, chunkHash), object.Body); err != nil {
+		v.logger.Errorf("CompleteChunkedUpload - failed to read chunk %d: %v", index, err)
+		return fmt.Errorf("failed to assemble upload: %v", err)
+	}
+
+	if hex.EncodeToString(chunkHash.Sum(nil)) != upload.ChunkChecksums[index] {
+		v.logger.Warnf("CompleteChunkedUpload - stored chunk %d of upload %s is corrupt", index, upload.UploadID)
+		if err = v.redisRepo.DeleteChunkChecksum(ctx, upload.UploadID, index); err != nil {
+			v.logger.Warnf("CompleteChunkedUpload - failed to reset chunk %d: %v", index, err)
+		}
+		return &videofiles.ChunkError{Message: "stored chunk is corrupt", Chunk: index, Retry: true, MissingChunks: []int{index}}
+	}
+	return nil
+}
+
+func (v *videoFileUC) removeChunks(ctx context.Context, upload *models.ChunkedUpload) {
+	for index := 0; index < upload.TotalChunks; index++ {
+		if err := v.awsRepo.RemoveObject(ctx, v.cfg.S3.InputBucket, chunkObjectKey(upload.UploadID, index)); err != nil {
+			v.logger.Warnf("Failed to remove chunk %d of upload %s: %v", index, upload.UploadID, err)
+		}
+	}
+	if err := v.redisRepo.DeleteChunkedUpload(ctx, upload.UploadID); err != nil {
+		v.logger.Warnf("Failed to delete chunked upload %s: %v", upload.UploadID, err)
+	}
+}
+
+// getChunkedUpload loads an upload session of the current user.
+func (v *videoFileUC) getChunkedUpload(ctx context.Context, uploadID string) (*models.ChunkedUpload, error) {
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		v.logger.Errorf("getChunkedUpload - failed to get user from context: %v", err)
+		return nil, fmt.Errorf("unauthorized: %v", err)
+	}
+
+	upload, err := v.redisRepo.GetChunkedUpload(ctx, uploadID)
+	if err != nil {
+		v.logger.Warnf("getChunkedUpload - failed to fetch upload %s: %v", uploadID, err)
+		return nil, fmt.Errorf("upload not found")
+	}
+	if upload.UserID != user.UserID.String() {
+		v.logger.Warnf("User %s is not authorized to access upload %s", user.UserID, uploadID)
+		return nil, fmt.Errorf("upload not found")
+	}
+	return upload, nil
+}
+
+func chunkUploadStatus(upload *models.ChunkedUpload) *models.ChunkUploadStatus {
+	status := &models.ChunkUploadStatus{
+		UploadID:       upload.UploadID,
+		TotalChunks:    upload.TotalChunks,
+		ReceivedChunks: []int{},
+		MissingChunks:  []int{},
+		Offset:         resumeOffset(upload),
+	}
+	for index := 0; index < upload.TotalChunks; index++ {
+		if _, ok := upload.ChunkChecksums[index]; ok {
+			status.ReceivedChunks = append(status.ReceivedChunks, index)
+		} else {
+			status.MissingChunks = append(status.MissingChunks, index)
+		}
+	}
+	return status
+}
+
+// resumeOffset is the end of the chunks received from the start of the file,
+// where a streamed upload resumes
+func resumeOffset(upload *models.ChunkedUpload) int64 {
+	index := 0
+	for index < upload.TotalChunks {
+		if _, ok := upload.ChunkChecksums[index]; !ok {
+			break
+		}
+		index++
+	}
+	return min(int64(index)*upload.ChunkSize, upload.Video.FileSize)
+}
+
+func chunkObjectKey(uploadID string, index int) string {
+	return fmt.Sprintf("chunks/%s/%05d", uploadID, index)
+}
@@ -0,0 +1,76 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+)
+
+// defaultEncodeSpeeds are the seconds of 1080p source encoded per second of
+// compute when Pricing.Speeds has none for a codec and hardware class. They
+// are conservative figures for a single job on a mid-sized worker.
+var defaultEncodeSpeeds = map[models.Codec]map[models.HardwareClass]float64{
+	models.CodecH264: {models.HardwareCPU: 2, models.HardwareGPU: 8},
+	models.CodecAV1:  {models.HardwareCPU: 0.3, models.HardwareGPU: 3},
+	models.CodecVP9:  {models.HardwareCPU: 0.6, models.HardwareGPU: 4},
+}
+
+// costLadder is the default ladder, highest first, with the pixel count of
+// each rendition relative to 1080p. Encode time scales roughly with pixels.
+var costLadder = []struct {
+	quality models.VideoQuality
+	weight  float64
+}{
+	{models.Quality1080P, 1},
+	{models.Quality720P, 1280 * 720 / (1920 * 1080.0)},
+	{models.Quality480P, 854 * 480 / (1920 * 1080.0)},
+	{models.Quality360P, 640 * 360 / (1920 * 1080.0)},
+}
+
+// EstimateCost works out how long encoding a source would take and what it
+// would cost at the configured rates, without creating anything.
+func (v *videoFileUC) EstimateCost(ctx context.Context, input *models.CostEstimateInput) (*models.CostEstimate, error) {
+	if err := utils.ValidateStruct(ctx, input); err != nil {
+		v.logger.Errorf("EstimateCost - ValidateStruct error: %v", err)
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+	if input.Codec == "" {
+		input.Codec = models.CodecH264
+	}
+	if input.Profile == "" {
+		input.Profile = models.ProfileLadder
+	}
+	if input.HardwareClass == "" {
+		input.HardwareClass = models.HardwareCPU
+	}
+
+	estimate := &models.CostEstimate{
+		Codec:         input.Codec,
+		Profile:       input.Profile,
+		HardwareClass: input.HardwareClass,
+		Currency:      v.cfg.Pricing.Currency,
+	}
+	var weight float64
+	for _, rendition := range costLadder {
+		if rendition.quality == input.Resolution || (input.Profile == models.ProfileLadder && len(estimate.Renditions) > 0) {
+			estimate.Renditions = append(estimate.Renditions, rendition.quality)
+			weight += rendition.weight
+		}
+	}
+
+	speed := v.cfg.Pricing.Speeds[string(input.Codec)][string(input.HardwareClass)]
+	if speed <= 0 {
+		speed = defaultEncodeSpeeds[input.Codec][input.HardwareClass]
+	}
+	estimate.EncodeSeconds = math.Round(input.Duration*weight/speed*10) / 10
+
+	estimate.RatePerMinute = v.cfg.Pricing.Rates[string(input.Codec)][string(input.HardwareClass)]
+	if estimate.RatePerMinute <= 0 {
+		estimate.Warning = fmt.Sprintf("no rate is configured for %s on %s", input.Codec, input.HardwareClass)
+	}
+	estimate.Cost = math.Round(estimate.EncodeSeconds/60*estimate.RatePerMinute*10000) / 10000
+	return estimate, nil
+}
@@ -0,0 +1,174 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxDeletionAttempts is how often removing the objects of a deleted
+	// video is tried before it is given up and logged
+	MaxDeletionAttempts = 5
+	// deletionPollTimeout is how long the deletion job waits for work at once
+	deletionPollTimeout = 5 * time.Second
+)
+
+// PurgeVideo deletes a video of the user for good, whether or not it is in
+// the trash, with its playback info, analytics and job rows, its source
+// object and every output object. A dry run only reports what would go.
+// Async deletes the objects in the background; a synchronous delete falls
+// back to that when storage fails after the rows are gone.
+func (v *videoFileUC) PurgeVideo(ctx context.Context, videoID uuid.UUID, opts models.VideoDeletionOptions) (*models.VideoDeletionReport, error) {
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		v.logger.Errorf("PurgeVideo - failed to get user from context: %v", err)
+		return nil, err
+	}
+	if videoID == uuid.Nil {
+		return nil, fmt.Errorf("invalid video id: cannot be empty")
+	}
+	video, err := v.GetVideo(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+
+	deletion := newVideoDeletion(video, v.cfg.S3.OutputBucket)
+	report := &models.VideoDeletionReport{
+		VideoID:      video.VideoID,
+		DryRun:       opts.DryRun,
+		SourceObject: fmt.Sprintf("%s/%s", deletion.SourceBucket, deletion.SourceKey),
+		OutputPrefix: fmt.Sprintf("%s/%s/", deletion.OutputBucket, deletion.OutputPrefix),
+	}
+
+	if opts.DryRun {
+		if report.Rows, err = v.videoRepo.CountVideoRows(ctx, videoID); err != nil {
+			v.logger.Errorf("PurgeVideo - CountVideoRows error: %v", err)
+			return nil, fmt.Errorf("failed to count video rows: %w", err)
+		}
+		if report.Objects, err = v.awsRepo.ListObjectsWithPrefix(ctx, deletion.OutputBucket, deletion.OutputPrefix+"/"); err != nil {
+			v.logger.Errorf("PurgeVideo - ListObjectsWithPrefix error: %v", err)
+			return nil, fmt.Errorf("failed to list video objects: %w", err)
+		}
+		report.ObjectCount = len(report.Objects)
+		return report, nil
+	}
+
+	if report.Rows, err = v.videoRepo.DeleteVideo(ctx, user.UserID, videoID); err != nil {
+		v.logger.Errorf("PurgeVideo - failed to delete video: %v", err)
+		return nil, fmt.Errorf("failed to delete video: %v", err)
+	}
+
+	if !opts.Async {
+		report.Objects, err = deleteVideoObjects(ctx, v.awsRepo, deletion)
+		report.ObjectCount = len(report.Objects)
+		if err == nil {
+			return report, nil
+		}
+		v.logger.Warnf("PurgeVideo - deleting objects of video %s failed, queueing: %v", videoID, err)
+		deletion.Attempts++
+	}
+
+	if err := v.redisRepo.EnqueueVideoDeletion(ctx, deletion); err != nil {
+		v.logger.Errorf("PurgeVideo - EnqueueVideoDeletion error: %v", err)
+		return nil, fmt.Errorf("video deleted but its files could not be queued for deletion: %w", err)
+	}
+	report.Queued = true
+	return report, nil
+}
+
+// newVideoDeletion describes the stored objects of a video about to be
+// deleted
+func newVideoDeletion(video *models.VideoFile, outputBucket string) *models.VideoDeletion {
+	return &models.VideoDeletion{
+		VideoID:      video.VideoID,
+		SourceBucket: video.S3Bucket,
+		SourceKey:    video.S3Key,
+		OutputBucket: outputBucket,
+		OutputPrefix: outputPrefix(video),
+		RequestedAt:  time.Now(),
+	}
+}
+
+// deleteVideoObjects removes the output objects and the source of a deleted
+// video and returns the output keys it removed. Keys already gone are not an
+// error, so a deletion can be retried from the start.
+func deleteVideoObjects(ctx context.Context, awsRepo videofiles.AWSRepository, deletion *models.VideoDeletion) ([]string, error) {
+	var removed []string
+	if deletion.OutputPrefix != "" {
+		keys, err := awsRepo.ListObjectsWithPrefix(ctx, deletion.OutputBucket, deletion.OutputPrefix+"/")
+		if err != nil {
+			return removed, err
+		}
+		for _, key := range keys {
+			if err := awsRepo.RemoveObject(ctx, deletion.OutputBucket, key); err != nil {
+				return removed, err
+			}
+			removed = append(removed, key)
+		}
+	}
+	if deletion.SourceKey != "" {
+		if err := awsRepo.RemoveObject(ctx, deletion.SourceBucket, deletion.SourceKey); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// DeletionJob removes the objects of deleted videos queued by PurgeVideo and
+// the trash purge.
+// Every API server runs one; each deletion is taken by one of them.
+type DeletionJob struct {
+	redisRepo videofiles.RedisRepository
+	awsRepo   videofiles.AWSRepository
+	logger    logger.Logger
+}
+
+// NewDeletionJob creates a job working through the video deletion queue
+func NewDeletionJob(redisRepo videofiles.RedisRepository, awsRepo videofiles.AWSRepository, log logger.Logger) *DeletionJob {
+	return &DeletionJob{
+		redisRepo: redisRepo,
+		awsRepo:   awsRepo,
+		logger:    log,
+	}
+}
+
+// Run deletes queued videos until ctx is done
+func (j *DeletionJob) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		deletion, err := j.redisRepo.DequeueVideoDeletion(ctx, deletionPollTimeout)
+		if err != nil {
+			j.logger.Warnf("DeletionJob - DequeueVideoDeletion error: %v", err)
+			time.Sleep(deletionPollTimeout)
+			continue
+		}
+		if deletion != nil {
+			j.delete(ctx, deletion)
+		}
+	}
+}
+
+func (j *DeletionJob) delete(ctx context.Context, deletion *models.VideoDeletion) {
+	removed, err := deleteVideoObjects(ctx, j.awsRepo, deletion)
+	if err == nil {
+		j.logger.Infof("Deleted %d objects of video %s", len(removed), deletion.VideoID)
+		return
+	}
+
+	deletion.Attempts++
+	if deletion.Attempts >= MaxDeletionAttempts {
+		j.logger.Errorf("DeletionJob - giving up on objects of video %s under %s/%s after %d attempts: %v",
+			deletion.VideoID, deletion.OutputBucket, deletion.OutputPrefix, deletion.Attempts, err)
+		return
+	}
+	j.logger.Warnf("DeletionJob - deleting objects of video %s failed, retrying: %v", deletion.VideoID, err)
+	if err := j.redisRepo.EnqueueVideoDeletion(context.WithoutCancel(ctx), deletion); err != nil {
+		j.logger.Errorf("DeletionJob - EnqueueVideoDeletion error: %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package usecase
+
+import (
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/drm"
+)
+
+// DefaultLicenseURLTTL is how long signed license URLs are valid when no TTL
+// is configured.
+const DefaultLicenseURLTTL = time.Hour
+
+// drmInfo returns the license acquisition details of a DRM packaged video,
+// with license URLs signed for the current viewer. It returns nil for videos
+// packaged without DRM.
+func (v *videoFileUC) drmInfo(video *models.VideoFile) *models.DRMInfo {
+	if video.DRMKeyID == nil {
+		return nil
+	}
+
+	ttl := DefaultLicenseURLTTL
+	if v.cfg.DRM.LicenseURLTTL > 0 {
+		ttl = time.Duration(v.cfg.DRM.LicenseURLTTL) * time.Second
+	}
+
+	info := &models.DRMInfo{
+		KeyID:                  *video.DRMKeyID,
+		FairPlayCertificateURL: v.cfg.DRM.FairPlayCertificateURL,
+		ExpiresAt:              time.Now().Add(ttl),
+	}
+	if video.DRMScheme != nil {
+		info.Scheme = *video.DRMScheme
+	}
+
+	sign := func(licenseURL string) string {
+		if licenseURL == "" {
+			return ""
+		}
+		signed, err := drm.SignLicenseURL(licenseURL, v.cfg.DRM.LicenseSigningKey, video.VideoID.String(), info.KeyID, info.ExpiresAt)
+		if err != nil {
+			v.logger.Errorf("drmInfo - failed to sign license url: %v", err)
+			return ""
+		}
+		return signed
+	}
+	info.WidevineLicenseURL = sign(v.cfg.DRM.WidevineLicenseURL)
+	if info.Scheme == drm.SchemeCBCS {
+		info.FairPlayLicenseURL = sign(v.cfg.DRM.FairPlayLicenseURL)
+	}
+
+	return info
+}
@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultDuplicateSimilarity is the least similarity a video is reported
+	// as a duplicate with when none is asked for
+	DefaultDuplicateSimilarity = 0.8
+	// MaxDuplicateMatches bounds the videos a duplicate search returns
+	MaxDuplicateMatches = 50
+)
+
+// FindDuplicates returns the videos of the account whose sources look like
+// the source of a video with at least minSimilarity, most similar first.
+// Videos are fingerprinted when they are processed; videos processed before
+// fingerprinting existed are not found until they are processed again.
+func (v *videoFileUC) FindDuplicates(ctx context.Context, videoID uuid.UUID, minSimilarity float64) (*models.DuplicateReport, error) {
+	if minSimilarity == 0 {
+		minSimilarity = DefaultDuplicateSimilarity
+	}
+	if minSimilarity < 0 || minSimilarity > 1 {
+		return nil, fmt.Errorf("min similarity must be between 0 and 1")
+	}
+	video, err := v.GetVideo(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint, err := v.videoRepo.GetFingerprint(ctx, videoID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("video has not been fingerprinted yet")
+		}
+		v.logger.Errorf("FindDuplicates - failed to fetch fingerprint: %v", err)
+		return nil, fmt.Errorf("failed to fetch fingerprint: %v", err)
+	}
+	candidates, err := v.videoRepo.GetUserFingerprints(ctx, video.UserID)
+	if err != nil {
+		v.logger.Errorf("FindDuplicates - failed to fetch fingerprints: %v", err)
+		return nil, fmt.Errorf("failed to fetch fingerprints: %v", err)
+	}
+
+	report := &models.DuplicateReport{VideoID: videoID, MinSimilarity: minSimilarity, Matches: []*models.DuplicateMatch{}}
+	for _, candidate := range candidates {
+		if candidate.VideoID == videoID {
+			continue
+		}
+		similarity, matched := models.CompareFingerprints(fingerprint, candidate)
+		if similarity < minSimilarity {
+			continue
+		}
+		report.Matches = append(report.Matches, &models.DuplicateMatch{
+			VideoID:        candidate.VideoID,
+			FileName:       candidate.FileName,
+			Similarity:     similarity,
+			MatchedSeconds: matched,
+		})
+	}
+	sort.Slice(report.Matches, func(i, j int) bool {
+		return report.Matches[i].Similarity > report.Matches[j].Similarity
+	})
+	if len(report.Matches) > MaxDuplicateMatches {
+		report.Matches = report.Matches[:MaxDuplicateMatches]
+	}
+	return report, nil
+}
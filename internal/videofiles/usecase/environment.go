@@ -0,0 +1,42 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
+)
+
+// ListJobEnvironments returns the environments every completed job of a
+// video was processed in, most recent first.
+func (v *videoFileUC) ListJobEnvironments(ctx context.Context, videoID uuid.UUID) ([]*models.JobEnvironment, error) {
+	if _, err := v.GetVideo(ctx, videoID); err != nil {
+		return nil, err
+	}
+
+	envs, err := v.videoRepo.GetJobEnvironments(ctx, videoID)
+	if err != nil {
+		v.logger.Errorf("ListJobEnvironments - failed to fetch job environments: %v", err)
+		return nil, fmt.Errorf("failed to fetch job environments: %v", err)
+	}
+	return envs, nil
+}
+
+// GetSourceReport returns what the worker found in the uploaded source of a
+// video when it downloaded it.
+func (v *videoFileUC) GetSourceReport(ctx context.Context, videoID uuid.UUID) (*models.SourceReport, error) {
+	if _, err := v.GetVideo(ctx, videoID); err != nil {
+		return nil, err
+	}
+
+	probe, err := v.videoRepo.GetSourceMetadata(ctx, videoID)
+	if err != nil {
+		v.logger.Errorf("GetSourceReport - failed to fetch source metadata: %v", err)
+		return nil, fmt.Errorf("failed to fetch source metadata: %v", err)
+	}
+	if probe == nil {
+		return nil, fmt.Errorf("source has not been probed yet")
+	}
+	return models.NewSourceReport(videoID.String(), probe)
+}
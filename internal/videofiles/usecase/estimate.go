@@ -0,0 +1,80 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+)
+
+// quotaUnitBytes is the unit of users.storage_quota_db
+const quotaUnitBytes = 1 << 30
+
+// EstimateOutputSize works out how much storage a job would need and whether
+// it fits in what is left of the user's quota, without creating anything.
+func (v *videoFileUC) EstimateOutputSize(ctx context.Context, input *models.VideoUploadInput) (*models.OutputEstimate, error) {
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		v.logger.Errorf("EstimateOutputSize - failed to get user from context: %v", err)
+		return nil, fmt.Errorf("unauthorized: %v", err)
+	}
+	if err = utils.ValidateStruct(ctx, input); err != nil {
+		v.logger.Errorf("EstimateOutputSize - ValidateStruct error: %v", err)
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+	applyJobDefaults(input)
+	return v.estimateOutput(ctx, user.UserID, input), nil
+}
+
+// estimateOutput sizes every rendition of the ladder at its target bitrate.
+// LL-HLS renditions are fragmented copies and progressive downloads are
+// separate files, so both add to the total. The quota check is skipped when
+// it cannot be read rather than failing the job.
+func (v *videoFileUC) estimateOutput(ctx context.Context, userID uuid.UUID, input *models.VideoUploadInput) *models.OutputEstimate {
+	estimate := &models.OutputEstimate{Renditions: make([]models.RenditionEstimate, 0, len(input.Qualities))}
+
+	var ladderBytes, downloadBytes int64
+	for _, quality := range input.Qualities {
+		bytes := models.EstimateRenditionBytes(quality.Bitrate, float64(input.Duration))
+		estimate.Renditions = append(estimate.Renditions, models.RenditionEstimate{
+			Resolution: quality.Resolution,
+			Bitrate:    quality.Bitrate + models.EstimateAudioBitrate,
+			Bytes:      bytes,
+		})
+		ladderBytes += bytes
+		if input.EnableDownloads && (input.DownloadQuality == "" || string(input.DownloadQuality) == quality.Resolution) {
+			downloadBytes += bytes
+		}
+	}
+
+	estimate.TotalBytes = ladderBytes + downloadBytes
+	if input.LowLatencyHLS {
+		estimate.TotalBytes += ladderBytes
+	}
+
+	quota, err := v.videoRepo.GetStorageQuota(ctx, userID)
+	if err != nil {
+		v.logger.Warnf("estimateOutput - failed to get storage quota: %v", err)
+		return estimate
+	}
+	used, err := v.videoRepo.GetStorageUsage(ctx, userID)
+	if err != nil {
+		v.logger.Warnf("estimateOutput - failed to get storage usage: %v", err)
+		return estimate
+	}
+	if quota <= 0 {
+		return estimate
+	}
+
+	estimate.QuotaBytes = quota * quotaUnitBytes
+	estimate.UsedBytes = used
+	estimate.RemainingBytes = max(estimate.QuotaBytes-used, 0)
+	if estimate.TotalBytes > estimate.RemainingBytes {
+		estimate.ExceedsQuota = true
+		estimate.Warning = fmt.Sprintf("estimated output of %d bytes exceeds the %d bytes left in the storage quota",
+			estimate.TotalBytes, estimate.RemainingBytes)
+	}
+	return estimate
+}
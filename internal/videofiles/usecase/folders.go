@@ -0,0 +1,179 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+)
+
+func (v *videoFileUC) CreateFolder(ctx context.Context, input *models.FolderInput) (*models.Folder, error) {
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		v.logger.Errorf("CreateFolder - GetUserFromCtx error: %v", err)
+		return nil, err
+	}
+	if err = utils.ValidateStruct(ctx, input); err != nil {
+		v.logger.Errorf("CreateFolder - ValidateStruct error: %v", err)
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+
+	if input.ParentID != nil {
+		if _, err = v.getFolder(ctx, *input.ParentID, user.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	folder, err := v.videoRepo.CreateFolder(ctx, &models.Folder{
+		UserID:   user.UserID,
+		ParentID: input.ParentID,
+		Name:     input.Name,
+	})
+	if err != nil {
+		v.logger.Errorf("CreateFolder - failed to create folder: %v", err)
+		return nil, fmt.Errorf("failed to create folder: %v", err)
+	}
+	return folder, nil
+}
+
+// ListFolders lists the folders directly below parentID, or at the root of
+// the library when parentID is nil.
+func (v *videoFileUC) ListFolders(ctx context.Context, parentID *uuid.UUID) ([]*models.Folder, error) {
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		v.logger.Errorf("ListFolders - GetUserFromCtx error: %v", err)
+		return nil, err
+	}
+
+	if parentID != nil {
+		if _, err = v.getFolder(ctx, *parentID, user.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	folders, err := v.videoRepo.GetFolders(ctx, user.UserID, parentID)
+	if err != nil {
+		v.logger.Errorf("ListFolders - failed to fetch folders: %v", err)
+		return nil, fmt.Errorf("failed to fetch folders: %v", err)
+	}
+	return folders, nil
+}
+
+func (v *videoFileUC) RenameFolder(ctx context.Context, folderID uuid.UUID, input *models.RenameFolderInput) (*models.Folder, error) {
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		v.logger.Errorf("RenameFolder - GetUserFromCtx error: %v", err)
+		return nil, err
+	}
+	if err = utils.ValidateStruct(ctx, input); err != nil {
+		v.logger.Errorf("RenameFolder - ValidateStruct error: %v", err)
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+
+	folder, err := v.getFolder(ctx, folderID, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	folder.Name = input.Name
+	if err = v.videoRepo.UpdateFolder(ctx, folder); err != nil {
+		v.logger.Errorf("RenameFolder - failed to update folder: %v", err)
+		return nil, fmt.Errorf("failed to rename folder: %v", err)
+	}
+	return folder, nil
+}
+
+// MoveFolder moves a folder, with everything in it, under another folder or
+// to the root of the library. A folder cannot be moved into itself or one of
+// its own subfolders.
+func (v *videoFileUC) MoveFolder(ctx context.Context, folderID uuid.UUID, input *models.MoveFolderInput) (*models.Folder, error) {
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		v.logger.Errorf("MoveFolder - GetUserFromCtx error: %v", err)
+		return nil, err
+	}
+
+	folder, err := v.getFolder(ctx, folderID, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.ParentID != nil {
+		if _, err = v.getFolder(ctx, *input.ParentID, user.UserID); err != nil {
+			return nil, err
+		}
+		cycle, err := v.videoRepo.IsFolderDescendant(ctx, folderID, *input.ParentID)
+		if err != nil {
+			v.logger.Errorf("MoveFolder - failed to check folder hierarchy: %v", err)
+			return nil, fmt.Errorf("failed to move folder: %v", err)
+		}
+		if cycle {
+			return nil, fmt.Errorf("cannot move a folder into itself")
+		}
+	}
+
+	folder.ParentID = input.ParentID
+	if err = v.videoRepo.UpdateFolder(ctx, folder); err != nil {
+		v.logger.Errorf("MoveFolder - failed to update folder: %v", err)
+		return nil, fmt.Errorf("failed to move folder: %v", err)
+	}
+	return folder, nil
+}
+
+// DeleteFolder deletes a folder and its subfolders. The videos they contain
+// are kept and moved back to the root of the library.
+func (v *videoFileUC) DeleteFolder(ctx context.Context, folderID uuid.UUID) error {
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		v.logger.Errorf("DeleteFolder - GetUserFromCtx error: %v", err)
+		return err
+	}
+	if err = v.videoRepo.DeleteFolder(ctx, user.UserID, folderID); err != nil {
+		v.logger.Errorf("DeleteFolder - failed to delete folder: %v", err)
+		return fmt.Errorf("failed to delete folder: %v", err)
+	}
+	return nil
+}
+
+// MoveVideo moves a video into a folder, or to the root of the library when
+// no folder is given.
+func (v *videoFileUC) MoveVideo(ctx context.Context, videoID uuid.UUID, input *models.MoveVideoInput) error {
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		v.logger.Errorf("MoveVideo - GetUserFromCtx error: %v", err)
+		return err
+	}
+
+	if input.FolderID != nil {
+		if _, err = v.getFolder(ctx, *input.FolderID, user.UserID); err != nil {
+			return err
+		}
+	}
+
+	if err = v.videoRepo.MoveVideo(ctx, user.UserID, videoID, input.FolderID); err != nil {
+		v.logger.Errorf("MoveVideo - failed to move video: %v", err)
+		return fmt.Errorf("failed to move video: %v", err)
+	}
+	return nil
+}
+
+// getFolder returns a folder owned by userID.
+func (v *videoFileUC) getFolder(ctx context.Context, folderID uuid.UUID, userID uuid.UUID) (*models.Folder, error) {
+	folder, err := v.videoRepo.GetFolderByID(ctx, folderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("folder not found")
+		}
+		v.logger.Errorf("getFolder - failed to fetch folder: %v", err)
+		return nil, fmt.Errorf("failed to fetch folder: %v", err)
+	}
+	if folder.UserID != userID {
+		v.logger.Warnf("User %s is not authorized to access folder %s", userID, folderID.String())
+		return nil, fmt.Errorf("folder not found")
+	}
+	return folder, nil
+}
@@ -0,0 +1,139 @@
+package usecase
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+	"github.com/google/uuid"
+)
+
+const (
+	s3ObjectCreatedEvent = "ObjectCreated:"
+	// subscribeTimeout bounds the request confirming an SNS subscription
+	subscribeTimeout = 10 * time.Second
+)
+
+// IngestS3Event creates a video and queues an encode job with the default
+// profile for every file created under the watched prefix. Files are owned by
+// the user whose ID follows the prefix. Records that cannot be ingested are
+// skipped, as are files already ingested, since S3 may deliver an event more
+// than once.
+func (v *videoFileUC) IngestS3Event(ctx context.Context, token string, event *models.S3Event) (*models.IngestSummary, error) {
+	if err := v.authorizeIngest(token); err != nil {
+		return nil, err
+	}
+
+	summary := &models.IngestSummary{Jobs: []string{}}
+	for _, record := range event.Records {
+		job, err := v.ingestRecord(ctx, record)
+		if err != nil {
+			v.logger.Warnf("IngestS3Event - skipping %s/%s: %v", record.S3.Bucket.Name, record.S3.Object.Key, err)
+			summary.Skipped++
+			continue
+		}
+		if job == nil {
+			summary.Skipped++
+			continue
+		}
+		summary.Jobs = append(summary.Jobs, job.JobID)
+	}
+	if len(summary.Jobs) > 0 {
+		v.logger.Infof("Ingested %d files from S3, skipped %d", len(summary.Jobs), summary.Skipped)
+	}
+	return summary, nil
+}
+
+// ConfirmIngestSubscription confirms the SNS subscription S3 notifications
+// are delivered through. Only SNS endpoints are contacted.
+func (v *videoFileUC) ConfirmIngestSubscription(ctx context.Context, token string, message *models.SNSMessage) error {
+	if err := v.authorizeIngest(token); err != nil {
+		return err
+	}
+
+	subscribeURL, err := url.Parse(message.SubscribeURL)
+	if err != nil || subscribeURL.Scheme != "https" ||
+		!strings.HasPrefix(subscribeURL.Hostname(), "sns.") || !strings.HasSuffix(subscribeURL.Hostname(), ".amazonaws.com") {
+		return fmt.Errorf("invalid subscribe url")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, subscribeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, subscribeURL.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to confirm subscription: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		v.logger.Errorf("ConfirmIngestSubscription - request error: %v", err)
+		return fmt.Errorf("failed to confirm subscription: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to confirm subscription: unexpected status %d", resp.StatusCode)
+	}
+	v.logger.Infof("Confirmed ingest subscription to %s", message.TopicArn)
+	return nil
+}
+
+func (v *videoFileUC) authorizeIngest(token string) error {
+	if v.cfg.Ingest.Token == "" {
+		return videofiles.ErrIngestDisabled
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(v.cfg.Ingest.Token)) != 1 {
+		return videofiles.ErrIngestUnauthorized
+	}
+	return nil
+}
+
+// ingestRecord queues the job of one S3 event record. It returns a nil job
+// for records that are not meant to be ingested.
+func (v *videoFileUC) ingestRecord(ctx context.Context, record models.S3EventRecord) (*models.EncodeJob, error) {
+	if !strings.HasPrefix(record.EventName, s3ObjectCreatedEvent) || record.S3.Bucket.Name != v.cfg.S3.InputBucket {
+		return nil, nil
+	}
+	key, err := url.QueryUnescape(record.S3.Object.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid object key: %v", err)
+	}
+	rest, ok := strings.CutPrefix(key, v.cfg.Ingest.Prefix)
+	if !ok || strings.HasSuffix(key, "/") {
+		return nil, nil
+	}
+
+	owner, _, _ := strings.Cut(rest, "/")
+	userID, err := uuid.Parse(owner)
+	if err != nil {
+		return nil, fmt.Errorf("key is not under a user id")
+	}
+	if _, err = v.videoRepo.GetUserPlan(ctx, userID); err != nil {
+		return nil, fmt.Errorf("unknown user %s: %v", userID, err)
+	}
+
+	exists, err := v.videoRepo.VideoExistsByS3Key(ctx, record.S3.Bucket.Name, key)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, nil
+	}
+
+	fileName := path.Base(key)
+	format := strings.TrimPrefix(path.Ext(fileName), ".")
+	if format == "" || record.S3.Object.Size <= 0 {
+		return nil, fmt.Errorf("not a video file")
+	}
+	input := &models.VideoUploadInput{
+		FileName: fileName,
+		FileSize: record.S3.Object.Size,
+		Format:   strings.ToLower(format),
+	}
+	return v.createJob(ctx, userID, input, key)
+}
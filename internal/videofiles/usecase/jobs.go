@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/i18n"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+)
+
+// GetJob returns the status of an encode job of the current user. Failed jobs
+// carry a failure reason from models.FailureReason, a description of it in
+// the request's locale and the underlying error.
+func (v *videoFileUC) GetJob(ctx context.Context, jobID string) (*models.EncodeJob, error) {
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		v.logger.Errorf("GetJob - failed to get user from context: %v", err)
+		return nil, fmt.Errorf("unauthorized: %v", err)
+	}
+
+	job, err := v.redisRepo.GetJobDetails(ctx, jobID)
+	if err != nil {
+		v.logger.Warnf("GetJob - failed to fetch job %s: %v", jobID, err)
+		return nil, videofiles.ErrJobNotFound
+	}
+	if job.UserID != user.UserID.String() {
+		v.logger.Warnf("User %s is not authorized to access job %s", user.UserID, jobID)
+		return nil, videofiles.ErrJobNotFound
+	}
+	if job.FailureReason != "" {
+		if catalog, err := i18n.Default(); err == nil {
+			job.FailureDescription = catalog.FailureReason(utils.GetLocaleFromCtx(ctx), string(job.FailureReason))
+		}
+	}
+	return job, nil
+}
+
+// jobQueue routes jobs encoded with a hardware-friendly codec to the GPU
+// queue while a GPU worker is advertising NVENC sessions. AV1 and everything
+// else is encoded in software and goes to the CPU queue.
+func (v *videoFileUC) jobQueue(ctx context.Context, codec models.Codec) string {
+	workers, err := v.redisRepo.ListWorkerCapabilities(ctx)
+	if err != nil {
+		v.logger.Warnf("jobQueue - failed to list worker capabilities: %v", err)
+		return v.cfg.Redis.JobQueueKey
+	}
+
+	gpuAvailable := false
+	for _, caps := range workers {
+		if caps.HasGPU() {
+			gpuAvailable = true
+			break
+		}
+	}
+	return videofiles.JobQueue(v.cfg.Redis.JobQueueKey, codec, gpuAvailable)
+}
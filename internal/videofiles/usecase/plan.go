@@ -0,0 +1,94 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+)
+
+// EncodePlanTTL is how long the plan of a dry run is kept
+const EncodePlanTTL = 24 * time.Hour
+
+// PlanEncode queues a dry run of one of the user's videos with a profile. A
+// worker probes the source and records the renditions, predicted sizes and
+// ffmpeg arguments it would use, without encoding anything, so profiles and
+// ladder decisions can be checked cheaply. The plan is read with
+// GetEncodePlan once its status is no longer pending.
+func (v *videoFileUC) PlanEncode(ctx context.Context, videoID uuid.UUID, input *models.EncodePlanInput) (*models.EncodePlan, error) {
+	if err := utils.ValidateStruct(ctx, input); err != nil {
+		v.logger.Errorf("PlanEncode - ValidateStruct error: %v", err)
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+	video, err := v.GetVideo(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+	if video.DeletedAt != nil {
+		return nil, fmt.Errorf("video is in the trash")
+	}
+	if video.Kind != "" && video.Kind != models.AssetVideo {
+		return nil, fmt.Errorf("only videos can be planned")
+	}
+	if video.Status == models.JobStatusRejected {
+		return nil, fmt.Errorf("source of the video was quarantined")
+	}
+
+	defaults := &models.VideoUploadInput{
+		Codec:         input.Codec,
+		Qualities:     input.Qualities,
+		OutputFormats: input.OutputFormats,
+	}
+	applyJobDefaults(defaults)
+	input.Codec, input.Qualities, input.OutputFormats = defaults.Codec, defaults.Qualities, defaults.OutputFormats
+
+	job := &models.EncodeJob{
+		JobID:                  uuid.New().String(),
+		UserID:                 video.UserID.String(),
+		VideoID:                video.VideoID.String(),
+		InputS3Key:             video.S3Key,
+		InputBucket:            video.S3Bucket,
+		OutputBucket:           v.cfg.S3.OutputBucket,
+		OutputS3Key:            video.S3Key,
+		Qualities:              input.Qualities,
+		OutputFormats:          input.OutputFormats,
+		EnablePerTitleEncoding: input.EnablePerTitleEncoding,
+		Status:                 models.JobStatusQueued,
+		Codec:                  input.Codec,
+		StartedAt:              time.Now(),
+		Type:                   models.JobTypePlan,
+	}
+	plan := &models.EncodePlan{
+		JobID:     job.JobID,
+		VideoID:   job.VideoID,
+		Status:    models.EncodePlanPending,
+		Input:     *input,
+		CreatedAt: time.Now(),
+	}
+	if err := v.redisRepo.SaveEncodePlan(ctx, plan, EncodePlanTTL); err != nil {
+		v.logger.Errorf("PlanEncode - SaveEncodePlan error: %v", err)
+		return nil, fmt.Errorf("failed to save plan: %v", err)
+	}
+	// The dry run goes where the encode would, so it is planned for the
+	// same kind of worker
+	if err := v.redisRepo.EnqueueJob(ctx, v.jobQueue(ctx, job.Codec), job); err != nil {
+		v.logger.Errorf("PlanEncode - EnqueueJob error: %v", err)
+		return nil, fmt.Errorf("failed to queue the job: %v", err)
+	}
+	return plan, nil
+}
+
+// GetEncodePlan returns the plan of a dry run of one of the user's videos
+func (v *videoFileUC) GetEncodePlan(ctx context.Context, videoID uuid.UUID, jobID string) (*models.EncodePlan, error) {
+	if _, err := v.GetVideo(ctx, videoID); err != nil {
+		return nil, err
+	}
+	plan, err := v.redisRepo.GetEncodePlan(ctx, jobID)
+	if err != nil || plan.VideoID != videoID.String() {
+		return nil, fmt.Errorf("plan not found")
+	}
+	return plan, nil
+}
@@ -0,0 +1,161 @@
+package usecase
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/playbacktoken"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultPlaybackTokenTTL is how long playback tokens are valid when no
+	// TTL is configured. It should outlast a viewing session, since players
+	// keep requesting segments with the token they started with.
+	DefaultPlaybackTokenTTL = 6 * time.Hour
+	// originRedirectTTL is how long the presigned URLs segments are
+	// redirected to are valid. They are requested right away.
+	originRedirectTTL = time.Minute
+)
+
+// signPlaybackURLs points the CDN URLs of a video at the playback origin with
+// a token for the viewer, when playback tokens are enabled. Encrypted videos
+// are already served through the playback proxy, which checks access itself.
+func (v *videoFileUC) signPlaybackURLs(video *models.VideoFile, playbackInfo *models.PlaybackInfo, viewerID uuid.UUID) {
+	signer, err := playbacktoken.NewSigner(v.cfg.Playback.TokenSecrets...)
+	if err != nil || video.Encrypted {
+		return
+	}
+
+	ttl := DefaultPlaybackTokenTTL
+	if v.cfg.Playback.TokenTTL > 0 {
+		ttl = time.Duration(v.cfg.Playback.TokenTTL) * time.Second
+	}
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	claims := playbacktoken.Claims{
+		VideoID:   video.VideoID,
+		UserID:    viewerID,
+		ExpiresAt: expiresAt,
+	}
+	if video.ShareToken != nil {
+		claims.ShareHash = playbacktoken.ShareHash(*video.ShareToken)
+	}
+	token := signer.Sign(claims)
+
+	originURL := v.cfg.Playback.OriginURL
+	if originURL == "" {
+		originURL = v.cfg.Encryption.PlaybackProxyURL
+	}
+	cdnBase := fmt.Sprintf("%s/%s", v.cfg.S3.CDNEndpoint, outputPrefix(video))
+	originBase := fmt.Sprintf("%s/video/%s/play/%s", originURL, video.VideoID, token)
+	rewrite := func(u string) string {
+		if rest, ok := strings.CutPrefix(u, cdnBase); ok {
+			return originBase + rest
+		}
+		return u
+	}
+
+	playbackInfo.Thumbnail = rewrite(playbackInfo.Thumbnail)
+	for i := range playbackInfo.Thumbnails {
+		playbackInfo.Thumbnails[i] = rewrite(playbackInfo.Thumbnails[i])
+	}
+	playbackInfo.Preview = rewrite(playbackInfo.Preview)
+	for i := range playbackInfo.Subtitles {
+		playbackInfo.Subtitles[i] = rewrite(playbackInfo.Subtitles[i])
+	}
+	for quality, info := range playbackInfo.Qualities {
+		info.URLs.HLS = rewrite(info.URLs.HLS)
+		info.URLs.DASH = rewrite(info.URLs.DASH)
+		info.URLs.MP4 = rewrite(info.URLs.MP4)
+		info.URLs.LLHLS = rewrite(info.URLs.LLHLS)
+		playbackInfo.Qualities[quality] = info
+	}
+	playbackInfo.TokenExpiresAt = &expiresAt
+}
+
+// GetOriginObject serves an output object to the holder of a playback token.
+// Access is checked again, so tokens stop working once the video is made
+// private or deleted, or its share token is rotated. Playlists are streamed, since players resolve the
+// relative URLs in them against the URL they were served from; segments are
+// redirected to presigned URLs unless every object is proxied.
+func (v *videoFileUC) GetOriginObject(ctx context.Context, videoID uuid.UUID, objectPath string) (*videofiles.OriginObject, error) {
+	claims, err := utils.GetPlaybackClaimsFromCtx(ctx)
+	if err != nil {
+		v.logger.Errorf("GetOriginObject - failed to get playback claims from context: %v", err)
+		return nil, videofiles.ErrPlaybackForbidden
+	}
+	if claims.VideoID != videoID {
+		return nil, videofiles.ErrPlaybackForbidden
+	}
+
+	objectPath = path.Clean("/" + objectPath)
+	if objectPath == "/" {
+		return nil, fmt.Errorf("invalid object path")
+	}
+
+	video, err := v.videoRepo.GetVideoByID(ctx, videoID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, videofiles.ErrVideoNotFound
+		}
+		v.logger.Errorf("GetOriginObject - failed to fetch video: %v", err)
+		return nil, fmt.Errorf("failed to fetch video: %v", err)
+	}
+	if video.DeletedAt != nil || !video.CanView(claims.UserID, "") && !sharedWith(video, claims) {
+		v.logger.Warnf("User %s is not authorized to play video %s", claims.UserID, videoID.String())
+		return nil, videofiles.ErrPlaybackForbidden
+	}
+
+	if video.Encrypted {
+		object, err := v.getEncryptedObject(ctx, video, objectPath)
+		if err != nil {
+			return nil, err
+		}
+		return &videofiles.OriginObject{Object: object}, nil
+	}
+
+	key := outputPrefix(video) + objectPath
+	if v.cfg.Playback.Proxy || isManifest(objectPath) {
+		object, err := v.awsRepo.GetObject(ctx, v.cfg.S3.OutputBucket, key)
+		if err != nil {
+			v.logger.Errorf("GetOriginObject - GetObject error: %v", err)
+			return nil, fmt.Errorf("failed to fetch object: %w", err)
+		}
+		return &videofiles.OriginObject{Object: object}, nil
+	}
+
+	redirectURL, err := v.awsRepo.GetPresignedObjectURL(ctx, v.cfg.S3.OutputBucket, key, originRedirectTTL)
+	if err != nil {
+		v.logger.Errorf("GetOriginObject - GetPresignedObjectURL error: %v", err)
+		return nil, fmt.Errorf("failed to presign object: %w", err)
+	}
+	return &videofiles.OriginObject{RedirectURL: redirectURL}, nil
+}
+
+// sharedWith reports whether a token was issued against the current share
+// token of an unlisted video
+func sharedWith(video *models.VideoFile, claims *playbacktoken.Claims) bool {
+	if video.Visibility != models.VisibilityUnlisted || video.ShareToken == nil || claims.ShareHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(playbacktoken.ShareHash(*video.ShareToken)), []byte(claims.ShareHash)) == 1
+}
+
+// isManifest reports whether an output object is an HLS or DASH playlist
+func isManifest(objectPath string) bool {
+	switch path.Ext(objectPath) {
+	case ".m3u8", ".mpd":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,121 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+)
+
+const (
+	CPUQueueName = "cpu"
+	GPUQueueName = "gpu"
+)
+
+// queueKey maps the name of a job queue to its Redis key. Only the queues
+// jobs are routed to can be inspected, so the endpoints cannot be used to
+// read arbitrary keys.
+func (v *videoFileUC) queueKey(name string) (string, error) {
+	switch name {
+	case CPUQueueName:
+		return v.cfg.Redis.JobQueueKey, nil
+	case GPUQueueName:
+		return v.cfg.Redis.JobQueueKey + videofiles.GPUQueueSuffix, nil
+	default:
+		return "", fmt.Errorf("unknown queue %q", name)
+	}
+}
+
+// ListQueueDepths reports how many jobs wait on each job queue.
+func (v *videoFileUC) ListQueueDepths(ctx context.Context) ([]*models.QueueDepth, error) {
+	var depths []*models.QueueDepth
+	for _, name := range []string{CPUQueueName, GPUQueueName} {
+		depth, err := v.queueDepth(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		depths = append(depths, depth)
+	}
+	return depths, nil
+}
+
+func (v *videoFileUC) queueDepth(ctx context.Context, name string) (*models.QueueDepth, error) {
+	key, err := v.queueKey(name)
+	if err != nil {
+		return nil, err
+	}
+	length, err := v.redisRepo.QueueLength(ctx, key)
+	if err != nil {
+		v.logger.Errorf("ListQueueDepths - failed to get length of %s: %v", key, err)
+		return nil, fmt.Errorf("failed to get queue depth: %v", err)
+	}
+	return &models.QueueDepth{Name: name, Key: key, Depth: length}, nil
+}
+
+// PeekQueue returns a page of a job queue without dequeueing anything.
+// Payloads that do not decode into a job are returned as is with the decode
+// error, so they can be found and purged.
+func (v *videoFileUC) PeekQueue(ctx context.Context, name string, pagination *utils.Pagination) (*models.QueuePage, error) {
+	depth, err := v.queueDepth(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	start := int64(pagination.GetOffset())
+	payloads, err := v.redisRepo.PeekQueue(ctx, depth.Key, start, start+int64(pagination.GetLimit())-1)
+	if err != nil {
+		v.logger.Errorf("PeekQueue - failed to read %s: %v", depth.Key, err)
+		return nil, fmt.Errorf("failed to read queue: %v", err)
+	}
+
+	page := &models.QueuePage{Queue: *depth, Jobs: make([]*models.QueuedJob, 0, len(payloads))}
+	for i, payload := range payloads {
+		queued := &models.QueuedJob{Index: start + int64(i), Payload: payload}
+		job := &models.EncodeJob{}
+		if err := json.Unmarshal([]byte(payload), job); err != nil {
+			queued.Error = err.Error()
+		} else {
+			queued.Job = job
+		}
+		page.Jobs = append(page.Jobs, queued)
+	}
+	return page, nil
+}
+
+// GetJobHash returns the Redis hash of any job, without the ownership check
+// GetJob applies.
+func (v *videoFileUC) GetJobHash(ctx context.Context, jobID string) (map[string]string, error) {
+	fields, err := v.redisRepo.GetJobHash(ctx, jobID)
+	if err != nil {
+		v.logger.Warnf("GetJobHash - failed to fetch job %s: %v", jobID, err)
+		return nil, videofiles.ErrJobNotFound
+	}
+	return fields, nil
+}
+
+// PurgeQueuedJob removes a payload from a job queue. The payload must match
+// exactly, as returned by PeekQueue.
+func (v *videoFileUC) PurgeQueuedJob(ctx context.Context, name string, input *models.PurgeJobInput) error {
+	if err := utils.ValidateStruct(ctx, input); err != nil {
+		v.logger.Errorf("PurgeQueuedJob - ValidateStruct error: %v", err)
+		return fmt.Errorf("invalid input: %v", err)
+	}
+	key, err := v.queueKey(name)
+	if err != nil {
+		return err
+	}
+
+	removed, err := v.redisRepo.RemoveQueuedPayload(ctx, key, input.Payload)
+	if err != nil {
+		v.logger.Errorf("PurgeQueuedJob - failed to remove payload from %s: %v", key, err)
+		return fmt.Errorf("failed to purge job: %v", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("payload not found in queue %s", name)
+	}
+	v.logger.Infof("Purged %d payloads from queue %s", removed, key)
+	return nil
+}
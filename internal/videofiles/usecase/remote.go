@@ -0,0 +1,43 @@
+package usecase
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+// remoteSourceName is the file name of remote sources whose URL path does
+// not end in one
+const remoteSourceName = "source"
+
+// prepareRemoteSource checks the source URL of a job and fills in the file
+// name and format the client may leave out, taking them from the URL path.
+// The worker fetches the source and stores it under the file name like an
+// upload.
+func prepareRemoteSource(input *models.VideoUploadInput) error {
+	sourceURL, err := url.Parse(input.SourceURL)
+	if err != nil {
+		return fmt.Errorf("invalid source url: %w", err)
+	}
+	if sourceURL.Scheme != "http" && sourceURL.Scheme != "https" {
+		return fmt.Errorf("source url must be http or https")
+	}
+	if sourceURL.Host == "" || sourceURL.User != nil {
+		return fmt.Errorf("source url must have a host and no credentials")
+	}
+
+	if input.FileName == "" {
+		input.FileName = path.Base(sourceURL.Path)
+		if input.FileName == "." || input.FileName == "/" {
+			input.FileName = remoteSourceName
+		}
+	}
+	input.FileName = path.Base(input.FileName)
+	if input.Format == "" {
+		input.Format = strings.TrimPrefix(path.Ext(input.FileName), ".")
+	}
+	return nil
+}
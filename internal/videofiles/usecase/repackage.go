@@ -0,0 +1,132 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/drm"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+)
+
+// RepackageVideo queues a repackage of one of the user's finished videos.
+func (v *videoFileUC) RepackageVideo(ctx context.Context, videoID uuid.UUID, input *models.RepackageInput) (*models.EncodeJob, error) {
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		v.logger.Errorf("RepackageVideo - failed to get user from context: %v", err)
+		return nil, fmt.Errorf("unauthorized: %v", err)
+	}
+	if err = v.validateRepackage(ctx, input); err != nil {
+		return nil, err
+	}
+
+	video, err := v.videoRepo.GetVideoByID(ctx, videoID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, videofiles.ErrVideoNotFound
+		}
+		v.logger.Errorf("RepackageVideo - failed to fetch video: %v", err)
+		return nil, fmt.Errorf("failed to fetch video: %v", err)
+	}
+	if video.UserID != user.UserID {
+		v.logger.Warnf("User %s is not authorized to repackage video %s", user.UserID, videoID)
+		return nil, videofiles.ErrVideoForbidden
+	}
+
+	return v.enqueueRepackage(ctx, video, input)
+}
+
+// RepackageLibrary queues a repackage of every finished video. Videos that
+// cannot be repackaged with the given options are skipped.
+func (v *videoFileUC) RepackageLibrary(ctx context.Context, input *models.RepackageInput) (*models.RepackageSummary, error) {
+	if err := v.validateRepackage(ctx, input); err != nil {
+		return nil, err
+	}
+
+	videos, err := v.videoRepo.GetCompletedVideos(ctx)
+	if err != nil {
+		v.logger.Errorf("RepackageLibrary - failed to fetch videos: %v", err)
+		return nil, fmt.Errorf("failed to fetch videos: %v", err)
+	}
+
+	summary := &models.RepackageSummary{}
+	for _, video := range videos {
+		if _, err := v.enqueueRepackage(ctx, video, input); err != nil {
+			v.logger.Warnf("RepackageLibrary - skipping video %s: %v", video.VideoID, err)
+			summary.Skipped++
+			continue
+		}
+		summary.Queued++
+	}
+	v.logger.Infof("Queued %d repackage jobs, skipped %d videos", summary.Queued, summary.Skipped)
+	return summary, nil
+}
+
+func (v *videoFileUC) validateRepackage(ctx context.Context, input *models.RepackageInput) error {
+	if err := utils.ValidateStruct(ctx, input); err != nil {
+		v.logger.Errorf("Repackage - ValidateStruct error: %v", err)
+		return fmt.Errorf("invalid input: %v", err)
+	}
+	if input.EnableDRM && v.cfg.DRM.KeyServerURL == "" {
+		return drm.ErrNotConfigured
+	}
+	return nil
+}
+
+// enqueueRepackage queues a repackage job writing over the output of the
+// video's active playback version. Protected output is refused since its
+// segments are encrypted.
+func (v *videoFileUC) enqueueRepackage(ctx context.Context, video *models.VideoFile, input *models.RepackageInput) (*models.EncodeJob, error) {
+	if video.Status != models.JobStatusCompleted {
+		return nil, fmt.Errorf("only completed videos can be repackaged")
+	}
+	if video.Kind != "" && video.Kind != models.AssetVideo {
+		return nil, fmt.Errorf("only videos can be repackaged")
+	}
+	if video.DRMKeyID != nil && *video.DRMKeyID != "" {
+		return nil, fmt.Errorf("video is DRM protected and cannot be repackaged")
+	}
+
+	outputKey := video.S3Key
+	var version int
+	if playbackInfo, err := v.videoRepo.GetPlaybackInfo(ctx, video.VideoID); err == nil && playbackInfo.Version > 0 {
+		version = playbackInfo.Version
+		outputKey = path.Join(outputPrefix(video), models.PlaybackVersionPath(version))
+	}
+
+	job := &models.EncodeJob{
+		JobID:             uuid.New().String(),
+		UserID:            video.UserID.String(),
+		VideoID:           video.VideoID.String(),
+		InputS3Key:        video.S3Key,
+		InputBucket:       video.S3Bucket,
+		OutputBucket:      v.cfg.S3.OutputBucket,
+		OutputS3Key:       outputKey,
+		PlaybackVersion:   version,
+		Status:            models.JobStatusQueued,
+		StartedAt:         time.Now(),
+		EncryptedDataKey:  video.EncryptedDataKey,
+		EnableDRM:         input.EnableDRM,
+		Type:              models.JobTypeRepackage,
+		SegmentDuration:   input.SegmentDuration,
+		IntegrityManifest: input.IntegrityManifest,
+	}
+
+	if err := v.videoRepo.UpdateVideoProgress(ctx, video.VideoID, models.JobStatusQueued, 0); err != nil {
+		v.logger.Errorf("enqueueRepackage - failed to update video %s: %v", video.VideoID, err)
+		return nil, fmt.Errorf("failed to update video: %v", err)
+	}
+	v.recordJobEvent(ctx, job, models.JobEventQueued)
+	// Repackaging runs no encoder, so it never needs a GPU worker
+	if err := v.redisRepo.EnqueueJob(ctx, v.cfg.Redis.JobQueueKey, job); err != nil {
+		v.logger.Errorf("enqueueRepackage - EnqueueJob error: %v", err)
+		return nil, fmt.Errorf("failed to queue the job: %v", err)
+	}
+	return job, nil
+}
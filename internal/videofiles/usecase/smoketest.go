@@ -0,0 +1,281 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultSmokeTestDuration = 10
+	DefaultSmokeTestWidth    = 1280
+	DefaultSmokeTestHeight   = 720
+	SmokeTestTTL             = 24 * time.Hour
+	// smokeTestDurationTolerance is how far, in seconds, the encoded duration
+	// may drift from the generated one
+	smokeTestDurationTolerance = 0.5
+)
+
+// smokeTestRenditions mirrors the worker's quality ladder so the renditions
+// expected for a source resolution can be checked.
+var smokeTestRenditions = []struct {
+	quality       models.VideoQuality
+	width, height int
+}{
+	{models.Quality1080P, 1920, 1080},
+	{models.Quality720P, 1280, 720},
+	{models.Quality480P, 854, 480},
+	{models.Quality360P, 640, 360},
+}
+
+// StartSmokeTest generates a synthetic test video, uploads it and enqueues it
+// like a regular upload. Progress and validation results are reported by
+// GetSmokeTest.
+func (v *videoFileUC) StartSmokeTest(ctx context.Context, input *models.SmokeTestInput) (*models.SmokeTest, error) {
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		v.logger.Errorf("StartSmokeTest - failed to get user from context: %v", err)
+		return nil, fmt.Errorf("unauthorized: %v", err)
+	}
+	if err = utils.ValidateStruct(ctx, input); err != nil {
+		v.logger.Errorf("StartSmokeTest - ValidateStruct error: %v", err)
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+	if input.Duration == 0 {
+		input.Duration = DefaultSmokeTestDuration
+	}
+	if input.Width == 0 || input.Height == 0 {
+		input.Width, input.Height = DefaultSmokeTestWidth, DefaultSmokeTestHeight
+	}
+	// Encoders need even dimensions for yuv420p
+	input.Width &^= 1
+	input.Height &^= 1
+
+	test := &models.SmokeTest{
+		Input:     *input,
+		Status:    models.SmokeTestRunning,
+		Checks:    []models.SmokeTestCheck{},
+		StartedAt: time.Now(),
+	}
+
+	tempDir, err := os.MkdirTemp("", "smoketest-")
+	if err != nil {
+		v.logger.Errorf("StartSmokeTest - failed to create temp dir: %v", err)
+		return nil, fmt.Errorf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	fileName := fmt.Sprintf("smoke-test-%s.mp4", uuid.New().String()[:8])
+	sourcePath := filepath.Join(tempDir, fileName)
+	if err = generateTestSource(ctx, sourcePath, input); err != nil {
+		v.logger.Errorf("StartSmokeTest - failed to generate test source: %v", err)
+		return nil, fmt.Errorf("failed to generate test source: %v", err)
+	}
+	test.GenerateTime = time.Since(test.StartedAt).Seconds()
+
+	uploadStart := time.Now()
+	fileSize, err := v.uploadTestSource(ctx, sourcePath, fmt.Sprintf("uploads/%s/%s", user.UserID, fileName))
+	if err != nil {
+		v.logger.Errorf("StartSmokeTest - failed to upload test source: %v", err)
+		return nil, fmt.Errorf("failed to upload test source: %v", err)
+	}
+	test.UploadTime = time.Since(uploadStart).Seconds()
+
+	job, err := v.CreateJob(ctx, &models.VideoUploadInput{
+		FileName:               fileName,
+		FileSize:               fileSize,
+		Duration:               int64(input.Duration),
+		Codec:                  input.Codec,
+		Format:                 "mp4",
+		OutputFormats:          input.OutputFormats,
+		EnablePerTitleEncoding: input.EnablePerTitleEncoding,
+		Visibility:             models.VisibilityPrivate,
+	})
+	if err != nil {
+		return nil, err
+	}
+	test.VideoID = job.VideoID
+	test.JobID = job.JobID
+	test.QueuedAt = time.Now()
+
+	if err = v.redisRepo.SaveSmokeTest(ctx, test, SmokeTestTTL); err != nil {
+		v.logger.Errorf("StartSmokeTest - failed to save smoke test: %v", err)
+		return nil, fmt.Errorf("failed to save smoke test: %v", err)
+	}
+	return test, nil
+}
+
+// GetSmokeTest reports the progress of a smoke test. Once its job has
+// finished the output is validated and the result is kept until the test
+// expires.
+func (v *videoFileUC) GetSmokeTest(ctx context.Context, videoID uuid.UUID) (*models.SmokeTest, error) {
+	test, err := v.redisRepo.GetSmokeTest(ctx, videoID.String())
+	if err != nil {
+		v.logger.Errorf("GetSmokeTest - failed to fetch smoke test: %v", err)
+		return nil, fmt.Errorf("smoke test not found")
+	}
+	if test.Status != models.SmokeTestRunning {
+		return test, nil
+	}
+
+	video, err := v.videoRepo.GetVideoByID(ctx, videoID)
+	if err != nil {
+		v.logger.Errorf("GetSmokeTest - failed to fetch video: %v", err)
+		return nil, fmt.Errorf("failed to fetch video: %v", err)
+	}
+
+	var playbackInfo *models.PlaybackInfo
+	switch video.Status {
+	case models.JobStatusFailed, models.JobStatusRejected:
+	case models.JobStatusCompleted:
+		// The worker stores playback info right after marking the video completed
+		if playbackInfo, err = v.videoRepo.GetPlaybackInfo(ctx, videoID); err != nil {
+			return test, nil
+		}
+	default:
+		return test, nil
+	}
+
+	finishedAt := time.Now()
+	if video.ProgressUpdatedAt != nil {
+		finishedAt = *video.ProgressUpdatedAt
+	}
+	test.FinishedAt = &finishedAt
+	test.ProcessingTime = finishedAt.Sub(test.QueuedAt).Seconds()
+	test.TotalTime = finishedAt.Sub(test.StartedAt).Seconds()
+	test.Checks = v.validateSmokeTest(ctx, test, video, playbackInfo)
+
+	test.Status = models.SmokeTestPassed
+	for _, check := range test.Checks {
+		if !check.Passed {
+			test.Status = models.SmokeTestFailed
+			break
+		}
+	}
+
+	if err = v.redisRepo.SaveSmokeTest(ctx, test, SmokeTestTTL); err != nil {
+		v.logger.Warnf("GetSmokeTest - failed to save smoke test result: %v", err)
+	}
+	return test, nil
+}
+
+func (v *videoFileUC) validateSmokeTest(ctx context.Context, test *models.SmokeTest, video *models.VideoFile, playbackInfo *models.PlaybackInfo) []models.SmokeTestCheck {
+	checks := []models.SmokeTestCheck{{
+		Name:    "status",
+		Passed:  video.Status == models.JobStatusCompleted,
+		Message: fmt.Sprintf("job finished with status %s", video.Status),
+	}}
+	if playbackInfo == nil {
+		return checks
+	}
+
+	var missing []string
+	for _, rendition := range smokeTestRenditions {
+		if rendition.width > test.Input.Width || rendition.height > test.Input.Height {
+			continue
+		}
+		if _, ok := playbackInfo.Qualities[rendition.quality]; !ok {
+			missing = append(missing, string(rendition.quality))
+		}
+	}
+	renditions := models.SmokeTestCheck{Name: "renditions", Passed: len(missing) == 0}
+	if len(missing) > 0 {
+		renditions.Message = "missing " + strings.Join(missing, ", ")
+	}
+	checks = append(checks, renditions)
+
+	checks = append(checks, models.SmokeTestCheck{
+		Name:    "duration",
+		Passed:  math.Abs(playbackInfo.Duration-float64(test.Input.Duration)) <= smokeTestDurationTolerance,
+		Message: fmt.Sprintf("expected %ds, got %.2fs", test.Input.Duration, playbackInfo.Duration),
+	})
+
+	outputPrefix := strings.TrimSuffix(video.S3Key, filepath.Ext(video.S3Key))
+	checks = append(checks, v.checkManifest(ctx, "hls_manifest", outputPrefix+"/master.m3u8", "#EXTM3U"))
+	for _, format := range test.Input.OutputFormats {
+		if format == models.FormatDASH {
+			checks = append(checks, v.checkManifest(ctx, "dash_manifest", outputPrefix+"/stream.mpd", "<?xml"))
+		}
+	}
+
+	return checks
+}
+
+// checkManifest verifies a manifest was uploaded and starts with prefix.
+func (v *videoFileUC) checkManifest(ctx context.Context, name, key, prefix string) models.SmokeTestCheck {
+	check := models.SmokeTestCheck{Name: name}
+
+	object, err := v.awsRepo.GetObject(ctx, v.cfg.S3.OutputBucket, key)
+	if err != nil {
+		check.Message = fmt.Sprintf("failed to fetch %s: %v", key, err)
+		return check
+	}
+	defer object.Body.Close()
+
+	head := make([]byte, len(prefix))
+	if _, err := io.ReadFull(object.Body, head); err != nil || !bytes.Equal(head, []byte(prefix)) {
+		check.Message = fmt.Sprintf("%s is not a valid manifest", key)
+		return check
+	}
+
+	check.Passed = true
+	return check
+}
+
+func (v *videoFileUC) uploadTestSource(ctx context.Context, path, key string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = v.awsRepo.PutObject(ctx, models.UploadInput{
+		File:       file,
+		Name:       filepath.Base(path),
+		MimeType:   "video/mp4",
+		Size:       fileInfo.Size(),
+		Key:        key,
+		BucketName: v.cfg.S3.InputBucket,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return fileInfo.Size(), nil
+}
+
+// generateTestSource renders a test pattern with a sine tone using ffmpeg's
+// lavfi sources.
+func generateTestSource(ctx context.Context, outputPath string, input *models.SmokeTestInput) error {
+	args := []string{
+		"-y",
+		"-f", "lavfi", "-i", fmt.Sprintf("testsrc2=size=%dx%d:rate=30:duration=%d", input.Width, input.Height, input.Duration),
+		"-f", "lavfi", "-i", fmt.Sprintf("sine=frequency=1000:sample_rate=48000:duration=%d", input.Duration),
+		"-c:v", "libx264", "-preset", "veryfast", "-pix_fmt", "yuv420p",
+		"-c:a", "aac", "-b:a", "128k",
+		"-shortest",
+		"-movflags", "+faststart",
+		outputPath,
+	}
+
+	output, err := exec.CommandContext(ctx, "ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg failed: %v, output: %s", err, string(output))
+	}
+	return nil
+}
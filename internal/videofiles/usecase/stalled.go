@@ -0,0 +1,61 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+const DefaultStallWindow = 10 * time.Minute
+
+// ListStalledVideos lists processing videos that made no progress within the
+// stall window and whose worker no longer sends heartbeats.
+func (v *videoFileUC) ListStalledVideos(ctx context.Context) ([]*models.VideoFile, error) {
+	videos, err := v.videoRepo.GetStaleVideos(ctx, time.Now().Add(-v.stallWindow()))
+	if err != nil {
+		v.logger.Errorf("ListStalledVideos - failed to fetch stale videos: %v", err)
+		return nil, fmt.Errorf("failed to fetch stalled videos: %v", err)
+	}
+
+	v.markStalled(ctx, videos...)
+
+	stalled := make([]*models.VideoFile, 0, len(videos))
+	for _, video := range videos {
+		if video.Stalled {
+			stalled = append(stalled, video)
+		}
+	}
+	return stalled, nil
+}
+
+// markStalled sets the Stalled flag of processing videos. A video whose
+// progress is older than the stall window is only slow while its worker is
+// still alive; it is stalled once the worker stopped sending heartbeats.
+func (v *videoFileUC) markStalled(ctx context.Context, videos ...*models.VideoFile) {
+	cutoff := time.Now().Add(-v.stallWindow())
+	for _, video := range videos {
+		if video.Status != models.JobStatusProcessing || video.ProgressUpdatedAt == nil || video.ProgressUpdatedAt.After(cutoff) {
+			continue
+		}
+		if video.WorkerID == nil {
+			video.Stalled = true
+			continue
+		}
+
+		alive, err := v.redisRepo.IsWorkerAlive(ctx, *video.WorkerID)
+		if err != nil {
+			v.logger.Warnf("Failed to check worker %s of video %s: %v", *video.WorkerID, video.VideoID, err)
+			continue
+		}
+		video.Stalled = !alive
+	}
+}
+
+func (v *videoFileUC) stallWindow() time.Duration {
+	if v.cfg.Worker.StallWindow <= 0 {
+		return DefaultStallWindow
+	}
+	return time.Duration(v.cfg.Worker.StallWindow) * time.Second
+}
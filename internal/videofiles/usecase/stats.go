@@ -0,0 +1,122 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+const (
+	// DefaultStatsWindow is the window of throughput stats without a range
+	DefaultStatsWindow = 24 * time.Hour
+	// MaxStatsBuckets bounds the range of one throughput query
+	MaxStatsBuckets = 24 * 31
+)
+
+// GetThroughputStats aggregates the job events of a time range into buckets of
+// the requested interval, broken down by profile.
+func (v *videoFileUC) GetThroughputStats(ctx context.Context, query *models.ThroughputQuery) (*models.ThroughputStats, error) {
+	interval := query.Interval
+	if interval == "" {
+		interval = models.StatsIntervalHour
+	}
+	var width time.Duration
+	switch interval {
+	case models.StatsIntervalHour:
+		width = time.Hour
+	case models.StatsIntervalDay:
+		width = 24 * time.Hour
+	default:
+		return nil, fmt.Errorf("invalid interval %q", interval)
+	}
+
+	to := query.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	from := query.From
+	if from.IsZero() {
+		from = to.Add(-DefaultStatsWindow)
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("from must be before to")
+	}
+	if to.Sub(from) > MaxStatsBuckets*width {
+		return nil, fmt.Errorf("range is limited to %d buckets", MaxStatsBuckets)
+	}
+
+	rows, err := v.videoRepo.GetJobThroughput(ctx, interval, from, to)
+	if err != nil {
+		v.logger.Errorf("GetThroughputStats - GetJobThroughput error: %v", err)
+		return nil, fmt.Errorf("failed to fetch throughput: %v", err)
+	}
+
+	stats := &models.ThroughputStats{
+		Interval: interval,
+		From:     from,
+		To:       to,
+		Buckets:  []*models.ThroughputBucket{},
+	}
+	var bucket *models.ThroughputBucket
+	var waitTotal, encodeTotal float64
+	var waitCount, encodeCount int
+	flush := func() {
+		if bucket == nil {
+			return
+		}
+		finished := bucket.Completed + bucket.Failed
+		bucket.JobsPerHour = float64(finished) / width.Hours()
+		bucket.FailureRate = ratio(float64(bucket.Failed), finished)
+		bucket.AvgQueueWait = ratio(waitTotal, waitCount)
+		bucket.AvgEncodeDuration = ratio(encodeTotal, encodeCount)
+		stats.Buckets = append(stats.Buckets, bucket)
+	}
+
+	// Rows come ordered by bucket
+	for _, row := range rows {
+		if bucket == nil || !bucket.Start.Equal(row.Bucket) {
+			flush()
+			bucket = &models.ThroughputBucket{Start: row.Bucket, Profiles: []*models.ProfileThroughput{}}
+			waitTotal, encodeTotal, waitCount, encodeCount = 0, 0, 0, 0
+		}
+		bucket.Completed += row.Finished - row.Failed
+		bucket.Failed += row.Failed
+		waitTotal += row.QueueWaitTotal
+		waitCount += row.QueueWaitCount
+		encodeTotal += row.EncodeTotal
+		encodeCount += row.EncodeCount
+		bucket.Profiles = append(bucket.Profiles, &models.ProfileThroughput{
+			Profile:           row.Profile,
+			Completed:         row.Finished - row.Failed,
+			Failed:            row.Failed,
+			AvgQueueWait:      ratio(row.QueueWaitTotal, row.QueueWaitCount),
+			AvgEncodeDuration: ratio(row.EncodeTotal, row.EncodeCount),
+		})
+	}
+	flush()
+	return stats, nil
+}
+
+func ratio(total float64, count int) float64 {
+	if count == 0 {
+		return 0
+	}
+	return total / float64(count)
+}
+
+// recordJobEvent adds a step of the job's lifecycle to the throughput stats.
+// Stats are best effort, so failures are only logged.
+func (v *videoFileUC) recordJobEvent(ctx context.Context, job *models.EncodeJob, event models.JobEventType) {
+	err := v.videoRepo.CreateJobEvent(ctx, &models.JobEvent{
+		JobID:   job.JobID,
+		VideoID: job.VideoID,
+		Event:   event,
+		JobType: job.Type,
+		Codec:   job.Codec,
+	})
+	if err != nil {
+		v.logger.Warnf("Failed to record %s event of job %s: %v", event, job.JobID, err)
+	}
+}
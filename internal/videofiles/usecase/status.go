@@ -0,0 +1,221 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/i18n"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+)
+
+// GetVideoStatus returns the processing state of a video of the current
+// user, combining the video, the events of its last job and the job's Redis
+// hash so clients do not have to query each of them.
+func (v *videoFileUC) GetVideoStatus(ctx context.Context, videoID uuid.UUID) (*models.VideoStatus, error) {
+	video, err := v.GetVideo(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.VideoStatus{
+		VideoID:   video.VideoID.String(),
+		Status:    video.Status,
+		Progress:  float64(video.Progress),
+		UpdatedAt: video.ProgressUpdatedAt,
+	}
+	events, err := v.videoRepo.GetLastJobEvents(ctx, videoID)
+	if err != nil {
+		v.logger.Errorf("GetVideoStatus - failed to fetch job events: %v", err)
+		return nil, fmt.Errorf("failed to fetch job events: %v", err)
+	}
+	if len(events) == 0 {
+		status.State = videoState(video)
+		return status, nil
+	}
+
+	last := events[len(events)-1]
+	status.JobID = last.JobID
+	status.JobType = last.JobType
+	for _, event := range events {
+		at := event.CreatedAt
+		switch event.Event {
+		case models.JobEventQueued:
+			status.QueuedAt = &at
+			status.StartedAt, status.CompletedAt = nil, nil
+		case models.JobEventStarted:
+			status.StartedAt = &at
+		case models.JobEventCompleted, models.JobEventFailed:
+			status.CompletedAt = &at
+		}
+	}
+
+	switch last.Event {
+	case models.JobEventQueued:
+		status.State = models.StateQueued
+	case models.JobEventStarted:
+		status.State = models.StateProcessing
+		if video.Stalled {
+			status.State = models.StateStalled
+		}
+	case models.JobEventCompleted:
+		status.State = models.StateReady
+		status.Progress = 100
+	case models.JobEventFailed:
+		status.State = models.StateFailed
+		if last.FailureReason == models.FailureMalware {
+			status.State = models.StateRejected
+		}
+		status.Error = &models.JobError{Reason: last.FailureReason}
+	}
+
+	// The hash has the stage and is updated more often than the video, but
+	// expires some time after the job finished
+	job, err := v.redisRepo.GetJobDetails(ctx, last.JobID)
+	if err == nil {
+		if status.State == models.StateProcessing || status.State == models.StateStalled {
+			status.Progress = job.Progress
+			status.Stage = job.Stage
+			status.StageProgress = job.StageProgress
+		}
+		if status.Error != nil {
+			if job.FailureReason != "" {
+				status.Error.Reason = job.FailureReason
+			}
+			status.Error.Message = job.FailureMessage
+		}
+	}
+	if status.Error != nil && status.Error.Reason != "" {
+		if catalog, err := i18n.Default(); err == nil {
+			status.Error.Description = catalog.FailureReason(utils.GetLocaleFromCtx(ctx), string(status.Error.Reason))
+		}
+	}
+
+	if status.State == models.StateQueued || status.State == models.StateProcessing {
+		var duration float64
+		if video.Duration != nil {
+			duration = float64(*video.Duration)
+		}
+		status.ETA = v.estimateRemaining(ctx, last, job, duration, status)
+	}
+	return status, nil
+}
+
+// videoState is the state of a video without recorded jobs, such as videos
+// processed before job events were recorded
+func videoState(video *models.VideoFile) models.ProcessingState {
+	switch video.Status {
+	case models.JobStatusCompleted:
+		return models.StateReady
+	case models.JobStatusFailed:
+		return models.StateFailed
+	case models.JobStatusRejected:
+		return models.StateRejected
+	case models.JobStatusProcessing:
+		if video.Stalled {
+			return models.StateStalled
+		}
+		return models.StateProcessing
+	default:
+		return models.StateUploaded
+	}
+}
+
+// estimateRemaining estimates the seconds until the job of last finishes.
+// Running jobs use the estimate of their worker when it has one. Otherwise
+// the job's own encode is estimated from the speed of recent encodes of its
+// profile, and a queued job adds the jobs ahead of it spread over the slots
+// of the live workers.
+func (v *videoFileUC) estimateRemaining(ctx context.Context, last *models.JobEvent, job *models.EncodeJob, duration float64, status *models.VideoStatus) *int64 {
+	now := time.Now()
+	if status.StartedAt != nil && job != nil && job.EstimatedCompletion != nil {
+		return etaSeconds(job.EstimatedCompletion.Sub(now).Seconds())
+	}
+
+	hardware := models.HardwareCPU
+	queue := v.cfg.Redis.JobQueueKey
+	if gpuQueue := videofiles.JobQueue(queue, last.Codec, true); gpuQueue != queue && status.StartedAt == nil {
+		// The job is on the GPU queue if it was routed there
+		if position, err := v.redisRepo.QueuePosition(ctx, gpuQueue, last.JobID); err == nil && position >= 0 {
+			hardware, queue = models.HardwareGPU, gpuQueue
+		}
+	}
+
+	throughput, err := v.videoRepo.GetEncodeThroughput(ctx, now.Add(-models.ETAHistoryWindow))
+	if err != nil {
+		v.logger.Warnf("Failed to fetch encode throughput for ETA of job %s: %v", last.JobID, err)
+	}
+	speed, ok := models.ThroughputSpeed(throughput, last.Codec, "", hardware)
+	if !ok {
+		speed = v.cfg.Pricing.Speeds[string(last.Codec)][string(hardware)]
+		if speed <= 0 {
+			speed = defaultEncodeSpeeds[last.Codec][hardware]
+		}
+	}
+
+	var elapsed time.Duration
+	if status.StartedAt != nil {
+		elapsed = now.Sub(*status.StartedAt)
+	}
+	remaining, ok := models.EstimateRemaining(elapsed, status.Progress, duration, speed)
+	if !ok {
+		return nil
+	}
+	if status.StartedAt == nil {
+		wait, ok := v.estimateQueueWait(ctx, queue, last, status, throughput)
+		if !ok {
+			return nil
+		}
+		remaining += wait
+	}
+	return etaSeconds(remaining)
+}
+
+// estimateQueueWait estimates the seconds until a worker starts the queued
+// job of last. With a browsable queue it is the jobs ahead of it times the
+// average job duration, divided over the worker slots; otherwise the
+// average queue wait of recent jobs less the time it has waited.
+func (v *videoFileUC) estimateQueueWait(ctx context.Context, queue string, last *models.JobEvent, status *models.VideoStatus, throughput []*models.EncodeThroughput) (float64, bool) {
+	now := time.Now()
+	rows, err := v.videoRepo.GetJobThroughput(ctx, models.StatsIntervalDay, now.Add(-models.ETAHistoryWindow), now)
+	if err != nil {
+		v.logger.Warnf("Failed to fetch throughput for ETA of job %s: %v", last.JobID, err)
+		return 0, false
+	}
+	var waitTotal, encodeTotal float64
+	var waitCount, encodeCount int
+	for _, row := range rows {
+		waitTotal += row.QueueWaitTotal
+		waitCount += row.QueueWaitCount
+		encodeTotal += row.EncodeTotal
+		encodeCount += row.EncodeCount
+	}
+
+	position, err := v.redisRepo.QueuePosition(ctx, queue, last.JobID)
+	if err == nil && position >= 0 && encodeCount > 0 {
+		slots := 0
+		if workers, err := v.redisRepo.ListWorkerStatuses(ctx); err == nil {
+			for _, worker := range workers {
+				if !worker.Draining {
+					slots += worker.Slots
+				}
+			}
+		}
+		return float64(position) / float64(max(slots, 1)) * encodeTotal / float64(encodeCount), true
+	}
+
+	if waitCount == 0 || status.QueuedAt == nil {
+		return 0, false
+	}
+	return max(waitTotal/float64(waitCount)-now.Sub(*status.QueuedAt).Seconds(), 0), true
+}
+
+// etaSeconds rounds an estimate up to whole seconds. Jobs running longer
+// than estimated are reported as about to finish rather than overdue.
+func etaSeconds(seconds float64) *int64 {
+	eta := int64(max(seconds, 1) + 0.5)
+	return &eta
+}
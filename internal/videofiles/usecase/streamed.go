@@ -0,0 +1,196 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+)
+
+const (
+	// UploadProgressInterval is how often the progress of a stream is stored
+	// while it is received
+	UploadProgressInterval = time.Second
+	// uploadProgressStale is how long the progress of an active stream may go
+	// without updates before the stream is taken to have ended, e.g. because
+	// the server receiving it went away
+	uploadProgressStale = 10 * UploadProgressInterval
+)
+
+// StreamUpload receives the file of a chunked upload as one stream starting
+// at offset, which must be where the upload resumes. The stream is cut into
+// chunks that are stored as they fill, so an interrupted stream loses at most
+// the chunk in flight, and its progress is stored for GetUploadProgress as it
+// arrives. The upload is then completed like any other chunked upload, which
+// checks the file against its checksum.
+func (v *videoFileUC) StreamUpload(ctx context.Context, uploadID string, offset int64, body io.Reader) (*models.UploadProgress, error) {
+	upload, err := v.getChunkedUpload(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	resume := resumeOffset(upload)
+	if offset != resume {
+		return nil, &videofiles.UploadOffsetError{Message: "stream does not start where the upload resumes", Offset: resume}
+	}
+
+	tracker := &uploadTracker{
+		v:        v,
+		ctx:      ctx,
+		started:  time.Now(),
+		startAt:  offset,
+		progress: &models.UploadProgress{UploadID: uploadID, FileSize: upload.Video.FileSize, Received: offset, Offset: offset, Active: true},
+	}
+	tracker.save()
+	defer tracker.finish()
+
+	reader := &progressReader{r: body, tracker: tracker}
+	buf := make([]byte, upload.ChunkSize)
+	for index := int(offset / upload.ChunkSize); index < upload.TotalChunks; index++ {
+		size := upload.ChunkSize
+		if index == upload.TotalChunks-1 {
+			size = upload.Video.FileSize - int64(index)*upload.ChunkSize
+		}
+		n, err := io.ReadFull(reader, buf[:size])
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// The client ended the stream early, e.g. to pause; it resumes at
+			// the end of the last full chunk
+			v.logger.Infof("StreamUpload - stream of upload %s ended at %d of %d bytes", uploadID, tracker.progress.Offset+int64(n), upload.Video.FileSize)
+			return tracker.finish(), nil
+		}
+		if err != nil {
+			v.logger.Warnf("StreamUpload - failed to read upload %s: %v", uploadID, err)
+			return nil, fmt.Errorf("failed to read upload: %v", err)
+		}
+
+		sum := sha256.Sum256(buf[:n])
+		if err = v.storeChunk(ctx, upload, index, buf[:n], hex.EncodeToString(sum[:])); err != nil {
+			return nil, err
+		}
+		tracker.stored(resumeOffset(upload))
+	}
+
+	if n, _ := reader.Read(make([]byte, 1)); n > 0 {
+		return nil, fmt.Errorf("stream is longer than the file size of %d bytes", upload.Video.FileSize)
+	}
+	return tracker.finish(), nil
+}
+
+// GetUploadProgress returns how far an upload has come, whether it is being
+// streamed or sent in chunks
+func (v *videoFileUC) GetUploadProgress(ctx context.Context, uploadID string) (*models.UploadProgress, error) {
+	upload, err := v.getChunkedUpload(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	progress, err := v.redisRepo.GetUploadProgress(ctx, uploadID)
+	if err != nil {
+		// Nothing was streamed yet
+		progress = &models.UploadProgress{UploadID: uploadID, FileSize: upload.Video.FileSize}
+	}
+	progress.Offset = resumeOffset(upload)
+	if progress.Active && time.Since(progress.UpdatedAt) > uploadProgressStale {
+		progress.Active = false
+	}
+	if !progress.Active {
+		progress.Received = receivedBytes(upload)
+		progress.BytesPerSecond = 0
+	}
+	progress.Percent = uploadPercent(progress.Received, progress.FileSize)
+	return progress, nil
+}
+
+// uploadTracker stores the progress of a stream at most once per
+// UploadProgressInterval. Failing to store it is logged and does not fail
+// the upload.
+type uploadTracker struct {
+	v        *videoFileUC
+	ctx      context.Context
+	started  time.Time
+	startAt  int64
+	lastSave time.Time
+	progress *models.UploadProgress
+}
+
+func (t *uploadTracker) received(n int) {
+	t.progress.Received += int64(n)
+	if time.Since(t.lastSave) >= UploadProgressInterval {
+		t.save()
+	}
+}
+
+// stored records that the stream was stored up to offset
+func (t *uploadTracker) stored(offset int64) {
+	t.progress.Offset = offset
+	t.save()
+}
+
+// finish marks the stream ended; the bytes of a partly received chunk are
+// discarded, so Received drops back to Offset
+func (t *uploadTracker) finish() *models.UploadProgress {
+	if t.progress.Active {
+		t.progress.Active = false
+		t.progress.Received = t.progress.Offset
+		t.progress.BytesPerSecond = 0
+		t.save()
+	}
+	return t.progress
+}
+
+func (t *uploadTracker) save() {
+	now := time.Now()
+	if elapsed := now.Sub(t.started).Seconds(); elapsed > 0 && t.progress.Active {
+		t.progress.BytesPerSecond = int64(float64(t.progress.Received-t.startAt) / elapsed)
+	}
+	t.progress.Percent = uploadPercent(t.progress.Received, t.progress.FileSize)
+	t.progress.UpdatedAt = now
+	t.lastSave = now
+	// The progress outlives a request that was cancelled
+	if err := t.v.redisRepo.SetUploadProgress(context.WithoutCancel(t.ctx), t.progress, ChunkedUploadTTL); err != nil {
+		t.v.logger.Warnf("StreamUpload - failed to store progress of upload %s: %v", t.progress.UploadID, err)
+	}
+}
+
+// progressReader reports the bytes read from r to its tracker
+type progressReader struct {
+	r       io.Reader
+	tracker *uploadTracker
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.tracker.received(n)
+	}
+	return n, err
+}
+
+// receivedBytes is the size of the chunks received so far
+func receivedBytes(upload *models.ChunkedUpload) int64 {
+	var total int64
+	for index := range upload.ChunkChecksums {
+		if index < 0 || index >= upload.TotalChunks {
+			continue
+		}
+		size := upload.ChunkSize
+		if index == upload.TotalChunks-1 {
+			size = upload.Video.FileSize - int64(index)*upload.ChunkSize
+		}
+		total += size
+	}
+	return total
+}
+
+func uploadPercent(received, size int64) float64 {
+	if size <= 0 {
+		return 0
+	}
+	return math.Round(float64(received)*10000/float64(size)) / 100
+}
@@ -0,0 +1,215 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultTrashRetention is how long deleted videos can be restored when
+	// the trash config does not set it
+	DefaultTrashRetention = 30 * 24 * time.Hour
+	// DefaultTrashPurgeInterval is used when the trash config does not set one
+	DefaultTrashPurgeInterval = time.Hour
+	// trashPurgeBatch caps the videos purged at once
+	trashPurgeBatch = 100
+)
+
+// trashRetention returns how long a deleted video stays in the trash
+func trashRetention(cfg config.TrashConfig) time.Duration {
+	if cfg.RetentionDays > 0 {
+		return time.Duration(cfg.RetentionDays) * 24 * time.Hour
+	}
+	return DefaultTrashRetention
+}
+
+// markPurgeAt sets when each of the trashed videos will be purged
+func (v *videoFileUC) markPurgeAt(videos ...*models.VideoFile) {
+	retention := trashRetention(v.cfg.Trash)
+	for _, video := range videos {
+		if video.DeletedAt != nil {
+			purgeAt := video.DeletedAt.Add(retention)
+			video.PurgeAt = &purgeAt
+		}
+	}
+}
+
+// DeleteVideo moves a video of the user to the trash. It stops being listed
+// and played, and is purged once the trash retention has passed unless it is
+// restored first. Its files keep counting towards the storage quota until
+// then.
+func (v *videoFileUC) DeleteVideo(ctx context.Context, videoID uuid.UUID) (*models.VideoFile, error) {
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		v.logger.Errorf("DeleteVideo - failed to get user from context: %v", err)
+		return nil, err
+	}
+	if videoID == uuid.Nil {
+		return nil, fmt.Errorf("invalid video id: cannot be empty")
+	}
+	video, err := v.GetVideo(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+	if video.DeletedAt != nil {
+		return nil, fmt.Errorf("video is already in the trash")
+	}
+
+	deletedAt, err := v.videoRepo.TrashVideo(ctx, user.UserID, videoID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("video is already in the trash")
+		}
+		v.logger.Errorf("DeleteVideo - TrashVideo error: %v", err)
+		return nil, fmt.Errorf("failed to delete video: %w", err)
+	}
+	video.DeletedAt = &deletedAt
+	v.markPurgeAt(video)
+	return video, nil
+}
+
+// RestoreVideo takes a video of the user out of the trash
+func (v *videoFileUC) RestoreVideo(ctx context.Context, videoID uuid.UUID) (*models.VideoFile, error) {
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		v.logger.Errorf("RestoreVideo - failed to get user from context: %v", err)
+		return nil, err
+	}
+	video, err := v.GetVideo(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+	if video.DeletedAt == nil {
+		return nil, fmt.Errorf("video is not in the trash")
+	}
+
+	if err := v.videoRepo.RestoreVideo(ctx, user.UserID, videoID); err != nil {
+		v.logger.Errorf("RestoreVideo - RestoreVideo error: %v", err)
+		return nil, fmt.Errorf("failed to restore video: %w", err)
+	}
+	video.DeletedAt = nil
+	return video, nil
+}
+
+// ListTrash lists the videos in the user's trash with when each is purged
+func (v *videoFileUC) ListTrash(ctx context.Context, pagination *utils.Pagination) (*models.VideoList, error) {
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		v.logger.Errorf("ListTrash - failed to get user from context: %v", err)
+		return nil, err
+	}
+	if pagination == nil {
+		pagination = &utils.Pagination{
+			Page: 1,
+			Size: 10,
+		}
+	}
+	if pagination.Page < 1 {
+		pagination.Page = 1
+	}
+	if pagination.Size < 1 || pagination.Size > 100 {
+		pagination.Size = 10
+	}
+
+	videos, err := v.videoRepo.GetTrashedVideos(ctx, user.UserID, pagination)
+	if err != nil {
+		v.logger.Errorf("ListTrash - GetTrashedVideos error: %v", err)
+		return nil, fmt.Errorf("failed to fetch trashed videos: %w", err)
+	}
+	v.markPurgeAt(videos.Videos...)
+	return videos, nil
+}
+
+// TrashPurgeJob deletes videos for good once they have been in the trash for
+// the retention window. Their rows go right away; their objects are queued
+// for the deletion job.
+type TrashPurgeJob struct {
+	cfg       *config.Config
+	videoRepo videofiles.Repository
+	redisRepo videofiles.RedisRepository
+	logger    logger.Logger
+}
+
+// NewTrashPurgeJob creates a job purging expired videos from the trash
+func NewTrashPurgeJob(cfg *config.Config, videoRepo videofiles.Repository, redisRepo videofiles.RedisRepository, log logger.Logger) *TrashPurgeJob {
+	return &TrashPurgeJob{
+		cfg:       cfg,
+		videoRepo: videoRepo,
+		redisRepo: redisRepo,
+		logger:    log,
+	}
+}
+
+// Run purges once immediately and then every purge interval until ctx is done
+func (j *TrashPurgeJob) Run(ctx context.Context) {
+	interval := time.Duration(j.cfg.Trash.PurgeInterval) * time.Second
+	if interval <= 0 {
+		interval = DefaultTrashPurgeInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		j.Purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge deletes every video past the trash retention. Failures are logged
+// and retried on the next run.
+func (j *TrashPurgeJob) Purge(ctx context.Context) {
+	cutoff := time.Now().Add(-trashRetention(j.cfg.Trash))
+	for ctx.Err() == nil {
+		videos, err := j.videoRepo.GetExpiredTrashedVideos(ctx, cutoff, trashPurgeBatch)
+		if err != nil {
+			j.logger.Errorf("TrashPurgeJob - GetExpiredTrashedVideos error: %v", err)
+			return
+		}
+
+		purged := 0
+		for _, video := range videos {
+			if j.purge(ctx, video, cutoff) {
+				purged++
+			}
+		}
+		if purged > 0 {
+			j.logger.Infof("Purged %d videos from the trash", purged)
+		}
+		// Stop when a batch made no progress so failing videos are not
+		// retried in a loop
+		if len(videos) < trashPurgeBatch || purged == 0 {
+			return
+		}
+	}
+}
+
+func (j *TrashPurgeJob) purge(ctx context.Context, video *models.VideoFile, cutoff time.Time) bool {
+	if _, err := j.videoRepo.PurgeTrashedVideo(ctx, video.VideoID, cutoff); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			j.logger.Errorf("TrashPurgeJob - failed to purge video %s: %v", video.VideoID, err)
+		}
+		return false
+	}
+	deletion := newVideoDeletion(video, j.cfg.S3.OutputBucket)
+	if err := j.redisRepo.EnqueueVideoDeletion(ctx, deletion); err != nil {
+		j.logger.Errorf("TrashPurgeJob - objects of purged video %s under %s/%s could not be queued for deletion: %v",
+			video.VideoID, deletion.OutputBucket, deletion.OutputPrefix, err)
+	}
+	return true
+}
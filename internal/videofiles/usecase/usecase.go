@@ -2,33 +2,51 @@ package usecase
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"log"
+	"net/url"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/drm"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/kms"
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/google/uuid"
 )
 
+const (
+	// analyticsBeaconPath is where players report playback events
+	analyticsBeaconPath = "/api/v1/analytics"
+	heartbeatInterval   = 10
+)
+
 type videoFileUC struct {
 	cfg       *config.Config
 	videoRepo videofiles.Repository
 	redisRepo videofiles.RedisRepository
 	awsRepo   videofiles.AWSRepository
+	keys      kms.KeyManager
 	logger    logger.Logger
 }
 
+// NewVideoUseCase creates the video use case. keys may be nil, in which case
+// uploads requesting encryption are rejected.
 func NewVideoUseCase(
 	cfg *config.Config,
 	videoRepo videofiles.Repository,
 	redisRepo videofiles.RedisRepository,
 	awsRepo videofiles.AWSRepository,
+	keys kms.KeyManager,
 	log logger.Logger,
 ) videofiles.UseCase {
 	return &videoFileUC{
@@ -36,6 +54,7 @@ func NewVideoUseCase(
 		videoRepo: videoRepo,
 		redisRepo: redisRepo,
 		awsRepo:   awsRepo,
+		keys:      keys,
 		logger:    log,
 	}
 }
@@ -79,6 +98,16 @@ func (v *videoFileUC) CreateVideo(ctx context.Context, input *models.VideoUpload
 		v.logger.Errorf("UploadVideo - ValidateStruct error: %v", err)
 		return nil, fmt.Errorf("invalid input: %v", err)
 	}
+	if input.SourceURL != "" {
+		return nil, fmt.Errorf("source urls are only accepted when creating a job")
+	}
+	kind, err := assetKind(input)
+	if err != nil {
+		return nil, err
+	}
+	if input.Duration == 0 && kind != models.AssetImage {
+		return nil, fmt.Errorf("invalid input: duration is required")
+	}
 	duration := &input.Duration
 	videoFile := &models.VideoFile{
 		UserID:   user.UserID,
@@ -89,6 +118,11 @@ func (v *videoFileUC) CreateVideo(ctx context.Context, input *models.VideoUpload
 		Status:   models.JobStatusQueued,
 		S3Bucket: v.cfg.S3.InputBucket,
 		Format:   input.Format,
+		Kind:     kind,
+	}
+	if err = setVisibility(videoFile, input.Visibility); err != nil {
+		v.logger.Errorf("UploadVideo - setVisibility error: %v", err)
+		return nil, err
 	}
 	videoFile, err = v.videoRepo.CreateVideo(ctx, videoFile)
 	if err != nil {
@@ -107,46 +141,76 @@ func (v *videoFileUC) CreateJob(ctx context.Context, input *models.VideoUploadIn
 	log.Println("input", input)
 	if err = utils.ValidateStruct(ctx, input); err != nil {
 		v.logger.Errorf("UploadVideo - ValidateStruct error: %v", err)
-		return nil, fmt.Errorf("invalid input: %v", err)
-	}
-	if len(input.Qualities) == 0 {
-		input.Qualities = utils.GetDefaultQualities()
-	} else {
-		for i, quality := range input.Qualities {
-			if quality.MaxBitrate <= 0 {
-				quality.MaxBitrate = utils.GetDefaultMaxBitrate(quality.Resolution)
-			}
-			if quality.MinBitrate <= 0 {
-				quality.MinBitrate = utils.GetDefaultMinBitrate(quality.Resolution)
-			}
-			if quality.Bitrate < quality.MinBitrate || quality.Bitrate > quality.MaxBitrate {
-				input.Qualities[i].Bitrate = utils.AdjustBitrateToRange(
-					quality.Bitrate,
-					quality.MinBitrate,
-					quality.MaxBitrate,
-				)
-			}
-		}
+		return nil, fmt.Errorf("%w: %v", videofiles.ErrInvalidInput, err)
 	}
-	if len(input.OutputFormats) == 0 {
-		input.OutputFormats = []models.PlaybackFormat{
-			models.FormatHLS,
+	if input.SourceURL != "" {
+		if err = prepareRemoteSource(input); err != nil {
+			return nil, fmt.Errorf("%w: %v", videofiles.ErrInvalidInput, err)
 		}
+	} else if input.Duration == 0 && input.AssetKind() != models.AssetImage {
+		return nil, fmt.Errorf("%w: duration is required", videofiles.ErrInvalidInput)
 	}
-	if input.Codec == "" {
-		input.Codec = models.CodecH264
+	return v.createJob(ctx, user.UserID, input, fmt.Sprintf("uploads/%s/%s", user.UserID, input.FileName))
+}
+
+// createJob creates the video record of a source uploaded to s3Key in the
+// input bucket and queues its encode job.
+func (v *videoFileUC) createJob(ctx context.Context, userID uuid.UUID, input *models.VideoUploadInput, s3Key string) (*models.EncodeJob, error) {
+	applyJobDefaults(input)
+	kind, err := assetKind(input)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", videofiles.ErrInvalidInput, err)
 	}
 
 	videoFile := &models.VideoFile{
-		UserID:   user.UserID,
+		UserID:   userID,
 		FileName: input.FileName,
 		FileSize: input.FileSize,
 		Duration: &input.Duration,
 		Progress: 0,
-		S3Key:    fmt.Sprintf("uploads/%s/%s", user.UserID, input.FileName),
+		S3Key:    s3Key,
 		Status:   models.JobStatusQueued,
 		S3Bucket: v.cfg.S3.InputBucket,
 		Format:   input.Format,
+		Kind:     kind,
+	}
+	err = setVisibility(videoFile, input.Visibility)
+	if err != nil {
+		v.logger.Errorf("CreateJob - setVisibility error: %v", err)
+		return nil, fmt.Errorf("%w: %v", videofiles.ErrInvalidInput, err)
+	}
+	if input.Encrypt {
+		if v.keys == nil {
+			return nil, kms.ErrNotConfigured
+		}
+		dataKey, err := v.keys.GenerateDataKey(ctx)
+		if err != nil {
+			v.logger.Errorf("CreateJob - GenerateDataKey error: %v", err)
+			return nil, fmt.Errorf("failed to generate data key: %w", err)
+		}
+		videoFile.Encrypted = true
+		videoFile.EncryptedDataKey = dataKey.Ciphertext
+	}
+	if input.EnableDRM && v.cfg.DRM.KeyServerURL == "" {
+		return nil, drm.ErrNotConfigured
+	}
+	if input.EnableDRM && input.LowLatencyHLS {
+		// LL-HLS renditions are packaged without mp4dash and cannot be encrypted
+		return nil, fmt.Errorf("%w: low latency HLS cannot be combined with DRM", videofiles.ErrInvalidInput)
+	}
+	if err := validateBumpers(userID, input.IntroS3Key, input.OutroS3Key); err != nil {
+		return nil, fmt.Errorf("%w: %v", videofiles.ErrInvalidInput, err)
+	}
+	if err := models.ValidateChapters(input.Chapters); err != nil {
+		return nil, fmt.Errorf("%w: %v", videofiles.ErrInvalidInput, err)
+	}
+	// The estimate is of a video ladder, it means nothing for other assets
+	var estimate *models.OutputEstimate
+	if kind == models.AssetVideo {
+		estimate = v.estimateOutput(ctx, userID, input)
+		if estimate.ExceedsQuota {
+			v.logger.Warnf("CreateJob - %s for user %s", estimate.Warning, userID)
+		}
 	}
 	videoFile, err = v.videoRepo.CreateVideo(ctx, videoFile)
 	if err != nil {
@@ -155,12 +219,12 @@ func (v *videoFileUC) CreateJob(ctx context.Context, input *models.VideoUploadIn
 	}
 	job := &models.EncodeJob{
 		JobID:                  uuid.New().String(),
-		UserID:                 user.UserID.String(),
+		UserID:                 userID.String(),
 		VideoID:                videoFile.VideoID.String(),
 		InputS3Key:             videoFile.S3Key,
 		InputBucket:            videoFile.S3Bucket,
 		OutputBucket:           v.cfg.S3.OutputBucket,
-		OutputS3Key:            fmt.Sprintf("uploads/%s/%s", user.UserID, input.FileName),
+		OutputS3Key:            s3Key,
 		Progress:               0,
 		Qualities:              input.Qualities,
 		OutputFormats:          input.OutputFormats,
@@ -168,14 +232,96 @@ func (v *videoFileUC) CreateJob(ctx context.Context, input *models.VideoUploadIn
 		Status:                 videoFile.Status,
 		Codec:                  input.Codec,
 		StartedAt:              time.Now(),
-	}
-	if err = v.redisRepo.EnqueueJob(ctx, v.cfg.Redis.JobQueueKey, job); err != nil {
+		EncryptedDataKey:       videoFile.EncryptedDataKey,
+		GenerateCaptions:       input.GenerateCaptions,
+		CaptionLanguage:        input.CaptionLanguage,
+		EnableDownloads:        input.EnableDownloads,
+		DownloadQuality:        input.DownloadQuality,
+		EnableDRM:              input.EnableDRM,
+		Thumbnails:             input.Thumbnails,
+		PreviewFormat:          input.PreviewFormat,
+		LowLatencyHLS:          input.LowLatencyHLS,
+		IntegrityManifest:      input.IntegrityManifest,
+		NotBefore:              input.NotBefore,
+		IntroS3Key:             input.IntroS3Key,
+		OutroS3Key:             input.OutroS3Key,
+		Chapters:               input.Chapters,
+		SourceURL:              input.SourceURL,
+		BypassCache:            input.BypassCache,
+		Type:                   kind.JobType(),
+	}
+	queue := v.cfg.Redis.JobQueueKey
+	if kind == models.AssetVideo {
+		queue = v.jobQueue(ctx, job.Codec)
+	}
+	// Recorded first so a worker cannot start the job before it is queued
+	v.recordJobEvent(ctx, job, models.JobEventQueued)
+	if err = v.redisRepo.EnqueueJob(ctx, queue, job); err != nil {
 		v.logger.Errorf("UploadVideo - EnqueueJob error: %v", err)
 		return nil, fmt.Errorf("failed to queue the job :%v", err)
 	}
+	job.StorageEstimate = estimate
 	return job, nil
 }
 
+// assetKind returns the kind of asset input uploads, detecting it when it is
+// not given, and refuses options that only apply to videos for other kinds.
+// Audio and image jobs run no video encoder, so they are never routed to GPU
+// workers.
+func assetKind(input *models.VideoUploadInput) (models.AssetKind, error) {
+	kind := input.AssetKind()
+	if kind == models.AssetVideo {
+		return kind, nil
+	}
+
+	switch {
+	case input.Encrypt:
+		return "", fmt.Errorf("%s files cannot be encrypted", kind)
+	case input.EnableDRM:
+		return "", fmt.Errorf("%s files cannot be DRM protected", kind)
+	case input.LowLatencyHLS:
+		return "", fmt.Errorf("low latency HLS is only available for videos")
+	case input.IntroS3Key != "" || input.OutroS3Key != "":
+		return "", fmt.Errorf("intros and outros can only be added to videos")
+	case input.GenerateCaptions:
+		return "", fmt.Errorf("captions can only be generated for videos")
+	}
+	return kind, nil
+}
+
+// applyJobDefaults fills in the quality ladder, output formats and codec a
+// job uses when the request leaves them out, and clamps bitrates to the
+// range of their resolution.
+func applyJobDefaults(input *models.VideoUploadInput) {
+	if len(input.Qualities) == 0 {
+		input.Qualities = utils.GetDefaultQualities()
+	} else {
+		for i, quality := range input.Qualities {
+			if quality.MaxBitrate <= 0 {
+				quality.MaxBitrate = utils.GetDefaultMaxBitrate(quality.Resolution)
+			}
+			if quality.MinBitrate <= 0 {
+				quality.MinBitrate = utils.GetDefaultMinBitrate(quality.Resolution)
+			}
+			if quality.Bitrate < quality.MinBitrate || quality.Bitrate > quality.MaxBitrate {
+				input.Qualities[i].Bitrate = utils.AdjustBitrateToRange(
+					quality.Bitrate,
+					quality.MinBitrate,
+					quality.MaxBitrate,
+				)
+			}
+		}
+	}
+	if len(input.OutputFormats) == 0 {
+		input.OutputFormats = []models.PlaybackFormat{
+			models.FormatHLS,
+		}
+	}
+	if input.Codec == "" {
+		input.Codec = models.CodecH264
+	}
+}
+
 func (v *videoFileUC) GetVideo(ctx context.Context, videoID uuid.UUID) (*models.VideoFile, error) {
 	if videoID == uuid.Nil {
 		return nil, fmt.Errorf("invalid video id: cannot be empty")
@@ -193,7 +339,7 @@ func (v *videoFileUC) GetVideo(ctx context.Context, videoID uuid.UUID) (*models.
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			v.logger.Warnf("Video not found with ID: %s", videoID.String())
-			return nil, fmt.Errorf("video not found")
+			return nil, videofiles.ErrVideoNotFound
 		}
 		v.logger.Errorf("GetVideo - failed to fetch video: %v", err)
 		return nil, fmt.Errorf("failed to fetch video: %v", err)
@@ -201,13 +347,16 @@ func (v *videoFileUC) GetVideo(ctx context.Context, videoID uuid.UUID) (*models.
 
 	if video.UserID != user.UserID {
 		v.logger.Warnf("User %s is not authorized to access video %s", user.UserID, videoID.String())
-		return nil, fmt.Errorf("unauthorized access to video")
+		return nil, videofiles.ErrVideoForbidden
 	}
 
+	v.markStalled(ctx, video)
 	return video, nil
 }
 
-func (v *videoFileUC) ListVideos(ctx context.Context, pagination *utils.Pagination) (*models.VideoList, error) {
+// ListVideos lists the videos of the current user, restricted to a folder
+// when folderID is set.
+func (v *videoFileUC) ListVideos(ctx context.Context, folderID *uuid.UUID, pagination *utils.Pagination) (*models.VideoList, error) {
 	user, err := utils.GetUserFromCtx(ctx)
 	if err != nil {
 		v.logger.Errorf("GetVideo - failed to get user from context: %v", err)
@@ -232,7 +381,13 @@ func (v *videoFileUC) ListVideos(ctx context.Context, pagination *utils.Paginati
 		pagination.Size,
 	)
 
-	videos, err := v.videoRepo.GetVideos(ctx, user.UserID, pagination)
+	if folderID != nil {
+		if _, err = v.getFolder(ctx, *folderID, user.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	videos, err := v.videoRepo.GetVideos(ctx, user.UserID, folderID, pagination)
 	if err != nil {
 		v.logger.Errorf("ListVideos - failed to fetch videos for user %s: %v",
 			user.UserID.String(),
@@ -240,6 +395,7 @@ func (v *videoFileUC) ListVideos(ctx context.Context, pagination *utils.Paginati
 		)
 		return nil, fmt.Errorf("failed to fetch videos: %v", err)
 	}
+	v.markStalled(ctx, videos.Videos...)
 	v.logger.Infof("Successfully fetched %d videos for user %s (total: %d, page: %d/%d)",
 		len(videos.Videos),
 		user.UserID.String(),
@@ -250,7 +406,9 @@ func (v *videoFileUC) ListVideos(ctx context.Context, pagination *utils.Paginati
 	return videos, nil
 }
 
-func (v *videoFileUC) SearchVideos(ctx context.Context, query string, pagination *utils.Pagination) (*models.VideoList, error) {
+// SearchVideos returns the user's videos matching query, most relevant first.
+// The query supports quoted phrases, OR and -word exclusions.
+func (v *videoFileUC) SearchVideos(ctx context.Context, query string, highlight bool, pagination *utils.Pagination) (*models.VideoList, error) {
 	user, err := utils.GetUserFromCtx(ctx)
 	if err != nil {
 		v.logger.Errorf("GetVideo - failed to get user from context: %v", err)
@@ -271,7 +429,7 @@ func (v *videoFileUC) SearchVideos(ctx context.Context, query string, pagination
 	if pagination.Size < 1 || pagination.Size > 100 {
 		pagination.Size = 10
 	}
-	videos, err := v.videoRepo.GetVideosByQuery(ctx, user.UserID, query, pagination)
+	videos, err := v.videoRepo.GetVideosByQuery(ctx, user.UserID, query, highlight, pagination)
 	if err != nil {
 		v.logger.Errorf("SearchVideos - failed to search videos: %v", err)
 		return nil, fmt.Errorf("failed to search videos: %v", err)
@@ -279,22 +437,6 @@ func (v *videoFileUC) SearchVideos(ctx context.Context, query string, pagination
 	return videos, nil
 }
 
-func (v *videoFileUC) DeleteVideo(ctx context.Context, videoID uuid.UUID) error {
-	user, err := utils.GetUserFromCtx(ctx)
-	if err != nil {
-		v.logger.Errorf("GetVideo - failed to get user from context: %v", err)
-		return err
-	}
-	if videoID == uuid.Nil {
-		return fmt.Errorf("invalid video id: cannot be empty")
-	}
-	if err = v.videoRepo.DeleteVideo(ctx, user.UserID, videoID); err != nil {
-		v.logger.Errorf("DeleteVideo - failed to delete video: %v", err)
-		return fmt.Errorf("failed to delete video: %v", err)
-	}
-	return nil
-}
-
 func (v *videoFileUC) UpdateVideo(ctx context.Context, video *models.VideoFile) error {
 	user, err := utils.GetUserFromCtx(ctx)
 	if err != nil {
@@ -303,7 +445,7 @@ func (v *videoFileUC) UpdateVideo(ctx context.Context, video *models.VideoFile)
 	}
 	if video.UserID != user.UserID {
 		v.logger.Warnf("User %s is not authorized to update video %s", user.UserID, video.VideoID.String())
-		return fmt.Errorf("unauthorized access to video")
+		return videofiles.ErrVideoForbidden
 	}
 	if _, err = v.videoRepo.UpdateVideo(ctx, video); err != nil {
 		v.logger.Errorf("UpdateVideo - failed to update video: %v", err)
@@ -312,29 +454,292 @@ func (v *videoFileUC) UpdateVideo(ctx context.Context, video *models.VideoFile)
 	return nil
 }
 
-func (v *videoFileUC) GetPlaybackInfo(ctx context.Context, videoID uuid.UUID) (*models.PlaybackInfo, error) {
-	user, err := utils.GetUserFromCtx(ctx)
+// GetPlaybackInfo returns the playback info of a video the caller may view.
+// The caller may be anonymous, in which case only public videos and unlisted
+// ones with a matching share token are returned.
+func (v *videoFileUC) GetPlaybackInfo(ctx context.Context, videoID uuid.UUID, shareToken string) (*models.PlaybackInfo, error) {
+	_, playbackInfo, err := v.getPlayableVideo(ctx, videoID, shareToken)
 	if err != nil {
-		v.logger.Errorf("GetVideo - failed to get user from context: %v", err)
-		return nil, fmt.Errorf("GetVideo - failed to get user from context:  %v", err)
+		return nil, err
+	}
+	return playbackInfo, nil
+}
+
+// GetPlayerConfig returns the playback details of a video in a single
+// response a web player can be initialized from.
+func (v *videoFileUC) GetPlayerConfig(ctx context.Context, videoID uuid.UUID, shareToken string) (*models.PlayerConfig, error) {
+	video, playbackInfo, err := v.getPlayableVideo(ctx, videoID, shareToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Beacons of unlisted videos are authorized by the share token as well
+	var tokenQuery string
+	if shareToken != "" {
+		tokenQuery = "?token=" + url.QueryEscape(shareToken)
+	}
+
+	config := &models.PlayerConfig{
+		VideoID:   playbackInfo.VideoID,
+		Title:     playbackInfo.Title,
+		Duration:  playbackInfo.Duration,
+		Poster:    playbackInfo.Thumbnail,
+		Preview:   playbackInfo.Preview,
+		Sources:   []models.PlayerSource{},
+		Qualities: []models.PlayerQuality{},
+		Captions:  []models.PlayerCaption{},
+		Chapters:  []models.PlayerChapter{},
+		Analytics: models.PlayerAnalytics{
+			ViewURL:           analyticsBeaconPath + "/views" + tokenQuery,
+			SessionStartURL:   analyticsBeaconPath + "/sessions/start" + tokenQuery,
+			SessionEndURL:     analyticsBeaconPath + "/sessions/end" + tokenQuery,
+			HeartbeatURL:      analyticsBeaconPath + "/heartbeats" + tokenQuery,
+			HeartbeatInterval: heartbeatInterval,
+		},
+	}
+
+	if src := playbackInfo.GetPlaybackURL(models.FormatHLS, models.QualityMaster); src != "" {
+		config.Sources = append(config.Sources, models.PlayerSource{Src: src, Type: "application/x-mpegURL", Format: models.FormatHLS})
+	}
+	if src := playbackInfo.GetPlaybackURL(models.FormatDASH, models.QualityMaster); src != "" {
+		config.Sources = append(config.Sources, models.PlayerSource{Src: src, Type: "application/dash+xml", Format: models.FormatDASH})
+	}
+
+	for _, quality := range []models.VideoQuality{models.Quality1080P, models.Quality720P, models.Quality480P, models.Quality360P} {
+		if info, ok := playbackInfo.Qualities[quality]; ok {
+			config.Qualities = append(config.Qualities, models.PlayerQuality{
+				Name:       quality,
+				Resolution: info.Resolution,
+				Bitrate:    info.Bitrate,
+			})
+		}
+	}
+
+	for i, subtitleURL := range playbackInfo.Subtitles {
+		language, label := subtitleLanguage(subtitleURL, i)
+		config.Captions = append(config.Captions, models.PlayerCaption{
+			Src:      subtitleURL,
+			Language: language,
+			Label:    label,
+			Kind:     "subtitles",
+		})
+	}
+
+	for _, chapter := range playbackInfo.Chapters {
+		config.Chapters = append(config.Chapters, models.PlayerChapter{
+			Title:     chapter.Title,
+			StartTime: chapter.Start,
+		})
+	}
+
+	if video.Encrypted || playbackInfo.DRM != nil {
+		config.Protection = &models.PlayerProtection{
+			Encrypted:       video.Encrypted,
+			WithCredentials: video.Encrypted,
+			DRM:             playbackInfo.DRM,
+		}
+	}
+
+	return config, nil
+}
+
+// getPlayableVideo loads a video and its playback info after checking the
+// caller may view it.
+func (v *videoFileUC) getPlayableVideo(ctx context.Context, videoID uuid.UUID, shareToken string) (*models.VideoFile, *models.PlaybackInfo, error) {
+	viewerID := uuid.Nil
+	if user, err := utils.GetUserFromCtx(ctx); err == nil {
+		viewerID = user.UserID
 	}
 	video, err := v.videoRepo.GetVideoByID(ctx, videoID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			v.logger.Warnf("Video not found with ID: %s", videoID.String())
-			return nil, fmt.Errorf("video not found")
+			return nil, nil, videofiles.ErrVideoNotFound
 		}
 		v.logger.Errorf("GetPlaybackInfo - failed to fetch video: %v", err)
-		return nil, fmt.Errorf("failed to fetch video: %v", err)
+		return nil, nil, fmt.Errorf("failed to fetch video: %v", err)
 	}
-	if video.UserID != user.UserID {
-		v.logger.Warnf("User %s is not authorized to access video %s", user.UserID, videoID.String())
-		return nil, fmt.Errorf("unauthorized access to video")
+	if !video.CanView(viewerID, shareToken) {
+		v.logger.Warnf("User %s is not authorized to play video %s", viewerID, videoID.String())
+		return nil, nil, videofiles.ErrVideoForbidden
 	}
 	playbackInfo, err := v.videoRepo.GetPlaybackInfo(ctx, videoID)
 	if err != nil {
 		v.logger.Errorf("GetPlaybackInfo - failed to fetch playback info: %v", err)
-		return nil, fmt.Errorf("failed to fetch playback info: %v", err)
+		return nil, nil, fmt.Errorf("failed to fetch playback info: %v", err)
+	}
+	playbackInfo.DRM = v.drmInfo(video)
+	v.signPlaybackURLs(video, playbackInfo, viewerID)
+	return video, playbackInfo, nil
+}
+
+// subtitleLanguage derives the language and a display label of a subtitle
+// track from its file name, e.g. subtitle_0_eng.vtt.
+func subtitleLanguage(subtitleURL string, index int) (language, label string) {
+	name := strings.TrimSuffix(path.Base(subtitleURL), path.Ext(subtitleURL))
+	parts := strings.Split(name, "_")
+	language = parts[len(parts)-1]
+	if len(parts) < 3 || language == "" || language == "und" {
+		return "und", fmt.Sprintf("Subtitles %d", index+1)
+	}
+	return language, strings.ToUpper(language)
+}
+
+// GetStreamObject fetches an output object of an encrypted video for the
+// playback proxy. The object is decrypted by S3 with the video's data key,
+// which is only unwrapped for viewers allowed to view the video: its owner,
+// anyone for public videos and holders of the share token for unlisted ones.
+func (v *videoFileUC) GetStreamObject(ctx context.Context, videoID uuid.UUID, objectPath, shareToken string) (*s3.GetObjectOutput, error) {
+	viewerID := uuid.Nil
+	if user, err := utils.GetUserFromCtx(ctx); err == nil {
+		viewerID = user.UserID
+	}
+
+	objectPath = path.Clean("/" + objectPath)
+	if objectPath == "/" {
+		return nil, fmt.Errorf("invalid object path")
+	}
+
+	video, err := v.videoRepo.GetVideoByID(ctx, videoID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, videofiles.ErrVideoNotFound
+		}
+		v.logger.Errorf("GetStreamObject - failed to fetch video: %v", err)
+		return nil, fmt.Errorf("failed to fetch video: %v", err)
+	}
+	if !video.CanView(viewerID, shareToken) {
+		v.logger.Warnf("User %s is not authorized to stream video %s", viewerID, videoID.String())
+		return nil, videofiles.ErrVideoForbidden
+	}
+	if !video.Encrypted {
+		return nil, fmt.Errorf("video is not encrypted")
+	}
+	return v.getEncryptedObject(ctx, video, objectPath)
+}
+
+// getEncryptedObject fetches an output object of an encrypted video,
+// unwrapping its data key for S3 to decrypt it with.
+func (v *videoFileUC) getEncryptedObject(ctx context.Context, video *models.VideoFile, objectPath string) (*s3.GetObjectOutput, error) {
+	if v.keys == nil {
+		return nil, kms.ErrNotConfigured
+	}
+
+	dataKey, err := v.keys.Decrypt(ctx, video.EncryptedDataKey)
+	if err != nil {
+		v.logger.Errorf("GetStreamObject - Decrypt error: %v", err)
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	object, err := v.awsRepo.GetObject(kms.WithDataKey(ctx, dataKey), v.cfg.S3.OutputBucket, outputPrefix(video)+objectPath)
+	if err != nil {
+		v.logger.Errorf("GetStreamObject - GetObject error: %v", err)
+		return nil, fmt.Errorf("failed to fetch object: %w", err)
+	}
+	return object, nil
+}
+
+// outputPrefix returns the key prefix the outputs of a video are stored under
+func outputPrefix(video *models.VideoFile) string {
+	return strings.TrimSuffix(video.S3Key, path.Ext(video.S3Key))
+}
+
+// UpdateVisibility changes who may play a video. Making a video unlisted
+// issues a share token; any other visibility revokes it.
+func (v *videoFileUC) UpdateVisibility(ctx context.Context, videoID uuid.UUID, input *models.VisibilityInput) (*models.VideoFile, error) {
+	if err := utils.ValidateStruct(ctx, input); err != nil {
+		v.logger.Errorf("UpdateVisibility - ValidateStruct error: %v", err)
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+
+	video, err := v.GetVideo(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = setVisibility(video, input.Visibility); err != nil {
+		return nil, err
+	}
+
+	if err = v.videoRepo.UpdateVisibility(ctx, video); err != nil {
+		v.logger.Errorf("UpdateVisibility - failed to update video: %v", err)
+		return nil, fmt.Errorf("failed to update visibility: %v", err)
+	}
+	return video, nil
+}
+
+// RotateShareToken replaces the share token of an unlisted video, so links
+// shared earlier stop working.
+func (v *videoFileUC) RotateShareToken(ctx context.Context, videoID uuid.UUID) (*models.VideoFile, error) {
+	video, err := v.GetVideo(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+	if video.Visibility != models.VisibilityUnlisted {
+		return nil, fmt.Errorf("only unlisted videos have a share token")
+	}
+
+	video.ShareToken = nil
+	if err = setVisibility(video, models.VisibilityUnlisted); err != nil {
+		return nil, err
+	}
+
+	if err = v.videoRepo.UpdateVisibility(ctx, video); err != nil {
+		v.logger.Errorf("RotateShareToken - failed to update video: %v", err)
+		return nil, fmt.Errorf("failed to rotate share token: %v", err)
+	}
+	return video, nil
+}
+
+// SetPoster makes one of the thumbnails generated for a video its poster.
+func (v *videoFileUC) SetPoster(ctx context.Context, videoID uuid.UUID, input *models.PosterInput) (*models.PlaybackInfo, error) {
+	if err := utils.ValidateStruct(ctx, input); err != nil {
+		v.logger.Errorf("SetPoster - ValidateStruct error: %v", err)
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+
+	if _, err := v.GetVideo(ctx, videoID); err != nil {
+		return nil, err
+	}
+
+	playbackInfo, err := v.videoRepo.GetPlaybackInfo(ctx, videoID)
+	if err != nil {
+		v.logger.Errorf("SetPoster - failed to fetch playback info: %v", err)
+		return nil, fmt.Errorf("video has no thumbnails yet")
+	}
+	if *input.Index >= len(playbackInfo.Thumbnails) {
+		return nil, fmt.Errorf("thumbnail index must be between 0 and %d", len(playbackInfo.Thumbnails)-1)
+	}
+
+	playbackInfo.Thumbnail = playbackInfo.Thumbnails[*input.Index]
+	if err = v.videoRepo.SetPoster(ctx, videoID, playbackInfo.Thumbnail); err != nil {
+		v.logger.Errorf("SetPoster - failed to set poster: %v", err)
+		return nil, fmt.Errorf("failed to set poster: %v", err)
 	}
 	return playbackInfo, nil
 }
+
+// setVisibility sets the visibility of video, defaulting to private, and
+// issues a share token for unlisted videos that do not have one yet.
+func setVisibility(video *models.VideoFile, visibility models.Visibility) error {
+	if visibility == "" {
+		visibility = models.VisibilityPrivate
+	}
+	video.Visibility = visibility
+
+	if visibility != models.VisibilityUnlisted {
+		video.ShareToken = nil
+		return nil
+	}
+	if video.ShareToken != nil {
+		return nil
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Errorf("failed to generate share token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	video.ShareToken = &token
+	return nil
+}
@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// validationURLTTL is how long ffprobe may read an upload being validated
+	validationURLTTL = 5 * time.Minute
+	// validationTimeout bounds the probe of an upload being validated
+	validationTimeout = 30 * time.Second
+)
+
+// ValidateSource runs the worker's pre-flight checks on one of the user's
+// uploads before a job is created for it. ffprobe reads only the parts of
+// the upload it needs, through a presigned URL.
+func (v *videoFileUC) ValidateSource(ctx context.Context, input *models.ValidateSourceInput) (*models.SourceValidation, error) {
+	user, err := utils.GetUserFromCtx(ctx)
+	if err != nil {
+		v.logger.Errorf("ValidateSource - failed to get user from context: %v", err)
+		return nil, fmt.Errorf("unauthorized: %v", err)
+	}
+	if err = utils.ValidateStruct(ctx, input); err != nil {
+		v.logger.Errorf("ValidateSource - ValidateStruct error: %v", err)
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+	if !ownsUpload(user.UserID, input.S3Key) {
+		return nil, fmt.Errorf("%q is not one of your uploads", input.S3Key)
+	}
+
+	url, err := v.awsRepo.GetPresignedObjectURL(ctx, v.cfg.S3.InputBucket, input.S3Key, validationURLTTL)
+	if err != nil {
+		v.logger.Errorf("ValidateSource - GetPresignedObjectURL error: %v", err)
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, validationTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(probeCtx, "ffprobe", "-v", "error", "-show_format", "-show_streams", "-of", "json", url)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		// Files ffprobe cannot read at all are rejected like the worker does
+		v.logger.Warnf("ValidateSource - ffprobe failed for %s: %v, stderr: %s", input.S3Key, err, stderr.String())
+		return &models.SourceValidation{
+			Issues: []models.SourceIssue{{Code: models.SourceUnsupportedContainer, Message: "source could not be read"}},
+		}, nil
+	}
+
+	probe, err := models.StripProbeFilename(stdout.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	report, err := models.NewSourceReport("", probe)
+	if err != nil {
+		return nil, err
+	}
+	issues := models.ValidateSource(report)
+	return &models.SourceValidation{Valid: len(issues) == 0, Issues: issues, Source: report}, nil
+}
+
+// ownsUpload reports whether key is in the upload prefix of the user
+func ownsUpload(userID uuid.UUID, key string) bool {
+	return path.Clean(key) == key && strings.HasPrefix(key, fmt.Sprintf("uploads/%s/", userID))
+}
@@ -0,0 +1,146 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/drm"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/google/uuid"
+)
+
+// ReencodeVideo queues an encode of one of the user's finished videos with a
+// new profile. The outputs are written to a new playback version next to
+// the current ones, which keep playing until the encode completes and the
+// playback info is switched over.
+func (v *videoFileUC) ReencodeVideo(ctx context.Context, videoID uuid.UUID, input *models.ReencodeInput) (*models.EncodeJob, error) {
+	if err := utils.ValidateStruct(ctx, input); err != nil {
+		v.logger.Errorf("ReencodeVideo - ValidateStruct error: %v", err)
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+	video, err := v.GetVideo(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+	if video.DeletedAt != nil {
+		return nil, fmt.Errorf("video is in the trash")
+	}
+	if video.Status != models.JobStatusCompleted {
+		return nil, fmt.Errorf("only completed videos can be re-encoded")
+	}
+	if video.Kind != "" && video.Kind != models.AssetVideo {
+		return nil, fmt.Errorf("only videos can be re-encoded")
+	}
+	if input.EnableDRM && v.cfg.DRM.KeyServerURL == "" {
+		return nil, drm.ErrNotConfigured
+	}
+	if input.EnableDRM && input.LowLatencyHLS {
+		// LL-HLS renditions are packaged without mp4dash and cannot be encrypted
+		return nil, fmt.Errorf("low latency HLS cannot be combined with DRM")
+	}
+	if err := validateBumpers(video.UserID, input.IntroS3Key, input.OutroS3Key); err != nil {
+		return nil, err
+	}
+	if err := models.ValidateChapters(input.Chapters); err != nil {
+		return nil, err
+	}
+
+	defaults := &models.VideoUploadInput{
+		Codec:         input.Codec,
+		Qualities:     input.Qualities,
+		OutputFormats: input.OutputFormats,
+	}
+	applyJobDefaults(defaults)
+	input.Codec, input.Qualities, input.OutputFormats = defaults.Codec, defaults.Qualities, defaults.OutputFormats
+
+	jobID := uuid.New().String()
+	version, err := v.videoRepo.CreatePlaybackVersion(ctx, video.VideoID, jobID, input)
+	if err != nil {
+		v.logger.Errorf("ReencodeVideo - CreatePlaybackVersion error: %v", err)
+		return nil, fmt.Errorf("failed to create playback version: %w", err)
+	}
+
+	job := &models.EncodeJob{
+		JobID:                  jobID,
+		UserID:                 video.UserID.String(),
+		VideoID:                video.VideoID.String(),
+		InputS3Key:             video.S3Key,
+		InputBucket:            video.S3Bucket,
+		OutputBucket:           v.cfg.S3.OutputBucket,
+		OutputS3Key:            path.Join(outputPrefix(video), models.PlaybackVersionPath(version)),
+		Qualities:              input.Qualities,
+		OutputFormats:          input.OutputFormats,
+		EnablePerTitleEncoding: input.EnablePerTitleEncoding,
+		Status:                 models.JobStatusQueued,
+		Codec:                  input.Codec,
+		StartedAt:              time.Now(),
+		EncryptedDataKey:       video.EncryptedDataKey,
+		GenerateCaptions:       input.GenerateCaptions,
+		CaptionLanguage:        input.CaptionLanguage,
+		EnableDownloads:        input.EnableDownloads,
+		DownloadQuality:        input.DownloadQuality,
+		EnableDRM:              input.EnableDRM,
+		Thumbnails:             input.Thumbnails,
+		PreviewFormat:          input.PreviewFormat,
+		LowLatencyHLS:          input.LowLatencyHLS,
+		IntegrityManifest:      input.IntegrityManifest,
+		PlaybackVersion:        version,
+		IntroS3Key:             input.IntroS3Key,
+		OutroS3Key:             input.OutroS3Key,
+		Chapters:               input.Chapters,
+	}
+
+	if err := v.videoRepo.UpdateVideoProgress(ctx, video.VideoID, models.JobStatusQueued, 0); err != nil {
+		v.logger.Errorf("ReencodeVideo - failed to update video %s: %v", video.VideoID, err)
+		return nil, fmt.Errorf("failed to update video: %v", err)
+	}
+	v.recordJobEvent(ctx, job, models.JobEventQueued)
+	if err := v.redisRepo.EnqueueJob(ctx, v.jobQueue(ctx, job.Codec), job); err != nil {
+		v.logger.Errorf("ReencodeVideo - EnqueueJob error: %v", err)
+		if failErr := v.videoRepo.FailPlaybackVersion(ctx, video.VideoID, version, "job could not be queued"); failErr != nil {
+			v.logger.Errorf("ReencodeVideo - FailPlaybackVersion error: %v", failErr)
+		}
+		if err := v.videoRepo.UpdateVideoProgress(ctx, video.VideoID, models.JobStatusCompleted, 100); err != nil {
+			v.logger.Errorf("ReencodeVideo - failed to restore video %s: %v", video.VideoID, err)
+		}
+		return nil, fmt.Errorf("failed to queue the job: %v", err)
+	}
+	return job, nil
+}
+
+// ListPlaybackVersions lists the playback versions of one of the user's
+// videos, newest first, marking the one being played.
+func (v *videoFileUC) ListPlaybackVersions(ctx context.Context, videoID uuid.UUID) ([]*models.PlaybackVersion, error) {
+	if _, err := v.GetVideo(ctx, videoID); err != nil {
+		return nil, err
+	}
+	versions, err := v.videoRepo.GetPlaybackVersions(ctx, videoID)
+	if err != nil {
+		v.logger.Errorf("ListPlaybackVersions - GetPlaybackVersions error: %v", err)
+		return nil, fmt.Errorf("failed to fetch playback versions: %w", err)
+	}
+	return versions, nil
+}
+
+// RollbackPlaybackVersion switches one of the user's videos back to an
+// earlier completed playback version and returns the versions after the
+// switch. The outputs of every version are kept, so the rollback can itself
+// be undone.
+func (v *videoFileUC) RollbackPlaybackVersion(ctx context.Context, videoID uuid.UUID, version int) ([]*models.PlaybackVersion, error) {
+	video, err := v.GetVideo(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+	if video.Status != models.JobStatusCompleted {
+		return nil, fmt.Errorf("video cannot be rolled back while it is being processed")
+	}
+	if err := v.videoRepo.RollbackPlaybackVersion(ctx, videoID, version); err != nil {
+		v.logger.Errorf("RollbackPlaybackVersion - RollbackPlaybackVersion error: %v", err)
+		return nil, fmt.Errorf("failed to roll back playback version: %w", err)
+	}
+	v.logger.Infof("Rolled back video %s to playback version %d", videoID, version)
+	return v.ListPlaybackVersions(ctx, videoID)
+}
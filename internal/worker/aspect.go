@@ -0,0 +1,111 @@
+package worker
+
+import (
+	"bufio"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+// probeDisplayGeometry returns the sample aspect ratio and rotation of the
+// first video stream. Anamorphic sources store pixels that are not square and
+// phone recordings are often stored landscape with a rotation, so neither
+// frame is shown at its coded size. A missing SAR is reported as 1:1.
+func probeDisplayGeometry(path string) (sarNum, sarDen, rotation int, err error) {
+	output, err := ffprobeCommand("-v", "quiet", "-select_streams", "v:0",
+		"-show_entries", "stream=sample_aspect_ratio:stream_tags=rotate:stream_side_data=rotation",
+		"-of", "default=noprint_wrappers=1", path).Output()
+	if err != nil {
+		return 1, 1, 0, err
+	}
+
+	sarNum, sarDen = 1, 1
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimPrefix(key, "TAG:") {
+		case "sample_aspect_ratio":
+			n, d, found := strings.Cut(value, ":")
+			num, numErr := strconv.Atoi(n)
+			den, denErr := strconv.Atoi(d)
+			if found && numErr == nil && denErr == nil && num > 0 && den > 0 {
+				sarNum, sarDen = num, den
+			}
+		case "rotation", "rotate":
+			if r, err := strconv.Atoi(value); err == nil {
+				rotation = r
+			}
+		}
+	}
+	return sarNum, sarDen, rotation, nil
+}
+
+// displaySize returns the size a frame of width x height is shown at once its
+// sample aspect ratio and rotation are applied, as ffmpeg does when decoding.
+func displaySize(width, height, sarNum, sarDen, rotation int) (int, int) {
+	if sarNum > 0 && sarDen > 0 && sarNum != sarDen {
+		width = int(math.Round(float64(width) * float64(sarNum) / float64(sarDen)))
+	}
+	if r := ((rotation % 360) + 360) % 360; r == 90 || r == 270 {
+		width, height = height, width
+	}
+	return width, height
+}
+
+// fitPreset sizes a preset to the source's display aspect ratio. The preset's
+// resolution is the box of its rung for 16:9 landscape video; the rendition
+// is the largest frame of the source's shape that fits in that box, turned
+// upright for portrait sources, so 4:3 1080p is 1440x1080 and 9:16 1080p is
+// 1080x1920. It reports false when the source is smaller than the rendition
+// would be.
+func fitPreset(preset QualityPreset, displayWidth, displayHeight int) (QualityPreset, bool) {
+	if displayWidth <= 0 || displayHeight <= 0 {
+		return preset, false
+	}
+
+	boxWidth, boxHeight := preset.Resolution[0], preset.Resolution[1]
+	if displayHeight > displayWidth {
+		boxWidth, boxHeight = boxHeight, boxWidth
+	}
+	scale := math.Min(float64(boxWidth)/float64(displayWidth), float64(boxHeight)/float64(displayHeight))
+
+	preset.tier = preset.height()
+	preset.Resolution = [2]int{
+		evenDimension(float64(displayWidth) * scale),
+		evenDimension(float64(displayHeight) * scale),
+	}
+	return preset, scale <= 1
+}
+
+// evenDimension rounds a dimension to the nearest even number, which 4:2:0
+// chroma subsampling requires
+func evenDimension(value float64) int {
+	return max(2, int(math.Round(value/2))*2)
+}
+
+// height returns the nominal height of the preset's rung, which is what the
+// rung is named after whatever the shape of the rendition
+func (q QualityPreset) height() int {
+	if q.tier > 0 {
+		return q.tier
+	}
+	return q.Resolution[1]
+}
+
+// rungFor returns the highest rung a rendition of width x height belongs to:
+// the first whose box, turned like the frame, it fills in at least one
+// dimension.
+func rungFor(width, height int) models.VideoQuality {
+	long, short := max(width, height), min(width, height)
+	for _, preset := range qualityPresets {
+		if long >= preset.Resolution[0] || short >= preset.Resolution[1] {
+			return preset.Name
+		}
+	}
+	return qualityPresets[len(qualityPresets)-1].Name
+}
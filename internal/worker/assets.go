@@ -0,0 +1,374 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// AudioSegmentDuration is the length in seconds of HLS audio segments
+	AudioSegmentDuration = 6
+	// ImagesDir holds the variants of an image, relative to its output key
+	ImagesDir = "images"
+)
+
+// audioBitrates are the kbps of the AAC renditions of audio files. Bitrates
+// above the source's are left out, except the lowest.
+var audioBitrates = []int{256, 128, 64}
+
+// imageWidths are the widths of the variants of images. Widths above the
+// source's are left out, and the source's own width is added when it is
+// below the largest.
+var imageWidths = []int{2048, 1600, 1024, 640, 320}
+
+// imageFormats are the formats every image variant is written in, WebP for
+// browsers that support it and JPEG for the rest
+var imageFormats = []string{"webp", "jpg"}
+
+// assetStageProgress is stageProgress for audio and image jobs, which have
+// no encode stage
+var assetStageProgress = map[models.ProgressStage][2]float64{
+	models.ProgressDownloading: {0, 20},
+	models.ProgressScanning:    {20, 25},
+	models.ProgressProbing:     {25, 30},
+	models.ProgressRenditions:  {30, 75},
+	models.ProgressWaveform:    {75, 85},
+	models.ProgressUploading:   {85, 90},
+}
+
+// ProcessAudio packages a standalone audio file as HLS audio renditions
+// with a master playlist, and renders its waveform.
+func (p *videoProcessor) ProcessAudio(ctx context.Context, job *models.EncodeJob, videoID uuid.UUID) (*ProcessingResult, error) {
+	if err := p.openWorkspace(); err != nil {
+		return nil, err
+	}
+	defer p.cleanup()
+
+	sourcePath, err := p.fetchAsset(ctx, job, videoID)
+	if err != nil {
+		return nil, err
+	}
+	report, err := p.probeAsset(ctx, videoID, sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var audio *models.SourceStream
+	for i, stream := range report.Streams {
+		if stream.Type == "audio" {
+			audio = &report.Streams[i]
+			break
+		}
+	}
+	if audio == nil {
+		return nil, failedAt(models.FailureInvalidSource, errors.New("source has no audio stream"))
+	}
+	duration := report.Container.Duration
+	if duration <= 0 {
+		return nil, failedAt(models.FailureInvalidSource, errors.New("source has no duration"))
+	}
+	sourceBitrate := audio.Bitrate
+	if sourceBitrate <= 0 {
+		sourceBitrate = report.Container.Bitrate
+	}
+
+	outputPath := filepath.Join(p.tempDir, "output")
+	var qualities []models.InputQualityInfo
+	for _, bitrate := range audioBitrates {
+		if sourceBitrate > 0 && int64(bitrate)*1000 > sourceBitrate && bitrate != audioBitrates[len(audioBitrates)-1] {
+			continue
+		}
+		qualities = append(qualities, models.InputQualityInfo{Quality: models.AudioQuality(bitrate), Bitrate: bitrate})
+	}
+	for i, quality := range qualities {
+		p.reportStage(models.ProgressRenditions, float64(100*i/len(qualities)))
+		if err := encodeAudioRendition(ctx, sourcePath, filepath.Join(outputPath, string(quality.Quality)), quality.Bitrate); err != nil {
+			if ctx.Err() != nil {
+				return nil, p.interrupt(ctx)
+			}
+			return nil, failedAt(models.FailureEncode, err)
+		}
+	}
+	if err := writeAudioMaster(outputPath, qualities); err != nil {
+		return nil, failedAt(models.FailurePackage, err)
+	}
+
+	p.reportStage(models.ProgressWaveform, 0)
+	if err := writeWaveform(ctx, sourcePath, duration, filepath.Join(outputPath, WaveformFile)); err != nil {
+		if ctx.Err() != nil {
+			return nil, p.interrupt(ctx)
+		}
+		return nil, failedAt(models.FailureEncode, err)
+	}
+
+	if err := p.uploadAsset(ctx, job, videoID, outputPath); err != nil {
+		return nil, err
+	}
+	return &ProcessingResult{
+		Duration:      duration,
+		Qualities:     qualities,
+		Waveform:      WaveformFile,
+		UploadedBytes: p.uploadedBytes.Load(),
+	}, nil
+}
+
+// ProcessImage resizes an image into variants of imageWidths in every
+// format of imageFormats.
+func (p *videoProcessor) ProcessImage(ctx context.Context, job *models.EncodeJob, videoID uuid.UUID) (*ProcessingResult, error) {
+	if err := p.openWorkspace(); err != nil {
+		return nil, err
+	}
+	defer p.cleanup()
+
+	sourcePath, err := p.fetchAsset(ctx, job, videoID)
+	if err != nil {
+		return nil, err
+	}
+	report, err := p.probeAsset(ctx, videoID, sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var width, height int
+	for _, stream := range report.Streams {
+		if stream.Type == "video" && stream.Width > 0 && stream.Height > 0 {
+			width, height = stream.Width, stream.Height
+			break
+		}
+	}
+	if width == 0 || height == 0 {
+		return nil, failedAt(models.FailureInvalidSource, errors.New("source is not an image"))
+	}
+
+	outputPath := filepath.Join(p.tempDir, "output")
+	if err := os.MkdirAll(filepath.Join(outputPath, ImagesDir), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create images directory: %w", err)
+	}
+	widths := variantWidths(width)
+	var variants []models.ImageVariant
+	for i, variantWidth := range widths {
+		p.reportStage(models.ProgressRenditions, float64(100*i/len(widths)))
+		variantHeight := int(math.Round(float64(variantWidth*height)/float64(width)/2)) * 2
+		for _, format := range imageFormats {
+			name := fmt.Sprintf("%s/%d.%s", ImagesDir, variantWidth, format)
+			path := filepath.Join(outputPath, filepath.FromSlash(name))
+			if err := resizeImage(ctx, sourcePath, path, variantWidth); err != nil {
+				if ctx.Err() != nil {
+					return nil, p.interrupt(ctx)
+				}
+				return nil, failedAt(models.FailureEncode, err)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, failedAt(models.FailureEncode, fmt.Errorf("failed to stat variant %s: %w", name, err))
+			}
+			variants = append(variants, models.ImageVariant{
+				URL:    name,
+				Width:  variantWidth,
+				Height: max(variantHeight, 2),
+				Format: format,
+				Size:   info.Size(),
+			})
+		}
+	}
+
+	if err := p.uploadAsset(ctx, job, videoID, outputPath); err != nil {
+		return nil, err
+	}
+	return &ProcessingResult{
+		Width:         width,
+		Height:        height,
+		Images:        variants,
+		UploadedBytes: p.uploadedBytes.Load(),
+	}, nil
+}
+
+// fetchAsset downloads the source of an audio or image job and scans it
+func (p *videoProcessor) fetchAsset(ctx context.Context, job *models.EncodeJob, videoID uuid.UUID) (string, error) {
+	p.reportStage(models.ProgressDownloading, 0)
+	var localPath string
+	var err error
+	if job.SourceURL != "" {
+		localPath, err = p.fetchRemoteSource(ctx, videoID)
+	} else {
+		localPath, err = p.downloadVideo(ctx, job.InputS3Key)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", p.interrupt(ctx)
+		}
+		return "", failedAt(models.FailureDownload, fmt.Errorf("download failed: %w", err))
+	}
+	if err := p.scanSource(ctx, localPath); err != nil {
+		return "", err
+	}
+	if err := p.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusProcessing, 25); err != nil {
+		p.logger.Errorf("Failed to update progress after download: %v", err)
+	}
+	return localPath, nil
+}
+
+// probeAsset probes the source of an audio or image job and stores what
+// ffprobe reports about it, like preflight does for videos
+func (p *videoProcessor) probeAsset(ctx context.Context, videoID uuid.UUID, sourcePath string) (*models.SourceReport, error) {
+	p.reportStage(models.ProgressProbing, 0)
+	probe, err := probeSource(sourcePath)
+	if err != nil {
+		return nil, failedAt(models.FailureProbe, fmt.Errorf("failed to probe source: %w", err))
+	}
+	if err := p.videoRepo.SetSourceMetadata(ctx, videoID, probe); err != nil {
+		p.logger.Warnf("Failed to record source metadata of job %s: %v", p.job.JobID, err)
+	}
+	report, err := models.NewSourceReport(videoID.String(), probe)
+	if err != nil {
+		return nil, failedAt(models.FailureProbe, err)
+	}
+	// The rest of ValidateSource is about video streams
+	for _, issue := range models.ValidateSource(report) {
+		if issue.Code == models.SourceDRMProtected {
+			return nil, failedAt(models.FailureInvalidSource, models.SourceIssuesError([]models.SourceIssue{issue}))
+		}
+	}
+	return report, nil
+}
+
+// uploadAsset uploads the outputs of an audio or image job to its output key
+func (p *videoProcessor) uploadAsset(ctx context.Context, job *models.EncodeJob, videoID uuid.UUID, outputPath string) error {
+	p.reportStage(models.ProgressUploading, 0)
+	outputKey := strings.Trim(job.OutputS3Key, "/")
+	if err := p.uploadProcessedFiles(ctx, outputPath, outputKey); err != nil {
+		return failedAt(models.FailureUpload, fmt.Errorf("upload failed: %w", err))
+	}
+	p.reportStage(models.ProgressUploading, 100)
+	if err := p.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusProcessing, 90); err != nil {
+		p.logger.Errorf("Failed to update progress after upload: %v", err)
+	}
+	return nil
+}
+
+// encodeAudioRendition encodes the first audio stream of sourcePath into an
+// HLS rendition of bitrate kbps in outputDir
+func encodeAudioRendition(ctx context.Context, sourcePath, outputDir string, bitrate int) error {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create rendition directory: %w", err)
+	}
+	cmd := ffmpegCommandContext(ctx, "-y", "-hide_banner", "-loglevel", "error",
+		"-i", sourcePath, "-map", "0:a:0", "-vn",
+		"-c:a", "aac", "-b:a", fmt.Sprintf("%dk", bitrate), "-ac", "2", "-ar", "48000",
+		"-f", "hls", "-hls_time", strconv.Itoa(AudioSegmentDuration), "-hls_playlist_type", "vod",
+		"-hls_segment_filename", filepath.Join(outputDir, "segment_%04d.ts"),
+		filepath.Join(outputDir, "playlist.m3u8"))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to encode %dk audio: %v, stderr: %s", bitrate, err, stderr.String())
+	}
+	return nil
+}
+
+// writeAudioMaster writes the master playlist of the audio renditions
+func writeAudioMaster(outputPath string, qualities []models.InputQualityInfo) error {
+	var master strings.Builder
+	master.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, quality := range qualities {
+		// Bandwidth includes the MPEG-TS overhead of about a tenth
+		fmt.Fprintf(&master, "#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=\"mp4a.40.2\"\n%s/playlist.m3u8\n",
+			quality.Bitrate*1100, quality.Quality)
+	}
+	if err := os.WriteFile(filepath.Join(outputPath, "master.m3u8"), []byte(master.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write master playlist: %w", err)
+	}
+	return nil
+}
+
+// variantWidths returns the widths to resize an image of width to, largest
+// first
+func variantWidths(width int) []int {
+	var widths []int
+	if width < imageWidths[0] {
+		widths = append(widths, width)
+	}
+	for _, w := range imageWidths {
+		if w < width || (w == width && len(widths) == 0) {
+			widths = append(widths, w)
+		}
+	}
+	return widths
+}
+
+// resizeImage writes the first frame of sourcePath scaled to width, keeping
+// its aspect ratio, to outputPath in the format of its extension
+func resizeImage(ctx context.Context, sourcePath, outputPath string, width int) error {
+	args := []string{"-y", "-hide_banner", "-loglevel", "error",
+		"-i", sourcePath, "-frames:v", "1", "-vf", fmt.Sprintf("scale=%d:-2:flags=lanczos", width)}
+	switch filepath.Ext(outputPath) {
+	case ".webp":
+		args = append(args, "-c:v", "libwebp", "-quality", "80")
+	case ".jpg":
+		args = append(args, "-q:v", "3")
+	}
+	cmd := ffmpegCommandContext(ctx, append(args, outputPath)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to resize image to %dpx: %v, stderr: %s", width, err, stderr.String())
+	}
+	return nil
+}
+
+// assetPlaybackInfo is the playback info of an audio or image job, with the
+// outputs of result under baseURL
+func assetPlaybackInfo(job *models.EncodeJob, baseURL string, result *ProcessingResult) *models.PlaybackInfo {
+	info := &models.PlaybackInfo{
+		VideoID:   job.VideoID,
+		Title:     filepath.Base(job.InputS3Key),
+		Duration:  result.Duration,
+		Qualities: make(map[models.VideoQuality]models.QualityInfo),
+		Format:    models.FormatHLS,
+		Status:    models.JobStatusCompleted,
+	}
+
+	switch job.Type {
+	case models.JobTypeAudio:
+		info.Kind = models.AssetAudio
+		for _, quality := range result.Qualities {
+			info.Qualities[quality.Quality] = models.QualityInfo{
+				URLs:    models.PlaybackURLs{HLS: fmt.Sprintf("%s/%s/playlist.m3u8", baseURL, quality.Quality)},
+				Bitrate: quality.Bitrate,
+			}
+		}
+		info.Qualities[models.QualityMaster] = models.QualityInfo{
+			URLs:       models.PlaybackURLs{HLS: fmt.Sprintf("%s/master.m3u8", baseURL)},
+			Resolution: "adaptive",
+		}
+		if result.Waveform != "" {
+			info.Waveform = fmt.Sprintf("%s/%s", baseURL, result.Waveform)
+		}
+	case models.JobTypeImage:
+		info.Kind = models.AssetImage
+		for _, variant := range result.Images {
+			variant.URL = fmt.Sprintf("%s/%s", baseURL, variant.URL)
+			info.Images = append(info.Images, variant)
+		}
+		// The smallest JPEG makes the poster, as every client can show it
+		for _, variant := range info.Images {
+			if variant.Format == "jpg" {
+				info.Thumbnail = variant.URL
+			}
+		}
+		if info.Thumbnail != "" {
+			info.Thumbnails = []string{info.Thumbnail}
+		}
+	}
+	return info
+}
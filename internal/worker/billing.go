@@ -0,0 +1,130 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/webhook"
+)
+
+const (
+	DefaultBillingDeliveryInterval = 30 * time.Second
+	DefaultBillingMaxAttempts      = 10
+	// BillingDeliveryBatch is how many events one delivery tick sends at most
+	BillingDeliveryBatch = 50
+	// BillingWebhookTimeout bounds a single delivery request
+	BillingWebhookTimeout = 10 * time.Second
+)
+
+// emitBilling records the billing event of a completed job. Billing is best
+// effort here: a job is not failed because its event could not be written.
+func (w *Worker) emitBilling(ctx context.Context, job *models.EncodeJob, result *ProcessingResult) {
+	height := result.Height
+	var bitrate int
+	for _, quality := range result.Qualities {
+		var width, h int
+		if _, err := fmt.Sscanf(quality.Resolution, "%dx%d", &width, &h); err == nil {
+			height = max(height, h)
+		}
+		bitrate = max(bitrate, quality.Bitrate)
+	}
+
+	event := &models.BillingEvent{
+		EventID:            uuid.New(),
+		Type:               models.BillingJobCompleted,
+		JobID:              job.JobID,
+		VideoID:            job.VideoID,
+		UserID:             job.UserID,
+		JobType:            job.Type,
+		Tier:               models.BillingTierFor(job.Codec, height),
+		EncodeMinutes:      math.Round(result.Duration/60*1e4) / 1e4,
+		StorageBytes:       result.UploadedBytes,
+		EgressBytesPerView: int64(float64(bitrate) * 1000 / 8 * result.Duration),
+	}
+	if err := w.videoRepo.CreateBillingEvent(context.WithoutCancel(ctx), event); err != nil {
+		w.jobLogger(job).Warnf("Failed to record billing event of job %s: %v", job.JobID, err)
+	}
+}
+
+// deliverBillingEvents posts pending billing events to the billing webhook.
+// Every worker runs it; claiming events with SKIP LOCKED keeps two workers
+// from sending the same one, and failed events are retried with a growing
+// backoff until they run out of attempts.
+func (w *Worker) deliverBillingEvents(ctx context.Context, signer *webhook.Signer) {
+	defer w.wg.Done()
+
+	interval := DefaultBillingDeliveryInterval
+	if w.cfg.Billing.DeliveryInterval > 0 {
+		interval = time.Duration(w.cfg.Billing.DeliveryInterval) * time.Second
+	}
+	maxAttempts := DefaultBillingMaxAttempts
+	if w.cfg.Billing.MaxAttempts > 0 {
+		maxAttempts = w.cfg.Billing.MaxAttempts
+	}
+	client := outboundClient()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			events, err := w.videoRepo.ClaimBillingEvents(ctx, BillingDeliveryBatch, interval, maxAttempts)
+			if err != nil {
+				w.logger.Warnf("Failed to claim billing events: %v", err)
+				continue
+			}
+			for _, event := range events {
+				if err := w.postBillingEvent(ctx, client, signer, event); err != nil {
+					w.logger.Warnf("Failed to deliver billing event %s (attempt %d): %v", event.EventID, event.Attempts, err)
+					continue
+				}
+				if err := w.videoRepo.MarkBillingEventDelivered(ctx, event.ID); err != nil {
+					w.logger.Warnf("Failed to mark billing event %s delivered: %v", event.EventID, err)
+				}
+			}
+		}
+	}
+}
+
+func (w *Worker) postBillingEvent(ctx context.Context, client *http.Client, signer *webhook.Signer, event *models.BillingEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, BillingWebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.Billing.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.SignatureHeader, signer.Sign(body, time.Now()))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/kms"
+)
+
+const (
+	// bumperAudioRate is the sample rate every clip is resampled to
+	bumperAudioRate = 48000
+)
+
+// bumperClip is one of the clips joined into the source
+type bumperClip struct {
+	path     string
+	duration float64
+	hasAudio bool
+}
+
+// addBumpers joins the job's intro and outro to the source at sourcePath and
+// returns the path of the result, or sourcePath when the job has none. The
+// clips are scaled and padded to the display size of the source and brought
+// to its frame rate and audio layout, so every rendition starts and ends with
+// them. Bumpers are uploaded like sources and read without the job's key.
+func (p *videoProcessor) addBumpers(ctx context.Context, sourcePath string) (string, error) {
+	if p.job.IntroS3Key == "" && p.job.OutroS3Key == "" {
+		return sourcePath, nil
+	}
+
+	info, err := GetVideoInfo(sourcePath)
+	if err != nil {
+		return "", failedAt(models.FailureProbe, fmt.Errorf("video info extraction failed: %w", err))
+	}
+	if info.HDR() != HDRNone {
+		// Joining SDR clips would need tone mapping the ladder does not do
+		p.logger.Warnf("Source of job %s is HDR, leaving out its bumpers", p.job.JobID)
+		return sourcePath, nil
+	}
+
+	bumperDir := filepath.Join(p.tempDir, "bumpers")
+	if err := os.MkdirAll(bumperDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create bumper directory: %w", err)
+	}
+
+	source, err := probeBumperClip(sourcePath)
+	if err != nil {
+		return "", failedAt(models.FailureProbe, fmt.Errorf("failed to probe source: %w", err))
+	}
+	var clips []bumperClip
+	if p.job.IntroS3Key != "" {
+		intro, err := p.fetchBumper(ctx, bumperDir, "intro", p.job.IntroS3Key)
+		if err != nil {
+			return "", err
+		}
+		p.introDuration = intro.duration
+		clips = append(clips, intro)
+	}
+	clips = append(clips, source)
+	if p.job.OutroS3Key != "" {
+		outro, err := p.fetchBumper(ctx, bumperDir, "outro", p.job.OutroS3Key)
+		if err != nil {
+			return "", err
+		}
+		clips = append(clips, outro)
+	}
+
+	outputPath := filepath.Join(bumperDir, "joined.mp4")
+	args := []string{"-y", "-hide_banner", "-loglevel", "error"}
+	for _, clip := range clips {
+		args = append(args, "-i", clip.path)
+	}
+
+	// Clips without audio get silence so the concat keeps the source's track
+	width, height := info.DisplayWidth&^1, info.DisplayHeight&^1
+	var filters, concatInputs []string
+	silence := len(clips)
+	for i, clip := range clips {
+		video := fmt.Sprintf("[%d:v:0]scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1",
+			i, width, height, width, height)
+		if info.FrameRateNum > 0 && info.FrameRateDen > 0 {
+			video += fmt.Sprintf(",fps=%d/%d", info.FrameRateNum, info.FrameRateDen)
+		}
+		filters = append(filters, video+fmt.Sprintf(",format=yuv420p[v%d]", i))
+		concatInputs = append(concatInputs, fmt.Sprintf("[v%d]", i))
+		if !source.hasAudio {
+			continue
+		}
+
+		audio := fmt.Sprintf("[%d:a:0]", i)
+		if !clip.hasAudio {
+			args = append(args, "-f", "lavfi", "-t", strconv.FormatFloat(clip.duration, 'f', 3, 64),
+				"-i", fmt.Sprintf("anullsrc=r=%d:cl=stereo", bumperAudioRate))
+			audio = fmt.Sprintf("[%d:a:0]", silence)
+			silence++
+		}
+		filters = append(filters, audio+fmt.Sprintf("aresample=%d,aformat=sample_fmts=fltp:channel_layouts=stereo[a%d]", bumperAudioRate, i))
+		concatInputs[len(concatInputs)-1] += fmt.Sprintf("[a%d]", i)
+	}
+	maps := []string{"-map", "[v]"}
+	if source.hasAudio {
+		filters = append(filters, fmt.Sprintf("%sconcat=n=%d:v=1:a=1[v][a]", strings.Join(concatInputs, ""), len(clips)))
+		maps = append(maps, "-map", "[a]")
+	} else {
+		filters = append(filters, fmt.Sprintf("%sconcat=n=%d:v=1:a=0[v]", strings.Join(concatInputs, ""), len(clips)))
+	}
+
+	args = append(args, "-filter_complex", strings.Join(filters, ";"))
+	args = append(args, maps...)
+	args = append(args,
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", strconv.Itoa(intermediateCRF),
+		"-c:a", "aac",
+		"-b:a", "192k",
+		"-movflags", "+faststart",
+		outputPath,
+	)
+
+	p.logger.Infof("Joining bumpers to the source of job %s", p.job.JobID)
+	cmd := ffmpegCommand(args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", failedAt(models.FailureEncode, fmt.Errorf("failed to join bumpers: %v, stderr: %s", err, stderr.String()))
+	}
+	return outputPath, nil
+}
+
+// fetchBumper downloads the bumper at key into dir and probes it
+func (p *videoProcessor) fetchBumper(ctx context.Context, dir, name, key string) (bumperClip, error) {
+	localPath := filepath.Join(dir, name+filepath.Ext(key))
+	if err := p.downloadObject(kms.WithoutDataKey(ctx), p.cfg.S3.InputBucket, key, localPath); err != nil {
+		return bumperClip{}, failedAt(models.FailureDownload, fmt.Errorf("failed to download %s: %w", name, err))
+	}
+	clip, err := probeBumperClip(localPath)
+	if err != nil {
+		return bumperClip{}, failedAt(models.FailureProbe, fmt.Errorf("failed to probe %s: %w", name, err))
+	}
+	return clip, nil
+}
+
+// probeBumperClip reads the duration and audio presence of a clip to join
+func probeBumperClip(path string) (bumperClip, error) {
+	info, err := GetVideoInfo(path)
+	if err != nil {
+		return bumperClip{}, err
+	}
+	_, hasAudio, err := probeStreamTypes(path)
+	if err != nil {
+		return bumperClip{}, err
+	}
+	return bumperClip{path: path, duration: info.Duration, hasAudio: hasAudio}, nil
+}
+
+// offsetSubtitles shifts subtitles extracted from the source by the length
+// of its intro. Files that cannot be shifted are left out rather than shown
+// out of sync.
+func (p *videoProcessor) offsetSubtitles(files []string) []string {
+	if p.introDuration <= 0 {
+		return files
+	}
+	shifted := make([]string, 0, len(files))
+	for _, file := range files {
+		tmpPath := file + ".shifted.vtt"
+		cmd := ffmpegCommand("-y", "-hide_banner", "-loglevel", "error",
+			"-itsoffset", strconv.FormatFloat(p.introDuration, 'f', 3, 64),
+			"-i", file, "-c:s", "webvtt", tmpPath)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			p.logger.Warnf("Failed to shift subtitle %s: %v, stderr: %s", file, err, stderr.String())
+			continue
+		}
+		if err := os.Rename(tmpPath, file); err != nil {
+			p.logger.Warnf("Failed to replace subtitle %s: %v", file, err)
+			continue
+		}
+		shifted = append(shifted, file)
+	}
+	return shifted
+}
@@ -0,0 +1,134 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
+)
+
+// outputBaseKey returns the prefix the outputs of a job are stored under
+func outputBaseKey(job *models.EncodeJob) string {
+	outputKey := strings.Trim(job.OutputS3Key, "/")
+	return strings.TrimSuffix(outputKey, filepath.Ext(outputKey))
+}
+
+// copyCachedOutput looks up an earlier encode of the source at localPath
+// with the profile of the job and copies its outputs to the job's prefix
+// within the output bucket. It returns nil when there is nothing to copy,
+// in which case the job is encoded as usual.
+func (p *videoProcessor) copyCachedOutput(ctx context.Context, localPath string) *ProcessingResult {
+	job := p.job
+	if !job.Cacheable() {
+		return nil
+	}
+	sourceHash, err := hashFile(localPath)
+	if err != nil {
+		p.logger.Warnf("Not caching output of job %s: %v", job.JobID, err)
+		return nil
+	}
+	p.sourceHash = sourceHash
+	if job.BypassCache {
+		return nil
+	}
+
+	userID, err := uuid.Parse(job.UserID)
+	if err != nil {
+		return nil
+	}
+	entry, err := p.videoRepo.GetOutputCache(ctx, userID, sourceHash, job.ProfileHash())
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			p.logger.Warnf("Failed to look up output cache of job %s: %v", job.JobID, err)
+		}
+		return nil
+	}
+	info, err := p.videoRepo.GetPlaybackInfo(ctx, entry.VideoID)
+	if err != nil || info.Version != 0 {
+		// The cached video was re-encoded since; its playback info no longer
+		// describes the cached outputs
+		return nil
+	}
+
+	keys, err := p.awsRepo.ListObjectsWithPrefix(ctx, p.cfg.S3.OutputBucket, entry.OutputPrefix+"/")
+	if err != nil || len(keys) == 0 {
+		p.logger.Warnf("Cached output of job %s under %s is gone: %v", job.JobID, entry.OutputPrefix, err)
+		return nil
+	}
+	baseKey := outputBaseKey(job)
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, entry.OutputPrefix+"/")
+		if isVersionPath(name) {
+			continue
+		}
+		if err := p.awsRepo.CopyObject(ctx, p.cfg.S3.OutputBucket, key, baseKey+"/"+name); err != nil {
+			p.logger.Warnf("Failed to copy cached output %s of job %s, encoding instead: %v", key, job.JobID, err)
+			return nil
+		}
+	}
+	p.logger.Infof("Copied %d cached outputs of video %s to job %s", len(keys), entry.VideoID, job.JobID)
+	return cachedResult(info, fmt.Sprintf("%s/%s", p.cfg.S3.CDNEndpoint, entry.OutputPrefix))
+}
+
+// cachedResult rebuilds the result of the encode that produced info, whose
+// URLs are relative to baseURL.
+func cachedResult(info *models.PlaybackInfo, baseURL string) *ProcessingResult {
+	relative := func(url string) string {
+		return strings.TrimPrefix(url, baseURL+"/")
+	}
+	result := &ProcessingResult{
+		Duration:      info.Duration,
+		SubtitleFiles: info.Subtitles,
+		Thumbnails:    info.Thumbnails,
+		DownloadFiles: make(map[models.VideoQuality]string),
+		Chapters:      info.Chapters,
+		Cached:        true,
+	}
+	if info.Preview != "" {
+		result.Preview = relative(info.Preview)
+	}
+	for quality, qualityInfo := range info.Qualities {
+		if quality == models.QualityMaster {
+			continue
+		}
+		result.Qualities = append(result.Qualities, models.InputQualityInfo{
+			Quality:    quality,
+			Resolution: qualityInfo.Resolution,
+			Bitrate:    qualityInfo.Bitrate,
+			MaxBitrate: int(float64(qualityInfo.Bitrate) * 1.2),
+			MinBitrate: int(float64(qualityInfo.Bitrate) * 0.8),
+			FrameRate:  qualityInfo.FrameRate,
+		})
+		if qualityInfo.URLs.MP4 != "" {
+			result.DownloadFiles[quality] = relative(qualityInfo.URLs.MP4)
+		}
+	}
+	return result
+}
+
+// saveOutputCache records the outputs of a finished encode so later jobs of
+// the user with the same source and profile can copy them
+func (w *Worker) saveOutputCache(ctx context.Context, job *models.EncodeJob, videoID uuid.UUID, result *ProcessingResult) {
+	if result.SourceHash == "" || result.Cached {
+		return
+	}
+	userID, err := uuid.Parse(job.UserID)
+	if err != nil {
+		return
+	}
+	entry := &models.OutputCacheEntry{
+		UserID:       userID,
+		SourceHash:   result.SourceHash,
+		ProfileHash:  job.ProfileHash(),
+		VideoID:      videoID,
+		OutputPrefix: outputBaseKey(job),
+	}
+	if err := w.videoRepo.SaveOutputCache(ctx, entry); err != nil {
+		w.jobLogger(job).Warnf("Failed to cache output of job %s: %v", job.JobID, err)
+	}
+}
@@ -0,0 +1,162 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+const (
+	// ChaptersVTTName and ChaptersJSONName are the chapter files written
+	// next to the master playlist
+	ChaptersVTTName  = "chapters.vtt"
+	ChaptersJSONName = "chapters.json"
+	// ChaptersDataID names the chapters in the master playlist's
+	// EXT-X-SESSION-DATA
+	ChaptersDataID = "com.streamscale.chapters"
+)
+
+// chapters returns the chapters of the output: those the user gave or else
+// those embedded in the source, shifted past the intro and ending where the
+// next one starts. Chapters past the end of the output are dropped.
+func (p *videoProcessor) chapters(sourcePath string, duration float64) []models.Chapter {
+	chapters := p.job.Chapters
+	if len(chapters) == 0 {
+		extracted, err := extractChapters(sourcePath)
+		if err != nil {
+			p.logger.Warnf("Chapter extraction failed: %v", err)
+		}
+		chapters = extracted
+	}
+	if len(chapters) == 0 {
+		return nil
+	}
+
+	shifted := make([]models.Chapter, 0, len(chapters))
+	for _, chapter := range chapters {
+		chapter.Start += p.introDuration
+		if chapter.End > 0 {
+			chapter.End += p.introDuration
+		}
+		if chapter.Start >= duration {
+			continue
+		}
+		shifted = append(shifted, chapter)
+	}
+	sort.SliceStable(shifted, func(i, j int) bool { return shifted[i].Start < shifted[j].Start })
+	for i := range shifted {
+		end := duration
+		if i+1 < len(shifted) {
+			end = shifted[i+1].Start
+		}
+		if shifted[i].End <= shifted[i].Start || shifted[i].End > end {
+			shifted[i].End = end
+		}
+	}
+	return shifted
+}
+
+// extractChapters reads the chapters embedded in a source, such as MP4
+// chapter tracks or Matroska editions
+func extractChapters(inputPath string) ([]models.Chapter, error) {
+	cmd := ffprobeCommand("-v", "quiet", "-show_chapters", "-of", "json", inputPath)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = io.Discard
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to probe chapters: %w", err)
+	}
+
+	var output struct {
+		Chapters []struct {
+			StartTime string            `json:"start_time"`
+			EndTime   string            `json:"end_time"`
+			Tags      map[string]string `json:"tags"`
+		} `json:"chapters"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return nil, fmt.Errorf("failed to parse chapters: %w", err)
+	}
+
+	chapters := make([]models.Chapter, 0, len(output.Chapters))
+	for i, c := range output.Chapters {
+		start, err := strconv.ParseFloat(c.StartTime, 64)
+		if err != nil {
+			continue
+		}
+		end, _ := strconv.ParseFloat(c.EndTime, 64)
+		title := c.Tags["title"]
+		if title == "" {
+			title = fmt.Sprintf("Chapter %d", i+1)
+		}
+		chapters = append(chapters, models.Chapter{Title: title, Start: start, End: end})
+	}
+	return chapters, nil
+}
+
+// writeChapters writes the chapters as WebVTT and JSON into the output and
+// points the master playlist at the JSON, so both HLS players and web players
+// can show them
+func writeChapters(outputPath string, chapters []models.Chapter) error {
+	var vtt strings.Builder
+	vtt.WriteString("WEBVTT\n\n")
+	for i, chapter := range chapters {
+		fmt.Fprintf(&vtt, "%d\n%s --> %s\n%s\n\n", i+1,
+			vttTimestamp(chapter.Start), vttTimestamp(chapter.End),
+			strings.ReplaceAll(chapter.Title, "\n", " "))
+	}
+	if err := os.WriteFile(filepath.Join(outputPath, ChaptersVTTName), []byte(vtt.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write chapters: %w", err)
+	}
+
+	data, err := json.Marshal(chapters)
+	if err != nil {
+		return fmt.Errorf("failed to encode chapters: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputPath, ChaptersJSONName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write chapters: %w", err)
+	}
+
+	return addChaptersToMasterPlaylist(filepath.Join(outputPath, "master.m3u8"))
+}
+
+// addChaptersToMasterPlaylist declares the chapters JSON as session data,
+// after the playlist's header tags
+func addChaptersToMasterPlaylist(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	tag := fmt.Sprintf("#EXT-X-SESSION-DATA:DATA-ID=\"%s\",URI=\"%s\"", ChaptersDataID, ChaptersJSONName)
+
+	var out strings.Builder
+	inserted := false
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if !inserted && line != "#EXTM3U" && !strings.HasPrefix(line, "#EXT-X-VERSION") && !strings.HasPrefix(line, "#EXT-X-INDEPENDENT-SEGMENTS") {
+			out.WriteString(tag)
+			out.WriteString("\n")
+			inserted = true
+		}
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+	if !inserted {
+		return fmt.Errorf("no playlist entries found in %s", path)
+	}
+
+	return os.WriteFile(path, []byte(out.String()), 0644)
+}
+
+// vttTimestamp formats seconds as a WebVTT timestamp, e.g. 01:02:03.456
+func vttTimestamp(seconds float64) string {
+	millis := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", millis/3600000, millis/60000%60, millis/1000%60, millis%1000)
+}
@@ -0,0 +1,251 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	CheckpointPrefix        = "checkpoints"
+	CheckpointUploadTimeout = 2 * time.Minute
+	LocalCheckpointFile     = "checkpoint.json"
+)
+
+var (
+	errJobInterrupted = errors.New("job interrupted")
+	// errJobCancelled is the cancellation cause of a job an admin cancelled
+	errJobCancelled = errors.New("job cancelled by an administrator")
+)
+
+// CheckpointError is returned by ProcessVideo when the job was interrupted by
+// a drain. It carries the checkpoint that should be used to resume the job.
+type CheckpointError struct {
+	Checkpoint *models.JobCheckpoint
+}
+
+func (e *CheckpointError) Error() string {
+	return fmt.Sprintf("job %s interrupted at stage %q", e.Checkpoint.JobID, e.Checkpoint.Stage)
+}
+
+// Stage returns the last stage the job completed.
+func (p *videoProcessor) Stage() models.JobStage {
+	p.checkpointMu.Lock()
+	defer p.checkpointMu.Unlock()
+	return p.checkpoint.Stage
+}
+
+func (p *videoProcessor) markStage(stage models.JobStage) {
+	p.checkpointMu.Lock()
+	defer p.checkpointMu.Unlock()
+	p.checkpoint.Stage = stage
+	p.persistCheckpoint()
+}
+
+func (p *videoProcessor) markSegmentDone(quality models.VideoQuality, index int) {
+	p.checkpointMu.Lock()
+	defer p.checkpointMu.Unlock()
+	p.checkpoint.CompletedSegments[quality] = append(p.checkpoint.CompletedSegments[quality], index)
+	p.persistCheckpoint()
+}
+
+func (p *videoProcessor) isSegmentDone(quality models.VideoQuality, index int) bool {
+	p.checkpointMu.Lock()
+	defer p.checkpointMu.Unlock()
+	for _, idx := range p.checkpoint.CompletedSegments[quality] {
+		if idx == index {
+			return true
+		}
+	}
+	return false
+}
+
+// persistCheckpoint mirrors the checkpoint into the job's work directory so a
+// worker that crashes (rather than drains) can still pick up where it left
+// off. Callers must hold checkpointMu.
+func (p *videoProcessor) persistCheckpoint() {
+	data, err := json.Marshal(p.checkpoint)
+	if err != nil {
+		p.logger.Warnf("Failed to marshal local checkpoint: %v", err)
+		return
+	}
+
+	path := filepath.Join(p.tempDir, LocalCheckpointFile)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		p.logger.Warnf("Failed to write local checkpoint: %v", err)
+		return
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		p.logger.Warnf("Failed to save local checkpoint: %v", err)
+	}
+}
+
+// loadLocalCheckpoint returns the checkpoint a previous run of this job left in
+// the work directory, if any.
+func (p *videoProcessor) loadLocalCheckpoint() *models.JobCheckpoint {
+	data, err := os.ReadFile(filepath.Join(p.tempDir, LocalCheckpointFile))
+	if err != nil {
+		return nil
+	}
+
+	checkpoint := &models.JobCheckpoint{}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		p.logger.Warnf("Ignoring corrupt local checkpoint: %v", err)
+		return nil
+	}
+	if checkpoint.CompletedSegments == nil {
+		checkpoint.CompletedSegments = make(map[models.VideoQuality][]int)
+	}
+	return checkpoint
+}
+
+// restoreSegments works out which encoded segments from an earlier attempt
+// can be reused. Segments are taken from the local work directory when they
+// survived a crash and fetched from the checkpoint artifacts in S3 otherwise.
+// Anything that cannot be recovered is simply encoded again.
+func (p *videoProcessor) restoreSegments(ctx context.Context, segmentCount int) {
+	if p.resume == nil || p.resume.SegmentCount == 0 {
+		return
+	}
+
+	if p.resume.SegmentCount != segmentCount {
+		p.logger.Warnf("Checkpoint for job %s has %d segments but split produced %d, re-encoding everything",
+			p.job.JobID, p.resume.SegmentCount, segmentCount)
+		return
+	}
+
+	restored := 0
+	for quality, indices := range p.resume.CompletedSegments {
+		if err := os.MkdirAll(filepath.Dir(p.encodedSegmentPath(quality, 0)), 0755); err != nil {
+			p.logger.Warnf("Failed to create directory for restored %s segments: %v", quality, err)
+			continue
+		}
+
+		seen := make(map[int]bool, len(indices))
+		for _, idx := range indices {
+			if idx < 0 || idx >= segmentCount || seen[idx] {
+				continue
+			}
+			seen[idx] = true
+
+			path := p.encodedSegmentPath(quality, idx)
+			if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+				if p.resume.ArtifactPrefix == "" {
+					continue
+				}
+				s3Key := p.checkpointArtifactKey(p.resume.ArtifactPrefix, quality, idx)
+				if err := p.downloadObject(ctx, p.cfg.S3.OutputBucket, s3Key, path); err != nil {
+					p.logger.Warnf("Failed to restore checkpoint segment %s: %v", s3Key, err)
+					continue
+				}
+			}
+
+			p.markSegmentDone(quality, idx)
+			restored++
+		}
+	}
+
+	p.logger.Infof("Restored %d encoded segments for job %s", restored, p.job.JobID)
+}
+
+func (p *videoProcessor) downloadObject(ctx context.Context, bucket, key, localPath string) error {
+	object, err := p.awsRepo.GetObject(ctx, bucket, key)
+	if err != nil {
+		return fmt.Errorf("failed to get object from S3: %w", err)
+	}
+	defer object.Body.Close()
+
+	outFile, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to create local file: %w", err)
+	}
+	defer outFile.Close()
+
+	if _, err := io.Copy(outFile, object.Body); err != nil {
+		os.Remove(localPath)
+		return fmt.Errorf("failed to write local file: %w", err)
+	}
+
+	return nil
+}
+
+// removeCheckpointArtifacts deletes the segments a drained worker uploaded for
+// this job once they are no longer needed.
+func (p *videoProcessor) removeCheckpointArtifacts(ctx context.Context) {
+	if p.resume == nil || p.resume.ArtifactPrefix == "" {
+		return
+	}
+
+	for quality, indices := range p.resume.CompletedSegments {
+		for _, idx := range indices {
+			s3Key := p.checkpointArtifactKey(p.resume.ArtifactPrefix, quality, idx)
+			if err := p.awsRepo.RemoveObject(ctx, p.cfg.S3.OutputBucket, s3Key); err != nil {
+				p.logger.Warnf("Failed to remove checkpoint segment %s: %v", s3Key, err)
+			}
+		}
+	}
+}
+
+func (p *videoProcessor) encodedSegmentPath(quality models.VideoQuality, index int) string {
+	return filepath.Join(p.tempDir, "encoded_segments", string(quality), fmt.Sprintf("encoded_%03d.mp4", index))
+}
+
+func (p *videoProcessor) checkpointArtifactKey(prefix string, quality models.VideoQuality, index int) string {
+	return fmt.Sprintf("%s/%s/encoded_%03d.mp4", prefix, quality, index)
+}
+
+// interrupt uploads every segment encoded so far to the output bucket and
+// returns a CheckpointError describing them, unless the job was cancelled. The job context is already
+// cancelled at this point, so uploads run on a bounded context detached from
+// it that still carries the job's values such as its data key.
+func (p *videoProcessor) interrupt(jobCtx context.Context) error {
+	// A cancelled job will not be resumed, so there is nothing to save
+	if errors.Is(context.Cause(jobCtx), errJobCancelled) {
+		return failedAt(models.FailureCancelled, errJobCancelled)
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(jobCtx), CheckpointUploadTimeout)
+	defer cancel()
+
+	p.checkpointMu.Lock()
+	defer p.checkpointMu.Unlock()
+
+	checkpoint := p.checkpoint
+	checkpoint.ResumeToken = uuid.New().String()
+	checkpoint.ArtifactPrefix = fmt.Sprintf("%s/%s", CheckpointPrefix, checkpoint.JobID)
+	checkpoint.CreatedAt = time.Now()
+
+	for quality, indices := range checkpoint.CompletedSegments {
+		uploaded := make([]int, 0, len(indices))
+		for _, idx := range indices {
+			path := p.encodedSegmentPath(quality, idx)
+			fileInfo, err := os.Stat(path)
+			if err != nil {
+				p.logger.Warnf("Checkpoint segment %s missing: %v", path, err)
+				continue
+			}
+
+			s3Key := p.checkpointArtifactKey(checkpoint.ArtifactPrefix, quality, idx)
+			if err := p.uploadSingleFileOptimized(ctx, path, s3Key, fileInfo); err != nil {
+				p.logger.Warnf("Failed to upload checkpoint segment %s: %v", s3Key, err)
+				continue
+			}
+			uploaded = append(uploaded, idx)
+		}
+		sort.Ints(uploaded)
+		checkpoint.CompletedSegments[quality] = uploaded
+	}
+
+	p.logger.Infof("Checkpointed job %s at stage %s with token %s", checkpoint.JobID, checkpoint.Stage, checkpoint.ResumeToken)
+	return &CheckpointError{Checkpoint: checkpoint}
+}
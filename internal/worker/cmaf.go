@@ -0,0 +1,142 @@
+package worker
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// CMAFAudioName names the audio track of CMAF output
+	CMAFAudioName = "audio"
+	// cmafInitTemplate and cmafSegmentTemplate place every track in its own
+	// directory with the names mp4dash uses, so repackaging finds them alike
+	cmafInitTemplate    = "$RepresentationID$/" + packagedInitSegment
+	cmafSegmentTemplate = "$RepresentationID$/seg-$Number$.m4s"
+)
+
+// cmafInput is a file handed to the CMAF packager. Name identifies its video
+// track, e.g. as the directory of its low-latency playlist.
+type cmafInput struct {
+	Path string
+	Name string
+}
+
+// cmafTrack is a track of CMAF output: an init segment and numbered media
+// segments in Dir, relative to the output root.
+type cmafTrack struct {
+	Dir  string
+	Name string
+}
+
+// packageCMAF packages inputs once into CMAF fragmented MP4 segments that the
+// DASH manifest and the HLS playlists both reference, so dual-format output
+// costs no more storage or upload than either format alone. The video of
+// every input becomes a representation; the audio is taken from the first
+// input that has any. ffmpeg cannot signal DRM in the manifests, encrypted
+// output is packaged by mp4dash instead.
+func (p *videoProcessor) packageCMAF(inputs []cmafInput, outputPath string, opts stitchAndPackageOptions) ([]cmafTrack, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no inputs to package")
+	}
+
+	args := []string{"-y", "-hide_banner", "-loglevel", "error"}
+	for _, input := range inputs {
+		args = append(args, "-i", input.Path)
+	}
+
+	var tracks []cmafTrack
+	var maps, videoStreams []string
+	audioInput := -1
+	for i, input := range inputs {
+		hasVideo, hasAudio, err := probeStreamTypes(input.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to probe %s: %w", input.Name, err)
+		}
+		if hasVideo {
+			videoStreams = append(videoStreams, strconv.Itoa(len(tracks)))
+			tracks = append(tracks, cmafTrack{Dir: strconv.Itoa(len(tracks)), Name: input.Name})
+			maps = append(maps, "-map", fmt.Sprintf("%d:v:0", i))
+		}
+		if hasAudio && audioInput == -1 {
+			audioInput = i
+		}
+	}
+	if len(videoStreams) == 0 {
+		return nil, fmt.Errorf("no video to package")
+	}
+	adaptationSets := "id=0,streams=" + strings.Join(videoStreams, ",")
+	if audioInput != -1 {
+		adaptationSets += fmt.Sprintf(" id=1,streams=%d", len(tracks))
+		tracks = append(tracks, cmafTrack{Dir: strconv.Itoa(len(tracks)), Name: CMAFAudioName})
+		maps = append(maps, "-map", fmt.Sprintf("%d:a:0", audioInput))
+	}
+
+	// ffmpeg writes into the track directories but does not create them
+	for _, track := range tracks {
+		if err := os.MkdirAll(filepath.Join(outputPath, track.Dir), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create track directory: %w", err)
+		}
+	}
+
+	args = append(args, maps...)
+	args = append(args,
+		"-c", "copy",
+		"-f", "dash",
+		"-dash_segment_type", "mp4",
+		"-seg_duration", strconv.Itoa(opts.segmentDuration),
+		"-use_template", "1",
+		"-use_timeline", "1",
+		"-adaptation_sets", adaptationSets,
+		"-init_seg_name", cmafInitTemplate,
+		"-media_seg_name", cmafSegmentTemplate,
+	)
+	if opts.withHLS {
+		args = append(args, "-hls_playlist", "1", "-hls_master_name", "master.m3u8")
+	}
+	if opts.lowLatency {
+		// Chunk segments so low-latency playlists can serve them as parts
+		args = append(args,
+			"-frag_type", "duration",
+			"-frag_duration", strconv.FormatFloat(LLHLSPartDuration, 'f', -1, 64))
+	}
+	args = append(args, filepath.Join(outputPath, DASHManifestName))
+
+	p.logger.Infof("Packaging %d tracks as CMAF into %s", len(tracks), outputPath)
+
+	cmd := ffmpegCommand(args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %v, stderr: %s", err, stderr.String())
+	}
+
+	if opts.lowLatency {
+		if err := p.packageLowLatencyHLS(outputPath, tracks); err != nil {
+			return nil, fmt.Errorf("failed to package low-latency HLS: %w", err)
+		}
+	}
+	return tracks, nil
+}
+
+// probeStreamTypes reports whether a file has a video and an audio stream
+func probeStreamTypes(path string) (bool, bool, error) {
+	output, err := ffprobeCommand("-v", "quiet", "-show_entries", "stream=codec_type",
+		"-of", "csv=p=0", path).Output()
+	if err != nil {
+		return false, false, err
+	}
+	var hasVideo, hasAudio bool
+	for _, line := range strings.Fields(string(output)) {
+		switch strings.TrimRight(line, ",") {
+		case "video":
+			hasVideo = true
+		case "audio":
+			hasAudio = true
+		}
+	}
+	return hasVideo, hasAudio, nil
+}
@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+)
+
+const (
+	CPUBudgetInterval = 5 * time.Second
+	// MinEncoderSlots keeps jobs moving even when the API is saturating the host
+	MinEncoderSlots = 1
+	// CPUBudgetSlack is how far, in percent, host CPU must be under the
+	// worker's maximum before another encoder slot is opened
+	CPUBudgetSlack = 10.0
+)
+
+// trackEncodeBudget resizes the encode scheduler from live CPU readings. The
+// slots are capped by GetMaxConcurrentEncoders and, when the worker shares
+// the host with the API, scaled down by the CPU the API has recently used:
+// the worker may use MaxCPUUsage percent of the host minus the API's usage
+// and the configured headroom. Within that cap a slot is closed whenever the
+// host is above MaxCPUUsage, since more encodes would only thrash, and one is
+// opened again once it has CPUBudgetSlack to spare while segments wait.
+func (w *Worker) trackEncodeBudget(ctx context.Context, host *utils.HostCPUSampler, api *utils.CgroupCPUSampler) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(CPUBudgetInterval)
+	defer ticker.Stop()
+
+	maxUsage := w.cfg.Worker.MaxCPUUsage
+	if maxUsage <= 0 || maxUsage > 100 {
+		maxUsage = 100
+	}
+	maxSlots := GetMaxConcurrentEncoders()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			ceiling := maxSlots
+			var apiUsage float64
+			if api != nil {
+				var err error
+				if apiUsage, err = api.Sample(); err != nil {
+					w.logger.Warnf("Failed to sample API CPU usage: %v", err)
+					continue
+				}
+				budget := maxUsage - apiUsage - w.cfg.Worker.CPUHeadroom
+				ceiling = int(math.Floor(float64(maxSlots) * budget / maxUsage))
+			}
+
+			previous := w.encoders.Limit()
+			slots := previous
+			hostUsage, err := host.Sample()
+			if err != nil {
+				w.logger.Warnf("Failed to sample host CPU usage: %v", err)
+			} else {
+				active, waiting := w.encoders.Load()
+				switch {
+				case hostUsage > maxUsage && active >= previous:
+					slots--
+				case hostUsage < maxUsage-CPUBudgetSlack && waiting > 0:
+					slots++
+				}
+			}
+			slots = max(MinEncoderSlots, min(slots, min(ceiling, maxSlots)))
+
+			if slots != previous {
+				w.logger.Infof("Host using %.1f%% CPU, API %.1f%%: encoder slots %d -> %d",
+					hostUsage, apiUsage, previous, slots)
+				w.encoders.SetLimit(slots)
+			}
+		}
+	}
+}
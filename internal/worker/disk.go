@@ -0,0 +1,157 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+const (
+	// DefaultDiskFactorPerQuality is the disk a job needs per quality of its
+	// ladder, as a multiple of its source size: encoded segments, the
+	// stitched rendition and the packaged output
+	DefaultDiskFactorPerQuality = 1.5
+	// DiskSpaceRetryInterval is how long a job that does not fit on the
+	// work disk waits before it is queued again
+	DiskSpaceRetryInterval = 30 * time.Second
+	// DiskUsageInterval is how often the work disk metrics are refreshed
+	DiskUsageInterval = 30 * time.Second
+)
+
+var (
+	workDiskFreeBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "worker_work_disk_free_bytes",
+		Help: "Bytes available on the disk of the worker's work directory",
+	})
+
+	workDiskTotalBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "worker_work_disk_total_bytes",
+		Help: "Size of the disk of the worker's work directory",
+	})
+
+	jobsDeferredForDiskTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "worker_jobs_deferred_for_disk_total",
+		Help: "Number of jobs held back because the work disk could not fit them",
+	})
+)
+
+// setupWorkDir creates the configured work directory, TempDir unless the
+// config sets one, and returns it absolute so ffmpeg and the sandbox see the
+// same path wherever they run from.
+func setupWorkDir(cfg config.WorkerConfig) (string, error) {
+	dir := cfg.WorkDir
+	if dir == "" {
+		dir = TempDir
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// diskUsage returns the bytes available to the worker and the size of the
+// disk holding dir
+func diskUsage(dir string) (uint64, uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, 0, err
+	}
+	blockSize := uint64(stat.Bsize)
+	return stat.Bavail * blockSize, stat.Blocks * blockSize, nil
+}
+
+// estimateDiskNeed is the disk a job is expected to use at its peak: its
+// source and split segments, plus the outputs of every quality of its ladder
+func (w *Worker) estimateDiskNeed(job *models.EncodeJob, sourceSize int64) uint64 {
+	factor := w.cfg.Worker.DiskFactorPerQuality
+	if factor <= 0 {
+		factor = DefaultDiskFactorPerQuality
+	}
+	qualities := max(len(job.Qualities), 1)
+	return uint64(float64(sourceSize) * (2 + factor*float64(qualities)))
+}
+
+// checkDiskSpace reports whether the work disk can fit job on top of the
+// configured free space reserve and the disk reserved for the jobs already
+// admitted, reserving the job's estimate if so. Jobs are let through when
+// their source size or the free space cannot be found out.
+func (w *Worker) checkDiskSpace(ctx context.Context, job *models.EncodeJob) bool {
+	log := w.jobLogger(job)
+	videoID, err := uuid.Parse(job.VideoID)
+	if err != nil {
+		return true
+	}
+	video, err := w.videoRepo.GetVideoByID(ctx, videoID)
+	if err != nil || video.FileSize <= 0 {
+		return true
+	}
+	free, _, err := diskUsage(w.workDir)
+	if err != nil {
+		log.Warnf("Failed to check free space of %s: %v", w.workDir, err)
+		return true
+	}
+	workDiskFreeBytes.Set(float64(free))
+
+	estimate := w.estimateDiskNeed(job, video.FileSize)
+	need := estimate + uint64(w.cfg.Worker.MinFreeDiskMB)*1024*1024
+
+	w.diskMu.Lock()
+	defer w.diskMu.Unlock()
+	if w.diskReserved < free && need <= free-w.diskReserved {
+		w.diskReservations[job.JobID] += estimate
+		w.diskReserved += estimate
+		return true
+	}
+	jobsDeferredForDiskTotal.Inc()
+	log.Warnf("Job %s needs about %d MB of disk but %d MB is free and %d MB is reserved by other jobs, holding it back",
+		job.JobID, need>>20, free>>20, w.diskReserved>>20)
+	return false
+}
+
+// releaseDisk frees the disk checkDiskSpace reserved for job. Releasing a
+// job that reserved nothing is harmless.
+func (w *Worker) releaseDisk(job *models.EncodeJob) {
+	w.diskMu.Lock()
+	defer w.diskMu.Unlock()
+
+	w.diskReserved -= w.diskReservations[job.JobID]
+	delete(w.diskReservations, job.JobID)
+}
+
+// reportDiskUsage refreshes the work disk metrics until ctx is done
+func (w *Worker) reportDiskUsage(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(DiskUsageInterval)
+	defer ticker.Stop()
+
+	for {
+		if free, total, err := diskUsage(w.workDir); err != nil {
+			w.logger.Warnf("Failed to read disk usage of %s: %v", w.workDir, err)
+		} else {
+			workDiskFreeBytes.Set(float64(free))
+			workDiskTotalBytes.Set(float64(total))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+const DownloadsDir = "downloads"
+
+// uploadDownloads publishes the stitched MP4 of each requested quality as a
+// progressive download. The files are remuxed with the moov atom in front so
+// they can start playing before they are fully downloaded, and are served
+// as attachments named after the source file. It returns the object names of
+// the uploaded files relative to outputKey.
+func (p *videoProcessor) uploadDownloads(ctx context.Context, qualities []models.VideoQuality, outputKey string) (map[models.VideoQuality]string, error) {
+	downloadDir := filepath.Join(p.tempDir, DownloadsDir)
+	if err := os.MkdirAll(downloadDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create download directory: %w", err)
+	}
+
+	baseKey := strings.TrimSuffix(outputKey, filepath.Ext(outputKey))
+	title := strings.TrimSuffix(filepath.Base(p.job.InputS3Key), filepath.Ext(p.job.InputS3Key))
+	downloads := make(map[models.VideoQuality]string)
+	for _, quality := range qualities {
+		if p.job.DownloadQuality != "" && quality != p.job.DownloadQuality {
+			continue
+		}
+
+		stitchedPath := filepath.Join(p.tempDir, "packaging", fmt.Sprintf("stitched_%s.mp4", quality))
+		if _, err := os.Stat(stitchedPath); err != nil {
+			p.logger.Warnf("No stitched %s rendition to offer for download: %v", quality, err)
+			continue
+		}
+
+		downloadPath := filepath.Join(downloadDir, fmt.Sprintf("%s.mp4", quality))
+		cmd := ffmpegCommandContext(ctx, "-y", "-hide_banner", "-loglevel", "error",
+			"-i", stitchedPath, "-c", "copy", "-movflags", "+faststart", downloadPath)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("failed to prepare %s download: %v, stderr: %s", quality, err, stderr.String())
+		}
+
+		fileInfo, err := os.Stat(downloadPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s download: %w", quality, err)
+		}
+
+		objectName := fmt.Sprintf("%s/%s.mp4", DownloadsDir, quality)
+		disposition := mime.FormatMediaType("attachment", map[string]string{
+			"filename": fmt.Sprintf("%s_%s.mp4", title, quality),
+		})
+		if err := p.uploadFileWithDisposition(ctx, downloadPath, fmt.Sprintf("%s/%s", baseKey, objectName), fileInfo, disposition); err != nil {
+			return nil, fmt.Errorf("failed to upload %s download: %w", quality, err)
+		}
+		downloads[quality] = objectName
+	}
+
+	if p.job.DownloadQuality != "" && len(downloads) == 0 {
+		return nil, fmt.Errorf("quality %s is not available for download", p.job.DownloadQuality)
+	}
+	return downloads, nil
+}
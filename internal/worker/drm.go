@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/drm"
+)
+
+const DRMKeyRequestTimeout = 30 * time.Second
+
+// drmScheme returns the configured common encryption scheme. cbcs is the
+// default as it is the only one both Widevine and FairPlay players accept.
+func drmScheme(scheme string) string {
+	if scheme == drm.SchemeCENC {
+		return drm.SchemeCENC
+	}
+	return drm.SchemeCBCS
+}
+
+// acquireContentKey requests the key the video is packaged with from the
+// configured key server.
+func (p *videoProcessor) acquireContentKey(ctx context.Context) error {
+	provider, err := drm.NewKeyProvider(p.cfg.DRM.KeyServerURL, p.cfg.DRM.KeyServerToken, DRMKeyRequestTimeout, outboundTLS)
+	if err != nil {
+		return err
+	}
+
+	key, err := provider.GetContentKey(ctx, p.job.VideoID)
+	if err != nil {
+		return err
+	}
+
+	p.contentKey = key
+	p.logger.Infof("Acquired DRM key %s for video %s", key.KeyIDHex(), p.job.VideoID)
+	return nil
+}
+
+// encryptionArgs returns the mp4dash options encrypting the packaged output
+// with key and signalling the configured DRM systems in the manifests.
+func (p *videoProcessor) encryptionArgs(key *drm.ContentKey) []string {
+	scheme := drmScheme(p.cfg.DRM.Scheme)
+	args := []string{
+		fmt.Sprintf("--encryption-key=%s:%s", key.KeyIDHex(), hex.EncodeToString(key.Key)),
+		"--encryption-cenc-scheme=" + scheme,
+	}
+
+	if p.cfg.DRM.WidevineProvider != "" {
+		args = append(args, fmt.Sprintf("--widevine-header=provider:%s#content_id:%s",
+			p.cfg.DRM.WidevineProvider, hex.EncodeToString([]byte(p.job.VideoID))))
+	}
+
+	// FairPlay only supports cbcs
+	if scheme == drm.SchemeCBCS && p.cfg.DRM.FairPlayLicenseURL != "" {
+		args = append(args, "--fairplay-key-uri=skd://"+key.KeyIDHex())
+	}
+
+	return args
+}
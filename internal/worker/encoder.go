@@ -0,0 +1,339 @@
+package worker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+// Encoder encodes renditions in one codec. Encoders register themselves with
+// RegisterEncoder and the codec of a job picks the one its segments are
+// encoded with, so a codec is added with an Encoder and no pipeline changes.
+type Encoder interface {
+	// Name is the codec of the encoder, as jobs request it
+	Name() models.Codec
+	// Probe reports what the encoder can do with the encoders ffmpeg was
+	// built with, or an error when it cannot encode at all
+	Probe(ffmpegEncoders map[string]bool) (*EncoderCapabilities, error)
+	// BuildArgs returns the arguments encoding a segment into a rendition
+	BuildArgs(opts *EncodeOptions) *EncodeArgs
+}
+
+// EncoderCapabilities is what an encoder can do on this worker
+type EncoderCapabilities struct {
+	// HWAccels are the hardware the codec can be encoded on
+	HWAccels []HardwareAccelType
+	// HDR is set when renditions keep the HDR of a source in 10 bits
+	// instead of being tone-mapped
+	HDR bool
+}
+
+func (c *EncoderCapabilities) hasHWAccel(hwAccel HardwareAccelType) bool {
+	for _, supported := range c.HWAccels {
+		if supported == hwAccel {
+			return true
+		}
+	}
+	return false
+}
+
+// EncodeOptions is what the arguments of a segment are built from
+type EncodeOptions struct {
+	Preset QualityPreset
+	// HWAccel is the hardware to encode on, HWAccelNone for software
+	HWAccel HardwareAccelType
+	// VideoFilter scales the segment in software, with the watermark and
+	// tone mapping of the job
+	VideoFilter string
+	// EncoderPreset is the speed preset recommended by benchmarks, empty
+	// to let the encoder choose
+	EncoderPreset string
+}
+
+// EncodeArgs are the arguments an encoder encodes a segment with. Colour
+// and frame rate arguments are added after Output by the pipeline.
+type EncodeArgs struct {
+	// Input goes before the input, e.g. to decode on the encoding hardware
+	Input  []string
+	Output []string
+	// Encoder and Preset are the ffmpeg encoder and speed preset used, as
+	// recorded in the job environment
+	Encoder string
+	Preset  string
+}
+
+type registeredEncoder struct {
+	encoder Encoder
+	once    sync.Once
+	caps    *EncoderCapabilities
+	err     error
+}
+
+var (
+	encoderRegistryMu sync.RWMutex
+	encoderRegistry   = map[models.Codec]*registeredEncoder{}
+)
+
+// RegisterEncoder makes an encoder available for jobs of its codec. It
+// panics when the codec has an encoder already.
+func RegisterEncoder(encoder Encoder) {
+	encoderRegistryMu.Lock()
+	defer encoderRegistryMu.Unlock()
+	if _, ok := encoderRegistry[encoder.Name()]; ok {
+		panic(fmt.Sprintf("worker: encoder for %s registered twice", encoder.Name()))
+	}
+	encoderRegistry[encoder.Name()] = &registeredEncoder{encoder: encoder}
+}
+
+// lookupEncoder returns the encoder of a codec and what it can do. Encoders
+// are probed once per worker process, when first used.
+func lookupEncoder(codec models.Codec) (Encoder, *EncoderCapabilities, error) {
+	registered, ok := registeredEncoderOf(codec)
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported codec: %s", codec)
+	}
+	registered.once.Do(func() {
+		registered.caps, registered.err = registered.encoder.Probe(ffmpegEncoders())
+	})
+	if registered.err != nil {
+		return nil, nil, fmt.Errorf("codec %s cannot be encoded on this worker: %w", codec, registered.err)
+	}
+	return registered.encoder, registered.caps, nil
+}
+
+func registeredEncoderOf(codec models.Codec) (*registeredEncoder, bool) {
+	encoderRegistryMu.RLock()
+	defer encoderRegistryMu.RUnlock()
+	registered, ok := encoderRegistry[codec]
+	return registered, ok
+}
+
+var (
+	ffmpegEncodersOnce sync.Once
+	ffmpegEncoderSet   map[string]bool
+)
+
+// ffmpegEncoders returns the names of the encoders ffmpeg was built with
+func ffmpegEncoders() map[string]bool {
+	ffmpegEncodersOnce.Do(func() {
+		ffmpegEncoderSet = map[string]bool{}
+		output, err := ffmpegCommand("-hide_banner", "-encoders").Output()
+		if err != nil {
+			return
+		}
+		// The list follows a legend ending in a line of dashes
+		listed := false
+		for _, line := range strings.Split(string(output), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			if strings.HasPrefix(fields[0], "---") {
+				listed = true
+				continue
+			}
+			if listed {
+				ffmpegEncoderSet[fields[1]] = true
+			}
+		}
+	})
+	return ffmpegEncoderSet
+}
+
+// encodeSegment encodes a segment into a rendition with the encoder of the
+// job's codec, on hardware when the encoder can use what the worker has. A
+// failed hardware encode is retried in software.
+func (p *videoProcessor) encodeSegment(inputPath, outputPath string, preset QualityPreset) error {
+	encoder, caps, err := lookupEncoder(p.job.Codec)
+	if err != nil {
+		return err
+	}
+	hwAccel := p.encodeHWAccel(caps, preset)
+	err = p.runEncoder(encoder, inputPath, outputPath, preset, hwAccel)
+	if err != nil && hwAccel != HWAccelNone {
+		p.logger.Warnf("Hardware encoding on %s failed, falling back to software: %v", hwAccel, err)
+		err = p.runEncoder(encoder, inputPath, outputPath, preset, HWAccelNone)
+	}
+	return err
+}
+
+// encodeHWAccel returns the hardware a rendition is encoded on,
+// HWAccelNone when it is encoded in software
+func (p *videoProcessor) encodeHWAccel(caps *EncoderCapabilities, preset QualityPreset) HardwareAccelType {
+	hwAccel := p.detectHardwareAcceleration()
+	if p.watermarkEnabled() || p.toneMaps(preset) || !caps.hasHWAccel(hwAccel) {
+		// The watermark is overlaid and HDR tone-mapped in software
+		return HWAccelNone
+	}
+	return hwAccel
+}
+
+// encodeArgs returns what the encoder built for a rendition and the ffmpeg
+// arguments encoding inputPath into outputPath with it
+func (p *videoProcessor) encodeArgs(encoder Encoder, inputPath, outputPath string, preset QualityPreset, hwAccel HardwareAccelType) (*EncodeArgs, []string) {
+	recommended, _ := p.recommendations.Preset(encoder.Name(), hwAccel)
+	built := encoder.BuildArgs(&EncodeOptions{
+		Preset:        preset,
+		HWAccel:       hwAccel,
+		VideoFilter:   p.scaleFilter(preset),
+		EncoderPreset: recommended,
+	})
+
+	args := []string{
+		"-y",
+		"-hide_banner",
+		"-loglevel", "error",
+	}
+	args = append(args, built.Input...)
+	args = append(args, "-i", inputPath)
+	args = append(args, built.Output...)
+	args = append(args, p.colorArgs(preset)...)
+	args = append(args, frameRateArgs(preset)...)
+	args = append(args, outputPath)
+	return built, args
+}
+
+func (p *videoProcessor) runEncoder(encoder Encoder, inputPath, outputPath string, preset QualityPreset, hwAccel HardwareAccelType) error {
+	built, args := p.encodeArgs(encoder, inputPath, outputPath, preset, hwAccel)
+	p.recordEncoder(built.Encoder, hwAccel, built.Preset)
+
+	cmd := p.encodeCommand(outputPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s encoding failed: %v, stderr: %s", built.Encoder, err, stderr.String())
+	}
+
+	if stat, err := os.Stat(outputPath); err != nil || stat.Size() == 0 {
+		return fmt.Errorf("%s encoding produced invalid output file", built.Encoder)
+	}
+
+	return nil
+}
+
+// codecStringer is implemented by encoders whose RFC 6381 codecs packagers
+// leave incomplete. The codec of a rendition depends on its size and frame
+// rate, e.g. through the codec level.
+type codecStringer interface {
+	CodecString(width, height int, frameRate float64) string
+}
+
+var (
+	mpdRepresentationPattern = regexp.MustCompile(`<Representation\b[^>]*>`)
+	mpdAttributePattern      = regexp.MustCompile(`\b(width|height|frameRate|codecs)="([^"]*)"`)
+	mpdCodecsPattern         = regexp.MustCompile(`\bcodecs="[^"]*"`)
+)
+
+// setManifestCodecs sets the video codec of every variant of the HLS master
+// playlist and every video representation of the DASH manifest at
+// outputPath, for codecs whose encoder knows it better than the packagers.
+// The encoder need not be able to encode on this worker.
+func setManifestCodecs(outputPath string, codec models.Codec) error {
+	registered, ok := registeredEncoderOf(codec)
+	if !ok {
+		return nil
+	}
+	stringer, ok := registered.encoder.(codecStringer)
+	if !ok {
+		return nil
+	}
+	if err := setMasterCodecs(filepath.Join(outputPath, "master.m3u8"), stringer); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to set codecs in master playlist: %w", err)
+	}
+	if err := setMPDCodecs(filepath.Join(outputPath, DASHManifestName), stringer); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to set codecs in dash manifest: %w", err)
+	}
+	return nil
+}
+
+func setMasterCodecs(path string, stringer codecStringer) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	for i, line := range lines {
+		attrs, ok := strings.CutPrefix(line, "#EXT-X-STREAM-INF:")
+		if !ok {
+			continue
+		}
+		var width, height int
+		if _, err := fmt.Sscanf(playlistAttribute(attrs, "RESOLUTION"), "%dx%d", &width, &height); err != nil {
+			continue
+		}
+		frameRate, _ := strconv.ParseFloat(playlistAttribute(attrs, "FRAME-RATE"), 64)
+		video := stringer.CodecString(width, height, frameRate)
+
+		codecs := []string{video}
+		for _, existing := range strings.Split(strings.Trim(playlistAttribute(attrs, "CODECS"), `"`), ",") {
+			if isAudioCodec(existing) {
+				codecs = append(codecs, existing)
+			}
+		}
+		if len(codecs) == 1 && playlistAttribute(attrs, "AUDIO") != "" {
+			codecs = append(codecs, "mp4a.40.2")
+		}
+		lines[i] = setPlaylistAttribute(line, "CODECS", `"`+strings.Join(codecs, ",")+`"`)
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+func setMPDCodecs(path string, stringer codecStringer) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	manifest := mpdRepresentationPattern.ReplaceAllStringFunc(string(data), func(tag string) string {
+		attrs := map[string]string{}
+		for _, match := range mpdAttributePattern.FindAllStringSubmatch(tag, -1) {
+			attrs[match[1]] = match[2]
+		}
+		width, _ := strconv.Atoi(attrs["width"])
+		height, _ := strconv.Atoi(attrs["height"])
+		if width == 0 || height == 0 {
+			// Audio and text representations have no size
+			return tag
+		}
+		var frameRate float64
+		if num, den, ok := parseFrameRate(attrs["frameRate"]); ok {
+			frameRate = float64(num) / float64(den)
+		}
+		codec := stringer.CodecString(width, height, frameRate)
+		if _, ok := attrs["codecs"]; ok {
+			return mpdCodecsPattern.ReplaceAllString(tag, `codecs="`+codec+`"`)
+		}
+		return strings.Replace(tag, "<Representation", `<Representation codecs="`+codec+`"`, 1)
+	})
+	return os.WriteFile(path, []byte(manifest), 0644)
+}
+
+// isAudioCodec reports whether an RFC 6381 codec is one of audio
+func isAudioCodec(codec string) bool {
+	for _, prefix := range []string{"mp4a", "ac-3", "ec-3", "opus", "flac"} {
+		if strings.HasPrefix(codec, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// probeVideoCodec returns the codec of the first video stream of path as the
+// codec of a job, e.g. of renditions that are repackaged. ffprobe's codec
+// names match the codecs jobs request.
+func probeVideoCodec(path string) (models.Codec, error) {
+	output, err := ffprobeCommand("-v", "quiet", "-select_streams", "v:0",
+		"-show_entries", "stream=codec_name", "-of", "csv=p=0", path).Output()
+	if err != nil {
+		return "", fmt.Errorf("ffprobe error: %v", err)
+	}
+	return models.Codec(strings.TrimRight(strings.TrimSpace(string(output)), ",")), nil
+}
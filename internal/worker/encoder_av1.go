@@ -0,0 +1,72 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+func init() {
+	RegisterEncoder(av1Encoder{})
+}
+
+// av1Encoder encodes AV1 with SVT-AV1. It is the only encoder keeping HDR,
+// since HDR in H.264 needs a 10-bit profile few players decode.
+type av1Encoder struct{}
+
+func (av1Encoder) Name() models.Codec {
+	return models.CodecAV1
+}
+
+func (av1Encoder) Probe(ffmpegEncoders map[string]bool) (*EncoderCapabilities, error) {
+	if !ffmpegEncoders["libsvtav1"] {
+		return nil, errors.New("ffmpeg was built without libsvtav1")
+	}
+	return &EncoderCapabilities{HDR: true}, nil
+}
+
+func (av1Encoder) BuildArgs(opts *EncodeOptions) *EncodeArgs {
+	preset := opts.Preset
+	svtPreset := opts.EncoderPreset
+	if svtPreset == "" {
+		switch cores := runtime.NumCPU(); {
+		case cores >= 32:
+			svtPreset = "8"
+		case cores >= 16:
+			svtPreset = "9"
+		case cores >= 8:
+			svtPreset = "10"
+		default:
+			svtPreset = "11"
+		}
+	}
+
+	return &EncodeArgs{
+		Encoder: "libsvtav1",
+		Preset:  svtPreset,
+		Output: []string{
+			"-c:v", "libsvtav1",
+			"-preset", svtPreset,
+			"-vf", opts.VideoFilter,
+			"-crf", "32",
+			"-maxrate", fmt.Sprintf("%dk", int(float64(preset.Bitrate)*1.1)),
+			"-bufsize", fmt.Sprintf("%dk", preset.Bitrate),
+			"-g", gopSize(preset, 120),
+			"-keyint_min", gopSize(preset, 120),
+			"-tile-columns", "4",
+			"-tile-rows", "2",
+			"-avoid_negative_ts", "make_zero",
+			"-fflags", "+genpts",
+			"-async", "1",
+			"-vsync", "cfr",
+			"-af", "aresample=async=1",
+			"-movflags", "+faststart",
+			"-c:a", "aac",
+			"-b:a", "96k",
+			"-ar", "44100",
+			"-ac", "2",
+		},
+	}
+}
@@ -0,0 +1,121 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+func init() {
+	RegisterEncoder(h264Encoder{})
+}
+
+// h264HWEncoders are the ffmpeg encoders of H.264 on each kind of hardware
+var h264HWEncoders = map[HardwareAccelType]string{
+	HWAccelNVENC: "h264_nvenc",
+	HWAccelQSV:   "h264_qsv",
+	HWAccelAMF:   "h264_amf",
+	HWAccelVAAPI: "h264_vaapi",
+}
+
+// h264Encoder encodes H.264 with x264, or on the GPU when there is one
+type h264Encoder struct{}
+
+func (h264Encoder) Name() models.Codec {
+	return models.CodecH264
+}
+
+func (h264Encoder) Probe(ffmpegEncoders map[string]bool) (*EncoderCapabilities, error) {
+	if !ffmpegEncoders["libx264"] {
+		return nil, errors.New("ffmpeg was built without libx264")
+	}
+	caps := &EncoderCapabilities{}
+	for hwAccel, encoder := range h264HWEncoders {
+		if ffmpegEncoders[encoder] {
+			caps.HWAccels = append(caps.HWAccels, hwAccel)
+		}
+	}
+	return caps, nil
+}
+
+func (h264Encoder) BuildArgs(opts *EncodeOptions) *EncodeArgs {
+	preset := opts.Preset
+	built := &EncodeArgs{Encoder: "libx264", Preset: "fast"}
+	if opts.EncoderPreset != "" {
+		built.Preset = opts.EncoderPreset
+	}
+
+	videoFilter := opts.VideoFilter
+	switch opts.HWAccel {
+	case HWAccelNVENC:
+		built.Input = []string{
+			"-hwaccel", "cuda",
+			"-hwaccel_output_format", "cuda",
+		}
+		videoFilter = fmt.Sprintf("scale_cuda=%d:%d,setsar=1", preset.Resolution[0], preset.Resolution[1])
+	case HWAccelQSV:
+		built.Input = []string{
+			"-hwaccel", "qsv",
+			"-hwaccel_output_format", "qsv",
+		}
+	case HWAccelAMF:
+		built.Input = []string{
+			"-hwaccel", "d3d11va",
+			"-hwaccel_output_format", "d3d11",
+		}
+	case HWAccelVAAPI:
+		built.Input = []string{
+			"-hwaccel", "vaapi",
+			"-hwaccel_output_format", "vaapi",
+			"-hwaccel_device", "/dev/dri/renderD128",
+		}
+		videoFilter = fmt.Sprintf("scale_vaapi=%d:%d,setsar=1", preset.Resolution[0], preset.Resolution[1])
+	}
+	if encoder, ok := h264HWEncoders[opts.HWAccel]; ok {
+		built.Encoder = encoder
+	}
+
+	built.Output = []string{
+		"-c:v", built.Encoder,
+		"-preset", built.Preset,
+		"-vf", videoFilter,
+		"-b:v", fmt.Sprintf("%dk", preset.Bitrate),
+		"-maxrate", fmt.Sprintf("%dk", int(float64(preset.Bitrate)*1.1)),
+		"-bufsize", fmt.Sprintf("%dk", preset.Bitrate),
+		"-g", gopSize(preset, 30),
+		"-keyint_min", gopSize(preset, 30),
+		"-sc_threshold", "0",
+		"-avoid_negative_ts", "make_zero",
+		"-fflags", "+genpts",
+		"-async", "1",
+		"-vsync", "cfr",
+		"-af", "aresample=async=1",
+		"-movflags", "+faststart",
+		"-c:a", "aac",
+		"-b:a", "96k",
+		"-ar", "44100",
+		"-ac", "2",
+	}
+
+	switch opts.HWAccel {
+	case HWAccelNone:
+		built.Output = append(built.Output,
+			"-profile:v", "main",
+			"-level", "3.1",
+			"-threads", fmt.Sprintf("%d", runtime.NumCPU()),
+			"-x264-params", "ref=1:bframes=0:b-adapt=0:direct=spatial:me=dia:subme=1:trellis=0:rc-lookahead=10",
+		)
+	case HWAccelNVENC:
+		built.Output = append(built.Output,
+			"-profile:v", "main",
+			"-level", "3.1",
+			"-rc", "cbr",
+			"-rc-lookahead", "8",
+			"-surfaces", "8",
+			"-bf", "0",
+		)
+	}
+	return built
+}
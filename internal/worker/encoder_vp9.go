@@ -0,0 +1,189 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+func init() {
+	RegisterEncoder(vp9Encoder{})
+}
+
+// vp9HWEncoders are the ffmpeg encoders of VP9 on each kind of hardware;
+// NVENC has none
+var vp9HWEncoders = map[HardwareAccelType]string{
+	HWAccelVAAPI: "vp9_vaapi",
+	HWAccelQSV:   "vp9_qsv",
+}
+
+// vp9Levels are the VP9 levels by the largest picture and luma sample rate
+// they allow, lowest first
+var vp9Levels = []struct {
+	level      int
+	pictureMax int
+	rateMax    float64
+}{
+	{10, 36864, 829440},
+	{11, 73728, 2764800},
+	{20, 122880, 4608000},
+	{21, 245760, 9216000},
+	{30, 552960, 20736000},
+	{31, 983040, 36864000},
+	{40, 2228224, 83558400},
+	{41, 2228224, 160432128},
+	{50, 8912896, 311951360},
+	{51, 8912896, 588251136},
+	{52, 8912896, 1176502272},
+	{60, 35651584, 1176502272},
+	{61, 35651584, 2353004544},
+	{62, 35651584, 4706009088},
+}
+
+// vp9Encoder encodes VP9 with libvpx, or on Intel and AMD GPUs through VAAPI
+// or QSV. Renditions are 8-bit profile 0, which older Android devices and
+// Chromecasts without AV1 decode in hardware; HDR sources are tone-mapped.
+type vp9Encoder struct{}
+
+func (vp9Encoder) Name() models.Codec {
+	return models.CodecVP9
+}
+
+func (vp9Encoder) Probe(ffmpegEncoders map[string]bool) (*EncoderCapabilities, error) {
+	if !ffmpegEncoders["libvpx-vp9"] {
+		return nil, errors.New("ffmpeg was built without libvpx-vp9")
+	}
+	caps := &EncoderCapabilities{}
+	for hwAccel, encoder := range vp9HWEncoders {
+		if ffmpegEncoders[encoder] {
+			caps.HWAccels = append(caps.HWAccels, hwAccel)
+		}
+	}
+	return caps, nil
+}
+
+// BuildArgs encodes in a single constrained quality pass: the CRF of the
+// rendition's height sets the quality and its bitrate caps it, which needs
+// no first pass per segment and still keeps renditions within their rung.
+func (vp9Encoder) BuildArgs(opts *EncodeOptions) *EncodeArgs {
+	preset := opts.Preset
+	width, height := preset.Resolution[0], preset.Resolution[1]
+	bitrate := []string{
+		"-b:v", fmt.Sprintf("%dk", preset.Bitrate),
+		"-maxrate", fmt.Sprintf("%dk", int(float64(preset.Bitrate)*1.45)),
+		"-bufsize", fmt.Sprintf("%dk", preset.Bitrate*2),
+	}
+	common := []string{
+		"-g", gopSize(preset, 120),
+		"-keyint_min", gopSize(preset, 120),
+		"-avoid_negative_ts", "make_zero",
+		"-fflags", "+genpts",
+		"-async", "1",
+		"-vsync", "cfr",
+		"-af", "aresample=async=1",
+		"-movflags", "+faststart",
+		"-c:a", "aac",
+		"-b:a", "96k",
+		"-ar", "44100",
+		"-ac", "2",
+	}
+
+	if encoder, ok := vp9HWEncoders[opts.HWAccel]; ok {
+		built := &EncodeArgs{Encoder: encoder}
+		var videoFilter string
+		switch opts.HWAccel {
+		case HWAccelVAAPI:
+			built.Input = []string{
+				"-hwaccel", "vaapi",
+				"-hwaccel_output_format", "vaapi",
+				"-hwaccel_device", "/dev/dri/renderD128",
+			}
+			videoFilter = fmt.Sprintf("scale_vaapi=%d:%d,setsar=1", width, height)
+		case HWAccelQSV:
+			built.Input = []string{
+				"-hwaccel", "qsv",
+				"-hwaccel_output_format", "qsv",
+			}
+			videoFilter = fmt.Sprintf("scale_qsv=w=%d:h=%d", width, height)
+		}
+		built.Output = append([]string{"-c:v", encoder, "-vf", videoFilter}, bitrate...)
+		built.Output = append(built.Output, common...)
+		return built
+	}
+
+	crf, tileColumns, threads := vp9RateControl(height)
+	speed := opts.EncoderPreset
+	if speed == "" {
+		switch cores := runtime.NumCPU(); {
+		case cores >= 16:
+			speed = "2"
+		case cores >= 8:
+			speed = "3"
+		default:
+			speed = "4"
+		}
+	}
+	built := &EncodeArgs{Encoder: "libvpx-vp9", Preset: speed}
+	built.Output = []string{
+		"-c:v", "libvpx-vp9",
+		"-vf", opts.VideoFilter,
+		"-pix_fmt", "yuv420p",
+		"-crf", fmt.Sprintf("%d", crf),
+	}
+	built.Output = append(built.Output, bitrate...)
+	built.Output = append(built.Output,
+		"-minrate", fmt.Sprintf("%dk", preset.Bitrate/2),
+		"-deadline", "good",
+		"-cpu-used", speed,
+		"-row-mt", "1",
+		"-tile-columns", fmt.Sprintf("%d", tileColumns),
+		"-frame-parallel", "0",
+		"-auto-alt-ref", "1",
+		"-lag-in-frames", "25",
+		"-threads", fmt.Sprintf("%d", threads),
+	)
+	built.Output = append(built.Output, common...)
+	return built
+}
+
+// CodecString returns the RFC 6381 codec of a rendition, e.g. vp09.00.40.08
+// for 8-bit profile 0 at level 4. Packagers leave the level and bit depth out
+// of VP9 codecs, which players need to tell whether they can decode it.
+func (vp9Encoder) CodecString(width, height int, frameRate float64) string {
+	if frameRate <= 0 {
+		frameRate = StandardFrameRateMax
+	}
+	picture := width * height
+	rate := float64(picture) * frameRate
+	level := vp9Levels[len(vp9Levels)-1].level
+	for _, l := range vp9Levels {
+		if picture <= l.pictureMax && rate <= l.rateMax {
+			level = l.level
+			break
+		}
+	}
+	return fmt.Sprintf("vp09.00.%d.08", level)
+}
+
+// vp9RateControl returns the CRF, tile columns as a log2 and threads libvpx
+// is recommended for renditions of height
+func vp9RateControl(height int) (crf, tileColumns, threads int) {
+	switch {
+	case height <= 240:
+		return 37, 0, 2
+	case height <= 360:
+		return 36, 1, 4
+	case height <= 480:
+		return 33, 1, 4
+	case height <= 720:
+		return 32, 2, 8
+	case height <= 1080:
+		return 31, 2, 8
+	case height <= 1440:
+		return 24, 3, 16
+	default:
+		return 15, 3, 16
+	}
+}
@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/kms"
+)
+
+// sourceContext returns the context used to download the source. Sources are
+// uploaded in plaintext through a presigned URL and only encrypted by the
+// first run of the job, so earlier runs must read them without a key.
+func (p *videoProcessor) sourceContext(ctx context.Context) context.Context {
+	if p.resume != nil && p.resume.SourceEncrypted {
+		return ctx
+	}
+	return kms.WithoutDataKey(ctx)
+}
+
+// encryptSource replaces the plaintext source of an encrypted job with a copy
+// encrypted under the job's data key. Outputs need no extra step since every
+// upload made with the job context is encrypted by the storage layer.
+func (p *videoProcessor) encryptSource(ctx context.Context, localPath string) error {
+	if _, ok := kms.DataKeyFromContext(ctx); !ok {
+		return nil
+	}
+
+	if p.resume == nil || !p.resume.SourceEncrypted {
+		file, err := os.Open(localPath)
+		if err != nil {
+			return fmt.Errorf("failed to open source: %w", err)
+		}
+		defer file.Close()
+
+		fileInfo, err := file.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to stat source: %w", err)
+		}
+
+		if err := p.putObject(ctx, models.UploadInput{
+			File:       file,
+			BucketName: p.cfg.S3.InputBucket,
+			Key:        p.job.InputS3Key,
+			MimeType:   getContentType(localPath),
+			Size:       fileInfo.Size(),
+		}); err != nil {
+			return fmt.Errorf("failed to upload encrypted source: %w", err)
+		}
+		p.logger.Infof("Encrypted source %s for job %s", p.job.InputS3Key, p.job.JobID)
+	}
+
+	p.checkpointMu.Lock()
+	defer p.checkpointMu.Unlock()
+	p.checkpoint.SourceEncrypted = true
+	p.persistCheckpoint()
+	return nil
+}
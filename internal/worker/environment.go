@@ -0,0 +1,106 @@
+package worker
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+// toolVersions are probed once per worker process; binaries only change when
+// the worker is redeployed.
+var (
+	toolVersionsOnce    sync.Once
+	ffmpegVersion       string
+	ffmpegConfiguration string
+	packagerVersions    map[string]string
+)
+
+func probeToolVersions() {
+	toolVersionsOnce.Do(func() {
+		output, _ := exec.Command(toolPath("ffmpeg"), "-hide_banner", "-version").CombinedOutput()
+		for _, line := range strings.Split(string(output), "\n") {
+			line = strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(line, "ffmpeg version "):
+				ffmpegVersion = strings.Fields(line)[2]
+			case strings.HasPrefix(line, "configuration:"):
+				ffmpegConfiguration = strings.TrimSpace(strings.TrimPrefix(line, "configuration:"))
+			}
+		}
+
+		packagerVersions = map[string]string{
+			// mp4fragment prints its version in the usage banner
+			"mp4fragment": firstLine(exec.Command(toolPath("mp4fragment"))),
+			"mp4dash":     firstLine(exec.Command(toolPath("mp4dash"), "--version")),
+		}
+	})
+}
+
+// firstLine returns the first non-empty line cmd prints. Tools that exit
+// non-zero when printing usage are still reported.
+func firstLine(cmd *exec.Cmd) string {
+	output, _ := cmd.CombinedOutput()
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// recordEncoder notes the encoder settings used for the job's segments. All
+// segments of a job are encoded the same way, so the first call wins.
+func (p *videoProcessor) recordEncoder(encoder string, hwAccel HardwareAccelType, preset string) {
+	p.encoderOnce.Do(func() {
+		p.encoder = encoder
+		p.hwAccel = hwAccel
+		p.encoderPreset = preset
+		p.eta.setHardware(p.HardwareClass())
+	})
+}
+
+// environment returns the snapshot of tools and settings the job was
+// encoded with.
+func (p *videoProcessor) environment(presets []QualityPreset) *models.JobEnvironment {
+	probeToolVersions()
+
+	hostname, _ := os.Hostname()
+	hwAccel := string(p.hwAccel)
+	if hwAccel == "" {
+		hwAccel = "none"
+	}
+
+	ladder := make(map[models.VideoQuality]string, len(presets))
+	for _, preset := range presets {
+		ladder[preset.Name] = fmt.Sprintf("%dx%d@%dk", preset.Resolution[0], preset.Resolution[1], preset.Bitrate)
+	}
+
+	tools := make(map[string]string, len(packagerVersions))
+	for tool, version := range packagerVersions {
+		tools[tool] = version
+	}
+
+	return &models.JobEnvironment{
+		JobID:               p.job.JobID,
+		VideoID:             p.job.VideoID,
+		Hostname:            hostname,
+		OS:                  runtime.GOOS,
+		Arch:                runtime.GOARCH,
+		CPUCount:            runtime.NumCPU(),
+		FFmpegVersion:       ffmpegVersion,
+		FFmpegConfiguration: ffmpegConfiguration,
+		Codec:               p.job.Codec,
+		Encoder:             p.encoder,
+		HWAccel:             hwAccel,
+		EncoderPreset:       p.encoderPreset,
+		QualityLadder:       ladder,
+		Tools:               tools,
+		CapturedAt:          time.Now(),
+	}
+}
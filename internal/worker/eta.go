@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+// etaEstimator estimates when a job finishes for its progress notifications.
+// Until the job has made enough progress to extrapolate its own rate, the
+// estimate is based on the speed of recent encodes of the same codec,
+// resolution and hardware class.
+type etaEstimator struct {
+	mu            sync.Mutex
+	startedAt     time.Time
+	codec         models.Codec
+	resolution    models.VideoQuality
+	hardware      models.HardwareClass
+	sourceSeconds float64
+	throughput    []*models.EncodeThroughput
+}
+
+func newETAEstimator(job *models.EncodeJob) *etaEstimator {
+	return &etaEstimator{
+		startedAt:  time.Now(),
+		codec:      job.Codec,
+		resolution: job.ResolutionClass(),
+		hardware:   models.HardwareCPU,
+	}
+}
+
+// setSource records the duration of the source once it is probed, along
+// with the throughput the estimate is based on
+func (e *etaEstimator) setSource(seconds float64, throughput []*models.EncodeThroughput) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sourceSeconds = seconds
+	e.throughput = throughput
+}
+
+// setHardware records what the job's segments are encoded on
+func (e *etaEstimator) setHardware(class models.HardwareClass) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.hardware = class
+}
+
+// remaining returns the estimated seconds until the job finishes at
+// progress, or nil when there is nothing to base it on yet
+func (e *etaEstimator) remaining(progress float64) *int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	speed, _ := models.ThroughputSpeed(e.throughput, e.codec, e.resolution, e.hardware)
+	seconds, ok := models.EstimateRemaining(time.Since(e.startedAt), progress, e.sourceSeconds, speed)
+	if !ok {
+		return nil
+	}
+	eta := int64(math.Ceil(seconds))
+	return &eta
+}
+
+// loadThroughput passes the duration of the source and the recent encode
+// throughput to the job's ETA estimate. Without throughput the estimate
+// waits for the job's own progress.
+func (p *videoProcessor) loadThroughput(ctx context.Context, sourceSeconds float64) {
+	throughput, err := p.videoRepo.GetEncodeThroughput(ctx, time.Now().Add(-models.ETAHistoryWindow))
+	if err != nil {
+		p.logger.Warnf("Failed to load encode throughput for ETA of job %s: %v", p.job.JobID, err)
+	}
+	p.eta.setSource(sourceSeconds, throughput)
+}
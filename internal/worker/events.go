@@ -0,0 +1,23 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+// recordJobEvent adds a step of the job's lifecycle to the throughput stats.
+// Stats are best effort, so failures are only logged.
+func (w *Worker) recordJobEvent(ctx context.Context, job *models.EncodeJob, event models.JobEventType, reason models.FailureReason) {
+	err := w.videoRepo.CreateJobEvent(context.WithoutCancel(ctx), &models.JobEvent{
+		JobID:         job.JobID,
+		VideoID:       job.VideoID,
+		Event:         event,
+		JobType:       job.Type,
+		Codec:         job.Codec,
+		FailureReason: reason,
+	})
+	if err != nil {
+		w.jobLogger(job).Warnf("Failed to record %s event of job %s: %v", event, job.JobID, err)
+	}
+}
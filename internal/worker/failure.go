@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+	"github.com/google/uuid"
+)
+
+// JobError attributes a processing error to the pipeline stage it happened in.
+type JobError struct {
+	Reason models.FailureReason
+	Err    error
+}
+
+func (e *JobError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *JobError) Unwrap() error {
+	return e.Err
+}
+
+func failedAt(reason models.FailureReason, err error) error {
+	return &JobError{Reason: reason, Err: err}
+}
+
+// failureReason classifies err. Storage outages and context errors take
+// precedence over the stage they surfaced in.
+func failureReason(err error) models.FailureReason {
+	switch {
+	case errors.Is(err, videofiles.ErrStorageUnavailable):
+		return models.FailureStorageUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return models.FailureTimeout
+	case errors.Is(err, context.Canceled):
+		return models.FailureCancelled
+	}
+
+	var jobErr *JobError
+	if errors.As(err, &jobErr) {
+		return jobErr.Reason
+	}
+	return models.FailureInternal
+}
+
+// failJob marks a job and its video as failed and records why, in the job
+// hash for the job status API and in the playback info shown to viewers. A
+// failed re-encode only fails its new playback version; the video keeps
+// playing the active one.
+func (w *Worker) failJob(ctx context.Context, job *models.EncodeJob, videoID uuid.UUID, err error) {
+	ctx = context.WithoutCancel(ctx)
+	reason := failureReason(err)
+	log := w.jobLogger(job)
+	if job.Type == models.JobTypePlan {
+		// A dry run never touched the video
+		w.failEncodePlan(ctx, job, reason, err)
+		return
+	}
+	if reason == models.FailureMalware {
+		w.rejectJob(ctx, job, videoID, err)
+		return
+	}
+
+	if updateErr := w.redisRepo.UpdateStatus(ctx, job.JobID, VideoJobsQueue, models.JobStatusFailed); updateErr != nil {
+		log.Errorf("Failed to update job status to failed: %v", updateErr)
+	}
+	if updateErr := w.redisRepo.UpdateFailureReason(ctx, job.JobID, reason, err.Error()); updateErr != nil {
+		log.Errorf("Failed to record failure reason: %v", updateErr)
+	}
+	if job.PlaybackVersion > 0 && job.Type != models.JobTypeRepackage {
+		w.recordJobEvent(ctx, job, models.JobEventFailed, reason)
+		w.failPlaybackVersion(ctx, job, videoID, fmt.Sprintf("%s: %v", reason, err))
+		return
+	}
+	if updateErr := w.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusFailed, 0); updateErr != nil {
+		log.Errorf("Failed to update progress on failure: %v", updateErr)
+	}
+	w.recordJobEvent(ctx, job, models.JobEventFailed, reason)
+	message := fmt.Sprintf("%s: %v", reason, err)
+	if updateErr := w.videoRepo.SetPlaybackError(ctx, videoID, filepath.Base(job.InputS3Key), message); updateErr != nil {
+		log.Errorf("Failed to record failure in playback info: %v", updateErr)
+	}
+}
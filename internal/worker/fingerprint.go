@@ -0,0 +1,112 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// FingerprintInterval is the seconds between the frames a source is
+	// fingerprinted by
+	FingerprintInterval = 1.0
+	// FingerprintMaxFrames bounds the hashes of a fingerprint; longer sources
+	// are sampled less often
+	FingerprintMaxFrames = 1800
+	// A frame is hashed by comparing the neighbouring pixels of each row of
+	// it scaled down to 9x8 grayscale
+	fingerprintWidth  = 9
+	fingerprintHeight = 8
+)
+
+// fingerprintSource stores the perceptual fingerprint of the source so the
+// video can be checked for re-uploads. It is taken before bumpers are joined,
+// which every video of an account may share. Sources without video, and
+// sources that could not be fingerprinted, are left without one.
+func (p *videoProcessor) fingerprintSource(ctx context.Context, videoID uuid.UUID, sourcePath string) error {
+	hasVideo, _, err := probeStreamTypes(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to probe streams: %w", err)
+	}
+	if !hasVideo {
+		return nil
+	}
+	info, err := GetVideoInfo(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to get source duration: %w", err)
+	}
+
+	interval := FingerprintInterval
+	if info.Duration/interval > FingerprintMaxFrames {
+		interval = info.Duration / FingerprintMaxFrames
+	}
+	hashes, err := hashFrames(ctx, sourcePath, interval)
+	if err != nil {
+		return err
+	}
+	fingerprint := &models.VideoFingerprint{VideoID: videoID, Interval: interval, Hashes: hashes}
+	if err := p.videoRepo.SaveFingerprint(ctx, fingerprint); err != nil {
+		return err
+	}
+	p.logger.Debugf("Fingerprinted source of job %s with %d frames", p.job.JobID, len(hashes))
+	return nil
+}
+
+// hashFrames returns the difference hash of a frame of sourcePath taken every
+// interval seconds
+func hashFrames(ctx context.Context, sourcePath string, interval float64) ([]int64, error) {
+	filter := fmt.Sprintf("fps=1/%s,scale=%d:%d:flags=area,format=gray",
+		strconv.FormatFloat(interval, 'f', 3, 64), fingerprintWidth, fingerprintHeight)
+	cmd := ffmpegCommandContext(ctx, "-hide_banner", "-loglevel", "error",
+		"-i", sourcePath, "-map", "0:v:0", "-an", "-sn", "-vf", filter,
+		"-f", "rawvideo", "pipe:1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ffmpeg output: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	var hashes []int64
+	reader := bufio.NewReader(stdout)
+	frame := make([]byte, fingerprintWidth*fingerprintHeight)
+	for {
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				cmd.Wait()
+				return nil, fmt.Errorf("failed to read frames: %w", err)
+			}
+			break
+		}
+		hashes = append(hashes, differenceHash(frame))
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("ffmpeg error: %v, stderr: %s", err, stderr.String())
+	}
+	return hashes, nil
+}
+
+// differenceHash sets a bit for every pixel of a 9x8 grayscale frame that is
+// brighter than its right neighbour
+func differenceHash(frame []byte) int64 {
+	var hash uint64
+	for y := 0; y < fingerprintHeight; y++ {
+		row := frame[y*fingerprintWidth : (y+1)*fingerprintWidth]
+		for x := 0; x < fingerprintWidth-1; x++ {
+			hash <<= 1
+			if row[x] > row[x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return int64(hash)
+}
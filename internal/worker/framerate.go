@@ -0,0 +1,251 @@
+package worker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+const (
+	// StandardFrameRateMax is the highest frame rate of the standard ladder.
+	// Faster sources are normalized to an even fraction of their rate, e.g.
+	// 60 to 30 and 50 to 25 fps, so frames are dropped evenly.
+	StandardFrameRateMax = 30.0
+	// HighFrameRateMin is the lowest source frame rate high frame rate
+	// variants are added for
+	HighFrameRateMin = 49.0
+	// HighFrameRateMax caps high frame rate variants, so 120 fps sources get
+	// 60 fps variants
+	HighFrameRateMax = 60.0
+	// HighFrameRateMinHeight is the lowest rendition with a high frame rate
+	// variant
+	HighFrameRateMinHeight = 720
+	// HighFrameRateBitrateFactor scales the bitrate of a high frame rate
+	// variant from its standard rendition
+	HighFrameRateBitrateFactor = 1.5
+)
+
+// highFrameRateQualities names the high frame rate variant of a rendition
+var highFrameRateQualities = map[models.VideoQuality]models.VideoQuality{
+	models.Quality1080P: models.Quality1080P60,
+	models.Quality720P:  models.Quality720P60,
+}
+
+// probeFrameRate returns the average frame rate of the first video stream as
+// a fraction. r_frame_rate is used when the container reports no average.
+func probeFrameRate(path string) (num, den int, err error) {
+	output, err := ffprobeCommand("-v", "quiet", "-select_streams", "v:0",
+		"-show_entries", "stream=avg_frame_rate,r_frame_rate",
+		"-of", "default=noprint_wrappers=1", path).Output()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	rates := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "="); ok {
+			rates[key] = value
+		}
+	}
+	for _, key := range []string{"avg_frame_rate", "r_frame_rate"} {
+		if num, den, ok := parseFrameRate(rates[key]); ok {
+			return num, den, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("no frame rate reported")
+}
+
+func parseFrameRate(value string) (num, den int, ok bool) {
+	n, d, found := strings.Cut(value, "/")
+	if !found {
+		d = "1"
+	}
+	num, err := strconv.Atoi(n)
+	if err != nil || num <= 0 {
+		return 0, 0, false
+	}
+	den, err = strconv.Atoi(d)
+	if err != nil || den <= 0 {
+		return 0, 0, false
+	}
+	return num, den, true
+}
+
+// divideFrameRate returns the fraction of the source frame rate keeping every
+// n-th frame, with the smallest n bringing it down to max.
+func divideFrameRate(num, den int, max float64) (string, float64) {
+	divisor := 1
+	for float64(num)/float64(den*divisor) > max+0.01 {
+		divisor++
+	}
+	return fmt.Sprintf("%d/%d", num, den*divisor), float64(num) / float64(den*divisor)
+}
+
+// withFrameRates sets the frame rate of the standard ladder and adds high
+// frame rate variants of the 720p and larger renditions for sources of
+// HighFrameRateMin fps or more. The ladder keeps the source's frame rate when
+// it is unknown.
+func withFrameRates(presets []QualityPreset, videoInfo *VideoInfo) []QualityPreset {
+	if videoInfo.FrameRateNum == 0 {
+		return presets
+	}
+	num, den := videoInfo.FrameRateNum, videoInfo.FrameRateDen
+
+	standard, standardRate := divideFrameRate(num, den, StandardFrameRateMax)
+	high, highRate := divideFrameRate(num, den, HighFrameRateMax)
+
+	ladder := make([]QualityPreset, 0, len(presets)+len(highFrameRateQualities))
+	var variants []QualityPreset
+	for _, preset := range presets {
+		if videoInfo.FrameRate >= HighFrameRateMin && preset.height() >= HighFrameRateMinHeight {
+			if name, ok := highFrameRateQualities[preset.Name]; ok {
+				variant := preset
+				variant.Name = name
+				variant.Bitrate = int(float64(preset.Bitrate) * HighFrameRateBitrateFactor)
+				variant.FrameRate = highRate
+				variant.frameRate = high
+				variants = append(variants, variant)
+			}
+		}
+		preset.FrameRate = standardRate
+		preset.frameRate = standard
+		ladder = append(ladder, preset)
+	}
+	return append(variants, ladder...)
+}
+
+// frameRateArgs returns the encoder arguments setting the preset's frame rate
+func frameRateArgs(preset QualityPreset) []string {
+	if preset.frameRate == "" {
+		return nil
+	}
+	return []string{"-r", preset.frameRate}
+}
+
+// gopSize scales a keyframe interval, given in frames at up to 30 fps, to the
+// preset's frame rate so keyframes stay aligned in time across renditions.
+func gopSize(preset QualityPreset, frames int) string {
+	if preset.FrameRate > StandardFrameRateMax {
+		frames = int(float64(frames) * preset.FrameRate / StandardFrameRateMax)
+	}
+	return strconv.Itoa(frames)
+}
+
+// qualityFor names a rendition after its size and frame rate
+func qualityFor(width, height int, frameRate float64) models.VideoQuality {
+	quality := rungFor(width, height)
+	if frameRate > StandardFrameRateMax {
+		if high, ok := highFrameRateQualities[quality]; ok {
+			return high
+		}
+	}
+	return quality
+}
+
+// setMasterFrameRates sets the FRAME-RATE of every variant stream of the HLS
+// master playlist. mp4dash does not tell the variants of one resolution apart
+// by frame rate, so they are matched to the renditions of that resolution in
+// order of bandwidth, which grows with the frame rate.
+func setMasterFrameRates(path string, qualities []models.InputQualityInfo) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	rates := make(map[string][]float64)
+	for _, quality := range qualities {
+		if quality.FrameRate > 0 {
+			rates[quality.Resolution] = append(rates[quality.Resolution], quality.FrameRate)
+		}
+	}
+	if len(rates) == 0 {
+		return nil
+	}
+	for _, r := range rates {
+		sort.Float64s(r)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	type variant struct {
+		line      int
+		bandwidth int
+	}
+	variants := make(map[string][]variant)
+	for i, line := range lines {
+		attrs, ok := strings.CutPrefix(line, "#EXT-X-STREAM-INF:")
+		if !ok {
+			continue
+		}
+		resolution := playlistAttribute(attrs, "RESOLUTION")
+		bandwidth, _ := strconv.Atoi(playlistAttribute(attrs, "BANDWIDTH"))
+		variants[resolution] = append(variants[resolution], variant{line: i, bandwidth: bandwidth})
+	}
+
+	for resolution, streams := range variants {
+		r := rates[resolution]
+		if len(r) == 0 {
+			continue
+		}
+		sort.SliceStable(streams, func(i, j int) bool { return streams[i].bandwidth < streams[j].bandwidth })
+		for i, stream := range streams {
+			rate := r[min(i, len(r)-1)]
+			lines[stream.line] = setPlaylistAttribute(lines[stream.line], "FRAME-RATE", strconv.FormatFloat(rate, 'f', 3, 64))
+		}
+	}
+
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// playlistAttribute returns the value of an unquoted attribute of an HLS tag
+func playlistAttribute(attrs, name string) string {
+	for _, attr := range splitPlaylistAttributes(attrs) {
+		if key, value, ok := strings.Cut(attr, "="); ok && key == name {
+			return value
+		}
+	}
+	return ""
+}
+
+// setPlaylistAttribute replaces or appends an attribute of an HLS tag line
+func setPlaylistAttribute(line, name, value string) string {
+	tag, attrs, _ := strings.Cut(line, ":")
+	parts := splitPlaylistAttributes(attrs)
+	replaced := false
+	for i, attr := range parts {
+		if key, _, ok := strings.Cut(attr, "="); ok && key == name {
+			parts[i] = name + "=" + value
+			replaced = true
+		}
+	}
+	if !replaced {
+		parts = append(parts, name+"="+value)
+	}
+	return tag + ":" + strings.Join(parts, ",")
+}
+
+// splitPlaylistAttributes splits an attribute list on the commas outside of
+// quoted values such as CODECS.
+func splitPlaylistAttributes(attrs string) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i, c := range attrs {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			parts = append(parts, attrs[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(attrs) {
+		parts = append(parts, attrs[start:])
+	}
+	return parts
+}
@@ -0,0 +1,92 @@
+package worker
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+)
+
+// DefaultNVENCSessionsPerGPU is the concurrent NVENC session limit of
+// consumer NVIDIA drivers. Data center GPUs have no such limit and can be
+// given more through the worker config.
+const DefaultNVENCSessionsPerGPU = 5
+
+// detectCapabilities counts the NVIDIA GPUs of the host and the NVENC
+// sessions they allow, and lists what ffmpeg can encode. It runs once at
+// startup; hosts without nvidia-smi, or whose ffmpeg has no NVENC, are
+// treated as CPU only.
+func (w *Worker) detectCapabilities() *models.WorkerCapabilities {
+	caps := &models.WorkerCapabilities{
+		WorkerID: w.id,
+		Queues:   []string{VideoJobsQueueKey},
+	}
+	codecs, hwAccels := encodableCodecs()
+	caps.Codecs = codecs
+	for _, hwAccel := range hwAccels {
+		caps.HWAccels = append(caps.HWAccels, string(hwAccel))
+	}
+
+	output, err := exec.Command("nvidia-smi", "--query-gpu=name", "--format=csv,noheader").Output()
+	if err != nil {
+		w.logger.Infof("No NVIDIA GPU detected, worker %s only takes CPU jobs", w.id)
+		return caps
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) != "" {
+			caps.GPUs++
+		}
+	}
+	if caps.GPUs == 0 {
+		return caps
+	}
+	if _, h264, err := lookupEncoder(models.CodecH264); err != nil || !h264.hasHWAccel(HWAccelNVENC) {
+		w.logger.Warnf("ffmpeg was built without h264_nvenc, worker %s ignores its %d GPUs", w.id, caps.GPUs)
+		return caps
+	}
+
+	sessionsPerGPU := w.cfg.Worker.NVENCSessionsPerGPU
+	if sessionsPerGPU <= 0 {
+		sessionsPerGPU = DefaultNVENCSessionsPerGPU
+	}
+	caps.NVENCSessions = caps.GPUs * sessionsPerGPU
+	// GPU workers prefer hardware jobs but still take CPU jobs when idle
+	caps.Queues = []string{VideoJobsQueueKey + videofiles.GPUQueueSuffix, VideoJobsQueueKey}
+
+	w.logger.Infof("Detected %d NVIDIA GPUs with %d NVENC sessions", caps.GPUs, caps.NVENCSessions)
+	return caps
+}
+
+// advertiseCapabilities publishes the worker's capabilities so the API can
+// route jobs to it. It is refreshed with every heartbeat.
+func (w *Worker) advertiseCapabilities(ctx context.Context) {
+	w.capabilities.UpdatedAt = time.Now()
+	if err := w.redisRepo.SetWorkerCapabilities(ctx, w.capabilities, HeartbeatTTL); err != nil {
+		w.logger.Warnf("Failed to advertise worker capabilities: %v", err)
+	}
+}
+
+// jobQueue returns the queue a job is pushed back onto when this worker
+// gives it up.
+func (w *Worker) jobQueue(ctx context.Context, job *models.EncodeJob) string {
+	return videofiles.JobQueue(VideoJobsQueue, job.Codec, gpuWorkersAvailable(ctx, w.redisRepo))
+}
+
+// gpuWorkersAvailable reports whether any live worker advertises NVENC
+// sessions. Without one, jobs stay on the CPU queue so they are not stranded.
+func gpuWorkersAvailable(ctx context.Context, redisRepo videofiles.RedisRepository) bool {
+	workers, err := redisRepo.ListWorkerCapabilities(ctx)
+	if err != nil {
+		return false
+	}
+	for _, caps := range workers {
+		if caps.HasGPU() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package worker
+
+import (
+	"bufio"
+	"strings"
+)
+
+type HDRFormat string
+
+const (
+	HDRNone HDRFormat = ""
+	HDR10   HDRFormat = "hdr10"
+	HDRHLG  HDRFormat = "hlg"
+)
+
+// HDRMinHeight is the lowest rendition kept in HDR. Smaller renditions are
+// mostly watched on devices without HDR displays and are tone-mapped.
+const HDRMinHeight = 1080
+
+// toneMapFilter converts PQ or HLG BT.2020 video to 8-bit BT.709 SDR. Scaling
+// an HDR picture as if it were SDR leaves it dim and washed out.
+const toneMapFilter = "zscale=t=linear:npl=100,format=gbrpf32le,zscale=p=bt709," +
+	"tonemap=tonemap=hable:desat=0,zscale=t=bt709:m=bt709:r=tv,format=yuv420p"
+
+// HDR returns the HDR format of the video from its transfer characteristics.
+func (v *VideoInfo) HDR() HDRFormat {
+	switch v.ColorTransfer {
+	case "smpte2084":
+		return HDR10
+	case "arib-std-b67":
+		return HDRHLG
+	default:
+		return HDRNone
+	}
+}
+
+// probeColor reads the colour primaries, transfer characteristics and matrix
+// of the first video stream. Streams that are not tagged report empty values.
+func probeColor(path string) (primaries, transfer, space string, err error) {
+	output, err := ffprobeCommand("-v", "quiet", "-select_streams", "v:0",
+		"-show_entries", "stream=color_primaries,color_transfer,color_space",
+		"-of", "default=noprint_wrappers=1", path).Output()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok || value == "unknown" {
+			continue
+		}
+		switch key {
+		case "color_primaries":
+			primaries = value
+		case "color_transfer":
+			transfer = value
+		case "color_space":
+			space = value
+		}
+	}
+	return primaries, transfer, space, nil
+}
+
+// keepsHDR reports whether the preset is encoded in HDR. Only renditions of
+// encoders capable of HDR are, which AV1 is and H.264 is not.
+func (p *videoProcessor) keepsHDR(preset QualityPreset) bool {
+	if p.hdr == HDRNone || preset.height() < HDRMinHeight {
+		return false
+	}
+	_, caps, err := lookupEncoder(p.job.Codec)
+	return err == nil && caps.HDR
+}
+
+// toneMaps reports whether an HDR source is tone-mapped to SDR for the preset
+func (p *videoProcessor) toneMaps(preset QualityPreset) bool {
+	return p.hdr != HDRNone && !p.keepsHDR(preset)
+}
+
+// colorArgs returns the encoder arguments tagging the rendition's colour. HDR
+// renditions are encoded in 10 bits with the source's BT.2020 signalling;
+// tone-mapped ones are tagged BT.709 so players do not guess.
+func (p *videoProcessor) colorArgs(preset QualityPreset) []string {
+	switch {
+	case p.keepsHDR(preset):
+		transfer := "smpte2084"
+		if p.hdr == HDRHLG {
+			transfer = "arib-std-b67"
+		}
+		return []string{
+			"-pix_fmt", "yuv420p10le",
+			"-color_primaries", "bt2020",
+			"-color_trc", transfer,
+			"-colorspace", "bt2020nc",
+		}
+	case p.toneMaps(preset):
+		return []string{
+			"-color_primaries", "bt709",
+			"-color_trc", "bt709",
+			"-colorspace", "bt709",
+		}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,92 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	HeartbeatInterval = 10 * time.Second
+	HeartbeatTTL      = 3 * HeartbeatInterval
+)
+
+// newInstanceID returns an identifier for this worker process that is unique
+// across restarts, so a restarted worker is not mistaken for the one that
+// owned a job before it died.
+func newInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "worker"
+	}
+	return fmt.Sprintf("%s-%s", hostname, uuid.New().String()[:8])
+}
+
+// status describes the worker and the stage each of its jobs has reached.
+func (w *Worker) status() *models.WorkerStatus {
+	w.runningMu.Lock()
+	defer w.runningMu.Unlock()
+
+	status := &models.WorkerStatus{
+		WorkerID:  w.id,
+		Draining:  w.draining.Load(),
+		Slots:     cap(w.semaphore),
+		FreeSlots: max(cap(w.semaphore)-len(w.running), 0),
+		Jobs:      make([]models.RunningJob, 0, len(w.running)),
+		UpdatedAt: time.Now(),
+	}
+	for _, running := range w.running {
+		job := models.RunningJob{
+			JobID:     running.job.JobID,
+			VideoID:   running.job.VideoID,
+			UserID:    running.job.UserID,
+			WorkerID:  w.id,
+			StartedAt: running.startedAt,
+		}
+		if staged, ok := running.processor.(interface{ Stage() models.JobStage }); ok {
+			job.Stage = staged.Stage()
+		}
+		status.Jobs = append(status.Jobs, job)
+	}
+	return status
+}
+
+// sendHeartbeats keeps the worker's heartbeat, running jobs, job heartbeats
+// and capabilities alive in Redis for as long as the worker is running.
+func (w *Worker) sendHeartbeats(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.redisRepo.SetWorkerHeartbeat(ctx, w.id, HeartbeatTTL); err != nil {
+			w.logger.Warnf("Failed to send worker heartbeat: %v", err)
+		}
+		status := w.status()
+		if err := w.redisRepo.SetWorkerRunningJobs(ctx, w.id, len(status.Jobs), HeartbeatTTL); err != nil {
+			w.logger.Warnf("Failed to report running jobs: %v", err)
+		}
+		if err := w.redisRepo.SetWorkerStatus(ctx, status, HeartbeatTTL); err != nil {
+			w.logger.Warnf("Failed to report worker status: %v", err)
+		}
+		for _, job := range status.Jobs {
+			if err := w.redisRepo.SetJobHeartbeat(ctx, job.JobID, w.id, HeartbeatTTL); err != nil {
+				w.logger.Warnf("Failed to send heartbeat of job %s: %v", job.JobID, err)
+			}
+		}
+		w.advertiseCapabilities(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package worker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// IntegrityManifestName is the sidecar listing the digest of every packaged
+	// file, uploaded next to the manifests
+	IntegrityManifestName = "integrity.json"
+	integrityAlgorithm    = "sha256"
+)
+
+// integrityManifest lets caches and CDN edges verify that the segments and
+// manifests they hold are the bytes the worker uploaded. Files are keyed by
+// their path relative to the manifest.
+type integrityManifest struct {
+	Algorithm string                    `json:"algorithm"`
+	Files     map[string]integrityEntry `json:"files"`
+}
+
+type integrityEntry struct {
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
+
+// writeIntegrityManifest hashes every file under outputPath and writes the
+// digests to IntegrityManifestName in it. It must run after packaging is done,
+// since files written later are not covered.
+func writeIntegrityManifest(outputPath string) error {
+	manifest := integrityManifest{
+		Algorithm: integrityAlgorithm,
+		Files:     make(map[string]integrityEntry),
+	}
+	manifestPath := filepath.Join(outputPath, IntegrityManifestName)
+
+	err := filepath.Walk(outputPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || path == manifestPath {
+			return nil
+		}
+		digest, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(outputPath, path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+		manifest.Files[filepath.ToSlash(relPath)] = integrityEntry{Digest: digest, Size: info.Size()}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to hash output: %w", err)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode integrity manifest: %w", err)
+	}
+	if err := os.WriteFile(manifestPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write integrity manifest: %w", err)
+	}
+	return nil
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
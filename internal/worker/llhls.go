@@ -0,0 +1,515 @@
+package worker
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// LLHLSDir is where the low-latency playlists are written. They serve
+	// the CMAF segments of the regular output rather than a copy.
+	LLHLSDir = "ll"
+	// LLHLSPartDuration is the target duration of a partial segment in
+	// seconds. Parts do not need to start on a keyframe.
+	LLHLSPartDuration = 1.0
+	// LLHLSPartHoldBack is how many part durations from the live edge
+	// players start, the minimum the spec allows
+	LLHLSPartHoldBack   = 3
+	LLHLSPlaylist       = "index.m3u8"
+	LLHLSMasterPlaylist = "master.m3u8"
+)
+
+// Flags of the tfhd and trun boxes, ISO/IEC 14496-12
+const (
+	tfhdBaseDataOffset    = 0x000001
+	tfhdSampleDescription = 0x000002
+	tfhdDefaultDuration   = 0x000008
+	tfhdDefaultSize       = 0x000010
+	tfhdDefaultFlags      = 0x000020
+	trunDataOffset        = 0x000001
+	trunFirstSampleFlags  = 0x000004
+	trunSampleDuration    = 0x000100
+	trunSampleSize        = 0x000200
+	trunSampleFlags       = 0x000400
+	trunSampleTimeOffset  = 0x000800
+	sampleIsNonSyncSample = 0x010000
+)
+
+// llPart is a CMAF chunk (moof + mdat) of a segment, served as an
+// EXT-X-PART by byte range.
+type llPart struct {
+	Offset      int64
+	Length      int64
+	Duration    float64
+	Independent bool
+}
+
+// llSegment is a CMAF segment of a track, served whole as a full segment and
+// chunk by chunk as its parts.
+type llSegment struct {
+	URI      string
+	Parts    []llPart
+	Duration float64
+}
+
+type mp4Box struct {
+	Type    string
+	Payload []byte
+}
+
+// packageLowLatencyHLS writes low-latency HLS playlists for the CMAF tracks
+// under outputPath/ll. The segments were packaged in short chunks, which the
+// playlists address as EXT-X-PART byte ranges so players can fetch media
+// before a full segment is complete.
+func (p *videoProcessor) packageLowLatencyHLS(outputPath string, tracks []cmafTrack) error {
+	llPath := filepath.Join(outputPath, LLHLSDir)
+	playlists := make([]string, 0, 2*len(tracks))
+	for _, track := range tracks {
+		renditionDir := filepath.Join(llPath, track.Name)
+		if err := os.MkdirAll(renditionDir, 0755); err != nil {
+			return fmt.Errorf("failed to create low-latency directory for %s: %w", track.Name, err)
+		}
+
+		// Playlists sit two levels below the output root
+		trackURI := path.Join("..", "..", track.Dir)
+		segments, err := readCMAFTrack(filepath.Join(outputPath, track.Dir), trackURI)
+		if err != nil {
+			return fmt.Errorf("failed to read track %s: %w", track.Name, err)
+		}
+
+		playlist, err := os.Create(filepath.Join(renditionDir, LLHLSPlaylist))
+		if err != nil {
+			return fmt.Errorf("failed to create low-latency playlist: %w", err)
+		}
+		_, err = writeLowLatencyPlaylist(playlist, segments, path.Join(trackURI, packagedInitSegment), true)
+		playlist.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write low-latency playlist for %s: %w", track.Name, err)
+		}
+
+		// ffmpeg names the media playlist of a track after its stream index
+		playlists = append(playlists, fmt.Sprintf("media_%s.m3u8", track.Dir), path.Join(track.Name, LLHLSPlaylist))
+	}
+
+	master, err := os.ReadFile(filepath.Join(outputPath, "master.m3u8"))
+	if err != nil {
+		return fmt.Errorf("failed to read master playlist: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(llPath, LLHLSMasterPlaylist), lowLatencyMaster(string(master), playlists), 0644); err != nil {
+		return fmt.Errorf("failed to write low-latency master playlist: %w", err)
+	}
+
+	p.logger.Infof("Packaged low-latency HLS playlists for %d tracks", len(tracks))
+	return nil
+}
+
+// lowLatencyMaster derives the low-latency master playlist from the regular
+// one, pointing its variants and renditions at the low-latency playlists.
+// playlists holds pairs of old and new URIs.
+func lowLatencyMaster(master string, playlists []string) []byte {
+	master = strings.NewReplacer(playlists...).Replace(master)
+	lines := strings.Split(strings.TrimRight(master, "\n"), "\n")
+	var b strings.Builder
+	for i, line := range lines {
+		if strings.HasPrefix(line, "#EXT-X-VERSION:") {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+		if i == 0 {
+			b.WriteString("#EXT-X-VERSION:9\n")
+		}
+	}
+	return []byte(b.String())
+}
+
+// readCMAFTrack reads the chunks of every media segment of a CMAF track
+// directory, in order. Segment URIs are prefixed with uriPrefix.
+func readCMAFTrack(dir, uriPrefix string) ([]llSegment, error) {
+	track, _, err := scanFragments(filepath.Join(dir, packagedInitSegment), nil)
+	if err != nil {
+		return nil, err
+	}
+	if track == nil {
+		return nil, fmt.Errorf("init segment has no moov")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type segmentFile struct {
+		name  string
+		index int
+	}
+	var files []segmentFile
+	for _, entry := range entries {
+		if match := packagedSegmentRe.FindStringSubmatch(entry.Name()); match != nil {
+			index, _ := strconv.Atoi(match[1])
+			files = append(files, segmentFile{name: entry.Name(), index: index})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].index < files[j].index })
+
+	segments := make([]llSegment, 0, len(files))
+	for _, file := range files {
+		_, parts, err := scanFragments(filepath.Join(dir, file.name), track)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file.name, err)
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		segment := llSegment{URI: path.Join(uriPrefix, file.name), Parts: parts}
+		for _, part := range parts {
+			segment.Duration += part.Duration
+		}
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+// writeLowLatencyPlaylist writes the media playlist of a track's segments and
+// returns its duration. Finished playlists end with EXT-X-ENDLIST; open ones,
+// as a live stream would produce, end with a preload hint for the next part
+// instead.
+func writeLowLatencyPlaylist(w io.Writer, segments []llSegment, initURI string, ended bool) (float64, error) {
+	if len(segments) == 0 {
+		return 0, fmt.Errorf("no segments found")
+	}
+
+	var targetDuration, partTarget, total float64
+	for _, segment := range segments {
+		targetDuration = math.Max(targetDuration, segment.Duration)
+		total += segment.Duration
+		for _, part := range segment.Parts {
+			partTarget = math.Max(partTarget, part.Duration)
+		}
+	}
+	partTarget = math.Ceil(partTarget*1000) / 1000
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:9\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(targetDuration)))
+	fmt.Fprintf(&b, "#EXT-X-PART-INF:PART-TARGET=%.3f\n", partTarget)
+	fmt.Fprintf(&b, "#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=%.3f\n", partTarget*LLHLSPartHoldBack)
+	b.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+	if ended {
+		b.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	}
+	fmt.Fprintf(&b, "#EXT-X-MAP:URI=\"%s\"\n", initURI)
+
+	for _, segment := range segments {
+		for _, part := range segment.Parts {
+			fmt.Fprintf(&b, "#EXT-X-PART:DURATION=%.3f,URI=\"%s\",BYTERANGE=\"%d@%d\"", part.Duration, segment.URI, part.Length, part.Offset)
+			if part.Independent {
+				b.WriteString(",INDEPENDENT=YES")
+			}
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n%s\n", segment.Duration, segment.URI)
+	}
+
+	if ended {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	} else {
+		last := segments[len(segments)-1]
+		end := last.Parts[len(last.Parts)-1]
+		fmt.Fprintf(&b, "#EXT-X-PRELOAD-HINT:TYPE=PART,URI=\"%s\",BYTERANGE-START=%d\n", last.URI, end.Offset+end.Length)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return total, err
+}
+
+// scanFragments finds the chunks of an MP4 file with the duration of each
+// and whether it starts on a sync sample. The track to time them by is read
+// from the file's moov when track is nil, and returned.
+func scanFragments(path string, track *trackInfo) (*trackInfo, []llPart, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var parts []llPart
+	var part *llPart
+
+	for offset := int64(0); offset < fileInfo.Size(); {
+		boxType, size, err := readBoxHeader(file, offset, fileInfo.Size())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch boxType {
+		case "moov":
+			if track != nil {
+				break
+			}
+			payload, err := readBoxPayload(file, offset, size)
+			if err != nil {
+				return nil, nil, err
+			}
+			if track, err = mediaTrackInfo(payload); err != nil {
+				return nil, nil, err
+			}
+		case "moof":
+			if track == nil {
+				return nil, nil, fmt.Errorf("moof before moov")
+			}
+			payload, err := readBoxPayload(file, offset, size)
+			if err != nil {
+				return nil, nil, err
+			}
+			duration, independent := track.fragmentInfo(payload)
+			part = &llPart{Offset: offset, Duration: duration, Independent: independent}
+		case "mdat":
+			if part != nil {
+				part.Length = offset + size - part.Offset
+				parts = append(parts, *part)
+				part = nil
+			}
+		}
+
+		offset += size
+	}
+
+	return track, parts, nil
+}
+
+func readBoxHeader(file *os.File, offset, fileSize int64) (string, int64, error) {
+	header := make([]byte, 16)
+	n, err := file.ReadAt(header, offset)
+	if n < 8 {
+		return "", 0, fmt.Errorf("truncated box at %d: %v", offset, err)
+	}
+
+	size := int64(binary.BigEndian.Uint32(header[0:4]))
+	switch {
+	case size == 1 && n == 16:
+		size = int64(binary.BigEndian.Uint64(header[8:16]))
+	case size == 0:
+		size = fileSize - offset
+	}
+	if size < 8 || offset+size > fileSize {
+		return "", 0, fmt.Errorf("invalid box size %d at %d", size, offset)
+	}
+	return string(header[4:8]), size, nil
+}
+
+func readBoxPayload(file *os.File, offset, size int64) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := file.ReadAt(data, offset); err != nil {
+		return nil, err
+	}
+	boxes := childBoxes(data)
+	if len(boxes) != 1 {
+		return nil, fmt.Errorf("invalid box at %d", offset)
+	}
+	return boxes[0].Payload, nil
+}
+
+// childBoxes splits the payload of a container box into its children.
+func childBoxes(data []byte) []mp4Box {
+	var boxes []mp4Box
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[0:4]))
+		header := uint64(8)
+		switch {
+		case size == 1 && len(data) >= 16:
+			size = binary.BigEndian.Uint64(data[8:16])
+			header = 16
+		case size == 0:
+			size = uint64(len(data))
+		}
+		if size < header || size > uint64(len(data)) {
+			break
+		}
+		boxes = append(boxes, mp4Box{Type: string(data[4:8]), Payload: data[header:size]})
+		data = data[size:]
+	}
+	return boxes
+}
+
+func findBox(boxes []mp4Box, boxType string) []byte {
+	for _, box := range boxes {
+		if box.Type == boxType {
+			return box.Payload
+		}
+	}
+	return nil
+}
+
+// u32 reads a big endian uint32, returning 0 past the end of data.
+func u32(data []byte, offset int) uint32 {
+	if offset < 0 || offset+4 > len(data) {
+		return 0
+	}
+	return binary.BigEndian.Uint32(data[offset : offset+4])
+}
+
+// fullBoxVersion returns the version and flags of a full box payload.
+func fullBoxVersion(data []byte) (byte, uint32) {
+	header := u32(data, 0)
+	return byte(header >> 24), header & 0xffffff
+}
+
+// trackInfo holds what is needed from the init section to time fragments of
+// a track.
+type trackInfo struct {
+	ID              uint32
+	Timescale       uint32
+	DefaultDuration uint32
+	DefaultFlags    uint32
+}
+
+// mediaTrackInfo returns the video track of a moov, or its audio track when
+// it has no video.
+func mediaTrackInfo(moov []byte) (*trackInfo, error) {
+	for _, handler := range []string{"vide", "soun"} {
+		if info, err := trackInfoByHandler(moov, handler); info != nil || err != nil {
+			return info, err
+		}
+	}
+	return nil, fmt.Errorf("no media track found")
+}
+
+func trackInfoByHandler(moov []byte, handler string) (*trackInfo, error) {
+	boxes := childBoxes(moov)
+	for _, trak := range boxes {
+		if trak.Type != "trak" {
+			continue
+		}
+		trakBoxes := childBoxes(trak.Payload)
+		mdia := childBoxes(findBox(trakBoxes, "mdia"))
+
+		hdlr := findBox(mdia, "hdlr")
+		if len(hdlr) < 12 || string(hdlr[8:12]) != handler {
+			continue
+		}
+
+		info := &trackInfo{}
+		tkhd := findBox(trakBoxes, "tkhd")
+		mdhd := findBox(mdia, "mdhd")
+		if version, _ := fullBoxVersion(tkhd); version == 1 {
+			info.ID = u32(tkhd, 20)
+		} else {
+			info.ID = u32(tkhd, 12)
+		}
+		if version, _ := fullBoxVersion(mdhd); version == 1 {
+			info.Timescale = u32(mdhd, 20)
+		} else {
+			info.Timescale = u32(mdhd, 12)
+		}
+		if info.Timescale == 0 {
+			return nil, fmt.Errorf("%s track has no timescale", handler)
+		}
+
+		for _, box := range childBoxes(findBox(boxes, "mvex")) {
+			if box.Type == "trex" && u32(box.Payload, 4) == info.ID {
+				info.DefaultDuration = u32(box.Payload, 12)
+				info.DefaultFlags = u32(box.Payload, 20)
+			}
+		}
+		return info, nil
+	}
+	return nil, nil
+}
+
+// fragmentInfo returns the duration of the track's samples in a moof and
+// whether its first sample is a sync sample.
+func (t *trackInfo) fragmentInfo(moof []byte) (float64, bool) {
+	var ticks uint64
+	independent := false
+
+	for _, traf := range childBoxes(moof) {
+		if traf.Type != "traf" {
+			continue
+		}
+		trafBoxes := childBoxes(traf.Payload)
+
+		tfhd := findBox(trafBoxes, "tfhd")
+		if u32(tfhd, 4) != t.ID {
+			continue
+		}
+		defaultDuration, defaultFlags := t.DefaultDuration, t.DefaultFlags
+		_, flags := fullBoxVersion(tfhd)
+		cursor := 8
+		if flags&tfhdBaseDataOffset != 0 {
+			cursor += 8
+		}
+		if flags&tfhdSampleDescription != 0 {
+			cursor += 4
+		}
+		if flags&tfhdDefaultDuration != 0 {
+			defaultDuration = u32(tfhd, cursor)
+			cursor += 4
+		}
+		if flags&tfhdDefaultSize != 0 {
+			cursor += 4
+		}
+		if flags&tfhdDefaultFlags != 0 {
+			defaultFlags = u32(tfhd, cursor)
+		}
+
+		firstSample := true
+		for _, trun := range trafBoxes {
+			if trun.Type != "trun" {
+				continue
+			}
+			_, flags := fullBoxVersion(trun.Payload)
+			count := int(u32(trun.Payload, 4))
+			cursor := 8
+			if flags&trunDataOffset != 0 {
+				cursor += 4
+			}
+			firstFlags, hasFirstFlags := uint32(0), flags&trunFirstSampleFlags != 0
+			if hasFirstFlags {
+				firstFlags = u32(trun.Payload, cursor)
+				cursor += 4
+			}
+
+			for i := 0; i < count && cursor <= len(trun.Payload); i++ {
+				duration, sampleFlags := defaultDuration, defaultFlags
+				if flags&trunSampleDuration != 0 {
+					duration = u32(trun.Payload, cursor)
+					cursor += 4
+				}
+				if flags&trunSampleSize != 0 {
+					cursor += 4
+				}
+				if flags&trunSampleFlags != 0 {
+					sampleFlags = u32(trun.Payload, cursor)
+					cursor += 4
+				}
+				if flags&trunSampleTimeOffset != 0 {
+					cursor += 4
+				}
+
+				if firstSample {
+					if hasFirstFlags {
+						sampleFlags = firstFlags
+					}
+					independent = sampleFlags&sampleIsNonSyncSample == 0
+					firstSample = false
+				}
+				ticks += uint64(duration)
+			}
+		}
+	}
+
+	return float64(ticks) / float64(t.Timescale), independent
+}
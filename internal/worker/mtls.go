@@ -0,0 +1,41 @@
+package worker
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/mtls"
+)
+
+// outboundTLS is presented to the DRM key server and caption service. It is
+// nil, using the default TLS configuration, unless outbound mTLS is enabled.
+var outboundTLS *tls.Config
+
+// setupOutboundMTLS configures outboundTLS from the worker's certificate
+func setupOutboundMTLS(cfg config.MTLSConfig) error {
+	if cfg.CertFile == "" || !cfg.Outbound {
+		return nil
+	}
+	reloader, err := mtls.NewReloader(mtls.Options{
+		CertFile:     cfg.CertFile,
+		KeyFile:      cfg.KeyFile,
+		CAFile:       cfg.CAFile,
+		AllowedNames: cfg.AllowedNames,
+	})
+	if err != nil {
+		return err
+	}
+	outboundTLS = reloader.ClientConfig()
+	return nil
+}
+
+// outboundClient returns the client for requests to internal services
+func outboundClient() *http.Client {
+	if outboundTLS == nil {
+		return http.DefaultClient
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = outboundTLS
+	return &http.Client{Transport: transport}
+}
@@ -2,17 +2,22 @@ package worker
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/drm"
 )
 
 type stitchAndPackageOptions struct {
 	segmentDuration int
 	withHLS         bool
 	withDASH        bool
+	// contentKey encrypts the output for DRM when set
+	contentKey *drm.ContentKey
+	// lowLatency chunks CMAF segments and writes LL-HLS playlists over them
+	lowLatency bool
 }
 
 // This function is kept for backward compatibility but is no longer used
@@ -65,7 +70,7 @@ func (p *videoProcessor) fragmentVideo(inputPath, outputPath string) error {
 		outputPath,
 	}
 
-	cmd := exec.Command("mp4fragment", args...)
+	cmd := toolCommand(context.Background(), "mp4fragment", args...)
 
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
@@ -90,18 +95,22 @@ func (p *videoProcessor) packageVideo(inputPaths []string, outputPath string, op
 		// args = append(args, "--hls-segment-duration", fmt.Sprintf("%d", opts.segmentDuration))
 	}
 
-	// if opts.withDASH {
-	// 	args = append(args, "--mpd")
-	// 	args = append(args, "--mpd-name", "stream.mpd")
-	// }
+	if opts.contentKey != nil {
+		args = append(args, p.encryptionArgs(opts.contentKey)...)
+	}
+
+	if opts.withDASH {
+		// mp4dash always writes a manifest, this pins the name playback expects
+		args = append(args, "--mpd-name", DASHManifestName)
+	}
 
 	for _, inputPath := range inputPaths {
 		args = append(args, inputPath)
 	}
 
-	cmd := exec.Command("mp4dash", args...)
+	cmd := toolCommand(context.Background(), "mp4dash", args...)
 
-	log.Printf("Running mp4dash with args: %v", args)
+	p.logger.Infof("Running mp4dash for %d inputs into %s (encrypted: %t)", len(inputPaths), outputPath, opts.contentKey != nil)
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
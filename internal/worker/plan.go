@@ -0,0 +1,182 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// EncodePlanTTL is how long the plan of a dry run is kept
+	EncodePlanTTL = 24 * time.Hour
+	// planSegmentPath and planOutputPath stand in for the paths of a segment
+	// and its rendition in the ffmpeg arguments of a plan
+	planSegmentPath = "<segment>"
+	planOutputPath  = "<output>"
+)
+
+// planStageProgress is stageProgress for dry runs, which stop after probing
+var planStageProgress = map[models.ProgressStage][2]float64{
+	models.ProgressDownloading: {0, 80},
+	models.ProgressScanning:    {80, 85},
+	models.ProgressProbing:     {85, 100},
+}
+
+// PlanVideo probes the source of a dry run and works out the renditions it
+// would be encoded into, as ProcessVideo would on this worker, without
+// encoding anything or touching the video.
+func (p *videoProcessor) PlanVideo(ctx context.Context, job *models.EncodeJob, plan *models.EncodePlan) error {
+	if err := p.openWorkspace(); err != nil {
+		return err
+	}
+	defer p.cleanup()
+
+	p.reportStage(models.ProgressDownloading, 0)
+	localPath, err := p.downloadVideo(ctx, job.InputS3Key)
+	if err != nil {
+		return failedAt(models.FailureDownload, fmt.Errorf("download failed: %w", err))
+	}
+	if err := p.scanSource(ctx, localPath); err != nil {
+		return err
+	}
+	p.reportStage(models.ProgressProbing, 0)
+
+	probe, err := probeSource(localPath)
+	if err != nil {
+		return failedAt(models.FailureProbe, fmt.Errorf("failed to probe source: %w", err))
+	}
+	report, err := models.NewSourceReport(job.VideoID, probe)
+	if err != nil {
+		return failedAt(models.FailureProbe, err)
+	}
+	if issues := models.ValidateSource(report); len(issues) > 0 {
+		return failedAt(models.FailureInvalidSource, models.SourceIssuesError(issues))
+	}
+
+	videoInfo, err := GetVideoInfo(localPath)
+	if err != nil {
+		return failedAt(models.FailureProbe, fmt.Errorf("video info extraction failed: %w", err))
+	}
+	p.hdr = videoInfo.HDR()
+	plan.Source = &models.PlanSource{
+		Width:         videoInfo.Width,
+		Height:        videoInfo.Height,
+		DisplayWidth:  videoInfo.DisplayWidth,
+		DisplayHeight: videoInfo.DisplayHeight,
+		Duration:      videoInfo.Duration,
+		FrameRate:     videoInfo.FrameRate,
+		HDR:           string(p.hdr),
+		ColorSpace:    videoInfo.ColorSpace,
+	}
+
+	encoder, caps, err := lookupEncoder(job.Codec)
+	if err != nil {
+		return failedAt(models.FailureEncode, err)
+	}
+	presets := p.determineApplicablePresets(videoInfo)
+	p.presets = presets
+	plan.Renditions = make([]models.RenditionPlan, 0, len(presets))
+	plan.TotalBytes = 0
+	for _, preset := range presets {
+		hwAccel := p.encodeHWAccel(caps, preset)
+		built, args := p.encodeArgs(encoder, planSegmentPath, planOutputPath, preset, hwAccel)
+		hwAccelName := string(hwAccel)
+		if hwAccelName == "" {
+			hwAccelName = "none"
+		}
+		bytes := models.EstimateRenditionBytes(preset.Bitrate, videoInfo.Duration)
+		plan.Renditions = append(plan.Renditions, models.RenditionPlan{
+			Quality:        preset.Name,
+			Resolution:     fmt.Sprintf("%dx%d", preset.Resolution[0], preset.Resolution[1]),
+			Bitrate:        preset.Bitrate,
+			FrameRate:      preset.FrameRate,
+			Encoder:        built.Encoder,
+			EncoderPreset:  built.Preset,
+			HWAccel:        hwAccelName,
+			HDR:            p.keepsHDR(preset),
+			ToneMapped:     p.toneMaps(preset),
+			PredictedBytes: bytes,
+			FFmpegArgs:     args,
+		})
+		plan.TotalBytes += bytes
+	}
+	p.reportStage(models.ProgressProbing, 100)
+	return nil
+}
+
+// planJob runs a dry run and stores its plan for the API. Only the job's own
+// status is updated; the video, its playback info and its usage are not.
+func (w *Worker) planJob(ctx context.Context, workerID int, job *models.EncodeJob, videoID uuid.UUID) error {
+	log := w.jobLogger(job).With("slot", workerID)
+	if err := w.redisRepo.SetJobHeartbeat(ctx, job.JobID, w.id, HeartbeatTTL); err != nil {
+		log.Errorf("Failed to set heartbeat of job %s: %v", job.JobID, err)
+	}
+	if err := w.redisRepo.UpdateStatus(ctx, job.JobID, VideoJobsQueue, models.JobStatusProcessing); err != nil {
+		log.Errorf("Failed to update job status: %v", err)
+	}
+	w.loadUserPlan(ctx, job)
+
+	plan := w.pendingEncodePlan(ctx, job)
+	processor := NewVideoProcessor(w.cfg, w.awsRepo, w.videoRepo, w.redisRepo, log, job, nil, w.workDir, w.encoders, w.recommendations)
+	w.setJobProcessor(job.JobID, processor)
+	if err := processor.PlanVideo(ctx, job, plan); err != nil {
+		// A dry run has nothing to checkpoint; one stopped by a drain starts
+		// over on another worker
+		if ctx.Err() != nil && !errors.Is(context.Cause(ctx), errJobCancelled) {
+			w.requeueJob(job)
+			return fmt.Errorf("failed to plan video: %w", err)
+		}
+		w.failJob(ctx, job, videoID, err)
+		return fmt.Errorf("failed to plan video: %w", err)
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	completedAt := time.Now()
+	plan.Status = models.EncodePlanCompleted
+	plan.CompletedAt = &completedAt
+	if err := w.redisRepo.SaveEncodePlan(ctx, plan, EncodePlanTTL); err != nil {
+		log.Errorf("Failed to save plan of job %s: %v", job.JobID, err)
+	}
+	if err := w.redisRepo.UpdateStatus(ctx, job.JobID, VideoJobsQueue, models.JobStatusCompleted); err != nil {
+		log.Errorf("Failed to update job status to completed: %v", err)
+	}
+	log.Infof("Worker %d planned %d renditions for job %s", workerID, len(plan.Renditions), job.JobID)
+	return nil
+}
+
+// pendingEncodePlan returns the plan the API stored when it queued the dry
+// run, or a new one if it expired
+func (w *Worker) pendingEncodePlan(ctx context.Context, job *models.EncodeJob) *models.EncodePlan {
+	plan, err := w.redisRepo.GetEncodePlan(ctx, job.JobID)
+	if err != nil {
+		w.jobLogger(job).Warnf("Failed to load pending plan of job %s: %v", job.JobID, err)
+		plan = &models.EncodePlan{JobID: job.JobID, VideoID: job.VideoID, CreatedAt: time.Now()}
+	}
+	plan.WorkerID = w.id
+	return plan
+}
+
+// failEncodePlan marks a dry run and its plan as failed
+func (w *Worker) failEncodePlan(ctx context.Context, job *models.EncodeJob, reason models.FailureReason, err error) {
+	log := w.jobLogger(job)
+	if updateErr := w.redisRepo.UpdateStatus(ctx, job.JobID, VideoJobsQueue, models.JobStatusFailed); updateErr != nil {
+		log.Errorf("Failed to update job status to failed: %v", updateErr)
+	}
+	if updateErr := w.redisRepo.UpdateFailureReason(ctx, job.JobID, reason, err.Error()); updateErr != nil {
+		log.Errorf("Failed to record failure reason: %v", updateErr)
+	}
+
+	plan := w.pendingEncodePlan(ctx, job)
+	completedAt := time.Now()
+	plan.Status = models.EncodePlanFailed
+	plan.Error = fmt.Sprintf("%s: %v", reason, err)
+	plan.Renditions, plan.TotalBytes = nil, 0
+	plan.CompletedAt = &completedAt
+	if saveErr := w.redisRepo.SaveEncodePlan(ctx, plan, EncodePlanTTL); saveErr != nil {
+		log.Errorf("Failed to save plan of job %s: %v", job.JobID, saveErr)
+	}
+}
@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+const (
+	// PreviewsDir is where the animated preview is stored in the output
+	PreviewsDir = "previews"
+	// PreviewDuration is the length in seconds of the animated preview
+	PreviewDuration = 4.0
+	PreviewWidth    = 480
+	PreviewFPS      = 12
+)
+
+// generatePreview renders a short, silent, looping clip of the video for
+// gallery hover effects. It starts 10% in, where thumbnails are taken too,
+// and is shorter for videos shorter than PreviewDuration.
+func (p *videoProcessor) generatePreview(inputPath string, duration float64, format models.PreviewFormat) (string, error) {
+	if format == "" {
+		format = models.PreviewWebP
+	}
+	previewDir := filepath.Join(p.tempDir, PreviewsDir)
+	if err := os.MkdirAll(previewDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create preview directory: %w", err)
+	}
+	outputPath := filepath.Join(previewDir, "preview."+string(format))
+
+	start := duration * 0.1
+	length := math.Min(PreviewDuration, duration-start)
+	if length <= 0 {
+		return "", fmt.Errorf("video is too short for a preview")
+	}
+
+	scale := fmt.Sprintf("fps=%d,scale=%d:-2:flags=lanczos", PreviewFPS, PreviewWidth)
+	args := []string{
+		"-y", "-hide_banner", "-loglevel", "error",
+		"-ss", fmt.Sprintf("%.2f", start),
+		"-t", fmt.Sprintf("%.2f", length),
+		"-i", inputPath,
+		"-an",
+	}
+	switch format {
+	case models.PreviewGIF:
+		// A palette made from the clip itself keeps GIF banding down
+		args = append(args, "-filter_complex", scale+",split[a][b];[a]palettegen[p];[b][p]paletteuse", "-loop", "0")
+	case models.PreviewMP4:
+		args = append(args, "-vf", scale,
+			"-c:v", "libx264", "-preset", "veryfast", "-crf", "28",
+			"-pix_fmt", "yuv420p", "-movflags", "+faststart")
+	default:
+		args = append(args, "-vf", scale,
+			"-c:v", "libwebp", "-lossless", "0", "-q:v", "60", "-loop", "0")
+	}
+	args = append(args, outputPath)
+
+	cmd := ffmpegCommand(args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("preview generation failed: %v, stderr: %s", err, stderr.String())
+	}
+	if stat, err := os.Stat(outputPath); err != nil || stat.Size() == 0 {
+		return "", fmt.Errorf("preview generation produced invalid output file")
+	}
+	return outputPath, nil
+}
+
+// uploadPreview stores the preview under PreviewsDir of the output and
+// returns its name relative to the output
+func (p *videoProcessor) uploadPreview(ctx context.Context, previewPath, outputKey string) (string, error) {
+	fileInfo, err := os.Stat(previewPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat preview %s: %w", previewPath, err)
+	}
+	baseKey := strings.TrimSuffix(outputKey, filepath.Ext(outputKey))
+	name := path.Join(PreviewsDir, filepath.Base(previewPath))
+	if err := p.uploadSingleFileOptimized(ctx, previewPath, path.Join(baseKey, name), fileInfo); err != nil {
+		return "", fmt.Errorf("failed to upload preview: %w", err)
+	}
+	return name, nil
+}
@@ -4,9 +4,9 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"math"
 	"mime"
 	"os"
@@ -16,11 +16,13 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/drm"
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
 	"github.com/google/uuid"
 )
@@ -31,19 +33,101 @@ type videoProcessor struct {
 	cfg       *config.Config
 	awsRepo   videofiles.AWSRepository
 	videoRepo videofiles.Repository
+	redisRepo videofiles.RedisRepository
 	logger    logger.Logger
 	tempDir   string
 	job       *models.EncodeJob
+
+	// workDir is the worker's work directory, which holds the job's workspace
+	workDir string
+
+	// workspace holds tempDir while a job runs; nil before and after
+	workspace *workspace
+
+	// introDuration is the seconds of intro joined before the source, which
+	// its subtitles are shifted by
+	introDuration float64
+
+	// progress aggregates the progress of segment encodes; nil outside of
+	// encoding
+	progress *encodeProgress
+
+	resume       *models.JobCheckpoint
+	checkpoint   *models.JobCheckpoint
+	checkpointMu sync.Mutex
+
+	encoders *encodeScheduler
+	// recommendations override the encoder presets guessed from the core
+	// count; nil when the host was not benchmarked
+	recommendations *EncoderRecommendations
+
+	contentKey *drm.ContentKey
+
+	encoderOnce   sync.Once
+	encoder       string
+	hwAccel       HardwareAccelType
+	encoderPreset string
+
+	// hdr is the HDR format of the source
+	hdr HDRFormat
+	// presets is the ladder of the job, including high frame rate variants
+	presets []QualityPreset
+
+	// uploadedBytes is what the job added to the output bucket
+	uploadedBytes atomic.Int64
+
+	// sourceHash is the SHA-256 of the downloaded source of a cacheable job
+	sourceHash string
+
+	// notifier publishes the stage and progress of the job
+	notifier *progressNotifier
+	// eta estimates when the job finishes for its progress notifications
+	eta *etaEstimator
+}
+
+// NewVideoProcessor creates a processor for job. resume is the checkpoint left
+// behind by a drained worker, or nil when the job starts fresh. workDir is
+// where the job's workspace is created. encoders is shared by all jobs of the
+// worker to schedule their encodes; the job gets a scheduler of its own when
+// it is nil. recommendations may be nil.
+func NewVideoProcessor(cfg *config.Config, awsRepo videofiles.AWSRepository, videoRepo videofiles.Repository, redisRepo videofiles.RedisRepository, logger logger.Logger, job *models.EncodeJob, resume *models.JobCheckpoint, workDir string, encoders *encodeScheduler, recommendations *EncoderRecommendations) VideoProcessor {
+	if encoders == nil {
+		encoders = newEncodeScheduler(GetMaxConcurrentEncoders())
+	}
+	p := &videoProcessor{
+		cfg:             cfg,
+		awsRepo:         awsRepo,
+		videoRepo:       videoRepo,
+		redisRepo:       redisRepo,
+		logger:          logger,
+		tempDir:         filepath.Join(workDir, job.JobID),
+		job:             job,
+		workDir:         workDir,
+		resume:          resume,
+		encoders:        encoders,
+		recommendations: recommendations,
+		checkpoint: &models.JobCheckpoint{
+			JobID:             job.JobID,
+			VideoID:           job.VideoID,
+			CompletedSegments: make(map[models.VideoQuality][]int),
+		},
+	}
+	p.eta = newETAEstimator(job)
+	p.notifier = newProgressNotifier(time.Duration(cfg.Worker.ProgressInterval)*time.Second, p.publishProgress)
+	return p
 }
 
-func NewVideoProcessor(cfg *config.Config, awsRepo videofiles.AWSRepository, videoRepo videofiles.Repository, logger logger.Logger, job *models.EncodeJob) VideoProcessor {
-	return &videoProcessor{
-		cfg:       cfg,
-		awsRepo:   awsRepo,
-		videoRepo: videoRepo,
-		logger:    logger,
-		tempDir:   TempDir,
-		job:       job,
+// publishProgress stores the stage and progress of the job in Redis and
+// publishes them to its subscribers
+func (p *videoProcessor) publishProgress(progress *models.JobProgress) {
+	if p.redisRepo == nil {
+		return
+	}
+	progress.ETA = p.eta.remaining(progress.Progress)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.redisRepo.UpdateProgress(ctx, VideoJobsQueue, progress); err != nil {
+		p.logger.Errorf("Failed to publish progress: %v", err)
 	}
 }
 
@@ -53,15 +137,44 @@ type ProcessingResult struct {
 	Height        int
 	Qualities     []models.InputQualityInfo
 	SubtitleFiles []string
-	ThumbnailPath string
+	// DownloadFiles are the progressive MP4 downloads relative to the output key
+	DownloadFiles map[models.VideoQuality]string
+	// Thumbnails are the generated stills; the first is the default poster
+	Thumbnails []string
+	// DRMKeyID is the hex key ID of DRM packaged output
+	DRMKeyID    string
+	Environment *models.JobEnvironment
+	// UploadedBytes is what the job added to the output bucket
+	UploadedBytes int64
+	Chapters      []models.Chapter
+	// Preview is the animated preview's path relative to the output
+	Preview string
+	// SourceHash is the SHA-256 of the source, set when the output may be
+	// cached
+	SourceHash string
+	// Cached is set when the outputs were copied from an earlier encode
+	Cached bool
+	// Waveform is the waveform JSON of the audio and Images the variants of
+	// an image, relative to the output
+	Waveform string
+	Images   []models.ImageVariant
 }
 
 type QualityPreset struct {
 	Name       models.VideoQuality
 	Resolution [2]int
 	Bitrate    int
+	// FrameRate is the output frame rate; the source's is kept when it is 0
+	FrameRate float64
+	// frameRate is FrameRate as the exact fraction ffmpeg is given
+	frameRate string
+	// tier is the height of the rung once Resolution is fitted to the
+	// source's aspect ratio
+	tier int
 }
 
+// qualityPresets is the ladder for 16:9 sources. determineApplicablePresets
+// fits each rung to the source's aspect ratio.
 var qualityPresets = []QualityPreset{
 	{Name: models.Quality1080P, Resolution: [2]int{1920, 1080}, Bitrate: 5000},
 	{Name: models.Quality720P, Resolution: [2]int{1280, 720}, Bitrate: 3000},
@@ -74,15 +187,71 @@ func (p *videoProcessor) ProcessVideo(ctx context.Context, job *models.EncodeJob
 		return nil, fmt.Errorf("input key and output key cannot be empty")
 	}
 
+	if err := p.openWorkspace(); err != nil {
+		return nil, err
+	}
 	defer p.cleanup()
 
-	if err := os.MkdirAll(p.tempDir, os.ModePerm); err != nil {
-		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	// A checkpoint left in the work directory by a crashed run is at least as
+	// fresh as one from Redis, since it is written after every segment.
+	if local := p.loadLocalCheckpoint(); local != nil {
+		p.logger.Infof("Found local checkpoint for job %s at stage %s", job.JobID, local.Stage)
+		if p.resume != nil && local.SegmentCount == p.resume.SegmentCount {
+			local.ArtifactPrefix = p.resume.ArtifactPrefix
+			for quality, indices := range p.resume.CompletedSegments {
+				local.CompletedSegments[quality] = append(local.CompletedSegments[quality], indices...)
+			}
+			local.SourceEncrypted = local.SourceEncrypted || p.resume.SourceEncrypted
+		}
+		p.resume = local
 	}
 
-	localPath, err := p.downloadVideo(ctx, job.InputS3Key)
+	p.reportStage(models.ProgressDownloading, 0)
+	var localPath string
+	var err error
+	if job.SourceURL != "" {
+		localPath, err = p.fetchRemoteSource(p.sourceContext(ctx), videoID)
+	} else {
+		localPath, err = p.downloadVideo(p.sourceContext(ctx), job.InputS3Key)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("download failed: %w", err)
+		if ctx.Err() != nil {
+			return nil, p.interrupt(ctx)
+		}
+		return nil, failedAt(models.FailureDownload, fmt.Errorf("download failed: %w", err))
+	}
+	p.markStage(models.StageDownloaded)
+
+	if err := p.scanSource(ctx, localPath); err != nil {
+		return nil, err
+	}
+	p.reportStage(models.ProgressProbing, 0)
+
+	if err := p.preflight(ctx, videoID, localPath); err != nil {
+		return nil, err
+	}
+	if err := p.fingerprintSource(ctx, videoID, localPath); err != nil {
+		if ctx.Err() != nil {
+			return nil, p.interrupt(ctx)
+		}
+		p.logger.Warnf("Failed to fingerprint source of job %s: %v", job.JobID, err)
+	}
+
+	if result := p.copyCachedOutput(ctx, localPath); result != nil {
+		return result, nil
+	}
+
+	if err := p.encryptSource(ctx, localPath); err != nil {
+		return nil, fmt.Errorf("source encryption failed: %w", err)
+	}
+
+	sourcePath := localPath
+	localPath, err = p.addBumpers(ctx, sourcePath)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, p.interrupt(ctx)
+		}
+		return nil, err
 	}
 
 	if err := p.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusProcessing, 10); err != nil {
@@ -91,37 +260,72 @@ func (p *videoProcessor) ProcessVideo(ctx context.Context, job *models.EncodeJob
 
 	videoInfo, err := GetVideoInfo(localPath)
 	if err != nil {
-		return nil, fmt.Errorf("video info extraction failed: %w", err)
+		return nil, failedAt(models.FailureProbe, fmt.Errorf("video info extraction failed: %w", err))
+	}
+	p.loadThroughput(ctx, videoInfo.Duration)
+	p.hdr = videoInfo.HDR()
+	if p.hdr != HDRNone {
+		p.logger.Infof("Source of job %s is %s HDR", job.JobID, p.hdr)
 	}
 
 	if err := p.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusProcessing, 20); err != nil {
 		p.logger.Errorf("Failed to update progress after info extraction: %v", err)
 	}
 
-	subtitleFiles, err := p.extractSubtitles(localPath)
+	subtitleFiles, err := p.extractSubtitles(sourcePath)
 	if err != nil {
 		p.logger.Warnf("Subtitle extraction failed: %v", err)
 		subtitleFiles = []string{}
 	}
+	subtitleFiles = p.offsetSubtitles(subtitleFiles)
 	p.logger.Debugf("Subtitles found %v", subtitleFiles)
 
+	if job.GenerateCaptions {
+		captionFile, err := p.transcribe(ctx, localPath, len(subtitleFiles))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, p.interrupt(ctx)
+			}
+			p.logger.Warnf("Caption generation failed: %v", err)
+		} else {
+			subtitleFiles = append(subtitleFiles, captionFile)
+		}
+	}
+
 	if err := p.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusProcessing, 22); err != nil {
 		p.logger.Errorf("Failed to update progress after subtitle extraction: %v", err)
 	}
 
-	thumbnailPath, err := p.generateThumbnail(localPath, videoInfo.Duration)
+	thumbnailPaths, err := p.generateThumbnails(localPath, videoInfo.Duration, job.Thumbnails)
 	if err != nil {
 		p.logger.Warnf("Thumbnail generation failed: %v", err)
-		thumbnailPath = ""
+	}
+	previewPath, err := p.generatePreview(localPath, videoInfo.Duration, job.PreviewFormat)
+	if err != nil {
+		p.logger.Warnf("Preview generation failed: %v", err)
+	}
+	waveformPath, err := p.generateWaveform(ctx, localPath, videoInfo.Duration)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, p.interrupt(ctx)
+		}
+		p.logger.Warnf("Waveform generation failed: %v", err)
 	}
 
 	if err := p.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusProcessing, 25); err != nil {
 		p.logger.Errorf("Failed to update progress after thumbnail generation: %v", err)
 	}
+	p.reportStage(models.ProgressSplitting, 0)
 
 	segments, err := p.splitVideo(localPath, videoInfo)
 	if err != nil {
-		return nil, fmt.Errorf("split failed: %w", err)
+		return nil, failedAt(models.FailureEncode, fmt.Errorf("split failed: %w", err))
+	}
+	p.markStage(models.StageSplit)
+	p.restoreSegments(ctx, len(segments))
+
+	if ctx.Err() != nil {
+		return nil, p.interrupt(ctx)
 	}
 
 	if err := p.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusProcessing, 30); err != nil {
@@ -129,6 +333,8 @@ func (p *videoProcessor) ProcessVideo(ctx context.Context, job *models.EncodeJob
 	}
 
 	applicablePresets := p.determineApplicablePresets(videoInfo)
+	p.presets = applicablePresets
+	p.trackEncodeProgress(ctx, videoID, videoInfo.Duration, len(segments))
 
 	qualitySegments := make(map[models.VideoQuality][]string)
 	qualityInfos := make([]models.InputQualityInfo, 0, len(applicablePresets))
@@ -143,6 +349,7 @@ func (p *videoProcessor) ProcessVideo(ctx context.Context, job *models.EncodeJob
 	var wg sync.WaitGroup
 
 	p.logger.Infof("Starting parallel encoding for %d quality levels with maximum CPU utilization", len(applicablePresets))
+	p.markStage(models.StageEncoding)
 
 	for _, preset := range applicablePresets {
 		wg.Add(1)
@@ -160,7 +367,7 @@ func (p *videoProcessor) ProcessVideo(ctx context.Context, job *models.EncodeJob
 			}()
 
 			p.logger.Infof("Starting encoding for quality: %s", preset.Name)
-			encodedSegments, err := p.encodeSegmentsWithQuality(segments, preset, videoInfo)
+			encodedSegments, err := p.encodeSegmentsWithQuality(ctx, segments, preset, videoInfo)
 			resultChan <- qualityResult{
 				preset:   preset,
 				segments: encodedSegments,
@@ -174,55 +381,125 @@ func (p *videoProcessor) ProcessVideo(ctx context.Context, job *models.EncodeJob
 		close(resultChan)
 	}()
 
-	completedQualities := 0
+	interrupted := false
 	for result := range resultChan {
+		if errors.Is(result.err, errJobInterrupted) {
+			// Keep collecting so every in-flight segment finishes before checkpointing
+			interrupted = true
+			continue
+		}
 		if result.err != nil {
-			return nil, fmt.Errorf("encoding failed for quality %s: %w", result.preset.Name, result.err)
+			return nil, failedAt(models.FailureEncode, fmt.Errorf("encoding failed for quality %s: %w", result.preset.Name, result.err))
 		}
 
 		qualitySegments[result.preset.Name] = result.segments
 
 		qualityInfos = append(qualityInfos, models.InputQualityInfo{
+			Quality:    result.preset.Name,
 			Resolution: fmt.Sprintf("%dx%d", result.preset.Resolution[0], result.preset.Resolution[1]),
 			Bitrate:    result.preset.Bitrate,
 			MaxBitrate: int(float64(result.preset.Bitrate) * 1.2),
 			MinBitrate: int(float64(result.preset.Bitrate) * 0.8),
+			FrameRate:  result.preset.FrameRate,
 		})
 
-		completedQualities++
-		progressIncrement := 50.0 / float64(len(applicablePresets))
-		currentProgress := 30.0 + float64(completedQualities)*progressIncrement
-
-		if err := p.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusProcessing, float64(int(currentProgress))); err != nil {
-			p.logger.Errorf("Failed to update progress for quality %s: %v", result.preset.Name, err)
-		}
-
 		p.logger.Infof("Completed aggressive encoding for quality: %s", result.preset.Name)
 	}
 
+	if interrupted {
+		return nil, p.interrupt(ctx)
+	}
+	p.markStage(models.StageEncoded)
+	p.progress.finish()
+
+	// Encoding is the expensive part; once it is done we finish the job even
+	// if the worker is being drained.
+	ctx = context.WithoutCancel(ctx)
+
 	outputPath := filepath.Join(p.tempDir, "output")
 	if err := os.MkdirAll(outputPath, os.ModePerm); err != nil {
 		return nil, fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	p.reportStage(models.ProgressPackaging, 0)
+	if job.EnableDRM {
+		if err := p.acquireContentKey(ctx); err != nil {
+			return nil, failedAt(models.FailurePackage, fmt.Errorf("failed to acquire drm key: %w", err))
+		}
+	}
+
 	if err := p.stitchAndPackageMultiQuality(qualitySegments, outputPath); err != nil {
-		return nil, fmt.Errorf("finalization failed: %w", err)
+		return nil, failedAt(models.FailurePackage, fmt.Errorf("finalization failed: %w", err))
+	}
+	if err := setMasterFrameRates(filepath.Join(outputPath, "master.m3u8"), qualityInfos); err != nil {
+		p.logger.Warnf("Failed to set frame rates in master playlist: %v", err)
 	}
+	if err := setManifestCodecs(outputPath, job.Codec); err != nil {
+		p.logger.Warnf("Failed to set codecs in manifests: %v", err)
+	}
+
+	if err := p.packageSubtitles(outputPath, subtitleFiles, videoInfo.Duration); err != nil {
+		p.logger.Warnf("Failed to declare subtitles in manifests: %v", err)
+	}
+	chapters := p.chapters(sourcePath, videoInfo.Duration)
+	if len(chapters) > 0 {
+		if err := writeChapters(outputPath, chapters); err != nil {
+			p.logger.Warnf("Failed to write chapters: %v", err)
+			chapters = nil
+		}
+	}
+	p.markStage(models.StagePackaged)
 
 	if _, err := os.Stat(outputPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("output directory does not exist after processing")
+		return nil, failedAt(models.FailurePackage, fmt.Errorf("output directory does not exist after processing"))
+	}
+
+	if job.IntegrityManifest {
+		if err := writeIntegrityManifest(outputPath); err != nil {
+			return nil, failedAt(models.FailurePackage, err)
+		}
 	}
 
 	outputKey := strings.TrimPrefix(job.OutputS3Key, "/")
 	outputKey = strings.TrimSuffix(outputKey, "/")
 
+	p.reportStage(models.ProgressUploading, 0)
 	if err := p.uploadProcessedFiles(ctx, outputPath, outputKey); err != nil {
-		return nil, fmt.Errorf("upload failed: %w", err)
+		return nil, failedAt(models.FailureUpload, fmt.Errorf("upload failed: %w", err))
 	}
 
-	if err := p.uploadSubtitleAndThumbnailFiles(ctx, subtitleFiles, thumbnailPath, outputKey); err != nil {
+	if err := p.uploadSubtitleAndThumbnailFiles(ctx, subtitleFiles, thumbnailPaths, outputKey); err != nil {
 		p.logger.Warnf("Failed to upload subtitle/thumbnail files: %v", err)
 	}
+	var preview string
+	if previewPath != "" {
+		if preview, err = p.uploadPreview(ctx, previewPath, outputKey); err != nil {
+			p.logger.Warnf("Failed to upload preview: %v", err)
+		}
+	}
+	var waveform string
+	if waveformPath != "" {
+		if waveform, err = p.uploadWaveform(ctx, waveformPath, outputKey); err != nil {
+			p.logger.Warnf("Failed to upload waveform: %v", err)
+		}
+	}
+
+	var downloadFiles map[models.VideoQuality]string
+	if job.EnableDownloads && job.EnableDRM {
+		p.logger.Warnf("Skipping MP4 downloads of DRM protected video %s", videoID)
+	} else if job.EnableDownloads {
+		qualities := make([]models.VideoQuality, 0, len(qualitySegments))
+		for _, preset := range applicablePresets {
+			qualities = append(qualities, preset.Name)
+		}
+		downloadFiles, err = p.uploadDownloads(ctx, qualities, outputKey)
+		if err != nil {
+			p.logger.Warnf("Failed to publish MP4 downloads: %v", err)
+		}
+	}
+	p.markStage(models.StageUploaded)
+	p.reportStage(models.ProgressUploading, 100)
+	p.removeCheckpointArtifacts(ctx)
 
 	if err := p.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusProcessing, 90); err != nil {
 		p.logger.Errorf("Failed to update progress after upload: %v", err)
@@ -234,8 +511,18 @@ func (p *videoProcessor) ProcessVideo(ctx context.Context, job *models.EncodeJob
 		Height:        videoInfo.Height,
 		Qualities:     qualityInfos,
 		SubtitleFiles: subtitleFiles,
-		ThumbnailPath: thumbnailPath,
+		Thumbnails:    thumbnailPaths,
+		DownloadFiles: downloadFiles,
+		Chapters:      chapters,
+		Preview:       preview,
+		Waveform:      waveform,
+	}
+	if p.contentKey != nil {
+		result.DRMKeyID = p.contentKey.KeyIDHex()
 	}
+	result.Environment = p.environment(applicablePresets)
+	result.UploadedBytes = p.uploadedBytes.Load()
+	result.SourceHash = p.sourceHash
 
 	return result, nil
 }
@@ -243,32 +530,33 @@ func (p *videoProcessor) ProcessVideo(ctx context.Context, job *models.EncodeJob
 func (p *videoProcessor) determineApplicablePresets(videoInfo *VideoInfo) []QualityPreset {
 	var applicablePresets []QualityPreset
 
-	sourceWidth := videoInfo.Width
-	sourceHeight := videoInfo.Height
+	sourceWidth, sourceHeight := videoInfo.DisplayWidth, videoInfo.DisplayHeight
+	if sourceWidth == 0 || sourceHeight == 0 {
+		sourceWidth, sourceHeight = videoInfo.Width, videoInfo.Height
+	}
 
 	for _, preset := range qualityPresets {
-		if preset.Resolution[0] <= sourceWidth && preset.Resolution[1] <= sourceHeight {
-			applicablePresets = append(applicablePresets, preset)
+		if fitted, ok := fitPreset(preset, sourceWidth, sourceHeight); ok {
+			applicablePresets = append(applicablePresets, fitted)
 		}
 	}
 
 	if len(applicablePresets) == 0 {
-		applicablePresets = append(applicablePresets, qualityPresets[len(qualityPresets)-1])
+		fitted, _ := fitPreset(qualityPresets[len(qualityPresets)-1], sourceWidth, sourceHeight)
+		applicablePresets = append(applicablePresets, fitted)
 	}
 
-	return applicablePresets
+	return withFrameRates(applicablePresets, videoInfo)
 }
 
-func (p *videoProcessor) encodeSegmentsWithQuality(segments []string, preset QualityPreset, _ *VideoInfo) ([]string, error) {
+func (p *videoProcessor) encodeSegmentsWithQuality(ctx context.Context, segments []string, preset QualityPreset, _ *VideoInfo) ([]string, error) {
 	type encodeResult struct {
 		index int
 		path  string
 		err   error
 	}
 
-	maxEncoders := GetMaxConcurrentEncoders()
 	resultChan := make(chan encodeResult, len(segments))
-	sem := make(chan struct{}, maxEncoders)
 	var wg sync.WaitGroup
 
 	qualityDir := filepath.Join(p.tempDir, "encoded_segments", string(preset.Name))
@@ -276,17 +564,36 @@ func (p *videoProcessor) encodeSegmentsWithQuality(segments []string, preset Qua
 		return nil, fmt.Errorf("failed to create output directory for quality %s: %w", preset.Name, err)
 	}
 
-	p.logger.Infof("Starting aggressive encoding with %d concurrent encoders for %d segments", maxEncoders, len(segments))
+	p.logger.Infof("Scheduling %d segments of quality %s on %d encoder slots", len(segments), preset.Name, p.encoders.Limit())
 
 	for i, segment := range segments {
 		wg.Add(1)
 		go func(idx int, inputPath string) {
 			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
 
-			outputPath := filepath.Join(qualityDir, fmt.Sprintf("encoded_%03d.mp4", idx))
-			err := p.encodeSingleSegmentWithQualityOptimized(inputPath, outputPath, preset)
+			outputPath := p.encodedSegmentPath(preset.Name, idx)
+			if p.isSegmentDone(preset.Name, idx) {
+				p.progress.skip(outputPath)
+				resultChan <- encodeResult{index: idx, path: outputPath}
+				return
+			}
+
+			if ctx.Err() != nil {
+				resultChan <- encodeResult{index: idx, path: outputPath, err: errJobInterrupted}
+				return
+			}
+
+			// Lower renditions are scheduled first so they finish first
+			if err := p.encoders.Acquire(ctx, preset.height()); err != nil {
+				resultChan <- encodeResult{index: idx, path: outputPath, err: errJobInterrupted}
+				return
+			}
+			defer p.encoders.Release()
+
+			err := p.encodeSegment(inputPath, outputPath, preset)
+			if err == nil {
+				p.markSegmentDone(preset.Name, idx)
+			}
 
 			resultChan <- encodeResult{
 				index: idx,
@@ -302,199 +609,23 @@ func (p *videoProcessor) encodeSegmentsWithQuality(segments []string, preset Qua
 	}()
 
 	encodedSegments := make([]string, len(segments))
+	interrupted := false
 	for result := range resultChan {
+		if errors.Is(result.err, errJobInterrupted) {
+			interrupted = true
+			continue
+		}
 		if result.err != nil {
 			return nil, fmt.Errorf("segment %d encoding failed: %w", result.index, result.err)
 		}
 		encodedSegments[result.index] = result.path
 	}
 
-	return encodedSegments, nil
-}
-
-func (p *videoProcessor) encodeSingleSegmentWithQuality(inputPath, outputPath string, preset QualityPreset) error {
-	switch p.job.Codec {
-	case models.CodecH264:
-		return p.encodeSingleSegmentWithH264(inputPath, outputPath, preset)
-	case models.CodecAV1:
-		return p.encodeSingleSegmentWithSVTAV1(inputPath, outputPath, preset)
-	default:
-		return fmt.Errorf("unsupported codec: %s", p.job.Codec)
-	}
-}
-
-func (p *videoProcessor) encodeSingleSegmentWithQualityOptimized(inputPath, outputPath string, preset QualityPreset) error {
-	switch p.job.Codec {
-	case models.CodecH264:
-		return p.encodeSingleSegmentWithH264Optimized(inputPath, outputPath, preset)
-	case models.CodecAV1:
-		return p.encodeSingleSegmentWithSVTAV1Optimized(inputPath, outputPath, preset)
-	default:
-		return fmt.Errorf("unsupported codec: %s", p.job.Codec)
+	if interrupted {
+		return nil, errJobInterrupted
 	}
-}
 
-func (p *videoProcessor) encodeSingleSegmentWithH264(inputPath, outputPath string, preset QualityPreset) error {
-	hwAccel := p.detectHardwareAcceleration()
-	encodingPreset := p.determineEncodingPreset(hwAccel)
-
-	args := []string{
-		"-y",
-		"-hide_banner",
-		"-loglevel", "error",
-		"-i", inputPath,
-	}
-
-	var encoder string
-	var hwAccelArgs []string
-
-	switch hwAccel {
-	case HWAccelNVENC:
-		encoder = "h264_nvenc"
-		hwAccelArgs = []string{
-			"-hwaccel", "cuda",
-			"-hwaccel_output_format", "cuda",
-		}
-	case HWAccelQSV:
-		encoder = "h264_qsv"
-		hwAccelArgs = []string{
-			"-hwaccel", "qsv",
-			"-hwaccel_output_format", "qsv",
-		}
-	case HWAccelAMF:
-		encoder = "h264_amf"
-		hwAccelArgs = []string{
-			"-hwaccel", "d3d11va",
-			"-hwaccel_output_format", "d3d11",
-		}
-	case HWAccelVAAPI:
-		encoder = "h264_vaapi"
-		hwAccelArgs = []string{
-			"-hwaccel", "vaapi",
-			"-hwaccel_output_format", "vaapi",
-			"-hwaccel_device", "/dev/dri/renderD128",
-		}
-	default:
-		encoder = "libx264"
-	}
-
-	args = append(args, hwAccelArgs...)
-
-	videoFilter := fmt.Sprintf("scale=%d:%d", preset.Resolution[0], preset.Resolution[1])
-	if hwAccel == HWAccelVAAPI {
-		videoFilter = fmt.Sprintf("scale_vaapi=%d:%d", preset.Resolution[0], preset.Resolution[1])
-	} else if hwAccel == HWAccelNVENC {
-		videoFilter = fmt.Sprintf("scale_cuda=%d:%d", preset.Resolution[0], preset.Resolution[1])
-	}
-
-	encodingArgs := []string{
-		"-c:v", encoder,
-		"-preset", encodingPreset,
-		"-vf", videoFilter,
-		"-b:v", fmt.Sprintf("%dk", preset.Bitrate),
-		"-maxrate", fmt.Sprintf("%dk", int(float64(preset.Bitrate)*1.2)),
-		"-bufsize", fmt.Sprintf("%dk", preset.Bitrate*2),
-		"-g", "60",
-		"-keyint_min", "60",
-		"-sc_threshold", "0",
-		"-avoid_negative_ts", "make_zero",
-		"-fflags", "+genpts",
-		"-async", "1",
-		"-vsync", "cfr",
-		"-af", "aresample=async=1",
-		"-movflags", "+faststart",
-		"-c:a", "aac",
-		"-b:a", "128k",
-		"-ar", "48000",
-		"-ac", "2",
-	}
-
-	if hwAccel == HWAccelNone {
-		encodingArgs = append(encodingArgs,
-			"-profile:v", "high",
-			"-level", "4.1",
-			"-threads", "0",
-			"-x264-params", "ref=3:bframes=3:b-adapt=1:direct=auto:me=umh:subme=7:trellis=1:rc-lookahead=50",
-		)
-	} else if hwAccel == HWAccelNVENC {
-		encodingArgs = append(encodingArgs,
-			"-profile:v", "high",
-			"-level", "4.1",
-			"-rc", "vbr",
-			"-rc-lookahead", "32",
-			"-surfaces", "32",
-			"-bf", "3",
-			"-b_ref_mode", "middle",
-		)
-	}
-
-	args = append(args, encodingArgs...)
-	args = append(args, outputPath)
-
-	cmd := exec.Command("ffmpeg", args...)
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		if hwAccel != HWAccelNone {
-			p.logger.Warn("Hardware acceleration failed, falling back to software encoding")
-			return p.encodeSingleSegmentWithH264Software(inputPath, outputPath, preset)
-		}
-		return fmt.Errorf("H.264 encoding failed: %v, stderr: %s", err, stderr.String())
-	}
-
-	if stat, err := os.Stat(outputPath); err != nil || stat.Size() == 0 {
-		return fmt.Errorf("encoding produced invalid output file")
-	}
-
-	return nil
-}
-
-func (p *videoProcessor) encodeSingleSegmentWithH264Software(inputPath, outputPath string, preset QualityPreset) error {
-	args := []string{
-		"-y",
-		"-hide_banner",
-		"-loglevel", "error",
-		"-i", inputPath,
-		"-c:v", "libx264",
-		"-preset", "fast",
-		"-profile:v", "high",
-		"-level", "4.1",
-		"-vf", fmt.Sprintf("scale=%d:%d", preset.Resolution[0], preset.Resolution[1]),
-		"-b:v", fmt.Sprintf("%dk", preset.Bitrate),
-		"-maxrate", fmt.Sprintf("%dk", int(float64(preset.Bitrate)*1.2)),
-		"-bufsize", fmt.Sprintf("%dk", preset.Bitrate*2),
-		"-threads", "0",
-		"-g", "60",
-		"-keyint_min", "60",
-		"-sc_threshold", "0",
-		"-avoid_negative_ts", "make_zero",
-		"-fflags", "+genpts",
-		"-async", "1",
-		"-vsync", "cfr",
-		"-af", "aresample=async=1",
-		"-x264-params", "ref=3:bframes=3:b-adapt=1:direct=auto:me=umh:subme=7:trellis=1:rc-lookahead=50",
-		"-movflags", "+faststart",
-		"-c:a", "aac",
-		"-b:a", "128k",
-		"-ar", "48000",
-		"-ac", "2",
-		outputPath,
-	}
-
-	cmd := exec.Command("ffmpeg", args...)
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("software H.264 encoding failed: %v, stderr: %s", err, stderr.String())
-	}
-
-	if stat, err := os.Stat(outputPath); err != nil || stat.Size() == 0 {
-		return fmt.Errorf("encoding produced invalid output file")
-	}
-
-	return nil
+	return encodedSegments, nil
 }
 
 type HardwareAccelType string
@@ -571,264 +702,6 @@ func (p *videoProcessor) checkHardwareAcceleration() bool {
 	return p.detectHardwareAcceleration() != HWAccelNone
 }
 
-func (p *videoProcessor) determineEncodingPreset(hwAccel HardwareAccelType) string {
-	cores := runtime.NumCPU()
-
-	if hwAccel != HWAccelNone {
-		switch hwAccel {
-		case HWAccelNVENC:
-			return "p4"
-		case HWAccelQSV:
-			return "balanced"
-		case HWAccelAMF:
-			return "balanced"
-		case HWAccelVAAPI:
-			return "balanced"
-		}
-	}
-
-	switch {
-	case cores >= 16:
-		return "slow"
-	case cores >= 8:
-		return "medium"
-	case cores >= 4:
-		return "fast"
-	default:
-		return "veryfast"
-	}
-}
-
-func (p *videoProcessor) encodeSingleSegmentWithSVTAV1(inputPath, outputPath string, preset QualityPreset) error {
-	cores := runtime.NumCPU()
-	svtPreset := "8"
-
-	switch {
-	case cores >= 16:
-		svtPreset = "6"
-	case cores >= 8:
-		svtPreset = "7"
-	case cores >= 4:
-		svtPreset = "8"
-	default:
-		svtPreset = "9"
-	}
-
-	args := []string{
-		"-y",
-		"-hide_banner",
-		"-loglevel", "error",
-		"-i", inputPath,
-		"-c:v", "libsvtav1",
-		"-preset", svtPreset,
-		"-vf", fmt.Sprintf("scale=%d:%d", preset.Resolution[0], preset.Resolution[1]),
-		"-crf", "28",
-		"-maxrate", fmt.Sprintf("%dk", int(float64(preset.Bitrate)*1.2)),
-		"-bufsize", fmt.Sprintf("%dk", preset.Bitrate*2),
-		"-g", "240",
-		"-keyint_min", "240",
-		"-tile-columns", "2",
-		"-tile-rows", "1",
-		"-avoid_negative_ts", "make_zero",
-		"-fflags", "+genpts",
-		"-async", "1",
-		"-vsync", "cfr",
-		"-af", "aresample=async=1",
-		"-movflags", "+faststart",
-		"-c:a", "aac",
-		"-b:a", "128k",
-		"-ar", "48000",
-		"-ac", "2",
-		outputPath,
-	}
-
-	cmd := exec.Command("ffmpeg", args...)
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("SVT-AV1 encoding failed: %v, stderr: %s", err, stderr.String())
-	}
-
-	if stat, err := os.Stat(outputPath); err != nil || stat.Size() == 0 {
-		return fmt.Errorf("AV1 encoding produced invalid output file")
-	}
-
-	return nil
-}
-
-func (p *videoProcessor) encodeSingleSegmentWithH264Optimized(inputPath, outputPath string, preset QualityPreset) error {
-	hwAccel := p.detectHardwareAcceleration()
-	cores := runtime.NumCPU()
-
-	args := []string{
-		"-y",
-		"-hide_banner",
-		"-loglevel", "error",
-		"-i", inputPath,
-	}
-
-	var encoder string
-	var hwAccelArgs []string
-
-	switch hwAccel {
-	case HWAccelNVENC:
-		encoder = "h264_nvenc"
-		hwAccelArgs = []string{
-			"-hwaccel", "cuda",
-			"-hwaccel_output_format", "cuda",
-		}
-	case HWAccelQSV:
-		encoder = "h264_qsv"
-		hwAccelArgs = []string{
-			"-hwaccel", "qsv",
-			"-hwaccel_output_format", "qsv",
-		}
-	case HWAccelAMF:
-		encoder = "h264_amf"
-		hwAccelArgs = []string{
-			"-hwaccel", "d3d11va",
-			"-hwaccel_output_format", "d3d11",
-		}
-	case HWAccelVAAPI:
-		encoder = "h264_vaapi"
-		hwAccelArgs = []string{
-			"-hwaccel", "vaapi",
-			"-hwaccel_output_format", "vaapi",
-			"-hwaccel_device", "/dev/dri/renderD128",
-		}
-	default:
-		encoder = "libx264"
-	}
-
-	args = append(args, hwAccelArgs...)
-
-	videoFilter := fmt.Sprintf("scale=%d:%d", preset.Resolution[0], preset.Resolution[1])
-	if hwAccel == HWAccelVAAPI {
-		videoFilter = fmt.Sprintf("scale_vaapi=%d:%d", preset.Resolution[0], preset.Resolution[1])
-	} else if hwAccel == HWAccelNVENC {
-		videoFilter = fmt.Sprintf("scale_cuda=%d:%d", preset.Resolution[0], preset.Resolution[1])
-	}
-
-	encodingArgs := []string{
-		"-c:v", encoder,
-		"-preset", "fast",
-		"-vf", videoFilter,
-		"-b:v", fmt.Sprintf("%dk", preset.Bitrate),
-		"-maxrate", fmt.Sprintf("%dk", int(float64(preset.Bitrate)*1.1)),
-		"-bufsize", fmt.Sprintf("%dk", preset.Bitrate),
-		"-g", "30",
-		"-keyint_min", "30",
-		"-sc_threshold", "0",
-		"-avoid_negative_ts", "make_zero",
-		"-fflags", "+genpts",
-		"-async", "1",
-		"-vsync", "cfr",
-		"-af", "aresample=async=1",
-		"-movflags", "+faststart",
-		"-c:a", "aac",
-		"-b:a", "96k",
-		"-ar", "44100",
-		"-ac", "2",
-	}
-
-	if hwAccel == HWAccelNone {
-		encodingArgs = append(encodingArgs,
-			"-profile:v", "main",
-			"-level", "3.1",
-			"-threads", fmt.Sprintf("%d", cores),
-			"-x264-params", "ref=1:bframes=0:b-adapt=0:direct=spatial:me=dia:subme=1:trellis=0:rc-lookahead=10",
-		)
-	} else if hwAccel == HWAccelNVENC {
-		encodingArgs = append(encodingArgs,
-			"-profile:v", "main",
-			"-level", "3.1",
-			"-rc", "cbr",
-			"-rc-lookahead", "8",
-			"-surfaces", "8",
-			"-bf", "0",
-		)
-	}
-
-	args = append(args, encodingArgs...)
-	args = append(args, outputPath)
-
-	cmd := exec.Command("ffmpeg", args...)
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		if hwAccel != HWAccelNone {
-			return p.encodeSingleSegmentWithH264Software(inputPath, outputPath, preset)
-		}
-		return fmt.Errorf("optimized H.264 encoding failed: %v, stderr: %s", err, stderr.String())
-	}
-
-	if stat, err := os.Stat(outputPath); err != nil || stat.Size() == 0 {
-		return fmt.Errorf("encoding produced invalid output file")
-	}
-
-	return nil
-}
-
-func (p *videoProcessor) encodeSingleSegmentWithSVTAV1Optimized(inputPath, outputPath string, preset QualityPreset) error {
-	cores := runtime.NumCPU()
-	svtPreset := "10"
-
-	switch {
-	case cores >= 32:
-		svtPreset = "8"
-	case cores >= 16:
-		svtPreset = "9"
-	case cores >= 8:
-		svtPreset = "10"
-	default:
-		svtPreset = "11"
-	}
-
-	args := []string{
-		"-y",
-		"-hide_banner",
-		"-loglevel", "error",
-		"-i", inputPath,
-		"-c:v", "libsvtav1",
-		"-preset", svtPreset,
-		"-vf", fmt.Sprintf("scale=%d:%d", preset.Resolution[0], preset.Resolution[1]),
-		"-crf", "32",
-		"-maxrate", fmt.Sprintf("%dk", int(float64(preset.Bitrate)*1.1)),
-		"-bufsize", fmt.Sprintf("%dk", preset.Bitrate),
-		"-g", "120",
-		"-keyint_min", "120",
-		"-tile-columns", "4",
-		"-tile-rows", "2",
-		"-avoid_negative_ts", "make_zero",
-		"-fflags", "+genpts",
-		"-async", "1",
-		"-vsync", "cfr",
-		"-af", "aresample=async=1",
-		"-movflags", "+faststart",
-		"-c:a", "aac",
-		"-b:a", "96k",
-		"-ar", "44100",
-		"-ac", "2",
-		outputPath,
-	}
-
-	cmd := exec.Command("ffmpeg", args...)
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("optimized SVT-AV1 encoding failed: %v, stderr: %s", err, stderr.String())
-	}
-
-	if stat, err := os.Stat(outputPath); err != nil || stat.Size() == 0 {
-		return fmt.Errorf("AV1 encoding produced invalid output file")
-	}
-
-	return nil
-}
-
 func (p *videoProcessor) uploadProcessedFiles(ctx context.Context, outputPath, outputKey string) error {
 	if outputPath == "" || outputKey == "" {
 		return fmt.Errorf("output path and key cannot be empty")
@@ -837,7 +710,7 @@ func (p *videoProcessor) uploadProcessedFiles(ctx context.Context, outputPath, o
 	outputKey = strings.TrimPrefix(outputKey, "/")
 	baseKey := strings.TrimSuffix(outputKey, filepath.Ext(outputKey))
 
-	log.Printf("Starting concurrent upload process from %s with base key: %s", outputPath, baseKey)
+	p.logger.Infof("Starting concurrent upload process from %s with base key: %s", outputPath, baseKey)
 
 	type uploadJob struct {
 		path     string
@@ -863,7 +736,7 @@ func (p *videoProcessor) uploadProcessedFiles(ctx context.Context, outputPath, o
 					case <-ctx.Done():
 					}
 				} else {
-					log.Printf("Worker %d successfully uploaded %s", workerID, job.s3Key)
+					p.logger.Debugf("Upload worker %d uploaded %s", workerID, job.s3Key)
 				}
 			}
 		}(i)
@@ -925,7 +798,7 @@ func (p *videoProcessor) uploadProcessedFiles(ctx context.Context, outputPath, o
 	}
 
 	if len(uploadErrors) > 0 {
-		return fmt.Errorf("encountered %d upload errors: %v", len(uploadErrors), uploadErrors[0])
+		return fmt.Errorf("encountered %d upload errors: %w", len(uploadErrors), uploadErrors[0])
 	}
 
 	return nil
@@ -954,13 +827,17 @@ func (p *videoProcessor) uploadSingleFile(ctx context.Context, path, s3Key strin
 			return fmt.Errorf("failed to reset file pointer: %w", err)
 		}
 
-		_, err := p.awsRepo.PutObject(ctx, uploadInput)
+		err := p.putObject(ctx, uploadInput)
 		if err == nil {
 			return nil
 		}
 
+		if errors.Is(err, videofiles.ErrStorageUnavailable) {
+			return err
+		}
+
 		if attempt < maxRetries {
-			log.Printf("Upload attempt %d/%d failed for %s: %v. Retrying...",
+			p.logger.Warnf("Upload attempt %d/%d failed for %s: %v. Retrying...",
 				attempt, maxRetries, s3Key, err)
 			time.Sleep(time.Duration(attempt) * time.Second)
 			continue
@@ -973,6 +850,12 @@ func (p *videoProcessor) uploadSingleFile(ctx context.Context, path, s3Key strin
 }
 
 func (p *videoProcessor) uploadSingleFileOptimized(ctx context.Context, path, s3Key string, fileInfo os.FileInfo) error {
+	return p.uploadFileWithDisposition(ctx, path, s3Key, fileInfo, "")
+}
+
+// uploadFileWithDisposition uploads a file with the given Content-Disposition,
+// which is left unset when empty.
+func (p *videoProcessor) uploadFileWithDisposition(ctx context.Context, path, s3Key string, fileInfo os.FileInfo, disposition string) error {
 	file, err := os.Open(path)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %w", err)
@@ -987,6 +870,8 @@ func (p *videoProcessor) uploadSingleFileOptimized(ctx context.Context, path, s3
 		Key:        s3Key,
 		MimeType:   contentType,
 		Size:       fileInfo.Size(),
+
+		ContentDisposition: disposition,
 	}
 
 	maxRetries := 2
@@ -995,11 +880,15 @@ func (p *videoProcessor) uploadSingleFileOptimized(ctx context.Context, path, s3
 			return fmt.Errorf("failed to reset file pointer: %w", err)
 		}
 
-		_, err := p.awsRepo.PutObject(ctx, uploadInput)
+		err := p.putObject(ctx, uploadInput)
 		if err == nil {
 			return nil
 		}
 
+		if errors.Is(err, videofiles.ErrStorageUnavailable) {
+			return err
+		}
+
 		if attempt < maxRetries {
 			time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
 			continue
@@ -1011,7 +900,7 @@ func (p *videoProcessor) uploadSingleFileOptimized(ctx context.Context, path, s3
 	return nil
 }
 
-func (p *videoProcessor) uploadSubtitleAndThumbnailFiles(ctx context.Context, subtitleFiles []string, thumbnailPath, outputKey string) error {
+func (p *videoProcessor) uploadSubtitleAndThumbnailFiles(ctx context.Context, subtitleFiles, thumbnailPaths []string, outputKey string) error {
 	baseKey := strings.TrimSuffix(outputKey, filepath.Ext(outputKey))
 
 	for _, subtitleFile := range subtitleFiles {
@@ -1035,9 +924,9 @@ func (p *videoProcessor) uploadSubtitleAndThumbnailFiles(ctx context.Context, su
 		}
 	}
 
-	if thumbnailPath != "" {
+	for _, thumbnailPath := range thumbnailPaths {
 		fileName := filepath.Base(thumbnailPath)
-		s3Key := fmt.Sprintf("%s/%s", baseKey, fileName)
+		s3Key := fmt.Sprintf("%s/thumbnails/%s", baseKey, fileName)
 
 		fileInfo, err := os.Stat(thumbnailPath)
 		if err != nil {
@@ -1086,8 +975,17 @@ func getContentType(filename string) string {
 		return "application/octet-stream"
 	}
 }
+// cleanup removes the job's workspace. Other jobs, and other runs of the
+// same job, work in workspaces of their own and are left alone.
 func (p *videoProcessor) cleanup() {
-	os.RemoveAll(p.tempDir)
+	p.notifier.stop()
+	if p.workspace == nil {
+		return
+	}
+	if err := p.workspace.Remove(); err != nil {
+		p.logger.Warnf("Failed to remove workspace %s: %v", p.workspace.Dir, err)
+	}
+	p.workspace = nil
 }
 
 func (p *videoProcessor) downloadVideo(ctx context.Context, inputKey string) (string, error) {
@@ -1109,8 +1007,15 @@ func (p *videoProcessor) downloadVideo(ctx context.Context, inputKey string) (st
 	}
 	defer outFile.Close()
 
+	var body io.Reader = videoFile.Body
+	if videoFile.ContentLength != nil {
+		body = &progressReader{Reader: videoFile.Body, size: *videoFile.ContentLength, report: func(percent float64) {
+			p.reportStage(models.ProgressDownloading, percent)
+		}}
+	}
+
 	buffer := make([]byte, 1024*1024)
-	if _, err = io.CopyBuffer(outFile, videoFile.Body, buffer); err != nil {
+	if _, err = io.CopyBuffer(outFile, body, buffer); err != nil {
 		return "", fmt.Errorf("failed to write video file: %w", err)
 	}
 
@@ -1127,21 +1032,47 @@ func (p *videoProcessor) splitVideo(inputPath string, videoInfo *VideoInfo) ([]s
 	segmentCount := math.Min(math.Ceil(videoInfo.Duration/optimalSegmentDuration), MaxSegments)
 	segmentDuration := math.Ceil(videoInfo.Duration / segmentCount)
 
+	// A resumed job must be cut exactly like the original so checkpointed
+	// segment indices still line up.
+	if p.resume != nil && p.resume.SegmentDuration > 0 {
+		segmentDuration = p.resume.SegmentDuration
+	}
+
+	plan, err := p.planSegments(inputPath, videoInfo, segmentDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cuts are on keyframes; the delta absorbs rounding of their times
+	splitArgs := []string{"-segment_time", fmt.Sprintf("%.0f", segmentDuration)}
+	if len(plan.cuts) > 0 {
+		delta := 0.02
+		if videoInfo.FrameRate > 0 {
+			delta = 0.5 / videoInfo.FrameRate
+		}
+		splitArgs = []string{
+			"-segment_times", formatTimes(plan.cuts),
+			"-segment_time_delta", strconv.FormatFloat(delta, 'f', 6, 64),
+		}
+	}
+
 	args := []string{
 		"-y",
 		"-hide_banner",
 		"-loglevel", "error",
-		"-i", inputPath,
+		"-i", plan.path,
 		"-c", "copy",
 		"-f", "segment",
-		"-segment_time", fmt.Sprintf("%.0f", segmentDuration),
+	}
+	args = append(args, splitArgs...)
+	args = append(args,
 		"-avoid_negative_ts", "make_zero",
 		"-fflags", "+genpts",
 		"-segment_format_options", "movflags=+faststart",
 		filepath.Join(segmentDir, "segment_%03d.mp4"),
-	}
+	)
 
-	cmd := exec.Command("ffmpeg", args...)
+	cmd := ffmpegCommand(args...)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
@@ -1158,6 +1089,11 @@ func (p *videoProcessor) splitVideo(inputPath string, videoInfo *VideoInfo) ([]s
 		return nil, fmt.Errorf("no segments were created")
 	}
 
+	p.checkpointMu.Lock()
+	p.checkpoint.SegmentDuration = segmentDuration
+	p.checkpoint.SegmentCount = len(segments)
+	p.checkpointMu.Unlock()
+
 	return segments, nil
 }
 
@@ -1184,7 +1120,7 @@ func (p *videoProcessor) normalizeVideoDuration(inputPath string, targetDuration
 	outputPath := inputPath + ".normalized.mp4"
 
 	// Use FFmpeg to precisely trim the video to the target duration
-	cmd := exec.Command("ffmpeg",
+	cmd := ffmpegCommand(
 		"-i", inputPath,
 		"-t", fmt.Sprintf("%.3f", targetDuration),
 		"-c", "copy",
@@ -1206,7 +1142,7 @@ func (p *videoProcessor) normalizeVideoDuration(inputPath string, targetDuration
 func (p *videoProcessor) stitchAndPackageMultiQuality(qualitySegments map[models.VideoQuality][]string, outputPath string) error {
 
 	packagingDir := filepath.Join(p.tempDir, "packaging")
-	log.Println("packagingDir ",packagingDir)
+	p.logger.Debugf("Packaging into %s", packagingDir)
 	if err := os.MkdirAll(packagingDir, 0755); err != nil {
 		return fmt.Errorf("failed to create packaging directory: %w", err)
 	}
@@ -1247,6 +1183,7 @@ func (p *videoProcessor) stitchAndPackageMultiQuality(qualitySegments map[models
 
 	// Ensure all videos have the same duration
 	fragmentPaths := []string{}
+	normalizedPaths := make(map[models.VideoQuality]string)
 	for quality, stitchedPath := range stitchedPaths {
 		info, err := GetVideoInfo(stitchedPath)
 		if err != nil {
@@ -1265,6 +1202,11 @@ func (p *videoProcessor) stitchAndPackageMultiQuality(qualitySegments map[models
 			}
 		}
 
+		normalizedPaths[quality] = normalizedPath
+		if p.contentKey == nil {
+			continue
+		}
+
 		fragmentedPath := filepath.Join(packagingDir, fmt.Sprintf("fragmented_%s.mp4", quality))
 		if err := p.fragmentVideo(normalizedPath, fragmentedPath); err != nil {
 			return fmt.Errorf("failed to fragment video for quality %s: %w", quality, err)
@@ -1277,6 +1219,22 @@ func (p *videoProcessor) stitchAndPackageMultiQuality(qualitySegments map[models
 		segmentDuration: 4,
 		withHLS:         true,
 		withDASH:        true,
+		contentKey:      p.contentKey,
+		lowLatency:      p.job.LowLatencyHLS,
+	}
+
+	// Unencrypted output is packaged once as CMAF for both formats
+	if p.contentKey == nil {
+		var inputs []cmafInput
+		for _, preset := range p.presets {
+			if normalizedPath, ok := normalizedPaths[preset.Name]; ok {
+				inputs = append(inputs, cmafInput{Path: normalizedPath, Name: string(preset.Name)})
+			}
+		}
+		if _, err := p.packageCMAF(inputs, outputPath, opts); err != nil {
+			return fmt.Errorf("failed to package video: %w", err)
+		}
+		return nil
 	}
 
 	p.logger.Info(fmt.Sprintf("Packaging %d fragment paths", len(fragmentPaths)))
@@ -1339,7 +1297,7 @@ func (p *videoProcessor) stitchSegmentsToFileOptimized(segments []string, output
 		outputPath,
 	}
 
-	cmd := exec.Command("ffmpeg", args...)
+	cmd := ffmpegCommand(args...)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
@@ -1380,73 +1338,13 @@ func (p *videoProcessor) copyFile(src, dst string) error {
 	return err
 }
 
-func (p *videoProcessor) createMasterPlaylist(outputPath string, qualitySegments map[models.VideoQuality][]string) error {
-	masterPlaylistPath := filepath.Join(outputPath, "master.m3u8")
-	file, err := os.Create(masterPlaylistPath)
-	if err != nil {
-		return fmt.Errorf("failed to create master playlist: %w", err)
-	}
-	defer file.Close()
-
-	if _, err := file.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n"); err != nil {
-		return fmt.Errorf("failed to write to master playlist: %w", err)
-	}
-
-	qualityOrder := []models.VideoQuality{
-		models.Quality1080P,
-		models.Quality720P,
-		models.Quality480P,
-		models.Quality360P,
-	}
-
-	for _, quality := range qualityOrder {
-		if _, exists := qualitySegments[quality]; !exists {
-			continue
-		}
-
-		var bandwidth int
-		var resolution string
-
-		switch quality {
-		case models.Quality1080P:
-			bandwidth = 4500000
-			resolution = "1920x1080"
-		case models.Quality720P:
-			bandwidth = 2500000
-			resolution = "1280x720"
-		case models.Quality480P:
-			bandwidth = 1000000
-			resolution = "854x480"
-		case models.Quality360P:
-			bandwidth = 600000
-			resolution = "640x360"
-		}
-
-		streamInfo := fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%s,CODECS=\"av01.0.08M.08.0.110.01.01.01.0,mp4a.40.2\",FRAME-RATE=30\n",
-			bandwidth, resolution)
-		if _, err := file.WriteString(streamInfo); err != nil {
-			return fmt.Errorf("failed to write stream info to master playlist: %w", err)
-		}
-
-		variantPath := fmt.Sprintf("%s/master.m3u8\n", quality)
-		if _, err := file.WriteString(variantPath); err != nil {
-			return fmt.Errorf("failed to write variant path to master playlist: %w", err)
-		}
-	}
-
-	return nil
-}
-
-
-
 func GetVideoInfo(inputPath string) (*VideoInfo, error) {
-	dir, err := os.Getwd()
+	finalPath, err := filepath.Abs(inputPath)
 	if err != nil {
 		return nil, err
 	}
-	finalPath := filepath.Join(dir, inputPath)
 
-	cmd := exec.Command("ffprobe", "-v", "quiet", "-select_streams", "v:0",
+	cmd := ffprobeCommand("-v", "quiet", "-select_streams", "v:0",
 		"-show_entries", "stream=width,height", "-of", "csv=p=0", finalPath)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -1485,7 +1383,7 @@ func GetVideoInfo(inputPath string) (*VideoInfo, error) {
 		return nil, fmt.Errorf("invalid height: %v", err)
 	}
 
-	cmd = exec.Command("ffprobe", "-v", "quiet", "-show_entries",
+	cmd = ffprobeCommand("-v", "quiet", "-show_entries",
 		"format=duration", "-of", "csv=p=0", finalPath)
 	durationOutput, err := cmd.CombinedOutput()
 	if err != nil {
@@ -1508,11 +1406,30 @@ func GetVideoInfo(inputPath string) (*VideoInfo, error) {
 		return nil, fmt.Errorf("invalid duration: %v", err)
 	}
 
-	return &VideoInfo{
-		Width:    width,
-		Height:   height,
-		Duration: duration,
-	}, nil
+	// Untagged or unprobeable colour is treated as SDR
+	primaries, transfer, space, _ := probeColor(finalPath)
+
+	displayWidth, displayHeight := width, height
+	if sarNum, sarDen, rotation, err := probeDisplayGeometry(finalPath); err == nil {
+		displayWidth, displayHeight = displaySize(width, height, sarNum, sarDen, rotation)
+	}
+
+	info := &VideoInfo{
+		Width:          width,
+		Height:         height,
+		DisplayWidth:   displayWidth,
+		DisplayHeight:  displayHeight,
+		Duration:       duration,
+		ColorPrimaries: primaries,
+		ColorTransfer:  transfer,
+		ColorSpace:     space,
+	}
+	if num, den, err := probeFrameRate(finalPath); err == nil {
+		info.FrameRate = float64(num) / float64(den)
+		info.FrameRateNum = num
+		info.FrameRateDen = den
+	}
+	return info, nil
 }
 
 func (p *videoProcessor) parseLogFile(filename, key string) (float64, error) {
@@ -1561,7 +1478,7 @@ func (p *videoProcessor) analyzeComplexity(inputPath string) (spatial, temporal
 	defer os.Remove(spatialLog)
 	defer os.Remove(temporalLog)
 
-	cmdSpatial := exec.Command("ffmpeg",
+	cmdSpatial := ffmpegCommand(
 		"-i", inputPath,
 		"-vf", "signalstats=stat=tout,metadata=print:key=lavfi.signalstats.YAVG:file="+spatialLog,
 		"-f", "null", "-",
@@ -1580,7 +1497,7 @@ func (p *videoProcessor) analyzeComplexity(inputPath string) (spatial, temporal
 	}
 	spatial = math.Pow(yavg, 2)
 
-	cmdTemp := exec.Command("ffmpeg",
+	cmdTemp := ffmpegCommand(
 		"-i", inputPath,
 		"-vf", "signalstats=stat=tout,metadata=print:key=lavfi.signalstats.YDIF:file="+temporalLog,
 		"-f", "null", "-",
@@ -1635,7 +1552,7 @@ func (p *videoProcessor) extractSubtitles(inputPath string) ([]string, error) {
 		return nil, fmt.Errorf("failed to create subtitle directory: %w", err)
 	}
 
-	cmd := exec.Command("ffprobe", "-v", "quiet", "-select_streams", "s",
+	cmd := ffprobeCommand("-v", "quiet", "-select_streams", "s",
 		"-show_entries", "stream=index,codec_name:stream_tags=language,title",
 		"-of", "csv=p=0", inputPath)
 
@@ -1675,7 +1592,7 @@ func (p *videoProcessor) extractSubtitles(inputPath string) ([]string, error) {
 
 		tempOutputPath := filepath.Join(subtitleDir, fmt.Sprintf("temp_subtitle_%d.%s", subtitleIndex, getOriginalSubtitleExt(codecName)))
 
-		extractCmd := exec.Command("ffmpeg", "-y", "-hide_banner", "-loglevel", "error",
+		extractCmd := ffmpegCommand("-y", "-hide_banner", "-loglevel", "error",
 			"-i", inputPath, "-map", fmt.Sprintf("0:%s", streamIndex), "-c:s", "copy", tempOutputPath)
 
 		var stderr bytes.Buffer
@@ -1743,7 +1660,7 @@ func (p *videoProcessor) convertToVTT(inputPath, outputPath, codecName string) e
 		outputPath,
 	}
 
-	cmd := exec.Command("ffmpeg", args...)
+	cmd := ffmpegCommand(args...)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
@@ -1758,43 +1675,3 @@ func (p *videoProcessor) convertToVTT(inputPath, outputPath, codecName string) e
 	return nil
 }
 
-func (p *videoProcessor) generateThumbnail(inputPath string, duration float64) (string, error) {
-	thumbnailDir := filepath.Join(p.tempDir, "thumbnails")
-	if err := os.MkdirAll(thumbnailDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create thumbnail directory: %w", err)
-	}
-
-	outputPath := filepath.Join(thumbnailDir, "thumbnail.jpg")
-
-	timestamp := duration * 0.1
-	if timestamp < 1.0 {
-		timestamp = 1.0
-	}
-
-	args := []string{
-		"-y",
-		"-hide_banner",
-		"-loglevel", "error",
-		"-ss", fmt.Sprintf("%.2f", timestamp),
-		"-i", inputPath,
-		"-vframes", "1",
-		"-q:v", "2",
-		"-vf", "scale=1280:720:force_original_aspect_ratio=decrease,pad=1280:720:(ow-iw)/2:(oh-ih)/2",
-		outputPath,
-	}
-
-	cmd := exec.Command("ffmpeg", args...)
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("thumbnail generation failed: %v, stderr: %s", err, stderr.String())
-	}
-
-	if stat, err := os.Stat(outputPath); err != nil || stat.Size() == 0 {
-		return "", fmt.Errorf("thumbnail generation produced invalid output file")
-	}
-
-	p.logger.Infof("Generated thumbnail at timestamp %.2fs: %s", timestamp, outputPath)
-	return outputPath, nil
-}
@@ -0,0 +1,306 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// EncodeProgressStart and EncodeProgressEnd bound the job progress
+	// reported while segments are encoded
+	EncodeProgressStart = 30
+	EncodeProgressEnd   = 80
+	// progressReportInterval throttles progress writes during encoding
+	progressReportInterval = 2 * time.Second
+	// DefaultProgressInterval is the least time between two progress
+	// notifications of a job
+	DefaultProgressInterval = 2 * time.Second
+)
+
+// stageProgress is the range of job progress each stage moves through.
+// Encoding stages share EncodeProgressStart to EncodeProgressEnd.
+var stageProgress = map[models.ProgressStage][2]float64{
+	models.ProgressDownloading: {0, 10},
+	models.ProgressScanning:    {10, 12},
+	models.ProgressProbing:     {12, 25},
+	models.ProgressSplitting:   {25, EncodeProgressStart},
+	models.ProgressPackaging:   {EncodeProgressEnd, 85},
+	models.ProgressUploading:   {85, 90},
+}
+
+// repackageStageProgress is stageProgress for repackage jobs, which
+// download the packaged renditions instead of the source
+var repackageStageProgress = map[models.ProgressStage][2]float64{
+	models.ProgressDownloading: {0, 30},
+	models.ProgressPackaging:   {30, 60},
+	models.ProgressUploading:   {60, 90},
+}
+
+// encodeProgress aggregates what ffmpeg reports for every segment of every
+// quality into the job's progress, so it moves smoothly between
+// EncodeProgressStart and EncodeProgressEnd instead of once per quality.
+type encodeProgress struct {
+	mu sync.Mutex
+	// duration is the seconds of media to encode per quality, and total
+	// over all qualities
+	duration float64
+	total    float64
+	// segmentDuration estimates segments encoded before a resume
+	segmentDuration float64
+	// encoded holds the seconds encoded into each output file
+	encoded    map[string]float64
+	reported   int
+	reportedAt time.Time
+	report     func(progress int)
+	// stage is passed the percentage of a quality that is encoded and the
+	// job's progress on every update; unlike report it is not throttled
+	stage func(quality models.VideoQuality, percent, progress float64)
+}
+
+// newEncodeProgress tracks the encode of segments of a video of duration
+// into qualities, passing every new percentage to report
+func newEncodeProgress(duration float64, qualities, segments int, report func(progress int)) *encodeProgress {
+	e := &encodeProgress{
+		duration: duration,
+		total:    duration * float64(qualities),
+		encoded:  make(map[string]float64),
+		reported: EncodeProgressStart,
+		report:   report,
+	}
+	if segments > 0 {
+		e.segmentDuration = duration / float64(segments)
+	}
+	return e
+}
+
+// update records the seconds encoded into outputPath and reports the job's
+// progress when it moved and the last report is old enough
+func (e *encodeProgress) update(outputPath string, seconds float64) {
+	if e == nil || e.total <= 0 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.encoded[outputPath] = seconds
+	// Segments are encoded into a directory named after their quality
+	quality := filepath.Base(filepath.Dir(outputPath))
+	var encoded, qualityEncoded float64
+	for path, s := range e.encoded {
+		encoded += s
+		if filepath.Base(filepath.Dir(path)) == quality {
+			qualityEncoded += s
+		}
+	}
+	fraction := math.Min(encoded/e.total, 1)
+	if e.stage != nil {
+		percent := math.Min(qualityEncoded/e.duration, 1) * 100
+		e.stage(models.VideoQuality(quality), percent, EncodeProgressStart+fraction*(EncodeProgressEnd-EncodeProgressStart))
+	}
+	progress := EncodeProgressStart + int(fraction*(EncodeProgressEnd-EncodeProgressStart))
+	if progress <= e.reported || time.Since(e.reportedAt) < progressReportInterval {
+		return
+	}
+	e.reported, e.reportedAt = progress, time.Now()
+	e.report(progress)
+}
+
+// skip counts a segment encoded before the job was resumed
+func (e *encodeProgress) skip(outputPath string) {
+	if e != nil {
+		e.update(outputPath, e.segmentDuration)
+	}
+}
+
+// finish reports the end of encoding
+func (e *encodeProgress) finish() {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.reported < EncodeProgressEnd {
+		e.reported, e.reportedAt = EncodeProgressEnd, time.Now()
+		e.report(EncodeProgressEnd)
+	}
+}
+
+// progressWriter parses the key=value lines ffmpeg writes with -progress and
+// records the encoded time of its output file.
+type progressWriter struct {
+	progress   *encodeProgress
+	outputPath string
+	buf        []byte
+}
+
+func (w *progressWriter) Write(data []byte) (int, error) {
+	w.buf = append(w.buf, data...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		key, value, ok := bytes.Cut(bytes.TrimSpace(w.buf[:i]), []byte("="))
+		w.buf = w.buf[i+1:]
+		if !ok || string(key) != "out_time_us" {
+			continue
+		}
+		if us, err := strconv.ParseInt(string(value), 10, 64); err == nil && us > 0 {
+			w.progress.update(w.outputPath, float64(us)/1e6)
+		}
+	}
+	return len(data), nil
+}
+
+// encodeCommand is ffmpegCommand for encoding a segment into outputPath. The
+// encode's progress is fed to the job's progress.
+func (p *videoProcessor) encodeCommand(outputPath string, args ...string) *exec.Cmd {
+	if p.progress == nil {
+		return ffmpegCommand(args...)
+	}
+	cmd := ffmpegCommand(append([]string{"-progress", "pipe:1", "-nostats"}, args...)...)
+	cmd.Stdout = &progressWriter{progress: p.progress, outputPath: outputPath}
+	return cmd
+}
+
+// trackEncodeProgress starts tracking the encode of segments into the
+// job's presets. Progress goes to the video and to the job in Redis.
+func (p *videoProcessor) trackEncodeProgress(ctx context.Context, videoID uuid.UUID, duration float64, segments int) {
+	// Drained workers still finish the segments in flight
+	ctx = context.WithoutCancel(ctx)
+	p.progress = newEncodeProgress(duration, len(p.presets), segments, func(progress int) {
+		if err := p.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusProcessing, float64(progress)); err != nil {
+			p.logger.Errorf("Failed to update encode progress: %v", err)
+		}
+	})
+	p.progress.stage = func(quality models.VideoQuality, percent, progress float64) {
+		p.notifier.notify(&models.JobProgress{
+			JobID:         p.job.JobID,
+			Stage:         models.EncodingStage(quality),
+			StageProgress: math.Round(percent),
+			Progress:      math.Round(progress),
+		})
+	}
+}
+
+// reportStage notifies that percent of stage is done
+func (p *videoProcessor) reportStage(stage models.ProgressStage, percent float64) {
+	bounds := stageProgress[stage]
+	switch p.job.Type {
+	case models.JobTypeRepackage:
+		bounds = repackageStageProgress[stage]
+	case models.JobTypeAudio, models.JobTypeImage:
+		bounds = assetStageProgress[stage]
+	case models.JobTypePlan:
+		bounds = planStageProgress[stage]
+	}
+	p.notifier.notify(&models.JobProgress{
+		JobID:         p.job.JobID,
+		Stage:         stage,
+		StageProgress: math.Round(percent),
+		Progress:      math.Round(bounds[0] + (bounds[1]-bounds[0])*percent/100),
+	})
+}
+
+// progressNotifier publishes the progress notifications of a job at most once
+// per interval. A notification coming in sooner is held back until the
+// interval is over, and replaced by any that comes in meanwhile, so the last
+// one of a burst is still published.
+type progressNotifier struct {
+	mu       sync.Mutex
+	interval time.Duration
+	publish  func(progress *models.JobProgress)
+	sentAt   time.Time
+	pending  *models.JobProgress
+	timer    *time.Timer
+	stopped  bool
+}
+
+func newProgressNotifier(interval time.Duration, publish func(progress *models.JobProgress)) *progressNotifier {
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+	return &progressNotifier{interval: interval, publish: publish}
+}
+
+// notify publishes progress now, or once the interval since the last
+// notification is over
+func (n *progressNotifier) notify(progress *models.JobProgress) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.stopped {
+		return
+	}
+
+	progress.Timestamp = time.Now()
+	wait := n.interval - time.Since(n.sentAt)
+	if wait <= 0 && n.pending == nil {
+		n.sentAt = time.Now()
+		go n.publish(progress)
+		return
+	}
+	n.pending = progress
+	if n.timer == nil {
+		n.timer = time.AfterFunc(max(wait, 0), n.flush)
+	}
+}
+
+// flush publishes the notification held back by notify
+func (n *progressNotifier) flush() {
+	n.mu.Lock()
+	progress := n.pending
+	n.pending, n.timer = nil, nil
+	if progress == nil || n.stopped {
+		n.mu.Unlock()
+		return
+	}
+	n.sentAt = time.Now()
+	n.mu.Unlock()
+	n.publish(progress)
+}
+
+// stop drops any held back notification. The job's status notification
+// follows, which supersedes it.
+func (n *progressNotifier) stop() {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.stopped = true
+	n.pending = nil
+	if n.timer != nil {
+		n.timer.Stop()
+		n.timer = nil
+	}
+}
+
+// progressReader reports the share of a download of size bytes read so far
+type progressReader struct {
+	io.Reader
+	size   int64
+	read   int64
+	report func(percent float64)
+}
+
+func (r *progressReader) Read(data []byte) (int, error) {
+	n, err := r.Reader.Read(data)
+	r.read += int64(n)
+	if r.size > 0 && n > 0 {
+		r.report(math.Min(float64(r.read)/float64(r.size), 1) * 100)
+	}
+	return n, err
+}
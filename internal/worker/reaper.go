@@ -0,0 +1,111 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// ReaperInterval is how often orphaned jobs are looked for
+	ReaperInterval = HeartbeatTTL
+	// MaxJobRecoveries is how many times a job is re-enqueued after losing its
+	// worker before it is failed, so a job that crashes workers cannot take
+	// down the whole fleet one worker at a time
+	MaxJobRecoveries = 3
+)
+
+// reapOrphanedJobs periodically re-enqueues jobs whose worker stopped sending
+// job heartbeats, such as after a crash or an OOM kill, which would otherwise
+// leave their videos processing forever. Every worker runs a reaper; claiming
+// a job before recovering it keeps them from enqueueing it twice.
+func (w *Worker) reapOrphanedJobs(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(ReaperInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			orphaned, err := w.redisRepo.ListOrphanedJobs(ctx)
+			if err != nil {
+				w.logger.Warnf("Failed to list orphaned jobs: %v", err)
+				continue
+			}
+			for jobID, workerID := range orphaned {
+				w.recoverOrphanedJob(ctx, jobID, workerID)
+			}
+		}
+	}
+}
+
+// recoverOrphanedJob resets an orphaned job to queued and enqueues it again
+// as it was submitted, keeping its resume token if it had one.
+func (w *Worker) recoverOrphanedJob(ctx context.Context, jobID, workerID string) {
+	log := w.logger.With("job_id", jobID)
+	recoveries, err := w.redisRepo.ClaimOrphanedJob(ctx, jobID)
+	if err != nil {
+		log.Errorf("Failed to claim orphaned job %s: %v", jobID, err)
+		return
+	}
+	if recoveries == 0 {
+		return
+	}
+
+	fields, err := w.redisRepo.GetJobHash(ctx, jobID)
+	if err != nil {
+		log.Warnf("Orphaned job %s of worker %s is gone: %v", jobID, workerID, err)
+		return
+	}
+	// The job finished or was handed back after its last heartbeat was sent
+	if models.JobStatus(fields["status"]) != models.JobStatusProcessing {
+		return
+	}
+
+	job := &models.EncodeJob{}
+	if err := json.Unmarshal([]byte(fields["payload"]), job); err != nil {
+		log.Errorf("Cannot recover orphaned job %s, invalid payload: %v", jobID, err)
+		return
+	}
+	videoID, err := uuid.Parse(job.VideoID)
+	if err != nil {
+		log.Errorf("Cannot recover orphaned job %s: invalid video ID: %v", jobID, err)
+		return
+	}
+
+	if recoveries > MaxJobRecoveries {
+		log.Errorf("Job %s lost its worker %d times, failing it", jobID, recoveries)
+		w.failJob(ctx, job, videoID, failedAt(models.FailureWorkerLost,
+			fmt.Errorf("worker %s stopped responding, job was already recovered %d times", workerID, MaxJobRecoveries)))
+		w.releaseUserSlot(job)
+		return
+	}
+
+	log.Warnf("Worker %s stopped responding, re-enqueueing job %s (recovery %d of %d)", workerID, jobID, recoveries, MaxJobRecoveries)
+	if job.Type != models.JobTypePlan {
+		if err := w.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusQueued, 0); err != nil {
+			log.Errorf("Failed to reset progress of orphaned job %s: %v", jobID, err)
+		}
+	}
+	w.requeueJob(job)
+}
+
+// clearJobHeartbeat takes a job this worker no longer runs off the list of
+// processing jobs, so the reaper does not mistake it for an orphan.
+func (w *Worker) clearJobHeartbeat(jobID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := w.redisRepo.ClearJobHeartbeat(ctx, jobID); err != nil {
+		w.logger.Warnf("Failed to clear heartbeat of job %s: %v", jobID, err)
+	}
+}
@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+// EncoderBenchmark is the measured speed and quality of one encoder preset on
+// the reference clip.
+type EncoderBenchmark struct {
+	Codec   models.Codec      `json:"codec"`
+	HWAccel HardwareAccelType `json:"hw_accel"`
+	Encoder string            `json:"encoder"`
+	Preset  string            `json:"preset"`
+	// FPS is the frames encoded per second of wall time
+	FPS float64 `json:"fps"`
+	// Speed is FPS over the clip's frame rate; 1 is real time
+	Speed float64 `json:"speed"`
+	// SSIM of the output against the reference clip, from 0 to 1
+	SSIM  float64 `json:"ssim"`
+	Error string  `json:"error,omitempty"`
+}
+
+// EncoderRecommendations is written by cmd/benchmark for a host. The worker
+// uses its presets in place of the ones it would guess from the core count.
+type EncoderRecommendations struct {
+	Host        string    `json:"host"`
+	CPUs        int       `json:"cpus"`
+	GeneratedAt time.Time `json:"generated_at"`
+	// MinSpeed is the speed a preset had to reach to be recommended
+	MinSpeed float64 `json:"min_speed"`
+	// Presets holds the recommended preset of every codec and hardware
+	// acceleration that was benchmarked
+	Presets []EncoderBenchmark `json:"presets"`
+	Results []EncoderBenchmark `json:"results"`
+}
+
+// LoadRecommendations reads a recommendations file written by cmd/benchmark
+func LoadRecommendations(path string) (*EncoderRecommendations, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encoder recommendations: %w", err)
+	}
+	recommendations := &EncoderRecommendations{}
+	if err := json.Unmarshal(data, recommendations); err != nil {
+		return nil, fmt.Errorf("failed to parse encoder recommendations: %w", err)
+	}
+	return recommendations, nil
+}
+
+// Preset returns the recommended preset of codec with hwAccel. It reports
+// false when r is nil or the combination was not benchmarked.
+func (r *EncoderRecommendations) Preset(codec models.Codec, hwAccel HardwareAccelType) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+	for _, preset := range r.Presets {
+		if preset.Codec == codec && preset.HWAccel == hwAccel && preset.Preset != "" {
+			return preset.Preset, true
+		}
+	}
+	return "", false
+}
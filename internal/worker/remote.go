@@ -0,0 +1,271 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// RemoteFetchAttempts is how often a remote source is requested before
+	// its job fails. Attempts after the first resume where the last stopped
+	// when the server supports range requests.
+	RemoteFetchAttempts = 5
+	// remoteFetchBackoff is the wait before the second attempt, doubled for
+	// every attempt after it
+	remoteFetchBackoff = 2 * time.Second
+	// remoteResponseTimeout bounds the wait for the headers of a response;
+	// the body may take as long as it needs
+	remoteResponseTimeout = 30 * time.Second
+	// DefaultMaxRemoteSourceMB caps the size of remote sources when the
+	// worker config does not
+	DefaultMaxRemoteSourceMB = 10 * 1024
+)
+
+var (
+	// errPrivateAddress is returned for remote sources on addresses that are
+	// not publicly routable, so jobs cannot be used to reach internal services
+	errPrivateAddress = errors.New("remote source resolves to a non-public address")
+	// errSourceTooLarge is returned for remote sources over the size limit
+	errSourceTooLarge = errors.New("remote source exceeds the maximum size")
+)
+
+// nonPublicNetworks are the ranges global unicast addresses include that are
+// not reachable on the internet: this network, shared address space used by
+// carrier-grade NAT, IETF protocol assignments, benchmarking and reserved
+var nonPublicNetworks = func() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range []string{"0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "240.0.0.0/4"} {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}()
+
+// remoteStatusError is an unexpected HTTP status of a remote source
+type remoteStatusError struct {
+	StatusCode int
+}
+
+func (e *remoteStatusError) Error() string {
+	return fmt.Sprintf("remote source returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// retryable reports whether requesting the source again may succeed
+func (e *remoteStatusError) retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusRequestTimeout
+}
+
+// remoteClient fetches remote sources. It only connects to public
+// addresses, including after redirects, and ignores proxy settings since a
+// proxy would connect on its behalf.
+var remoteClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   denyPrivateAddress,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: remoteResponseTimeout,
+	},
+}
+
+// denyPrivateAddress only allows connections to global unicast addresses
+// outside the private and other non-public ranges
+func denyPrivateAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return errPrivateAddress
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return errPrivateAddress
+		}
+	}
+	return nil
+}
+
+// fetchRemoteSource downloads the source of a job submitted with a URL and
+// stores it in the input bucket under the job's input key, so the video can
+// be re-encoded later like any upload. Runs after the first find it there.
+func (p *videoProcessor) fetchRemoteSource(ctx context.Context, videoID uuid.UUID) (string, error) {
+	keys, err := p.awsRepo.ListObjectsWithPrefix(ctx, p.cfg.S3.InputBucket, p.job.InputS3Key)
+	if err == nil && slices.Contains(keys, p.job.InputS3Key) {
+		return p.downloadVideo(ctx, p.job.InputS3Key)
+	}
+
+	if err := os.MkdirAll(p.tempDir, os.ModePerm); err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	localPath := filepath.Join(p.tempDir, filepath.Base(p.job.InputS3Key))
+	size, err := p.downloadURL(ctx, p.job.SourceURL, localPath)
+	if err != nil {
+		return "", err
+	}
+	p.logger.Infof("Fetched %d bytes of remote source for job %s", size, p.job.JobID)
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open remote source: %w", err)
+	}
+	defer file.Close()
+	if err := p.putObject(ctx, models.UploadInput{
+		File:       file,
+		BucketName: p.cfg.S3.InputBucket,
+		Key:        p.job.InputS3Key,
+		MimeType:   getContentType(localPath),
+		Size:       size,
+	}); err != nil {
+		return "", fmt.Errorf("failed to store remote source: %w", err)
+	}
+
+	// The size was unknown when the job was submitted
+	if _, err := p.videoRepo.UpdateVideo(ctx, &models.VideoFile{VideoID: videoID, FileSize: size}); err != nil {
+		p.logger.Warnf("Failed to record size of remote source of job %s: %v", p.job.JobID, err)
+	}
+	return localPath, nil
+}
+
+// downloadURL downloads rawURL to path and returns its size. Failed attempts
+// are retried with backoff, resuming from where they stopped when the
+// server honours range requests.
+func (p *videoProcessor) downloadURL(ctx context.Context, rawURL, path string) (int64, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create local video file: %w", err)
+	}
+	defer file.Close()
+
+	var offset int64
+	backoff := remoteFetchBackoff
+	for attempt := 1; ; attempt++ {
+		offset, err = p.fetchFrom(ctx, rawURL, file, offset)
+		if err == nil {
+			return offset, nil
+		}
+		var statusErr *remoteStatusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() || errors.Is(err, errPrivateAddress) || errors.Is(err, errSourceTooLarge) {
+			return offset, err
+		}
+		if attempt == RemoteFetchAttempts || ctx.Err() != nil {
+			return offset, fmt.Errorf("failed to fetch remote source after %d attempts: %w", attempt, err)
+		}
+		p.logger.Warnf("Fetching remote source of job %s failed at byte %d, retrying in %s: %v", p.job.JobID, offset, backoff, err)
+		select {
+		case <-ctx.Done():
+			return offset, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// maxRemoteSourceSize returns the size limit of remote sources in bytes
+func (p *videoProcessor) maxRemoteSourceSize() int64 {
+	limit := p.cfg.Worker.MaxRemoteSourceMB
+	if limit <= 0 {
+		limit = DefaultMaxRemoteSourceMB
+	}
+	return int64(limit) << 20
+}
+
+// fetchFrom writes rawURL to file from offset on and returns the offset it
+// got to. A server that ignores the range sends the whole file, which then
+// replaces what was written before. Sources over maxRemoteSourceSize are
+// refused by their announced size or cut off once they pass it.
+func (p *videoProcessor) fetchFrom(ctx context.Context, rawURL string, file *os.File, offset int64) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return offset, fmt.Errorf("invalid source url: %w", err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return offset, err
+	}
+	defer resp.Body.Close()
+
+	size := int64(-1)
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		size = contentRangeSize(resp.Header.Get("Content-Range"))
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The last attempt got everything but failed before noticing
+		return offset, nil
+	case resp.StatusCode == http.StatusOK:
+		offset = 0
+		if err := file.Truncate(0); err != nil {
+			return 0, fmt.Errorf("failed to truncate local video file: %w", err)
+		}
+		size = resp.ContentLength
+	default:
+		return offset, &remoteStatusError{StatusCode: resp.StatusCode}
+	}
+	maxSize := p.maxRemoteSourceSize()
+	if size > maxSize {
+		return offset, errSourceTooLarge
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return offset, fmt.Errorf("failed to seek local video file: %w", err)
+	}
+
+	// One byte past the limit tells a source that is too large apart from
+	// one that is exactly at it
+	body := io.LimitReader(resp.Body, maxSize-offset+1)
+	if size > 0 {
+		body = &progressReader{Reader: body, size: size - offset, report: func(percent float64) {
+			done := float64(offset) + float64(size-offset)*percent/100
+			p.reportStage(models.ProgressDownloading, math.Min(done/float64(size), 1)*100)
+		}}
+	}
+	n, err := io.CopyBuffer(file, body, make([]byte, 1024*1024))
+	offset += n
+	if err != nil {
+		return offset, err
+	}
+	if offset > maxSize {
+		return offset, errSourceTooLarge
+	}
+	if size > 0 && offset < size {
+		return offset, io.ErrUnexpectedEOF
+	}
+	return offset, nil
+}
+
+// contentRangeSize returns the complete length in a Content-Range header,
+// e.g. 1000 in "bytes 200-999/1000", or -1 when it is unknown
+func contentRangeSize(header string) int64 {
+	_, total, ok := strings.Cut(header, "/")
+	if !ok {
+		return -1
+	}
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
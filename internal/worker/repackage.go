@@ -0,0 +1,443 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// DASHManifestName is the name of the DASH manifest at the output root
+	DASHManifestName = "stream.mpd"
+	// DefaultRepackageSegmentDuration is used when a repackage job does not
+	// ask for a segment duration
+	DefaultRepackageSegmentDuration = 4
+	// packagedInitSegment is the init segment written in every track
+	// directory
+	packagedInitSegment = "init.mp4"
+)
+
+// packagedSegmentRe matches the media segments of a track, e.g. seg-12.m4s.
+var packagedSegmentRe = regexp.MustCompile(`^seg-(\d+)\.m4s$`)
+
+// packagedTrack is a track of packaged output: an init segment followed by
+// numbered media segments, all in Dir relative to the output root.
+type packagedTrack struct {
+	Dir      string
+	Segments []string
+}
+
+// RepackageVideo packages the renditions of a finished encode again without
+// re-encoding them. Every track of the existing output is rebuilt into a
+// fragmented MP4 from its init and media segments and packaged again to the
+// new segment duration: as CMAF, or by mp4dash when it is encrypted. Files
+// other than the packaged renditions, such as thumbnails and downloads, are
+// left where they are.
+func (p *videoProcessor) RepackageVideo(ctx context.Context, job *models.EncodeJob, videoID uuid.UUID) (*ProcessingResult, error) {
+	if job.OutputS3Key == "" {
+		return nil, fmt.Errorf("output key cannot be empty")
+	}
+
+	if err := p.openWorkspace(); err != nil {
+		return nil, err
+	}
+	defer p.cleanup()
+
+	packagingDir := filepath.Join(p.tempDir, "packaging")
+	if err := os.MkdirAll(packagingDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create packaging directory: %w", err)
+	}
+
+	outputKey := strings.Trim(job.OutputS3Key, "/")
+	baseKey := strings.TrimSuffix(outputKey, filepath.Ext(outputKey))
+
+	keys, err := p.awsRepo.ListObjectsWithPrefix(ctx, p.cfg.S3.OutputBucket, baseKey+"/")
+	if err != nil {
+		return nil, failedAt(models.FailureDownload, fmt.Errorf("failed to list packaged output: %w", err))
+	}
+	existing := make([]string, 0, len(keys))
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, baseKey+"/")
+		// Re-encoded versions are stored under the original output
+		if isVersionPath(name) {
+			continue
+		}
+		existing = append(existing, name)
+	}
+
+	tracks := findPackagedTracks(existing)
+	if len(tracks) == 0 {
+		return nil, failedAt(models.FailureDownload, fmt.Errorf("no packaged renditions found under %s", baseKey))
+	}
+	lowLatency := hasPrefix(existing, LLHLSDir+"/")
+	if job.EnableDRM && lowLatency {
+		// LL-HLS renditions are packaged without mp4dash and cannot be encrypted
+		return nil, failedAt(models.FailurePackage, fmt.Errorf("low latency HLS output cannot be repackaged with DRM"))
+	}
+
+	p.reportStage(models.ProgressDownloading, 0)
+	var trackPaths []string
+	for i, track := range tracks {
+		trackPath := filepath.Join(packagingDir, fmt.Sprintf("track_%d.mp4", i))
+		if err := p.rebuildTrack(ctx, baseKey, track, trackPath); err != nil {
+			if ctx.Err() != nil {
+				return nil, p.interrupt(ctx)
+			}
+			return nil, failedAt(models.FailureDownload, fmt.Errorf("failed to rebuild track %s: %w", track.Dir, err))
+		}
+		trackPaths = append(trackPaths, trackPath)
+		p.reportStage(models.ProgressDownloading, float64(100*(i+1)/len(tracks)))
+	}
+	p.markStage(models.StageDownloaded)
+	p.reportStage(models.ProgressPackaging, 0)
+
+	if err := p.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusProcessing, 30); err != nil {
+		p.logger.Errorf("Failed to update progress after rebuilding tracks: %v", err)
+	}
+
+	segmentDuration := job.SegmentDuration
+	if segmentDuration <= 0 {
+		segmentDuration = DefaultRepackageSegmentDuration
+	}
+
+	result := &ProcessingResult{}
+	var codec models.Codec
+	var fragmentPaths []string
+	var inputs []cmafInput
+	for i, trackPath := range trackPaths {
+		if job.EnableDRM {
+			fragmentedPath := filepath.Join(packagingDir, fmt.Sprintf("fragmented_%d.mp4", i))
+			if err := refragmentTrack(ctx, trackPath, fragmentedPath, segmentDuration); err != nil {
+				return nil, failedAt(models.FailurePackage, fmt.Errorf("failed to fragment track %s: %w", tracks[i].Dir, err))
+			}
+			fragmentPaths = append(fragmentPaths, fragmentedPath)
+		}
+
+		// Audio tracks have no video stream to probe
+		info, err := GetVideoInfo(trackPath)
+		if err != nil {
+			inputs = append(inputs, cmafInput{Path: trackPath})
+			continue
+		}
+		if codec == "" {
+			if codec, err = probeVideoCodec(trackPath); err != nil {
+				p.logger.Warnf("Failed to probe codec of track %s: %v", tracks[i].Dir, err)
+			}
+		}
+		result.Duration = max(result.Duration, info.Duration)
+		result.Width = max(result.Width, info.Width)
+		result.Height = max(result.Height, info.Height)
+		bitrate := 0
+		if fileInfo, err := os.Stat(trackPath); err == nil && info.Duration > 0 {
+			bitrate = int(float64(fileInfo.Size()*8) / info.Duration / 1000)
+		}
+		quality := qualityFor(info.Width, info.Height, info.FrameRate)
+		inputs = append(inputs, cmafInput{Path: trackPath, Name: string(quality)})
+		result.Qualities = append(result.Qualities, models.InputQualityInfo{
+			Quality:    quality,
+			Resolution: fmt.Sprintf("%dx%d", info.Width, info.Height),
+			Bitrate:    bitrate,
+			FrameRate:  info.FrameRate,
+		})
+	}
+	if len(result.Qualities) == 0 {
+		return nil, failedAt(models.FailureProbe, fmt.Errorf("packaged output has no video track"))
+	}
+
+	// Nothing below is worth redoing if the worker is drained meanwhile
+	ctx = context.WithoutCancel(ctx)
+
+	if job.EnableDRM {
+		if err := p.acquireContentKey(ctx); err != nil {
+			return nil, failedAt(models.FailurePackage, fmt.Errorf("failed to acquire drm key: %w", err))
+		}
+		result.DRMKeyID = p.contentKey.KeyIDHex()
+	}
+
+	outputPath := filepath.Join(p.tempDir, "output")
+	opts := stitchAndPackageOptions{
+		segmentDuration: segmentDuration,
+		withHLS:         true,
+		withDASH:        true,
+		contentKey:      p.contentKey,
+		lowLatency:      lowLatency,
+	}
+	if job.EnableDRM {
+		err = p.packageVideo(fragmentPaths, outputPath, opts)
+	} else {
+		_, err = p.packageCMAF(inputs, outputPath, opts)
+	}
+	if err != nil {
+		return nil, failedAt(models.FailurePackage, fmt.Errorf("failed to package video: %w", err))
+	}
+	if err := setMasterFrameRates(filepath.Join(outputPath, "master.m3u8"), result.Qualities); err != nil {
+		p.logger.Warnf("Failed to set frame rates in master playlist: %v", err)
+	}
+	if err := setManifestCodecs(outputPath, codec); err != nil {
+		p.logger.Warnf("Failed to set codecs in manifests: %v", err)
+	}
+
+	subtitleFiles, err := p.downloadSubtitleFiles(ctx, baseKey, existing)
+	if err != nil {
+		p.logger.Warnf("Failed to download subtitles of %s: %v", videoID, err)
+	}
+	if err := p.packageSubtitles(outputPath, subtitleFiles, result.Duration); err != nil {
+		p.logger.Warnf("Failed to declare subtitles in manifests: %v", err)
+	}
+	result.SubtitleFiles = subtitleFiles
+	// The chapter files are rewritten since stale files at the top of the
+	// output are removed
+	if info, err := p.videoRepo.GetPlaybackInfo(ctx, videoID); err == nil && len(info.Chapters) > 0 {
+		if err := writeChapters(outputPath, info.Chapters); err != nil {
+			p.logger.Warnf("Failed to write chapters: %v", err)
+		}
+	}
+	if job.IntegrityManifest {
+		if err := writeIntegrityManifest(outputPath); err != nil {
+			return nil, failedAt(models.FailurePackage, err)
+		}
+	}
+	p.markStage(models.StagePackaged)
+
+	if err := p.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusProcessing, 60); err != nil {
+		p.logger.Errorf("Failed to update progress after packaging: %v", err)
+	}
+
+	p.reportStage(models.ProgressUploading, 0)
+	if err := p.uploadProcessedFiles(ctx, outputPath, outputKey); err != nil {
+		return nil, failedAt(models.FailureUpload, fmt.Errorf("upload failed: %w", err))
+	}
+	p.markStage(models.StageUploaded)
+	p.reportStage(models.ProgressUploading, 100)
+
+	written, err := listRelativeFiles(outputPath)
+	if err != nil {
+		p.logger.Warnf("Failed to list repackaged output, keeping stale files: %v", err)
+	} else {
+		p.removeStaleOutput(ctx, baseKey, existing, tracks, written)
+	}
+
+	for _, name := range existing {
+		switch {
+		case path.Dir(name) == "thumbnails":
+			result.Thumbnails = append(result.Thumbnails, name)
+		case name == WaveformFile:
+			result.Waveform = name
+		case path.Dir(name) == DownloadsDir && !job.EnableDRM:
+			if result.DownloadFiles == nil {
+				result.DownloadFiles = make(map[models.VideoQuality]string)
+			}
+			quality := models.VideoQuality(strings.TrimSuffix(path.Base(name), path.Ext(name)))
+			result.DownloadFiles[quality] = name
+		}
+	}
+	sort.Strings(result.Thumbnails)
+	job.LowLatencyHLS = lowLatency
+	result.UploadedBytes = p.uploadedBytes.Load()
+
+	if err := p.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusProcessing, 90); err != nil {
+		p.logger.Errorf("Failed to update progress after upload: %v", err)
+	}
+
+	return result, nil
+}
+
+// findPackagedTracks finds the track directories of packaged output among
+// names relative to the output root. Low-latency playlists point into the
+// tracks and are skipped.
+func findPackagedTracks(names []string) []packagedTrack {
+	type segment struct {
+		name  string
+		index int
+	}
+	inits := make(map[string]bool)
+	segments := make(map[string][]segment)
+	for _, name := range names {
+		if strings.HasPrefix(name, LLHLSDir+"/") {
+			continue
+		}
+		dir, file := path.Split(name)
+		dir = strings.TrimSuffix(dir, "/")
+		if file == packagedInitSegment {
+			inits[dir] = true
+			continue
+		}
+		if match := packagedSegmentRe.FindStringSubmatch(file); match != nil {
+			index, _ := strconv.Atoi(match[1])
+			segments[dir] = append(segments[dir], segment{name: name, index: index})
+		}
+	}
+
+	var tracks []packagedTrack
+	for dir := range inits {
+		if len(segments[dir]) == 0 {
+			continue
+		}
+		sort.Slice(segments[dir], func(i, j int) bool {
+			return segments[dir][i].index < segments[dir][j].index
+		})
+		track := packagedTrack{Dir: dir}
+		for _, seg := range segments[dir] {
+			track.Segments = append(track.Segments, seg.name)
+		}
+		tracks = append(tracks, track)
+	}
+	sort.Slice(tracks, func(i, j int) bool {
+		return tracks[i].Dir < tracks[j].Dir
+	})
+	return tracks
+}
+
+// rebuildTrack downloads a track's init segment and media segments in order
+// into one fragmented MP4. Encrypted tracks are refused, the packager would
+// encrypt their samples a second time.
+func (p *videoProcessor) rebuildTrack(ctx context.Context, baseKey string, track packagedTrack, trackPath string) error {
+	out, err := os.Create(trackPath)
+	if err != nil {
+		return fmt.Errorf("failed to create track file: %w", err)
+	}
+	defer out.Close()
+
+	initKey := path.Join(baseKey, track.Dir, packagedInitSegment)
+	initSegment, err := p.readObject(ctx, initKey)
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(initSegment, []byte("tenc")) {
+		return fmt.Errorf("track is encrypted and cannot be repackaged")
+	}
+	if _, err := out.Write(initSegment); err != nil {
+		return fmt.Errorf("failed to write init segment: %w", err)
+	}
+
+	for _, segment := range track.Segments {
+		object, err := p.awsRepo.GetObject(ctx, p.cfg.S3.OutputBucket, path.Join(baseKey, segment))
+		if err != nil {
+			return fmt.Errorf("failed to get segment %s: %w", segment, err)
+		}
+		_, err = io.Copy(out, object.Body)
+		object.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write segment %s: %w", segment, err)
+		}
+	}
+	return nil
+}
+
+func (p *videoProcessor) readObject(ctx context.Context, key string) ([]byte, error) {
+	object, err := p.awsRepo.GetObject(ctx, p.cfg.S3.OutputBucket, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", key, err)
+	}
+	defer object.Body.Close()
+
+	data, err := io.ReadAll(object.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// refragmentTrack cuts a track into fragments of segmentDuration seconds.
+// Fragments still start on keyframes, so video segments are only as close to
+// the target as the encode's GOP allows.
+func refragmentTrack(ctx context.Context, inputPath, outputPath string, segmentDuration int) error {
+	cmd := toolCommand(ctx, "mp4fragment",
+		"--timescale", "10000000",
+		"--fragment-duration", strconv.Itoa(segmentDuration*1000),
+		inputPath, outputPath)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("mp4fragment failed: %v, stderr: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// downloadSubtitleFiles fetches the WebVTT files uploaded next to the output
+// so they can be declared in the new manifests.
+func (p *videoProcessor) downloadSubtitleFiles(ctx context.Context, baseKey string, names []string) ([]string, error) {
+	subtitleDir := filepath.Join(p.tempDir, "subtitles")
+	if err := os.MkdirAll(subtitleDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create subtitle directory: %w", err)
+	}
+
+	var files []string
+	for _, name := range names {
+		if path.Dir(name) != "subtitles" || path.Ext(name) != ".vtt" {
+			continue
+		}
+		localPath := filepath.Join(subtitleDir, path.Base(name))
+		if err := p.downloadObject(ctx, p.cfg.S3.OutputBucket, path.Join(baseKey, name), localPath); err != nil {
+			return files, err
+		}
+		files = append(files, localPath)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// removeStaleOutput deletes what the previous packaging wrote and this one
+// did not: top-level manifests, the segments of track directories and the
+// low-latency playlists. MP4 downloads are removed too once the output is
+// DRM protected.
+func (p *videoProcessor) removeStaleOutput(ctx context.Context, baseKey string, existing []string, tracks []packagedTrack, written map[string]bool) {
+	trackDirs := make(map[string]bool, len(tracks))
+	for _, track := range tracks {
+		trackDirs[track.Dir] = true
+	}
+
+	for _, name := range existing {
+		if written[name] {
+			continue
+		}
+		dir := path.Dir(name)
+		// The waveform is of the audio, which a repackage leaves as it is
+		stale := (dir == "." && name != WaveformFile) || trackDirs[dir] || strings.HasPrefix(name, LLHLSDir+"/") || (p.job.EnableDRM && dir == DownloadsDir)
+		if !stale {
+			continue
+		}
+		if err := p.awsRepo.RemoveObject(ctx, p.cfg.S3.OutputBucket, path.Join(baseKey, name)); err != nil {
+			p.logger.Warnf("Failed to remove stale output %s: %v", name, err)
+		}
+	}
+}
+
+// listRelativeFiles returns the files under root as slash separated paths
+// relative to it.
+func listRelativeFiles(root string) (map[string]bool, error) {
+	files := make(map[string]bool)
+	err := filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = true
+		return nil
+	})
+	return files, err
+}
+
+func hasPrefix(names []string, prefix string) bool {
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
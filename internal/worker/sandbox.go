@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"context"
+	"os/exec"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/proclimit"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/sandbox"
+)
+
+// mediaSandbox confines ffmpeg and ffprobe, which parse untrusted uploads.
+// It is nil, running them directly, unless sandboxing is enabled.
+var mediaSandbox *sandbox.Sandbox
+
+// childLimits lowers the priority of the tools the worker runs. It is nil,
+// leaving them alone, unless limits are configured.
+var childLimits *proclimit.Limiter
+
+// setupSandbox configures mediaSandbox. Job directories live under workDir,
+// which is the only place the tools may write to.
+func setupSandbox(cfg config.SandboxConfig, workDir string) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	s, err := sandbox.New(sandbox.Options{
+		BwrapPath:      cfg.BwrapPath,
+		SeccompProfile: cfg.SeccompProfile,
+		WritableDirs:   []string{workDir},
+		Devices:        cfg.GPUDevices,
+	})
+	if err != nil {
+		return err
+	}
+	mediaSandbox = s
+	return nil
+}
+
+// setupChildLimits configures childLimits
+func setupChildLimits(cfg config.ChildLimitsConfig) error {
+	if cfg.Nice == 0 && cfg.IOClass == "" && cfg.Cgroup == "" {
+		return nil
+	}
+	l, err := proclimit.New(proclimit.Options{
+		Nice:     cfg.Nice,
+		IOClass:  cfg.IOClass,
+		IOLevel:  cfg.IOLevel,
+		Cgroup:   cfg.Cgroup,
+		CPUs:     cfg.CPUs,
+		MemoryMB: cfg.MemoryMB,
+	})
+	if err != nil {
+		return err
+	}
+	childLimits = l
+	return nil
+}
+
+func ffmpegCommand(args ...string) *exec.Cmd {
+	return childLimits.Apply(mediaSandbox.Command(context.Background(), toolPath("ffmpeg"), args...))
+}
+
+func ffmpegCommandContext(ctx context.Context, args ...string) *exec.Cmd {
+	return childLimits.Apply(mediaSandbox.Command(ctx, toolPath("ffmpeg"), args...))
+}
+
+func ffprobeCommand(args ...string) *exec.Cmd {
+	return childLimits.Apply(mediaSandbox.Command(context.Background(), toolPath("ffprobe"), args...))
+}
+
+// toolCommand runs a tool that only reads the worker's own outputs, such as
+// the packagers, outside the sandbox but within the child limits
+func toolCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return childLimits.Apply(exec.CommandContext(ctx, toolPath(name), args...))
+}
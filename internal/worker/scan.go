@@ -0,0 +1,203 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/malware"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/webhook"
+)
+
+const (
+	ScanBackendClamAV = "clamav"
+	ScanBackendHTTP   = "http"
+	// DefaultScanTimeout bounds a scan when Scan.Timeout is not set
+	DefaultScanTimeout = 10 * time.Minute
+	// DefaultQuarantinePrefix is where infected sources are moved in the
+	// input bucket when Scan.QuarantinePrefix is not set
+	DefaultQuarantinePrefix = "quarantine/"
+	// ScanAlertTimeout bounds a single malware alert request
+	ScanAlertTimeout = 10 * time.Second
+)
+
+// malwareError is the error of a source the scan found infected
+type malwareError struct {
+	Signature string
+}
+
+func (e *malwareError) Error() string {
+	return fmt.Sprintf("malware detected in source: %s", e.Signature)
+}
+
+// newScanner returns the scanner of cfg, nil when sources are not scanned
+func newScanner(cfg config.ScanConfig) (malware.Scanner, error) {
+	switch cfg.Backend {
+	case "":
+		return nil, nil
+	case ScanBackendClamAV:
+		if cfg.ClamAVAddr == "" {
+			return nil, errors.New("clamav address is not set")
+		}
+		return malware.NewClamAV(cfg.ClamAVAddr), nil
+	case ScanBackendHTTP:
+		if cfg.APIURL == "" {
+			return nil, errors.New("scanning api url is not set")
+		}
+		scanner := malware.NewHTTPScanner(cfg.APIURL, cfg.APIKey)
+		scanner.Client = outboundClient()
+		return scanner, nil
+	default:
+		return nil, fmt.Errorf("unknown scan backend %q", cfg.Backend)
+	}
+}
+
+// scanSource scans the downloaded source for malware before anything else
+// reads it. Infected sources fail with FailureMalware, which rejects their
+// video. Sources that could not be scanned fail with FailureScan, or are
+// encoded anyway when the scan fails open.
+func (p *videoProcessor) scanSource(ctx context.Context, sourcePath string) error {
+	scanner, err := newScanner(p.cfg.Scan)
+	if err != nil {
+		return failedAt(models.FailureScan, fmt.Errorf("failed to set up malware scan: %w", err))
+	}
+	if scanner == nil {
+		return nil
+	}
+	p.reportStage(models.ProgressScanning, 0)
+
+	timeout := DefaultScanTimeout
+	if p.cfg.Scan.Timeout > 0 {
+		timeout = time.Duration(p.cfg.Scan.Timeout) * time.Second
+	}
+	scanCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	startedAt := time.Now()
+	result, err := scanner.Scan(scanCtx, sourcePath)
+	if err != nil {
+		if ctx.Err() != nil {
+			return p.interrupt(ctx)
+		}
+		if p.cfg.Scan.FailOpen {
+			p.logger.Warnf("Failed to scan source of job %s, encoding it unscanned: %v", p.job.JobID, err)
+			return nil
+		}
+		return failedAt(models.FailureScan, fmt.Errorf("malware scan failed: %w", err))
+	}
+	if result.Infected {
+		p.logger.Warnf("Malware %q detected in source of job %s", result.Signature, p.job.JobID)
+		return failedAt(models.FailureMalware, &malwareError{Signature: result.Signature})
+	}
+
+	p.logger.Infof("Scanned source of job %s in %s, no malware found", p.job.JobID, time.Since(startedAt).Round(time.Millisecond))
+	p.reportStage(models.ProgressScanning, 100)
+	return nil
+}
+
+// rejectJob marks a job and its video as rejected because the source is
+// infected, moves the source to quarantine so it is not served or processed
+// again, and alerts the scan webhook.
+func (w *Worker) rejectJob(ctx context.Context, job *models.EncodeJob, videoID uuid.UUID, err error) {
+	log := w.jobLogger(job)
+	reason := models.FailureMalware
+
+	if updateErr := w.redisRepo.UpdateStatus(ctx, job.JobID, VideoJobsQueue, models.JobStatusRejected); updateErr != nil {
+		log.Errorf("Failed to update job status to rejected: %v", updateErr)
+	}
+	if updateErr := w.redisRepo.UpdateFailureReason(ctx, job.JobID, reason, err.Error()); updateErr != nil {
+		log.Errorf("Failed to record failure reason: %v", updateErr)
+	}
+	if updateErr := w.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusRejected, 0); updateErr != nil {
+		log.Errorf("Failed to update progress on rejection: %v", updateErr)
+	}
+	w.recordJobEvent(ctx, job, models.JobEventFailed, reason)
+	message := fmt.Sprintf("%s: %v", reason, err)
+	if updateErr := w.videoRepo.SetPlaybackError(ctx, videoID, filepath.Base(job.InputS3Key), message); updateErr != nil {
+		log.Errorf("Failed to record rejection in playback info: %v", updateErr)
+	}
+
+	quarantineKey, quarantineErr := w.quarantineSource(ctx, job)
+	if quarantineErr != nil {
+		log.Errorf("Failed to quarantine source %s: %v", job.InputS3Key, quarantineErr)
+	}
+
+	if w.scanAlertSigner == nil {
+		return
+	}
+	alert := &models.MalwareAlert{
+		Event:         models.MalwareDetected,
+		JobID:         job.JobID,
+		VideoID:       job.VideoID,
+		UserID:        job.UserID,
+		SourceKey:     job.InputS3Key,
+		QuarantineKey: quarantineKey,
+		DetectedAt:    time.Now().UTC(),
+	}
+	var infected *malwareError
+	if errors.As(err, &infected) {
+		alert.Signature = infected.Signature
+	}
+	if alertErr := w.postScanAlert(ctx, alert); alertErr != nil {
+		log.Errorf("Failed to send malware alert for job %s: %v", job.JobID, alertErr)
+	}
+}
+
+// quarantineSource moves the source of job under the quarantine prefix of the
+// input bucket and returns its new key. Remote sources were never stored.
+func (w *Worker) quarantineSource(ctx context.Context, job *models.EncodeJob) (string, error) {
+	if job.SourceURL != "" || job.InputS3Key == "" {
+		return "", nil
+	}
+	prefix := w.cfg.Scan.QuarantinePrefix
+	if prefix == "" {
+		prefix = DefaultQuarantinePrefix
+	}
+	key := prefix + job.InputS3Key
+	if err := w.awsRepo.CopyObject(ctx, w.cfg.S3.InputBucket, job.InputS3Key, key); err != nil {
+		return "", fmt.Errorf("failed to copy source to quarantine: %w", err)
+	}
+	if err := w.awsRepo.RemoveObject(ctx, w.cfg.S3.InputBucket, job.InputS3Key); err != nil {
+		return key, fmt.Errorf("failed to remove source: %w", err)
+	}
+	return key, nil
+}
+
+func (w *Worker) postScanAlert(ctx context.Context, alert *models.MalwareAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ScanAlertTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.Scan.AlertWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.SignatureHeader, w.scanAlertSigner.Sign(body, time.Now()))
+
+	resp, err := outboundClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
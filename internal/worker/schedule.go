@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+const (
+	// UserCapRetryInterval is how long a job whose owner is at their
+	// concurrency cap waits before it is queued again
+	UserCapRetryInterval = 15 * time.Second
+	// DeferredJobsInterval is how often due deferred jobs are queued again
+	DeferredJobsInterval = 5 * time.Second
+)
+
+// admitJob reports whether a dequeued job may start now, taking a slot of
+// its owner's concurrency cap if so. Otherwise it returns when the job should
+// be tried again: its scheduled start, or after DiskSpaceRetryInterval or
+// UserCapRetryInterval.
+func (w *Worker) admitJob(ctx context.Context, job *models.EncodeJob) (time.Time, bool) {
+	now := time.Now()
+	if job.NotBefore != nil && job.NotBefore.After(now) {
+		return *job.NotBefore, false
+	}
+	if !w.checkDiskSpace(ctx, job) {
+		return now.Add(DiskSpaceRetryInterval), false
+	}
+
+	limit := w.cfg.Worker.MaxJobsPerUser
+	if limit <= 0 || job.UserID == "" {
+		return now, true
+	}
+	acquired, err := w.redisRepo.AcquireUserJobSlot(ctx, job.UserID, job.JobID, limit)
+	if err != nil {
+		// Better to run over the cap than to hold jobs back while Redis is flaky
+		w.jobLogger(job).Warnf("Failed to check job cap of user %s: %v", job.UserID, err)
+		return now, true
+	}
+	if !acquired {
+		w.releaseDisk(job)
+		return now.Add(UserCapRetryInterval), false
+	}
+	return now, true
+}
+
+// deferJob holds a job that may not start yet back from the queue until
+// until, without it taking a worker slot meanwhile.
+func (w *Worker) deferJob(ctx context.Context, job *models.EncodeJob, until time.Time) {
+	log := w.jobLogger(job)
+	if err := w.redisRepo.DeferJob(ctx, w.jobQueue(ctx, job), job, until); err != nil {
+		log.Errorf("Failed to defer job %s: %v", job.JobID, err)
+		w.requeueJob(job)
+		return
+	}
+	log.Debugf("Deferred job %s until %s", job.JobID, until.Format(time.RFC3339))
+}
+
+// releaseUserSlot frees the slot a job held in its owner's concurrency cap.
+// Releasing a slot that was never taken is harmless.
+func (w *Worker) releaseUserSlot(job *models.EncodeJob) {
+	if w.cfg.Worker.MaxJobsPerUser <= 0 || job.UserID == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := w.redisRepo.ReleaseUserJobSlot(ctx, job.UserID, job.JobID); err != nil {
+		w.jobLogger(job).Warnf("Failed to release job slot of user %s: %v", job.UserID, err)
+	}
+}
+
+// promoteDeferredJobs queues deferred jobs again once they are due. Every
+// worker runs it; each job is only moved by one of them.
+func (w *Worker) promoteDeferredJobs(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(DeferredJobsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			promoted, err := w.redisRepo.PromoteDeferredJobs(ctx, time.Now())
+			if err != nil {
+				w.logger.Warnf("Failed to queue deferred jobs: %v", err)
+			}
+			if promoted > 0 {
+				w.logger.Infof("Queued %d deferred jobs", promoted)
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// EncodeDelayPerLine is how much earlier a segment must have started waiting
+// for a slot, per line of height, to go before the segment of a lower
+// rendition. A 1080p segment goes before a 360p one that started waiting
+// 72 seconds after it, so lower renditions finish first without starving the
+// higher ones of a busy worker.
+const EncodeDelayPerLine = 100 * time.Millisecond
+
+// encodeScheduler hands out the encoder slots of the worker to the segments
+// of every quality of every job it runs. Waiting segments are served lowest
+// rendition first, and in the order they started waiting otherwise. Its limit
+// is adjusted while jobs run, so encodes already started finish but new
+// segments wait until the budget allows them.
+type encodeScheduler struct {
+	mu      sync.Mutex
+	limit   int
+	active  int
+	waiting encodeQueue
+}
+
+// encodeWaiter is a segment waiting for a slot. Its slot is granted by
+// closing ready.
+type encodeWaiter struct {
+	due     time.Time
+	ready   chan struct{}
+	granted bool
+	index   int
+}
+
+func newEncodeScheduler(limit int) *encodeScheduler {
+	return &encodeScheduler{limit: limit}
+}
+
+// Acquire blocks until the scheduler grants a slot to an encode of a
+// rendition height lines tall, or ctx is done.
+func (s *encodeScheduler) Acquire(ctx context.Context, height int) error {
+	s.mu.Lock()
+	if s.active < s.limit && len(s.waiting) == 0 {
+		s.active++
+		s.mu.Unlock()
+		return nil
+	}
+	waiter := &encodeWaiter{
+		due:   time.Now().Add(time.Duration(height) * EncodeDelayPerLine),
+		ready: make(chan struct{}),
+	}
+	heap.Push(&s.waiting, waiter)
+	s.mu.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if waiter.granted {
+			// The slot was granted as ctx was done; hand it on
+			s.active--
+			s.dispatch()
+		} else {
+			heap.Remove(&s.waiting, waiter.index)
+		}
+		return ctx.Err()
+	}
+}
+
+func (s *encodeScheduler) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+	s.dispatch()
+}
+
+func (s *encodeScheduler) SetLimit(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit = limit
+	s.dispatch()
+}
+
+func (s *encodeScheduler) Limit() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.limit
+}
+
+// Load returns the encodes running and waiting for a slot
+func (s *encodeScheduler) Load() (active, waiting int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active, len(s.waiting)
+}
+
+// dispatch grants free slots to the waiters due first. Callers must hold mu.
+func (s *encodeScheduler) dispatch() {
+	for s.active < s.limit && len(s.waiting) > 0 {
+		waiter := heap.Pop(&s.waiting).(*encodeWaiter)
+		waiter.granted = true
+		s.active++
+		close(waiter.ready)
+	}
+}
+
+// encodeQueue orders waiters by when they are due, as a container/heap
+type encodeQueue []*encodeWaiter
+
+func (q encodeQueue) Len() int           { return len(q) }
+func (q encodeQueue) Less(i, j int) bool { return q[i].due.Before(q[j].due) }
+func (q encodeQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *encodeQueue) Push(x any) {
+	waiter := x.(*encodeWaiter)
+	waiter.index = len(*q)
+	*q = append(*q, waiter)
+}
+
+func (q *encodeQueue) Pop() any {
+	old := *q
+	n := len(old)
+	waiter := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return waiter
+}
@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
+)
+
+// preflight probes the source, stores what ffprobe reports about it so users
+// can inspect what they uploaded, and rejects sources that cannot be encoded
+// before any encode capacity is spent on them.
+func (p *videoProcessor) preflight(ctx context.Context, videoID uuid.UUID, sourcePath string) error {
+	probe, err := probeSource(sourcePath)
+	if err != nil {
+		return failedAt(models.FailureProbe, fmt.Errorf("failed to probe source: %w", err))
+	}
+	if err := p.videoRepo.SetSourceMetadata(ctx, videoID, probe); err != nil {
+		p.logger.Warnf("Failed to record source metadata of job %s: %v", p.job.JobID, err)
+	}
+
+	report, err := models.NewSourceReport(videoID.String(), probe)
+	if err != nil {
+		return failedAt(models.FailureProbe, err)
+	}
+	if issues := models.ValidateSource(report); len(issues) > 0 {
+		return failedAt(models.FailureInvalidSource, models.SourceIssuesError(issues))
+	}
+	return nil
+}
+
+// probeSource returns ffprobe's report of the format and every stream of
+// path as JSON.
+func probeSource(path string) ([]byte, error) {
+	cmd := ffprobeCommand("-v", "error", "-show_format", "-show_streams", "-of", "json", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe error: %v, stderr: %s", err, stderr.String())
+	}
+	return models.StripProbeFilename(output)
+}
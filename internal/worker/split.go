@@ -0,0 +1,163 @@
+package worker
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// intermediateCRF keeps sources the worker re-encodes before the ladder,
+	// such as joined bumpers, close to lossless since they are encoded again
+	intermediateCRF = 16
+	// maxKeyframeShift is how far, as a fraction of the segment duration, a
+	// cut may move to the nearest keyframe before keyframes are forced
+	maxKeyframeShift = 0.5
+)
+
+// segmentPlan is where a source is split: cuts are the start times of every
+// segment after the first, each on a keyframe of the file at path.
+type segmentPlan struct {
+	path string
+	cuts []float64
+}
+
+// planSegments works out where to split inputPath into segments of about
+// segmentDuration. Stream copying can only cut on keyframes, so each cut is
+// moved to the nearest one; a split on fixed times lands wherever the next
+// keyframe happens to be and differs from what the checkpoint and the other
+// renditions expect. When the source's keyframes are too sparse for that, it
+// is first re-encoded with keyframes forced at the cuts.
+func (p *videoProcessor) planSegments(inputPath string, videoInfo *VideoInfo, segmentDuration float64) (*segmentPlan, error) {
+	targets := segmentTargets(videoInfo.Duration, segmentDuration)
+	if len(targets) == 0 {
+		return &segmentPlan{path: inputPath}, nil
+	}
+
+	keyframes, err := probeKeyframes(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe keyframes: %w", err)
+	}
+	cuts, aligned := alignCuts(targets, keyframes, segmentDuration*maxKeyframeShift)
+	if aligned {
+		return &segmentPlan{path: inputPath, cuts: cuts}, nil
+	}
+	if videoInfo.HDR() != HDRNone {
+		// The intermediate encode would drop the source's HDR signalling
+		p.logger.Warnf("Keyframes of HDR source of job %s are sparse, cutting on the nearest ones", p.job.JobID)
+		return &segmentPlan{path: inputPath, cuts: cuts}, nil
+	}
+
+	p.logger.Infof("Keyframes of job %s are sparse, forcing them at %d cuts", p.job.JobID, len(targets))
+	keyframedPath := filepath.Join(p.tempDir, "keyframed.mp4")
+	if err := forceKeyframes(inputPath, keyframedPath, targets); err != nil {
+		return nil, err
+	}
+	if keyframes, err = probeKeyframes(keyframedPath); err != nil {
+		return nil, fmt.Errorf("failed to probe keyframes: %w", err)
+	}
+	cuts, _ = alignCuts(targets, keyframes, segmentDuration*maxKeyframeShift)
+	return &segmentPlan{path: keyframedPath, cuts: cuts}, nil
+}
+
+// segmentTargets are the ideal start times of every segment after the first
+func segmentTargets(duration, segmentDuration float64) []float64 {
+	if segmentDuration <= 0 {
+		return nil
+	}
+	var targets []float64
+	for t := segmentDuration; t < duration; t += segmentDuration {
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+// alignCuts moves every target to the nearest keyframe after the previous
+// cut. It reports false when a target is further than maxShift from one.
+func alignCuts(targets, keyframes []float64, maxShift float64) ([]float64, bool) {
+	aligned := true
+	var cuts []float64
+	prev := 0.0
+	for _, target := range targets {
+		i := sort.SearchFloat64s(keyframes, target)
+		best := -1.0
+		for _, j := range []int{i - 1, i} {
+			if j < 0 || j >= len(keyframes) || keyframes[j] <= prev {
+				continue
+			}
+			if best < 0 || math.Abs(keyframes[j]-target) < math.Abs(best-target) {
+				best = keyframes[j]
+			}
+		}
+		if best < 0 || math.Abs(best-target) > maxShift {
+			aligned = false
+		}
+		if best < 0 {
+			continue
+		}
+		cuts = append(cuts, best)
+		prev = best
+	}
+	return cuts, aligned
+}
+
+// probeKeyframes returns the sorted presentation times of the keyframes of
+// the first video stream, read from packet flags without decoding.
+func probeKeyframes(path string) ([]float64, error) {
+	cmd := ffprobeCommand("-v", "error", "-select_streams", "v:0",
+		"-show_entries", "packet=pts_time,flags", "-of", "csv=p=0", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe error: %v, stderr: %s", err, stderr.String())
+	}
+
+	var keyframes []float64
+	for _, line := range strings.Split(string(output), "\n") {
+		pts, flags, ok := strings.Cut(strings.TrimSpace(line), ",")
+		if !ok || !strings.HasPrefix(flags, "K") {
+			continue
+		}
+		if t, err := strconv.ParseFloat(pts, 64); err == nil {
+			keyframes = append(keyframes, t)
+		}
+	}
+	sort.Float64s(keyframes)
+	return keyframes, nil
+}
+
+// forceKeyframes re-encodes the video of inputPath into outputPath with a
+// keyframe at each of times, copying the other streams.
+func forceKeyframes(inputPath, outputPath string, times []float64) error {
+	cmd := ffmpegCommand("-y", "-hide_banner", "-loglevel", "error",
+		"-i", inputPath,
+		"-map", "0:v:0", "-map", "0:a?",
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", strconv.Itoa(intermediateCRF),
+		"-force_key_frames", formatTimes(times),
+		"-c:a", "copy",
+		"-movflags", "+faststart",
+		outputPath,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to force keyframes: %v, stderr: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// formatTimes lists times in seconds the way ffmpeg options take them
+func formatTimes(times []float64) string {
+	formatted := make([]string, len(times))
+	for i, t := range times {
+		formatted[i] = strconv.FormatFloat(t, 'f', 6, 64)
+	}
+	return strings.Join(formatted, ",")
+}
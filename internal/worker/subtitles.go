@@ -0,0 +1,288 @@
+package worker
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	SubtitleSegmentDuration = 6
+	SubtitleGroupID         = "subs"
+	SubtitlePlaylistName    = "index.m3u8"
+	SubtitleSideFileName    = "subtitles.vtt"
+)
+
+// subtitleFileRe matches the names extractSubtitles gives WebVTT tracks,
+// e.g. subtitle_0_eng.vtt.
+var subtitleFileRe = regexp.MustCompile(`^subtitle_(\d+)_([A-Za-z-]+)\.vtt$`)
+
+// subtitleTrack is a WebVTT file to declare in the manifests.
+type subtitleTrack struct {
+	Path     string
+	Language string
+	Name     string
+	// Dir is the track's directory relative to the output root
+	Dir string
+}
+
+// vttCue is a single cue of a WebVTT file, kept as raw text apart from its
+// timing so it can be written back unchanged.
+type vttCue struct {
+	Start float64
+	End   float64
+	Text  string
+}
+
+// packageSubtitles segments every subtitle track into a WebVTT rendition and
+// declares it in the HLS master playlist and the DASH manifest produced by
+// packaging. Players ignore side-loaded files, so without this step the
+// subtitles would never be shown.
+func (p *videoProcessor) packageSubtitles(outputPath string, subtitleFiles []string, duration float64) error {
+	if len(subtitleFiles) == 0 || duration <= 0 {
+		return nil
+	}
+
+	var tracks []subtitleTrack
+	for i, subtitleFile := range subtitleFiles {
+		track := newSubtitleTrack(subtitleFile, i)
+		if err := p.writeSubtitleRendition(outputPath, track, duration); err != nil {
+			p.logger.Warnf("Skipping subtitle track %s: %v", subtitleFile, err)
+			continue
+		}
+		tracks = append(tracks, track)
+	}
+
+	if len(tracks) == 0 {
+		return nil
+	}
+
+	if err := addSubtitlesToMasterPlaylist(filepath.Join(outputPath, "master.m3u8"), tracks); err != nil {
+		return fmt.Errorf("failed to add subtitles to HLS master playlist: %w", err)
+	}
+
+	mpdPath := filepath.Join(outputPath, "stream.mpd")
+	if _, err := os.Stat(mpdPath); err == nil {
+		if err := addSubtitlesToMPD(mpdPath, tracks); err != nil {
+			return fmt.Errorf("failed to add subtitles to DASH manifest: %w", err)
+		}
+	}
+
+	p.logger.Infof("Declared %d subtitle tracks in manifests", len(tracks))
+	return nil
+}
+
+func newSubtitleTrack(path string, index int) subtitleTrack {
+	language := "und"
+	if match := subtitleFileRe.FindStringSubmatch(filepath.Base(path)); match != nil {
+		language = match[2]
+	}
+
+	name := strings.ToUpper(language)
+	if language == "und" {
+		name = fmt.Sprintf("Subtitles %d", index+1)
+	}
+
+	return subtitleTrack{
+		Path:     path,
+		Language: language,
+		Name:     name,
+		Dir:      fmt.Sprintf("subtitles/%s_%d", language, index),
+	}
+}
+
+// writeSubtitleRendition splits a WebVTT file into fixed length segments and
+// writes them with their media playlist. The full file is kept next to them
+// for DASH, where players load text tracks as a single file.
+func (p *videoProcessor) writeSubtitleRendition(outputPath string, track subtitleTrack, duration float64) error {
+	data, err := os.ReadFile(track.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read subtitle file: %w", err)
+	}
+
+	cues, err := parseVTT(string(data))
+	if err != nil {
+		return err
+	}
+
+	trackDir := filepath.Join(outputPath, filepath.FromSlash(track.Dir))
+	if err := os.MkdirAll(trackDir, 0755); err != nil {
+		return fmt.Errorf("failed to create subtitle directory: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(trackDir, SubtitleSideFileName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write subtitle file: %w", err)
+	}
+
+	segmentCount := int(math.Ceil(duration / SubtitleSegmentDuration))
+	var playlist strings.Builder
+	playlist.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	fmt.Fprintf(&playlist, "#EXT-X-TARGETDURATION:%d\n", SubtitleSegmentDuration)
+	playlist.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n")
+
+	for i := 0; i < segmentCount; i++ {
+		start := float64(i * SubtitleSegmentDuration)
+		end := math.Min(start+SubtitleSegmentDuration, duration)
+
+		var segment strings.Builder
+		segment.WriteString("WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:0,LOCAL:00:00:00.000\n\n")
+		for _, cue := range cues {
+			if cue.End > start && cue.Start < end {
+				segment.WriteString(cue.Text)
+				segment.WriteString("\n\n")
+			}
+		}
+
+		segmentName := fmt.Sprintf("segment_%03d.vtt", i)
+		if err := os.WriteFile(filepath.Join(trackDir, segmentName), []byte(segment.String()), 0644); err != nil {
+			return fmt.Errorf("failed to write subtitle segment: %w", err)
+		}
+
+		fmt.Fprintf(&playlist, "#EXTINF:%.3f,\n%s\n", end-start, segmentName)
+	}
+	playlist.WriteString("#EXT-X-ENDLIST\n")
+
+	if err := os.WriteFile(filepath.Join(trackDir, SubtitlePlaylistName), []byte(playlist.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write subtitle playlist: %w", err)
+	}
+
+	return nil
+}
+
+// parseVTT returns the cues of a WebVTT document. NOTE, STYLE and REGION
+// blocks are dropped.
+func parseVTT(content string) ([]vttCue, error) {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if !strings.HasPrefix(strings.TrimPrefix(content, "\ufeff"), "WEBVTT") {
+		return nil, fmt.Errorf("not a WebVTT file")
+	}
+
+	var cues []vttCue
+	for _, block := range strings.Split(content, "\n\n") {
+		block = strings.Trim(block, "\n")
+		lines := strings.Split(block, "\n")
+
+		timingLine := -1
+		for i, line := range lines {
+			if strings.Contains(line, "-->") {
+				timingLine = i
+				break
+			}
+		}
+		if timingLine == -1 {
+			continue
+		}
+
+		fields := strings.Fields(lines[timingLine])
+		if len(fields) < 3 {
+			continue
+		}
+		start, err := parseVTTTimestamp(fields[0])
+		if err != nil {
+			continue
+		}
+		end, err := parseVTTTimestamp(fields[2])
+		if err != nil {
+			continue
+		}
+
+		cues = append(cues, vttCue{Start: start, End: end, Text: block})
+	}
+
+	return cues, nil
+}
+
+// parseVTTTimestamp parses hh:mm:ss.ttt or mm:ss.ttt into seconds.
+func parseVTTTimestamp(value string) (float64, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", value)
+	}
+
+	var seconds float64
+	for _, part := range parts[:len(parts)-1] {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp %q", value)
+		}
+		seconds = seconds*60 + float64(n)
+	}
+
+	last, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q", value)
+	}
+
+	return seconds*60 + last, nil
+}
+
+// addSubtitlesToMasterPlaylist declares the subtitle renditions and attaches
+// the subtitle group to every variant stream.
+func addSubtitlesToMasterPlaylist(path string, tracks []subtitleTrack) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var media strings.Builder
+	for _, track := range tracks {
+		fmt.Fprintf(&media, "#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"%s\",NAME=\"%s\",", SubtitleGroupID, track.Name)
+		if track.Language != "und" {
+			fmt.Fprintf(&media, "LANGUAGE=\"%s\",", track.Language)
+		}
+		fmt.Fprintf(&media, "DEFAULT=NO,AUTOSELECT=YES,URI=\"%s/%s\"\n", track.Dir, SubtitlePlaylistName)
+	}
+
+	var out strings.Builder
+	inserted := false
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+			if !inserted {
+				out.WriteString(media.String())
+				inserted = true
+			}
+			line += fmt.Sprintf(",SUBTITLES=\"%s\"", SubtitleGroupID)
+		}
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+
+	if !inserted {
+		return fmt.Errorf("no variant streams found in %s", path)
+	}
+
+	return os.WriteFile(path, []byte(out.String()), 0644)
+}
+
+// addSubtitlesToMPD adds a text AdaptationSet per track to the last Period of
+// the DASH manifest.
+func addSubtitlesToMPD(path string, tracks []subtitleTrack) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	manifest := string(data)
+	idx := strings.LastIndex(manifest, "</Period>")
+	if idx == -1 {
+		return fmt.Errorf("no Period found in %s", path)
+	}
+
+	var sets strings.Builder
+	for i, track := range tracks {
+		fmt.Fprintf(&sets, "    <AdaptationSet contentType=\"text\" mimeType=\"text/vtt\" lang=\"%s\">\n", track.Language)
+		sets.WriteString("      <Role schemeIdUri=\"urn:mpeg:dash:role:2011\" value=\"subtitle\"/>\n")
+		fmt.Fprintf(&sets, "      <Representation id=\"subtitles_%d\" bandwidth=\"256\">\n", i)
+		fmt.Fprintf(&sets, "        <BaseURL>%s/%s</BaseURL>\n", track.Dir, SubtitleSideFileName)
+		sets.WriteString("      </Representation>\n")
+		sets.WriteString("    </AdaptationSet>\n")
+	}
+
+	lineStart := strings.LastIndex(manifest[:idx], "\n") + 1
+	manifest = manifest[:lineStart] + sets.String() + manifest[lineStart:]
+	return os.WriteFile(path, []byte(manifest), 0644)
+}
@@ -0,0 +1,143 @@
+package worker
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+const (
+	ThumbnailWidth  = 1280
+	ThumbnailHeight = 720
+	// DefaultThumbnailCount is used by the even and smart modes when the
+	// policy does not set a count
+	DefaultThumbnailCount = 5
+)
+
+// thumbnailStill is a still to extract. Smart stills are picked by ffmpeg
+// from the Window seconds starting at Timestamp.
+type thumbnailStill struct {
+	Timestamp float64
+	Window    float64
+}
+
+// generateThumbnails extracts the stills requested by policy and returns
+// their paths in order. Stills that fail are skipped; an error is returned
+// only when none could be generated.
+func (p *videoProcessor) generateThumbnails(inputPath string, duration float64, policy *models.ThumbnailPolicy) ([]string, error) {
+	thumbnailDir := filepath.Join(p.tempDir, "thumbnails")
+	if err := os.MkdirAll(thumbnailDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create thumbnail directory: %w", err)
+	}
+
+	stills := thumbnailStills(duration, policy)
+	smart := policy != nil && policy.Mode == models.ThumbnailModeSmart
+
+	var paths []string
+	var lastErr error
+	for i, still := range stills {
+		outputPath := filepath.Join(thumbnailDir, fmt.Sprintf("thumbnail_%02d.jpg", i))
+		if err := extractStill(inputPath, outputPath, still, smart); err != nil {
+			p.logger.Warnf("Failed to generate thumbnail at %.2fs: %v", still.Timestamp, err)
+			lastErr = err
+			continue
+		}
+		paths = append(paths, outputPath)
+	}
+
+	if len(paths) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no thumbnails requested")
+		}
+		return nil, lastErr
+	}
+
+	p.logger.Infof("Generated %d of %d thumbnails", len(paths), len(stills))
+	return paths, nil
+}
+
+// thumbnailStills works out where to take stills from. Without a policy a
+// single still is taken 10% into the video, as before policies existed.
+func thumbnailStills(duration float64, policy *models.ThumbnailPolicy) []thumbnailStill {
+	if policy == nil {
+		timestamp := duration * 0.1
+		if timestamp < 1.0 {
+			timestamp = 1.0
+		}
+		return []thumbnailStill{{Timestamp: timestamp}}
+	}
+
+	count := policy.Count
+	if count <= 0 {
+		count = DefaultThumbnailCount
+	}
+	if count > models.MaxThumbnails {
+		count = models.MaxThumbnails
+	}
+
+	var stills []thumbnailStill
+	switch policy.Mode {
+	case models.ThumbnailModeTimestamps:
+		for _, timestamp := range policy.Timestamps {
+			if timestamp < duration && len(stills) < models.MaxThumbnails {
+				stills = append(stills, thumbnailStill{Timestamp: timestamp})
+			}
+		}
+	case models.ThumbnailModeSmart:
+		window := duration / float64(count)
+		for i := 0; i < count; i++ {
+			stills = append(stills, thumbnailStill{Timestamp: float64(i) * window, Window: window})
+		}
+	default:
+		for i := 0; i < count; i++ {
+			stills = append(stills, thumbnailStill{Timestamp: duration * float64(i+1) / float64(count+1)})
+		}
+	}
+
+	if len(stills) == 0 {
+		return thumbnailStills(duration, nil)
+	}
+	return stills
+}
+
+func extractStill(inputPath, outputPath string, still thumbnailStill, smart bool) error {
+	filter := fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2",
+		ThumbnailWidth, ThumbnailHeight, ThumbnailWidth, ThumbnailHeight)
+
+	args := []string{
+		"-y",
+		"-hide_banner",
+		"-loglevel", "error",
+		"-ss", fmt.Sprintf("%.2f", still.Timestamp),
+	}
+	if smart && still.Window > 0 {
+		args = append(args, "-t", fmt.Sprintf("%.2f", still.Window))
+		// The thumbnail filter picks the most representative frame of each
+		// batch it sees, skipping black and transition frames
+		filter = "thumbnail," + filter
+	}
+	args = append(args,
+		"-i", inputPath,
+		"-vframes", "1",
+		"-q:v", "2",
+		"-vf", filter,
+		outputPath,
+	)
+
+	cmd := ffmpegCommand(args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("thumbnail generation failed: %v, stderr: %s", err, stderr.String())
+	}
+
+	if stat, err := os.Stat(outputPath); err != nil || stat.Size() == 0 {
+		return fmt.Errorf("thumbnail generation produced invalid output file")
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
+)
+
+const (
+	// DefaultMinFFmpegVersion is the oldest ffmpeg the pipeline is tested
+	// with, the first release with the av1 and vp9 hardware encoders it uses
+	DefaultMinFFmpegVersion = "5.1"
+	// DefaultMinBento4Version is the oldest Bento4 whose mp4dash writes the
+	// HLS and DASH manifests the players expect
+	DefaultMinBento4Version = "1.6.0"
+)
+
+// requiredFilters are the ffmpeg filters every job uses. Filters of optional
+// features, such as tone mapping, are checked where they are used.
+var requiredFilters = []string{"scale", "setsar", "fps", "format", "aresample", "anullsrc"}
+
+// toolPaths are the resolved paths of the media tools, set at startup.
+// Tools that are not in it run from PATH.
+var toolPaths = map[string]string{}
+
+// toolPath returns the binary of a media tool
+func toolPath(name string) string {
+	if path, ok := toolPaths[name]; ok {
+		return path
+	}
+	return name
+}
+
+var versionPattern = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// setupTools resolves the media tools, checks their versions and that ffmpeg
+// was built with what the pipeline needs, so a worker missing any of them
+// fails at startup rather than halfway through a job.
+func setupTools(cfg config.ToolsConfig, log logger.Logger) error {
+	minFFmpeg := cfg.MinFFmpegVersion
+	if minFFmpeg == "" {
+		minFFmpeg = DefaultMinFFmpegVersion
+	}
+	minBento4 := cfg.MinBento4Version
+	if minBento4 == "" {
+		minBento4 = DefaultMinBento4Version
+	}
+
+	tools := []struct {
+		name, path, minVersion string
+		versionArgs            []string
+	}{
+		{"ffmpeg", cfg.FFmpegPath, minFFmpeg, []string{"-hide_banner", "-version"}},
+		{"ffprobe", cfg.FFprobePath, minFFmpeg, []string{"-hide_banner", "-version"}},
+		// mp4fragment prints its version in the usage banner
+		{"mp4fragment", cfg.MP4FragmentPath, minBento4, nil},
+		{"mp4dash", cfg.MP4DashPath, minBento4, []string{"--version"}},
+	}
+	resolved := make(map[string]string, len(tools))
+	for _, tool := range tools {
+		path := tool.path
+		if path == "" {
+			path = tool.name
+		}
+		path, err := exec.LookPath(path)
+		if err != nil {
+			return fmt.Errorf("%s is not installed: %w", tool.name, err)
+		}
+		resolved[tool.name] = path
+
+		banner := firstLine(exec.Command(path, tool.versionArgs...))
+		version, ok := parseToolVersion(banner)
+		if !ok {
+			// Builds from git report a revision instead of a release
+			log.Warnf("Cannot tell the version of %s from %q, assuming it is recent enough", path, banner)
+			continue
+		}
+		if compareVersions(version, tool.minVersion) < 0 {
+			return fmt.Errorf("%s %s is older than the required %s", tool.name, version, tool.minVersion)
+		}
+		log.Infof("Using %s %s at %s", tool.name, version, path)
+	}
+	toolPaths = resolved
+
+	filters := ffmpegFilters()
+	var missing []string
+	for _, filter := range requiredFilters {
+		if !filters[filter] {
+			missing = append(missing, filter)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("ffmpeg was built without the %s filters", strings.Join(missing, ", "))
+	}
+
+	codecs, hwAccels := encodableCodecs()
+	if len(codecs) == 0 {
+		return errors.New("ffmpeg cannot encode any supported codec")
+	}
+	log.Infof("ffmpeg can encode %v, on hardware with %v", codecs, hwAccels)
+	return nil
+}
+
+// parseToolVersion finds the release in a version banner such as
+// "ffmpeg version 6.1.1-3ubuntu5" or "MP4 Fragmenter - Version 1.6.0"
+func parseToolVersion(banner string) (string, bool) {
+	if strings.HasPrefix(banner, "ffmpeg version N-") || strings.HasPrefix(banner, "ffprobe version N-") {
+		return "", false
+	}
+	version := versionPattern.FindString(banner)
+	return version, version != ""
+}
+
+// compareVersions compares dotted versions numerically, treating missing
+// parts as zero
+func compareVersions(a, b string) int {
+	partsA := strings.Split(a, ".")
+	partsB := strings.Split(b, ".")
+	for i := 0; i < max(len(partsA), len(partsB)); i++ {
+		var x, y int
+		if i < len(partsA) {
+			x, _ = strconv.Atoi(partsA[i])
+		}
+		if i < len(partsB) {
+			y, _ = strconv.Atoi(partsB[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+var (
+	ffmpegFiltersOnce sync.Once
+	ffmpegFilterSet   map[string]bool
+)
+
+// ffmpegFilters returns the names of the filters ffmpeg was built with
+func ffmpegFilters() map[string]bool {
+	ffmpegFiltersOnce.Do(func() {
+		ffmpegFilterSet = map[string]bool{}
+		output, err := ffmpegCommand("-hide_banner", "-filters").Output()
+		if err != nil {
+			return
+		}
+		// Filters are listed as flags, name and pads such as "V->V"; the
+		// legend before them has no pads
+		for _, line := range strings.Split(string(output), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 3 && strings.Contains(fields[2], "->") {
+				ffmpegFilterSet[fields[1]] = true
+			}
+		}
+	})
+	return ffmpegFilterSet
+}
+
+// encodableCodecs probes every registered encoder and returns the codecs
+// ffmpeg can encode and the hardware any of them can be encoded on
+func encodableCodecs() ([]models.Codec, []HardwareAccelType) {
+	encoderRegistryMu.RLock()
+	names := make([]models.Codec, 0, len(encoderRegistry))
+	for name := range encoderRegistry {
+		names = append(names, name)
+	}
+	encoderRegistryMu.RUnlock()
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
+	var codecs []models.Codec
+	var hwAccels []HardwareAccelType
+	seen := map[HardwareAccelType]bool{}
+	for _, name := range names {
+		_, caps, err := lookupEncoder(name)
+		if err != nil {
+			continue
+		}
+		codecs = append(codecs, name)
+		for _, hwAccel := range caps.HWAccels {
+			if !seen[hwAccel] {
+				seen[hwAccel] = true
+				hwAccels = append(hwAccels, hwAccel)
+			}
+		}
+	}
+	return codecs, hwAccels
+}
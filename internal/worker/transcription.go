@@ -0,0 +1,179 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultWhisperBinary        = "whisper-cli"
+	DefaultTranscriptionTimeout = 30 * time.Minute
+	// AutoLanguage asks the speech model to detect the spoken language
+	AutoLanguage = "auto"
+)
+
+// detectedLanguageRe matches the language whisper.cpp reports when it was
+// asked to detect it, e.g. "auto-detected language: en (p = 0.97)".
+var detectedLanguageRe = regexp.MustCompile(`auto-detected language: ([A-Za-z-]+)`)
+
+// transcribe generates WebVTT captions from the audio of the video. It uses
+// the configured speech-to-text service when there is one and runs whisper.cpp
+// locally otherwise. The captions are named like extracted subtitles, with
+// index following them, so they are packaged and published the same way.
+func (p *videoProcessor) transcribe(ctx context.Context, inputPath string, index int) (string, error) {
+	subtitleDir := filepath.Join(p.tempDir, "subtitles")
+	if err := os.MkdirAll(subtitleDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create subtitle directory: %w", err)
+	}
+
+	timeout := DefaultTranscriptionTimeout
+	if p.cfg.Captions.Timeout > 0 {
+		timeout = time.Duration(p.cfg.Captions.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Speech models expect 16kHz mono PCM
+	audioPath := filepath.Join(p.tempDir, "transcription_audio.wav")
+	extractCmd := ffmpegCommandContext(ctx, "-y", "-hide_banner", "-loglevel", "error",
+		"-i", inputPath, "-vn", "-ac", "1", "-ar", "16000", "-c:a", "pcm_s16le", audioPath)
+	var stderr bytes.Buffer
+	extractCmd.Stderr = &stderr
+	if err := extractCmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to extract audio: %v, stderr: %s", err, stderr.String())
+	}
+	defer os.Remove(audioPath)
+
+	language := p.job.CaptionLanguage
+	if language == "" {
+		language = AutoLanguage
+	}
+
+	var vtt []byte
+	var err error
+	if p.cfg.Captions.ServiceURL != "" {
+		vtt, err = p.transcribeWithService(ctx, audioPath, language)
+	} else {
+		vtt, language, err = p.transcribeWithWhisper(ctx, audioPath, language)
+	}
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(strings.TrimPrefix(string(vtt), "\ufeff"), "WEBVTT") {
+		return "", fmt.Errorf("transcription did not produce WebVTT")
+	}
+
+	if language == AutoLanguage {
+		language = "und"
+	}
+	captionPath := filepath.Join(subtitleDir, fmt.Sprintf("subtitle_%d_%s.vtt", index, language))
+	if err := os.WriteFile(captionPath, vtt, 0644); err != nil {
+		return "", fmt.Errorf("failed to write captions: %w", err)
+	}
+
+	p.logger.Infof("Generated %s captions for job %s", language, p.job.JobID)
+	return captionPath, nil
+}
+
+// transcribeWithWhisper runs whisper.cpp and returns the captions along with
+// the language it detected when none was requested.
+func (p *videoProcessor) transcribeWithWhisper(ctx context.Context, audioPath, language string) ([]byte, string, error) {
+	if p.cfg.Captions.WhisperModel == "" {
+		return nil, "", fmt.Errorf("no whisper model configured")
+	}
+	binary := p.cfg.Captions.WhisperBinary
+	if binary == "" {
+		binary = DefaultWhisperBinary
+	}
+
+	outputBase := filepath.Join(p.tempDir, "transcription")
+	cmd := toolCommand(ctx, binary,
+		"-m", p.cfg.Captions.WhisperModel,
+		"-f", audioPath,
+		"-l", language,
+		"-ovtt",
+		"-of", outputBase,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, "", fmt.Errorf("whisper failed: %v, stderr: %s", err, stderr.String())
+	}
+
+	vttPath := outputBase + ".vtt"
+	defer os.Remove(vttPath)
+	vtt, err := os.ReadFile(vttPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read whisper output: %w", err)
+	}
+
+	if language == AutoLanguage {
+		if match := detectedLanguageRe.FindStringSubmatch(stderr.String()); match != nil {
+			language = match[1]
+		}
+	}
+	return vtt, language, nil
+}
+
+// transcribeWithService uploads the audio to an OpenAI compatible
+// transcription endpoint and asks for WebVTT back.
+func (p *videoProcessor) transcribeWithService(ctx context.Context, audioPath, language string) ([]byte, error) {
+	audio, err := os.Open(audioPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audio: %w", err)
+	}
+	defer audio.Close()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filepath.Base(audioPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := io.Copy(part, audio); err != nil {
+		return nil, fmt.Errorf("failed to read audio: %w", err)
+	}
+	if p.cfg.Captions.ServiceModel != "" {
+		form.WriteField("model", p.cfg.Captions.ServiceModel)
+	}
+	if language != AutoLanguage {
+		form.WriteField("language", language)
+	}
+	form.WriteField("response_format", "vtt")
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.Captions.ServiceURL, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if p.cfg.Captions.ServiceAPIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.cfg.Captions.ServiceAPIKey)
+	}
+
+	resp, err := outboundClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("transcription request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	vtt, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transcription: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("transcription service returned %d: %s", resp.StatusCode, string(vtt))
+	}
+	return vtt, nil
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/kms"
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/webhook"
 	"github.com/google/uuid"
 )
 
@@ -30,21 +34,80 @@ type Worker struct {
 	redisRepo videofiles.RedisRepository
 	awsRepo   videofiles.AWSRepository
 	videoRepo videofiles.Repository
+	keys      kms.KeyManager
 	cfg       *config.Config
 	stopChan  chan struct{}
 	wg        sync.WaitGroup
-	jobs      chan *models.EncodeJob
 	semaphore chan struct{}
+
+	draining  atomic.Bool
+	running   map[string]*runningJob
+	runningMu sync.Mutex
+	inflight  sync.WaitGroup
+
+	// id identifies this worker process in heartbeats and job ownership
+	id string
+
+	// workDir is where jobs are processed, each in a directory named after
+	// the job so a crashed run can pick up its local checkpoint
+	workDir string
+
+	// diskReserved is the disk set aside for the admitted jobs that have not
+	// finished yet, the sum of diskReservations, which holds the estimate of
+	// each by job ID
+	diskReserved     uint64
+	diskReservations map[string]uint64
+	diskMu           sync.Mutex
+
+	// encoders schedules the encodes of every job of the worker
+	encoders *encodeScheduler
+
+	// capabilities is what the worker advertises for job routing
+	capabilities *models.WorkerCapabilities
+
+	// recommendations are the benchmarked encoder presets of the host; nil
+	// when none were loaded
+	recommendations *EncoderRecommendations
+
+	// scanAlertSigner signs malware alerts; nil when no alert webhook is
+	// configured
+	scanAlertSigner *webhook.Signer
+}
+
+// runningJob is a job this worker is processing. processor is nil until the
+// job has passed its pre-flight checks.
+type runningJob struct {
+	job       *models.EncodeJob
+	cancel    context.CancelCauseFunc
+	startedAt time.Time
+	processor VideoProcessor
 }
 
 type VideoInfo struct {
-	Width    int
-	Height   int
-	Duration float64
+	Width  int
+	Height int
+	// DisplayWidth and DisplayHeight are the size the frame is shown at, with
+	// its sample aspect ratio and rotation applied
+	DisplayWidth  int
+	DisplayHeight int
+	Duration      float64
+	// Colour signalling of the video stream as named by ffprobe, e.g.
+	// bt2020 / smpte2084 / bt2020nc for HDR10
+	ColorPrimaries string
+	ColorTransfer  string
+	ColorSpace     string
+	// FrameRate is FrameRateNum/FrameRateDen, or 0 when it is unknown
+	FrameRate    float64
+	FrameRateNum int
+	FrameRateDen int
 }
 
 type VideoProcessor interface {
 	ProcessVideo(ctx context.Context, job *models.EncodeJob, videoID uuid.UUID) (*ProcessingResult, error)
+	RepackageVideo(ctx context.Context, job *models.EncodeJob, videoID uuid.UUID) (*ProcessingResult, error)
+	ProcessAudio(ctx context.Context, job *models.EncodeJob, videoID uuid.UUID) (*ProcessingResult, error)
+	ProcessImage(ctx context.Context, job *models.EncodeJob, videoID uuid.UUID) (*ProcessingResult, error)
+	PlanVideo(ctx context.Context, job *models.EncodeJob, plan *models.EncodePlan) error
 }
 
 func GetOptimalParallelJobs() int {
@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+// HardwareClass is the class of hardware the job's segments were encoded on.
+// Jobs that did not reach encoding are counted as CPU.
+func (p *videoProcessor) HardwareClass() models.HardwareClass {
+	if p.hwAccel == HWAccelNone {
+		return models.HardwareCPU
+	}
+	return models.HardwareGPU
+}
+
+// recordUsage records the compute an attempt at job took, from startedAt
+// until now, priced at the configured rate. Every attempt is recorded, so
+// interrupted and failed ones are accounted for too. Usage is best effort,
+// so failures are only logged.
+func (w *Worker) recordUsage(ctx context.Context, job *models.EncodeJob, processor VideoProcessor, startedAt time.Time, result *ProcessingResult, err error) {
+	usage := &models.JobUsage{
+		JobID:         job.JobID,
+		VideoID:       job.VideoID,
+		UserID:        job.UserID,
+		JobType:       job.Type,
+		Codec:         job.Codec,
+		HardwareClass: models.HardwareCPU,
+		Resolution:    job.ResolutionClass(),
+		Outcome:       models.UsageCompleted,
+		Currency:      w.cfg.Pricing.Currency,
+		StartedAt:     startedAt,
+		FinishedAt:    time.Now(),
+	}
+	var checkpointErr *CheckpointError
+	switch {
+	case errors.As(err, &checkpointErr):
+		usage.Outcome = models.UsageInterrupted
+	case err != nil:
+		usage.Outcome = models.UsageFailed
+	}
+	if classed, ok := processor.(interface{ HardwareClass() models.HardwareClass }); ok {
+		usage.HardwareClass = classed.HardwareClass()
+	}
+	if result != nil {
+		usage.SourceSeconds = result.Duration
+	}
+
+	usage.ComputeSeconds = usage.FinishedAt.Sub(startedAt).Seconds()
+	rate := w.cfg.Pricing.Rates[string(job.Codec)][string(usage.HardwareClass)]
+	usage.Cost = math.Round(usage.ComputeSeconds/60*rate*1e6) / 1e6
+
+	if err := w.videoRepo.CreateJobUsage(context.WithoutCancel(ctx), usage); err != nil {
+		w.jobLogger(job).Warnf("Failed to record usage of job %s: %v", job.JobID, err)
+	}
+}
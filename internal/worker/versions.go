@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/google/uuid"
+)
+
+var versionPathRe = regexp.MustCompile(`^v[0-9]+/`)
+
+// isVersionPath reports whether name, relative to a video's output prefix,
+// belongs to a re-encoded playback version
+func isVersionPath(name string) bool {
+	return versionPathRe.MatchString(name)
+}
+
+// publishPlayback makes the outputs of a finished job playable. A re-encode
+// switches the playback info and DRM key of the video to its version in one
+// step, so viewers never see the old and new outputs mixed.
+func (w *Worker) publishPlayback(ctx context.Context, job *models.EncodeJob, videoID uuid.UUID, playbackInfo *models.PlaybackInfo, drmKeyID string) error {
+	var scheme string
+	if drmKeyID != "" {
+		scheme = drmScheme(w.cfg.DRM.Scheme)
+	}
+
+	if job.PlaybackVersion > 0 {
+		playbackInfo.Version = job.PlaybackVersion
+		if err := w.videoRepo.ActivatePlaybackVersion(ctx, videoID, job.PlaybackVersion, playbackInfo, drmKeyID, scheme); err != nil {
+			return fmt.Errorf("failed to activate playback version %d: %w", job.PlaybackVersion, err)
+		}
+		return nil
+	}
+
+	if drmKeyID != "" {
+		if err := w.videoRepo.SetVideoDRMKey(ctx, videoID, drmKeyID, scheme); err != nil {
+			return fmt.Errorf("failed to store drm key id: %w", err)
+		}
+	}
+	if err := w.videoRepo.CreatePlaybackInfo(ctx, videoID, playbackInfo); err != nil {
+		return fmt.Errorf("failed to create playback info: %w", err)
+	}
+	return nil
+}
+
+// failPlaybackVersion records a failed re-encode against its version and
+// leaves the video completed, since its active version still plays.
+func (w *Worker) failPlaybackVersion(ctx context.Context, job *models.EncodeJob, videoID uuid.UUID, message string) {
+	log := w.jobLogger(job)
+	if err := w.videoRepo.FailPlaybackVersion(ctx, videoID, job.PlaybackVersion, message); err != nil {
+		log.Errorf("Failed to mark playback version %d failed: %v", job.PlaybackVersion, err)
+	}
+	if err := w.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusCompleted, 100); err != nil {
+		log.Errorf("Failed to restore video status after failed re-encode: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+const (
+	DefaultWatermarkScale   = 0.1
+	DefaultWatermarkOpacity = 0.7
+	DefaultWatermarkMargin  = 16
+)
+
+// watermarkEnabled reports whether the platform watermark is burned into the
+// renditions of this job. It applies to every plan except paid ones when a
+// watermark image is configured.
+func (p *videoProcessor) watermarkEnabled() bool {
+	return p.cfg.Watermark.ImagePath != "" && p.job.UserPlan != models.PlanPaid
+}
+
+// scaleFilter returns the software video filter scaling a segment to the
+// preset's resolution, with HDR sources tone-mapped and the watermark overlaid
+// when they apply.
+func (p *videoProcessor) scaleFilter(preset QualityPreset) string {
+	// Square pixels, so anamorphic sources are not stretched again on playback
+	scale := fmt.Sprintf("scale=%d:%d,setsar=1", preset.Resolution[0], preset.Resolution[1])
+	if p.toneMaps(preset) {
+		scale = toneMapFilter + "," + scale
+	}
+	if !p.watermarkEnabled() {
+		return scale
+	}
+
+	cfg := p.cfg.Watermark
+	relativeSize := cfg.Scale
+	if relativeSize <= 0 || relativeSize > 1 {
+		relativeSize = DefaultWatermarkScale
+	}
+	opacity := cfg.Opacity
+	if opacity <= 0 || opacity > 1 {
+		opacity = DefaultWatermarkOpacity
+	}
+	margin := cfg.Margin
+	if margin <= 0 {
+		margin = DefaultWatermarkMargin
+	}
+
+	// Keep the width even so chroma subsampled formats can hold the overlay
+	width := int(float64(preset.Resolution[0])*relativeSize) &^ 1
+
+	var position string
+	switch cfg.Position {
+	case "top-left":
+		position = fmt.Sprintf("%d:%d", margin, margin)
+	case "top-right":
+		position = fmt.Sprintf("W-w-%d:%d", margin, margin)
+	case "bottom-left":
+		position = fmt.Sprintf("%d:H-h-%d", margin, margin)
+	default:
+		position = fmt.Sprintf("W-w-%d:H-h-%d", margin, margin)
+	}
+
+	return fmt.Sprintf("movie=%s,scale=%d:-1,format=rgba,colorchannelmixer=aa=%.2f[wm];[in]%s[scaled];[scaled][wm]overlay=%s[out]",
+		escapeFilterValue(cfg.ImagePath), width, opacity, scale, position)
+}
+
+// escapeFilterValue escapes a filter option value for use inside a filtergraph,
+// which takes one level of escaping for the option and one for the graph.
+func escapeFilterValue(value string) string {
+	option := strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`).Replace(value)
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `[`, `\[`, `]`, `\]`, `,`, `\,`, `;`, `\;`).Replace(option)
+}
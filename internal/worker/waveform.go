@@ -0,0 +1,140 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+)
+
+const (
+	// WaveformFile is the peaks JSON of a video's or audio file's audio,
+	// relative to its output
+	WaveformFile = "waveform.json"
+	// WaveformSampleRate is the rate audio is resampled to before its peaks
+	// are taken; peaks need no more detail than this
+	WaveformSampleRate = 8000
+	// WaveformMaxPoints bounds the pixels of a waveform so long files do not
+	// produce huge JSON
+	WaveformMaxPoints          = 4000
+	WaveformMinSamplesPerPixel = 64
+)
+
+// generateWaveform renders the peaks of the video's audio for scrubber
+// waveforms. Videos without audio have no waveform; an empty path is
+// returned for them.
+func (p *videoProcessor) generateWaveform(ctx context.Context, inputPath string, duration float64) (string, error) {
+	_, hasAudio, err := probeStreamTypes(inputPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to probe streams: %w", err)
+	}
+	if !hasAudio || duration <= 0 {
+		return "", nil
+	}
+	waveformPath := filepath.Join(p.tempDir, WaveformFile)
+	if err := writeWaveform(ctx, inputPath, duration, waveformPath); err != nil {
+		return "", err
+	}
+	return waveformPath, nil
+}
+
+// uploadWaveform stores the waveform at the root of the output and returns
+// its name relative to the output
+func (p *videoProcessor) uploadWaveform(ctx context.Context, waveformPath, outputKey string) (string, error) {
+	fileInfo, err := os.Stat(waveformPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat waveform %s: %w", waveformPath, err)
+	}
+	baseKey := strings.TrimSuffix(outputKey, filepath.Ext(outputKey))
+	if err := p.uploadSingleFileOptimized(ctx, waveformPath, path.Join(baseKey, WaveformFile), fileInfo); err != nil {
+		return "", fmt.Errorf("failed to upload waveform: %w", err)
+	}
+	return WaveformFile, nil
+}
+
+// writeWaveform renders the waveform of the first audio stream of
+// sourcePath into outputPath as JSON
+func writeWaveform(ctx context.Context, sourcePath string, duration float64, outputPath string) error {
+	waveform, err := renderWaveform(ctx, sourcePath, duration)
+	if err != nil {
+		return fmt.Errorf("failed to render waveform: %w", err)
+	}
+	data, err := json.Marshal(waveform)
+	if err != nil {
+		return fmt.Errorf("failed to encode waveform: %w", err)
+	}
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write waveform: %w", err)
+	}
+	return nil
+}
+
+// renderWaveform takes the peaks of the first audio stream of sourcePath,
+// mixed down to mono, as a waveform of at most WaveformMaxPoints pixels
+func renderWaveform(ctx context.Context, sourcePath string, duration float64) (*models.Waveform, error) {
+	samplesPerPixel := max(WaveformMinSamplesPerPixel, int(math.Ceil(duration*WaveformSampleRate/WaveformMaxPoints)))
+	cmd := ffmpegCommandContext(ctx, "-hide_banner", "-loglevel", "error",
+		"-i", sourcePath, "-map", "0:a:0", "-ac", "1", "-ar", strconv.Itoa(WaveformSampleRate),
+		"-f", "s16le", "-acodec", "pcm_s16le", "pipe:1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ffmpeg output: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	waveform := &models.Waveform{
+		Version:         2,
+		Channels:        1,
+		SampleRate:      WaveformSampleRate,
+		SamplesPerPixel: samplesPerPixel,
+		Bits:            8,
+	}
+	reader := bufio.NewReaderSize(stdout, 64*1024)
+	var sample [2]byte
+	var low, high int16
+	var count int
+	for {
+		if _, err := io.ReadFull(reader, sample[:]); err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				cmd.Wait()
+				return nil, fmt.Errorf("failed to read samples: %w", err)
+			}
+			break
+		}
+		value := int16(binary.LittleEndian.Uint16(sample[:]))
+		if count == 0 || value < low {
+			low = value
+		}
+		if count == 0 || value > high {
+			high = value
+		}
+		count++
+		if count == samplesPerPixel {
+			waveform.Data = append(waveform.Data, int8(low>>8), int8(high>>8))
+			count = 0
+		}
+	}
+	if count > 0 {
+		waveform.Data = append(waveform.Data, int8(low>>8), int8(high>>8))
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("ffmpeg error: %v, stderr: %s", err, stderr.String())
+	}
+	waveform.Length = len(waveform.Data) / 2
+	return waveform, nil
+}
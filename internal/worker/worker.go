@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -16,44 +15,136 @@ import (
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/videofiles"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/kms"
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/logger"
 	"github.com/amankumarsingh77/cloud-video-encoder/pkg/utils"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/webhook"
 )
 
 const (
-	VideoJobsQueue  = VideoJobsQueueKey
-	JobChannel      = "new_video_jobs_channel"
-	DefaultCPULimit = 1.0
+	VideoJobsQueue      = VideoJobsQueueKey
+	JobChannel          = "new_video_jobs_channel"
+	DefaultCPULimit     = 1.0
+	DefaultDrainTimeout = 5 * time.Minute
+	// StorageRetryInterval is how long a job that failed because object
+	// storage was down waits in the deferred set before it is queued again
+	StorageRetryInterval = 30 * time.Second
+	// MaxMemoryUsage is the memory usage, in percent, above which a worker
+	// stops taking jobs
+	MaxMemoryUsage = 85.0
+	// HeadroomRetryInterval is how long a free slot waits before checking
+	// again whether the host has room for another job
+	HeadroomRetryInterval = 2 * time.Second
 )
 
 var ErrNoJob = errors.New("no job available")
 
-func NewWorker(cfg *config.Config, logger logger.Logger, redisRepo videofiles.RedisRepository, awsRepo videofiles.AWSRepository, videoRepo videofiles.Repository) (*Worker, error) {
+// NewWorker creates a worker pool. keys may be nil when encryption is not
+// configured; encrypted jobs then fail.
+func NewWorker(cfg *config.Config, logger logger.Logger, redisRepo videofiles.RedisRepository, awsRepo videofiles.AWSRepository, videoRepo videofiles.Repository, keys kms.KeyManager) (*Worker, error) {
 	if cfg == nil || logger == nil || redisRepo == nil || awsRepo == nil || videoRepo == nil {
 		return nil, errors.New("missing required dependencies")
 	}
+	workDir, err := setupWorkDir(cfg.Worker)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up work directory: %w", err)
+	}
+	if err := setupSandbox(cfg.Worker.Sandbox, workDir); err != nil {
+		return nil, fmt.Errorf("failed to set up ffmpeg sandbox: %w", err)
+	}
+	if err := setupChildLimits(cfg.Worker.ChildLimits); err != nil {
+		return nil, fmt.Errorf("failed to set up child process limits: %w", err)
+	}
+	if err := setupTools(cfg.Worker.Tools, logger); err != nil {
+		return nil, fmt.Errorf("failed to set up media tools: %w", err)
+	}
+	if err := setupOutboundMTLS(cfg.MTLS); err != nil {
+		return nil, fmt.Errorf("failed to set up mtls: %w", err)
+	}
 
+	id := newInstanceID()
 	return &Worker{
-		logger:    logger,
+		id:        id,
+		workDir:   workDir,
+		logger:    logger.With("worker_id", id),
 		redisRepo: redisRepo,
 		awsRepo:   awsRepo,
 		videoRepo: videoRepo,
+		keys:      keys,
 		cfg:       cfg,
 		stopChan:  make(chan struct{}),
-		jobs:      make(chan *models.EncodeJob, 100),
 		semaphore: make(chan struct{}, cfg.Worker.WorkerCount),
+		running:   make(map[string]*runningJob),
+
+		diskReservations: make(map[string]uint64),
 	}, nil
 }
 
 func (w *Worker) Start(ctx context.Context) error {
-	w.logger.Info("Starting worker pool")
-	log.Println(w.cfg.Worker.WorkerCount)
+	w.logger.Infof("Starting worker pool %s with %d workers", w.id, w.cfg.Worker.WorkerCount)
+
+	w.capabilities = w.detectCapabilities()
+
+	if path := w.cfg.Worker.RecommendationsPath; path != "" {
+		recommendations, err := LoadRecommendations(path)
+		if err != nil {
+			w.logger.Warnf("Using default encoder presets: %v", err)
+		} else {
+			w.recommendations = recommendations
+			w.logger.Infof("Loaded encoder recommendations benchmarked on %s at %s", recommendations.Host, recommendations.GeneratedAt.Format(time.RFC3339))
+		}
+	}
+
+	w.wg.Add(1)
+	go w.sendHeartbeats(ctx)
+
+	w.encoders = newEncodeScheduler(GetMaxConcurrentEncoders())
+	var apiSampler *utils.CgroupCPUSampler
+	if w.cfg.Worker.APICgroupPath != "" {
+		sampler, err := utils.NewCgroupCPUSampler(w.cfg.Worker.APICgroupPath)
+		if err != nil {
+			w.logger.Warnf("Not tracking API CPU usage: %v", err)
+		} else {
+			apiSampler = sampler
+		}
+	}
+	if hostSampler, err := utils.NewHostCPUSampler(); err != nil {
+		w.logger.Warnf("Not tracking host CPU usage, encoder slots stay at %d: %v", w.encoders.Limit(), err)
+	} else {
+		w.wg.Add(1)
+		go w.trackEncodeBudget(ctx, hostSampler, apiSampler)
+	}
 
 	w.wg.Add(1)
 	go w.subscribeToJobs(ctx)
 
+	w.wg.Add(1)
+	go w.reapOrphanedJobs(ctx)
+
+	w.wg.Add(1)
+	go w.promoteDeferredJobs(ctx)
+
+	w.wg.Add(1)
+	go w.reportDiskUsage(ctx)
+
+	if w.cfg.Billing.WebhookURL != "" {
+		signer, err := webhook.NewSigner(w.cfg.Billing.WebhookSecrets...)
+		if err != nil {
+			return fmt.Errorf("failed to sign billing webhooks: %w", err)
+		}
+		w.wg.Add(1)
+		go w.deliverBillingEvents(ctx, signer)
+	}
+
+	if w.cfg.Scan.AlertWebhookURL != "" {
+		signer, err := webhook.NewSigner(w.cfg.Scan.AlertWebhookSecrets...)
+		if err != nil {
+			return fmt.Errorf("failed to sign malware alerts: %w", err)
+		}
+		w.scanAlertSigner = signer
+	}
+
 	for i := 0; i < w.cfg.Worker.WorkerCount; i++ {
-		log.Println("Starting worker", i)
 		w.wg.Add(1)
 		go func(id int) {
 			w.runWorker(ctx, id)
@@ -73,7 +164,7 @@ func (w *Worker) subscribeToJobs(ctx context.Context) {
 	}
 	client := redisClient.GetRedisClient()
 
-	pubsub := client.Subscribe(ctx, JobChannel)
+	pubsub := client.Subscribe(ctx, JobChannel, videofiles.JobCancelChannel)
 	defer pubsub.Close()
 
 	_, err := pubsub.Receive(ctx)
@@ -85,12 +176,6 @@ func (w *Worker) subscribeToJobs(ctx context.Context) {
 	w.logger.Info("Successfully subscribed to job notifications channel")
 	ch := pubsub.Channel()
 
-	w.wg.Add(1)
-	go func() {
-		defer w.wg.Done()
-		w.dequeueJobs(ctx)
-	}()
-
 	for {
 		select {
 		case <-ctx.Done():
@@ -100,62 +185,166 @@ func (w *Worker) subscribeToJobs(ctx context.Context) {
 			w.logger.Info("Job subscriber received stop signal")
 			return
 		case msg := <-ch:
-			if msg != nil {
-				w.logger.Infof("Received job notification: %s", msg.Payload)
+			if msg == nil {
+				continue
+			}
+			if msg.Channel == videofiles.JobCancelChannel {
+				if w.cancelJob(msg.Payload) {
+					w.logger.Infof("Cancelling job %s", msg.Payload)
+				}
+				continue
 			}
+			w.logger.Infof("Received job notification: %s", msg.Payload)
 		}
 	}
 }
 
-func (w *Worker) dequeueJobs(ctx context.Context) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-w.stopChan:
-			return
-		default:
+// Drain stops the worker from taking new jobs. Running jobs get until
+// timeout to finish; after
+// that they are interrupted, checkpointed and re-enqueued with a resume token.
+func (w *Worker) Drain(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultDrainTimeout
+	}
 
-			job, err := w.redisRepo.DequeueJob(ctx, VideoJobsQueueKey)
+	w.runningMu.Lock()
+	w.draining.Store(true)
+	running := len(w.running)
+	w.runningMu.Unlock()
 
-			if err != nil {
-				if err != redis.Nil {
+	w.logger.Infof("Draining worker, %d jobs in flight", running)
 
-				}
-				time.Sleep(1 * time.Second)
-				continue
-			}
+	done := make(chan struct{})
+	go func() {
+		w.inflight.Wait()
+		close(done)
+	}()
 
-			if job == nil {
-				time.Sleep(1 * time.Second)
-				continue
-			}
+	select {
+	case <-done:
+		w.logger.Info("All in-flight jobs finished during drain")
+		return
+	case <-time.After(timeout):
+	}
 
-			w.logger.Infof("Successfully dequeued job %s for video %s", job.JobID, job.VideoID)
-
-			select {
-			case w.jobs <- job:
-				w.logger.Infof("Successfully queued job %s for processing", job.JobID)
-			default:
-				w.logger.Warnf("Job queue is full, waiting to queue job %s", job.JobID)
-
-				for {
-					select {
-					case <-ctx.Done():
-						return
-					case <-w.stopChan:
-						return
-					case w.jobs <- job:
-						w.logger.Infof("Successfully queued job %s after waiting", job.JobID)
-						break
-					case <-time.After(1 * time.Second):
-						continue
-					}
-					break
-				}
-			}
-		}
+	w.runningMu.Lock()
+	w.logger.Warnf("Drain timeout reached, checkpointing %d running jobs", len(w.running))
+	for _, running := range w.running {
+		running.cancel(nil)
 	}
+	w.runningMu.Unlock()
+
+	<-done
+	w.logger.Info("Worker drained")
+}
+
+// trackJob registers a running job so Drain can interrupt it. It refuses new
+// jobs once draining has started.
+func (w *Worker) trackJob(job *models.EncodeJob, cancel context.CancelCauseFunc) bool {
+	w.runningMu.Lock()
+	defer w.runningMu.Unlock()
+
+	if w.draining.Load() {
+		return false
+	}
+	w.running[job.JobID] = &runningJob{job: job, cancel: cancel, startedAt: time.Now()}
+	w.inflight.Add(1)
+	return true
+}
+
+// setJobProcessor records the processor of a running job so its stage can be
+// reported.
+func (w *Worker) setJobProcessor(jobID string, processor VideoProcessor) {
+	w.runningMu.Lock()
+	defer w.runningMu.Unlock()
+
+	if running, ok := w.running[jobID]; ok {
+		running.processor = processor
+	}
+}
+
+// cancelJob stops a running job without checkpointing it. It reports whether
+// the job was running on this worker.
+func (w *Worker) cancelJob(jobID string) bool {
+	w.runningMu.Lock()
+	defer w.runningMu.Unlock()
+
+	running, ok := w.running[jobID]
+	if ok {
+		running.cancel(errJobCancelled)
+	}
+	return ok
+}
+
+func (w *Worker) untrackJob(jobID string) {
+	w.runningMu.Lock()
+	defer w.runningMu.Unlock()
+
+	delete(w.running, jobID)
+	w.inflight.Done()
+}
+
+// requeueJob pushes a job that this worker will not run back onto the shared
+// queue so another worker can take it.
+func (w *Worker) requeueJob(job *models.EncodeJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	w.releaseUserSlot(job)
+	job.Status = models.JobStatusQueued
+	w.recordJobEvent(ctx, job, models.JobEventQueued, "")
+	if err := w.redisRepo.EnqueueJob(ctx, w.jobQueue(ctx, job), job); err != nil {
+		w.jobLogger(job).Errorf("Failed to requeue job %s: %v", job.JobID, err)
+		return
+	}
+	w.jobLogger(job).Infof("Requeued job %s", job.JobID)
+}
+
+// checkpointJob stores the checkpoint of an interrupted job and re-enqueues it
+// with the checkpoint's resume token.
+func (w *Worker) checkpointJob(job *models.EncodeJob, videoID uuid.UUID, checkpoint *models.JobCheckpoint) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := w.redisRepo.SaveCheckpoint(ctx, checkpoint); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+
+	if err := w.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusQueued, 0); err != nil {
+		w.jobLogger(job).Errorf("Failed to reset progress for checkpointed job: %v", err)
+	}
+
+	job.ResumeToken = checkpoint.ResumeToken
+	w.requeueJob(job)
+	return nil
+}
+
+// parkJob holds a job that failed because object storage is down in the
+// deferred set for StorageRetryInterval, so it outlives this worker and
+// whichever worker promotes it retries it.
+func (w *Worker) parkJob(job *models.EncodeJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	w.releaseUserSlot(job)
+	job.Status = models.JobStatusQueued
+	w.recordJobEvent(ctx, job, models.JobEventQueued, "")
+	w.deferJob(ctx, job, time.Now().Add(StorageRetryInterval))
+}
+
+// failStorageUnavailable marks a job as failed because object storage is
+// unreachable and parks it so it is retried after StorageRetryInterval.
+func (w *Worker) failStorageUnavailable(ctx context.Context, job *models.EncodeJob, videoID uuid.UUID) {
+	w.failJob(ctx, job, videoID, videofiles.ErrStorageUnavailable)
+	w.parkJob(job)
+}
+
+// unwrapDataKey returns the plaintext data key of an encrypted job.
+func (w *Worker) unwrapDataKey(ctx context.Context, job *models.EncodeJob) ([]byte, error) {
+	if w.keys == nil {
+		return nil, kms.ErrNotConfigured
+	}
+	return w.keys.Decrypt(ctx, job.EncryptedDataKey)
 }
 
 func (w *Worker) Stop() {
@@ -164,6 +353,9 @@ func (w *Worker) Stop() {
 	w.logger.Info("Worker stopped successfully")
 }
 
+// runWorker runs one job slot. The slot only pops a job off the queue once
+// it is free and the host has CPU and memory to spare, so jobs wait in Redis,
+// where any worker can take them, rather than piling up on a busy worker.
 func (w *Worker) runWorker(ctx context.Context, workerID int) {
 	defer w.wg.Done()
 	w.logger.Infof("Worker %d started", workerID)
@@ -176,106 +368,275 @@ func (w *Worker) runWorker(ctx context.Context, workerID int) {
 		case <-w.stopChan:
 			w.logger.Infof("Worker %d received stop signal", workerID)
 			return
-		case job := <-w.jobs:
-
-			select {
-			case w.semaphore <- struct{}{}:
-
-				go func() {
-					defer func() { <-w.semaphore }()
-					if err := w.processJob(ctx, workerID, job); err != nil {
-						w.logger.Errorf("Worker %d failed to process job %s: %v", workerID, job.JobID, err)
-					}
-				}()
-			default:
-
-				select {
-				case w.jobs <- job:
-					w.logger.Infof("Worker %d: Requeued job %s due to full semaphore", workerID, job.JobID)
-				default:
-					w.logger.Warnf("Worker %d: Failed to requeue job %s, channel full", workerID, job.JobID)
-				}
+		case w.semaphore <- struct{}{}:
+		}
+
+		job, ok := w.pullJob(ctx, workerID)
+		if !ok {
+			<-w.semaphore
+			if w.draining.Load() {
+				w.logger.Infof("Worker %d is draining, no longer dequeuing jobs", workerID)
+				return
 			}
+			continue
 		}
+
+		w.runJob(ctx, workerID, job)
+		<-w.semaphore
+	}
+}
+
+// pullJob pops the next job this worker can run once the host has headroom.
+// It reports false when there was no job to take, after waiting long enough
+// that the caller can loop without spinning.
+func (w *Worker) pullJob(ctx context.Context, workerID int) (*models.EncodeJob, bool) {
+	if w.draining.Load() {
+		return nil, false
 	}
+
+	canAcceptJob, usage := utils.CheckCPUUsage(w.cfg.Worker.MaxCPUUsage)
+	memoryUsage := utils.CheckMemoryUsage()
+	if !canAcceptJob || memoryUsage > MaxMemoryUsage {
+		w.logger.Debugf("Worker %d: System resources too high (CPU: %.2f%%, Memory: %.2f%%), not taking jobs", workerID, usage, memoryUsage)
+		w.sleep(ctx, HeadroomRetryInterval)
+		return nil, false
+	}
+
+	job, err := w.redisRepo.DequeueJob(ctx, w.capabilities.Queues...)
+	if err != nil {
+		if !errors.Is(err, videofiles.ErrQueueEmpty) {
+			w.logger.Warnf("Failed to dequeue job: %v", err)
+			w.sleep(ctx, time.Second)
+		}
+		return nil, false
+	}
+	if job == nil {
+		w.sleep(ctx, time.Second)
+		return nil, false
+	}
+
+	if until, ok := w.admitJob(ctx, job); !ok {
+		w.deferJob(ctx, job, until)
+		return nil, false
+	}
+	if err := w.redisRepo.StartJob(ctx, job.JobID); err != nil {
+		w.jobLogger(job).Warnf("Failed to mark job %s as started: %v", job.JobID, err)
+	}
+
+	w.logger.Infof("Worker %d dequeued job %s for video %s", workerID, job.JobID, job.VideoID)
+	return job, true
+}
+
+// runJob processes a job in the calling slot. A job dequeued as the worker
+// started draining goes back to the queue.
+func (w *Worker) runJob(ctx context.Context, workerID int, job *models.EncodeJob) {
+	defer w.releaseDisk(job)
+
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if !w.trackJob(job, cancel) {
+		w.requeueJob(job)
+		return
+	}
+
+	defer w.releaseUserSlot(job)
+	defer w.clearJobHeartbeat(job.JobID)
+	defer w.untrackJob(job.JobID)
+	if err := w.processJob(jobCtx, workerID, job); err != nil {
+		w.jobLogger(job).Errorf("Worker %d failed to process job %s: %v", workerID, job.JobID, err)
+	}
+}
+
+// sleep waits for d unless the worker stops first
+func (w *Worker) sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-w.stopChan:
+	case <-time.After(d):
+	}
+}
+
+// jobLogger returns the worker's logger with the fields of job, which every
+// line logged about the job carries.
+func (w *Worker) jobLogger(job *models.EncodeJob) logger.Logger {
+	return w.logger.With("job_id", job.JobID, "video_id", job.VideoID)
+}
+
+// loadUserPlan sets the plan of the job's user, which decides the watermark
+func (w *Worker) loadUserPlan(ctx context.Context, job *models.EncodeJob) {
+	if w.cfg.Watermark.ImagePath == "" {
+		return
+	}
+	plan := models.PlanFree
+	userID, err := uuid.Parse(job.UserID)
+	if err == nil {
+		plan, err = w.videoRepo.GetUserPlan(ctx, userID)
+	}
+	if err != nil {
+		w.jobLogger(job).Warnf("Failed to get plan of user %s, applying free plan watermark: %v", job.UserID, err)
+		plan = models.PlanFree
+	}
+	job.UserPlan = plan
 }
 
 func (w *Worker) processJob(ctx context.Context, workerID int, job *models.EncodeJob) error {
-	w.logger.Infof("Worker %d processing job: %s", workerID, job.VideoID)
+	log := w.jobLogger(job).With("slot", workerID)
+	log.Infof("Worker %d processing job: %s", workerID, job.VideoID)
 
 	videoID, err := uuid.Parse(job.VideoID)
 	if err != nil {
-		w.logger.Errorf("Failed to parse video ID: %v", err)
+		log.Errorf("Failed to parse video ID: %v", err)
 		return fmt.Errorf("invalid video ID: %w", err)
 	}
 
-	canAcceptJob, usage := utils.CheckCPUUsage(w.cfg.Worker.MaxCPUUsage)
-	memoryUsage := utils.CheckMemoryUsage()
+	// An admin may have cancelled the job after it was dequeued
+	if details, err := w.redisRepo.GetJobDetails(ctx, job.JobID); err == nil && details.FailureReason == models.FailureCancelled {
+		log.Infof("Worker %d: skipping cancelled job %s", workerID, job.JobID)
+		return nil
+	}
 
-	if !canAcceptJob || memoryUsage > 85.0 {
-		w.logger.Infof("Worker %d: System resources too high (CPU: %.2f%%, Memory: %.2f%%), requeueing job", workerID, usage, memoryUsage)
-		select {
-		case w.jobs <- job:
-			return nil
-		default:
-			return fmt.Errorf("failed to requeue job, channel full")
-		}
+	// A dry run leaves the video alone
+	if job.Type == models.JobTypePlan {
+		return w.planJob(ctx, workerID, job, videoID)
+	}
+
+	if !w.awsRepo.StorageAvailable() {
+		log.Warnf("Worker %d: storage unavailable, failing job %s fast", workerID, job.JobID)
+		w.failStorageUnavailable(ctx, job, videoID)
+		return fmt.Errorf("failed to process video: %w", videofiles.ErrStorageUnavailable)
+	}
+
+	w.recordJobEvent(ctx, job, models.JobEventStarted, "")
+
+	if err := w.videoRepo.SetVideoWorker(ctx, videoID, w.id); err != nil {
+		log.Errorf("Failed to record worker of video %s: %v", videoID, err)
+	}
+
+	if err := w.redisRepo.SetJobHeartbeat(ctx, job.JobID, w.id, HeartbeatTTL); err != nil {
+		log.Errorf("Failed to set heartbeat of job %s: %v", job.JobID, err)
 	}
 
 	if err := w.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusProcessing, 0); err != nil {
-		w.logger.Errorf("Failed to update initial progress: %v", err)
+		log.Errorf("Failed to update initial progress: %v", err)
+	}
+
+	if err := w.redisRepo.UpdateStatus(ctx, job.JobID, VideoJobsQueue, models.JobStatusProcessing); err != nil {
+		log.Errorf("Failed to update job status: %v", err)
 	}
 
-	if err := w.redisRepo.UpdateStatus(ctx, job.VideoID, VideoJobsQueue, "processing"); err != nil {
-		w.logger.Errorf("Failed to update job status: %v", err)
+	w.loadUserPlan(ctx, job)
+
+	var resume *models.JobCheckpoint
+	if job.ResumeToken != "" {
+		resume, err = w.redisRepo.GetCheckpoint(ctx, job.ResumeToken)
+		if err != nil {
+			log.Warnf("Failed to load checkpoint for job %s, starting fresh: %v", job.JobID, err)
+			resume = nil
+		}
+	}
+
+	if len(job.EncryptedDataKey) > 0 {
+		dataKey, err := w.unwrapDataKey(ctx, job)
+		if err != nil {
+			log.Errorf("Worker %d: failed to unwrap data key for job %s: %v", workerID, job.JobID, err)
+			w.failJob(ctx, job, videoID, err)
+			return fmt.Errorf("failed to process video: %w", err)
+		}
+		ctx = kms.WithDataKey(ctx, dataKey)
 	}
 
-	processor := NewVideoProcessor(w.cfg, w.awsRepo, w.videoRepo, w.logger, job)
-	result, err := processor.ProcessVideo(ctx, job, videoID)
+	processor := NewVideoProcessor(w.cfg, w.awsRepo, w.videoRepo, w.redisRepo, log, job, resume, w.workDir, w.encoders, w.recommendations)
+	w.setJobProcessor(job.JobID, processor)
+	startedAt := time.Now()
+	var result *ProcessingResult
+	switch job.Type {
+	case models.JobTypeRepackage:
+		result, err = processor.RepackageVideo(ctx, job, videoID)
+	case models.JobTypeAudio:
+		result, err = processor.ProcessAudio(ctx, job, videoID)
+	case models.JobTypeImage:
+		result, err = processor.ProcessImage(ctx, job, videoID)
+	default:
+		result, err = processor.ProcessVideo(ctx, job, videoID)
+	}
+	w.recordUsage(ctx, job, processor, startedAt, result, err)
 	if err != nil {
-		if updateErr := w.redisRepo.UpdateStatus(ctx, job.VideoID, VideoJobsQueue, "failed"); updateErr != nil {
-			w.logger.Errorf("Failed to update job status to failed: %v", updateErr)
+		var checkpointErr *CheckpointError
+		if errors.As(err, &checkpointErr) {
+			log.Infof("Worker %d: job %s interrupted, re-enqueueing with checkpoint", workerID, job.JobID)
+			return w.checkpointJob(job, videoID, checkpointErr.Checkpoint)
 		}
 
-		if updateErr := w.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusFailed, 0); updateErr != nil {
-			w.logger.Errorf("Failed to update progress on failure: %v", updateErr)
+		if errors.Is(err, videofiles.ErrStorageUnavailable) {
+			w.failStorageUnavailable(ctx, job, videoID)
+			return fmt.Errorf("failed to process video: %w", err)
 		}
+
+		w.failJob(ctx, job, videoID, err)
 		return fmt.Errorf("failed to process video: %w", err)
 	}
 
+	// The encode is done; record the result even if a drain cancelled the job.
+	ctx = context.WithoutCancel(ctx)
+
 	if err := w.videoRepo.UpdateVideoProgress(ctx, videoID, models.JobStatusCompleted, 100); err != nil {
-		w.logger.Errorf("Failed to update final progress: %v", err)
+		log.Errorf("Failed to update final progress: %v", err)
 	}
-
-	outputPath := job.OutputS3Key
-	videoExtensions := []string{".mp4", ".mkv", ".avi", ".mov", ".wmv", ".flv", ".webm"}
-	for _, ext := range videoExtensions {
-		outputPath = strings.TrimSuffix(outputPath, ext)
+	w.recordJobEvent(ctx, job, models.JobEventCompleted, "")
+
+	// Outputs are uploaded under the output key without its extension
+	outputPath := outputBaseKey(job)
+
+	// Encrypted outputs can only be read with the video's key, so they are
+	// served through the API's playback proxy instead of the CDN.
+	baseURL := fmt.Sprintf("%s/%s", w.cfg.S3.CDNEndpoint, outputPath)
+	if len(job.EncryptedDataKey) > 0 {
+		baseURL = fmt.Sprintf("%s/video/%s/stream", w.cfg.Encryption.PlaybackProxyURL, job.VideoID)
+		if job.PlaybackVersion > 0 {
+			baseURL = fmt.Sprintf("%s/%s", baseURL, models.PlaybackVersionPath(job.PlaybackVersion))
+		}
 	}
 
+	var thumbnailURLs []string
+	for _, thumbnailPath := range result.Thumbnails {
+		thumbnailURLs = append(thumbnailURLs, fmt.Sprintf("%s/thumbnails/%s", baseURL, filepath.Base(thumbnailPath)))
+	}
 	var thumbnailURL string
-	if result.ThumbnailPath != "" {
-		thumbnailURL = fmt.Sprintf("%s/%s/thumbnail.jpg", w.cfg.S3.CDNEndpoint, outputPath)
+	if len(thumbnailURLs) > 0 {
+		thumbnailURL = thumbnailURLs[0]
+	}
+
+	var previewURL string
+	if result.Preview != "" {
+		previewURL = fmt.Sprintf("%s/%s", baseURL, result.Preview)
+	}
+	var waveformURL string
+	if result.Waveform != "" {
+		waveformURL = fmt.Sprintf("%s/%s", baseURL, result.Waveform)
 	}
 
 	var subtitleURLs []string
 	for _, subtitleFile := range result.SubtitleFiles {
 		if subtitleFile != "" {
 			fileName := filepath.Base(subtitleFile)
-			subtitleURL := fmt.Sprintf("%s/%s/subtitles/%s", w.cfg.S3.CDNEndpoint, outputPath, fileName)
+			subtitleURL := fmt.Sprintf("%s/subtitles/%s", baseURL, fileName)
 			subtitleURLs = append(subtitleURLs, subtitleURL)
 		}
 	}
 
 	playbackInfo := &models.PlaybackInfo{
-		VideoID:   job.VideoID,
-		Title:     filepath.Base(job.InputS3Key),
-		Duration:  result.Duration,
-		Thumbnail: thumbnailURL,
-		Qualities: make(map[models.VideoQuality]models.QualityInfo),
-		Subtitles: subtitleURLs,
-		Format:    models.FormatHLS,
-		Status:    models.JobStatusCompleted,
+		VideoID:    job.VideoID,
+		Title:      filepath.Base(job.InputS3Key),
+		Duration:   result.Duration,
+		Thumbnail:  thumbnailURL,
+		Thumbnails: thumbnailURLs,
+		Preview:    previewURL,
+		Qualities:  make(map[models.VideoQuality]models.QualityInfo),
+		Subtitles:  subtitleURLs,
+		Format:     models.FormatHLS,
+		Status:     models.JobStatusCompleted,
+		Chapters:   result.Chapters,
+		Waveform:   waveformURL,
 	}
 
 	for _, qualityInfo := range result.Qualities {
@@ -284,48 +645,88 @@ func (w *Worker) processJob(ctx context.Context, workerID int, job *models.Encod
 			continue
 		}
 
-		var qualityKey models.VideoQuality
-		width, _ := strconv.Atoi(resolutionParts[0])
-
-		switch {
-		case width >= 1920:
-			qualityKey = models.Quality1080P
-		case width >= 1280:
-			qualityKey = models.Quality720P
-		case width >= 854:
-			qualityKey = models.Quality480P
-		default:
-			qualityKey = models.Quality360P
+		qualityKey := qualityInfo.Quality
+		if qualityKey == "" {
+			width, _ := strconv.Atoi(resolutionParts[0])
+			height, _ := strconv.Atoi(resolutionParts[1])
+			qualityKey = qualityFor(width, height, qualityInfo.FrameRate)
+		}
+
+		urls := models.PlaybackURLs{
+			HLS:  fmt.Sprintf("%s/%s/master.m3u8", baseURL, qualityKey),
+			DASH: fmt.Sprintf("%s/%s/stream.mpd", baseURL, qualityKey),
+		}
+		if download, ok := result.DownloadFiles[qualityKey]; ok {
+			urls.MP4 = fmt.Sprintf("%s/%s", baseURL, download)
+		}
+		if job.LowLatencyHLS {
+			urls.LLHLS = fmt.Sprintf("%s/%s/%s/%s", baseURL, LLHLSDir, qualityKey, LLHLSPlaylist)
 		}
 
 		playbackInfo.Qualities[qualityKey] = models.QualityInfo{
-			URLs: models.PlaybackURLs{
-				HLS:  fmt.Sprintf("%s/%s/%s/master.m3u8", w.cfg.S3.CDNEndpoint, outputPath, qualityKey),
-				DASH: fmt.Sprintf("%s/%s/%s/stream.mpd", w.cfg.S3.CDNEndpoint, outputPath, qualityKey),
-			},
+			URLs:       urls,
 			Resolution: qualityInfo.Resolution,
 			Bitrate:    qualityInfo.Bitrate,
+			FrameRate:  qualityInfo.FrameRate,
 		}
 	}
 
+	masterURLs := models.PlaybackURLs{
+		HLS:  fmt.Sprintf("%s/master.m3u8", baseURL),
+		DASH: fmt.Sprintf("%s/stream.mpd", baseURL),
+	}
+	if job.LowLatencyHLS {
+		masterURLs.LLHLS = fmt.Sprintf("%s/%s/%s", baseURL, LLHLSDir, LLHLSMasterPlaylist)
+	}
 	playbackInfo.Qualities[models.QualityMaster] = models.QualityInfo{
-		URLs: models.PlaybackURLs{
-			HLS:  fmt.Sprintf("%s/%s/master.m3u8", w.cfg.S3.CDNEndpoint, outputPath),
-			DASH: fmt.Sprintf("%s/%s/stream.mpd", w.cfg.S3.CDNEndpoint, outputPath),
-		},
+		URLs:       masterURLs,
 		Resolution: "adaptive",
 		Bitrate:    0,
 	}
 
-	if err := w.videoRepo.CreatePlaybackInfo(ctx, videoID, playbackInfo); err != nil {
-		w.logger.Errorf("Failed to create playback info: %v", err)
-		return fmt.Errorf("failed to create playback info: %w", err)
+	if job.Type == models.JobTypeAudio || job.Type == models.JobTypeImage {
+		playbackInfo = assetPlaybackInfo(job, baseURL, result)
+	}
+
+	// A repackage keeps the poster the user picked rather than the first
+	// still, and the preview and chapters of the encode
+	if job.Type == models.JobTypeRepackage {
+		if existing, err := w.videoRepo.GetPlaybackInfo(ctx, videoID); err == nil {
+			if existing.Thumbnail != "" {
+				playbackInfo.Thumbnail = existing.Thumbnail
+			}
+			playbackInfo.Preview = existing.Preview
+			playbackInfo.Chapters = existing.Chapters
+			if playbackInfo.Waveform == "" {
+				playbackInfo.Waveform = existing.Waveform
+			}
+		}
+	}
+
+	if result.Environment != nil {
+		result.Environment.WorkerID = w.id
+		if err := w.videoRepo.CreateJobEnvironment(ctx, videoID, result.Environment); err != nil {
+			log.Warnf("Failed to record environment of job %s: %v", job.JobID, err)
+		}
 	}
 
-	if err := w.redisRepo.UpdateStatus(ctx, job.VideoID, VideoJobsQueue, "completed"); err != nil {
-		w.logger.Errorf("Failed to update job status to completed: %v", err)
+	if err := w.publishPlayback(ctx, job, videoID, playbackInfo, result.DRMKeyID); err != nil {
+		log.Errorf("Failed to publish playback info: %v", err)
+		return err
+	}
+	w.emitBilling(ctx, job, result)
+	w.saveOutputCache(ctx, job, videoID, result)
+
+	if err := w.redisRepo.UpdateStatus(ctx, job.JobID, VideoJobsQueue, models.JobStatusCompleted); err != nil {
+		log.Errorf("Failed to update job status to completed: %v", err)
+	}
+
+	if job.ResumeToken != "" {
+		if err := w.redisRepo.DeleteCheckpoint(ctx, job.ResumeToken); err != nil {
+			log.Warnf("Failed to delete checkpoint for job %s: %v", job.JobID, err)
+		}
 	}
 
-	w.logger.Infof("Worker %d successfully processed job: %s", workerID, job.JobID)
+	log.Infof("Worker %d successfully processed job: %s", workerID, job.JobID)
 	return nil
 }
@@ -0,0 +1,102 @@
+package worker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// workspaceLockFile is held locked in a job's workspace while a run uses it
+const workspaceLockFile = ".lock"
+
+// workspace is the directory a run of a job works in. It is named after the
+// job so a run after a crash finds the local checkpoint of the last one, and
+// is locked while in use. A second run of the same job, e.g. when it was
+// delivered twice, gets a directory of its own rather than sharing the first
+// one's files and removing them when it is done.
+type workspace struct {
+	Dir  string
+	lock *os.File
+}
+
+// openWorkspace creates and locks the workspace of a job under workDir
+func openWorkspace(workDir, jobID string) (*workspace, error) {
+	dir := filepath.Join(workDir, jobID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+
+	lock, err := lockWorkspace(dir)
+	if err != nil {
+		return nil, err
+	}
+	if lock != nil {
+		return &workspace{Dir: dir, lock: lock}, nil
+	}
+
+	// Another run holds the job's directory
+	dir, err = os.MkdirTemp(workDir, jobID+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	return &workspace{Dir: dir}, nil
+}
+
+// lockWorkspace takes the lock of dir without waiting. It returns nil when
+// another run holds it.
+func lockWorkspace(dir string) (*os.File, error) {
+	path := filepath.Join(dir, workspaceLockFile)
+	for {
+		lock, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open workspace lock: %w", err)
+		}
+		if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			lock.Close()
+			if err == syscall.EWOULDBLOCK {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to lock workspace: %w", err)
+		}
+
+		// The run that held the lock may have removed its workspace in
+		// between, leaving this one locking a file no one else can see
+		held, err := lock.Stat()
+		if err != nil {
+			lock.Close()
+			return nil, fmt.Errorf("failed to stat workspace lock: %w", err)
+		}
+		current, err := os.Stat(path)
+		if err == nil && os.SameFile(held, current) {
+			return lock, nil
+		}
+		lock.Close()
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create workspace: %w", err)
+		}
+	}
+}
+
+// Remove deletes the workspace and releases it. Only the run's own directory
+// is touched.
+func (ws *workspace) Remove() error {
+	err := os.RemoveAll(ws.Dir)
+	if ws.lock != nil {
+		ws.lock.Close()
+	}
+	return err
+}
+
+// openWorkspace opens the job's workspace and points tempDir at it
+func (p *videoProcessor) openWorkspace() error {
+	ws, err := openWorkspace(p.workDir, p.job.JobID)
+	if err != nil {
+		return err
+	}
+	if ws.lock == nil {
+		p.logger.Warnf("Workspace of job %s is in use by another run, working in %s", p.job.JobID, ws.Dir)
+	}
+	p.workspace, p.tempDir = ws, ws.Dir
+	return nil
+}
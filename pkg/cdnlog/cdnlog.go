@@ -0,0 +1,175 @@
+package cdnlog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is the layout of an access log
+type Format string
+
+const (
+	// FormatS3 is the S3 server access log format
+	FormatS3 Format = "s3"
+	// FormatCloudFront is the tab-separated CloudFront standard log format
+	FormatCloudFront Format = "cloudfront"
+)
+
+// Record is one successful object download in an access log
+type Record struct {
+	Time  time.Time
+	Key   string
+	Bytes int64
+}
+
+// Parse calls fn with every download of an object in the log read from r.
+// Requests other than GETs and failed requests are skipped, as are lines
+// that cannot be parsed; logs are written by another system and a single
+// malformed line should not hold back the rest.
+func Parse(r io.Reader, format Format, fn func(Record)) error {
+	switch format {
+	case FormatS3:
+		return parseS3(r, fn)
+	case FormatCloudFront:
+		return parseCloudFront(r, fn)
+	default:
+		return fmt.Errorf("unknown log format: %s", format)
+	}
+}
+
+// S3 server access log fields, see
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/LogFormat.html
+const (
+	s3FieldTime      = 2
+	s3FieldOperation = 6
+	s3FieldKey       = 7
+	s3FieldStatus    = 9
+	s3FieldBytes     = 11
+)
+
+func parseS3(r io.Reader, fn func(Record)) error {
+	scanner := newScanner(r)
+	for scanner.Scan() {
+		fields := splitS3Line(scanner.Text())
+		if len(fields) <= s3FieldBytes || fields[s3FieldOperation] != "REST.GET.OBJECT" {
+			continue
+		}
+		if !successful(fields[s3FieldStatus]) {
+			continue
+		}
+		t, err := time.Parse("02/Jan/2006:15:04:05 -0700", strings.Trim(fields[s3FieldTime], "[]"))
+		if err != nil {
+			continue
+		}
+		key, err := url.PathUnescape(fields[s3FieldKey])
+		if err != nil {
+			continue
+		}
+		fn(Record{Time: t.UTC(), Key: key, Bytes: parseBytes(fields[s3FieldBytes])})
+	}
+	return scanner.Err()
+}
+
+// splitS3Line splits a line of an S3 access log on spaces, keeping quoted
+// and bracketed fields, which may contain spaces, whole
+func splitS3Line(line string) []string {
+	var fields []string
+	for line = strings.TrimSpace(line); line != ""; line = strings.TrimLeft(line, " ") {
+		end := strings.IndexByte(line, ' ')
+		switch line[0] {
+		case '"':
+			if i := strings.IndexByte(line[1:], '"'); i >= 0 {
+				end = i + 2
+			}
+		case '[':
+			if i := strings.IndexByte(line, ']'); i >= 0 {
+				end = i + 1
+			}
+		}
+		if end < 0 || end > len(line) {
+			end = len(line)
+		}
+		fields = append(fields, strings.Trim(line[:end], `"`))
+		line = line[end:]
+	}
+	return fields
+}
+
+// cloudFrontDefaultFields is the order of the CloudFront fields used here
+// when a log has no #Fields header
+var cloudFrontDefaultFields = map[string]int{
+	"date":        0,
+	"time":        1,
+	"sc-bytes":    3,
+	"cs-method":   5,
+	"cs-uri-stem": 7,
+	"sc-status":   8,
+}
+
+func parseCloudFront(r io.Reader, fn func(Record)) error {
+	columns := cloudFrontDefaultFields
+	scanner := newScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#Fields:") {
+			columns = make(map[string]int)
+			for i, name := range strings.Fields(strings.TrimPrefix(line, "#Fields:")) {
+				columns[name] = i
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(fields) {
+				return ""
+			}
+			return fields[i]
+		}
+		if field("cs-method") != "GET" || !successful(field("sc-status")) {
+			continue
+		}
+		t, err := time.Parse("2006-01-02 15:04:05", field("date")+" "+field("time"))
+		if err != nil {
+			continue
+		}
+		key, err := url.PathUnescape(field("cs-uri-stem"))
+		if err != nil {
+			continue
+		}
+		fn(Record{Time: t, Key: strings.TrimPrefix(key, "/"), Bytes: parseBytes(field("sc-bytes"))})
+	}
+	return scanner.Err()
+}
+
+// newScanner reads lines of up to 1 MiB; user agents and query strings make
+// access log lines longer than bufio's default limit
+func newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return scanner
+}
+
+// successful reports whether an HTTP status delivered content: 200 for whole
+// objects and 206 for ranges
+func successful(status string) bool {
+	return status == "200" || status == "206"
+}
+
+// parseBytes parses a byte count, which logs write as - when none were sent
+func parseBytes(field string) int64 {
+	n, err := strconv.ParseInt(field, 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,162 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateHalfOpen
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half_open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+var (
+	stateGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "circuit_breaker_state",
+		Help: "Current circuit breaker state (0 = closed, 1 = half open, 2 = open)",
+	}, []string{"breaker"})
+
+	tripsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "circuit_breaker_trips_total",
+		Help: "Number of times the circuit breaker has opened",
+	}, []string{"breaker"})
+
+	rejectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "circuit_breaker_rejected_total",
+		Help: "Number of calls rejected while the circuit breaker was open",
+	}, []string{"breaker"})
+)
+
+// Breaker opens after FailureThreshold consecutive failures and rejects calls
+// until OpenTimeout has passed. It then lets a single trial call through and
+// closes again if that call succeeds.
+type Breaker struct {
+	name             string
+	failureThreshold int
+	openTimeout      time.Duration
+
+	mu        sync.Mutex
+	state     State
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	listeners []func(State)
+}
+
+func New(name string, failureThreshold int, openTimeout time.Duration) *Breaker {
+	b := &Breaker{
+		name:             name,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+	}
+	stateGauge.WithLabelValues(name).Set(float64(StateClosed))
+	return b
+}
+
+// OnStateChange registers fn to be called, outside the breaker lock, whenever
+// the breaker changes state.
+func (b *Breaker) OnStateChange(fn func(State)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners = append(b.listeners, fn)
+}
+
+// Allow reports whether a call may proceed. Every allowed call must be
+// followed by Success or Failure.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			b.mu.Unlock()
+			rejectedTotal.WithLabelValues(b.name).Inc()
+			return ErrOpen
+		}
+		listeners := b.setState(StateHalfOpen)
+		b.probing = true
+		b.mu.Unlock()
+		notify(listeners, StateHalfOpen)
+		return nil
+	case StateHalfOpen:
+		if b.probing {
+			b.mu.Unlock()
+			rejectedTotal.WithLabelValues(b.name).Inc()
+			return ErrOpen
+		}
+		b.probing = true
+	}
+
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	b.failures = 0
+	b.probing = false
+	var listeners []func(State)
+	if b.state != StateClosed {
+		listeners = b.setState(StateClosed)
+	}
+	b.mu.Unlock()
+
+	notify(listeners, StateClosed)
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	b.failures++
+	b.probing = false
+	var listeners []func(State)
+	if b.state == StateHalfOpen || (b.state == StateClosed && b.failures >= b.failureThreshold) {
+		b.openedAt = time.Now()
+		listeners = b.setState(StateOpen)
+		tripsTotal.WithLabelValues(b.name).Inc()
+	}
+	b.mu.Unlock()
+
+	notify(listeners, StateOpen)
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// setState must be called with the lock held. It returns the listeners to
+// notify once the lock has been released.
+func (b *Breaker) setState(state State) []func(State) {
+	b.state = state
+	stateGauge.WithLabelValues(b.name).Set(float64(state))
+	return append([]func(State){}, b.listeners...)
+}
+
+func notify(listeners []func(State), state State) {
+	for _, fn := range listeners {
+		fn(state)
+	}
+}
@@ -0,0 +1,199 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// dateLayout is the layout of the start_date and end_date query params
+const dateLayout = "2006-01-02"
+
+// AnalyticsQuery narrows analytics to the days from From to To, both
+// inclusive; zero times leave that end open. Limit and Offset are only used
+// by the queries that page their results. A nil query asks for everything.
+type AnalyticsQuery struct {
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+func (q *AnalyticsQuery) values() url.Values {
+	values := url.Values{}
+	if q == nil {
+		return values
+	}
+	if !q.From.IsZero() {
+		values.Set("start_date", q.From.Format(dateLayout))
+	}
+	if !q.To.IsZero() {
+		values.Set("end_date", q.To.Format(dateLayout))
+	}
+	if q.Limit > 0 {
+		values.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Offset > 0 {
+		values.Set("offset", strconv.Itoa(q.Offset))
+	}
+	return values
+}
+
+// videoAnalytics fetches one of the analytics of a video
+func (c *Client) videoAnalytics(ctx context.Context, videoID, name string, query url.Values, out any) error {
+	path := "/analytics/videos/" + url.PathEscape(videoID) + "/" + name
+	return c.do(ctx, request{method: http.MethodGet, path: path, query: query}, out)
+}
+
+// GetAnalyticsSummary returns the totals of the account
+func (c *Client) GetAnalyticsSummary(ctx context.Context, q *AnalyticsQuery) (*AnalyticsSummary, error) {
+	var out AnalyticsSummary
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/analytics/summary", query: q.values()}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetVideoViews returns the views of a video
+func (c *Client) GetVideoViews(ctx context.Context, videoID string, q *AnalyticsQuery) ([]*VideoView, error) {
+	var out []*VideoView
+	if err := c.videoAnalytics(ctx, videoID, "views", q.values(), &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetVideoTimeSeries returns a metric of a video bucketed by interval
+func (c *Client) GetVideoTimeSeries(ctx context.Context, videoID string, metric TimeSeriesMetric, interval TimeSeriesInterval, q *AnalyticsQuery) (*TimeSeries, error) {
+	query := q.values()
+	query.Set("metric", string(metric))
+	query.Set("interval", string(interval))
+	var out TimeSeries
+	if err := c.videoAnalytics(ctx, videoID, "timeseries", query, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetVideoGeo returns the views of a video by country
+func (c *Client) GetVideoGeo(ctx context.Context, videoID string, q *AnalyticsQuery) ([]*GeoBreakdown, error) {
+	var out []*GeoBreakdown
+	if err := c.videoAnalytics(ctx, videoID, "geo", q.values(), &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetVideoDevices returns the views of a video by device, OS and browser
+func (c *Client) GetVideoDevices(ctx context.Context, videoID string, q *AnalyticsQuery) (*DeviceBreakdown, error) {
+	var out DeviceBreakdown
+	if err := c.videoAnalytics(ctx, videoID, "devices", q.values(), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetVideoRetention returns the retention curve of a video in buckets of
+// bucketSeconds; zero uses the server's default
+func (c *Client) GetVideoRetention(ctx context.Context, videoID string, bucketSeconds int, q *AnalyticsQuery) (*RetentionCurve, error) {
+	query := q.values()
+	if bucketSeconds > 0 {
+		query.Set("bucket", strconv.Itoa(bucketSeconds))
+	}
+	var out RetentionCurve
+	if err := c.videoAnalytics(ctx, videoID, "retention", query, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetVideoViewers returns how many viewers are watching a video now
+func (c *Client) GetVideoViewers(ctx context.Context, videoID string) (*VideoViewers, error) {
+	var out VideoViewers
+	if err := c.videoAnalytics(ctx, videoID, "viewers", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetVideoQoE returns the playback quality of a video
+func (c *Client) GetVideoQoE(ctx context.Context, videoID string, q *AnalyticsQuery) (*VideoQoE, error) {
+	var out VideoQoE
+	if err := c.videoAnalytics(ctx, videoID, "qoe", q.values(), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetVideoDelivery returns the CDN traffic of a video
+func (c *Client) GetVideoDelivery(ctx context.Context, videoID string, q *AnalyticsQuery) (*VideoDelivery, error) {
+	var out VideoDelivery
+	if err := c.videoAnalytics(ctx, videoID, "delivery", q.values(), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetVideoPerformance returns the engagement of a video
+func (c *Client) GetVideoPerformance(ctx context.Context, videoID string) (*VideoPerformance, error) {
+	var out VideoPerformance
+	if err := c.videoAnalytics(ctx, videoID, "performance", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetTopVideos returns the best performing videos of the account
+func (c *Client) GetTopVideos(ctx context.Context, limit int) ([]*VideoPerformance, error) {
+	return c.videoList(ctx, "/analytics/videos/top", limit)
+}
+
+// GetRecentVideos returns the performance of the newest videos of the account
+func (c *Client) GetRecentVideos(ctx context.Context, limit int) ([]*VideoPerformance, error) {
+	return c.videoList(ctx, "/analytics/videos/recent", limit)
+}
+
+func (c *Client) videoList(ctx context.Context, path string, limit int) ([]*VideoPerformance, error) {
+	var out []*VideoPerformance
+	q := &AnalyticsQuery{Limit: limit}
+	if err := c.do(ctx, request{method: http.MethodGet, path: path, query: q.values()}, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetConcurrentViewers returns how many viewers are watching the videos of
+// the account now
+func (c *Client) GetConcurrentViewers(ctx context.Context) (*ConcurrentViewers, error) {
+	var out ConcurrentViewers
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/analytics/viewers"}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetAccountHeatmap returns where viewers drop off across the videos of the
+// account, each split into the given number of buckets; zero uses the
+// server's default
+func (c *Client) GetAccountHeatmap(ctx context.Context, buckets int, q *AnalyticsQuery) (*AccountHeatmap, error) {
+	query := q.values()
+	if buckets > 0 {
+		query.Set("buckets", strconv.Itoa(buckets))
+	}
+	var out AccountHeatmap
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/analytics/heatmap", query: query}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetAccountDelivery returns the CDN traffic of the account
+func (c *Client) GetAccountDelivery(ctx context.Context, q *AnalyticsQuery) (*AccountDelivery, error) {
+	var out AccountDelivery
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/analytics/delivery", query: q.values()}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
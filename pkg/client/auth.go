@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Login signs in with an email and password and signs later requests with
+// the access token it returns
+func (c *Client) Login(ctx context.Context, email, password string) (*UserWithToken, error) {
+	body := map[string]string{"email": email, "password": password}
+	var out UserWithToken
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/auth/login", body: body}, &out); err != nil {
+		return nil, err
+	}
+	c.setTokens(out.Token, out.RefreshToken)
+	return &out, nil
+}
+
+// RefreshToken trades the refresh token of the last Login or RefreshToken
+// for a new access token. The old refresh token cannot be used again.
+func (c *Client) RefreshToken(ctx context.Context) (*UserWithToken, error) {
+	c.mu.RLock()
+	refreshToken := c.refreshToken
+	c.mu.RUnlock()
+	if refreshToken == "" {
+		return nil, errors.New("no refresh token, sign in with Login first")
+	}
+
+	body := map[string]string{"refresh_token": refreshToken}
+	var out UserWithToken
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/auth/token/refresh", body: body}, &out); err != nil {
+		return nil, err
+	}
+	c.setTokens(out.Token, out.RefreshToken)
+	return &out, nil
+}
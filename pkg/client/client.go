@@ -0,0 +1,240 @@
+// Package client is a Go client for the REST API, so services can upload
+// videos, submit jobs, follow their progress and query analytics without
+// building requests by hand.
+//
+// A Client signs requests with an access token, set with WithToken or
+// obtained with Login, and can act on an account other than the user's own
+// with WithAccount. Requests and responses use the API's own types, which
+// are re-exported here. Errors returned by the API are *APIError; use
+// IsNotFound and the StatusCode field to tell them apart.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// apiPrefix is the path of the API under the base URL
+	apiPrefix = "/api/v1"
+	// defaultTimeout bounds requests of the default HTTP client. Uploads are
+	// sent with a client of their own, see WithUploadClient.
+	defaultTimeout = 30 * time.Second
+
+	accountHeader     = "X-Account-ID"
+	idempotencyHeader = "Idempotency-Key"
+)
+
+// APIError is a response of the API with an error status. Retry is set on
+// chunks the server rejected but will take again, e.g. after a checksum
+// mismatch.
+type APIError struct {
+	StatusCode int
+	Message    string
+	Retry      bool
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("api: %s", http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("api: %s (%d)", e.Message, e.StatusCode)
+}
+
+// IsNotFound reports whether err is a 404 from the API
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Client calls the API of one deployment. It is safe for concurrent use.
+type Client struct {
+	baseURL      *url.URL
+	http         *http.Client
+	uploadClient *http.Client
+	account      string
+	userAgent    string
+
+	mu           sync.RWMutex
+	token        string
+	refreshToken string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for API requests
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithUploadClient sets the HTTP client used to send video data, to storage
+// and to the chunk endpoints. It has no timeout by default, since uploads of
+// large files take as long as they take; bound them with the context instead.
+func WithUploadClient(hc *http.Client) Option {
+	return func(c *Client) { c.uploadClient = hc }
+}
+
+// WithToken signs requests with an access token
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithAccount makes requests act on an account the user is a member of
+func WithAccount(accountID string) Option {
+	return func(c *Client) { c.account = accountID }
+}
+
+// WithUserAgent sets the User-Agent of requests
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// New returns a client of the API served at baseURL, e.g.
+// https://videos.example.com
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse base url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("base url must be http or https, got %q", baseURL)
+	}
+	c := &Client{
+		baseURL:      u,
+		http:         &http.Client{Timeout: defaultTimeout},
+		uploadClient: &http.Client{},
+		userAgent:    "cloud-video-encoder-go",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Token returns the access token requests are signed with
+func (c *Client) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+func (c *Client) setTokens(token, refreshToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+	if refreshToken != "" {
+		c.refreshToken = refreshToken
+	}
+}
+
+// endpoint returns the URL of an API path, with query when it is not empty
+func (c *Client) endpoint(path string, query url.Values) string {
+	u := *c.baseURL
+	u.Path += apiPrefix + path
+	if len(query) > 0 {
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// request is a call of the API. body is sent as JSON and raw as
+// is; upload sends it with the upload client.
+type request struct {
+	method  string
+	path    string
+	query   url.Values
+	body    any
+	raw     io.Reader
+	headers map[string]string
+	upload  bool
+}
+
+// do sends req and decodes the JSON response into out, if out is not nil
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	_, err := c.send(ctx, req, out)
+	return err
+}
+
+// send is do that also returns the headers of the response
+func (c *Client) send(ctx context.Context, req request, out any) (http.Header, error) {
+	body := req.raw
+	if req.body != nil {
+		payload, err := json.Marshal(req.body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, c.endpoint(req.path, req.query), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	if req.body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("User-Agent", c.userAgent)
+	if token := c.Token(); token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+	if c.account != "" {
+		httpReq.Header.Set(accountHeader, c.account)
+	}
+	for k, v := range req.headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	hc := c.http
+	if req.upload {
+		hc = c.uploadClient
+	}
+	resp, err := hc.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return resp.Header, decodeError(resp)
+	}
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return resp.Header, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.Header, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return resp.Header, nil
+}
+
+// decodeError reads the {"error": "..."} body the API sends with errors
+func decodeError(resp *http.Response) error {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var body struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+		Retry   bool   `json:"retry"`
+	}
+	if json.Unmarshal(data, &body) == nil {
+		apiErr.Retry = body.Retry
+		apiErr.Message = body.Error
+		if apiErr.Message == "" {
+			apiErr.Message = body.Message
+		}
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	return apiErr
+}
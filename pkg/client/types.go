@@ -0,0 +1,78 @@
+package client
+
+import "github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+
+// The API's request and response types, so code outside this module can
+// name them.
+type (
+	User             = models.User
+	UserWithToken    = models.UserWithToken
+	Codec            = models.Codec
+	VideoQuality     = models.VideoQuality
+	PlaybackFormat   = models.PlaybackFormat
+	InputQualityInfo = models.InputQualityInfo
+	Visibility       = models.Visibility
+
+	VideoUploadInput = models.VideoUploadInput
+	VideoFile        = models.VideoFile
+	VideoList        = models.VideoList
+	VideoStatus      = models.VideoStatus
+	ProcessingState  = models.ProcessingState
+	JobError         = models.JobError
+	EncodeJob        = models.EncodeJob
+	JobStatus        = models.JobStatus
+	PlaybackInfo     = models.PlaybackInfo
+
+	ChunkedUpload     = models.ChunkedUpload
+	ChunkUploadStatus = models.ChunkUploadStatus
+	UploadProgress    = models.UploadProgress
+
+	EncodePlanInput  = models.EncodePlanInput
+	EncodePlan       = models.EncodePlan
+	EncodePlanStatus = models.EncodePlanStatus
+
+	AnalyticsSummary   = models.AnalyticsSummary
+	VideoView          = models.VideoView
+	GeoBreakdown       = models.GeoBreakdown
+	DeviceBreakdown    = models.DeviceBreakdown
+	TimeSeries         = models.TimeSeries
+	TimeSeriesMetric   = models.TimeSeriesMetric
+	TimeSeriesInterval = models.TimeSeriesInterval
+	RetentionCurve     = models.RetentionCurve
+	AccountHeatmap     = models.AccountHeatmap
+	VideoViewers       = models.VideoViewers
+	ConcurrentViewers  = models.ConcurrentViewers
+	VideoQoE           = models.VideoQoE
+	VideoDelivery      = models.VideoDelivery
+	AccountDelivery    = models.AccountDelivery
+	VideoPerformance   = models.VideoPerformance
+)
+
+const (
+	CodecH264 = models.CodecH264
+	CodecAV1  = models.CodecAV1
+	CodecVP9  = models.CodecVP9
+
+	FormatHLS  = models.FormatHLS
+	FormatDASH = models.FormatDASH
+
+	StateUploaded   = models.StateUploaded
+	StateQueued     = models.StateQueued
+	StateProcessing = models.StateProcessing
+	StateStalled    = models.StateStalled
+	StateReady      = models.StateReady
+	StateFailed     = models.StateFailed
+	StateRejected   = models.StateRejected
+
+	EncodePlanPending   = models.EncodePlanPending
+	EncodePlanCompleted = models.EncodePlanCompleted
+	EncodePlanFailed    = models.EncodePlanFailed
+
+	MetricViews         = models.MetricViews
+	MetricUniqueViewers = models.MetricUniqueViewers
+	MetricWatchTime     = models.MetricWatchTime
+
+	IntervalHour = models.IntervalHour
+	IntervalDay  = models.IntervalDay
+	IntervalWeek = models.IntervalWeek
+)
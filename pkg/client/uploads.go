@@ -0,0 +1,196 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultChunkSize is the size of the chunks UploadFile sends
+	DefaultChunkSize = 8 << 20
+	// chunkAttempts is how many times a chunk is sent before giving up
+	chunkAttempts = 3
+
+	chunkChecksumHeader = "X-Chunk-Checksum"
+)
+
+// GetUploadURL returns a presigned URL the file can be PUT to directly in
+// storage, bypassing the API. Create the video or its job afterwards with
+// the same file name.
+func (c *Client) GetUploadURL(ctx context.Context, name, mimeType string, size int64) (string, error) {
+	body := map[string]any{"name": name, "mime_type": mimeType, "size": size}
+	var out struct {
+		PresignURL string `json:"presignUrl"`
+	}
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/video/get-upload-url", body: body}, &out); err != nil {
+		return "", err
+	}
+	return out.PresignURL, nil
+}
+
+// PutToStorage uploads size bytes of r to a URL from GetUploadURL
+func (c *Client) PutToStorage(ctx context.Context, presignURL, mimeType string, r io.Reader, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, presignURL, r)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", mimeType)
+	resp, err := c.uploadClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload to storage: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusBadRequest {
+		return &APIError{StatusCode: resp.StatusCode, Message: "storage rejected the upload"}
+	}
+	return nil
+}
+
+// StartUpload starts an upload sent through the API in chunks of chunkSize
+// bytes. checksum is the hex encoded SHA-256 of the whole file. Keep the
+// returned upload to resume it with ResumeUpload.
+func (c *Client) StartUpload(ctx context.Context, input *VideoUploadInput, chunkSize int64, checksum string) (*ChunkedUpload, error) {
+	body := struct {
+		*VideoUploadInput
+		ChunkSize int64  `json:"chunk_size"`
+		Checksum  string `json:"checksum"`
+	}{input, chunkSize, checksum}
+	var out ChunkedUpload
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/video/uploads", body: body}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetUploadStatus returns the chunks the server has of an upload
+func (c *Client) GetUploadStatus(ctx context.Context, uploadID string) (*ChunkUploadStatus, error) {
+	var out ChunkUploadStatus
+	path := "/video/uploads/" + uploadID
+	if err := c.do(ctx, request{method: http.MethodGet, path: path}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetUploadProgress returns how far an upload has come, including chunks
+// still being received
+func (c *Client) GetUploadProgress(ctx context.Context, uploadID string) (*UploadProgress, error) {
+	var out UploadProgress
+	path := "/video/uploads/" + uploadID + "/progress"
+	if err := c.do(ctx, request{method: http.MethodGet, path: path}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UploadChunk sends one chunk of an upload with its checksum. Chunks the
+// server rejects with Retry set are sent again, up to three times.
+func (c *Client) UploadChunk(ctx context.Context, uploadID string, index int, chunk []byte) (*ChunkUploadStatus, error) {
+	sum := sha256.Sum256(chunk)
+	path := "/video/uploads/" + uploadID + "/chunks/" + strconv.Itoa(index)
+	var lastErr error
+	for attempt := 0; attempt < chunkAttempts; attempt++ {
+		var out ChunkUploadStatus
+		req := request{
+			method:  http.MethodPut,
+			path:    path,
+			raw:     bytes.NewReader(chunk),
+			headers: map[string]string{chunkChecksumHeader: hex.EncodeToString(sum[:]), "Content-Type": "application/octet-stream"},
+			upload:  true,
+		}
+		err := c.do(ctx, req, &out)
+		if err == nil {
+			return &out, nil
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || !apiErr.Retry {
+			return nil, fmt.Errorf("failed to upload chunk %d: %w", index, err)
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("failed to upload chunk %d: %w", index, lastErr)
+}
+
+// CompleteUpload assembles an upload once every chunk was received and
+// creates its video. Start its encode with CreateJob.
+func (c *Client) CompleteUpload(ctx context.Context, uploadID string) (*VideoFile, error) {
+	var out VideoFile
+	path := "/video/uploads/" + uploadID + "/complete"
+	if err := c.do(ctx, request{method: http.MethodPost, path: path}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ResumeUpload sends the chunks of upload the server does not have yet,
+// read from file, and completes it
+func (c *Client) ResumeUpload(ctx context.Context, upload *ChunkedUpload, file io.ReaderAt) (*VideoFile, error) {
+	status, err := c.GetUploadStatus(ctx, upload.UploadID)
+	if err != nil {
+		return nil, err
+	}
+	chunk := make([]byte, upload.ChunkSize)
+	for _, index := range status.MissingChunks {
+		n, err := file.ReadAt(chunk, int64(index)*upload.ChunkSize)
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read chunk %d: %w", index, err)
+		}
+		if _, err := c.UploadChunk(ctx, upload.UploadID, index, chunk[:n]); err != nil {
+			return nil, err
+		}
+	}
+	return c.CompleteUpload(ctx, upload.UploadID)
+}
+
+// UploadFile uploads a local file through the API in chunks of
+// DefaultChunkSize and returns its video. FileName, FileSize and Format of
+// input default to those of the file. Once the upload was started it is
+// returned along with any error, so it can be resumed with ResumeUpload.
+func (c *Client) UploadFile(ctx context.Context, path string, input *VideoUploadInput) (*VideoFile, *ChunkedUpload, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, nil, fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	in := *input
+	if in.FileName == "" {
+		in.FileName = filepath.Base(path)
+	}
+	if in.FileSize == 0 {
+		in.FileSize = info.Size()
+	}
+	if in.Format == "" {
+		in.Format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	upload, err := c.StartUpload(ctx, &in, DefaultChunkSize, hex.EncodeToString(hash.Sum(nil)))
+	if err != nil {
+		return nil, nil, err
+	}
+	video, err := c.ResumeUpload(ctx, upload, file)
+	if err != nil {
+		return nil, upload, err
+	}
+	return video, upload, nil
+}
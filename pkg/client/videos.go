@@ -0,0 +1,222 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultPollInterval is how often WatchVideo and WaitForPlan poll when no
+// interval is given
+const DefaultPollInterval = 5 * time.Second
+
+// CreateJob queues the encode of an uploaded file. A non-empty
+// idempotencyKey makes retries of the request with the same key return the
+// first job instead of queueing another.
+func (c *Client) CreateJob(ctx context.Context, input *VideoUploadInput, idempotencyKey string) (*EncodeJob, error) {
+	req := request{method: http.MethodPost, path: "/video/create-job", body: input}
+	if idempotencyKey != "" {
+		req.headers = map[string]string{idempotencyHeader: idempotencyKey}
+	}
+	var out EncodeJob
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetJob returns a job by its id
+func (c *Client) GetJob(ctx context.Context, jobID string) (*EncodeJob, error) {
+	var out EncodeJob
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/video/jobs/" + url.PathEscape(jobID)}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetVideo returns a video by its id
+func (c *Client) GetVideo(ctx context.Context, videoID string) (*VideoFile, error) {
+	var out VideoFile
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/video/" + url.PathEscape(videoID)}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListVideos returns a page of videos, of a folder when folderID is not
+// empty. Pages start at 1.
+func (c *Client) ListVideos(ctx context.Context, folderID string, page, size int) (*VideoList, error) {
+	query := url.Values{}
+	if folderID != "" {
+		query.Set("folder_id", folderID)
+	}
+	if page > 0 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	if size > 0 {
+		query.Set("size", strconv.Itoa(size))
+	}
+	var out VideoList
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/video/list-videos", query: query}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteVideo moves a video to the trash
+func (c *Client) DeleteVideo(ctx context.Context, videoID string) (*VideoFile, error) {
+	var out VideoFile
+	if err := c.do(ctx, request{method: http.MethodDelete, path: "/video/" + url.PathEscape(videoID)}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPlaybackInfo returns the playback URLs of a video. token is the share
+// token of unlisted videos and may be empty.
+func (c *Client) GetPlaybackInfo(ctx context.Context, videoID, token string) (*PlaybackInfo, error) {
+	query := url.Values{}
+	if token != "" {
+		query.Set("token", token)
+	}
+	var out PlaybackInfo
+	path := "/video/" + url.PathEscape(videoID) + "/playback-info"
+	if err := c.do(ctx, request{method: http.MethodGet, path: path, query: query}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetVideoStatus returns the processing state of a video and its last job
+func (c *Client) GetVideoStatus(ctx context.Context, videoID string) (*VideoStatus, error) {
+	var out VideoStatus
+	path := "/video/" + url.PathEscape(videoID) + "/status"
+	if err := c.do(ctx, request{method: http.MethodGet, path: path}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Finished reports whether a video has reached a state its job does not
+// leave on its own
+func Finished(status *VideoStatus) bool {
+	switch status.State {
+	case StateReady, StateFailed, StateRejected:
+		return true
+	}
+	return false
+}
+
+// WatchVideo polls the status of a video every interval and sends it on the
+// returned channel whenever its state, stage or progress changes. The
+// channel is closed once the video is finished, ctx is done or polling
+// fails; the error, if any, is then sent on the error channel.
+func (c *Client) WatchVideo(ctx context.Context, videoID string, interval time.Duration) (<-chan *VideoStatus, <-chan error) {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	updates := make(chan *VideoStatus)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(updates)
+		defer close(errs)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var last *VideoStatus
+		for {
+			status, err := c.GetVideoStatus(ctx, videoID)
+			if err != nil {
+				if ctx.Err() == nil {
+					errs <- err
+				}
+				return
+			}
+			if last == nil || status.State != last.State || status.Stage != last.Stage ||
+				status.Progress != last.Progress || status.StageProgress != last.StageProgress {
+				select {
+				case updates <- status:
+				case <-ctx.Done():
+					return
+				}
+				last = status
+			}
+			if Finished(status) {
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return updates, errs
+}
+
+// WaitForVideo blocks until a video is finished and returns its last status.
+// onUpdate, if not nil, is called with every change, e.g. to show progress.
+func (c *Client) WaitForVideo(ctx context.Context, videoID string, interval time.Duration, onUpdate func(*VideoStatus)) (*VideoStatus, error) {
+	updates, errs := c.WatchVideo(ctx, videoID, interval)
+	var last *VideoStatus
+	for status := range updates {
+		if onUpdate != nil {
+			onUpdate(status)
+		}
+		last = status
+	}
+	if err := <-errs; err != nil {
+		return last, err
+	}
+	if last == nil || !Finished(last) {
+		return last, ctx.Err()
+	}
+	return last, nil
+}
+
+// PlanEncode queues a dry run of a video with a profile. The plan it returns
+// is pending; wait for it with WaitForPlan.
+func (c *Client) PlanEncode(ctx context.Context, videoID string, input *EncodePlanInput) (*EncodePlan, error) {
+	var out EncodePlan
+	path := "/video/" + url.PathEscape(videoID) + "/plan"
+	if err := c.do(ctx, request{method: http.MethodPost, path: path, body: input}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetEncodePlan returns the plan of a dry run
+func (c *Client) GetEncodePlan(ctx context.Context, videoID, jobID string) (*EncodePlan, error) {
+	var out EncodePlan
+	path := "/video/" + url.PathEscape(videoID) + "/plan/" + url.PathEscape(jobID)
+	if err := c.do(ctx, request{method: http.MethodGet, path: path}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WaitForPlan polls the plan of a dry run every interval until it is no
+// longer pending
+func (c *Client) WaitForPlan(ctx context.Context, videoID, jobID string, interval time.Duration) (*EncodePlan, error) {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		plan, err := c.GetEncodePlan(ctx, videoID, jobID)
+		if err != nil {
+			return nil, err
+		}
+		if plan.Status != EncodePlanPending {
+			return plan, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return plan, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package drm
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// KeySize is the size of CENC content keys and key IDs.
+const KeySize = 16
+
+const (
+	SchemeCENC = "cenc"
+	SchemeCBCS = "cbcs"
+)
+
+// ErrNotConfigured is returned when DRM is requested but no key server has
+// been configured.
+var ErrNotConfigured = errors.New("drm is not configured")
+
+// ContentKey is the key a video is encrypted with. Only KeyID may be stored;
+// Key is handed to the packager and must never be persisted.
+type ContentKey struct {
+	KeyID []byte
+	Key   []byte
+}
+
+// KeyIDHex returns the key ID in the form packagers and manifests use.
+func (k *ContentKey) KeyIDHex() string {
+	return hex.EncodeToString(k.KeyID)
+}
+
+// KeyProvider acquires content keys. The key server is the source of truth,
+// so the license server can hand the same key to authorized players.
+type KeyProvider interface {
+	GetContentKey(ctx context.Context, contentID string) (*ContentKey, error)
+}
+
+type httpKeyProvider struct {
+	serverURL string
+	token     string
+	client    *http.Client
+}
+
+type keyRequest struct {
+	ContentID string `json:"content_id"`
+}
+
+type keyResponse struct {
+	KeyID string `json:"key_id"`
+	Key   string `json:"key"`
+}
+
+// NewKeyProvider returns a KeyProvider requesting keys from a KMS or DRM proxy
+// at serverURL. The server answers a POST of {"content_id"} with the hex
+// encoded {"key_id", "key"}. It returns ErrNotConfigured when serverURL is
+// empty. tlsConfig, which may be nil, is used to connect to the server, e.g.
+// to present a client certificate.
+func NewKeyProvider(serverURL, token string, timeout time.Duration, tlsConfig *tls.Config) (KeyProvider, error) {
+	if serverURL == "" {
+		return nil, ErrNotConfigured
+	}
+	client := &http.Client{Timeout: timeout}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		client.Transport = transport
+	}
+	return &httpKeyProvider{
+		serverURL: serverURL,
+		token:     token,
+		client:    client,
+	}, nil
+}
+
+func (p *httpKeyProvider) GetContentKey(ctx context.Context, contentID string) (*ContentKey, error) {
+	body, err := json.Marshal(keyRequest{ContentID: contentID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.serverURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request content key: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("key server returned %s: %s", resp.Status, bytes.TrimSpace(message))
+	}
+
+	var keyResp keyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&keyResp); err != nil {
+		return nil, fmt.Errorf("failed to decode key response: %w", err)
+	}
+
+	keyID, err := decodeKey(keyResp.KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key id: %w", err)
+	}
+	key, err := decodeKey(keyResp.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+
+	return &ContentKey{KeyID: keyID, Key: key}, nil
+}
+
+func decodeKey(value string) ([]byte, error) {
+	key, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// SignLicenseURL appends the video, key ID and an expiry to a license
+// acquisition URL and signs them with secret, so the license server can check
+// the request was issued to an authorized viewer.
+func SignLicenseURL(licenseURL, secret, videoID, keyID string, expires time.Time) (string, error) {
+	u, err := url.Parse(licenseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid license url: %w", err)
+	}
+
+	expiresAt := strconv.FormatInt(expires.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(videoID + "|" + keyID + "|" + expiresAt))
+
+	query := u.Query()
+	query.Set("video_id", videoID)
+	query.Set("kid", keyID)
+	query.Set("expires", expiresAt)
+	query.Set("signature", hex.EncodeToString(mac.Sum(nil)))
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
@@ -0,0 +1,136 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Location is the result of a GeoIP lookup. Empty fields mean unknown.
+type Location struct {
+	Country string
+	Region  string
+}
+
+// Resolver maps an IP address to a location.
+type Resolver interface {
+	Lookup(ip string) Location
+}
+
+// NewResolver loads the IP range database at path. An empty path yields a
+// resolver that never finds anything, so GeoIP stays optional.
+func NewResolver(path string) (Resolver, error) {
+	if path == "" {
+		return noopResolver{}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open geoip database: %w", err)
+	}
+	defer file.Close()
+
+	return NewCSVResolver(file)
+}
+
+type noopResolver struct{}
+
+func (noopResolver) Lookup(string) Location { return Location{} }
+
+type ipRange struct {
+	start   net.IP
+	end     net.IP
+	country string
+	region  string
+}
+
+type csvResolver struct {
+	ranges []ipRange
+}
+
+// NewCSVResolver reads rows of "start_ip,end_ip,country[,region]", the layout
+// used by the freely available DB-IP and IP2Location lite CSV exports.
+func NewCSVResolver(r io.Reader) (Resolver, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+
+	var ranges []ipRange
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read geoip database: %w", err)
+		}
+		if len(record) < 3 {
+			continue
+		}
+
+		start := normalize(net.ParseIP(strings.TrimSpace(record[0])))
+		end := normalize(net.ParseIP(strings.TrimSpace(record[1])))
+		if start == nil || end == nil || len(start) != len(end) {
+			continue
+		}
+
+		entry := ipRange{start: start, end: end, country: strings.ToUpper(strings.TrimSpace(record[2]))}
+		if len(record) > 3 {
+			entry.region = strings.TrimSpace(record[3])
+		}
+		ranges = append(ranges, entry)
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return compare(ranges[i].start, ranges[j].start) < 0
+	})
+
+	return &csvResolver{ranges: ranges}, nil
+}
+
+func (r *csvResolver) Lookup(ip string) Location {
+	addr := normalize(net.ParseIP(ip))
+	if addr == nil {
+		return Location{}
+	}
+
+	// Find the last range starting at or before addr
+	idx := sort.Search(len(r.ranges), func(i int) bool {
+		return compare(r.ranges[i].start, addr) > 0
+	}) - 1
+	if idx < 0 {
+		return Location{}
+	}
+
+	entry := r.ranges[idx]
+	if len(entry.start) != len(addr) || compare(addr, entry.end) > 0 {
+		return Location{}
+	}
+
+	return Location{Country: entry.country, Region: entry.region}
+}
+
+// normalize returns IPv4 addresses in their 4-byte form so they only compare
+// against other IPv4 ranges.
+func normalize(ip net.IP) net.IP {
+	if ip == nil {
+		return nil
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip.To16()
+}
+
+func compare(a, b net.IP) int {
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	return bytes.Compare(a, b)
+}
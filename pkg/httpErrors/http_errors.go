@@ -40,6 +40,7 @@ var (
 	InvalidJWTClaims      = errors.New("Invalid JWT claims")
 	NotAllowedImageHeader = errors.New("Not allowed image header")
 	NoCookie              = errors.New("not found cookie header")
+	TooManyRequests       = errors.New("Too many requests")
 )
 
 // Rest error interface
@@ -134,6 +135,15 @@ func NewForbiddenError(causes interface{}) RestErr {
 	}
 }
 
+// New Too Many Requests Error
+func NewTooManyRequestsError(causes interface{}) RestErr {
+	return RestError{
+		ErrStatus: http.StatusTooManyRequests,
+		ErrError:  TooManyRequests.Error(),
+		ErrCauses: causes,
+	}
+}
+
 // New Internal Server Error
 func NewInternalServerError(causes interface{}) RestErr {
 	result := RestError{
@@ -0,0 +1,143 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultLocale is used when a request accepts none of the bundled locales.
+// Error messages are written in it, so it needs no error translations.
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// Locale holds the translations of one language. Errors is keyed by the
+// English message and FailureReasons by models.FailureReason.
+type Locale struct {
+	FailureReasons map[string]string `json:"failure_reasons"`
+	Errors         map[string]string `json:"errors"`
+}
+
+// Catalog is the set of bundled locales, keyed by language tag.
+type Catalog struct {
+	locales map[string]*Locale
+}
+
+var (
+	defaultCatalog     *Catalog
+	defaultCatalogErr  error
+	defaultCatalogOnce sync.Once
+)
+
+// Default returns the catalog of the locales embedded in the binary.
+func Default() (*Catalog, error) {
+	defaultCatalogOnce.Do(func() {
+		defaultCatalog, defaultCatalogErr = load()
+	})
+	return defaultCatalog, defaultCatalogErr
+}
+
+func load() (*Catalog, error) {
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read locales: %w", err)
+	}
+
+	catalog := &Catalog{locales: make(map[string]*Locale, len(files))}
+	for _, file := range files {
+		data, err := localeFiles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read locale %s: %w", file.Name(), err)
+		}
+		locale := &Locale{}
+		if err := json.Unmarshal(data, locale); err != nil {
+			return nil, fmt.Errorf("failed to parse locale %s: %w", file.Name(), err)
+		}
+		catalog.locales[strings.TrimSuffix(file.Name(), ".json")] = locale
+	}
+
+	if _, ok := catalog.locales[DefaultLocale]; !ok {
+		return nil, fmt.Errorf("default locale %s is missing", DefaultLocale)
+	}
+	return catalog, nil
+}
+
+// Match picks the bundled locale that best fits an Accept-Language header,
+// honouring quality values. A regional tag such as es-MX falls back to its
+// base language.
+func (c *Catalog) Match(acceptLanguage string) string {
+	type candidate struct {
+		tag     string
+		quality float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if quality > 0 {
+			candidates = append(candidates, candidate{strings.ToLower(tag), quality})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+
+	for _, candidate := range candidates {
+		if _, ok := c.locales[candidate.tag]; ok {
+			return candidate.tag
+		}
+		base, _, _ := strings.Cut(candidate.tag, "-")
+		if _, ok := c.locales[base]; ok {
+			return base
+		}
+	}
+	return DefaultLocale
+}
+
+// Error translates an error message. Messages of the form "prefix: detail"
+// are translated by their prefix and keep the detail as is, since details
+// usually come from lower layers. Unknown messages are returned unchanged.
+func (c *Catalog) Error(locale, message string) string {
+	l, ok := c.locales[locale]
+	if !ok || len(l.Errors) == 0 {
+		return message
+	}
+	if translated, ok := l.Errors[message]; ok {
+		return translated
+	}
+	if prefix, detail, ok := strings.Cut(message, ": "); ok {
+		if translated, ok := l.Errors[prefix]; ok {
+			return translated + ": " + detail
+		}
+	}
+	return message
+}
+
+// FailureReason describes a job failure reason for users, falling back to
+// the default locale.
+func (c *Catalog) FailureReason(locale, reason string) string {
+	if l, ok := c.locales[locale]; ok {
+		if description, ok := l.FailureReasons[reason]; ok {
+			return description
+		}
+	}
+	return c.locales[DefaultLocale].FailureReasons[reason]
+}
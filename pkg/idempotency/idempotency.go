@@ -0,0 +1,102 @@
+// Package idempotency remembers the responses of requests sent with an
+// idempotency key, so a client retrying a request it never saw the answer to
+// gets the original response instead of repeating its side effects.
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	// ErrInProgress is returned while the first request with a key is still
+	// being handled
+	ErrInProgress = errors.New("a request with this idempotency key is in progress")
+	// ErrMismatch is returned when a key is reused for a different request
+	ErrMismatch = errors.New("idempotency key was used for a different request")
+)
+
+// Response is what a key remembers. Status is zero until the request that
+// claimed the key completes.
+type Response struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Store keeps keys and their responses in Redis
+type Store struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewStore keeps keys under Redis keys starting with prefix
+func NewStore(client *redis.Client, prefix string) *Store {
+	return &Store{client: client, prefix: prefix}
+}
+
+// Begin claims key for a request identified by fingerprint, for up to
+// lockTTL. It returns nil when the caller holds the key and should handle
+// the request, or the response of an earlier request with the same key to
+// replay.
+func (s *Store) Begin(ctx context.Context, key, fingerprint string, lockTTL time.Duration) (*Response, error) {
+	pending, err := json.Marshal(&Response{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode idempotency key: %w", err)
+	}
+	claimed, err := s.client.SetNX(ctx, s.prefix+key, pending, lockTTL).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+	if claimed {
+		return nil, nil
+	}
+
+	data, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// The other request released the key or its lock expired between
+		// the two calls; the client can retry.
+		return nil, ErrInProgress
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	var stored Response
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode idempotency key: %w", err)
+	}
+	if stored.Fingerprint != fingerprint {
+		return nil, ErrMismatch
+	}
+	if stored.Status == 0 {
+		return nil, ErrInProgress
+	}
+	return &stored, nil
+}
+
+// Complete stores the response of the request holding key for ttl
+func (s *Store) Complete(ctx context.Context, key string, response *Response, ttl time.Duration) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("failed to encode idempotent response: %w", err)
+	}
+	if err := s.client.Set(ctx, s.prefix+key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+// Release gives up key without storing a response, so the request can be
+// retried with it
+func (s *Store) Release(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, s.prefix+key).Err(); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package kms
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DataKeySize is the size of the per-video AES-256 data keys.
+const DataKeySize = 32
+
+// ErrNotConfigured is returned when encryption is requested but no master key
+// has been configured.
+var ErrNotConfigured = errors.New("encryption is not configured")
+
+// DataKey is a freshly generated data key. Plaintext is used to encrypt the
+// content and must never be stored; Ciphertext is the wrapped form that is
+// persisted alongside the video.
+type DataKey struct {
+	Plaintext  []byte
+	Ciphertext []byte
+}
+
+// KeyManager generates and unwraps per-video data keys. Implementations can
+// be backed by a cloud KMS; the local one wraps keys with a configured
+// customer master key.
+type KeyManager interface {
+	GenerateDataKey(ctx context.Context) (*DataKey, error)
+	Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+type localKeyManager struct {
+	aead cipher.AEAD
+}
+
+// New returns a KeyManager wrapping data keys with the base64 encoded 256-bit
+// master key. It returns ErrNotConfigured when masterKey is empty.
+func New(masterKey string) (KeyManager, error) {
+	if masterKey == "" {
+		return nil, ErrNotConfigured
+	}
+
+	key, err := base64.StdEncoding.DecodeString(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key encoding: %w", err)
+	}
+	if len(key) != DataKeySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", DataKeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcm: %w", err)
+	}
+
+	return &localKeyManager{aead: aead}, nil
+}
+
+func (m *localKeyManager) GenerateDataKey(ctx context.Context) (*DataKey, error) {
+	plaintext := make([]byte, DataKeySize)
+	if _, err := io.ReadFull(rand.Reader, plaintext); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	nonce := make([]byte, m.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return &DataKey{
+		Plaintext:  plaintext,
+		Ciphertext: m.aead.Seal(nonce, nonce, plaintext, nil),
+	}, nil
+}
+
+func (m *localKeyManager) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	nonceSize := m.aead.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, errors.New("wrapped data key is too short")
+	}
+
+	plaintext, err := m.aead.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return plaintext, nil
+}
+
+type dataKeyCtxKey struct{}
+
+// WithDataKey returns a context carrying the plaintext data key. The storage
+// layer encrypts and decrypts objects with it transparently.
+func WithDataKey(ctx context.Context, key []byte) context.Context {
+	return context.WithValue(ctx, dataKeyCtxKey{}, key)
+}
+
+// WithoutDataKey returns a context that accesses storage unencrypted, for
+// objects written before a key was assigned.
+func WithoutDataKey(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dataKeyCtxKey{}, []byte(nil))
+}
+
+// DataKeyFromContext returns the data key set on ctx, if any.
+func DataKeyFromContext(ctx context.Context) ([]byte, bool) {
+	key, ok := ctx.Value(dataKeyCtxKey{}).([]byte)
+	return key, ok && len(key) > 0
+}
@@ -22,6 +22,9 @@ type Logger interface {
 	DPanicf(template string, args ...interface{})
 	Fatal(args ...interface{})
 	Fatalf(template string, args ...interface{})
+	// With returns a logger that adds the key-value pairs to every line, so
+	// lines can be correlated by field rather than by parsing the message
+	With(args ...interface{}) Logger
 }
 
 type apiLogger struct {
@@ -70,6 +73,7 @@ func (l *apiLogger) InitLogger() {
 	encoderCfg.TimeKey = "TIME"
 	encoderCfg.NameKey = "NAME"
 	encoderCfg.MessageKey = "MESSAGE"
+	encoderCfg.EncodeTime = zapcore.ISO8601TimeEncoder
 
 	if l.cfg.Logger.Encoding == "console" {
 		encoder = zapcore.NewConsoleEncoder(encoderCfg)
@@ -77,7 +81,6 @@ func (l *apiLogger) InitLogger() {
 		encoder = zapcore.NewJSONEncoder(encoderCfg)
 	}
 
-	encoderCfg.EncodeTime = zapcore.ISO8601TimeEncoder
 	core := zapcore.NewCore(encoder, logWriter, zap.NewAtomicLevelAt(logLevel))
 	logger := zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))
 
@@ -142,3 +145,7 @@ func (l *apiLogger) Fatal(args ...interface{}) {
 func (l *apiLogger) Fatalf(template string, args ...interface{}) {
 	l.sugarLogger.Fatalf(template, args...)
 }
+
+func (l *apiLogger) With(args ...interface{}) Logger {
+	return &apiLogger{cfg: l.cfg, sugarLogger: l.sugarLogger.With(args...)}
+}
@@ -0,0 +1,170 @@
+// Package malware scans files for malware, with a ClamAV daemon or an
+// external scanning API.
+package malware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// instreamChunkSize is the size of the chunks a file is streamed to clamd in;
+// clamd rejects chunks above its StreamMaxLength
+const instreamChunkSize = 1 << 20
+
+// Result is the verdict of a scan. Signature names what was found in an
+// infected file.
+type Result struct {
+	Infected  bool   `json:"infected"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// Scanner scans a file for malware
+type Scanner interface {
+	Scan(ctx context.Context, path string) (*Result, error)
+}
+
+// ClamAV scans files with a clamd daemon, e.g. a sidecar container, over its
+// INSTREAM command so the daemon needs no access to the file system
+type ClamAV struct {
+	// Network is tcp or unix
+	Network string
+	Addr    string
+}
+
+// NewClamAV returns a scanner using the clamd listening at addr, a host and
+// port or the path of a unix socket
+func NewClamAV(addr string) *ClamAV {
+	network := "tcp"
+	if strings.HasPrefix(addr, "/") {
+		network = "unix"
+	}
+	return &ClamAV{Network: network, Addr: addr}
+}
+
+func (c *ClamAV) Scan(ctx context.Context, path string) (*Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.Network, c.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("failed to send scan command: %w", err)
+	}
+	buf := make([]byte, 4+instreamChunkSize)
+	for {
+		n, err := file.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				return nil, fmt.Errorf("failed to stream file to clamd: %w", err)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+	// A zero length chunk ends the stream
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, fmt.Errorf("failed to end stream: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	return parseClamdReply(reply)
+}
+
+// parseClamdReply parses replies like "stream: OK" and
+// "stream: Eicar-Signature FOUND"
+func parseClamdReply(reply string) (*Result, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	verdict := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case verdict == "OK":
+		return &Result{}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return &Result{Infected: true, Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	default:
+		return nil, fmt.Errorf("clamd error: %s", reply)
+	}
+}
+
+// HTTPScanner scans files with an external scanning API. The file is posted
+// as the request body and the API responds with a Result as JSON.
+type HTTPScanner struct {
+	URL    string
+	APIKey string
+	Client *http.Client
+}
+
+// NewHTTPScanner returns a scanner posting files to url, authenticated with
+// apiKey as a bearer token when it is set
+func NewHTTPScanner(url, apiKey string) *HTTPScanner {
+	return &HTTPScanner{URL: url, APIKey: apiKey, Client: http.DefaultClient}
+}
+
+func (s *HTTPScanner) Scan(ctx context.Context, path string) (*Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Accept", "application/json")
+	if s.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call scanning api: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scanning api response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("scanning api returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	result := &Result{}
+	if err := json.Unmarshal(body, result); err != nil {
+		return nil, fmt.Errorf("failed to decode scanning api response: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,240 @@
+// Package mtls builds mutual TLS configurations for the internal HTTP
+// surfaces, such as the worker metrics endpoint and the admin API, so they can
+// run on zero-trust networks where every peer presents a certificate.
+//
+// Certificates issued by a service mesh or cert-manager are short lived and
+// rotated in place. A Reloader watches the certificate, key and CA files and
+// picks up new versions on the next handshake after they change, so processes
+// do not need to be restarted. If a rotated file cannot be loaded, for instance
+// because the key has been written but the certificate not yet, the previous
+// version is kept and loading is retried on a later handshake.
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultCheckInterval is how often the files are checked for changes
+const DefaultCheckInterval = 10 * time.Second
+
+var (
+	ErrNotConfigured  = errors.New("mtls: certificate, key and CA files are required")
+	ErrNoClientCert   = errors.New("mtls: no verified client certificate")
+	ErrPeerNotAllowed = errors.New("mtls: peer certificate is not allowed")
+)
+
+type Options struct {
+	CertFile string
+	KeyFile  string
+	// CAFile holds the PEM encoded CAs peer certificates must chain to
+	CAFile string
+	// AllowedNames restricts peers to certificates carrying one of these DNS
+	// or URI SANs, such as SPIFFE IDs. Any certificate issued by the CA is
+	// accepted when it is empty.
+	AllowedNames []string
+	// CheckInterval defaults to DefaultCheckInterval
+	CheckInterval time.Duration
+}
+
+// Reloader serves the current certificate and CA pool. It is safe for
+// concurrent use.
+type Reloader struct {
+	opts    Options
+	allowed map[string]bool
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	modTimes  [3]time.Time
+	checkedAt time.Time
+}
+
+// NewReloader loads the certificate, key and CA files. It fails if any of
+// them is missing or invalid.
+func NewReloader(opts Options) (*Reloader, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" || opts.CAFile == "" {
+		return nil, ErrNotConfigured
+	}
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = DefaultCheckInterval
+	}
+
+	r := &Reloader{opts: opts}
+	if len(opts.AllowedNames) > 0 {
+		r.allowed = make(map[string]bool, len(opts.AllowedNames))
+		for _, name := range opts.AllowedNames {
+			r.allowed[name] = true
+		}
+	}
+	modTimes, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modTimes); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reloader) stat() ([3]time.Time, error) {
+	var modTimes [3]time.Time
+	for i, name := range []string{r.opts.CertFile, r.opts.KeyFile, r.opts.CAFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return modTimes, fmt.Errorf("mtls: %w", err)
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+func (r *Reloader) load(modTimes [3]time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("mtls: failed to load key pair: %w", err)
+	}
+	caPEM, err := os.ReadFile(r.opts.CAFile)
+	if err != nil {
+		return fmt.Errorf("mtls: failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return fmt.Errorf("mtls: no certificates found in %s", r.opts.CAFile)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.pool = pool
+	r.modTimes = modTimes
+	r.checkedAt = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+// current returns the certificate and pool, reloading them first if the
+// files changed since they were last checked.
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.RLock()
+	cert, pool, modTimes, checkedAt := r.cert, r.pool, r.modTimes, r.checkedAt
+	r.mu.RUnlock()
+	if time.Since(checkedAt) < r.opts.CheckInterval {
+		return cert, pool
+	}
+
+	latest, err := r.stat()
+	if err == nil && latest != modTimes && r.load(latest) == nil {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return r.cert, r.pool
+	}
+	r.mu.Lock()
+	r.checkedAt = time.Now()
+	r.mu.Unlock()
+	return cert, pool
+}
+
+// ServerConfig returns a server configuration verifying client certificates
+// against the CA. When requireClientCert is false, clients without a
+// certificate are let through and VerifyRequest decides per request; the
+// certificates that are presented must still be valid.
+func (r *Reloader) ServerConfig(requireClientCert bool) *tls.Config {
+	clientAuth := tls.VerifyClientCertIfGiven
+	if requireClientCert {
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+	getCertificate := func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert, _ := r.current()
+		return cert, nil
+	}
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: getCertificate,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			_, pool := r.current()
+			return &tls.Config{
+				MinVersion:       tls.VersionTLS12,
+				GetCertificate:   getCertificate,
+				ClientAuth:       clientAuth,
+				ClientCAs:        pool,
+				VerifyConnection: r.verifyClient,
+			}, nil
+		},
+	}
+}
+
+// ClientConfig returns a client configuration presenting the certificate and
+// verifying servers against the CA rather than the system roots.
+func (r *Reloader) ClientConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			return cert, nil
+		},
+		// The CA pool changes on reload, so the chain is verified in
+		// VerifyConnection instead of through RootCAs
+		InsecureSkipVerify: true,
+		VerifyConnection:   r.verifyServer,
+	}
+}
+
+func (r *Reloader) verifyClient(cs tls.ConnectionState) error {
+	// Chains were verified by the handshake; only the identity is left
+	if len(cs.PeerCertificates) == 0 {
+		return nil
+	}
+	return r.checkAllowed(cs.PeerCertificates[0])
+}
+
+func (r *Reloader) verifyServer(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("mtls: server presented no certificate")
+	}
+	_, pool := r.current()
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	leaf := cs.PeerCertificates[0]
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         pool,
+		Intermediates: intermediates,
+	}); err != nil {
+		return fmt.Errorf("mtls: %w", err)
+	}
+	return r.checkAllowed(leaf)
+}
+
+func (r *Reloader) checkAllowed(cert *x509.Certificate) error {
+	if r.allowed == nil {
+		return nil
+	}
+	for _, name := range cert.DNSNames {
+		if r.allowed[name] {
+			return nil
+		}
+	}
+	for _, uri := range cert.URIs {
+		if r.allowed[uri.String()] {
+			return nil
+		}
+	}
+	return ErrPeerNotAllowed
+}
+
+// VerifyRequest reports whether state, the TLS state of a request, carries a
+// client certificate verified against the CA. Use it to require mTLS on some
+// routes of a server whose ServerConfig does not require it.
+func VerifyRequest(state *tls.ConnectionState) error {
+	if state == nil || len(state.VerifiedChains) == 0 {
+		return ErrNoClientCert
+	}
+	return nil
+}
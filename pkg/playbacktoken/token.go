@@ -0,0 +1,149 @@
+// Package playbacktoken issues and verifies the signed tokens that authorize
+// requests to the playback origin, so private videos can be delivered without
+// making the output bucket public.
+//
+// A token has the form
+//
+//	<video id>.<user id>.<share>.<expiry>.<signature>
+//
+// where the user id is "-" for anonymous viewers, share is the ShareHash of
+// the share token of an unlisted video or "-", the expiry is a Unix time and
+// the signature is the hex encoded HMAC-SHA256 of the first four fields. It
+// only contains URL safe characters, so it can be used as a path segment and
+// relative playlist and segment URLs keep carrying it.
+//
+// Secrets are rotated like webhook secrets: tokens are signed with the first
+// secret and verified against all of them, so tokens issued before a rotation
+// stay valid until they expire.
+package playbacktoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// none stands for an anonymous user and for a missing share hash
+const none = "-"
+
+// shareHashLen is how many hex characters of the SHA-256 of a share token a
+// token carries
+const shareHashLen = 16
+
+var (
+	ErrNoSecrets        = errors.New("playbacktoken: no signing secrets configured")
+	ErrMalformedToken   = errors.New("playbacktoken: malformed token")
+	ErrInvalidSignature = errors.New("playbacktoken: signature does not match")
+	ErrExpired          = errors.New("playbacktoken: token expired")
+)
+
+// Claims are the fields a token vouches for
+type Claims struct {
+	VideoID uuid.UUID
+	// UserID is uuid.Nil for anonymous viewers of public and unlisted videos
+	UserID uuid.UUID
+	// ShareHash is the ShareHash of the share token an unlisted video had
+	// when the token was issued, so rotating it revokes the token. It is
+	// empty for videos without one.
+	ShareHash string
+	ExpiresAt time.Time
+}
+
+// ShareHash returns the digest of a share token that tokens carry in place of
+// the share token itself
+func ShareHash(shareToken string) string {
+	sum := sha256.Sum256([]byte(shareToken))
+	return hex.EncodeToString(sum[:])[:shareHashLen]
+}
+
+// Signer issues and verifies tokens. The first secret is the current one; the
+// others are being rotated out.
+type Signer struct {
+	secrets [][]byte
+}
+
+// NewSigner returns a Signer for the given secrets. Blank secrets are ignored.
+func NewSigner(secrets ...string) (*Signer, error) {
+	signer := &Signer{}
+	for _, secret := range secrets {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			signer.secrets = append(signer.secrets, []byte(secret))
+		}
+	}
+	if len(signer.secrets) == 0 {
+		return nil, ErrNoSecrets
+	}
+	return signer, nil
+}
+
+// Sign returns a token for claims
+func (s *Signer) Sign(claims Claims) string {
+	user := none
+	if claims.UserID != uuid.Nil {
+		user = claims.UserID.String()
+	}
+	share := none
+	if claims.ShareHash != "" {
+		share = claims.ShareHash
+	}
+	payload := claims.VideoID.String() + "." + user + "." + share + "." + strconv.FormatInt(claims.ExpiresAt.Unix(), 10)
+	return payload + "." + hex.EncodeToString(computeSignature(s.secrets[0], payload))
+}
+
+// Verify checks the signature and expiry of token and returns its claims.
+func (s *Signer) Verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return nil, ErrMalformedToken
+	}
+	signature, err := hex.DecodeString(parts[4])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	payload := strings.Join(parts[:4], ".")
+	valid := false
+	for _, secret := range s.secrets {
+		if hmac.Equal(signature, computeSignature(secret, payload)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, ErrInvalidSignature
+	}
+
+	claims := &Claims{}
+	if claims.VideoID, err = uuid.Parse(parts[0]); err != nil {
+		return nil, ErrMalformedToken
+	}
+	if parts[1] != none {
+		if claims.UserID, err = uuid.Parse(parts[1]); err != nil {
+			return nil, ErrMalformedToken
+		}
+	}
+	if parts[2] != none {
+		claims.ShareHash = parts[2]
+	}
+	expires, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	claims.ExpiresAt = time.Unix(expires, 0)
+	if !now.Before(claims.ExpiresAt) {
+		return nil, ErrExpired
+	}
+	return claims, nil
+}
+
+func computeSignature(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,117 @@
+package proclimit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const (
+	cgroupRoot = "/sys/fs/cgroup"
+	// cpuPeriod is the cpu.max period in microseconds, the kernel default
+	cpuPeriod = 100000
+)
+
+// setupCgroup creates the cgroup at path, writes its limits and returns the
+// open directory that commands are started in
+func setupCgroup(path string, cpus float64, memoryMB int) (*os.File, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cgroupRoot, path)
+	}
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("%w: no cgroup v2 hierarchy at %s", ErrCgroupUnsupported, cgroupRoot)
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, fmt.Errorf("proclimit: failed to create cgroup %s: %w", path, err)
+	}
+
+	var controllers []string
+	if cpus > 0 {
+		controllers = append(controllers, "cpu")
+	}
+	if memoryMB > 0 {
+		controllers = append(controllers, "memory")
+	}
+	if err := enableControllers(filepath.Dir(path), controllers); err != nil {
+		return nil, err
+	}
+
+	cpuMax := fmt.Sprintf("max %d", cpuPeriod)
+	if cpus > 0 {
+		cpuMax = fmt.Sprintf("%d %d", max(int(cpus*cpuPeriod), 1000), cpuPeriod)
+	}
+	memoryMax := "max"
+	if memoryMB > 0 {
+		memoryMax = fmt.Sprint(int64(memoryMB) << 20)
+	}
+	if cpus > 0 || hasFile(path, "cpu.max") {
+		if err := writeCgroupFile(path, "cpu.max", cpuMax); err != nil {
+			return nil, err
+		}
+	}
+	if memoryMB > 0 || hasFile(path, "memory.max") {
+		if err := writeCgroupFile(path, "memory.max", memoryMax); err != nil {
+			return nil, err
+		}
+	}
+
+	dir, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("proclimit: failed to open cgroup %s: %w", path, err)
+	}
+	return dir, nil
+}
+
+// enableControllers makes the controllers available to the children of
+// parent. Controllers that are enabled already are skipped so that a
+// delegated parent the worker may not write to still works.
+func enableControllers(parent string, controllers []string) error {
+	data, err := os.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))
+	if err != nil {
+		return fmt.Errorf("proclimit: failed to read controllers of %s: %w", parent, err)
+	}
+	enabled := strings.Fields(string(data))
+	var missing []string
+	for _, controller := range controllers {
+		found := false
+		for _, e := range enabled {
+			if e == controller {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, "+"+controller)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return writeCgroupFile(parent, "cgroup.subtree_control", strings.Join(missing, " "))
+}
+
+func writeCgroupFile(dir, name, value string) error {
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0644); err != nil {
+		return fmt.Errorf("proclimit: failed to write %s of %s: %w", name, dir, err)
+	}
+	return nil
+}
+
+func hasFile(dir, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name))
+	return !errors.Is(err, os.ErrNotExist)
+}
+
+// startInCgroup makes the kernel start cmd directly in the cgroup, so not
+// even its first instructions run outside of it
+func startInCgroup(cmd *exec.Cmd, cgroup *os.File) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(cgroup.Fd())
+}
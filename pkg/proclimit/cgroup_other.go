@@ -0,0 +1,14 @@
+//go:build !linux
+
+package proclimit
+
+import (
+	"os"
+	"os/exec"
+)
+
+func setupCgroup(path string, cpus float64, memoryMB int) (*os.File, error) {
+	return nil, ErrCgroupUnsupported
+}
+
+func startInCgroup(cmd *exec.Cmd, cgroup *os.File) {}
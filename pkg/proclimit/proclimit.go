@@ -0,0 +1,124 @@
+// Package proclimit lowers the priority of heavy child processes, such as
+// ffmpeg and the packagers, so a host they share with other services stays
+// responsive.
+//
+// Commands are started through nice and ionice, and on Linux they can be
+// placed in a cgroup v2 group whose cpu.max and memory.max cap all of them
+// together.
+package proclimit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+const (
+	IOClassRealtime   = "realtime"
+	IOClassBestEffort = "best-effort"
+	IOClassIdle       = "idle"
+)
+
+// ErrCgroupUnsupported is returned when a cgroup is requested on a system
+// without cgroup v2
+var ErrCgroupUnsupported = errors.New("proclimit: cgroups are only supported on linux")
+
+type Options struct {
+	// Nice is added to the scheduling niceness of commands, 0 to 19
+	Nice int
+	// IOClass is the I/O scheduling class of commands; their class is left
+	// alone when it is empty
+	IOClass string
+	// IOLevel is the priority within realtime and best-effort, 0 (highest)
+	// to 7
+	IOLevel int
+	// Cgroup is the cgroup v2 directory commands are started in, created
+	// when it does not exist. Relative paths are taken from /sys/fs/cgroup.
+	// The cgroup of the calling process cannot be used, because cgroup v2
+	// does not allow limits on a group that has processes of its own.
+	Cgroup string
+	// CPUs caps the CPU of the cgroup in cores. Zero means no cap.
+	CPUs float64
+	// MemoryMB caps the memory of the cgroup. Zero means no cap.
+	MemoryMB int
+}
+
+// Limiter applies limits to commands. A nil Limiter leaves them alone, so
+// callers do not need to check whether limits are configured.
+type Limiter struct {
+	prefix []string
+	cgroup *os.File
+}
+
+// New checks the options, looks up nice and ionice and sets up the cgroup
+func New(opts Options) (*Limiter, error) {
+	if opts.Nice < 0 || opts.Nice > 19 {
+		return nil, fmt.Errorf("proclimit: nice must be between 0 and 19, got %d", opts.Nice)
+	}
+	if opts.IOLevel < 0 || opts.IOLevel > 7 {
+		return nil, fmt.Errorf("proclimit: io level must be between 0 and 7, got %d", opts.IOLevel)
+	}
+	if opts.CPUs < 0 || opts.MemoryMB < 0 {
+		return nil, errors.New("proclimit: cgroup limits must not be negative")
+	}
+
+	l := &Limiter{}
+	if opts.Nice > 0 {
+		path, err := exec.LookPath("nice")
+		if err != nil {
+			return nil, fmt.Errorf("proclimit: nice is not installed: %w", err)
+		}
+		l.prefix = append(l.prefix, path, "-n", fmt.Sprint(opts.Nice))
+	}
+	if opts.IOClass != "" {
+		args, err := ioniceArgs(opts.IOClass, opts.IOLevel)
+		if err != nil {
+			return nil, err
+		}
+		path, err := exec.LookPath("ionice")
+		if err != nil {
+			return nil, fmt.Errorf("proclimit: ionice is not installed: %w", err)
+		}
+		l.prefix = append(append(l.prefix, path), args...)
+	}
+	if opts.Cgroup != "" {
+		cgroup, err := setupCgroup(opts.Cgroup, opts.CPUs, opts.MemoryMB)
+		if err != nil {
+			return nil, err
+		}
+		l.cgroup = cgroup
+	}
+	return l, nil
+}
+
+func ioniceArgs(class string, level int) ([]string, error) {
+	switch class {
+	case IOClassRealtime:
+		return []string{"-c", "1", "-n", fmt.Sprint(level)}, nil
+	case IOClassBestEffort:
+		return []string{"-c", "2", "-n", fmt.Sprint(level)}, nil
+	case IOClassIdle:
+		return []string{"-c", "3"}, nil
+	default:
+		return nil, fmt.Errorf("proclimit: unknown io class %q", class)
+	}
+}
+
+// Apply makes cmd start with the limits. It must be called before the
+// command is started; commands that already failed to build are left alone.
+func (l *Limiter) Apply(cmd *exec.Cmd) *exec.Cmd {
+	if l == nil || cmd.Err != nil {
+		return cmd
+	}
+	if len(l.prefix) > 0 {
+		args := append([]string{}, l.prefix...)
+		args = append(args, cmd.Path)
+		cmd.Args = append(args, cmd.Args[1:]...)
+		cmd.Path = l.prefix[0]
+	}
+	if l.cgroup != nil {
+		startInCgroup(cmd, l.cgroup)
+	}
+	return cmd
+}
@@ -0,0 +1,137 @@
+// Package sandbox runs media tools under bubblewrap so that a decoder
+// vulnerability triggered by an untrusted upload cannot reach the network,
+// other processes or files outside the directories the job writes to.
+//
+// Inside the sandbox the host filesystem is mounted read-only, every
+// namespace is unshared, all capabilities are dropped and the process dies
+// with the worker. An optional seccomp filter, a compiled BPF program such as
+// one exported with libseccomp's seccomp_export_bpf, further restricts the
+// system calls the tool may make.
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// seccompFD is the descriptor the filter is passed to bwrap on; it is the
+// first of exec.Cmd's ExtraFiles.
+const seccompFD = "3"
+
+// ErrUnavailable is returned when sandboxing is requested but bwrap cannot
+// be found.
+var ErrUnavailable = errors.New("sandbox: bwrap is not installed")
+
+type Options struct {
+	// BwrapPath is the bubblewrap binary, looked up in PATH when empty
+	BwrapPath string
+	// SeccompProfile is a compiled BPF seccomp filter. No filter is applied
+	// when it is empty.
+	SeccompProfile string
+	// WritableDirs are bind mounted read-write; everything else is read-only
+	WritableDirs []string
+	// Devices exposes the host's /dev, which hardware encoders need. Only a
+	// minimal /dev is mounted otherwise.
+	Devices bool
+}
+
+// Sandbox wraps commands in bwrap. A nil Sandbox runs them directly, so
+// callers do not need to check whether sandboxing is enabled.
+type Sandbox struct {
+	bwrap    string
+	seccomp  string
+	writable []string
+	devices  bool
+}
+
+// New checks that bwrap and the seccomp profile are present and returns a
+// Sandbox for them.
+func New(opts Options) (*Sandbox, error) {
+	bwrap := opts.BwrapPath
+	if bwrap == "" {
+		bwrap = "bwrap"
+	}
+	path, err := exec.LookPath(bwrap)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	if opts.SeccompProfile != "" {
+		info, err := os.Stat(opts.SeccompProfile)
+		if err != nil {
+			return nil, fmt.Errorf("sandbox: invalid seccomp profile: %w", err)
+		}
+		if info.Size() == 0 {
+			return nil, fmt.Errorf("sandbox: seccomp profile %s is empty", opts.SeccompProfile)
+		}
+	}
+
+	sandbox := &Sandbox{bwrap: path, seccomp: opts.SeccompProfile, devices: opts.Devices}
+	for _, dir := range opts.WritableDirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, fmt.Errorf("sandbox: invalid directory %s: %w", dir, err)
+		}
+		// bwrap can only bind directories that exist
+		if err := os.MkdirAll(abs, 0755); err != nil {
+			return nil, fmt.Errorf("sandbox: failed to create %s: %w", abs, err)
+		}
+		sandbox.writable = append(sandbox.writable, abs)
+	}
+	return sandbox, nil
+}
+
+// Command returns a command running name in the sandbox. The working
+// directory is kept so relative paths resolve as they would outside of it.
+// If the seccomp profile cannot be opened the command fails when started.
+func (s *Sandbox) Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	if s == nil {
+		return exec.CommandContext(ctx, name, args...)
+	}
+
+	bwrapArgs := []string{
+		"--unshare-all",
+		"--die-with-parent",
+		"--new-session",
+		"--cap-drop", "ALL",
+		"--ro-bind", "/", "/",
+		"--proc", "/proc",
+		"--tmpfs", "/tmp",
+	}
+	if s.devices {
+		bwrapArgs = append(bwrapArgs, "--dev-bind", "/dev", "/dev")
+	} else {
+		bwrapArgs = append(bwrapArgs, "--dev", "/dev")
+	}
+	for _, dir := range s.writable {
+		bwrapArgs = append(bwrapArgs, "--bind", dir, dir)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		bwrapArgs = append(bwrapArgs, "--chdir", wd)
+	}
+
+	var seccomp *os.File
+	if s.seccomp != "" {
+		file, err := os.Open(s.seccomp)
+		if err != nil {
+			cmd := exec.CommandContext(ctx, name, args...)
+			cmd.Err = fmt.Errorf("sandbox: failed to open seccomp profile: %w", err)
+			return cmd
+		}
+		seccomp = file
+		bwrapArgs = append(bwrapArgs, "--seccomp", seccompFD)
+	}
+
+	bwrapArgs = append(bwrapArgs, "--", name)
+	cmd := exec.CommandContext(ctx, s.bwrap, append(bwrapArgs, args...)...)
+	if seccomp != nil {
+		// The parent's copy is closed by the file's finalizer once the
+		// command is gone
+		cmd.ExtraFiles = []*os.File{seccomp}
+	}
+	return cmd
+}
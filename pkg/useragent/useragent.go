@@ -0,0 +1,110 @@
+package useragent
+
+import "strings"
+
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceTV      = "tv"
+	DeviceBot     = "bot"
+	Unknown       = "unknown"
+)
+
+// Info is the device, browser and operating system derived from a User-Agent.
+type Info struct {
+	DeviceType string
+	Browser    string
+	OS         string
+}
+
+type rule struct {
+	token string
+	name  string
+}
+
+// Order matters: more specific tokens must come before the generic ones they
+// contain (e.g. Edge and Opera UAs also contain "Chrome" and "Safari").
+var browserRules = []rule{
+	{"edg/", "Edge"},
+	{"edge/", "Edge"},
+	{"opr/", "Opera"},
+	{"opera", "Opera"},
+	{"samsungbrowser", "Samsung Internet"},
+	{"ucbrowser", "UC Browser"},
+	{"firefox/", "Firefox"},
+	{"fxios/", "Firefox"},
+	{"crios/", "Chrome"},
+	{"chrome/", "Chrome"},
+	{"chromium/", "Chromium"},
+	{"safari/", "Safari"},
+	{"msie ", "Internet Explorer"},
+	{"trident/", "Internet Explorer"},
+}
+
+var osRules = []rule{
+	{"windows", "Windows"},
+	{"iphone", "iOS"},
+	{"ipad", "iOS"},
+	{"ipod", "iOS"},
+	{"android", "Android"},
+	{"cros", "ChromeOS"},
+	{"mac os x", "macOS"},
+	{"macintosh", "macOS"},
+	{"tizen", "Tizen"},
+	{"webos", "webOS"},
+	{"linux", "Linux"},
+}
+
+var botTokens = []string{"bot", "crawler", "spider", "slurp", "curl/", "wget/", "python-requests", "headless"}
+
+var tvTokens = []string{"smart-tv", "smarttv", "googletv", "appletv", "hbbtv", "roku", "crkey", "aftb", "aftt", "bravia", "tizen", "webos"}
+
+// Parse derives device, browser and OS from a User-Agent header. Unrecognised
+// values are reported as "unknown" rather than guessed.
+func Parse(userAgent string) Info {
+	ua := strings.ToLower(userAgent)
+	if strings.TrimSpace(ua) == "" {
+		return Info{DeviceType: Unknown, Browser: Unknown, OS: Unknown}
+	}
+
+	return Info{
+		DeviceType: deviceType(ua),
+		Browser:    match(ua, browserRules),
+		OS:         match(ua, osRules),
+	}
+}
+
+func deviceType(ua string) string {
+	switch {
+	case containsAny(ua, botTokens):
+		return DeviceBot
+	case containsAny(ua, tvTokens):
+		return DeviceTV
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet") ||
+		(strings.Contains(ua, "android") && !strings.Contains(ua, "mobile")):
+		return DeviceTablet
+	case strings.Contains(ua, "mobi") || strings.Contains(ua, "iphone") || strings.Contains(ua, "ipod"):
+		return DeviceMobile
+	default:
+		return DeviceDesktop
+	}
+}
+
+func match(ua string, rules []rule) string {
+	for _, r := range rules {
+		if strings.Contains(ua, r.token) {
+			return r.name
+		}
+	}
+	return Unknown
+}
+
+func containsAny(ua string, tokens []string) bool {
+	for _, token := range tokens {
+		if strings.Contains(ua, token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CgroupCPUSampler measures the CPU used by the processes of a cgroup, such
+// as the API server running on the same host as a worker.
+type CgroupCPUSampler struct {
+	path string
+
+	mu        sync.Mutex
+	lastUsage time.Duration
+	lastTime  time.Time
+}
+
+// NewCgroupCPUSampler returns a sampler for the cgroup directory at path, e.g.
+// /sys/fs/cgroup/system.slice/api.service. Both cgroup v2 (cpu.stat) and v1
+// (cpuacct.usage) hierarchies are supported.
+func NewCgroupCPUSampler(path string) (*CgroupCPUSampler, error) {
+	s := &CgroupCPUSampler{path: path}
+	usage, err := s.readUsage()
+	if err != nil {
+		return nil, err
+	}
+	s.lastUsage = usage
+	s.lastTime = time.Now()
+	return s, nil
+}
+
+// Sample returns the CPU used by the cgroup since the previous sample as a
+// percentage of the whole host, comparable to CheckCPUUsage.
+func (s *CgroupCPUSampler) Sample() (float64, error) {
+	usage, err := s.readUsage()
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elapsed := now.Sub(s.lastTime)
+	used := usage - s.lastUsage
+	s.lastUsage = usage
+	s.lastTime = now
+
+	if elapsed <= 0 || used < 0 {
+		return 0, nil
+	}
+	return float64(used) / float64(elapsed*time.Duration(runtime.NumCPU())) * 100, nil
+}
+
+func (s *CgroupCPUSampler) readUsage() (time.Duration, error) {
+	file, err := os.Open(filepath.Join(s.path, "cpu.stat"))
+	if err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			key, value, ok := strings.Cut(scanner.Text(), " ")
+			if !ok || key != "usage_usec" {
+				continue
+			}
+			usec, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid usage_usec: %w", err)
+			}
+			return time.Duration(usec) * time.Microsecond, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return 0, fmt.Errorf("failed to read cpu.stat: %w", err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.path, "cpuacct.usage"))
+	if err != nil {
+		return 0, fmt.Errorf("no cpu usage found for cgroup %s: %w", s.path, err)
+	}
+	nsec, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cpuacct.usage: %w", err)
+	}
+	return time.Duration(nsec), nil
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/shirou/gopsutil/cpu"
+)
+
+// HostCPUSampler measures the CPU used on the whole host between samples.
+// Unlike CheckCPUUsage it keeps its own previous reading, so other callers do
+// not shorten its interval.
+type HostCPUSampler struct {
+	mu        sync.Mutex
+	lastBusy  float64
+	lastTotal float64
+}
+
+func NewHostCPUSampler() (*HostCPUSampler, error) {
+	s := &HostCPUSampler{}
+	busy, total, err := readHostCPU()
+	if err != nil {
+		return nil, err
+	}
+	s.lastBusy, s.lastTotal = busy, total
+	return s, nil
+}
+
+// Sample returns the CPU used on the host since the previous sample as a
+// percentage, comparable to CheckCPUUsage.
+func (s *HostCPUSampler) Sample() (float64, error) {
+	busy, total, err := readHostCPU()
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	busyDelta, totalDelta := busy-s.lastBusy, total-s.lastTotal
+	s.lastBusy, s.lastTotal = busy, total
+	if totalDelta <= 0 || busyDelta < 0 {
+		return 0, nil
+	}
+	return busyDelta / totalDelta * 100, nil
+}
+
+func readHostCPU() (busy, total float64, err error) {
+	times, err := cpu.Times(false)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read cpu times: %w", err)
+	}
+	if len(times) == 0 {
+		return 0, 0, fmt.Errorf("no cpu times reported")
+	}
+	t := times[0]
+	busy = t.User + t.System + t.Nice + t.Irq + t.Softirq + t.Steal
+	return busy, busy + t.Idle + t.Iowait, nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/config"
 	"github.com/amankumarsingh77/cloud-video-encoder/internal/models"
+	"github.com/amankumarsingh77/cloud-video-encoder/pkg/playbacktoken"
 	"github.com/labstack/echo/v4"
 )
 
@@ -24,6 +25,51 @@ func GetUserFromCtx(ctx context.Context) (*models.User, error) {
 	return user, nil
 }
 
+// ActorCtxKey is the type for the actor context key
+type ActorCtxKey struct{}
+
+// CtxActorKey is the singleton instance for the actor context. It holds the
+// signed in user while the user in the context is an account they act on.
+var CtxActorKey = ActorCtxKey{}
+
+// GetActorFromCtx returns the user making the request, which differs from
+// the user in the context when they act on an account they are a member of
+func GetActorFromCtx(ctx context.Context) (*models.User, error) {
+	if actor, ok := ctx.Value(CtxActorKey).(*models.User); ok {
+		return actor, nil
+	}
+	return GetUserFromCtx(ctx)
+}
+
+// LocaleCtxKey is the type for the locale context key
+type LocaleCtxKey struct{}
+
+// CtxLocaleKey is the singleton instance for locale context
+var CtxLocaleKey = LocaleCtxKey{}
+
+// GetLocaleFromCtx returns the locale negotiated for the request, or an
+// empty string when none was.
+func GetLocaleFromCtx(ctx context.Context) string {
+	locale, _ := ctx.Value(CtxLocaleKey).(string)
+	return locale
+}
+
+// PlaybackClaimsCtxKey is the type for the playback token claims context key
+type PlaybackClaimsCtxKey struct{}
+
+// CtxPlaybackClaimsKey is the singleton instance for playback token claims
+var CtxPlaybackClaimsKey = PlaybackClaimsCtxKey{}
+
+// GetPlaybackClaimsFromCtx returns the claims of the playback token the
+// request was authorized with.
+func GetPlaybackClaimsFromCtx(ctx context.Context) (*playbacktoken.Claims, error) {
+	claims, ok := ctx.Value(CtxPlaybackClaimsKey).(*playbacktoken.Claims)
+	if !ok {
+		return nil, fmt.Errorf("playback token not found in context")
+	}
+	return claims, nil
+}
+
 func GetRequestID(c echo.Context) string {
 	return c.Response().Header().Get(echo.HeaderXRequestID)
 }
@@ -46,3 +92,15 @@ func CreateSessionCookie(cfg *config.Config, session string) *http.Cookie {
 		SameSite:   0,
 	}
 }
+
+// DeleteSessionCookie makes the client drop its session cookie
+func DeleteSessionCookie(cfg *config.Config) *http.Cookie {
+	return &http.Cookie{
+		Name:     cfg.Session.Name,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   cfg.Cookie.Secure,
+		HttpOnly: cfg.Cookie.HTTPOnly,
+	}
+}
@@ -13,8 +13,51 @@ type ContextKey string
 
 const (
 	TokenExpireDuration = time.Hour * 24
+	// DefaultAccessTokenExpire and DefaultRefreshTokenExpire are used when the
+	// auth config does not set them
+	DefaultAccessTokenExpire  = 15 * time.Minute
+	DefaultRefreshTokenExpire = 30 * 24 * time.Hour
 )
 
+// Auth modes, see config.AuthConfig
+const (
+	AuthModeSession = "session"
+	AuthModeJWT     = "jwt"
+	AuthModeBoth    = "both"
+)
+
+// SessionsEnabled reports whether clients may authenticate with a session
+// cookie
+func SessionsEnabled(cfg *config.Config) bool {
+	return cfg.Auth.Mode != AuthModeJWT
+}
+
+// TokensEnabled reports whether clients may authenticate with access tokens
+// and renew them with refresh tokens
+func TokensEnabled(cfg *config.Config) bool {
+	return cfg.Auth.Mode == AuthModeJWT || cfg.Auth.Mode == AuthModeBoth
+}
+
+// AccessTokenExpire is how long the tokens of GenerateJWTToken are valid.
+// Tokens last a day unless they are used as access tokens.
+func AccessTokenExpire(cfg *config.Config) time.Duration {
+	if !TokensEnabled(cfg) {
+		return TokenExpireDuration
+	}
+	if cfg.Auth.AccessTokenExpire > 0 {
+		return time.Duration(cfg.Auth.AccessTokenExpire) * time.Second
+	}
+	return DefaultAccessTokenExpire
+}
+
+// RefreshTokenExpire is how long, in seconds, a refresh token is valid
+func RefreshTokenExpire(cfg *config.Config) int {
+	if cfg.Auth.RefreshTokenExpire > 0 {
+		return cfg.Auth.RefreshTokenExpire
+	}
+	return int(DefaultRefreshTokenExpire.Seconds())
+}
+
 type Claims struct {
 	UserID   string      `json:"user_id"`
 	Email    string      `json:"email"`
@@ -30,7 +73,8 @@ func GenerateJWTToken(user *models.User, config *config.Config) (string, error)
 		Username: user.Username,
 		Role:     user.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExpireDuration)),
+			Subject:   user.UserID.String(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenExpire(config))),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
@@ -0,0 +1,149 @@
+// Package webhook signs outgoing webhook payloads and verifies their
+// signatures.
+//
+// Every request carries a SignatureHeader of the form
+//
+//	t=1700000000,v1=5257a869e7ec...,v1=0a8d6cbd34f9...
+//
+// where t is the Unix time the payload was signed at and every v1 is the hex
+// encoded HMAC-SHA256 of "<t>.<body>" under one of the active secrets.
+//
+// Secrets are rotated without dropping events by signing with several at once:
+// add the new secret in front of the old one, let integrators switch to it,
+// then remove the old one. During the overlap every request carries a
+// signature for each secret, so receivers holding either of them accept it.
+//
+// Receivers should compute the expected signature for each secret they hold,
+// compare it to every v1 in constant time and reject requests whose t is
+// further than DefaultTolerance from their clock, which stops captured
+// requests from being replayed later. Verify does all of this.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SignatureHeader is the request header the signature is sent in
+	SignatureHeader = "X-Streamscale-Signature"
+	// DefaultTolerance is how far a signature's timestamp may be from the
+	// receiver's clock before it is rejected as a replay
+	DefaultTolerance = 5 * time.Minute
+
+	signatureScheme = "v1"
+)
+
+var (
+	ErrNoSecrets          = errors.New("webhook: no signing secrets configured")
+	ErrMalformedSignature = errors.New("webhook: malformed signature header")
+	ErrNoValidSignature   = errors.New("webhook: no signature matches the payload")
+	ErrTimestampTolerance = errors.New("webhook: signature timestamp outside the tolerance")
+)
+
+// Signer signs payloads with every active secret. The first secret is the
+// current one; the others are being rotated out.
+type Signer struct {
+	secrets [][]byte
+}
+
+// NewSigner returns a Signer for the given secrets. Blank secrets are ignored
+// so an unset rotation slot in the configuration does no harm.
+func NewSigner(secrets ...string) (*Signer, error) {
+	signer := &Signer{}
+	for _, secret := range secrets {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			signer.secrets = append(signer.secrets, []byte(secret))
+		}
+	}
+	if len(signer.secrets) == 0 {
+		return nil, ErrNoSecrets
+	}
+	return signer, nil
+}
+
+// Sign returns the SignatureHeader value for body signed at the given time.
+func (s *Signer) Sign(body []byte, at time.Time) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+
+	var header strings.Builder
+	header.WriteString("t=" + timestamp)
+	for _, secret := range s.secrets {
+		header.WriteString("," + signatureScheme + "=" + hex.EncodeToString(computeSignature(secret, timestamp, body)))
+	}
+	return header.String()
+}
+
+// Verify checks a SignatureHeader value against body. It succeeds when any
+// signature matches any of the secrets and the timestamp is within tolerance
+// of now. A tolerance of zero or less disables the replay check.
+func Verify(header string, body []byte, secrets []string, tolerance time.Duration, now time.Time) error {
+	timestamp, signatures, err := parseHeader(header)
+	if err != nil {
+		return err
+	}
+
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrMalformedSignature
+	}
+	if tolerance > 0 {
+		if age := now.Sub(time.Unix(signedAt, 0)); age > tolerance || age < -tolerance {
+			return ErrTimestampTolerance
+		}
+	}
+
+	for _, secret := range secrets {
+		if secret = strings.TrimSpace(secret); secret == "" {
+			continue
+		}
+		expected := computeSignature([]byte(secret), timestamp, body)
+		for _, signature := range signatures {
+			if hmac.Equal(expected, signature) {
+				return nil
+			}
+		}
+	}
+	return ErrNoValidSignature
+}
+
+// parseHeader splits a header into its timestamp and v1 signatures. Unknown
+// schemes are skipped so new ones can be added without breaking receivers.
+func parseHeader(header string) (string, [][]byte, error) {
+	var timestamp string
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return "", nil, ErrMalformedSignature
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case signatureScheme:
+			signature, err := hex.DecodeString(value)
+			if err != nil {
+				return "", nil, fmt.Errorf("%w: %v", ErrMalformedSignature, err)
+			}
+			signatures = append(signatures, signature)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return "", nil, ErrMalformedSignature
+	}
+	return timestamp, signatures, nil
+}
+
+func computeSignature(secret []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}